@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/prgtw/tests-helper/internal/combine"
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/fileutil"
+	"github.com/prgtw/tests-helper/internal/logging"
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/metrics"
+	"github.com/prgtw/tests-helper/internal/normalize"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+// Report formats of the combine command.
+const (
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+)
+
+// combineOptions configures the combine command. The fields mirror its flags.
+type combineOptions struct {
+	ActualFiles    []string // JUnit XML reports of every shard (--actual)
+	Manifest       string   // Plan written by split --manifest (--manifest)
+	Output         string   // Report file, empty or "-" for stdout (--output)
+	Format         string   // markdown or json (--format)
+	DurationFormat string   // seconds or human, for the Markdown report (--duration-format)
+	Store          string   // Timing store to update, empty to skip (--store)
+	History        string   // Stability history to update, empty to skip (--history)
+	Version        string   // Tool version compared with the manifest's, from the build
+	Metrics        []string // statsd:// or pushgateway:// URLs receiving the stability (--metrics)
+	MetricsLabels  []string // key=value labels added to every metric (--metrics-labels)
+	Alpha          float64  // Weight of the new actual times in the store (--alpha)
+	MinStability   float64  // Lowest accepted stability, 0 to disable (--min-stability)
+	Top            int      // Number of most-changed files listed (--top)
+	HistoryRuns    int      // Number of runs kept in History (--history-runs)
+	StrictStats    bool     // Fail on unusable reports (--strict-stats)
+	Debug          bool     // Log at debug level (--debug)
+}
+
+// newCombineCmd creates the combine command.
+func newCombineCmd(logger zerolog.Logger, version string) *cobra.Command {
+	opts := combineOptions{
+		Version:        version,
+		ActualFiles:    []string{},
+		Metrics:        []string{},
+		MetricsLabels:  []string{},
+		Format:         formatMarkdown,
+		DurationFormat: string(duration.FormatSeconds),
+		Alpha:          combine.DefaultAlpha,
+		Top:            combine.DefaultTop,
+		HistoryRuns:    combine.DefaultHistory,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "combine",
+		Short: "Compare the actual times of all shards with the split plan",
+		Long: `Combine runs after every shard has finished. It reads the plan written by
+split --manifest and the JUnit XML reports of all shards, then reports the predicted
+and actual time of every worker and the files whose time changed most.
+
+With --store, the actual times are folded into the timing store with an exponential
+moving average (--alpha), so one unusually slow run does not dominate the next split.
+
+The stability score is 100 minus the mean absolute percentage error of the files'
+predicted times, files without an actual time counting as 100% off. --history keeps it
+across runs, and --min-stability fails the command (exit code 6) when it drops below a
+threshold: an early sign of broken timing data, such as the wrong artifacts or renamed paths.
+
+Examples:
+  tests-helper combine --manifest plan.json --actual 'artifacts/shard-*/junit.xml' --output report.md
+  tests-helper combine --manifest plan.json --actual artifacts/ --store .test-times.json --format json
+  tests-helper combine --manifest plan.json --actual artifacts/ --history stability.json --min-stability 60`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return runCombine(ctx, logger, &opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Manifest, "manifest", opts.Manifest, "Plan written by split --manifest")
+	cmd.Flags().StringSliceVar(&opts.ActualFiles, "actual", opts.ActualFiles,
+		"Path(s) to the JUnit XML reports of every shard or directories (supports glob patterns)")
+	cmd.Flags().StringVar(&opts.Output, "output", opts.Output, "Write the report to a file instead of stdout")
+	cmd.Flags().StringVar(&opts.Format, "format", opts.Format, "Report format: markdown or json")
+	cmd.Flags().StringVar(&opts.DurationFormat, "duration-format", opts.DurationFormat,
+		"Durations in the Markdown report: seconds (1873.4s) or human (31m13s)")
+	cmd.Flags().StringVar(&opts.Store, "store", opts.Store,
+		"Timing store to update with the actual times, created if missing")
+	cmd.Flags().Float64Var(&opts.Alpha, "alpha", opts.Alpha,
+		"Weight of an actual time against the stored one, in (0, 1]; 1 replaces stored times")
+	cmd.Flags().IntVar(&opts.Top, "top", opts.Top, "Number of most-changed files listed in the report")
+	cmd.Flags().StringVar(&opts.History, "history", opts.History,
+		"Stability history to record this run in and compare it with, created if missing")
+	cmd.Flags().IntVar(&opts.HistoryRuns, "history-runs", opts.HistoryRuns, "Number of runs kept in --history")
+	cmd.Flags().Float64Var(&opts.MinStability, "min-stability", opts.MinStability,
+		"Fail with exit code 6 when the stability score, from 0 to 100, is below this; 0 disables the check")
+	cmd.Flags().StringSliceVar(&opts.Metrics, "metrics", opts.Metrics,
+		"Emit the stability score to these statsd://host:port or pushgateway://host:port/job/<name> URLs")
+	cmd.Flags().StringSliceVar(&opts.MetricsLabels, "metrics-labels", opts.MetricsLabels,
+		"Labels added to every metric, as key=value pairs")
+	cmd.Flags().BoolVar(&opts.StrictStats, "strict-stats", opts.StrictStats,
+		"Fail on unreadable, invalid, or truncated reports")
+	cmd.Flags().BoolVar(&opts.Debug, "debug", opts.Debug, "Enable debug logging")
+
+	return cmd
+}
+
+// runCombine compares the actual times with the plan, updates the store and the history when
+// requested, and writes the report to stdout unless opts.Output is set. The stability is checked
+// last, so a failing run still records and reports it.
+func runCombine(ctx context.Context, logger zerolog.Logger, opts *combineOptions, stdout io.Writer) error {
+	if opts.Debug {
+		logger = logger.Level(zerolog.DebugLevel)
+	} else {
+		logger = logger.Level(zerolog.InfoLevel)
+	}
+
+	if err := validateCombineOptions(opts); err != nil {
+		return usageError(err)
+	}
+	labels, err := metrics.ParseLabels(opts.MetricsLabels)
+	if err != nil {
+		return usageError(err)
+	}
+	sinks, err := openSinks(opts.Metrics)
+	if err != nil {
+		return usageError(err)
+	}
+
+	plan, err := readManifest(logger, opts.Manifest, opts.Version)
+	if err != nil {
+		return inputError(err)
+	}
+
+	ts := testsplit.New(
+		testsplit.WithLogger(slog.New(logging.NewHandler(logger))),
+		testsplit.WithStrictStats(opts.StrictStats),
+	)
+	actual, err := ts.LoadSources(ctx, ts.JUnitFiles(opts.ActualFiles...))
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return statsError(err)
+	}
+	if len(actual) == 0 {
+		logger.Warn().Strs("actual", opts.ActualFiles).Msg("No actual times found")
+	}
+
+	report := combine.Compare(plan, actual, normalize.New().Key, opts.Top)
+	logger.Info().
+		Float64("predicted", report.Predicted).
+		Float64("actual", report.Actual).
+		Float64("mean_abs_error_percent", report.MeanAbsErrorPercent).
+		Float64("file_mape_percent", report.FileErrorPercent).
+		Float64("stability", report.Stability).
+		Int("missing", report.Missing).
+		Int("unplanned", report.Unplanned).
+		Msg("Compared actual times with the plan")
+	if err = updateHistory(logger, opts, &report); err != nil {
+		return err
+	}
+
+	if err = updateStore(logger, opts, actual); err != nil {
+		return err
+	}
+
+	write := func(w io.Writer) error { return report.WriteMarkdown(w, duration.Format(opts.DurationFormat)) }
+	if opts.Format == formatJSON {
+		write = report.WriteJSON
+	}
+	if err = writeReport(opts.Output, write, stdout); err != nil {
+		return err
+	}
+
+	gauges := metrics.StabilityGauges(labels, report.Stability, report.FileErrorPercent)
+	metrics.Emit(ctx, logger, sinks, gauges, metrics.DefaultTimeout)
+	if opts.MinStability > 0 && report.Stability < opts.MinStability {
+		err = fmt.Errorf("stability %.1f is below --min-stability %g", report.Stability, opts.MinStability)
+		return unstableError(err)
+	}
+	return nil
+}
+
+// updateStore folds the actual times into the --store timing store, if any.
+func updateStore(logger zerolog.Logger, opts *combineOptions, actual map[string]float64) error {
+	if opts.Store == "" || len(actual) == 0 {
+		return nil
+	}
+	previous, err := readStore(opts.Store)
+	if err != nil {
+		return statsError(err)
+	}
+	merged := combine.MergeEMA(previous, actual, opts.Alpha)
+	if _, err = writeStore(opts.Store, merged); err != nil {
+		return err
+	}
+	logger.Info().Int("updated", len(actual)).Int("keys", len(merged)).Str("store", opts.Store).
+		Msg("Updated timing store")
+	return nil
+}
+
+// updateHistory records the stability of report in the --history file, if any, after setting
+// the stability of the previous run on report. A missing file starts a new history.
+func updateHistory(logger zerolog.Logger, opts *combineOptions, report *combine.Report) error {
+	if opts.History == "" {
+		return nil
+	}
+	history, err := readHistory(opts.History)
+	if err != nil {
+		return inputError(err)
+	}
+	if last, ok := history.Last(); ok {
+		report.PreviousStability = &last.Stability
+		logger.Info().
+			Float64("stability", report.Stability).
+			Float64("previous_stability", last.Stability).
+			Float64("change", report.Stability-last.Stability).
+			Msg("Compared stability with the previous run")
+	}
+
+	var buf bytes.Buffer
+	if err = history.Add(report.Run(time.Now().UTC()), opts.HistoryRuns).Write(&buf); err != nil {
+		return err
+	}
+	if err = fileutil.WriteAtomic(opts.History, buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot write stability history: %w", err)
+	}
+	return nil
+}
+
+// readHistory reads the stability history at path, empty when the file does not exist.
+func readHistory(path string) (combine.History, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return combine.History{}, nil
+	}
+	if err != nil {
+		return combine.History{}, fmt.Errorf("cannot open stability history: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	return combine.ReadHistory(f)
+}
+
+// openSinks opens the metrics sink of every URL.
+func openSinks(urls []string) ([]metrics.Sink, error) {
+	sinks := make([]metrics.Sink, 0, len(urls))
+	for _, raw := range urls {
+		sink, err := metrics.Open(raw)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// validateCombineOptions checks the flags of the combine command.
+func validateCombineOptions(opts *combineOptions) error {
+	switch {
+	case opts.Manifest == "":
+		return errors.New("--manifest is required")
+	case len(opts.ActualFiles) == 0:
+		return errors.New("--actual is required")
+	case opts.Format != formatMarkdown && opts.Format != formatJSON:
+		return fmt.Errorf("invalid --format %q (expected %s or %s)", opts.Format, formatMarkdown, formatJSON)
+	case opts.Alpha <= 0 || opts.Alpha > 1:
+		return fmt.Errorf("invalid --alpha %g (expected a value in (0, 1])", opts.Alpha)
+	case opts.Top < 0:
+		return fmt.Errorf("invalid --top %d (expected 0 or more)", opts.Top)
+	case opts.MinStability < 0 || opts.MinStability > 100 || math.IsNaN(opts.MinStability):
+		return fmt.Errorf("invalid --min-stability %g (expected 0 to 100)", opts.MinStability)
+	}
+	if opts.DurationFormat == "" {
+		// The zero format renders seconds
+		return nil
+	}
+	_, err := duration.ParseFormat(opts.DurationFormat)
+	return err
+}
+
+// readManifest reads the plan at path, warning when it was written by a version of the tool other
+// than version: the schema matches, but the plan may have been computed differently.
+func readManifest(logger zerolog.Logger, path, version string) (manifest.Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return manifest.Manifest{}, fmt.Errorf("cannot open manifest: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	m, err := manifest.Read(f)
+	if err != nil {
+		return manifest.Manifest{}, err
+	}
+	if manifest.ToolVersionMismatch(m, version) {
+		logger.Warn().
+			Str("manifest", path).
+			Str("manifest_version", m.ToolVersion).
+			Str("version", version).
+			Msg("Manifest was written by another version of tests-helper")
+	}
+	return m, nil
+}
+
+// writeReport writes a report with write to the file at path, or to stdout when path is empty or "-".
+func writeReport(path string, write func(io.Writer) error, stdout io.Writer) error {
+	if path == "" || path == "-" {
+		return write(stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create report: %w", err)
+	}
+	if err = write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+func TestCombineCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	storePath := write("times.json", `{"a_test.go": 4, "b_test.go": 2, "c_test.go": 2}`)
+	previous := write("previous.xml", `<testsuites><testsuite file="a_test.go" time="4"/>`+
+		`<testsuite file="b_test.go" time="2"/><testsuite file="c_test.go" time="2"/></testsuites>`)
+	write("shard-0/junit.xml", `<testsuite file="a_test.go" time="8"/>`)
+	write("shard-1/junit.xml", `<testsuites><testsuite file="b_test.go" time="2"/>`+
+		`<testsuite file="c_test.go" time="2"/></testsuites>`)
+
+	// The plan comes from a split run like any shard's: a_test.go alone on worker 0
+	plan := filepath.Join(dir, "plan.json")
+	args := []string{"split", "--index", "0", "--total", "2", "--no-percentiles", "--stats", previous,
+		"--input", write("tests.txt", "a_test.go\nb_test.go\nc_test.go\n"), "--manifest", plan}
+	if code := cmd.Main(args, io.Discard); code != cmd.ExitOK {
+		t.Fatalf("Split exit code = %d", code)
+	}
+
+	var stdout bytes.Buffer
+	opts := &cmd.CombineOptions{
+		Manifest:    plan,
+		ActualFiles: []string{filepath.Join(dir, "shard-*", "junit.xml")},
+		Format:      "markdown",
+		Store:       storePath,
+		Alpha:       0.5,
+		Top:         5,
+	}
+	if err := cmd.RunCombine(t.Context(), zerolog.Nop(), opts, &stdout); err != nil {
+		t.Fatalf("RunCombine failed: %v", err)
+	}
+	for _, want := range []string{"| 0 | 1 | 4.0s | 8.0s | +100.0% |", "| `a_test.go` | 0 | 4.0s | 8.0s | +4.0s |"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Report lacks %q:\n%s", want, stdout.String())
+		}
+	}
+
+	got, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to read store: %v", err)
+	}
+	if want := "{\n  \"a_test.go\": 6,\n  \"b_test.go\": 2,\n  \"c_test.go\": 2\n}\n"; string(got) != want {
+		t.Errorf("Store = %s, want %s", got, want)
+	}
+}
+
+func TestCombineCommand_Stability(t *testing.T) {
+	dir := t.TempDir()
+	plan, tests := filepath.Join(dir, "plan.json"), filepath.Join(dir, "tests.txt")
+	if err := os.WriteFile(tests, []byte("a_test.go 10\nb_test.go 4\nc_test.go 5\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write test list: %v", err)
+	}
+	args := []string{"split", "--index", "0", "--total", "1", "--no-percentiles", "--inline-times",
+		"--input", tests, "--manifest", plan}
+	if code := cmd.Main(args, io.Discard); code != cmd.ExitOK {
+		t.Fatalf("Split exit code = %d", code)
+	}
+	report := filepath.Join(dir, "junit.xml")
+	content := `<testsuites><testsuite file="a_test.go" time="12"/><testsuite file="b_test.go" time="3"/>` +
+		`<testsuite file="c_test.go" time="5"/></testsuites>`
+	if err := os.WriteFile(report, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	opts := &cmd.CombineOptions{
+		Manifest:     plan,
+		ActualFiles:  []string{report},
+		Format:       "json",
+		History:      filepath.Join(dir, "history", "stability.json"),
+		MinStability: 80,
+		Alpha:        0.3,
+	}
+	var got struct {
+		FileErrorPercent  float64  `json:"file_mape_percent"`
+		Stability         float64  `json:"stability"`
+		PreviousStability *float64 `json:"previous_stability"`
+	}
+	for run := range 2 {
+		var stdout bytes.Buffer
+		if err := cmd.RunCombine(t.Context(), zerolog.Nop(), opts, &stdout); err != nil {
+			t.Fatalf("RunCombine failed: %v", err)
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("Invalid JSON report: %v", err)
+		}
+		// Off by 20%, 25%, and 0%
+		if got.FileErrorPercent != 15 || got.Stability != 85 || (got.PreviousStability != nil) != (run == 1) {
+			t.Errorf("Run %d: MAPE %v, stability %v, previous %v, want 15, 85, and a previous run only the second time",
+				run, got.FileErrorPercent, got.Stability, got.PreviousStability)
+		}
+	}
+	data, err := os.ReadFile(opts.History)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if n := strings.Count(string(data), `"stability": 85`); n != 2 {
+		t.Errorf("History records %d runs, want 2:\n%s", n, data)
+	}
+
+	opts.MinStability = 90
+	err = cmd.RunCombine(t.Context(), zerolog.Nop(), opts, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUnstable {
+		t.Errorf("Exit code = %d (%v), want %d below --min-stability", code, err, cmd.ExitUnstable)
+	}
+}
+
+func TestCombineCommand_Errors(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "junit.xml")
+	if err := os.WriteFile(report, []byte(`<testsuite file="a_test.go" time="1"/>`), 0o600); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	valid := cmd.CombineOptions{
+		Manifest:    filepath.Join(dir, "missing.json"),
+		ActualFiles: []string{report},
+		Format:      "markdown",
+		Alpha:       0.3,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*cmd.CombineOptions)
+		want   int
+	}{
+		{name: "missing manifest flag", modify: func(o *cmd.CombineOptions) { o.Manifest = "" }, want: cmd.ExitUsage},
+		{name: "invalid format", modify: func(o *cmd.CombineOptions) { o.Format = "html" }, want: cmd.ExitUsage},
+		{name: "invalid alpha", modify: func(o *cmd.CombineOptions) { o.Alpha = 1.5 }, want: cmd.ExitUsage},
+		{name: "invalid stability", modify: func(o *cmd.CombineOptions) { o.MinStability = 101 }, want: cmd.ExitUsage},
+		{name: "bad metrics", modify: func(o *cmd.CombineOptions) { o.Metrics = []string{"x"} }, want: cmd.ExitUsage},
+		{name: "unreadable manifest", modify: func(*cmd.CombineOptions) {}, want: cmd.ExitInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+			err := cmd.RunCombine(t.Context(), zerolog.Nop(), &opts, io.Discard)
+			if got := cmd.ExitCode(err); got != tt.want {
+				t.Errorf("Exit code = %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
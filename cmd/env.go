@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/prgtw/tests-helper/internal/config"
+)
+
+// envOptions configures the env command. The fields mirror its flags.
+type envOptions struct {
+	Format string // text or json (--format)
+	// Split holds the options shared with split: --ci, --index, --total, and --one-based.
+	Split SplitOptions
+}
+
+// envReport is the output of the env command.
+type envReport struct {
+	CI     string   `json:"ci"`               // Provider detected or selected with --ci, none without
+	Marker string   `json:"marker,omitempty"` // Variable the provider was detected by, with --ci auto
+	Index  envValue `json:"index"`
+	Total  envValue `json:"total"`
+	Error  string   `json:"error,omitempty"` // Why split would reject the index and total
+}
+
+// envValue is a resolved worker index or total with its origin.
+type envValue struct {
+	Value    int    `json:"value"`              // Counting from 0 for the index
+	Origin   string `json:"origin"`             // Flag or variable, or "default"
+	Provider string `json:"provider,omitempty"` // CI provider setting the variable
+	OneBased bool   `json:"one_based,omitempty"`
+}
+
+// newEnvCmd creates the env command.
+func newEnvCmd() *cobra.Command {
+	opts := envOptions{Format: formatText, Split: DefaultSplitOptions()}
+	split := &opts.Split
+
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Show the detected CI provider and the resolved worker index and total",
+		Long: `Env resolves the worker index and total from the flags and the environment exactly
+like split does, and prints them with the CI provider and the variables they came
+from, to debug pipelines. It exits with code 2 when split would reject them.
+
+Examples:
+  tests-helper env
+  tests-helper env --ci gitlab --format json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runEnv(&opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Format, "format", opts.Format, "Output format: text or json")
+	cmd.Flags().StringVar(&split.CI, "ci", split.CI,
+		"CI provider whose variables give the worker index and total: auto, circleci, github, gitlab, "+
+			"buildkite, semaphore, jenkins, or none")
+	cmd.Flags().IntVar(&split.Index, "index", split.Index, "Worker index, as passed to split")
+	cmd.Flags().IntVar(&split.Total, "total", split.Total, "Total number of workers, as passed to split")
+	cmd.Flags().BoolVar(&split.OneBased, "one-based", split.OneBased,
+		"--index and TESTS_HELPER_NODE_INDEX count from 1")
+
+	return cmd
+}
+
+// runEnv writes the detected CI provider and the resolved worker index and total to stdout,
+// then fails like split would when they are invalid.
+func runEnv(opts *envOptions, stdout io.Writer) error {
+	if opts.Format != formatText && opts.Format != formatJSON {
+		return usageError(fmt.Errorf("invalid --format %q (expected %s or %s)", opts.Format, formatText, formatJSON))
+	}
+	cfg, err := loadConfig(&opts.Split)
+	if err != nil {
+		return err
+	}
+
+	index := cfg.ResolveNodeIndex(opts.Split.Index, 0)
+	if opts.Split.OneBased {
+		index = config.FromOneBased(index)
+	}
+	total := cfg.ResolveNodeTotal(opts.Split.Total, 1)
+	report := envReport{CI: string(cfg.DetectCI()), Index: newEnvValue(index), Total: newEnvValue(total)}
+	if cfg.CI == config.CIAuto {
+		report.Marker = config.Marker(cfg.DetectCI())
+	}
+	invalid := config.ValidateNode(index, total)
+	if invalid != nil {
+		report.Error = invalid.Error()
+	}
+
+	if opts.Format == formatJSON {
+		err = writeJSON(stdout, &report, reportIndent)
+	} else {
+		err = report.writeText(stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if invalid != nil {
+		return usageError(invalid)
+	}
+	return nil
+}
+
+func newEnvValue(v config.Value) envValue {
+	return envValue{Value: v.Value, Origin: v.Origin(), Provider: v.Provider, OneBased: v.OneBased}
+}
+
+// writeText writes the report as one line per setting, followed by the error, if any.
+func (r *envReport) writeText(w io.Writer) error {
+	var b strings.Builder
+	switch {
+	case r.Marker != "":
+		fmt.Fprintf(&b, "CI provider:  %s (detected by %s)\n", r.CI, r.Marker)
+	case r.CI == string(config.CINone):
+		fmt.Fprintf(&b, "CI provider:  %s\n", r.CI)
+	default:
+		fmt.Fprintf(&b, "CI provider:  %s (selected by --ci)\n", r.CI)
+	}
+	fmt.Fprintf(&b, "Worker index: %s\n", r.Index)
+	fmt.Fprintf(&b, "Worker total: %s\n", r.Total)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "Error:        %s\n", r.Error)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// String formats the value with its origin, e.g. "1 (from CIRCLE_NODE_INDEX, CircleCI)".
+func (v envValue) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d (from %s", v.Value, v.Origin)
+	if v.Provider != "" {
+		b.WriteString(", " + v.Provider)
+	}
+	if v.OneBased {
+		b.WriteString(", counting from 1")
+	}
+	b.WriteString(")")
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+// clearCI unsets the marker variables of every CI provider for the duration of the test, so it
+// passes on any CI.
+func clearCI(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"CIRCLECI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "SEMAPHORE", "JENKINS_URL"} {
+		t.Setenv(name, "") // Restores the variable afterwards
+		if err := os.Unsetenv(name); err != nil {
+			t.Fatalf("Failed to unset %s: %v", name, err)
+		}
+	}
+}
+
+func newEnvOptions() *cmd.EnvOptions {
+	return &cmd.EnvOptions{Format: "text", Split: cmd.DefaultSplitOptions()}
+}
+
+func TestEnvCommand_Text(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		ci   string
+		want string
+	}{
+		{
+			name: "detected",
+			env:  map[string]string{"CIRCLECI": "true", "CIRCLE_NODE_INDEX": "1", "CIRCLE_NODE_TOTAL": "3"},
+			want: "CI provider:  circleci (detected by CIRCLECI)\n" +
+				"Worker index: 1 (from CIRCLE_NODE_INDEX, CircleCI)\n" +
+				"Worker total: 3 (from CIRCLE_NODE_TOTAL, CircleCI)\n",
+		},
+		{
+			name: "selected",
+			env:  map[string]string{"CI_NODE_INDEX": "2", "CI_NODE_TOTAL": "2"},
+			ci:   "gitlab",
+			want: "CI provider:  gitlab (selected by --ci)\n" +
+				"Worker index: 1 (from CI_NODE_INDEX, GitLab CI, counting from 1)\n" +
+				"Worker total: 2 (from CI_NODE_TOTAL, GitLab CI)\n",
+		},
+		{
+			name: "none",
+			want: "CI provider:  none\n" +
+				"Worker index: 0 (from default)\n" +
+				"Worker total: 1 (from default)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCI(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			opts := newEnvOptions()
+			if tt.ci != "" {
+				opts.Split.CI = tt.ci
+			}
+
+			var stdout bytes.Buffer
+			if err := cmd.RunEnv(opts, &stdout); err != nil {
+				t.Fatalf("RunEnv failed: %v", err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("Output =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvCommand_JSON(t *testing.T) {
+	clearCI(t)
+	t.Setenv("SEMAPHORE", "true")
+	t.Setenv("SEMAPHORE_JOB_INDEX", "2")
+	t.Setenv("SEMAPHORE_JOB_COUNT", "4")
+
+	tree := cmd.NewCommandTree(zerolog.Nop(), cmd.BuildInfo{})
+	var stdout bytes.Buffer
+	tree.SetOut(&stdout)
+	tree.SetArgs([]string{"env", "--format", "json", "--total", "5"})
+	if _, err := tree.ExecuteC(); err != nil {
+		t.Fatalf("ExecuteC failed: %v", err)
+	}
+
+	want := `{
+  "ci": "semaphore",
+  "marker": "SEMAPHORE",
+  "index": {
+    "value": 1,
+    "origin": "SEMAPHORE_JOB_INDEX",
+    "provider": "Semaphore",
+    "one_based": true
+  },
+  "total": {
+    "value": 5,
+    "origin": "--total"
+  }
+}
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("Output =\n%s\nwant\n%s", got, want)
+	}
+	if !json.Valid(stdout.Bytes()) {
+		t.Errorf("Output is not valid JSON")
+	}
+}
+
+func TestEnvCommand_Invalid(t *testing.T) {
+	clearCI(t)
+	t.Setenv("BUILDKITE", "true")
+	t.Setenv("BUILDKITE_PARALLEL_JOB", "1")
+
+	var stdout bytes.Buffer
+	err := cmd.RunEnv(newEnvOptions(), &stdout)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Exit code = %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+	want := "Error:        invalid worker count: " +
+		"BUILDKITE_PARALLEL_JOB is set to 1 but BUILDKITE_PARALLEL_JOB_COUNT is missing"
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("Output =\n%s\nwant containing %q", stdout.String(), want)
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts func(*cmd.EnvOptions)
+		want string
+	}{
+		{"format", func(o *cmd.EnvOptions) { o.Format = "yaml" }, `invalid --format "yaml"`},
+		{"ci", func(o *cmd.EnvOptions) { o.Split.CI = "travis" }, `unknown CI provider "travis"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := newEnvOptions()
+			tt.opts(opts)
+			err := cmd.RunEnv(opts, &bytes.Buffer{})
+			if code := cmd.ExitCode(err); code != cmd.ExitUsage || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Error = %v (exit code %d), want %q with %d", err, code, tt.want, cmd.ExitUsage)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/splitter"
+	"github.com/prgtw/tests-helper/internal/storage"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// Process exit codes, one per error category.
+const (
+	ExitOK       = 0 // Success
+	ExitInternal = 1 // Unexpected failure, e.g. writing the output
+	ExitUsage    = 2 // Invalid flags, arguments, or worker configuration
+	ExitInput    = 3 // The test list could not be read or was empty
+	ExitStats    = 4 // Stats files could not be used (with --strict-stats)
+	ExitUpload   = 5 // The timing store could not be uploaded (record --upload)
+	ExitUnstable = 6 // Predictions degraded below combine --min-stability
+	ExitCoverage = 7 // Too many tests without stats (verify --max-missing)
+
+	ExitInterrupted = 130 // Cancelled by SIGINT or SIGTERM (128 + SIGINT, as shells report it)
+)
+
+// exitCodesHelp documents the exit codes in the command help.
+const exitCodesHelp = `Exit codes:
+  0    success
+  1    internal error
+  2    invalid usage (flags, arguments, worker index or total)
+  3    unreadable or empty test list
+  4    unusable stats files (with --strict-stats)
+  5    failed upload of the timing store (record --upload)
+  6    stability below combine --min-stability
+  7    too many tests without stats (verify --max-missing, --max-missing-percent)
+  *    exit code of the test command (split --exec)
+  130  interrupted by SIGINT or SIGTERM`
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageError marks err as caused by invalid flags, arguments, or configuration.
+func usageError(err error) error {
+	return &exitError{err: err, code: ExitUsage}
+}
+
+// inputError marks err as caused by the test list.
+func inputError(err error) error {
+	return &exitError{err: err, code: ExitInput}
+}
+
+// statsError marks err as caused by the stats files.
+func statsError(err error) error {
+	return &exitError{err: err, code: ExitStats}
+}
+
+// uploadError marks err as caused by uploading the timing store.
+func uploadError(err error) error {
+	return &exitError{err: err, code: ExitUpload}
+}
+
+// unstableError marks err as a stability score below --min-stability.
+func unstableError(err error) error {
+	return &exitError{err: err, code: ExitUnstable}
+}
+
+// coverageError marks err as more tests without stats than verify allows.
+func coverageError(err error) error {
+	return &exitError{err: err, code: ExitCoverage}
+}
+
+// commandError marks err as the failure of the command run by split --exec, whose exit code
+// the process exits with.
+func commandError(err error, code int) error {
+	return &exitError{err: err, code: code}
+}
+
+// exitCode maps err to the process exit code.
+// Explicitly categorized errors take precedence over the sentinels of the internal packages.
+func exitCode(err error) int {
+	var exitErr *exitError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case errors.Is(err, worker.ErrInvalidWorkerCount), errors.Is(err, worker.ErrInvalidWorkerIndex),
+		errors.Is(err, worker.ErrResourceLimit):
+		return ExitUsage
+	case errors.Is(err, splitter.ErrNoTests):
+		return ExitInput
+	case errors.Is(err, junit.ErrNoStatsMatched), errors.Is(err, junit.ErrNoUsableStats),
+		errors.Is(err, storage.ErrNoObjectsMatched):
+		return ExitStats
+	default:
+		return ExitInternal
+	}
+}
@@ -0,0 +1,107 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/cmd"
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/splitter"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+func TestMain_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tests.txt")
+	if err := os.WriteFile(input, []byte("a_test.go\nb_test.go\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input file: %v", err)
+	}
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, []byte("# nothing here\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input file: %v", err)
+	}
+	malformed := filepath.Join(dir, "malformed.txt")
+	if err := os.WriteFile(malformed, []byte("a_test.go -1\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input file: %v", err)
+	}
+	report := filepath.Join(dir, "report.xml")
+	if err := os.WriteFile(report, []byte("<testsuites><testsuite"), 0o600); err != nil {
+		t.Fatalf("Failed to create report file: %v", err)
+	}
+	split := []string{"split", "--no-percentiles", "--index", "0", "--total", "2"}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "success", args: append(split, "--input", input), want: cmd.ExitOK},
+		{name: "unknown flag", args: append(split, "--bogus"), want: cmd.ExitUsage},
+		{name: "unknown command", args: []string{"bogus"}, want: cmd.ExitUsage},
+		{name: "invalid total", args: []string{"split", "--input", input, "--total", "0"}, want: cmd.ExitUsage},
+		{name: "invalid match mode", args: append(split, "--input", input, "--match", "fuzzy"), want: cmd.ExitUsage},
+		{name: "missing input file", args: append(split, "--input", filepath.Join(dir, "missing.txt")), want: cmd.ExitInput},
+		{name: "empty input", args: append(split, "--input", empty), want: cmd.ExitInput},
+		{name: "malformed input", args: append(split, "--input", malformed, "--inline-times"), want: cmd.ExitInput},
+		{
+			name: "strict stats with a malformed report",
+			args: append(split, "--input", input, "--strict-stats", "--stats", report),
+			want: cmd.ExitStats,
+		},
+		{
+			name: "strict stats without matches",
+			args: append(split, "--input", input, "--strict-stats", "--stats", filepath.Join(dir, "*.json")),
+			want: cmd.ExitStats,
+		},
+		{
+			name: "lenient stats without matches",
+			args: append(split, "--input", input, "--stats", filepath.Join(dir, "*.json")),
+			want: cmd.ExitOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			if got := cmd.Main(tt.args, &stderr); got != tt.want {
+				t.Errorf("Exit code: got %d, want %d\n%s", got, tt.want, stderr.String())
+			}
+
+			failures := strings.Count(stderr.String(), "Command failed")
+			if (tt.want == cmd.ExitOK && failures != 0) || (tt.want != cmd.ExitOK && failures != 1) {
+				t.Errorf("Got %d error lines, output:\n%s", failures, stderr.String())
+			}
+			if strings.Contains(stderr.String(), "Usage:") {
+				t.Errorf("Usage should not be printed on error, output:\n%s", stderr.String())
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: nil, want: cmd.ExitOK},
+		{err: errors.New("boom"), want: cmd.ExitInternal},
+		{err: fmt.Errorf("failed to read tests: %w", splitter.ErrNoTests), want: cmd.ExitInput},
+		{err: fmt.Errorf("failed to load stats files: %w", junit.ErrNoStatsMatched), want: cmd.ExitStats},
+		{err: fmt.Errorf("wrapped: %w", junit.ErrNoUsableStats), want: cmd.ExitStats},
+		{err: fmt.Errorf("loading stats interrupted: %w", context.Canceled), want: cmd.ExitInterrupted},
+		{err: fmt.Errorf("cannot split tests: %w", worker.ErrInvalidWorkerCount), want: cmd.ExitUsage},
+		{err: fmt.Errorf("wrapped: %w", worker.ErrInvalidWorkerIndex), want: cmd.ExitUsage},
+	}
+
+	for _, tt := range tests {
+		if got := cmd.ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
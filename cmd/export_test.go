@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"io"
+	"os"
+)
+
+// Exported aliases of unexported functions for use in cmd_test.
+var (
+	WriteOutput = writeOutput //nolint:gochecknoglobals // test-only export
+	ExitCode    = exitCode    //nolint:gochecknoglobals // test-only export
+	NewRootCmd  = newRootCmd  //nolint:gochecknoglobals // test-only export
+
+	NewCommandTree = newCommandTree //nolint:gochecknoglobals // test-only export
+	RunServe       = runServe       //nolint:gochecknoglobals // test-only export
+	RunRecord      = runRecord      //nolint:gochecknoglobals // test-only export
+	RunCombine     = runCombine     //nolint:gochecknoglobals // test-only export
+	RunPlanDiff    = runPlanDiff    //nolint:gochecknoglobals // test-only export
+	RunStats       = runStats       //nolint:gochecknoglobals // test-only export
+	RunVerify      = runVerify      //nolint:gochecknoglobals // test-only export
+	RunEnv         = runEnv         //nolint:gochecknoglobals // test-only export
+	RunSplitWith   = runSplit       //nolint:gochecknoglobals // test-only export
+)
+
+// ServeOptions exposes the serve command options to cmd_test.
+type ServeOptions = serveOptions
+
+// RecordOptions exposes the record command options to cmd_test.
+type RecordOptions = recordOptions
+
+// CombineOptions exposes the combine command options to cmd_test.
+type CombineOptions = combineOptions
+
+// PlanDiffOptions exposes the plan-diff command options to cmd_test.
+type PlanDiffOptions = planDiffOptions
+
+// StatsOptions exposes the stats command options to cmd_test.
+type StatsOptions = statsOptions
+
+// VerifyOptions exposes the verify command options to cmd_test.
+type VerifyOptions = verifyOptions
+
+// EnvOptions exposes the env command options to cmd_test.
+type EnvOptions = envOptions
+
+// testBuildInfo is the build information used by the test entry points.
+var testBuildInfo = BuildInfo{Version: "test", Commit: "test", Date: "test"} //nolint:gochecknoglobals // test-only
+
+// Run executes the command line with logs written to stderr.
+func Run(args []string) error {
+	return execute(newLogger(os.Stderr), testBuildInfo, args)
+}
+
+// Main executes the command line like Execute, returning the exit code instead of exiting.
+func Main(args []string, stderr io.Writer) int {
+	return run(testBuildInfo, args, stderr)
+}
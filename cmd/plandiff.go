@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/prgtw/tests-helper/internal/config"
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/plandiff"
+	"github.com/prgtw/tests-helper/internal/store"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+// planDiffOptions configures the plan-diff command. The fields mirror its flags.
+type planDiffOptions struct {
+	StatsOld string // Timing store of the current plan (--stats-old)
+	StatsNew string // Timing store whose plan is previewed (--stats-new)
+	Output   string // Report file, empty or "-" for stdout (--output)
+	Format   string // markdown or json (--format)
+	// Split holds the options shared by both splits: --total, --input, --inline-times, --match,
+	// --dedupe, --granularity, --default-time, --zero-time, --resource, --resource-limit,
+	// --duration-format, and --debug.
+	Split SplitOptions
+}
+
+// newPlanDiffCmd creates the plan-diff command.
+func newPlanDiffCmd(logger zerolog.Logger) *cobra.Command {
+	opts := planDiffOptions{Format: formatMarkdown, Split: DefaultSplitOptions()}
+	split := &opts.Split
+
+	cmd := &cobra.Command{
+		Use:   "plan-diff",
+		Short: "Preview how new timings would change the split",
+		Long: `Plan-diff splits the same test list twice with identical options, once with the
+timings of --stats-old and once with those of --stats-new, and reports the predicted
+time of every worker before and after, the tests that would move to another worker,
+and the change in imbalance. Nothing is written besides the report.
+
+Examples:
+  tests-helper plan-diff --stats-old old.json --stats-new .test-times.json --total 8 < tests.txt
+  tests-helper plan-diff --stats-old old.json --stats-new new.json --total 8 --input tests.txt --format json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return runPlanDiff(ctx, logger, &opts, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.StatsOld, "stats-old", opts.StatsOld,
+		"Timing store (JSON map of test name to seconds) of the current plan")
+	cmd.Flags().StringVar(&opts.StatsNew, "stats-new", opts.StatsNew,
+		"Timing store (JSON map of test name to seconds) whose plan is previewed")
+	cmd.Flags().StringVar(&opts.Output, "output", opts.Output, "Write the report to a file instead of stdout")
+	cmd.Flags().StringVar(&opts.Format, "format", opts.Format, "Report format: markdown or json")
+	cmd.Flags().IntVar(&split.Total, "total", split.Total, "Total number of workers")
+	cmd.Flags().StringVar(&split.InputFile, "input", split.InputFile, "Read the test list from a file instead of stdin")
+	cmd.Flags().BoolVar(&split.InlineTimes, "inline-times", split.InlineTimes,
+		"Treat a trailing number on an input line as that test's time")
+	cmd.Flags().StringVar(&split.MatchMode, "match", split.MatchMode, "Stats key matching: exact or suffix")
+	cmd.Flags().StringVar(&split.Dedupe, "dedupe", split.Dedupe, "Duplicate test lines: keep or first")
+	cmd.Flags().StringVar(&split.Algorithm, "algorithm", split.Algorithm,
+		"How tests are assigned to workers: greedy, roundrobin, or kk")
+	cmd.Flags().StringVar(&split.Granularity, "granularity", split.Granularity,
+		"What is scheduled: file or suite")
+	cmd.Flags().Float64Var(&split.DefaultTime, "default-time", split.DefaultTime,
+		"Time in seconds used for tests without stats")
+	cmd.Flags().Float64Var(&split.ZeroTime, "zero-time", split.ZeroTime,
+		"Time in seconds used for tests recorded as taking zero seconds")
+	cmd.Flags().StringArrayVar(&split.Resources, "resource", split.Resources,
+		"Tag the tests matching a glob with a resource, as PATTERN=TAG (repeatable)")
+	cmd.Flags().StringArrayVar(&split.ResourceLimits, "resource-limit", split.ResourceLimits,
+		"At most N tests tagged TAG per worker, as TAG=N (repeatable)")
+	cmd.Flags().StringVar(&split.DurationFormat, "duration-format", split.DurationFormat,
+		"Durations in the Markdown report: seconds (1873.4s) or human (31m13s)")
+	cmd.Flags().BoolVar(&split.Debug, "debug", split.Debug, "Enable debug logging")
+
+	return cmd
+}
+
+// runPlanDiff splits the test list with the old and the new timings and writes the difference
+// to stdout unless opts.Output is set.
+func runPlanDiff(
+	ctx context.Context, logger zerolog.Logger, opts *planDiffOptions, stdin io.Reader, stdout io.Writer,
+) error {
+	if opts.Split.Debug {
+		logger = logger.Level(zerolog.DebugLevel)
+	} else {
+		logger = logger.Level(zerolog.InfoLevel)
+	}
+
+	if err := validatePlanDiffOptions(opts); err != nil {
+		return usageError(err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return usageError(fmt.Errorf("failed to load configuration: %w", err))
+	}
+	total := cfg.ResolveNodeTotal(opts.Split.Total, 1)
+	if err = config.ValidateTotal(total); err != nil {
+		return usageError(err)
+	}
+	ts, err := newTestSplit(logger, &opts.Split)
+	if err != nil {
+		return usageError(err)
+	}
+
+	list, err := readTestList(opts.Split.InputFile, stdin)
+	if err != nil {
+		return inputError(err)
+	}
+	before, err := planWith(ctx, logger, ts, opts.StatsOld, list, total.Value)
+	if err != nil {
+		return err
+	}
+	after, err := planWith(ctx, logger, ts, opts.StatsNew, list, total.Value)
+	if err != nil {
+		return err
+	}
+
+	report := plandiff.Compare(before, after)
+	logger.Info().
+		Float64("predicted_before", report.Before).
+		Float64("predicted_after", report.After).
+		Float64("imbalance_before", report.ImbalanceBefore).
+		Float64("imbalance_after", report.ImbalanceAfter).
+		Int("moved", len(report.Moved)).
+		Int("tests", report.Tests).
+		Msg("Compared the plans of the old and new timings")
+
+	write := func(w io.Writer) error { return report.WriteMarkdown(w, duration.Format(opts.Split.DurationFormat)) }
+	if opts.Format == formatJSON {
+		write = report.WriteJSON
+	}
+	return writeReport(opts.Output, write, stdout)
+}
+
+// validatePlanDiffOptions checks the flags of the plan-diff command.
+func validatePlanDiffOptions(opts *planDiffOptions) error {
+	switch {
+	case opts.StatsOld == "" || opts.StatsNew == "":
+		return errors.New("--stats-old and --stats-new are required")
+	case opts.Format != formatMarkdown && opts.Format != formatJSON:
+		return fmt.Errorf("invalid --format %q (expected %s or %s)", opts.Format, formatMarkdown, formatJSON)
+	}
+	return nil
+}
+
+// readTestList reads the whole test list from the file at path, or from stdin when path is empty,
+// so it can be split more than once.
+func readTestList(path string, stdin io.Reader) ([]byte, error) {
+	if path == "" {
+		list, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read test list: %w", err)
+		}
+		return list, nil
+	}
+	list, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	return list, nil
+}
+
+// planWith splits list across total workers with the times of the timing store at path.
+func planWith(
+	ctx context.Context, logger zerolog.Logger, ts *testsplit.Splitter, path string, list []byte, total int,
+) (manifest.Manifest, error) {
+	times, err := ts.LoadSources(ctx, store.NewSource(path))
+	if err != nil {
+		if ctx.Err() != nil {
+			return manifest.Manifest{}, err
+		}
+		return manifest.Manifest{}, statsError(err)
+	}
+	tests, err := ts.ReadTests(bytes.NewReader(list), times)
+	if err != nil {
+		return manifest.Manifest{}, inputError(err)
+	}
+	result, err := ts.SplitInPlace(tests, total)
+	if err != nil {
+		return manifest.Manifest{}, usageError(err)
+	}
+	stats := result.Stats(testsplit.StatsOptions{})
+	logger.Info().
+		Str("store", path).
+		Int("keys", len(times)).
+		Float64("imbalance", stats.Imbalance()).
+		Msg("Planned split")
+	return manifest.Manifest{Groups: result.Groups(), Distribution: stats}, nil
+}
@@ -0,0 +1,105 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+func TestPlanDiffCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	// Old: a alone on worker 0, c, d, b on worker 1. New: b takes 9s, so d moves to worker 0
+	oldStore := write("old.json", `{"a_test.go": 10, "b_test.go": 2, "c_test.go": 4, "d_test.go": 4}`)
+	newStore := write("new.json", `{"a_test.go": 10, "b_test.go": 9, "c_test.go": 4, "d_test.go": 4}`)
+	list := write("tests.txt", "a_test.go\nb_test.go\nc_test.go\nd_test.go\n")
+	args := []string{"plan-diff", "--stats-old", oldStore, "--stats-new", newStore, "--total", "2", "--input", list}
+
+	report := filepath.Join(dir, "diff.md")
+	if code := cmd.Main(append(args, "--output", report), io.Discard); code != cmd.ExitOK {
+		t.Fatalf("Exit code = %d", code)
+	}
+	md, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	for _, want := range []string{
+		"imbalance **1.00** → **1.04** (+0.04); 1 of 4 tests move",
+		"| 0 | 1 → 2 | 10.0s | 14.0s | +4.0s | 1 | 0 |",
+		"| 1 | 3 → 2 | 10.0s | 13.0s | +3.0s | 0 | 1 |",
+		"| `d_test.go` | 1 | 0 | 4.0s | 4.0s |",
+	} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("Report lacks %q:\n%s", want, md)
+		}
+	}
+
+	var stdout bytes.Buffer
+	opts := &cmd.PlanDiffOptions{
+		StatsOld: oldStore, StatsNew: newStore, Format: "json", Split: cmd.DefaultSplitOptions(),
+	}
+	opts.Split.Total = 2
+	stdin := strings.NewReader("a_test.go\nb_test.go\nc_test.go\nd_test.go\n")
+	if err = cmd.RunPlanDiff(t.Context(), zerolog.Nop(), opts, stdin, &stdout); err != nil {
+		t.Fatalf("RunPlanDiff failed: %v", err)
+	}
+	var got struct {
+		Moved []struct {
+			Name string `json:"name"`
+		} `json:"moved"`
+		ImbalanceBefore float64 `json:"imbalance_before"`
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", stdout.String(), err)
+	}
+	if len(got.Moved) != 1 || got.Moved[0].Name != "d_test.go" || got.ImbalanceBefore != 1 {
+		t.Errorf("JSON report = %+v", got)
+	}
+}
+
+func TestPlanDiffCommand_Errors(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "times.json")
+	if err := os.WriteFile(store, []byte(`{"a_test.go": 1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list := filepath.Join(dir, "tests.txt")
+	if err := os.WriteFile(list, []byte("a_test.go\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"missing store flag", []string{"--stats-old", store}, cmd.ExitUsage},
+		{"invalid format", []string{"--stats-old", store, "--stats-new", store, "--format", "xml"}, cmd.ExitUsage},
+		{"invalid total", []string{"--stats-old", store, "--stats-new", store, "--total", "0"}, cmd.ExitUsage},
+		{"missing store", []string{"--stats-old", store, "--stats-new", dir + "/none.json"}, cmd.ExitStats},
+		{"missing list", []string{"--stats-old", store, "--stats-new", store, "--input", "none.txt"}, cmd.ExitInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"plan-diff", "--input", list, "--total", "2"}, tt.args...)
+			if code := cmd.Main(args, io.Discard); code != tt.want {
+				t.Errorf("Exit code = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
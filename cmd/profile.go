@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+// profiler captures optional CPU, heap, and execution trace profiles around command execution.
+type profiler struct {
+	logger     zerolog.Logger
+	cpuFile    *os.File
+	traceFile  *os.File
+	cpuProfile string
+	memProfile string
+	tracePath  string
+}
+
+// newProfiler registers the hidden profiling flags on cmd.
+func newProfiler(logger zerolog.Logger, cmd *cobra.Command) *profiler {
+	p := &profiler{logger: logger}
+
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&p.cpuProfile, "cpuprofile", "", "Write a CPU profile to `path`")
+	flags.StringVar(&p.memProfile, "memprofile", "", "Write a heap profile to `path` on exit")
+	flags.StringVar(&p.tracePath, "trace", "", "Write a runtime execution trace to `path`")
+	for _, name := range []string{"cpuprofile", "memprofile", "trace"} {
+		_ = flags.MarkHidden(name)
+	}
+
+	return p
+}
+
+// start begins CPU profiling and tracing when requested.
+// Failures are logged and never abort the command.
+func (p *profiler) start() {
+	if p.cpuProfile != "" {
+		f, err := os.Create(p.cpuProfile)
+		if err == nil {
+			err = pprof.StartCPUProfile(f)
+		}
+		if err != nil {
+			p.logger.Warn().Err(err).Str("path", p.cpuProfile).Msg("Failed to start CPU profile")
+			closeQuietly(f)
+		} else {
+			p.cpuFile = f
+		}
+	}
+
+	if p.tracePath != "" {
+		f, err := os.Create(p.tracePath)
+		if err == nil {
+			err = trace.Start(f)
+		}
+		if err != nil {
+			p.logger.Warn().Err(err).Str("path", p.tracePath).Msg("Failed to start execution trace")
+			closeQuietly(f)
+		} else {
+			p.traceFile = f
+		}
+	}
+}
+
+// stop finishes any running profiles and writes the heap profile.
+// Failures are logged and never change the command's outcome.
+func (p *profiler) stop() {
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		p.closeFile(p.cpuFile)
+		p.cpuFile = nil
+	}
+
+	if p.traceFile != nil {
+		trace.Stop()
+		p.closeFile(p.traceFile)
+		p.traceFile = nil
+	}
+
+	if p.memProfile != "" {
+		if err := writeHeapProfile(p.memProfile); err != nil {
+			p.logger.Warn().Err(err).Str("path", p.memProfile).Msg("Failed to write heap profile")
+		}
+	}
+}
+
+// closeFile closes a profile file, logging any error.
+func (p *profiler) closeFile(f *os.File) {
+	if err := f.Close(); err != nil {
+		p.logger.Warn().Err(err).Str("path", f.Name()).Msg("Failed to close profile")
+	}
+}
+
+// writeHeapProfile writes an up-to-date heap profile to path.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create file: %w", err)
+	}
+
+	// Collect garbage first so the profile reflects live objects
+	runtime.GC()
+	if err = pprof.WriteHeapProfile(f); err != nil {
+		closeQuietly(f)
+		return fmt.Errorf("cannot write profile: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("cannot close file: %w", err)
+	}
+	return nil
+}
+
+// closeQuietly closes f if it is open, ignoring errors.
+func closeQuietly(f *os.File) {
+	if f != nil {
+		_ = f.Close()
+	}
+}
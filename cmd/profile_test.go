@@ -0,0 +1,59 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+func TestProfilingFlags(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tests.txt")
+	if err := os.WriteFile(input, []byte("a_test.go\nb_test.go\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input: %v", err)
+	}
+
+	cpuProfile := filepath.Join(dir, "cpu.pprof")
+	memProfile := filepath.Join(dir, "mem.pprof")
+	tracePath := filepath.Join(dir, "trace.out")
+
+	err := cmd.Run([]string{
+		"--cpuprofile", cpuProfile,
+		"--memprofile", memProfile,
+		"--trace", tracePath,
+		"split", "--input", input, "--index", "1", "--total", "2", "--no-percentiles",
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, path := range []string{cpuProfile, memProfile, tracePath} {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			t.Errorf("Profile %s not written: %v", filepath.Base(path), statErr)
+			continue
+		}
+		if info.Size() == 0 {
+			t.Errorf("Profile %s is empty", filepath.Base(path))
+		}
+	}
+}
+
+func TestProfilingFlags_FailureKeepsOutcome(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tests.txt")
+	if err := os.WriteFile(input, []byte("a_test.go\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input: %v", err)
+	}
+
+	// An unwritable profile path must not turn a successful run into a failure
+	err := cmd.Run([]string{
+		"--memprofile", filepath.Join(dir, "missing", "mem.pprof"),
+		"split", "--input", input, "--index", "1", "--total", "2", "--no-percentiles",
+	})
+	if err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/prgtw/tests-helper/internal/logging"
+	"github.com/prgtw/tests-helper/internal/storage"
+	"github.com/prgtw/tests-helper/internal/store"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+const (
+	storeContentType = "application/json" // Content type of an uploaded timing store
+	storeFileMode    = 0o644              // Permissions of a written timing store
+)
+
+// recordOptions configures the record command. The fields mirror its flags.
+type recordOptions struct {
+	StatsFiles      []string // JUnit XML files, glob patterns, or directories (--stats)
+	Store           string   // Timing store to update (--store)
+	Upload          string   // s3:// or gs:// URL to upload the store to (--upload)
+	UploadRetries   int      // Retries after a failed upload attempt (--upload-retries)
+	UploadIfChanged bool     // Skip the upload when the stored object is identical (--upload-if-changed)
+	StrictStats     bool     // Fail on unusable stats files (--strict-stats)
+	Debug           bool     // Log at debug level (--debug)
+}
+
+// newRecordCmd creates the record command.
+func newRecordCmd(logger zerolog.Logger) *cobra.Command {
+	opts := recordOptions{
+		StatsFiles:    []string{},
+		UploadRetries: storage.DefaultRetries,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "Record test times from JUnit reports into a timing store",
+		Long: `Record merges the times of JUnit XML reports into a timing store, a JSON map of
+test name to seconds. Tests found in the reports take their new time; other entries are kept.
+
+With --upload, the merged store is written to S3 or GCS. Credentials come from the standard
+chains: AWS environment variables, shared configuration, and instance roles for s3://,
+Application Default Credentials for gs://.
+
+Examples:
+  tests-helper record --stats "reports/*.xml" --store times.json
+  tests-helper record --stats "reports/*.xml" --store times.json --upload s3://ci-timings/main/times.json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return runRecord(ctx, logger, &opts, storage.Open)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&opts.StatsFiles, "stats", opts.StatsFiles,
+		"Path(s) to JUnit XML stats files or directories (supports glob patterns)")
+	cmd.Flags().StringVar(&opts.Store, "store", opts.Store, "Timing store to update, created if missing")
+	cmd.Flags().StringVar(&opts.Upload, "upload", opts.Upload,
+		"Upload the merged store to s3://bucket/key or gs://bucket/key")
+	cmd.Flags().BoolVar(&opts.UploadIfChanged, "upload-if-changed", opts.UploadIfChanged,
+		"Skip the upload when the stored object already has the same content")
+	cmd.Flags().IntVar(&opts.UploadRetries, "upload-retries", opts.UploadRetries,
+		"Retries after a failed upload attempt, with exponential backoff")
+	cmd.Flags().BoolVar(&opts.StrictStats, "strict-stats", opts.StrictStats,
+		"Fail on unreadable, invalid, or truncated stats files")
+	cmd.Flags().BoolVar(&opts.Debug, "debug", opts.Debug, "Enable debug logging")
+
+	return cmd
+}
+
+// runRecord merges the reports into the store and uploads it when requested,
+// opening the upload bucket with open.
+func runRecord(ctx context.Context, logger zerolog.Logger, opts *recordOptions, open storage.Opener) error {
+	if opts.Debug {
+		logger = logger.Level(zerolog.DebugLevel)
+	} else {
+		logger = logger.Level(zerolog.InfoLevel)
+	}
+
+	if opts.Store == "" {
+		return usageError(errors.New("--store is required"))
+	}
+	if len(opts.StatsFiles) == 0 {
+		return usageError(errors.New("--stats is required"))
+	}
+	var location storage.Location
+	if opts.Upload != "" {
+		var err error
+		if location, err = storage.ParseURL(opts.Upload); err != nil {
+			return usageError(err)
+		}
+	}
+
+	times, err := readStore(opts.Store)
+	if err != nil {
+		return statsError(err)
+	}
+
+	ts := testsplit.New(
+		testsplit.WithLogger(slog.New(logging.NewHandler(logger))),
+		testsplit.WithStrictStats(opts.StrictStats),
+	)
+	recorded, err := ts.LoadSources(ctx, ts.JUnitFiles(opts.StatsFiles...))
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return statsError(err)
+	}
+	maps.Copy(times, recorded)
+
+	data, err := writeStore(opts.Store, times)
+	if err != nil {
+		return err
+	}
+	logger.Info().
+		Int("recorded", len(recorded)).
+		Int("keys", len(times)).
+		Str("store", opts.Store).
+		Msg("Recorded timings")
+
+	if opts.Upload == "" {
+		return nil
+	}
+	return uploadStore(ctx, logger, opts, open, location, data)
+}
+
+// readStore reads the timing store at path. A missing store is empty.
+func readStore(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]float64), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open timing store: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	return store.Read(f)
+}
+
+// writeStore writes times as a timing store to path and returns the encoded store.
+func writeStore(path string, times map[string]float64) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := store.Write(&buf, times); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), storeFileMode); err != nil {
+		return nil, fmt.Errorf("cannot write timing store: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// uploadStore uploads the encoded store to location.
+func uploadStore(
+	ctx context.Context, logger zerolog.Logger, opts *recordOptions, open storage.Opener,
+	location storage.Location, data []byte,
+) error {
+	bucket, err := open(ctx, location)
+	if err != nil {
+		return uploadError(err)
+	}
+	defer func() { _ = bucket.Close() }()
+
+	uploader := storage.NewUploader(logger,
+		storage.WithRetries(opts.UploadRetries),
+		storage.WithIfChanged(opts.UploadIfChanged),
+	)
+	uploaded, err := uploader.Upload(ctx, bucket, location.Key, data, storeContentType)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return uploadError(fmt.Errorf("cannot upload timing store to %s: %w", location, err))
+	}
+	if uploaded {
+		logger.Info().Str("url", location.String()).Int("bytes", len(data)).Msg("Uploaded timing store")
+	}
+	return nil
+}
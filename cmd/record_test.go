@@ -0,0 +1,169 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/cmd"
+	"github.com/prgtw/tests-helper/internal/storage"
+)
+
+// memoryBucket is an in-memory storage.Bucket whose Put and List fail with err when set.
+type memoryBucket struct {
+	err     error
+	objects map[string][]byte
+	puts    int
+}
+
+func (b *memoryBucket) Put(_ context.Context, key string, data []byte, _ string) error {
+	b.puts++
+	if b.err != nil {
+		return b.err
+	}
+	b.objects[key] = data
+	return nil
+}
+
+func (b *memoryBucket) Checksum(_ context.Context, _ string) (string, error) {
+	return "", storage.ErrNotFound
+}
+
+func (b *memoryBucket) List(_ context.Context, prefix string) ([]storage.Object, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	var objects []storage.Object
+	for key, data := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, storage.Object{Key: key, Size: int64(len(data))})
+		}
+	}
+	slices.SortFunc(objects, func(a, b storage.Object) int { return strings.Compare(a.Key, b.Key) })
+	return objects, nil
+}
+
+func (b *memoryBucket) Get(_ context.Context, key string) ([]byte, error) {
+	data, ok := b.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return data, nil
+}
+
+func (b *memoryBucket) Close() error {
+	return nil
+}
+
+func TestRecordCommand_Upload(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "times.json")
+	if err := os.WriteFile(storePath, []byte(`{"kept_test.go": 7, "handler_test.go": 99}`), 0o600); err != nil {
+		t.Fatalf("Failed to write store: %v", err)
+	}
+	report := filepath.Join(dir, "report.xml")
+	if err := os.WriteFile(report, []byte(`<testsuites>
+  <testsuite file="handler_test.go" time="2.5"/>
+  <testsuite file="db_test.go" time="1"/>
+</testsuites>`), 0o600); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	bucket := &memoryBucket{objects: make(map[string][]byte)}
+	var opened storage.Location
+	open := func(_ context.Context, loc storage.Location) (storage.Bucket, error) {
+		opened = loc
+		return bucket, nil
+	}
+
+	opts := &cmd.RecordOptions{
+		StatsFiles: []string{report},
+		Store:      storePath,
+		Upload:     "gs://ci-timings/main/times.json",
+	}
+	if err := cmd.RunRecord(t.Context(), zerolog.Nop(), opts, open); err != nil {
+		t.Fatalf("RunRecord failed: %v", err)
+	}
+
+	want := "{\n  \"db_test.go\": 1,\n  \"handler_test.go\": 2.5,\n  \"kept_test.go\": 7\n}\n"
+	got, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to read store: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Store = %s, want %s", got, want)
+	}
+	if opened.Bucket != "ci-timings" || opened.Key != "main/times.json" {
+		t.Errorf("Opened %+v", opened)
+	}
+	if !bytes.Equal(bucket.objects["main/times.json"], got) {
+		t.Errorf("Uploaded %s, want the merged store", bucket.objects["main/times.json"])
+	}
+}
+
+func TestRecordCommand_Errors(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.xml")
+	if err := os.WriteFile(report, []byte(`<testsuite file="a_test.go" time="1"/>`), 0o600); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	failing := &memoryBucket{err: errors.New("access denied")}
+
+	tests := []struct {
+		name string
+		opts cmd.RecordOptions
+		open storage.Opener
+		want int
+	}{
+		{name: "missing store", opts: cmd.RecordOptions{StatsFiles: []string{report}}, want: cmd.ExitUsage},
+		{
+			name: "invalid upload url",
+			opts: cmd.RecordOptions{StatsFiles: []string{report}, Store: "times.json", Upload: "ftp://host/times.json"},
+			want: cmd.ExitUsage,
+		},
+		{
+			name: "no reports",
+			opts: cmd.RecordOptions{StatsFiles: []string{filepath.Join(dir, "*.json")}, Store: "times.json"},
+			want: cmd.ExitStats,
+		},
+		{
+			name: "open fails",
+			opts: cmd.RecordOptions{StatsFiles: []string{report}, Store: "times.json", Upload: "s3://b/times.json"},
+			open: func(context.Context, storage.Location) (storage.Bucket, error) {
+				return nil, errors.New("no credentials")
+			},
+			want: cmd.ExitUpload,
+		},
+		{
+			name: "upload fails",
+			opts: cmd.RecordOptions{StatsFiles: []string{report}, Store: "times.json", Upload: "s3://b/times.json"},
+			open: func(context.Context, storage.Location) (storage.Bucket, error) {
+				return failing, nil
+			},
+			want: cmd.ExitUpload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if opts.Store != "" {
+				opts.Store = filepath.Join(t.TempDir(), opts.Store)
+			}
+			err := cmd.RunRecord(t.Context(), zerolog.Nop(), &opts, tt.open)
+			if got := cmd.ExitCode(err); got != tt.want {
+				t.Errorf("Exit code = %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+	if failing.puts != 1 {
+		t.Errorf("Puts = %d, want a single attempt with no retries configured", failing.puts)
+	}
+}
@@ -1,22 +1,26 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
-)
 
-var (
-	version = "snapshot"
-	commit  = "<commit-unknown>"              //nolint:gochecknoglobals // replaced by GoReleaser
-	date    = time.Now().Format(time.RFC3339) //nolint:gochecknoglobals // replaced by GoReleaser
+	"github.com/prgtw/tests-helper/internal/junit"
 )
 
+// BuildInfo describes the binary, as injected at build time.
+type BuildInfo struct {
+	Version string
+	Commit  string
+	Date    string
+}
+
 // newRootCmd creates the root command.
-func newRootCmd() *cobra.Command {
+func newRootCmd(info BuildInfo) *cobra.Command {
 	return &cobra.Command{
 		Use:   "tests-helper",
 		Short: "A tool for splitting test suites across parallel workers",
@@ -28,24 +32,88 @@ helping to optimize parallel test execution in CI/CD environments.
 
 Version: %s
 Commit:  %s
-Built:   %s`, version, commit, date),
-		Version: version,
+Built:   %s
+
+%s`, info.Version, info.Commit, info.Date, exitCodesHelp),
+		Version: info.Version,
+		// Errors are reported once by run, through the logger
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
-// This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute() {
-	// Initialize logger with console output
-	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).
+// This is called by main.main() with the build information injected there.
+func Execute(info BuildInfo) {
+	os.Exit(run(info, os.Args[1:], os.Stderr))
+}
+
+// run executes the command line, reports a failure as a single log line on stderr,
+// and returns the process exit code.
+func run(info BuildInfo, args []string, stderr io.Writer) int {
+	logger := newLogger(stderr)
+	err := execute(logger, info, args)
+	code := exitCode(err)
+	if code == ExitInterrupted {
+		logger.Warn().Int("exit_code", code).Msg("Interrupted")
+		return code
+	}
+	if err != nil {
+		event := logger.Error().Err(err).Int("exit_code", code)
+		if code == ExitUsage {
+			event = event.Str("hint", "run 'tests-helper --help' for usage")
+		}
+		var parseErr *junit.ParseError
+		if errors.As(err, &parseErr) {
+			event = event.Str("file", parseErr.File).Int("line", parseErr.Line)
+		}
+		event.Msg("Command failed")
+	}
+	return code
+}
+
+// newLogger creates the console logger used by all commands.
+func newLogger(w io.Writer) zerolog.Logger {
+	return zerolog.New(zerolog.ConsoleWriter{Out: w}).
 		With().
 		Timestamp().
 		Logger()
+}
 
-	rootCmd := newRootCmd()
-	rootCmd.AddCommand(newSplitCmd(logger))
+// newCommandTree builds the root command with every subcommand attached.
+func newCommandTree(logger zerolog.Logger, info BuildInfo) *cobra.Command {
+	rootCmd := newRootCmd(info)
+	rootCmd.AddCommand(newSplitCmd(logger, info.Version))
+	rootCmd.AddCommand(newServeCmd(logger, info.Version))
+	rootCmd.AddCommand(newRecordCmd(logger))
+	rootCmd.AddCommand(newCombineCmd(logger, info.Version))
+	rootCmd.AddCommand(newPlanDiffCmd(logger))
+	rootCmd.AddCommand(newStatsCmd(logger))
+	rootCmd.AddCommand(newVerifyCmd(logger))
+	rootCmd.AddCommand(newEnvCmd())
+	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return usageError(err)
+	})
+	return rootCmd
+}
+
+// execute builds the command tree and runs it with args.
+func execute(logger zerolog.Logger, info BuildInfo, args []string) error {
+	rootCmd := newCommandTree(logger, info)
+
+	profiles := newProfiler(logger, rootCmd)
+	started := false
+	rootCmd.PersistentPreRun = func(_ *cobra.Command, _ []string) {
+		started = true
+		profiles.start()
+	}
+	defer profiles.stop()
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	if err != nil && !started {
+		// Cobra failed before running any command: unknown command, flag, or bad arguments
+		return usageError(err)
 	}
+	return err
 }
@@ -0,0 +1,30 @@
+package cmd_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+func TestRootCmd_BuildInfo(t *testing.T) {
+	info := cmd.BuildInfo{Version: "1.2.3", Commit: "abc1234", Date: "2024-05-02T10:00:00Z"}
+	root := cmd.NewRootCmd(info)
+
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"--version"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "tests-helper version 1.2.3" {
+		t.Errorf("--version: got %q, want %q", got, "tests-helper version 1.2.3")
+	}
+
+	for _, want := range []string{"Version: 1.2.3", "Commit:  abc1234", "Built:   2024-05-02T10:00:00Z"} {
+		if !strings.Contains(root.Long, want) {
+			t.Errorf("Long description does not contain %q:\n%s", want, root.Long)
+		}
+	}
+}
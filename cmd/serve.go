@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/prgtw/tests-helper/internal/config"
+	"github.com/prgtw/tests-helper/internal/logging"
+	"github.com/prgtw/tests-helper/internal/server"
+	"github.com/prgtw/tests-helper/internal/store"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take once shutdown starts.
+const shutdownTimeout = 10 * time.Second
+
+// serveOptions configures the serve command. The fields mirror its flags.
+type serveOptions struct {
+	Listen          string   // Address to listen on (--listen)
+	StatsStore      string   // Timing store location (--stats-store)
+	StatsFiles      []string // JUnit XML files, glob patterns, or directories (--stats)
+	MaxRequestBytes int64    // Largest accepted request body (--max-request-bytes)
+	PlanCacheSize   int      // Plans kept for lookups (--plan-cache-size)
+	MaxWorkers      int      // Largest total of a request (--max-workers)
+	Version         string   // Tool version stamped into the plans, from the build
+	Debug           bool     // Log at debug level (--debug)
+}
+
+// newServeCmd creates the serve command.
+func newServeCmd(logger zerolog.Logger, version string) *cobra.Command {
+	opts := serveOptions{
+		Version:         version,
+		Listen:          ":8080",
+		StatsFiles:      []string{},
+		MaxRequestBytes: server.DefaultMaxRequestBytes,
+		PlanCacheSize:   server.DefaultPlanCacheSize,
+		MaxWorkers:      server.DefaultMaxWorkers,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve split plans over HTTP",
+		Long: `Serve loads historical times once and computes split plans over HTTP.
+
+  POST /split                  {"tests": ["a_test.go", ...], "total": 4}
+                               returns the plan id, every group, and the distribution
+  GET  /split/{index}?plan=ID  returns one group of a recently computed plan
+
+Set TESTS_HELPER_SERVE_TOKEN to require "Authorization: Bearer <token>" on every request.
+
+Examples:
+  tests-helper serve --stats-store times.json --listen :8080
+  tests-helper serve --stats "reports/*.xml"`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return runServe(ctx, logger, &opts, nil)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Listen, "listen", opts.Listen, "Address to listen on")
+	cmd.Flags().StringVar(&opts.StatsStore, "stats-store", opts.StatsStore,
+		"Timing store (JSON map of test name to seconds) to load, as a path or file:// URL")
+	cmd.Flags().StringSliceVar(&opts.StatsFiles, "stats", opts.StatsFiles,
+		"Path(s) to JUnit XML stats files or directories (supports glob patterns)")
+	cmd.Flags().Int64Var(&opts.MaxRequestBytes, "max-request-bytes", opts.MaxRequestBytes,
+		"Largest request body accepted, in bytes")
+	cmd.Flags().IntVar(&opts.PlanCacheSize, "plan-cache-size", opts.PlanCacheSize,
+		"Number of computed plans kept for GET /split/{index}")
+	cmd.Flags().IntVar(&opts.MaxWorkers, "max-workers", opts.MaxWorkers,
+		"Largest total a request may split into")
+	cmd.Flags().BoolVar(&opts.Debug, "debug", opts.Debug, "Enable debug logging")
+
+	return cmd
+}
+
+// runServe loads the timings and serves until ctx is cancelled, then shuts down gracefully.
+// When ready is not nil, it receives the listening address once requests are accepted.
+func runServe(ctx context.Context, logger zerolog.Logger, opts *serveOptions, ready chan<- net.Addr) error {
+	if opts.Debug {
+		logger = logger.Level(zerolog.DebugLevel)
+	} else {
+		logger = logger.Level(zerolog.InfoLevel)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return usageError(fmt.Errorf("failed to load configuration: %w", err))
+	}
+
+	ts := testsplit.New(
+		testsplit.WithLogger(slog.New(logging.NewHandler(logger))),
+		testsplit.WithStrictStats(true),
+	)
+	times, err := loadServeTimings(ctx, ts, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return statsError(err)
+	}
+	logger.Info().Int("keys", len(times)).Msg("Loaded timings")
+
+	srv := server.New(logger, ts, times,
+		server.WithMaxRequestBytes(opts.MaxRequestBytes),
+		server.WithPlanCacheSize(opts.PlanCacheSize),
+		server.WithMaxWorkers(opts.MaxWorkers),
+		server.WithToken(cfg.ServeToken),
+		server.WithToolVersion(opts.Version),
+	)
+
+	listener, err := new(net.ListenConfig).Listen(ctx, "tcp", opts.Listen)
+	if err != nil {
+		return usageError(fmt.Errorf("cannot listen on %s: %w", opts.Listen, err))
+	}
+	httpServer := &http.Server{Handler: srv.Handler(), ReadHeaderTimeout: shutdownTimeout}
+	return serve(ctx, logger, httpServer, listener, ready)
+}
+
+// loadServeTimings loads the configured store and reports.
+// Any unusable source is an error, so the server never starts with partial timings.
+func loadServeTimings(ctx context.Context, ts *testsplit.Splitter, opts *serveOptions) (map[string]float64, error) {
+	var sources []testsplit.TimeSource
+	if opts.StatsStore != "" {
+		sources = append(sources, store.NewSource(opts.StatsStore))
+	}
+	if len(opts.StatsFiles) > 0 {
+		sources = append(sources, ts.JUnitFiles(opts.StatsFiles...))
+	}
+	if len(sources) == 0 {
+		return make(map[string]float64), nil
+	}
+	return ts.LoadSources(ctx, sources...)
+}
+
+// serve runs httpServer on listener until ctx is cancelled, then waits for in-flight requests.
+func serve(
+	ctx context.Context, logger zerolog.Logger, httpServer *http.Server, listener net.Listener, ready chan<- net.Addr,
+) error {
+	errs := make(chan error, 1)
+	go func() { errs <- httpServer.Serve(listener) }()
+
+	logger.Info().Str("address", listener.Addr().String()).Msg("Serving split plans")
+	if ready != nil {
+		ready <- listener.Addr()
+	}
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info().Msg("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
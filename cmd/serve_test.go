@@ -0,0 +1,74 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+func TestServeCommand_GracefulShutdown(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "times.json")
+	if err := os.WriteFile(storePath, []byte(`{"slow_test.go": 9, "fast_test.go": 1}`), 0o600); err != nil {
+		t.Fatalf("Failed to write store: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	ready := make(chan net.Addr, 1)
+	done := make(chan error, 1)
+	opts := &cmd.ServeOptions{Listen: "127.0.0.1:0", StatsStore: storePath}
+	go func() { done <- cmd.RunServe(ctx, zerolog.Nop(), opts, ready) }()
+
+	var addr net.Addr
+	select {
+	case addr = <-ready:
+	case err := <-done:
+		t.Fatalf("RunServe failed before serving: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, "http://"+addr.String()+"/split",
+		strings.NewReader(`{"tests": ["slow_test.go", "fast_test.go", "new_test.go"], "total": 2}`))
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /split failed: %v", err)
+	}
+	var plan struct {
+		Groups []struct {
+			Total float64 `json:"total"`
+		} `json:"groups"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&plan)
+	_ = resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /split: status %d, decode error %v", resp.StatusCode, err)
+	}
+	if len(plan.Groups) != 2 || plan.Groups[0].Total != 9 || plan.Groups[1].Total != 2 {
+		t.Errorf("Unexpected plan %+v, want the store's times", plan)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestServeCommand_StatsErrors(t *testing.T) {
+	for _, location := range []string{filepath.Join(t.TempDir(), "missing.json"), "s3://bucket/times.json"} {
+		opts := &cmd.ServeOptions{Listen: "127.0.0.1:0", StatsStore: location}
+		err := cmd.RunServe(t.Context(), zerolog.Nop(), opts, nil)
+		if cmd.ExitCode(err) != cmd.ExitStats {
+			t.Errorf("Store %s: got %v (exit %d), want a stats error", location, err, cmd.ExitCode(err))
+		}
+	}
+}
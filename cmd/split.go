@@ -1,29 +1,202 @@
 package cmd
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 
+	"github.com/prgtw/tests-helper/internal/changes"
+	"github.com/prgtw/tests-helper/internal/circleci"
 	"github.com/prgtw/tests-helper/internal/config"
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/fileutil"
+	"github.com/prgtw/tests-helper/internal/github"
+	"github.com/prgtw/tests-helper/internal/gotest"
+	"github.com/prgtw/tests-helper/internal/inputcmd"
 	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/logging"
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/metrics"
+	"github.com/prgtw/tests-helper/internal/notify"
+	"github.com/prgtw/tests-helper/internal/progress"
+	"github.com/prgtw/tests-helper/internal/shardexec"
+	"github.com/prgtw/tests-helper/internal/shardfile"
+	"github.com/prgtw/tests-helper/internal/shardindex"
 	"github.com/prgtw/tests-helper/internal/splitter"
+	"github.com/prgtw/tests-helper/internal/storage"
+	"github.com/prgtw/tests-helper/internal/worker"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
 )
 
-type splitOptions struct {
-	statsFiles    []string
-	indexFlag     int
-	totalFlag     int
-	noPercentiles bool
-	debugFlag     bool
+// githubTimeout bounds the requests upserting the pull-request comment.
+const githubTimeout = 10 * time.Second
+
+// formatText is the plain output format of split, one test per line.
+const formatText = "text"
+
+// Values of --emit: the groups of a grouped test list as split, or their members.
+const (
+	emitGroups  = "groups"
+	emitMembers = "members"
+)
+
+// SplitOptions configures a split run. The fields mirror the flags of the split command, but for
+// Version, which comes from the build.
+type SplitOptions struct {
+	StatsFiles             []string      // JUnit XML files, patterns, directories, JSON stores, or URLs (--stats)
+	StatsGoTest            []string      // Files of go test -json output timing packages (--stats-gotest)
+	Metrics                []string      // statsd:// or pushgateway:// URLs receiving distribution metrics (--metrics)
+	MetricsLabels          []string      // key=value labels added to every metric (--metrics-labels)
+	ChangedRules           []string      // Rules mapping changed files to tests, as FROM -> TO (--changed-rule)
+	InputCmdArgs           []string      // Arguments of InputCmd, run without a shell (--input-cmd-args)
+	Resources              []string      // PATTERN=TAG resource tags (--resource)
+	ResourceLimits         []string      // TAG=N per-worker resource limits (--resource-limit)
+	OnlyTags               []string      // Tag patterns a test must carry one of to be kept (--only-tag)
+	ExcludeTags            []string      // Tag patterns dropping the tests carrying them (--exclude-tag)
+	Weights                []string      // PATTERN=FACTOR or tag:PATTERN=FACTOR time weights (--weight)
+	StripPrefixes          []string      // Directories stripped from names and stats keys (--strip-prefix)
+	NotifyOn               []string      // Conditions triggering the webhook, e.g. imbalance>20 (--notify-on)
+	Percentiles            []int         // Percentiles reported for the suite and every worker (--percentiles)
+	NotifyWebhook          string        // Webhook notified when a --notify-on condition holds (--notify-webhook)
+	ChangedSince           string        // Ref the branch is compared with for changed files (--changed-since)
+	RenamesSince           string        // Ref compared for renames, empty for recent history (--follow-renames-since)
+	StatsCacheDir          string        // Cache directory for parsed stats, empty to disable (--stats-cache)
+	StatsSHA256            string        // Expected SHA-256 digest of the single --stats URL (--stats-sha256)
+	StatsBranch            string        // Branch whose CircleCI artifacts are used (--stats-branch)
+	StatsArtifactGlob      string        // Pattern selecting CircleCI artifacts (--stats-artifact-glob)
+	StatsCircleCIProject   string        // CircleCI project slug, empty to derive it (--stats-circleci-project)
+	InputFile              string        // Test list file, empty to read stdin (--input)
+	InputCmd               string        // Command whose output is the test list (--input-cmd)
+	InputFormat            string        // lines or groups (--input-format)
+	Metadata               string        // JSON file of the tags of every test (--metadata)
+	Emit                   string        // groups or members, what to output of grouped input (--emit)
+	IndexFromHash          string        // Runner name hashed into the worker index (--index-from-hash)
+	CI                     string        // CI provider supplying the worker index and total, or auto (--ci)
+	ClaimDir               string        // Shared directory of worker index lock files (--claim-file)
+	Manifest               string        // File receiving the plan of every worker (--manifest)
+	Replay                 string        // Manifest whose plan is printed instead of splitting (--replay)
+	Version                string        // Tool version stamped into --manifest and compared by --replay
+	DeferredOutput         string        // File receiving the tests trimmed by Budget (--deferred-output)
+	OutputDir              string        // Directory receiving one file per worker instead of stdout (--output-dir)
+	OutputTemplate         string        // File name of each worker in OutputDir (--output-template)
+	ExecTemplate           string        // Command line running the selected worker's tests (--exec-template)
+	Format                 string        // text or json (--format)
+	OutputFormat           string        // text or json, the selected worker's assignment (--output-format)
+	DurationFormat         string        // seconds or human, for the summary and reports (--duration-format)
+	SummaryRollup          string        // dir:N, empty to disable (--summary-rollup)
+	GitHubRepo             string        // Commented repository owner/name, empty for GITHUB_REPOSITORY (--github-repo)
+	MatchMode              string        // exact or suffix (--match)
+	Dedupe                 string        // keep or first (--dedupe)
+	Algorithm              string        // greedy, roundrobin, or kk (--algorithm)
+	ControlChars           string        // reject or strip (--control-chars)
+	Granularity            string        // file or suite (--granularity)
+	StatsKey               string        // file, classname, or auto (--stats-key)
+	StatsMerge             string        // sum, avg, max, latest, or priority (--stats-merge)
+	DefaultTime            float64       // Time for tests without stats (--default-time)
+	ZeroTime               float64       // Time for tests recorded as zero seconds (--zero-time)
+	MaxTestTime            float64       // Ceiling for a stats entry, 0 to disable (--max-test-time)
+	InputCmdTimeout        time.Duration // Limit for InputCmd, 0 to disable (--input-cmd-timeout)
+	Budget                 float64       // Seconds every worker is trimmed to, 0 to disable (--budget)
+	ExpectedCount          int           // Expected number of tests (--expected-count)
+	GitHubPR               int           // Commented pull request, 0 to derive it from Actions (--github-pr)
+	Index                  int           // Worker index, config.Unset to use the environment (--index)
+	OneBased               bool          // --index and TESTS_HELPER_NODE_INDEX count from 1 (--one-based)
+	Total                  int           // Number of workers, config.Unset to use the environment (--total)
+	MaxLineBytes           int           // Maximum test list line length (--max-line-bytes)
+	MaxNameBytes           int           // Maximum test name length (--max-name-bytes)
+	StatsRetries           int           // Downloads repeated after a failed integrity check (--stats-retries)
+	VerboseWorkerTop       int           // Tests listed before truncating, 0 for all (--verbose-worker-top)
+	NoPercentiles          bool          // Skip percentile statistics (--no-percentiles)
+	PercentilesByCases     bool          // Weigh worker percentiles by test cases (--percentiles-by-cases)
+	Debug                  bool          // Log at debug level (--debug)
+	VerboseWorker          bool          // List the worker's tests with a running total (--verbose-worker)
+	Progress               bool          // Show a progress line when stderr is a terminal (--progress)
+	StrictStats            bool          // Fail on unusable stats files (--strict-stats)
+	InlineTimes            bool          // Accept per-line time overrides (--inline-times)
+	NormalizePaths         bool          // Clean paths before matching (--normalize-paths)
+	NormalizeUnicode       bool          // Match in Unicode NFC (--normalize-unicode)
+	DedupeNested           bool          // Skip parent suites repeating their children (--dedupe-nested)
+	IgnoreSkipped          bool          // Leave out the time of skipped test cases (--stats-ignore-skipped)
+	StatsCircleCIArtifacts bool          // Load the latest CircleCI artifacts (--stats-circleci-artifacts)
+	GitHubComment          bool          // Upsert the summary as a pull-request comment (--github-comment)
+	PrioritizeChanged      bool          // Emit the tests affected by changed files first (--prioritize-changed)
+	ChangedOnly            bool          // Split only the tests affected by changed files (--changed-only)
+	FollowRenames          bool          // Move stats of files renamed in git to their new paths (--follow-renames)
+	CleanOutputDir         bool          // Remove stale files matching OutputTemplate (--clean-output-dir)
+	Exec                   bool          // Run the ExecTemplate command instead of printing tests (--exec)
+	PrintExec              bool          // Print the ExecTemplate command instead of the tests (--print-exec)
+}
+
+// DefaultSplitOptions returns the options used when no flag is given.
+func DefaultSplitOptions() SplitOptions {
+	return SplitOptions{
+		StatsFiles:        []string{},
+		StatsGoTest:       []string{},
+		Metrics:           []string{},
+		MetricsLabels:     []string{},
+		NotifyOn:          []string{"imbalance>20", "all-default>50"},
+		Percentiles:       testsplit.DefaultPercentiles(),
+		ChangedRules:      []string{},
+		InputCmdArgs:      []string{},
+		Resources:         []string{},
+		ResourceLimits:    []string{},
+		OnlyTags:          []string{},
+		ExcludeTags:       []string{},
+		Weights:           []string{},
+		StripPrefixes:     []string{},
+		InputCmdTimeout:   inputcmd.DefaultTimeout,
+		ChangedSince:      changes.DefaultSince,
+		StatsBranch:       circleci.DefaultBranch,
+		StatsArtifactGlob: circleci.DefaultArtifactGlob,
+		MatchMode:         string(splitter.MatchExact),
+		Dedupe:            string(splitter.DedupeKeep),
+		Algorithm:         string(worker.AlgorithmGreedy),
+		ControlChars:      string(splitter.ControlReject),
+		Granularity:       string(junit.GranularityFile),
+		DurationFormat:    string(duration.FormatSeconds),
+		StatsKey:          string(junit.StatsKeyFile),
+		StatsMerge:        string(junit.MergeSum),
+		DefaultTime:       splitter.DefaultTestTime,
+		ZeroTime:          splitter.DefaultZeroTime,
+		MaxTestTime:       junit.DefaultMaxTime,
+		CI:                string(config.CIAuto),
+		Index:             config.Unset,
+		Total:             config.Unset,
+		MaxLineBytes:      splitter.DefaultMaxLineBytes,
+		MaxNameBytes:      splitter.DefaultMaxNameBytes,
+		StatsRetries:      storage.DefaultDownloadRetries,
+		VerboseWorkerTop:  splitter.DefaultDetailsTop,
+		OutputTemplate:    shardfile.DefaultTemplate,
+		Format:            formatText,
+		OutputFormat:      formatText,
+		InputFormat:       string(splitter.InputLines),
+		Emit:              emitGroups,
+		NormalizePaths:    true,
+		NormalizeUnicode:  true,
+		DedupeNested:      true,
+		IgnoreSkipped:     true,
+	}
 }
 
 // newSplitCmd creates the split command.
-func newSplitCmd(logger zerolog.Logger) *cobra.Command {
-	opts := &splitOptions{}
+func newSplitCmd(logger zerolog.Logger, version string) *cobra.Command {
+	opts := DefaultSplitOptions()
+	opts.Version = version
 
 	cmd := &cobra.Command{
 		Use:   "split",
@@ -42,89 +215,298 @@ Examples:
 
   # Enable debug logging
   cat test-list.txt | tests-helper split --stats "*.xml" --debug --index 0 --total 2`,
-		RunE: func(_ *cobra.Command, _ []string) error {
-			return runSplit(logger, opts, os.Stdin, os.Stdout)
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// Report a closed stdout as EPIPE instead of being killed by SIGPIPE
+			signal.Ignore(syscall.SIGPIPE)
+
+			// The first SIGINT/SIGTERM cancels the work; restoring the default handlers
+			// lets a second one kill the process if it is stuck reading stdin
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			context.AfterFunc(ctx, stop)
+
+			return runSplit(ctx, logger, &opts, cmd.InOrStdin(), cmd.OutOrStdout(),
+				storage.Open, inputcmd.Exec, shardexec.Exec, changes.Git)
 		},
 	}
 
-	cmd.Flags().
-		StringSliceVar(&opts.statsFiles, "stats", []string{}, "Path(s) to JUnit XML stats files (supports glob patterns)")
-	cmd.Flags().IntVar(&opts.indexFlag, "index", -1, "Worker index (overrides CIRCLE_NODE_INDEX)")
-	cmd.Flags().IntVar(&opts.totalFlag, "total", -1, "Total number of workers (overrides CIRCLE_NODE_TOTAL)")
-	cmd.Flags().BoolVar(&opts.noPercentiles, "no-percentiles", false, "Disable percentile statistics")
-	cmd.Flags().BoolVar(&opts.debugFlag, "debug", false, "Enable debug logging")
+	cmd.Flags().StringSliceVar(&opts.StatsFiles, "stats", opts.StatsFiles,
+		"Path(s) to JUnit XML stats files or directories, .json timing stores, or s3://, gs://, and https:// URLs "+
+			"(supports glob patterns)")
+	cmd.Flags().StringSliceVar(&opts.StatsGoTest, "stats-gotest", opts.StatsGoTest,
+		"Path(s) to go test -json output timing whole packages, for lists of package import paths "+
+			"(supports glob patterns)")
+	cmd.Flags().IntVar(&opts.Index, "index", opts.Index,
+		"Worker index (overrides TESTS_HELPER_NODE_INDEX and the index variable of the --ci provider)")
+	cmd.Flags().IntVar(&opts.Total, "total", opts.Total,
+		"Total number of workers (overrides TESTS_HELPER_NODE_TOTAL and the total variable of the --ci provider)")
+	cmd.Flags().StringVar(&opts.CI, "ci", opts.CI,
+		"CI provider whose variables give the worker index and total: auto, circleci, github, gitlab, "+
+			"buildkite, semaphore, jenkins, or none")
+	cmd.Flags().BoolVar(&opts.OneBased, "one-based", opts.OneBased,
+		"--index and TESTS_HELPER_NODE_INDEX count from 1, e.g. when set from a CI that numbers nodes from 1")
+	cmd.Flags().StringVar(&opts.IndexFromHash, "index-from-hash", opts.IndexFromHash,
+		"Derive the worker index from a stable hash of this string, e.g. the hostname, instead of --index")
+	cmd.Flags().StringVar(&opts.ClaimDir, "claim-file", opts.ClaimDir,
+		"Claim a free worker index with a lock file in this shared directory, instead of --index")
+	cmd.Flags().BoolVar(&opts.NoPercentiles, "no-percentiles", opts.NoPercentiles, "Disable percentile statistics")
+	cmd.Flags().IntSliceVar(&opts.Percentiles, "percentiles", opts.Percentiles,
+		"Percentiles of test file times reported for the whole suite and for every worker")
+	cmd.Flags().BoolVar(&opts.PercentilesByCases, "percentiles-by-cases", opts.PercentilesByCases,
+		"Weigh every worker's percentiles by the test cases of each file in the --stats reports")
+	cmd.Flags().StringVar(&opts.StatsCacheDir, "stats-cache", opts.StatsCacheDir,
+		"Directory for caching parsed stats files between runs")
+	cmd.Flags().StringVar(&opts.StatsSHA256, "stats-sha256", opts.StatsSHA256,
+		"Expected SHA-256 digest (hex) of the report downloaded from the single --stats URL")
+	cmd.Flags().IntVar(&opts.StatsRetries, "stats-retries", opts.StatsRetries,
+		"Download a remote report again up to this many times when it fails its checksum")
+	cmd.Flags().BoolVar(&opts.StatsCircleCIArtifacts, "stats-circleci-artifacts", opts.StatsCircleCIArtifacts,
+		"Load the JUnit artifacts of the latest successful CircleCI workflow on --stats-branch (needs CIRCLE_TOKEN)")
+	cmd.Flags().StringVar(&opts.StatsBranch, "stats-branch", opts.StatsBranch,
+		"Branch whose latest successful CircleCI workflow provides the artifacts")
+	cmd.Flags().StringVar(&opts.StatsArtifactGlob, "stats-artifact-glob", opts.StatsArtifactGlob,
+		"Pattern selecting CircleCI artifacts by path; ** matches any number of directories")
+	cmd.Flags().StringVar(&opts.StatsCircleCIProject, "stats-circleci-project", opts.StatsCircleCIProject,
+		"CircleCI project slug, e.g. gh/org/repo (default from CIRCLE_PROJECT_USERNAME and CIRCLE_PROJECT_REPONAME)")
+	cmd.Flags().StringVar(&opts.InputFile, "input", opts.InputFile, "Read the test list from a file instead of stdin")
+	cmd.Flags().StringVar(&opts.InputCmd, "input-cmd", opts.InputCmd,
+		"Read the test list from the output of a shell command, failing the split if it fails")
+	cmd.Flags().StringArrayVar(&opts.InputCmdArgs, "input-cmd-args", opts.InputCmdArgs,
+		"Argument of --input-cmd, which then names an executable run without a shell (repeatable)")
+	cmd.Flags().DurationVar(&opts.InputCmdTimeout, "input-cmd-timeout", opts.InputCmdTimeout,
+		"Stop --input-cmd and fail after this long (0 to wait forever)")
+	cmd.Flags().StringVar(&opts.InputFormat, "input-format", opts.InputFormat,
+		"Test list format: lines (a test per line) or groups (a group per line: name<TAB>member,member,...)")
+	cmd.Flags().StringVar(&opts.Emit, "emit", opts.Emit,
+		"Output of --input-format groups: groups (the group names) or members (the tests of every group)")
+	cmd.Flags().StringVar(&opts.Metadata, "metadata", opts.Metadata,
+		"JSON file mapping test names to lists of tags, used by --only-tag, --exclude-tag, and tag: weights")
+	cmd.Flags().StringArrayVar(&opts.OnlyTags, "only-tag", opts.OnlyTags,
+		"Keep only the tests with a tag matching this glob pattern (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.ExcludeTags, "exclude-tag", opts.ExcludeTags,
+		"Drop the tests with a tag matching this glob pattern (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.Weights, "weight", opts.Weights,
+		"Multiply the time of the tests matching a pattern, as PATTERN=FACTOR or tag:PATTERN=FACTOR (repeatable)")
+	cmd.Flags().Float64Var(&opts.Budget, "budget", opts.Budget,
+		"Trim every worker to this many seconds by deferring its cheapest tests (0 to disable)")
+	cmd.Flags().StringVar(&opts.DeferredOutput, "deferred-output", opts.DeferredOutput,
+		"Write the tests deferred by --budget to this file, one \"name seconds\" per line")
+	cmd.Flags().StringVar(&opts.Manifest, "manifest", opts.Manifest,
+		"Write the plan of every worker to this file, for tests-helper combine")
+	cmd.Flags().StringVar(&opts.Replay, "replay", opts.Replay,
+		"Print the selected worker's tests from this manifest as it was written, without loading stats or splitting")
+	cmd.Flags().StringVar(&opts.Format, "format", opts.Format,
+		"Output format of the selected worker: text (one test per line) or json (with each test's time and source)")
+	cmd.Flags().StringVar(&opts.OutputFormat, "output-format", opts.OutputFormat,
+		"Output format of the selected worker: text (one test per line) or json (index, total, predicted seconds, "+
+			"and each test's time and whether it was estimated), for every worker with --output-dir")
+	cmd.Flags().StringVar(&opts.DurationFormat, "duration-format", opts.DurationFormat,
+		"Durations in the summary and the pull-request comment: seconds (1873.421s) or human (31m13s)")
+	cmd.Flags().StringVar(&opts.SummaryRollup, "summary-rollup", opts.SummaryRollup,
+		"Add the top directories of every worker, cut to a depth as in dir:2, to the summary and manifest")
+	cmd.Flags().BoolVar(&opts.VerboseWorker, "verbose-worker", opts.VerboseWorker,
+		"Log the worker's tests longest first with a running total, and add every worker's tests to the manifest")
+	cmd.Flags().IntVar(&opts.VerboseWorkerTop, "verbose-worker-top", opts.VerboseWorkerTop,
+		"Tests listed by --verbose-worker or --debug before truncating (0 lists all)")
+	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", opts.OutputDir,
+		"Write the tests of every worker to its own file in this directory instead of one worker to stdout")
+	cmd.Flags().StringVar(&opts.OutputTemplate, "output-template", opts.OutputTemplate,
+		"File name of each worker in --output-dir, with {index}, {total}, and padded forms like {index:02}")
+	cmd.Flags().BoolVar(&opts.CleanOutputDir, "clean-output-dir", opts.CleanOutputDir,
+		"Remove files in --output-dir matching --output-template that this run did not write")
+	cmd.Flags().StringVar(&opts.ExecTemplate, "exec-template", opts.ExecTemplate,
+		"Command running the selected worker's tests, a Go template with .Tests, .Index, .Total, and join")
+	cmd.Flags().BoolVar(&opts.Exec, "exec", opts.Exec,
+		"Run the --exec-template command, streaming its output and exiting with its exit code")
+	cmd.Flags().BoolVar(&opts.PrintExec, "print-exec", opts.PrintExec,
+		"Print the --exec-template command instead of the tests")
+	cmd.Flags().IntVar(&opts.ExpectedCount, "expected-count", opts.ExpectedCount,
+		"Expected number of tests, used to pre-allocate memory")
+	cmd.Flags().IntVar(&opts.MaxLineBytes, "max-line-bytes", opts.MaxLineBytes,
+		"Maximum length of a single input line in bytes")
+	cmd.Flags().IntVar(&opts.MaxNameBytes, "max-name-bytes", opts.MaxNameBytes,
+		"Maximum length of a test name in bytes; longer names fail the split")
+	cmd.Flags().StringVar(&opts.ControlChars, "control-chars", opts.ControlChars,
+		"Test names with control characters such as ANSI escape sequences: reject fails the split, strip removes them")
+	cmd.Flags().Float64Var(&opts.DefaultTime, "default-time", opts.DefaultTime,
+		"Time in seconds used for tests without stats")
+	cmd.Flags().Float64Var(&opts.ZeroTime, "zero-time", opts.ZeroTime,
+		"Time in seconds used for tests recorded as taking zero seconds (0 gives them no weight)")
+	cmd.Flags().Float64Var(&opts.MaxTestTime, "max-test-time", opts.MaxTestTime,
+		"Reject stats entries longer than this many seconds (0 disables the check)")
+	cmd.Flags().BoolVar(&opts.InlineTimes, "inline-times", opts.InlineTimes,
+		"Treat a trailing number on an input line as that test's time in seconds")
+	cmd.Flags().BoolVar(&opts.NormalizePaths, "normalize-paths", opts.NormalizePaths,
+		"Match names and stats keys after cleaning paths (./a, a//b, a/../b, a\\b)")
+	cmd.Flags().StringArrayVar(&opts.StripPrefixes, "strip-prefix", opts.StripPrefixes,
+		"Strip a directory such as /home/ci/project from the start of names and stats keys; "+
+			"the first match wins (repeatable)")
+	cmd.Flags().BoolVar(&opts.NormalizeUnicode, "normalize-unicode", opts.NormalizeUnicode,
+		"Match names and stats keys in Unicode normalization form C (NFC)")
+	cmd.Flags().StringVar(&opts.MatchMode, "match", opts.MatchMode,
+		"How test names are matched against stats keys: exact, or suffix to also accept a unique key ending in /<name>")
+	cmd.Flags().StringVar(&opts.Algorithm, "algorithm", opts.Algorithm,
+		"How tests are assigned to workers: greedy (longest first to the least loaded), roundrobin, or kk")
+	cmd.Flags().StringVar(&opts.Dedupe, "dedupe", opts.Dedupe,
+		"How tests listed more than once are handled: keep every occurrence, or first to drop repeats")
+	cmd.Flags().StringArrayVar(&opts.Resources, "resource", opts.Resources,
+		"Tag the tests matching a pattern with a resource, as PATTERN=TAG; the first matching rule wins (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.ResourceLimits, "resource-limit", opts.ResourceLimits,
+		"Let a worker take at most N tests tagged TAG, as TAG=N (repeatable)")
+	cmd.Flags().StringVar(&opts.Granularity, "granularity", opts.Granularity,
+		"What is scheduled: file, keyed by the report's file attribute, or suite, keyed by the suite name verbatim")
+	cmd.Flags().StringVar(&opts.StatsKey, "stats-key", opts.StatsKey,
+		"Report attribute keying the stats: file, classname of the test cases, or auto to prefer file")
+	cmd.Flags().StringVar(&opts.StatsMerge, "stats-merge", opts.StatsMerge,
+		"How times of a test found in several stats files are combined: sum, avg, max, latest (newest report), "+
+			"or priority (first --stats holding it, later ones only fill gaps)")
+	cmd.Flags().BoolVar(&opts.DedupeNested, "dedupe-nested", opts.DedupeNested,
+		"Skip a parent suite's time when it equals the sum of its children sharing the same file")
+	cmd.Flags().BoolVar(&opts.IgnoreSkipped, "stats-ignore-skipped", opts.IgnoreSkipped,
+		"Leave out the time of skipped test cases; a suite whose test cases are all skipped takes no time")
+	cmd.Flags().StringSliceVar(&opts.Metrics, "metrics", opts.Metrics,
+		"Emit distribution metrics to statsd://host:port or pushgateway://host:port/job/<name>; failures are logged")
+	cmd.Flags().StringSliceVar(&opts.MetricsLabels, "metrics-labels", opts.MetricsLabels,
+		"Extra key=value labels attached to every metric")
+	cmd.Flags().StringVar(&opts.NotifyWebhook, "notify-webhook", opts.NotifyWebhook,
+		"POST a JSON (Slack-compatible) notification to this URL when a --notify-on condition holds")
+	cmd.Flags().StringSliceVar(&opts.NotifyOn, "notify-on", opts.NotifyOn,
+		"Conditions triggering the webhook: imbalance or all-default (percent) compared with >, >=, <, or <=")
+	cmd.Flags().BoolVar(&opts.GitHubComment, "github-comment", opts.GitHubComment,
+		"Post the distribution summary as a pull-request comment, edited on re-runs (needs GITHUB_TOKEN)")
+	cmd.Flags().StringVar(&opts.GitHubRepo, "github-repo", opts.GitHubRepo,
+		"Repository of --github-comment as owner/name (default from GITHUB_REPOSITORY)")
+	cmd.Flags().IntVar(&opts.GitHubPR, "github-pr", opts.GitHubPR,
+		"Pull request of --github-comment (default from GITHUB_REF or the GITHUB_EVENT_PATH payload)")
+	cmd.Flags().BoolVar(&opts.PrioritizeChanged, "prioritize-changed", opts.PrioritizeChanged,
+		"Emit the tests affected by files changed since --changed-since first on every worker")
+	cmd.Flags().BoolVar(&opts.ChangedOnly, "changed-only", opts.ChangedOnly,
+		"Split only the tests affected by files changed since --changed-since")
+	cmd.Flags().StringVar(&opts.ChangedSince, "changed-since", opts.ChangedSince,
+		"Git ref the current branch is compared with to find changed files")
+	cmd.Flags().StringSliceVar(&opts.ChangedRules, "changed-rule", opts.ChangedRules,
+		"Map changed files to tests, e.g. 'src/foo/** -> tests/foo/**' (tests in a changed directory always match)")
+	cmd.Flags().BoolVar(&opts.FollowRenames, "follow-renames", opts.FollowRenames,
+		"Move the stats of test files renamed in git to their current paths before matching")
+	cmd.Flags().StringVar(&opts.RenamesSince, "follow-renames-since", opts.RenamesSince,
+		"Git ref whose tree is compared with HEAD for --follow-renames, instead of searching recent history")
+	cmd.Flags().BoolVar(&opts.Debug, "debug", opts.Debug, "Enable debug logging")
+	cmd.Flags().BoolVar(&opts.Progress, "progress", opts.Progress,
+		"Show the progress of parsing stats files and distributing tests when stderr is a terminal")
+	cmd.Flags().BoolVar(&opts.StrictStats, "strict-stats", opts.StrictStats,
+		"Fail on unreadable, invalid, or truncated stats files")
 
 	return cmd
 }
 
-func runSplit(logger zerolog.Logger, opts *splitOptions, stdin io.Reader, stdout io.Writer) error {
+// RunSplit runs the split command with opts, reading the test list from stdin unless
+// opts.InputFile or opts.InputCmd is set, writing the selected worker's tests to stdout and logs
+// to stderr. The worker index and total fall back to the environment like the command line does.
+func RunSplit(ctx context.Context, opts SplitOptions, stdin io.Reader, stdout, stderr io.Writer) error {
+	return runSplit(ctx, newLogger(stderr), &opts, stdin, stdout,
+		storage.Open, inputcmd.Exec, shardexec.Exec, changes.Git)
+}
+
+// holdSignals keeps SIGINT and SIGTERM from killing the process until the returned function is
+// called, even once the first signal restored their default handlers. While the command listens
+// for them, a signal still cancels its context.
+func holdSignals() func() {
+	held := make(chan os.Signal, 1)
+	signal.Notify(held, os.Interrupt, syscall.SIGTERM)
+	return func() { signal.Stop(held) }
+}
+
+// runSplit runs the split command, opening the buckets of remote stats URLs with open, running
+// --input-cmd with run, and running the --exec command with runTests.
+func runSplit(
+	ctx context.Context, logger zerolog.Logger, opts *SplitOptions, stdin io.Reader, stdout io.Writer,
+	open storage.Opener, run inputcmd.Runner, runTests shardexec.Runner, git changes.Runner,
+) error {
 	// Configure logger level
-	if opts.debugFlag {
+	if opts.Debug {
 		logger = logger.Level(zerolog.DebugLevel)
 	} else {
 		logger = logger.Level(zerolog.InfoLevel)
 	}
 
 	// Load configuration from environment
-	cfg, err := config.Load()
+	cfg, err := loadConfig(opts)
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return err
+	}
+	if opts.Replay != "" {
+		return replaySplit(ctx, logger, cfg, opts, stdout, runTests)
 	}
 
 	// Get worker index and total
-	total := cfg.GetNodeTotal(opts.totalFlag, 1)
-	index := cfg.GetNodeIndex(opts.indexFlag, 0)
-
-	// Validate index
-	if index < 0 || index >= total {
-		return fmt.Errorf("invalid node index: %d (must be between 0 and %d)", index, total-1)
+	index, total, err := resolveNode(logger, cfg, opts)
+	if err != nil {
+		return usageError(err)
 	}
 
 	logger.Info().
+		Str("ci", string(cfg.DetectCI())).
 		Int("index", index).
 		Int("total", total).
 		Msg("Starting test split")
 
+	ts, err := newTestSplit(logger, opts)
+	if err != nil {
+		return usageError(err)
+	}
+	reports, err := newSplitReports(logger, cfg, opts)
+	if err != nil {
+		return usageError(err)
+	}
+	rules, err := changes.ParseRules(opts.ChangedRules)
+	if err != nil {
+		return usageError(err)
+	}
+	changed := newChangeMatcher(ctx, logger, opts, rules, git)
+
 	// Parse JUnit XML files
-	var times map[string]float64
-	if len(opts.statsFiles) > 0 {
-		parser := junit.NewParser(logger)
-		times, err = parser.LoadFiles(opts.statsFiles)
-		if err != nil {
-			logger.Warn().Err(err).Msg("Failed to load stats files, continuing with defaults")
-			times = make(map[string]float64)
-		}
-	} else {
-		logger.Info().Msg("No stats files provided, using default test times")
-		times = make(map[string]float64)
+	times, err := loadStats(ctx, logger, opts, ts, statsSources(logger, cfg, opts, ts, open), git)
+	if err != nil {
+		return err
 	}
 
-	// Read tests from stdin
-	testSplitter := splitter.NewSplitter(logger)
-	tests, err := testSplitter.ReadTests(stdin, times)
+	// Read tests from stdin or the input file
+	tests, err := readTests(ctx, logger, ts, opts, stdin, run, times)
 	if err != nil {
-		return fmt.Errorf("failed to read tests: %w", err)
+		return inputError(err)
+	}
+	tests = filterChanged(logger, opts, changed, tests)
+	if err = ctx.Err(); err != nil {
+		return fmt.Errorf("split interrupted: %w", err)
 	}
 
-	// Split tests across workers
-	allocator := testSplitter.Split(tests, total)
+	// Split tests across workers; the input order is not needed afterwards
+	result, err := ts.SplitInPlace(tests, total)
+	if err != nil {
+		return usageError(err)
+	}
+	if err = applyBudget(logger, opts, result, index); err != nil {
+		return err
+	}
 
 	// Print distribution summary using logger
-	stats := allocator.GetStats()
-	reporter := splitter.NewStatsReporter(logger)
-	reporter.PrintSummary(stats, !opts.noPercentiles)
+	reporter := newStatsReporter(logger, opts)
+	percentiles := reportedPercentiles(opts)
+	groups := result.Groups()
+	stats := reporter.Details(reporter.Rollup(result.Stats(reporter.StatsOptions(percentiles)), groups), groups)
+	reporter.PrintSummary(stats, percentiles)
 
 	// Print selected worker details using logger
-	reporter.PrintWorkerDetails(allocator, index)
+	worker := result.GroupRef(index)
+	reporter.PrintWorkerDetails(index, worker)
 
 	// Print selected worker's tests to stdout
-	worker := allocator.GetWorker(index)
-	if worker == nil {
-		return fmt.Errorf("failed to get worker %d", index)
+	command, err := emitTests(ctx, logger, opts, stdout, result, changed, index)
+	if err != nil {
+		return err
 	}
-
-	for _, test := range worker.Tests {
-		_, _ = fmt.Fprintln(stdout, test.Name)
+	if err = writeManifest(opts.Manifest, opts.Version, result, stats); err != nil {
+		return err
 	}
 
 	logger.Info().
@@ -132,5 +514,1098 @@ func runSplit(logger zerolog.Logger, opts *splitOptions, stdin io.Reader, stdout
 		Float64("total_time", worker.Total).
 		Msg("Split completed successfully")
 
+	reports.send(ctx, logger, result, stats, index)
+	return runTestCommand(ctx, logger, stdout, runTests, command)
+}
+
+// newChangeMatcher finds the files changed since --changed-since and maps them to tests with
+// rules. It returns nil when neither --prioritize-changed nor --changed-only is set, or when
+// git fails, e.g. outside a repository, which is logged instead of failing the split.
+func newChangeMatcher(
+	ctx context.Context, logger zerolog.Logger, opts *SplitOptions, rules []changes.Rule, git changes.Runner,
+) *changes.Matcher {
+	if !opts.PrioritizeChanged && !opts.ChangedOnly {
+		return nil
+	}
+	files, err := changes.Files(ctx, git, opts.ChangedSince)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Cannot list changed files, splitting every test in its usual order")
+		return nil
+	}
+	logger.Debug().
+		Str("since", opts.ChangedSince).
+		Int("files", len(files)).
+		Msg("Listed changed files")
+	return changes.NewMatcher(files, rules)
+}
+
+// filterChanged keeps only the tests affected by the changes with --changed-only, and logs how
+// many tests and how much predicted time were filtered out. A nil matcher keeps every test.
+func filterChanged(logger zerolog.Logger, opts *SplitOptions, m *changes.Matcher, tests []junit.Test) []junit.Test {
+	if m == nil || !opts.ChangedOnly {
+		return tests
+	}
+	kept := make([]junit.Test, 0, len(tests))
+	var saved float64
+	for _, test := range tests {
+		if m.Affects(test.Name) {
+			kept = append(kept, test)
+			continue
+		}
+		saved += test.Time
+		logger.Debug().
+			Str("test", test.Name).
+			Float64("time", test.Time).
+			Msg("Skipping test not affected by changed files")
+	}
+
+	logger.Info().
+		Int("kept", len(kept)).
+		Int("skipped", len(tests)-len(kept)).
+		Float64("saved_time", saved).
+		Msg("Filtered tests by changed files")
+	if len(kept) == 0 {
+		logger.Info().
+			Str("since", opts.ChangedSince).
+			Msg("No tests are affected by the changed files, every worker is empty")
+	}
+	return kept
+}
+
+// prioritize moves the tests affected by the changes to the front with --prioritize-changed.
+// A nil matcher keeps the order.
+func prioritize(logger zerolog.Logger, opts *SplitOptions, m *changes.Matcher, tests []junit.Test) []junit.Test {
+	if m == nil || !opts.PrioritizeChanged {
+		return tests
+	}
+	ordered, affected := m.Prioritize(tests)
+	logger.Info().
+		Int("affected", affected).
+		Msg("Moved tests affected by changed files to the front")
+	return ordered
+}
+
+// outputTests returns the tests of a worker in output order, see prioritize, with every group
+// replaced by its members with --emit members.
+func outputTests(logger zerolog.Logger, opts *SplitOptions, m *changes.Matcher, tests []junit.Test) []junit.Test {
+	tests = prioritize(logger, opts, m, tests)
+	if opts.Emit == emitMembers {
+		return testsplit.ExpandGroups(tests)
+	}
+	return tests
+}
+
+// readTests reads the test list, see readInput. With --percentiles-by-cases, every test also gets
+// the number of test cases the --stats reports hold for it.
+func readTests(
+	ctx context.Context, logger zerolog.Logger, ts *testsplit.Splitter, opts *SplitOptions, stdin io.Reader,
+	run inputcmd.Runner, times map[string]float64,
+) ([]junit.Test, error) {
+	tests, err := readInput(ctx, logger, ts, opts, stdin, run, times)
+	if err != nil {
+		return nil, err
+	}
+	if tests, err = applyMetadata(logger, ts, opts, tests); err != nil || !opts.PercentilesByCases {
+		return tests, err
+	}
+	assignCases(ctx, logger, opts, ts, tests)
+	return tests, nil
+}
+
+// applyMetadata drops the tests filtered out by --only-tag and --exclude-tag, using the tags of
+// the --metadata file, and multiplies the times of the rest by the --weight rules, both checked
+// by validateTagFlags.
+func applyMetadata(
+	logger zerolog.Logger, ts *testsplit.Splitter, opts *SplitOptions, tests []junit.Test,
+) ([]junit.Test, error) {
+	var meta testsplit.Metadata
+	if opts.Metadata != "" {
+		data, err := os.ReadFile(opts.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata file: %w", err)
+		}
+		if meta, err = ts.ReadMetadata(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.OnlyTags) > 0 || len(opts.ExcludeTags) > 0 {
+		kept := meta.Filter(tests, testsplit.TagFilter{Only: opts.OnlyTags, Exclude: opts.ExcludeTags})
+		logger.Info().
+			Int("kept", len(kept)).
+			Int("test_count", len(tests)).
+			Msgf("Kept %d of %d tests by their tags", len(kept), len(tests))
+		if len(kept) == 0 {
+			logger.Warn().Msg("No tests left after filtering by tags")
+		}
+		tests = kept
+	}
+
+	rules, _ := splitter.ParseWeightRules(opts.Weights)
+	if len(rules) > 0 {
+		weighted := meta.ApplyWeights(tests, rules)
+		logger.Info().
+			Int("weighted", weighted).
+			Msgf("Weighted the times of %d tests", weighted)
+	}
+	return tests, nil
+}
+
+// assignCases gives tests the number of test cases the local --stats reports hold for each,
+// loaded with a second pass over the reports, served by --stats-cache when set. Failing to load
+// them leaves the percentiles unweighted with a warning.
+func assignCases(
+	ctx context.Context, logger zerolog.Logger, opts *SplitOptions, ts *testsplit.Splitter, tests []junit.Test,
+) {
+	var local []string
+	for _, pattern := range opts.StatsFiles {
+		if !storage.IsURL(pattern) {
+			local = append(local, pattern)
+		}
+	}
+	if len(local) == 0 {
+		logger.Warn().Msg("--percentiles-by-cases needs local --stats reports, reporting unweighted percentiles")
+		return
+	}
+	cases, err := ts.LoadSources(ctx, ts.JUnitCases(local...))
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to count test cases, reporting unweighted percentiles")
+		return
+	}
+	assigned := ts.AssignCases(tests, cases)
+	logger.Info().
+		Int("tests", assigned).
+		Int("test_count", len(tests)).
+		Msgf("Counted the test cases of %d of %d tests to weigh the worker percentiles", assigned, len(tests))
+}
+
+// readInput reads the test list from the --input file, from the output of --input-cmd run with
+// run, or from stdin when neither is set.
+func readInput(
+	ctx context.Context, logger zerolog.Logger, ts *testsplit.Splitter, opts *SplitOptions, stdin io.Reader,
+	run inputcmd.Runner, times map[string]float64,
+) ([]junit.Test, error) {
+	if opts.InputCmd != "" {
+		out, err := runInputCmd(ctx, logger, opts, run)
+		if err != nil {
+			return nil, err
+		}
+		return ts.ReadTests(bytes.NewReader(out), times)
+	}
+	if opts.InputFile == "" {
+		return ts.ReadTests(stdin, times)
+	}
+	file, err := os.Open(opts.InputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return ts.ReadTests(file, times)
+}
+
+// runInputCmd runs --input-cmd, through the shell unless --input-cmd-args are given, and returns
+// its standard output. Its standard error is logged, as a warning when the command fails.
+func runInputCmd(ctx context.Context, logger zerolog.Logger, opts *SplitOptions, run inputcmd.Runner) ([]byte, error) {
+	c := inputcmd.Shell(opts.InputCmd)
+	if len(opts.InputCmdArgs) > 0 {
+		c = inputcmd.Command{Name: opts.InputCmd, Args: opts.InputCmdArgs}
+	}
+
+	start := time.Now()
+	out, err := inputcmd.Run(ctx, run, c, opts.InputCmdTimeout)
+	level := zerolog.DebugLevel
+	if err != nil {
+		level = zerolog.WarnLevel
+	}
+	if stderr := bytes.TrimSpace(out.Stderr); len(stderr) > 0 {
+		logger.WithLevel(level).
+			Str("command", c.String()).
+			Str("stderr", string(stderr)).
+			Msg("Input command wrote to stderr")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug().
+		Str("command", c.String()).
+		Int("bytes", len(out.Stdout)).
+		Dur("duration", time.Since(start)).
+		Msg("Ran input command")
+	return out.Stdout, nil
+}
+
+// applyBudget trims every worker to --budget, logs what each kept and deferred, and writes the
+// deferred tests of the emitted workers, the selected one or all with --output-dir, to
+// --deferred-output.
+func applyBudget(logger zerolog.Logger, opts *SplitOptions, result *testsplit.Result, index int) error {
+	if opts.Budget <= 0 {
+		return nil
+	}
+	deferred := result.Trim(opts.Budget)
+	newStatsReporter(logger, opts).PrintBudget(opts.Budget, result.Groups(), deferred)
+	if opts.DeferredOutput == "" {
+		return nil
+	}
+
+	emitted := deferred[index : index+1]
+	if opts.OutputDir != "" {
+		emitted = deferred
+	}
+	var b strings.Builder
+	count := 0
+	for _, tests := range emitted {
+		for _, t := range tests {
+			b.WriteString(t.Name + " " + strconv.FormatFloat(t.Time, 'f', -1, 64) + "\n")
+			count++
+		}
+	}
+	if err := fileutil.WriteAtomic(opts.DeferredOutput, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write deferred tests: %w", err)
+	}
+	logger.Info().
+		Str("path", opts.DeferredOutput).
+		Int("deferred", count).
+		Msg("Wrote deferred tests")
+	return nil
+}
+
+// writeManifest writes the plan of every worker to path, for the combine command, stamped with the
+// tool version. An empty path writes nothing.
+func writeManifest(path, version string, result *testsplit.Result, stats testsplit.Distribution) error {
+	if path == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	m := manifest.Manifest{ToolVersion: version, Groups: result.Groups(), Distribution: stats}
+	if err := manifest.Write(&buf, m); err != nil {
+		return err
+	}
+	if err := fileutil.WriteAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot write manifest: %w", err)
+	}
 	return nil
 }
+
+// emitTests writes the tests of the worker at index to stdout in --format or, with --output-dir,
+// the tests of every worker to its own file. With --exec, it writes nothing and returns the
+// command line to run once the split is reported.
+//
+// The output is written in full or not at all: SIGINT and SIGTERM are held while it is written,
+// and once ctx is done nothing is written and an interruption is reported instead, so a consumer
+// never sees a truncated list.
+func emitTests(
+	ctx context.Context, logger zerolog.Logger, opts *SplitOptions, stdout io.Writer, result *testsplit.Result,
+	changed *changes.Matcher, index int,
+) (string, error) {
+	defer holdSignals()()
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("split interrupted before writing the output: %w", err)
+	}
+	selected := result.GroupRef(index)
+	switch {
+	case selected == nil:
+		return "", fmt.Errorf("failed to get worker %d", index)
+	case opts.Exec || opts.PrintExec:
+		tests := outputTests(logger, opts, changed, selected.Tests)
+		return emitTestCommand(logger, opts, stdout, tests, index, result.Len())
+	case opts.OutputDir == "" && opts.Format == formatJSON:
+		group := testsplit.Group{Tests: outputTests(logger, opts, changed, selected.Tests), Total: selected.Total}
+		return "", writeJSON(stdout, group, "")
+	case opts.OutputDir == "" && opts.OutputFormat == formatJSON:
+		tests := outputTests(logger, opts, changed, selected.Tests)
+		return "", writeJSON(stdout, newAssignment(tests, index, result.Len(), selected.Total), "")
+	case opts.OutputDir == "":
+		return "", writeOutput(logger, stdout, outputTests(logger, opts, changed, selected.Tests))
+	}
+	return "", emitWorkerFiles(logger, opts, stdout, result, changed)
+}
+
+// emitWorkerFiles writes the tests of every worker to its own file in --output-dir and, with
+// --output-format json, the assignments of all of them to stdout.
+func emitWorkerFiles(
+	logger zerolog.Logger, opts *SplitOptions, stdout io.Writer, result *testsplit.Result, changed *changes.Matcher,
+) error {
+	tmpl, err := shardfile.ParseTemplate(opts.OutputTemplate)
+	if err != nil {
+		return usageError(err)
+	}
+	workers := make([][]junit.Test, result.Len())
+	for i := range workers {
+		workers[i] = outputTests(logger, opts, changed, result.GroupRef(i).Tests)
+	}
+	paths, err := shardfile.WriteAll(opts.OutputDir, tmpl, workers, opts.CleanOutputDir)
+	if err != nil {
+		return err
+	}
+	logger.Info().
+		Str("dir", opts.OutputDir).
+		Int("files", len(paths)).
+		Msg("Wrote the tests of every worker")
+	if opts.OutputFormat != formatJSON {
+		return nil
+	}
+
+	all := assignments{Workers: make([]workerFile, len(workers))}
+	for i, tests := range workers {
+		a := newAssignment(tests, i, len(workers), result.GroupRef(i).Total)
+		all.Workers[i] = workerFile{assignment: a, File: paths[i]}
+	}
+	return writeJSON(stdout, all, "")
+}
+
+// emitTestCommand renders the --exec-template command running tests, the tests of the worker at
+// index of total. With --print-exec it writes the command to stdout, otherwise it returns it.
+// A worker without tests has no command, since most runners would run every test instead.
+func emitTestCommand(
+	logger zerolog.Logger, opts *SplitOptions, stdout io.Writer, tests []junit.Test, index, total int,
+) (string, error) {
+	if len(tests) == 0 {
+		logger.Warn().Int("index", index).Msg("Worker has no tests, skipping the --exec-template command")
+		return "", nil
+	}
+	tmpl, err := shardexec.ParseTemplate(opts.ExecTemplate)
+	if err != nil {
+		return "", usageError(err)
+	}
+	data := shardexec.Data{Tests: make([]string, len(tests)), Index: index, Total: total}
+	for i, test := range tests {
+		data.Tests[i] = test.Name
+	}
+	command, err := shardexec.Render(tmpl, data)
+	if err != nil {
+		return "", usageError(err)
+	}
+	if opts.Exec {
+		return command, nil
+	}
+	if _, err = io.WriteString(stdout, command+"\n"); err != nil && !errors.Is(err, syscall.EPIPE) {
+		return "", fmt.Errorf("failed to write output: %w", err)
+	}
+	return "", nil
+}
+
+// runTestCommand runs the command line rendered for --exec with the platform shell through run,
+// streaming its output to stdout. A non-zero exit status becomes the exit code of the process.
+// An empty command line runs nothing.
+func runTestCommand(
+	ctx context.Context, logger zerolog.Logger, stdout io.Writer, run shardexec.Runner, command string,
+) error {
+	if command == "" {
+		return nil
+	}
+	logger.Info().Str("command", command).Msg("Running the tests of the worker")
+	code, err := run(ctx, inputcmd.Shell(command), stdout)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("test command interrupted: %w", ctxErr)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot run test command: %w", err)
+	}
+	if code != ExitOK {
+		return commandError(fmt.Errorf("test command exited with status %d", code), code)
+	}
+	logger.Info().Msg("Test command succeeded")
+	return nil
+}
+
+// assignment is the selected worker as written by --output-format json.
+type assignment struct {
+	Index            int              `json:"index"`             // Selected worker, 0-based
+	Total            int              `json:"total"`             // Number of workers
+	PredictedSeconds float64          `json:"predicted_seconds"` // Predicted time of the worker
+	Tests            []assignmentTest `json:"tests"`             // In output order
+}
+
+// assignments are the workers written to --output-dir, as printed by --output-format json.
+type assignments struct {
+	Workers []workerFile `json:"workers"`
+}
+
+// workerFile is the assignment of a worker and the file its tests were written to.
+type workerFile struct {
+	assignment
+
+	File string `json:"file"`
+}
+
+// assignmentTest is a test of an assignment.
+type assignmentTest struct {
+	Name      string  `json:"name"`
+	Time      float64 `json:"time"`
+	Estimated bool    `json:"estimated"` // No timing data, the test got --default-time
+}
+
+// newAssignment returns the assignment of tests to the worker at index of total, predicted to
+// take predicted seconds.
+func newAssignment(tests []junit.Test, index, total int, predicted float64) assignment {
+	a := assignment{Index: index, Total: total, PredictedSeconds: predicted, Tests: make([]assignmentTest, len(tests))}
+	for i, test := range tests {
+		a.Tests[i] = assignmentTest{Name: test.Name, Time: test.Time, Estimated: test.Source == junit.SourceDefault}
+	}
+	return a
+}
+
+// reportIndent indents the JSON reports of env, stats, and verify, which are read by people too.
+const reportIndent = "  "
+
+// writeJSON writes v to w as JSON followed by a newline, every level indented by indent, or on a
+// single line when indent is empty: split's --format json and --output-format json, or the report
+// of another command. Like writeOutput, a consumer closing the pipe early is not an error.
+func writeJSON(w io.Writer, v any, indent string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", indent)
+	err := enc.Encode(v)
+	if err == nil || errors.Is(err, syscall.EPIPE) {
+		return nil
+	}
+	return fmt.Errorf("failed to write output: %w", err)
+}
+
+// writeOutput writes test names to w with a single write, so an interruption cannot cut the list.
+//
+// A consumer closing the pipe early (e.g. "| head") is not an error: the remaining
+// output is dropped and nil is returned, so the command still exits 0.
+func writeOutput(logger zerolog.Logger, w io.Writer, tests []junit.Test) error {
+	size := 0
+	for _, test := range tests {
+		size += len(test.Name) + 1
+	}
+	buf := make([]byte, 0, size)
+	for _, test := range tests {
+		buf = append(buf, test.Name...)
+		buf = append(buf, '\n')
+	}
+
+	_, err := w.Write(buf)
+	if errors.Is(err, syscall.EPIPE) {
+		logger.Debug().Msg("Output consumer closed the pipe early")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// reportedPercentiles returns the percentiles the summary reports, none with --no-percentiles.
+func reportedPercentiles(opts *SplitOptions) []int {
+	if opts.NoPercentiles {
+		return nil
+	}
+	return opts.Percentiles
+}
+
+// replaySplit prints the selected worker of the --replay manifest exactly as the run that wrote it
+// split the tests, without loading stats or distributing them again. The manifest sets the total,
+// which --total or the environment must match when given.
+func replaySplit(
+	ctx context.Context, logger zerolog.Logger, cfg *config.Config, opts *SplitOptions, stdout io.Writer,
+	runTests shardexec.Runner,
+) error {
+	if opts.Budget > 0 || opts.ChangedOnly || opts.PrioritizeChanged || opts.Emit == emitMembers {
+		return usageError(errors.New(
+			"--replay cannot be combined with --budget, --changed-only, --prioritize-changed, or --emit members"))
+	}
+	plan, err := readManifest(logger, opts.Replay, opts.Version)
+	if err != nil {
+		return inputError(err)
+	}
+
+	node := *opts
+	node.Total = len(plan.Groups)
+	if requested := cfg.ResolveNodeTotal(opts.Total, node.Total); requested.Value != node.Total {
+		return usageError(fmt.Errorf("%w: the manifest has %d workers but %s is %d",
+			testsplit.ErrInvalidWorkerCount, node.Total, requested.Origin(), requested.Value))
+	}
+	index, total, err := resolveNode(logger, cfg, &node)
+	if err != nil {
+		return usageError(err)
+	}
+	logger.Info().
+		Str("manifest", opts.Replay).
+		Str("plan_id", plan.PlanID).
+		Str("ci", string(cfg.DetectCI())).
+		Int("index", index).
+		Int("total", total).
+		Msg("Replaying split from manifest")
+
+	ts, err := newTestSplit(logger, opts)
+	if err != nil {
+		return usageError(err)
+	}
+	result, err := ts.Resume(plan.Groups)
+	if err != nil {
+		return inputError(err)
+	}
+	newStatsReporter(logger, opts).PrintWorkerDetails(index, result.GroupRef(index))
+	command, err := emitTests(ctx, logger, opts, stdout, result, nil, index)
+	if err != nil {
+		return err
+	}
+	return runTestCommand(ctx, logger, stdout, runTests, command)
+}
+
+// loadConfig loads the configuration from the environment, reading the worker index and total
+// from the variables of the CI provider selected by --ci.
+func loadConfig(opts *SplitOptions) (*config.Config, error) {
+	ci, err := config.ParseCI(opts.CI)
+	if err != nil {
+		return nil, usageError(err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, usageError(fmt.Errorf("failed to load configuration: %w", err))
+	}
+	cfg.CI = ci
+	return cfg, nil
+}
+
+// resolveNode returns the worker index and total from the flags or the environment, deriving the
+// index with --index-from-hash or --claim-file for runners without one. With --one-based, the
+// index is converted to count from 0 before it is validated. The variables or flags supplying
+// them are logged at debug level.
+func resolveNode(logger zerolog.Logger, cfg *config.Config, opts *SplitOptions) (int, int, error) {
+	totalValue := cfg.ResolveNodeTotal(opts.Total, 1)
+	indexValue := cfg.ResolveNodeIndex(opts.Index, 0)
+	if opts.OneBased {
+		indexValue = config.FromOneBased(indexValue)
+	}
+	if opts.IndexFromHash != "" || opts.ClaimDir != "" {
+		if opts.Index != config.Unset {
+			return 0, 0, errors.New("--index cannot be combined with --index-from-hash or --claim-file")
+		}
+		if err := config.ValidateTotal(totalValue); err != nil {
+			return 0, 0, err
+		}
+		var err error
+		if indexValue, err = deriveIndex(logger, opts, totalValue.Value); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := config.ValidateNode(indexValue, totalValue); err != nil {
+		return 0, 0, err
+	}
+	logger.Debug().
+		Str("index_source", indexValue.Origin()).
+		Str("index_provider", indexValue.Provider).
+		Str("total_source", totalValue.Origin()).
+		Str("total_provider", totalValue.Provider).
+		Msg("Resolved worker index and total")
+	return indexValue.Value, totalValue.Value, nil
+}
+
+// deriveIndex hashes --index-from-hash into an index among total, then, with --claim-file,
+// claims the first free index from there on. The claim is owned by the hashed string, or by the
+// hostname and process when there is none.
+func deriveIndex(logger zerolog.Logger, opts *SplitOptions, total int) (config.Value, error) {
+	value := config.Value{Name: "--claim-file", Source: config.SourceFlag}
+	owner := opts.IndexFromHash
+	if opts.IndexFromHash != "" {
+		value.Name, value.Value = "--index-from-hash", shardindex.FromHash(owner, total)
+		logger.Info().
+			Str("key", owner).
+			Int("index", value.Value).
+			Int("total", total).
+			Msg("Derived worker index from hash")
+	}
+	if opts.ClaimDir == "" {
+		return value, nil
+	}
+
+	if owner == "" {
+		host, _ := os.Hostname()
+		owner = fmt.Sprintf("%s:%d", host, os.Getpid())
+	}
+	index, err := shardindex.Claim(opts.ClaimDir, total, value.Value, owner)
+	if err != nil {
+		return config.Value{}, err
+	}
+	logger.Info().
+		Str("dir", opts.ClaimDir).
+		Str("owner", owner).
+		Int("index", index).
+		Msg("Claimed worker index")
+	return config.Value{Name: "--claim-file", Value: index, Source: config.SourceFlag}, nil
+}
+
+// newTestSplit configures the library splitter from the command flags, after checking the
+// flags of remote stats, input, and output that are only used later.
+func newTestSplit(logger zerolog.Logger, opts *SplitOptions) (*testsplit.Splitter, error) {
+	if err := validateStatsSHA256(opts); err != nil {
+		return nil, err
+	}
+	if err := validateExecFlags(opts); err != nil {
+		return nil, err
+	}
+	if err := validateOutputFlags(opts); err != nil {
+		return nil, err
+	}
+	if opts.DefaultTime <= 0 || math.IsNaN(opts.DefaultTime) || math.IsInf(opts.DefaultTime, 1) {
+		return nil, fmt.Errorf("invalid --default-time %g (expected a positive number of seconds)", opts.DefaultTime)
+	}
+	if opts.ZeroTime < 0 || math.IsNaN(opts.ZeroTime) || math.IsInf(opts.ZeroTime, 1) {
+		return nil, fmt.Errorf("invalid --zero-time %g (expected a non-negative number of seconds)", opts.ZeroTime)
+	}
+	matchMode, err := splitter.ParseMatchMode(opts.MatchMode)
+	if err != nil {
+		return nil, err
+	}
+	dedupeMode, err := splitter.ParseDedupeMode(opts.Dedupe)
+	if err != nil {
+		return nil, err
+	}
+	controlMode, err := splitter.ParseControlMode(opts.ControlChars)
+	if err != nil {
+		return nil, err
+	}
+	inputFormat, err := splitter.ParseInputFormat(opts.InputFormat)
+	if err != nil {
+		return nil, err
+	}
+	granularity, err := junit.ParseGranularity(opts.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	statsKey, err := junit.ParseStatsKey(opts.StatsKey)
+	if err != nil {
+		return nil, err
+	}
+	statsMerge, err := junit.ParseMerge(opts.StatsMerge)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := worker.ParseResourceRules(opts.Resources)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := worker.ParseResourceLimits(opts.ResourceLimits)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, err := parseAlgorithm(opts)
+	if err != nil {
+		return nil, err
+	}
+	return testsplit.New(
+		testsplit.WithLogger(slog.New(logging.NewHandler(logger))),
+		testsplit.WithStrictStats(opts.StrictStats),
+		testsplit.WithCacheDir(opts.StatsCacheDir),
+		testsplit.WithMaxTestTime(opts.MaxTestTime),
+		testsplit.WithDedupeNested(opts.DedupeNested),
+		testsplit.WithStatsIgnoreSkipped(opts.IgnoreSkipped),
+		testsplit.WithPathNormalization(opts.NormalizePaths),
+		testsplit.WithStripPrefixes(opts.StripPrefixes...),
+		testsplit.WithUnicodeNormalization(opts.NormalizeUnicode),
+		testsplit.WithMatchMode(matchMode),
+		testsplit.WithDedupe(dedupeMode),
+		testsplit.WithGranularity(granularity),
+		testsplit.WithStatsKey(statsKey),
+		testsplit.WithStatsMerge(statsMerge),
+		testsplit.WithResources(rules, limits),
+		testsplit.WithAlgorithm(algorithm),
+		testsplit.WithInlineTimes(opts.InlineTimes),
+		testsplit.WithInputFormat(inputFormat),
+		testsplit.WithMaxLineBytes(opts.MaxLineBytes),
+		testsplit.WithMaxNameBytes(opts.MaxNameBytes),
+		testsplit.WithControlChars(controlMode),
+		testsplit.WithSizeHint(opts.ExpectedCount),
+		testsplit.WithDefaultTime(opts.DefaultTime),
+		testsplit.WithZeroTime(opts.ZeroTime),
+		testsplit.WithProgress(newProgress(opts)),
+	), nil
+}
+
+// parseAlgorithm returns the --algorithm, which must be greedy with --resource-limit since no
+// other algorithm honours the limits.
+func parseAlgorithm(opts *SplitOptions) (worker.Algorithm, error) {
+	algorithm, err := worker.ParseAlgorithm(opts.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	if algorithm != worker.AlgorithmGreedy && len(opts.ResourceLimits) > 0 {
+		return "", fmt.Errorf("--algorithm %s cannot be combined with --resource-limit", algorithm)
+	}
+	return algorithm, nil
+}
+
+// newProgress returns a progress line on stderr for --progress, or nil when the flag is not set
+// or stderr is not a terminal, e.g. in CI logs.
+func newProgress(opts *SplitOptions) testsplit.Progress {
+	if !opts.Progress {
+		return nil
+	}
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return progress.NewLine(os.Stderr)
+}
+
+// validateOutputFlags checks the flags choosing the test list source and shaping the output,
+// which are only used after loading the stats.
+func validateOutputFlags(opts *SplitOptions) error {
+	if opts.Budget < 0 || math.IsNaN(opts.Budget) || math.IsInf(opts.Budget, 1) {
+		return fmt.Errorf("invalid --budget %g (expected 0 or more seconds)", opts.Budget)
+	}
+	if opts.DeferredOutput != "" && opts.Budget == 0 {
+		return errors.New("--deferred-output needs --budget")
+	}
+	if opts.InputCmd != "" && opts.InputFile != "" {
+		return errors.New("--input and --input-cmd cannot be used together")
+	}
+	if len(opts.InputCmdArgs) > 0 && opts.InputCmd == "" {
+		return errors.New("--input-cmd-args needs --input-cmd")
+	}
+	if err := validateTagFlags(opts); err != nil {
+		return err
+	}
+	if err := validateFormats(opts); err != nil {
+		return err
+	}
+	for _, p := range opts.Percentiles {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("invalid --percentiles value %d (expected 0 to 100)", p)
+		}
+	}
+	if _, err := duration.ParseFormat(opts.DurationFormat); err != nil {
+		return err
+	}
+	if _, err := splitter.ParseRollup(opts.SummaryRollup); err != nil {
+		return err
+	}
+	if opts.VerboseWorkerTop < 0 {
+		return fmt.Errorf("invalid --verbose-worker-top %d (expected 0 or more)", opts.VerboseWorkerTop)
+	}
+	return validateOutputDir(opts)
+}
+
+// validateTagFlags checks the --weight rules, and that --only-tag, --exclude-tag, and tag: rules
+// come with the --metadata file holding the tags.
+func validateTagFlags(opts *SplitOptions) error {
+	rules, err := splitter.ParseWeightRules(opts.Weights)
+	if err != nil {
+		return err
+	}
+	if opts.Metadata != "" {
+		return nil
+	}
+	if len(opts.OnlyTags) > 0 || len(opts.ExcludeTags) > 0 {
+		return errors.New("--only-tag and --exclude-tag need --metadata")
+	}
+	for _, rule := range rules {
+		if rule.Tag {
+			return fmt.Errorf("--weight tag:%s=%g needs --metadata", rule.Pattern, rule.Factor)
+		}
+	}
+	return nil
+}
+
+// validateOutputDir checks that --output-dir, which writes every worker, comes with a valid
+// --output-template and without the flags selecting a single worker.
+func validateOutputDir(opts *SplitOptions) error {
+	switch {
+	case opts.OutputDir == "":
+		return nil
+	case opts.Index != config.Unset || opts.IndexFromHash != "" || opts.ClaimDir != "":
+		return errors.New("--index, --index-from-hash, and --claim-file cannot be combined with --output-dir")
+	}
+	_, err := shardfile.ParseTemplate(opts.OutputTemplate)
+	return err
+}
+
+// validateFormats checks --format and --output-format, which choose between two JSON forms of
+// the selected worker and so cannot both ask for JSON, and --emit.
+func validateFormats(opts *SplitOptions) error {
+	switch {
+	case opts.Format != formatText && opts.Format != formatJSON:
+		return fmt.Errorf("invalid --format %q (expected %s or %s)", opts.Format, formatText, formatJSON)
+	case opts.OutputFormat != formatText && opts.OutputFormat != formatJSON:
+		return fmt.Errorf("invalid --output-format %q (expected %s or %s)", opts.OutputFormat, formatText, formatJSON)
+	case opts.OutputFormat == formatJSON && opts.Format == formatJSON:
+		return errors.New("--output-format json and --format json cannot be used together")
+	case opts.Emit != emitGroups && opts.Emit != emitMembers:
+		return fmt.Errorf("invalid --emit %q (expected %s or %s)", opts.Emit, emitGroups, emitMembers)
+	case opts.Emit == emitMembers && opts.InputFormat != string(splitter.InputGroups):
+		return errors.New("--emit members needs --input-format groups")
+	}
+	return nil
+}
+
+// validateExecFlags checks that --exec and --print-exec, one at a time, come with a valid
+// --exec-template and replace the output of the selected worker.
+func validateExecFlags(opts *SplitOptions) error {
+	switch {
+	case !opts.Exec && !opts.PrintExec:
+		if opts.ExecTemplate != "" {
+			return errors.New("--exec-template needs --exec or --print-exec")
+		}
+		return nil
+	case opts.Exec && opts.PrintExec:
+		return errors.New("--exec and --print-exec cannot be used together")
+	case opts.ExecTemplate == "":
+		return errors.New("--exec and --print-exec need --exec-template")
+	case opts.OutputDir != "" || opts.Format == formatJSON || opts.OutputFormat == formatJSON:
+		return errors.New("--exec and --print-exec cannot be combined with --output-dir or JSON output")
+	}
+	_, err := shardexec.ParseTemplate(opts.ExecTemplate)
+	return err
+}
+
+// newStatsReporter returns a reporter rendering durations in the --duration-format and rolling
+// the summary up by --summary-rollup, both checked by validateOutputFlags.
+func newStatsReporter(logger zerolog.Logger, opts *SplitOptions) *splitter.StatsReporter {
+	rollup, _ := splitter.ParseRollup(opts.SummaryRollup)
+	return splitter.NewStatsReporter(logger,
+		splitter.WithDurationFormat(duration.Format(opts.DurationFormat)),
+		splitter.WithRollup(rollup),
+		splitter.WithWorkerDetails(splitter.WorkerDetails{Verbose: opts.VerboseWorker, Top: opts.VerboseWorkerTop}),
+		splitter.WithCaseWeights(opts.PercentilesByCases))
+}
+
+// validateStatsSHA256 checks that --stats-sha256 is a digest and that exactly one --stats URL
+// selects the report it applies to.
+func validateStatsSHA256(opts *SplitOptions) error {
+	if opts.StatsSHA256 == "" {
+		return nil
+	}
+	if err := storage.ValidateSHA256(opts.StatsSHA256); err != nil {
+		return err
+	}
+	urls := 0
+	for _, pattern := range opts.StatsFiles {
+		if storage.IsURL(pattern) {
+			urls++
+		}
+	}
+	if urls != 1 {
+		return fmt.Errorf("--stats-sha256 needs exactly one s3://, gs://, or https:// --stats URL, got %d", urls)
+	}
+	return nil
+}
+
+// loadStats parses the stats files into a map of test times, moved to the current paths of renamed
+// files with --follow-renames. Failures are fatal only with --strict-stats; otherwise every test
+// falls back to the default time.
+func loadStats(
+	ctx context.Context, logger zerolog.Logger, opts *SplitOptions, ts *testsplit.Splitter,
+	sources []testsplit.TimeSource, git changes.Runner,
+) (map[string]float64, error) {
+	if len(sources) == 0 {
+		logger.Info().Msg("No stats files provided, using default test times")
+		return make(map[string]float64), nil
+	}
+
+	times, err := ts.LoadSources(ctx, sources...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if opts.StrictStats {
+			return nil, statsError(err)
+		}
+		logger.Warn().Err(err).Msg("Failed to load stats files, continuing with defaults")
+		return make(map[string]float64), nil
+	}
+	if opts.FollowRenames {
+		followRenames(ctx, logger, opts, ts, git, times)
+	}
+	return times, nil
+}
+
+// followRenames moves the times of test files renamed in git, between --follow-renames-since and
+// HEAD or in the recent history, to their current paths. Git failures, e.g. outside a repository,
+// are logged instead of failing the split.
+func followRenames(
+	ctx context.Context, logger zerolog.Logger, opts *SplitOptions, ts *testsplit.Splitter, git changes.Runner,
+	times map[string]float64,
+) {
+	renames, err := changes.Renames(ctx, git, opts.RenamesSince, changes.DefaultRenameCommits)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Cannot list renamed files, keeping the stats under their recorded paths")
+		return
+	}
+	moved := ts.FollowRenames(times, renames)
+	logger.Info().
+		Str("since", opts.RenamesSince).
+		Int("renames", len(renames)).
+		Int("moved", moved).
+		Msgf("Moved the times of %d renamed test files to their current paths", moved)
+}
+
+// statsSources returns one source for the local stats patterns, JUnit reports and .json timings
+// alike, one per s3://, gs://, or http(s):// URL, one for the --stats-gotest files of go test -json
+// output, and one for the CircleCI artifacts when enabled.
+// Downloaded reports are kept in the stats cache directory and parsed like local ones.
+// With --stats-merge priority, sources follow the flag order and each local pattern is its own source.
+func statsSources(
+	logger zerolog.Logger, cfg *config.Config, opts *SplitOptions, ts *testsplit.Splitter, open storage.Opener,
+) []testsplit.TimeSource {
+	var local []string
+	var sources []testsplit.TimeSource
+	loadFiles := func(ctx context.Context, paths []string) (map[string]float64, error) {
+		return ts.LoadTimings(ctx, paths...)
+	}
+	priority := opts.StatsMerge == string(junit.MergePriority)
+	for _, pattern := range opts.StatsFiles {
+		switch {
+		case storage.IsURL(pattern):
+			sources = append(sources, storage.NewStatsSource(pattern, open, loadFiles,
+				storage.WithCacheDir(opts.StatsCacheDir),
+				storage.WithLogger(logger),
+				storage.WithSHA256(opts.StatsSHA256),
+				storage.WithDownloadRetries(opts.StatsRetries),
+			))
+		case priority:
+			sources = append(sources, ts.JUnitFiles(pattern))
+		default:
+			local = append(local, pattern)
+		}
+	}
+	if len(local) > 0 {
+		sources = append([]testsplit.TimeSource{ts.JUnitFiles(local...)}, sources...)
+	}
+	if len(opts.StatsGoTest) > 0 {
+		sources = append(sources, gotest.NewSource(logger, opts.StatsGoTest...))
+	}
+	if opts.StatsCircleCIArtifacts {
+		slug := opts.StatsCircleCIProject
+		if slug == "" {
+			slug = cfg.CircleProjectSlug()
+		}
+		client := circleci.NewClient(logger, cfg.CircleToken, circleci.WithBaseURL(cfg.CircleAPIURL))
+		sources = append(sources, circleci.NewSource(client, slug, loadFiles,
+			circleci.WithBranch(opts.StatsBranch),
+			circleci.WithArtifactGlob(opts.StatsArtifactGlob),
+			circleci.WithCacheDir(opts.StatsCacheDir),
+		))
+	}
+	return sources
+}
+
+// splitReports sends the outcome of a split to the --metrics sinks, the --notify-webhook,
+// and the --github-comment pull request.
+type splitReports struct {
+	labels     map[string]string
+	notifier   *notify.Notifier
+	comments   github.Comments
+	repo       string
+	sinks      []metrics.Sink
+	conditions []notify.Condition
+	durations  duration.Format
+	pr         int
+}
+
+// newSplitReports opens the sinks of --metrics, parses --metrics-labels, prepares the
+// --notify-webhook notifier with its --notify-on conditions, and resolves the pull request
+// of --github-comment. A pull request that cannot be resolved is logged and skipped.
+func newSplitReports(logger zerolog.Logger, cfg *config.Config, opts *SplitOptions) (splitReports, error) {
+	labels, err := metrics.ParseLabels(opts.MetricsLabels)
+	if err != nil {
+		return splitReports{}, err
+	}
+	r := splitReports{
+		labels:    labels,
+		durations: duration.Format(opts.DurationFormat),
+	}
+	if r.sinks, err = openSinks(opts.Metrics); err != nil {
+		return splitReports{}, err
+	}
+
+	if opts.NotifyWebhook != "" {
+		if r.conditions, err = notify.ParseConditions(opts.NotifyOn); err != nil {
+			return splitReports{}, err
+		}
+		if r.notifier, err = notify.NewNotifier(opts.NotifyWebhook); err != nil {
+			return splitReports{}, err
+		}
+	}
+
+	if opts.GitHubComment {
+		r.repo = cmp.Or(opts.GitHubRepo, cfg.GitHubRepository)
+		r.pr = cmp.Or(opts.GitHubPR, github.PullRequestNumber(cfg.GitHubRef, cfg.GitHubEventPath))
+		switch {
+		case cfg.GitHubToken == "":
+			logger.Warn().Msg("Skipping the GitHub comment: " + github.ErrMissingToken.Error())
+		case r.repo == "" || r.pr <= 0:
+			logger.Warn().
+				Str("repo", r.repo).
+				Int("pr", r.pr).
+				Msg("Skipping the GitHub comment: repository or pull request unknown (--github-repo, --github-pr)")
+		default:
+			r.comments = github.NewClient(cfg.GitHubToken, github.WithBaseURL(cfg.GitHubAPIURL))
+		}
+	}
+	return r, nil
+}
+
+// send emits the gauges of the split, as seen by worker index, posts a notification when a
+// condition holds, and upserts the pull-request comment. Only worker 0 notifies and comments,
+// since every worker computes the same split. Failures are logged and never fail the split.
+func (r splitReports) send(
+	ctx context.Context, logger zerolog.Logger, result *testsplit.Result, stats testsplit.Distribution, index int,
+) {
+	first := index == 0
+	if len(r.sinks) == 0 && (!first || (r.notifier == nil && r.comments == nil)) {
+		return
+	}
+	workers := make([]testsplit.Group, result.Len())
+	for i := range workers {
+		workers[i] = *result.GroupRef(i)
+	}
+	untimed := countUntimed(workers)
+
+	if len(r.sinks) > 0 {
+		run := metrics.Run{Labels: r.labels, Distribution: stats, Index: index, Total: len(workers), Untimed: untimed}
+		metrics.Emit(ctx, logger, r.sinks, metrics.Gauges(run), metrics.DefaultTimeout)
+	}
+	if first && r.notifier != nil {
+		r.notify(ctx, logger, notify.Run{Workers: workers, Distribution: stats, Index: index})
+	}
+	if first && r.comments != nil {
+		tests := 0
+		for _, w := range workers {
+			tests += len(w.Tests)
+		}
+		r.comment(ctx, logger, github.Summary(stats, tests, untimed, r.durations))
+	}
+}
+
+// notify posts a notification when a --notify-on condition holds, within notify.DefaultTimeout.
+func (r splitReports) notify(ctx context.Context, logger zerolog.Logger, run notify.Run) {
+	payload, triggered := notify.Evaluate(r.conditions, run)
+	if !triggered {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, notify.DefaultTimeout)
+	defer cancel()
+	if err := r.notifier.Send(ctx, payload); err != nil {
+		logger.Warn().Err(err).Msg("Failed to send webhook notification")
+		return
+	}
+	logger.Info().Int("conditions", len(payload.Triggered)).Msg("Sent webhook notification")
+}
+
+// comment upserts the summary comment of the pull request, within githubTimeout.
+func (r splitReports) comment(ctx context.Context, logger zerolog.Logger, summary string) {
+	ctx, cancel := context.WithTimeout(ctx, githubTimeout)
+	defer cancel()
+	action, err := github.Upsert(ctx, r.comments, r.repo, r.pr, summary)
+	if err != nil {
+		logger.Warn().Err(err).Str("repo", r.repo).Int("pr", r.pr).Msg("Failed to comment on the pull request")
+		return
+	}
+	logger.Info().Str("repo", r.repo).Int("pr", r.pr).Str("action", string(action)).Msg("Commented on the pull request")
+}
+
+// countUntimed returns the number of tests without historical timing data.
+func countUntimed(workers []testsplit.Group) int {
+	untimed := 0
+	for _, w := range workers {
+		for _, test := range w.Tests {
+			if test.Source == testsplit.SourceDefault {
+				untimed++
+			}
+		}
+	}
+	return untimed
+}
@@ -2,33 +2,51 @@ package cmd_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/rs/zerolog"
 
 	"github.com/prgtw/tests-helper/cmd"
+	"github.com/prgtw/tests-helper/internal/changes"
+	"github.com/prgtw/tests-helper/internal/inputcmd"
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/splitter"
+	"github.com/prgtw/tests-helper/internal/storage"
+	"github.com/prgtw/tests-helper/internal/worker"
 )
 
 func TestSplitCommand_Integration(t *testing.T) {
 	tests := []struct {
-		name            string
-		input           string
-		statsFiles      []string
-		index           int
-		total           int
-		noPercentiles   bool
-		wantTestCount   int
-		wantInOutput    []string
-		wantNotInOutput []string
+		name          string
+		input         string
+		statsFiles    []string
+		wantInOutput  []string
+		index         int
+		total         int
+		wantTestCount int
 	}{
 		{
 			name:          "basic split without stats",
 			input:         "test1.go\ntest2.go\ntest3.go\ntest4.go\n",
 			index:         0,
 			total:         2,
-			noPercentiles: true,
 			wantTestCount: 2,
 			wantInOutput:  []string{"test"},
 		},
@@ -38,7 +56,6 @@ func TestSplitCommand_Integration(t *testing.T) {
 			statsFiles:    []string{"../testdata/junit/example*.xml"},
 			index:         0,
 			total:         2,
-			noPercentiles: true,
 			wantTestCount: 2,
 		},
 		{
@@ -46,7 +63,6 @@ func TestSplitCommand_Integration(t *testing.T) {
 			input:         "single.go\n",
 			index:         0,
 			total:         3,
-			noPercentiles: true,
 			wantTestCount: 1,
 			wantInOutput:  []string{"single.go"},
 		},
@@ -55,81 +71,129 @@ func TestSplitCommand_Integration(t *testing.T) {
 			input:         "test1.go\ntest2.go\n",
 			index:         2,
 			total:         5,
-			noPercentiles: true,
 			wantTestCount: 0, // Worker 2 should have no tests
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// This is a simplified integration test
-			// A full test would need to capture the actual command execution
-			// For now, we test that the command structure is correct
+			opts := cmd.DefaultSplitOptions()
+			opts.StatsFiles = tt.statsFiles
+			opts.Index, opts.Total = tt.index, tt.total
+			opts.NoPercentiles = true
 
-			// The actual integration would be better tested via:
-			// 1. Building the binary
-			// 2. Running it as a subprocess
-			// 3. Capturing stdout/stderr
-
-			// For unit testing the runSplit function directly, we'd need to export it
-			// or test through the public Execute() function
+			var stdout, stderr bytes.Buffer
+			if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(tt.input), &stdout, &stderr); err != nil {
+				t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+			}
 
-			t.Skip("Full integration test requires command execution - see TestE2E")
+			lines := strings.Fields(stdout.String())
+			if len(lines) != tt.wantTestCount {
+				t.Errorf("Got %d tests, want %d: %v", len(lines), tt.wantTestCount, lines)
+			}
+			for _, want := range tt.wantInOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("Output does not contain %q:\n%s", want, stdout.String())
+				}
+			}
+			if !strings.Contains(stderr.String(), "Split completed successfully") {
+				t.Errorf("Logs do not report completion:\n%s", stderr.String())
+			}
 		})
 	}
 }
 
-// TestE2E is an end-to-end test that runs the actual binary.
-func TestE2E(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping E2E test in short mode")
-	}
-
-	// Check if binary exists
-	_, err := os.Stat("../tests-helper")
-	if err != nil {
-		t.Skip("Binary not built, run 'go build' first")
-	}
-
+// TestCommandTree_Output runs the full command tree in-process and captures its output.
+func TestCommandTree_Output(t *testing.T) {
 	tests := []struct {
 		name         string
-		inputFile    string
 		args         []string
-		wantExit0    bool
 		wantInStdout []string
-		wantInStderr []string
 	}{
 		{
-			name:      "help command",
-			args:      []string{"split", "--help"},
-			wantExit0: true,
+			name: "help command",
+			args: []string{"split", "--help"},
 			wantInStdout: []string{
-				"Split tests across parallel workers",
+				"Split reads a list of test files",
 				"--stats",
 				"--index",
 				"--total",
 			},
 		},
 		{
-			name:      "version",
-			args:      []string{"--version"},
-			wantExit0: true,
-			wantInStdout: []string{
-				"1.0.0",
-			},
+			name:         "version",
+			args:         []string{"--version"},
+			wantInStdout: []string{"1.0.0"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// This would use exec.Command to run the binary
-			// and verify the output
-			t.Skip("E2E test requires subprocess execution")
+			tree := cmd.NewCommandTree(zerolog.Nop(), cmd.BuildInfo{Version: "1.0.0"})
+			var stdout bytes.Buffer
+			tree.SetOut(&stdout)
+			tree.SetArgs(tt.args)
+
+			if _, err := tree.ExecuteC(); err != nil {
+				t.Fatalf("ExecuteC failed: %v", err)
+			}
+			for _, want := range tt.wantInStdout {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("Output does not contain %q:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSplitCommand_Flags(t *testing.T) {
+	tree := cmd.NewCommandTree(zerolog.Nop(), cmd.BuildInfo{})
+	var stdout bytes.Buffer
+	tree.SetIn(strings.NewReader("slow_test.go 10\nfast_test.go 1\nother_test.go 1\n"))
+	tree.SetOut(&stdout)
+	tree.SetArgs([]string{"split", "--index", "1", "--total", "2", "--inline-times", "--no-percentiles"})
+
+	if _, err := tree.ExecuteC(); err != nil {
+		t.Fatalf("ExecuteC failed: %v", err)
+	}
+	if got := stdout.String(); got != "fast_test.go\nother_test.go\n" && got != "other_test.go\nfast_test.go\n" {
+		t.Errorf("Worker 1 got %q, want the two fast tests", got)
+	}
+}
+
+func TestSplitCommand_Dedupe(t *testing.T) {
+	for _, tt := range []struct {
+		dedupe string
+		want   string
+	}{
+		{dedupe: "keep", want: "a_test.go\nb_test.go\na_test.go\n"},
+		{dedupe: "first", want: "a_test.go\nb_test.go\n"},
+	} {
+		t.Run(tt.dedupe, func(t *testing.T) {
+			tree := cmd.NewCommandTree(zerolog.Nop(), cmd.BuildInfo{})
+			var stdout bytes.Buffer
+			tree.SetIn(strings.NewReader("a_test.go\nb_test.go\na_test.go\n"))
+			tree.SetOut(&stdout)
+			tree.SetArgs([]string{"split", "--index", "0", "--total", "1", "--dedupe", tt.dedupe, "--no-percentiles"})
+
+			if _, err := tree.ExecuteC(); err != nil {
+				t.Fatalf("ExecuteC failed: %v", err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("Got %q, want %q", got, tt.want)
+			}
 		})
 	}
+
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total, opts.Dedupe = 0, 1, "last"
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+	if cmd.ExitCode(err) != cmd.ExitUsage {
+		t.Errorf("Expected a usage error for an unknown dedupe mode, got %v", err)
+	}
 }
 
-// TestSplitOptionsValidation tests the validation logic.
+// TestSplitOptionsValidation tests the validation of the worker index against the total.
 func TestSplitOptionsValidation(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -151,7 +215,7 @@ func TestSplitOptionsValidation(t *testing.T) {
 		},
 		{
 			name:      "negative index",
-			index:     -1,
+			index:     -2,
 			total:     2,
 			wantError: true,
 		},
@@ -165,64 +229,1988 @@ func TestSplitOptionsValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Test index validation
-			if tt.index < 0 || tt.index >= tt.total {
-				if !tt.wantError {
-					t.Error("Expected validation to pass but indices are invalid")
-				}
-			} else {
-				if tt.wantError {
-					t.Error("Expected validation to fail but indices are valid")
+			opts := cmd.DefaultSplitOptions()
+			opts.Index, opts.Total = tt.index, tt.total
+
+			err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+			if tt.wantError {
+				if cmd.ExitCode(err) != cmd.ExitUsage {
+					t.Errorf("Expected a usage error, got %v", err)
 				}
+			} else if err != nil {
+				t.Errorf("Expected validation to pass, got %v", err)
 			}
 		})
 	}
 }
 
 func TestSplitCommand_EmptyInput(t *testing.T) {
-	// Test that empty input is handled correctly
-	input := ""
-	stdin := strings.NewReader(input)
-	stdout := &bytes.Buffer{}
-	stderr := &bytes.Buffer{}
-
-	logger := zerolog.New(stderr).Level(zerolog.Disabled)
-
-	// We would test runSplit here if it were exported
-	// For now, this documents the expected behavior
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
 
-	_ = stdin
-	_ = stdout
-	_ = logger
-
-	t.Skip("Requires exported runSplit function or Execute with dependency injection")
+	var stdout bytes.Buffer
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader("# only a comment\n\n"), &stdout, io.Discard)
+	if !errors.Is(err, splitter.ErrNoTests) {
+		t.Errorf("Expected ErrNoTests, got %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output, got %q", stdout.String())
+	}
 }
 
 func TestSplitCommand_WithCircleCIEnv(t *testing.T) {
-	// Test CircleCI environment variable handling
 	t.Setenv("CIRCLE_NODE_INDEX", "1")
 	t.Setenv("CIRCLE_NODE_TOTAL", "4")
 
-	// Verify env vars are set
-	if os.Getenv("CIRCLE_NODE_INDEX") != "1" {
-		t.Error("CIRCLE_NODE_INDEX not set correctly")
-	}
-	if os.Getenv("CIRCLE_NODE_TOTAL") != "4" {
-		t.Error("CIRCLE_NODE_TOTAL not set correctly")
+	var stdout, stderr bytes.Buffer
+	input := strings.NewReader("a 4\nb 3\nc 2\nd 1\n")
+	opts := cmd.DefaultSplitOptions()
+	opts.InlineTimes = true
+	if err := cmd.RunSplit(t.Context(), opts, input, &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
 	}
 
-	// The actual command would use these values
-	// This test documents the expected behavior
-	t.Skip("Requires command execution to verify env var usage")
+	// Sorted by time, b is the second test and goes to worker 1
+	if got := stdout.String(); got != "b\n" {
+		t.Errorf("Worker 1 got %q, want %q", got, "b\n")
+	}
 }
 
 // This test verifies the command is properly registered.
 func TestSplitCommand_Registration(t *testing.T) {
-	// Create root command
-	_ = cmd.Execute
+	tree := cmd.NewCommandTree(zerolog.Nop(), cmd.BuildInfo{})
+	split, _, err := tree.Find([]string{"split"})
+	if err != nil || split.Name() != "split" {
+		t.Errorf("split command is not registered: %v", err)
+	}
+}
+
+// failingWriter accepts limit bytes and then fails every write with err.
+type failingWriter struct {
+	err     error
+	written int
+	limit   int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		n := w.limit - w.written
+		w.written = w.limit
+		return n, w.err
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestWriteOutput(t *testing.T) {
+	logger := zerolog.Nop()
+
+	tests := make([]junit.Test, 50_000)
+	for i := range tests {
+		tests[i] = junit.Test{Name: fmt.Sprintf("pkg/file%d_test.go", i)}
+	}
+
+	t.Run("writes all names", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := cmd.WriteOutput(logger, &buf, tests); err != nil {
+			t.Fatalf("WriteOutput failed: %v", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(lines) != len(tests) {
+			t.Fatalf("Got %d lines, want %d", len(lines), len(tests))
+		}
+		if lines[len(lines)-1] != tests[len(tests)-1].Name {
+			t.Errorf("Last line: got %q, want %q", lines[len(lines)-1], tests[len(tests)-1].Name)
+		}
+	})
+
+	t.Run("broken pipe after partial write is not an error", func(t *testing.T) {
+		w := &failingWriter{err: syscall.EPIPE, limit: 10_000}
+		if err := cmd.WriteOutput(logger, w, tests); err != nil {
+			t.Errorf("Expected nil error on EPIPE, got %v", err)
+		}
+	})
+
+	t.Run("other write errors are reported", func(t *testing.T) {
+		w := &failingWriter{err: syscall.ENOSPC, limit: 10_000}
+		err := cmd.WriteOutput(logger, w, tests)
+		if !errors.Is(err, syscall.ENOSPC) {
+			t.Errorf("Expected ENOSPC error, got %v", err)
+		}
+	})
+
+	t.Run("single write", func(t *testing.T) {
+		w := &cancelWriter{cancel: func() {}}
+		if err := cmd.WriteOutput(logger, w, tests); err != nil || w.writes != 1 {
+			t.Errorf("WriteOutput = %v in %d writes, want the whole list in one", err, w.writes)
+		}
+	})
+}
+
+// cancelWriter records what is written to it and calls cancel once a write contains trigger, which
+// an empty trigger always does.
+type cancelWriter struct {
+	bytes.Buffer
+
+	cancel  context.CancelFunc
+	trigger string
+	writes  int
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if bytes.Contains(p, []byte(w.trigger)) {
+		w.cancel()
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestSplitCommand_InterruptedOutput(t *testing.T) {
+	input := "a_test.go 1\nb_test.go 2\nc_test.go 3\n"
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.InlineTimes = true
+
+	t.Run("before the output", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		// The signal arrives once the split is done, while its summary is logged
+		stderr := &cancelWriter{cancel: cancel, trigger: "Distribution Summary"}
+
+		var stdout bytes.Buffer
+		err := cmd.RunSplit(ctx, opts, strings.NewReader(input), &stdout, stderr)
+		if code := cmd.ExitCode(err); code != cmd.ExitInterrupted {
+			t.Errorf("Exit code = %d, want %d (err: %v)", code, cmd.ExitInterrupted, err)
+		}
+		if stdout.Len() > 0 {
+			t.Errorf("Interrupted split wrote %q, want nothing", stdout.String())
+		}
+	})
+
+	t.Run("during the output", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		stdout := &cancelWriter{cancel: cancel}
+
+		if err := cmd.RunSplit(ctx, opts, strings.NewReader(input), stdout, io.Discard); err != nil {
+			t.Fatalf("RunSplit failed: %v", err)
+		}
+		if want := "c_test.go\nb_test.go\na_test.go\n"; stdout.String() != want || stdout.writes != 1 {
+			t.Errorf("Output = %q in %d writes, want %q in one", stdout.String(), stdout.writes, want)
+		}
+	})
+}
+
+func TestSplitCommand_InvalidTotal(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "tests.txt")
+	if err := os.WriteFile(input, []byte("a_test.go\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input file: %v", err)
+	}
+
+	for _, total := range []string{"0", "-3"} {
+		t.Run(total, func(t *testing.T) {
+			err := cmd.Run([]string{"split", "--input", input, "--index", "0", "--total", total})
+			want := "invalid worker count: must be at least 1 (got " + total + " from --total)"
+			if err == nil || err.Error() != want {
+				t.Errorf("Error: got %v, want %q", err, want)
+			}
+			if !errors.Is(err, worker.ErrInvalidWorkerCount) {
+				t.Errorf("Error %v does not wrap ErrInvalidWorkerCount", err)
+			}
+		})
+	}
+}
+
+func TestSplitCommand_RemoteStats(t *testing.T) {
+	report, err := os.ReadFile("../testdata/junit/example1.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	bucket := &memoryBucket{objects: map[string][]byte{"ci/main/example1.xml": report}}
+	open := func(context.Context, storage.Location) (storage.Bucket, error) { return bucket, nil }
+	input := "pkg/api/handler_test.go\nnew_test.go\n"
+
+	opts := cmd.DefaultSplitOptions()
+	opts.StatsFiles = []string{"s3://reports/ci/main/*.xml"}
+	opts.StatsCacheDir = t.TempDir()
+	opts.Index, opts.Total = 0, 2
+	var stdout bytes.Buffer
+	err = cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(input), &stdout,
+		open, inputcmd.Exec, nil, nil)
+	if err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	// The downloaded stats make the handler test the longest, so it goes first
+	if got := stdout.String(); got != "pkg/api/handler_test.go\n" {
+		t.Errorf("Worker 0 got %q, want the test timed by the downloaded report", got)
+	}
+
+	// Access errors follow the lenient and strict stats rules
+	denied := func(context.Context, storage.Location) (storage.Bucket, error) {
+		return nil, errors.New("no credentials")
+	}
+	err = cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(input), io.Discard,
+		denied, nil, nil, nil)
+	if err != nil {
+		t.Errorf("Lenient split failed: %v", err)
+	}
+	opts.StrictStats = true
+	err = cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(input), io.Discard,
+		denied, nil, nil, nil)
+	if code := cmd.ExitCode(err); code != cmd.ExitStats {
+		t.Errorf("Strict split exit code = %d (%v), want %d", code, err, cmd.ExitStats)
+	}
+}
+
+func TestSplitCommand_StatsSHA256(t *testing.T) {
+	report, err := os.ReadFile("../testdata/junit/example1.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(report)
+	}))
+	defer server.Close()
+	sum := sha256.Sum256(report)
+	digest := hex.EncodeToString(sum[:])
+	args := []string{"split", "--index", "0", "--total", "1", "--no-percentiles", "--input", writeTestList(t),
+		"--stats", server.URL + "/example1.xml", "--stats-retries", "0"}
+
+	if code := cmd.Main(append(args, "--stats-sha256", digest, "--strict-stats"), io.Discard); code != cmd.ExitOK {
+		t.Errorf("Matching digest exit code = %d, want %d", code, cmd.ExitOK)
+	}
+	// A mismatch follows the lenient and strict stats rules
+	wrong := strings.Repeat("0", 64)
+	if code := cmd.Main(append(args, "--stats-sha256", wrong), io.Discard); code != cmd.ExitOK {
+		t.Errorf("Lenient mismatch exit code = %d, want %d", code, cmd.ExitOK)
+	}
+	if code := cmd.Main(append(args, "--stats-sha256", wrong, "--strict-stats"), io.Discard); code != cmd.ExitStats {
+		t.Errorf("Strict mismatch exit code = %d, want %d", code, cmd.ExitStats)
+	}
+
+	for _, bad := range [][]string{
+		{"--stats-sha256", "abc"},
+		{"--stats-sha256", digest, "--stats", "s3://bucket/other.xml"},
+	} {
+		if code := cmd.Main(append(args, bad...), io.Discard); code != cmd.ExitUsage {
+			t.Errorf("%v exit code = %d, want %d", bad, code, cmd.ExitUsage)
+		}
+	}
+}
+
+func TestSplitCommand_CircleCIArtifactsWithoutToken(t *testing.T) {
+	t.Setenv("CIRCLE_TOKEN", "")
+	t.Setenv("CIRCLE_PROJECT_USERNAME", "org")
+	t.Setenv("CIRCLE_PROJECT_REPONAME", "repo")
+	args := []string{"split", "--index", "0", "--total", "1", "--no-percentiles", "--stats-circleci-artifacts"}
+
+	var stderr bytes.Buffer
+	if code := cmd.Main(append(args, "--input", writeTestList(t)), &stderr); code != cmd.ExitOK {
+		t.Errorf("Lenient exit code = %d, want %d\n%s", code, cmd.ExitOK, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "CIRCLE_TOKEN") {
+		t.Errorf("Expected a warning naming CIRCLE_TOKEN, got:\n%s", stderr.String())
+	}
+	if code := cmd.Main(append(args, "--input", writeTestList(t), "--strict-stats"), io.Discard); code != cmd.ExitStats {
+		t.Errorf("Strict exit code = %d, want %d", code, cmd.ExitStats)
+	}
+}
+
+func TestSplitCommand_Metrics(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+	args := []string{"split", "--index", "0", "--total", "2", "--no-percentiles", "--input", writeTestList(t)}
+
+	var stderr bytes.Buffer
+	url := "pushgateway://" + strings.TrimPrefix(server.URL, "http://") + "/job/tests-helper"
+	code := cmd.Main(append(args, "--metrics", url, "--metrics-labels", "branch=main"), &stderr)
+	if code != cmd.ExitOK {
+		t.Fatalf("Exit code = %d, want %d\n%s", code, cmd.ExitOK, stderr.String())
+	}
+	if want := `tests_helper_untimed_tests{branch="main",total="2"} 1`; !strings.Contains(body, want) {
+		t.Errorf("Pushed metrics lack %q:\n%s", want, body)
+	}
+
+	// An unreachable sink is only logged
+	stderr.Reset()
+	if code := cmd.Main(append(args, "--metrics", "pushgateway://127.0.0.1:1/job/x"), &stderr); code != cmd.ExitOK {
+		t.Errorf("Unreachable sink exit code = %d, want %d", code, cmd.ExitOK)
+	}
+	if !strings.Contains(stderr.String(), "Failed to emit metrics") {
+		t.Errorf("Expected a warning, got:\n%s", stderr.String())
+	}
+
+	for _, bad := range [][]string{{"--metrics", "udp://host:1"}, {"--metrics-labels", "index=1"}} {
+		if code := cmd.Main(append(args, bad...), io.Discard); code != cmd.ExitUsage {
+			t.Errorf("%v exit code = %d, want %d", bad, code, cmd.ExitUsage)
+		}
+	}
+}
+
+func TestSplitCommand_NotifyWebhook(t *testing.T) {
+	var payloads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		payloads = append(payloads, string(data))
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	// Without stats every test uses the default time
+	args := []string{"split", "--total", "2", "--no-percentiles", "--input", writeTestList(t)}
+
+	if code := cmd.Main(append(args, "--index", "0", "--notify-webhook", server.URL), io.Discard); code != cmd.ExitOK {
+		t.Fatalf("Exit code = %d, want %d", code, cmd.ExitOK)
+	}
+	if len(payloads) != 1 || !strings.Contains(payloads[0], `"condition":"all-default\u003e50"`) ||
+		!strings.Contains(payloads[0], `"text":`) {
+		t.Errorf("Payloads = %q, want one all-default notification with a text field", payloads)
+	}
+
+	// Other workers compute the same split and stay quiet
+	if code := cmd.Main(append(args, "--index", "1", "--notify-webhook", server.URL), io.Discard); code != cmd.ExitOK ||
+		len(payloads) != 1 {
+		t.Errorf("Worker 1: exit code %d after %d payloads, want %d after 1", code, len(payloads), cmd.ExitOK)
+	}
+
+	// Conditions that do not hold send nothing, and a failing webhook is only logged
+	quiet := append(args, "--index", "0", "--notify-webhook", server.URL, "--notify-on", "imbalance>200")
+	broken := append(args, "--index", "0", "--notify-webhook", server.URL+"/broken")
+	if code := cmd.Main(quiet, io.Discard); code != cmd.ExitOK || len(payloads) != 1 {
+		t.Errorf("Quiet run: exit code %d after %d payloads", code, len(payloads))
+	}
+	if code := cmd.Main(broken, io.Discard); code != cmd.ExitOK || len(payloads) != 2 {
+		t.Errorf("Broken webhook: exit code %d after %d payloads, want %d after 2", code, len(payloads), cmd.ExitOK)
+	}
+
+	for _, bad := range [][]string{{"--notify-on", "speed>1"}, {"--notify-on", "imbalance=1"}} {
+		bad = append(bad, "--index", "0", "--notify-webhook", server.URL)
+		if code := cmd.Main(append(args, bad...), io.Discard); code != cmd.ExitUsage {
+			t.Errorf("%v exit code = %d, want %d", bad, code, cmd.ExitUsage)
+		}
+	}
+}
+
+func TestSplitCommand_GitHubComment(t *testing.T) {
+	var comments []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte("[]"))
+		case r.URL.Path == "/repos/org/repo/issues/9/comments":
+			data, _ := io.ReadAll(r.Body)
+			comments = append(comments, string(data))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_API_URL", server.URL)
+	t.Setenv("GITHUB_TOKEN", "secret")
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_REF", "refs/pull/9/merge")
+	t.Setenv("GITHUB_EVENT_PATH", "")
+	args := []string{"split", "--index", "0", "--total", "2", "--no-percentiles", "--input", writeTestList(t),
+		"--github-comment"}
 
-	// The Execute function should register the split command
-	// We can't easily test this without running Execute,
-	// but this documents that the command exists
-	t.Log("Split command should be registered via Execute()")
+	if code := cmd.Main(args, io.Discard); code != cmd.ExitOK {
+		t.Fatalf("Exit code = %d, want %d", code, cmd.ExitOK)
+	}
+	if len(comments) != 1 || !strings.Contains(comments[0], "Test split across 2 workers") {
+		t.Errorf("Comments = %q, want one summary", comments)
+	}
+
+	// API errors and an unknown pull request are only logged
+	var stderr bytes.Buffer
+	if code := cmd.Main(append(args, "--github-pr", "10"), &stderr); code != cmd.ExitOK {
+		t.Errorf("Failing API exit code = %d, want %d", code, cmd.ExitOK)
+	}
+	t.Setenv("GITHUB_REF", "refs/heads/main")
+	if code := cmd.Main(args, &stderr); code != cmd.ExitOK || len(comments) != 1 {
+		t.Errorf("Push build: exit code %d after %d comments", code, len(comments))
+	}
+	for _, want := range []string{"Failed to comment on the pull request", "Skipping the GitHub comment"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %q in the logs:\n%s", want, stderr.String())
+		}
+	}
+}
+
+func TestSplitCommand_PrioritizeChanged(t *testing.T) {
+	repo := newGitRepo(t)
+	repo.commit(t, map[string]string{"a/a_test.go": "", "b/b.go": "", "b/b_test.go": "", "c/c_test.go": ""})
+	repo.git(t, "checkout", "-q", "-b", "feature")
+	repo.commit(t, map[string]string{"b/b.go": "changed"})
+
+	split := func(rules ...string) string {
+		t.Helper()
+		opts := cmd.DefaultSplitOptions()
+		opts.Index, opts.Total = 0, 1
+		opts.NoPercentiles = true
+		opts.PrioritizeChanged = true
+		opts.ChangedSince = "main"
+		opts.ChangedRules = rules
+		var stdout, stderr bytes.Buffer
+		input := strings.NewReader("a/a_test.go\nb/b_test.go\nc/c_test.go\n")
+		if err := cmd.RunSplit(t.Context(), opts, input, &stdout, &stderr); err != nil {
+			t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	if got, want := split(), "b/b_test.go\na/a_test.go\nc/c_test.go\n"; got != want {
+		t.Errorf("Output = %q, want %q", got, want)
+	}
+	if got, want := split("b/*.go -> c/**"), "b/b_test.go\nc/c_test.go\na/a_test.go\n"; got != want {
+		t.Errorf("Output with a rule = %q, want %q", got, want)
+	}
+
+	// Outside a repository the split order is kept
+	t.Chdir(t.TempDir())
+	var stderr bytes.Buffer
+	args := []string{"split", "--index", "0", "--total", "1", "--input", writeTestList(t), "--prioritize-changed"}
+	if code := cmd.Main(args, &stderr); code != cmd.ExitOK {
+		t.Errorf("Exit code outside a repository = %d, want %d", code, cmd.ExitOK)
+	}
+	if !strings.Contains(stderr.String(), "Cannot list changed files") {
+		t.Errorf("Expected a warning, got:\n%s", stderr.String())
+	}
+	if code := cmd.Main(append(args, "--changed-rule", "src/**"), io.Discard); code != cmd.ExitUsage {
+		t.Errorf("Invalid rule exit code = %d, want %d", code, cmd.ExitUsage)
+	}
+}
+
+func TestSplitCommand_ChangedOnly(t *testing.T) {
+	repo := newGitRepo(t)
+	repo.commit(t, map[string]string{"src/foo/foo.go": "", "api/api_test.go": "", "tests/foo/foo_test.go": ""})
+	repo.git(t, "checkout", "-q", "-b", "feature")
+	repo.commit(t, map[string]string{"api/api.go": "", "src/foo/foo.go": "changed"})
+
+	split := func(rules ...string) (string, string) {
+		t.Helper()
+		opts := cmd.DefaultSplitOptions()
+		opts.Index, opts.Total = 0, 1
+		opts.NoPercentiles = true
+		opts.InlineTimes = true
+		opts.ChangedOnly = true
+		opts.ChangedSince = "main"
+		opts.ChangedRules = rules
+		var stdout, stderr bytes.Buffer
+		input := strings.NewReader("api/api_test.go 3\ntests/foo/foo_test.go 4\nweb/web_test.go 5\n")
+		if err := cmd.RunSplit(t.Context(), opts, input, &stdout, &stderr); err != nil {
+			t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+		}
+		return stdout.String(), stderr.String()
+	}
+
+	stdout, stderr := split()
+	if stdout != "api/api_test.go\n" {
+		t.Errorf("Output = %q, want only the test next to the changed file", stdout)
+	}
+	for _, want := range []string{"Filtered tests by changed files", "skipped", "saved_time"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("Expected %q in the logs:\n%s", want, stderr)
+		}
+	}
+	if stdout, _ = split("src/foo/** -> tests/foo/**"); stdout != "tests/foo/foo_test.go\napi/api_test.go\n" {
+		t.Errorf("Output with a rule = %q, want the mapped test too", stdout)
+	}
+
+	// Nothing affected leaves an empty shard instead of failing
+	repo.git(t, "checkout", "-q", "main")
+	stdout, stderr = split()
+	if stdout != "" || !strings.Contains(stderr, "No tests are affected by the changed files") {
+		t.Errorf("Output = %q, want none and a log line:\n%s", stdout, stderr)
+	}
+}
+
+func TestSplitCommand_OutputDir(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "shard_05_of_06.txt")
+	if err := os.WriteFile(stale, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write stale file: %v", err)
+	}
+	opts := cmd.DefaultSplitOptions()
+	opts.Total = 2
+	opts.NoPercentiles = true
+	opts.OutputDir = dir
+	opts.OutputTemplate = "shard_{index:02}_of_{total:02}.txt"
+	opts.CleanOutputDir = true
+
+	var stdout bytes.Buffer
+	input := strings.NewReader("a_test.go\nb_test.go\nc_test.go\n")
+	if err := cmd.RunSplit(t.Context(), opts, input, &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Stdout = %q, want nothing when writing files", stdout.String())
+	}
+	lines := 0
+	for _, name := range []string{"shard_00_of_02.txt", "shard_01_of_02.txt"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Missing worker file: %v", err)
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != 3 {
+		t.Errorf("Worker files list %d tests, want 3", lines)
+	}
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stale file was not removed: %v", err)
+	}
+
+	for name, modify := range map[string]func(*cmd.SplitOptions){
+		"template without {index}": func(o *cmd.SplitOptions) { o.OutputTemplate = "shard.txt" },
+		"with --index":             func(o *cmd.SplitOptions) { o.Index = 1 },
+		"with --index-from-hash":   func(o *cmd.SplitOptions) { o.IndexFromHash = "runner-1" },
+	} {
+		o := opts
+		modify(&o)
+		err := cmd.RunSplit(t.Context(), o, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+		if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+			t.Errorf("%s: exit code %d (%v), want %d", name, code, err, cmd.ExitUsage)
+		}
+	}
+}
+
+func TestSplitCommand_InputGroups(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 1, 2
+	opts.NoPercentiles = true
+	opts.StatsFiles = []string{writeStatsTimings(t)}
+	opts.InputFormat = "groups"
+	// //:a takes 8s and the default 1s of its unknown member, more than //:bc and //:d together
+	input := "//:a\tpkg/a_test.go,pkg/new_test.go\n//:bc\tpkg/b_test.go,pkg/c_test.go\n//:d\tpkg/d_test.go\n"
+
+	for emit, want := range map[string]string{
+		"groups":  "//:bc\n//:d\n",
+		"members": "pkg/b_test.go\npkg/c_test.go\npkg/d_test.go\n",
+	} {
+		o := opts
+		o.Emit = emit
+		var stdout bytes.Buffer
+		if err := cmd.RunSplit(t.Context(), o, strings.NewReader(input), &stdout, io.Discard); err != nil {
+			t.Fatalf("--emit %s: RunSplit failed: %v", emit, err)
+		}
+		if got := stdout.String(); got != want {
+			t.Errorf("--emit %s: output = %q, want %q", emit, got, want)
+		}
+	}
+
+	o := opts
+	o.Emit, o.OutputFormat = "members", "json"
+	var stdout bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), o, strings.NewReader(input), &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	want := `{"index":1,"total":2,"predicted_seconds":7,"tests":[` +
+		`{"name":"pkg/b_test.go","time":4,"estimated":false},{"name":"pkg/c_test.go","time":2,"estimated":false},` +
+		`{"name":"pkg/d_test.go","time":1,"estimated":false}]}` + "\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("JSON output =\n%s\nwant\n%s", got, want)
+	}
+
+	for name, modify := range map[string]func(*cmd.SplitOptions){
+		"invalid --input-format":  func(o *cmd.SplitOptions) { o.InputFormat = "csv" },
+		"invalid --emit":          func(o *cmd.SplitOptions) { o.Emit = "targets" },
+		"members of a plain list": func(o *cmd.SplitOptions) { o.InputFormat, o.Emit = "lines", "members" },
+	} {
+		o := opts
+		modify(&o)
+		err := cmd.RunSplit(t.Context(), o, strings.NewReader(input), io.Discard, io.Discard)
+		if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+			t.Errorf("%s: exit code %d (%v), want %d", name, code, err, cmd.ExitUsage)
+		}
+	}
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader("//:a pkg/a_test.go\n"), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitInput {
+		t.Errorf("Line without a tab: exit code %d (%v), want %d", code, err, cmd.ExitInput)
+	}
+}
+
+func TestSplitCommand_OutputDirJSON(t *testing.T) {
+	dir := t.TempDir()
+	opts := cmd.DefaultSplitOptions()
+	opts.Total = 2
+	opts.NoPercentiles = true
+	opts.InlineTimes = true
+	opts.OutputDir = dir
+	opts.OutputFormat = "json"
+
+	var stdout bytes.Buffer
+	input := strings.NewReader("a_test.go 3\nb_test.go 2\nc_test.go\n")
+	if err := cmd.RunSplit(t.Context(), opts, input, &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	want := `{"workers":[` +
+		`{"index":0,"total":2,"predicted_seconds":3,"tests":[{"name":"a_test.go","time":3,"estimated":false}],` +
+		`"file":"` + filepath.Join(dir, "worker-0.txt") + `"},` +
+		`{"index":1,"total":2,"predicted_seconds":3,"tests":[{"name":"b_test.go","time":2,"estimated":false},` +
+		`{"name":"c_test.go","time":1,"estimated":true}],"file":"` + filepath.Join(dir, "worker-1.txt") + `"}]}` + "\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("Stdout =\n%s\nwant\n%s", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "worker-1.txt"))
+	if err != nil || string(data) != "b_test.go\nc_test.go\n" {
+		t.Errorf("Worker file = %q (%v), want the tests of worker 1", data, err)
+	}
+}
+
+func TestSplitCommand_FormatJSON(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.NoPercentiles = true
+	opts.InlineTimes = true
+	opts.StatsFiles = []string{"../testdata/junit/example1.xml"}
+	opts.Format = "json"
+	opts.Debug = true
+
+	var stdout, stderr bytes.Buffer
+	input := strings.NewReader("pkg/api/handler_test.go\nslow_test.go 30\nnew_test.go\n")
+	if err := cmd.RunSplit(t.Context(), opts, input, &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	var group worker.Worker
+	if err := json.Unmarshal(stdout.Bytes(), &group); err != nil {
+		t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+	}
+	sources := make(map[string]junit.Source)
+	for _, test := range group.Tests {
+		sources[test.Name] = test.Source
+	}
+	want := map[string]junit.Source{
+		"slow_test.go":            junit.SourceInline,
+		"pkg/api/handler_test.go": junit.SourceStats,
+		"new_test.go":             junit.SourceDefault,
+	}
+	if !maps.Equal(sources, want) {
+		t.Errorf("Sources = %v, want %v", sources, want)
+	}
+	if !strings.Contains(stderr.String(), "Assigned test") {
+		t.Errorf("Expected per-test debug lines, got:\n%s", stderr.String())
+	}
+
+	opts.Format = "yaml"
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Invalid format: exit code %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+}
+
+func TestSplitCommand_OutputFormatJSON(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 1, 2
+	opts.InlineTimes = true
+	input := "a_test.go 5\nb_test.go 3\nnew_test.go\n"
+
+	var text, textStderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &text, &textStderr); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if got, want := text.String(), "b_test.go\nnew_test.go\n"; got != want {
+		t.Errorf("Text output = %q, want %q", got, want)
+	}
+
+	opts.OutputFormat = "json"
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	want := `{"index":1,"total":2,"predicted_seconds":4,"tests":[` +
+		`{"name":"b_test.go","time":3,"estimated":false},{"name":"new_test.go","time":1,"estimated":true}]}` + "\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("Output = %s, want %s", got, want)
+	}
+	if stderr.String() != textStderr.String() {
+		t.Errorf("Summary differs from the text format:\n%s\nwant\n%s", stderr.String(), textStderr.String())
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*cmd.SplitOptions)
+	}{
+		{"invalid", func(o *cmd.SplitOptions) { o.OutputFormat = "yaml" }},
+		{"with --format json", func(o *cmd.SplitOptions) { o.Format = "json" }},
+	} {
+		o := opts
+		tt.modify(&o)
+		err := cmd.RunSplit(t.Context(), o, strings.NewReader(input), io.Discard, io.Discard)
+		if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+			t.Errorf("%s: exit code %d (%v), want %d", tt.name, code, err, cmd.ExitUsage)
+		}
+	}
+}
+
+func TestSplitCommand_Percentiles(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 2
+	opts.InlineTimes = true
+	opts.Percentiles = []int{95}
+	opts.Manifest = filepath.Join(t.TempDir(), "plan.json")
+
+	var stderr bytes.Buffer
+	input := strings.NewReader("a_test.go 1\nb_test.go 2\nc_test.go 3\nd_test.go 4\ne_test.go 5\n")
+	if err := cmd.RunSplit(t.Context(), opts, input, io.Discard, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Suite P95  = 4.800s") || strings.Contains(stderr.String(), "P50") {
+		t.Errorf("Expected only the suite and worker P95, got:\n%s", stderr.String())
+	}
+	data, err := os.ReadFile(opts.Manifest)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if !strings.Contains(string(data), `"suite_percentiles"`) {
+		t.Errorf("Expected suite percentiles in the manifest, got %s", data)
+	}
+
+	stderr.Reset()
+	opts.NoPercentiles = true
+	if err = cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, &stderr); err != nil {
+		t.Fatalf("RunSplit with --no-percentiles failed: %v", err)
+	}
+	if strings.Contains(stderr.String(), "Suite") {
+		t.Errorf("Expected no percentiles with --no-percentiles, got:\n%s", stderr.String())
+	}
+
+	opts.Percentiles = []int{101}
+	err = cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Invalid percentile: exit code %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+}
+
+func TestSplitCommand_PercentilesByCases(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.xml")
+	content := `<testsuites>
+  <testsuite name="slow" file="slow_test.go" time="10"><testcase name="TestSlow" time="10"/></testsuite>
+  <testsuite name="fast" file="fast_test.go" time="1">` +
+		strings.Repeat(`<testcase name="TestFast"/>`, 9) + `</testsuite>
+</testsuites>`
+	if err := os.WriteFile(report, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.StatsFiles = []string{report}
+	opts.Percentiles = []int{50}
+	input := "slow_test.go\nfast_test.go\n"
+
+	var stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "P50  = 5.500s") || strings.Contains(stderr.String(), "weighted") {
+		t.Errorf("Expected the unweighted P50 of the two files, got:\n%s", stderr.String())
+	}
+
+	// Nine of the ten test cases take the fast file's time
+	stderr.Reset()
+	opts.PercentilesByCases = true
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	for _, want := range []string{"Counted the test cases of 2 of 2 tests", "P50  = 1.000s (weighted by test cases)"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %q, got:\n%s", want, stderr.String())
+		}
+	}
+}
+
+func TestSplitCommand_InputCmd(t *testing.T) {
+	var ran []string
+	run := func(_ context.Context, name string, args ...string) (inputcmd.Output, error) {
+		ran = append([]string{name}, args...)
+		if name == "broken" {
+			return inputcmd.Output{Stderr: []byte("go: no packages to test\n")}, errors.New("exit status 1")
+		}
+		return inputcmd.Output{Stdout: []byte("a_test.go\nb_test.go\n"), Stderr: []byte("scanning\n")}, nil
+	}
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.InputCmd = "go list ./..."
+
+	var stdout bytes.Buffer
+	stdin := strings.NewReader("ignored_test.go\n")
+	err := cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, stdin, &stdout, nil, run, nil, nil)
+	if err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if got := stdout.String(); got != "a_test.go\nb_test.go\n" {
+		t.Errorf("Output = %q, want the tests listed by the command", got)
+	}
+	if want := inputcmd.Shell("go list ./...").String(); strings.Join(ran, " ") != want {
+		t.Errorf("Ran %q, want %q", ran, want)
+	}
+
+	// Exec-style, and a failing command fails the split with its stderr in the log
+	opts.InputCmd, opts.InputCmdArgs = "broken", []string{"--flag", "two words"}
+	var logs bytes.Buffer
+	err = cmd.RunSplitWith(t.Context(), zerolog.New(&logs), &opts, strings.NewReader(""), io.Discard,
+		nil, run, nil, nil)
+	if code := cmd.ExitCode(err); code != cmd.ExitInput || !errors.Is(err, inputcmd.ErrFailed) {
+		t.Errorf("Failing command: exit code %d (%v), want %d", code, err, cmd.ExitInput)
+	}
+	if want := []string{"broken", "--flag", "two words"}; !slices.Equal(ran, want) {
+		t.Errorf("Ran %q, want %q", ran, want)
+	}
+	if !strings.Contains(logs.String(), `"stderr":"go: no packages to test"`) {
+		t.Errorf("Expected the command's stderr in the log, got %s", logs.String())
+	}
+
+	opts.InputFile = "tests.txt"
+	err = cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(""), io.Discard, nil, run, nil, nil)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("--input with --input-cmd: exit code %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+}
+
+func TestSplitCommand_Exec(t *testing.T) {
+	var ran []inputcmd.Command
+	code := 0
+	run := func(_ context.Context, c inputcmd.Command, stdout io.Writer) (int, error) {
+		ran = append(ran, c)
+		_, _ = io.WriteString(stdout, "PASS\n")
+		return code, nil
+	}
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 1, 2
+	opts.InlineTimes = true
+	opts.ExecTemplate = `go test {{join .Tests " "}} # {{.Index}}/{{.Total}}`
+	input := "./a 30\n./b 20\n./c 10\n"
+
+	// --print-exec writes the command line instead of the tests
+	opts.PrintExec = true
+	var stdout bytes.Buffer
+	err := cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(input), &stdout, nil, nil, run, nil)
+	if err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if got, want := stdout.String(), "go test ./b ./c # 1/2\n"; got != want || len(ran) != 0 {
+		t.Errorf("Output = %q with %d commands run, want %q and none", got, len(ran), want)
+	}
+
+	// --exec streams the output of the command instead
+	opts.PrintExec, opts.Exec = false, true
+	stdout.Reset()
+	err = cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(input), &stdout, nil, nil, run, nil)
+	if err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	want := inputcmd.Shell("go test ./b ./c # 1/2")
+	if len(ran) != 1 || ran[0].String() != want.String() || stdout.String() != "PASS\n" {
+		t.Errorf("Ran %q with output %q, want %q with its output", ran, stdout.String(), want)
+	}
+
+	// A failing command sets the exit code
+	code = 42
+	err = cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(input), io.Discard, nil, nil, run, nil)
+	if got := cmd.ExitCode(err); got != 42 {
+		t.Errorf("Failing command: exit code %d (%v), want 42", got, err)
+	}
+
+	// A worker without tests runs nothing
+	ran = nil
+	opts.Index, opts.Total = 3, 4
+	err = cmd.RunSplitWith(t.Context(), zerolog.Nop(), &opts, strings.NewReader(input), io.Discard, nil, nil, run, nil)
+	if err != nil || len(ran) != 0 {
+		t.Errorf("Empty worker: ran %q (%v), want nothing", ran, err)
+	}
+}
+
+func TestSplitCommand_ExecFlags(t *testing.T) {
+	for name, set := range map[string]func(*cmd.SplitOptions){
+		"template alone":    func(o *cmd.SplitOptions) { o.ExecTemplate = "go test" },
+		"exec alone":        func(o *cmd.SplitOptions) { o.Exec = true },
+		"exec and print":    func(o *cmd.SplitOptions) { o.ExecTemplate, o.Exec, o.PrintExec = "go test", true, true },
+		"json":              func(o *cmd.SplitOptions) { o.ExecTemplate, o.Exec, o.Format = "go test", true, "json" },
+		"invalid template":  func(o *cmd.SplitOptions) { o.ExecTemplate, o.PrintExec = "go test {{", true },
+		"unknown field":     func(o *cmd.SplitOptions) { o.ExecTemplate, o.PrintExec = "go test {{.Files}}", true },
+		"with --output-dir": func(o *cmd.SplitOptions) { o.ExecTemplate, o.Exec, o.OutputDir = "go test", true, "out" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := cmd.DefaultSplitOptions()
+			opts.Index, opts.Total = 0, 1
+			set(&opts)
+			err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+			if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+				t.Errorf("Exit code %d (%v), want %d", code, err, cmd.ExitUsage)
+			}
+		})
+	}
+}
+
+func TestSplitCommand_ControlChars(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	input := "a_test.go\n\x1b[31mb_test.go\x1b[0m\n"
+
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitInput || !errors.Is(err, splitter.ErrControlChars) {
+		t.Errorf("Escape sequence: exit code %d (%v), want %d", code, err, cmd.ExitInput)
+	}
+
+	opts.ControlChars = "strip"
+	var stdout bytes.Buffer
+	if err = cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if got := stdout.String(); got != "a_test.go\nb_test.go\n" {
+		t.Errorf("Output = %q, want the names without escape sequences", got)
+	}
+
+	opts.MaxNameBytes = 8
+	err = cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitInput || !errors.Is(err, splitter.ErrNameTooLong) {
+		t.Errorf("Overlong name: exit code %d (%v), want %d", code, err, cmd.ExitInput)
+	}
+
+	opts.ControlChars = "escape"
+	err = cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Unknown mode: exit code %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+}
+
+func TestSplitCommand_Budget(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 1, 2
+	opts.InlineTimes = true
+	opts.Budget = 10
+	opts.DeferredOutput = filepath.Join(t.TempDir(), "deferred.txt")
+	// Worker 0 gets the huge test alone, worker 1 the five others (15s)
+	input := "huge_test.go 100\na_test.go 5\nb_test.go 4\nc_test.go 3\nd_test.go 2\ne_test.go 1\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	if got := stdout.String(); got != "a_test.go\nb_test.go\n" {
+		t.Errorf("Kept = %q, want the most expensive tests within 10s", got)
+	}
+	data, err := os.ReadFile(opts.DeferredOutput)
+	if err != nil {
+		t.Fatalf("Failed to read deferred tests: %v", err)
+	}
+	if got := string(data); got != "c_test.go 3\nd_test.go 2\ne_test.go 1\n" {
+		t.Errorf("Deferred = %q", got)
+	}
+	for _, want := range []string{
+		"Worker 1: kept 9.000s (2 test files), deferred 6.000s (3 test files), budget 10.000s",
+		"Worker 0: kept 100.000s (1 test files), deferred 0.000s (0 test files)",
+		"Worker 0 stays over the budget",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %q in the logs, got:\n%s", want, stderr.String())
+		}
+	}
+
+	// Entirely under budget: nothing is deferred
+	opts.Budget = 1000
+	stdout.Reset()
+	if err = cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit under budget failed: %v", err)
+	}
+	if data, _ = os.ReadFile(opts.DeferredOutput); len(data) != 0 || strings.Count(stdout.String(), "\n") != 5 {
+		t.Errorf("Under budget: kept %q, deferred %q; want every test kept", stdout.String(), data)
+	}
+
+	opts.Budget = 0
+	err = cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("--deferred-output without --budget: exit code %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+
+	for _, invalid := range []float64{-1, math.NaN(), math.Inf(1)} {
+		opts.Budget = invalid
+		err = cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+		if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+			t.Errorf("--budget %g: exit code %d (%v), want %d", invalid, code, err, cmd.ExitUsage)
+		}
+	}
+}
+
+// gitRepo is a git repository in a temporary directory, which is also made the working directory.
+type gitRepo struct {
+	dir string
+}
+
+// newGitRepo initializes a repository on branch main, isolated from the user's git configuration.
+func newGitRepo(t *testing.T) gitRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repo := gitRepo{dir: t.TempDir()}
+	t.Chdir(repo.dir)
+	repo.git(t, "init", "-q", "-b", "main")
+	return repo
+}
+
+// git runs git in the repository and fails the test on an error.
+func (r gitRepo) git(t *testing.T, args ...string) {
+	t.Helper()
+	git := exec.CommandContext(t.Context(), "git", args...)
+	git.Dir = r.dir
+	if out, err := git.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+// commit writes files, relative to the repository, and commits them.
+func (r gitRepo) commit(t *testing.T, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	r.git(t, "add", "-A")
+	r.git(t, "commit", "-q", "-m", "change")
+}
+
+func TestSplitCommand_FollowRenames(t *testing.T) {
+	repo := newGitRepo(t)
+	repo.commit(t, map[string]string{"old/a_test.go": "package old\n", "b_test.go": "package b\n"})
+	repo.git(t, "mv", "old", "new")
+	repo.git(t, "commit", "-q", "-m", "rename")
+
+	stats := filepath.Join(t.TempDir(), "times.json")
+	if err := os.WriteFile(stats, []byte(`{"old/a_test.go": 9, "b_test.go": 3}`), 0o600); err != nil {
+		t.Fatalf("Failed to write stats: %v", err)
+	}
+	split := func(opts *cmd.SplitOptions, git changes.Runner) (float64, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		input := strings.NewReader("new/a_test.go\nb_test.go\n")
+		err := cmd.RunSplitWith(t.Context(), zerolog.New(&stderr), opts, input, &stdout, nil, nil, nil, git)
+		if err != nil {
+			t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+		}
+		var got struct {
+			Total float64 `json:"total"`
+		}
+		if err = json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+		}
+		return got.Total, stderr.String()
+	}
+
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.Format = "json"
+	opts.StatsFiles = []string{stats}
+	if total, _ := split(&opts, changes.Git); total != 4 {
+		t.Errorf("Without --follow-renames: total = %g, want 4 (the renamed file at the default time)", total)
+	}
+
+	opts.FollowRenames = true
+	for _, since := range []string{"", "HEAD~1"} {
+		opts.RenamesSince = since
+		total, logs := split(&opts, changes.Git)
+		if total != 12 {
+			t.Errorf("--follow-renames-since %q: total = %g, want 12", since, total)
+		}
+		if !strings.Contains(logs, "Moved the times of 1 renamed test files") {
+			t.Errorf("--follow-renames-since %q: logs lack the moved times:\n%s", since, logs)
+		}
+	}
+
+	// Outside a repository the stats are used as recorded
+	failing := func(context.Context, ...string) ([]byte, error) { return nil, errors.New("not a git repository") }
+	total, logs := split(&opts, failing)
+	if total != 4 || !strings.Contains(logs, "Cannot list renamed files") {
+		t.Errorf("Failing git: total = %g, want 4 and a warning:\n%s", total, logs)
+	}
+}
+
+func writeTestList(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tests.txt")
+	if err := os.WriteFile(path, []byte("a_test.go\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write test list: %v", err)
+	}
+	return path
+}
+
+func TestSplitCommand_IndexFromHash(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Total = 8
+	opts.InlineTimes = true
+	opts.IndexFromHash = "runner-a"
+	input := "a_test.go 8\nb_test.go 7\nc_test.go 6\nd_test.go 5\ne_test.go 4\nf_test.go 3\ng_test.go 2\nh_test.go 1\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	// runner-a hashes to index 1, the second longest test
+	if got := stdout.String(); got != "b_test.go\n" {
+		t.Errorf("Output = %q, want the tests of worker 1", got)
+	}
+	if !strings.Contains(stderr.String(), "Derived worker index from hash") {
+		t.Errorf("Expected the derived index in the logs, got:\n%s", stderr.String())
+	}
+
+	// Claiming starts at the hashed index and moves on when it is taken
+	opts.ClaimDir = t.TempDir()
+	stdout.Reset()
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit claiming failed: %v", err)
+	}
+	opts.IndexFromHash = "build-pod-7f9c" // also hashes to 1
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit claiming a taken index failed: %v", err)
+	}
+	if got := stdout.String(); got != "b_test.go\nc_test.go\n" {
+		t.Errorf("Output = %q, want worker 1 then worker 2", got)
+	}
+
+	opts.Index = 3
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("--index with --index-from-hash: exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+	}
+}
+
+func TestSplitCommand_OneBased(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 2, 3
+	opts.InlineTimes = true
+	opts.OneBased = true
+	input := "a_test.go 3\nb_test.go 2\nc_test.go 1\n"
+
+	var stdout bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if got := stdout.String(); got != "b_test.go\n" {
+		t.Errorf("Output = %q, want the tests of the second worker", got)
+	}
+
+	for _, index := range []int{0, 4} {
+		opts.Index = index
+		err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+		if code := cmd.ExitCode(err); code != cmd.ExitUsage || !strings.Contains(err.Error(), "counting from 1") {
+			t.Errorf("--index %d: exit code = %d (%v), want %d counting from 1", index, code, err, cmd.ExitUsage)
+		}
+	}
+}
+
+func TestSplitCommand_NodeSourceLogged(t *testing.T) {
+	clearCI(t)
+	t.Setenv("CIRCLE_NODE_INDEX", "1")
+	t.Setenv("BUILDKITE_PARALLEL_JOB", "0")
+	t.Setenv("BUILDKITE_PARALLEL_JOB_COUNT", "2")
+	opts := cmd.DefaultSplitOptions()
+	opts.InlineTimes = true
+	opts.Debug = true
+
+	var stdout, stderr bytes.Buffer
+	input := "a_test.go 2\nb_test.go 1\n"
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	// CircleCI takes precedence over Buildkite for the index, the only variable it sets
+	if got := stdout.String(); got != "b_test.go\n" {
+		t.Errorf("Output = %q, want the tests of worker 1", got)
+	}
+	for _, want := range []string{
+		"Resolved worker index and total", "CIRCLE_NODE_INDEX", "BUILDKITE_PARALLEL_JOB_COUNT",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %s in the logs, got:\n%s", want, stderr.String())
+		}
+	}
+}
+
+func TestSplitCommand_CI(t *testing.T) {
+	clearCI(t)
+	t.Setenv("CIRCLECI", "true")
+	t.Setenv("CIRCLE_NODE_INDEX", "1")
+	t.Setenv("CIRCLE_NODE_TOTAL", "2")
+	opts := cmd.DefaultSplitOptions()
+	opts.InlineTimes = true
+	input := "a_test.go 2\nb_test.go 1\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	if got := stdout.String(); got != "b_test.go\n" {
+		t.Errorf("Output = %q, want the tests of worker 1", got)
+	}
+	if !strings.Contains(stderr.String(), "circleci") {
+		t.Errorf("Expected the detected provider in the logs, got:\n%s", stderr.String())
+	}
+
+	// --ci none ignores the CircleCI variables: a single worker gets every test
+	stdout.Reset()
+	opts.CI = "none"
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	if got := stdout.String(); got != "a_test.go\nb_test.go\n" {
+		t.Errorf("Output with --ci none = %q, want every test", got)
+	}
+
+	opts.CI = "travis"
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("--ci travis: exit code = %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+}
+
+func TestSplitCommand_Replay(t *testing.T) {
+	dir := t.TempDir()
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 1, 3
+	opts.InlineTimes = true
+	opts.Manifest = filepath.Join(dir, "plan.json")
+	input := "a_test.go 9\nb_test.go 6\nc_test.go 4\nd_test.go 3\ne_test.go 2\n"
+
+	var want bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &want, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+
+	// Different timings and no input: the manifest alone decides
+	replay := cmd.DefaultSplitOptions()
+	replay.Index = 1
+	replay.Replay = opts.Manifest
+	var stdout, stderr bytes.Buffer
+	err := cmd.RunSplit(t.Context(), replay, strings.NewReader("a_test.go 1\n"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("RunSplit --replay failed: %v\n%s", err, stderr.String())
+	}
+	if stdout.String() != want.String() {
+		t.Errorf("Replayed = %q, want %q", stdout.String(), want.String())
+	}
+	if !strings.Contains(stderr.String(), "Replaying split from manifest") {
+		t.Errorf("Expected the replay in the logs, got:\n%s", stderr.String())
+	}
+
+	replay.Total = 3
+	if err = cmd.RunSplit(t.Context(), replay, strings.NewReader(""), io.Discard, io.Discard); err != nil {
+		t.Errorf("RunSplit --replay with a matching --total failed: %v", err)
+	}
+
+	future, missing := filepath.Join(dir, "future.json"), filepath.Join(dir, "none.json")
+	if err = os.WriteFile(future, []byte(`{"version": 99, "groups": [{"total": 0}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		modify   func(o *cmd.SplitOptions)
+		wantCode int
+		wantErr  string
+	}{
+		{"total mismatch", func(o *cmd.SplitOptions) { o.Total = 4 }, cmd.ExitUsage, "the manifest has 3 workers"},
+		{"index out of range", func(o *cmd.SplitOptions) { o.Index = 3 }, cmd.ExitUsage, "invalid worker index"},
+		{"unsupported version", func(o *cmd.SplitOptions) { o.Replay = future }, cmd.ExitInput, "version 99"},
+		{"missing manifest", func(o *cmd.SplitOptions) { o.Replay = missing }, cmd.ExitInput, ""},
+		{"with budget", func(o *cmd.SplitOptions) { o.Budget = 5 }, cmd.ExitUsage, "--replay cannot be combined"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o := replay
+			o.Total = cmd.DefaultSplitOptions().Total
+			tt.modify(&o)
+			err := cmd.RunSplit(t.Context(), o, strings.NewReader(""), io.Discard, io.Discard)
+			if code := cmd.ExitCode(err); code != tt.wantCode {
+				t.Errorf("Exit code = %d, want %d (err: %v)", code, tt.wantCode, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplitCommand_ReplayVersions(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 2
+	opts.InlineTimes = true
+	opts.Version = "1.0.0"
+	opts.Manifest = filepath.Join(t.TempDir(), "plan.json")
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go 3\n"), io.Discard, io.Discard); err != nil {
+		t.Fatalf("RunSplit failed: %v", err)
+	}
+	data, err := os.ReadFile(opts.Manifest)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if !strings.Contains(string(data), `"tool_version": "1.0.0"`) {
+		t.Errorf("Manifest is not stamped with the tool version:\n%s", data)
+	}
+
+	for _, tt := range []struct {
+		manifest string
+		wantCode int
+		wantWarn bool
+		wantErr  string
+	}{
+		{manifest: opts.Manifest, wantCode: cmd.ExitOK},
+		{manifest: "../testdata/manifest/unversioned.json", wantCode: cmd.ExitOK},
+		{manifest: "../testdata/manifest/older-tool.json", wantCode: cmd.ExitOK, wantWarn: true},
+		{
+			manifest: "../testdata/manifest/future-schema.json",
+			wantCode: cmd.ExitInput,
+			wantErr:  "version 2 written by tests-helper 9.0.0 (supported: 1)",
+		},
+	} {
+		t.Run(filepath.Base(tt.manifest), func(t *testing.T) {
+			replay := cmd.DefaultSplitOptions()
+			replay.Index = 0
+			replay.Version = "1.0.0"
+			replay.Replay = tt.manifest
+
+			var stderr bytes.Buffer
+			err := cmd.RunSplit(t.Context(), replay, strings.NewReader(""), io.Discard, &stderr)
+			if code := cmd.ExitCode(err); code != tt.wantCode {
+				t.Errorf("Exit code = %d, want %d (err: %v)", code, tt.wantCode, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			warned := strings.Contains(stderr.String(), "Manifest was written by another version of tests-helper")
+			if warned != tt.wantWarn {
+				t.Errorf("Warned = %t, want %t:\n%s", warned, tt.wantWarn, stderr.String())
+			}
+			named := strings.Contains(stderr.String(), "0.9.0") && strings.Contains(stderr.String(), "1.0.0")
+			if tt.wantWarn && !named {
+				t.Errorf("The warning should name both versions:\n%s", stderr.String())
+			}
+		})
+	}
+}
+
+func TestSplitCommand_GranularitySuite(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 1, 2
+	opts.StatsFiles = []string{"../testdata/junit/sbt"}
+	opts.Granularity = "suite"
+	input := "com.example.billing.InvoiceSpec\ncom.example.UserServiceSpec\ncom.example.util.StringUtilsSpec\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	// InvoiceSpec (30.25s) fills worker 0 on its own
+	if got := stdout.String(); got != "com.example.UserServiceSpec\ncom.example.util.StringUtilsSpec\n" {
+		t.Errorf("Output = %q, want the two shorter suites", got)
+	}
+
+	opts.Granularity = "testcase"
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Unknown granularity: exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+	}
+}
+
+func TestSplitCommand_StatsKey(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 2
+	opts.Format = "json"
+	opts.StatsFiles = []string{"../testdata/junit/classname/pytest.xml"}
+	opts.StatsKey = "classname"
+	input := "tests.test_users\ntests.api.test_orders\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	var got struct {
+		Tests []struct {
+			Name   string  `json:"name"`
+			Time   float64 `json:"time"`
+			Source string  `json:"source"`
+		} `json:"tests"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+	}
+	if len(got.Tests) != 1 || got.Tests[0].Name != "tests.api.test_orders" || got.Tests[0].Time != 4.1 ||
+		got.Tests[0].Source != "stats" {
+		t.Errorf("Worker 0 = %+v, want tests.api.test_orders from the stats", got.Tests)
+	}
+
+	opts.StatsKey = "name"
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Unknown stats key: exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+	}
+}
+
+func TestSplitCommand_StatsMerge(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.Format = "json"
+	opts.StatsFiles = []string{"../testdata/junit/example1.xml", "../testdata/junit/example2.xml"}
+	opts.StatsMerge = "avg"
+
+	var stdout, stderr bytes.Buffer
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader("pkg/service/auth_test.go\n"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	var got struct {
+		Total float64 `json:"total"`
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+	}
+	// 5.234s and 2.1s in the two reports
+	if math.Abs(got.Total-3.667) > 1e-9 {
+		t.Errorf("Total = %g, want the average of both reports, 3.667", got.Total)
+	}
+
+	opts.StatsMerge = "median"
+	err = cmd.RunSplit(t.Context(), opts, strings.NewReader("pkg/service/auth_test.go\n"), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Unknown merge strategy: exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+	}
+}
+
+func TestSplitCommand_StripPrefix(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.xml")
+	content := `<testsuites>
+  <testsuite file="./foo_test.go" time="1"/>
+  <testsuite file=".\pkg\win_test.go" time="2"/>
+  <testsuite file="/home/ci/project/pkg/abs_test.go" time="4"/>
+</testsuites>`
+	if err := os.WriteFile(report, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to create report: %v", err)
+	}
+
+	for _, tt := range []struct {
+		prefixes []string
+		want     float64
+	}{
+		// abs_test.go falls back to the default 1s
+		{prefixes: nil, want: 4},
+		{prefixes: []string{"/home/ci/project/"}, want: 7},
+	} {
+		opts := cmd.DefaultSplitOptions()
+		opts.Index, opts.Total = 0, 1
+		opts.Format = "json"
+		opts.StatsFiles = []string{report}
+		opts.StripPrefixes = tt.prefixes
+
+		var stdout, stderr bytes.Buffer
+		input := strings.NewReader("foo_test.go\npkg/win_test.go\npkg/abs_test.go\n")
+		if err := cmd.RunSplit(t.Context(), opts, input, &stdout, &stderr); err != nil {
+			t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+		}
+		var got struct {
+			Total float64 `json:"total"`
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+		}
+		if got.Total != tt.want {
+			t.Errorf("--strip-prefix %v: total = %g, want %g", tt.prefixes, got.Total, tt.want)
+		}
+	}
+}
+
+func TestSplitCommand_DefaultTime(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.xml")
+	content := `<testsuites><testsuite file="zero_test.go" time="0"/>` +
+		`<testsuite file="b_test.go" time="2"/></testsuites>`
+	if err := os.WriteFile(report, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to create report: %v", err)
+	}
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.Format = "json"
+	opts.StatsFiles = []string{report}
+	opts.DefaultTime = 5
+	opts.ZeroTime = 0
+
+	var stdout, stderr bytes.Buffer
+	input := strings.NewReader("zero_test.go\nb_test.go\nnew_test.go\n")
+	if err := cmd.RunSplit(t.Context(), opts, input, &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	var got struct {
+		Tests []struct {
+			Name   string  `json:"name"`
+			Source string  `json:"source"`
+			Time   float64 `json:"time"`
+		} `json:"tests"`
+		Total float64 `json:"total"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+	}
+	// The test recorded as zero seconds weighs nothing, the one without stats the default
+	if got.Total != 7 {
+		t.Errorf("Total = %g, want 7", got.Total)
+	}
+	for _, test := range got.Tests {
+		if test.Name == "new_test.go" && (test.Time != 5 || test.Source != "default") {
+			t.Errorf("new_test.go = %gs from %s, want 5s from default", test.Time, test.Source)
+		}
+		if test.Name == "zero_test.go" && (test.Time != 0 || test.Source != "clamped") {
+			t.Errorf("zero_test.go = %gs from %s, want 0s from clamped", test.Time, test.Source)
+		}
+	}
+
+	for _, invalid := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		opts.DefaultTime = invalid
+		err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+		if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+			t.Errorf("--default-time %g: exit code = %d, want %d (err: %v)", invalid, code, cmd.ExitUsage, err)
+		}
+	}
+	opts.DefaultTime = 5
+	for _, invalid := range []float64{-100, math.NaN(), math.Inf(1)} {
+		opts.ZeroTime = invalid
+		err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+		if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+			t.Errorf("--zero-time %g: exit code = %d, want %d (err: %v)", invalid, code, cmd.ExitUsage, err)
+		}
+	}
+}
+
+func TestSplitCommand_IgnoreSkipped(t *testing.T) {
+	for _, tt := range []struct {
+		ignore bool
+		want   float64
+	}{
+		{ignore: true, want: 2.5},
+		{ignore: false, want: 4},
+	} {
+		opts := cmd.DefaultSplitOptions()
+		opts.Index, opts.Total = 0, 1
+		opts.Format = "json"
+		opts.StatsFiles = []string{"../testdata/junit/skipped.xml"}
+		opts.IgnoreSkipped = tt.ignore
+
+		var stdout, stderr bytes.Buffer
+		err := cmd.RunSplit(t.Context(), opts, strings.NewReader("pkg/partial/partial_test.go\n"), &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+		}
+		var got struct {
+			Total float64 `json:"total"`
+		}
+		if err = json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+		}
+		if got.Total != tt.want {
+			t.Errorf("--stats-ignore-skipped=%t: total = %g, want %g", tt.ignore, got.Total, tt.want)
+		}
+	}
+}
+
+func TestSplitCommand_StatsMergePriority(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]string{
+		"times-feature.json": `{"a_test.go": 1}`,
+		"times-main.json":    `{"a_test.go": 10, "b_test.go": 20}`,
+	}
+	for name, content := range stores {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to create timing store: %v", err)
+		}
+	}
+	feature, mainBranch := filepath.Join(dir, "times-feature.json"), filepath.Join(dir, "times-main.json")
+
+	for _, tt := range []struct {
+		stats []string
+		want  float64
+	}{
+		{stats: []string{feature, mainBranch}, want: 21},
+		{stats: []string{mainBranch, feature}, want: 30},
+	} {
+		opts := cmd.DefaultSplitOptions()
+		opts.Index, opts.Total = 0, 1
+		opts.Format = "json"
+		opts.StatsFiles = tt.stats
+		opts.StatsMerge = "priority"
+
+		var stdout, stderr bytes.Buffer
+		err := cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\nb_test.go\n"), &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+		}
+		var got struct {
+			Total float64 `json:"total"`
+		}
+		if err = json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+		}
+		if got.Total != tt.want {
+			t.Errorf("%v: total = %g, want %g", tt.stats, got.Total, tt.want)
+		}
+		if !strings.Contains(stderr.String(), "Keys taken from time source") {
+			t.Errorf("%v: the keys taken from each source should be logged, got:\n%s", tt.stats, stderr.String())
+		}
+	}
+}
+
+func TestSplitCommand_DurationFormat(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 2
+	opts.InlineTimes = true
+	opts.DurationFormat = "human"
+	input := "slow_test.go 1873.421\nfast_test.go 0.25\n"
+
+	var stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	for _, want := range []string{"Worker 0: 31m13s (1 test files", "Worker 1: 250ms (1 test files"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %q in the logs, got:\n%s", want, stderr.String())
+		}
+	}
+
+	opts.DurationFormat = "minutes"
+	err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Unknown duration format: exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+	}
+}
+
+func TestSplitCommand_Resources(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 1, 2
+	opts.InlineTimes = true
+	opts.Format = "json"
+	opts.Resources = []string{"device/**=gpu"}
+	opts.ResourceLimits = []string{"gpu=1"}
+	// Unlimited, worker 1 would take both device tests
+	input := "a_test.go 10\ndevice/x_test.go 5\ndevice/y_test.go 4\nb_test.go 1\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	var got struct {
+		Tests []struct {
+			Name     string `json:"name"`
+			Resource string `json:"resource"`
+		} `json:"tests"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+	}
+	if len(got.Tests) != 2 || got.Tests[0].Name != "device/x_test.go" || got.Tests[0].Resource != "gpu" ||
+		got.Tests[1].Resource != "" {
+		t.Errorf("Worker 1 = %+v, want device/x_test.go tagged gpu, then b_test.go", got.Tests)
+	}
+	for _, want := range []string{"Tagged tests with a resource", "Worker 1 resources: gpu=1/1"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %q in the logs, got:\n%s", want, stderr.String())
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(o *cmd.SplitOptions)
+	}{
+		{"impossible", func(o *cmd.SplitOptions) { o.Total, o.Index = 1, 0 }},
+		{"invalid rule", func(o *cmd.SplitOptions) { o.Resources = []string{"device/**"} }},
+		{"invalid limit", func(o *cmd.SplitOptions) { o.ResourceLimits = []string{"gpu=-1"} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o := opts
+			tt.modify(&o)
+			err := cmd.RunSplit(t.Context(), o, strings.NewReader(input), io.Discard, io.Discard)
+			if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+				t.Errorf("Exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+			}
+		})
+	}
+}
+
+func TestSplitCommand_Tags(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 2
+	opts.InlineTimes = true
+	opts.Metadata = "../testdata/metadata/tags.json"
+	opts.ExcludeTags = []string{"slow"}
+	opts.Weights = []string{"tag:integration=4", "com.acme.util.*=0.5"}
+	// Weighted, IndexIT takes 12s and StringsTest 1s: worker 0 gets IndexIT alone
+	input := "com.acme.billing.InvoiceTest 4\ncom.acme.billing.InvoiceIT 20\ncom.acme.search.IndexIT 3\n" +
+		"com.acme.search.QueryTest 5\ncom.acme.legacy.ReportTest 9\ncom.acme.util.StringsTest 2\n"
+
+	var stdout, stderr bytes.Buffer
+	if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	if got := stdout.String(); got != "com.acme.search.IndexIT\n" {
+		t.Errorf("Worker 0 = %q, want com.acme.search.IndexIT", got)
+	}
+	for _, want := range []string{"Kept 4 of 6 tests by their tags", "Weighted the times of 2 tests"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected %q in the logs, got:\n%s", want, stderr.String())
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(o *cmd.SplitOptions)
+		code   int
+	}{
+		{"tags without metadata", func(o *cmd.SplitOptions) { o.Metadata, o.Weights = "", nil }, cmd.ExitUsage},
+		{"tag weight without metadata", func(o *cmd.SplitOptions) { o.Metadata, o.ExcludeTags = "", nil },
+			cmd.ExitUsage},
+		{"invalid weight", func(o *cmd.SplitOptions) { o.Weights = []string{"tag:slow"} }, cmd.ExitUsage},
+		{"missing metadata", func(o *cmd.SplitOptions) { o.Metadata = "missing.json" }, cmd.ExitInput},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o := opts
+			tt.modify(&o)
+			err := cmd.RunSplit(t.Context(), o, strings.NewReader(input), io.Discard, io.Discard)
+			if code := cmd.ExitCode(err); code != tt.code {
+				t.Errorf("Exit code = %d, want %d (err: %v)", code, tt.code, err)
+			}
+		})
+	}
+}
+
+func TestSplitCommand_Algorithm(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Total = 3
+	opts.InlineTimes = true
+	opts.Format = "json"
+	opts.InputFile = "../testdata/testlists/lpt-worst-case.txt"
+
+	busiest := func(t *testing.T, algorithm string) float64 {
+		t.Helper()
+		most := 0.0
+		for index := range opts.Total {
+			o := opts
+			o.Index, o.Algorithm = index, algorithm
+			var stdout, stderr bytes.Buffer
+			if err := cmd.RunSplit(t.Context(), o, strings.NewReader(""), &stdout, &stderr); err != nil {
+				t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+			}
+			var got struct {
+				Total float64 `json:"total"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+				t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+			}
+			most = max(most, got.Total)
+		}
+		return most
+	}
+	if greedy, kk := busiest(t, "greedy"), busiest(t, "kk"); greedy != 11 || kk != 10 {
+		t.Errorf("Busiest worker: greedy %g, kk %g, want 11 and 10", greedy, kk)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(o *cmd.SplitOptions)
+	}{
+		{"unknown", func(o *cmd.SplitOptions) { o.Algorithm = "lpt" }},
+		{"resource limits", func(o *cmd.SplitOptions) { o.Algorithm, o.ResourceLimits = "kk", []string{"db=1"} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o := opts
+			o.Index = 0
+			tt.modify(&o)
+			err := cmd.RunSplit(t.Context(), o, strings.NewReader(""), io.Discard, io.Discard)
+			if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+				t.Errorf("Exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+			}
+		})
+	}
+}
+
+func TestSplitCommand_Progress(t *testing.T) {
+	// Under go test the process stderr is not a terminal, so no progress line is drawn and the
+	// logs are unchanged
+	args := []string{"split", "--index", "0", "--total", "2", "--stats", "../testdata/junit/example1.xml",
+		"--input", writeTestList(t), "--progress"}
+
+	var stderr bytes.Buffer
+	if code := cmd.Main(args, &stderr); code != cmd.ExitOK {
+		t.Fatalf("Exit code = %d\n%s", code, stderr.String())
+	}
+	if strings.Contains(stderr.String(), "\r") {
+		t.Errorf("Progress line written to a non-terminal:\n%q", stderr.String())
+	}
+}
+
+func TestSplitCommand_SummaryRollup(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 1
+	opts.InlineTimes = true
+	opts.SummaryRollup = "dir:2"
+	opts.Manifest = filepath.Join(t.TempDir(), "plan.json")
+
+	var stderr bytes.Buffer
+	input := strings.NewReader("src/api/v1/a_test.go 3\nsrc/api/v2/b_test.go 2\nsrc/web/c_test.go 4\nd_test.go 1\n")
+	if err := cmd.RunSplit(t.Context(), opts, input, io.Discard, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	want := "Worker 0 top directories: src/api 5.000s (2 test files), src/web 4.000s"
+	if !strings.Contains(stderr.String(), want) {
+		t.Errorf("Summary lacks %q:\n%s", want, stderr.String())
+	}
+	data, err := os.ReadFile(opts.Manifest)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var m struct {
+		Distribution struct {
+			Workers []struct {
+				Directories []struct {
+					Dir   string  `json:"dir"`
+					Time  float64 `json:"time"`
+					Tests int     `json:"tests"`
+				} `json:"directories"`
+			} `json:"workers"`
+		} `json:"distribution"`
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	if dirs := m.Distribution.Workers[0].Directories; len(dirs) != 3 || dirs[0].Dir != "src/api" ||
+		dirs[0].Time != 5 || dirs[0].Tests != 2 || dirs[2].Dir != "." {
+		t.Errorf("Manifest directories = %+v, want src/api (5s, 2 tests), src/web, and .", dirs)
+	}
+
+	opts.SummaryRollup = "dir:0"
+	err = cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("--summary-rollup dir:0: exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+	}
+}
+
+func TestSplitCommand_VerboseWorker(t *testing.T) {
+	opts := cmd.DefaultSplitOptions()
+	opts.Index, opts.Total = 0, 2
+	opts.InlineTimes = true
+	opts.VerboseWorker = true
+	opts.VerboseWorkerTop = 1
+	opts.Manifest = filepath.Join(t.TempDir(), "plan.json")
+
+	var stderr bytes.Buffer
+	input := strings.NewReader("a_test.go 6\nb_test.go 5\nc_test.go 3\nd_test.go 2\n")
+	if err := cmd.RunSplit(t.Context(), opts, input, io.Discard, &stderr); err != nil {
+		t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+	}
+	for _, want := range []string{
+		"Assigned test 1. a_test.go 6.000s, cumulative 6.000s (75.0%)",
+		"Listed the 1 longest of 2 tests, 75.0% of the worker's time",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Log lacks %q:\n%s", want, stderr.String())
+		}
+	}
+	data, err := os.ReadFile(opts.Manifest)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var m struct {
+		Distribution struct {
+			Workers []struct {
+				Tests []struct {
+					Name       string  `json:"name"`
+					Cumulative float64 `json:"cumulative"`
+				} `json:"tests"`
+			} `json:"workers"`
+		} `json:"distribution"`
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	// The manifest lists every test of every worker, not only the top of the logged one
+	for i, want := range []string{"a_test.go,d_test.go", "b_test.go,c_test.go"} {
+		var names []string
+		for _, test := range m.Distribution.Workers[i].Tests {
+			names = append(names, test.Name)
+		}
+		if got := strings.Join(names, ","); got != want {
+			t.Errorf("Worker %d manifest tests = %s, want %s", i, got, want)
+		}
+	}
+	if got := m.Distribution.Workers[1].Tests[1].Cumulative; got != 8 {
+		t.Errorf("Worker 1 cumulative time = %g, want 8", got)
+	}
+
+	opts.VerboseWorkerTop = -1
+	err = cmd.RunSplit(t.Context(), opts, strings.NewReader("a_test.go\n"), io.Discard, io.Discard)
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("--verbose-worker-top -1: exit code = %d, want %d (err: %v)", code, cmd.ExitUsage, err)
+	}
+}
+
+func TestSplitCommand_StatsGoTest(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input string
+		strip []string
+		want  float64
+	}{
+		"import paths":     {"example.com/app/api\nexample.com/app/db\nexample.com/app/slow\n", nil, 48.75},
+		"relative to root": {"./api\n./db\n", []string{"example.com/app"}, 6.25},
+	} {
+		opts := cmd.DefaultSplitOptions()
+		opts.Index, opts.Total = 0, 1
+		opts.Format = "json"
+		opts.StatsGoTest = []string{"../testdata/gotest/*.jsonl"}
+		opts.StripPrefixes = tc.strip
+
+		var stdout, stderr bytes.Buffer
+		if err := cmd.RunSplit(t.Context(), opts, strings.NewReader(tc.input), &stdout, &stderr); err != nil {
+			t.Fatalf("%s: RunSplit failed: %v\n%s", name, err, stderr.String())
+		}
+		var got struct {
+			Total float64 `json:"total"`
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("%s: invalid JSON output %q: %v", name, stdout.String(), err)
+		}
+		if math.Abs(got.Total-tc.want) > 1e-9 {
+			t.Errorf("%s: total = %g, want %g", name, got.Total, tc.want)
+		}
+	}
+}
+
+func TestSplitCommand_StatsJSON(t *testing.T) {
+	for merge, want := range map[string]float64{"sum": 22.5, "max": 16.635} {
+		opts := cmd.DefaultSplitOptions()
+		opts.Index, opts.Total = 0, 1
+		opts.Format = "json"
+		opts.StatsFiles = []string{"../testdata/timings/timings.json", "../testdata/junit/example1.xml"}
+		opts.StatsMerge = merge
+
+		var stdout, stderr bytes.Buffer
+		input := strings.NewReader("pkg/service/auth_test.go\npkg/api/handler_test.go\npkg/new/new_test.go\n")
+		if err := cmd.RunSplit(t.Context(), opts, input, &stdout, &stderr); err != nil {
+			t.Fatalf("RunSplit failed: %v\n%s", err, stderr.String())
+		}
+		var got struct {
+			Total float64 `json:"total"`
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+		}
+		if math.Abs(got.Total-want) > 1e-9 {
+			t.Errorf("--stats-merge %s: total = %g, want %g", merge, got.Total, want)
+		}
+	}
 }
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/prgtw/tests-helper/internal/logging"
+	"github.com/prgtw/tests-helper/internal/splitter"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+// statsOptions configures the stats command. The fields mirror its flags.
+type statsOptions struct {
+	StatsFiles  []string // JUnit XML files, glob patterns, or directories (--stats)
+	Format      string   // text or json (--format)
+	Top         int      // Slowest tests listed, 0 for all (--top)
+	StrictStats bool     // Fail on unusable stats files (--strict-stats)
+	Debug       bool     // Log at debug level (--debug)
+}
+
+// statsReport is the output of the stats command.
+type statsReport struct {
+	Tests       []statsEntry           `json:"tests"`       // Slowest first, at most --top
+	Count       int                    `json:"count"`       // Tests loaded, including those beyond --top
+	Total       float64                `json:"total"`       // Time of every loaded test
+	Percentiles []testsplit.Percentile `json:"percentiles"` // P50, P75, P95, and P99 of every loaded test
+}
+
+// statsEntry is the accumulated time of one test.
+type statsEntry struct {
+	Name string  `json:"name"`
+	Time float64 `json:"time"`
+}
+
+// newStatsCmd creates the stats command.
+func newStatsCmd(logger zerolog.Logger) *cobra.Command {
+	opts := statsOptions{StatsFiles: []string{}, Format: formatText}
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the test times loaded from JUnit reports",
+		Long: `Stats parses JUnit XML reports the way split does and prints every test with its
+accumulated time, slowest first, followed by the total and the P50, P75, P95, and P99
+percentiles of all tests. No test list is read.
+
+Examples:
+  tests-helper stats --stats "reports/*.xml"
+  tests-helper stats --stats "reports/*.xml" --top 20
+  tests-helper stats --stats "reports/*.xml" --format json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return runStats(ctx, logger, &opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&opts.StatsFiles, "stats", opts.StatsFiles,
+		"Path(s) to JUnit XML stats files or directories, or .json timings (supports glob patterns)")
+	cmd.Flags().StringVar(&opts.Format, "format", opts.Format, "Output format: text or json")
+	cmd.Flags().IntVar(&opts.Top, "top", opts.Top, "List only the N slowest tests (0 lists all)")
+	cmd.Flags().BoolVar(&opts.StrictStats, "strict-stats", opts.StrictStats,
+		"Fail on unreadable, invalid, or truncated stats files")
+	cmd.Flags().BoolVar(&opts.Debug, "debug", opts.Debug, "Enable debug logging")
+
+	return cmd
+}
+
+// runStats loads the reports and writes the times they hold to stdout.
+func runStats(ctx context.Context, logger zerolog.Logger, opts *statsOptions, stdout io.Writer) error {
+	if opts.Debug {
+		logger = logger.Level(zerolog.DebugLevel)
+	} else {
+		logger = logger.Level(zerolog.InfoLevel)
+	}
+
+	switch {
+	case len(opts.StatsFiles) == 0:
+		return usageError(errors.New("--stats is required"))
+	case opts.Format != formatText && opts.Format != formatJSON:
+		return usageError(fmt.Errorf("invalid --format %q (expected %s or %s)", opts.Format, formatText, formatJSON))
+	case opts.Top < 0:
+		return usageError(fmt.Errorf("--top must not be negative, got %d", opts.Top))
+	}
+
+	ts := testsplit.New(
+		testsplit.WithLogger(slog.New(logging.NewHandler(logger))),
+		testsplit.WithStrictStats(opts.StrictStats),
+	)
+	times, err := ts.LoadSources(ctx, ts.JUnitFiles(opts.StatsFiles...))
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return statsError(err)
+	}
+
+	report := newStatsReport(times, opts.Top)
+	if opts.Format == formatJSON {
+		return writeJSON(stdout, report, reportIndent)
+	}
+	return report.writeText(stdout)
+}
+
+// newStatsReport sorts times slowest first, by name among equal times, keeping the top slowest
+// unless top is 0. The total and percentiles cover every test.
+func newStatsReport(times map[string]float64, top int) statsReport {
+	report := statsReport{Tests: make([]statsEntry, 0, len(times)), Count: len(times)}
+	values := make([]float64, 0, len(times))
+	for name, t := range times {
+		report.Tests = append(report.Tests, statsEntry{Name: name, Time: t})
+		report.Total += t
+		values = append(values, t)
+	}
+	slices.SortFunc(report.Tests, func(a, b statsEntry) int {
+		return cmp.Or(cmp.Compare(b.Time, a.Time), strings.Compare(a.Name, b.Name))
+	})
+	if top > 0 && top < len(report.Tests) {
+		report.Tests = report.Tests[:top]
+	}
+
+	percentiles := []int{50, 75, 95, 99}
+	results := splitter.NewPercentileCalculator().Calculate(values, percentiles)
+	report.Percentiles = make([]testsplit.Percentile, 0, len(results))
+	for _, p := range percentiles {
+		if value, ok := results[p]; ok {
+			report.Percentiles = append(report.Percentiles, testsplit.Percentile{Percentile: p, Value: value})
+		}
+	}
+	return report
+}
+
+// writeText writes the report as a table of tests with their time and share of the total,
+// followed by the totals and percentiles.
+func (r *statsReport) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TIME\tSHARE\t  TEST")
+	for _, entry := range r.Tests {
+		share := 0.0
+		if r.Total > 0 {
+			share = entry.Time / r.Total * 100
+		}
+		fmt.Fprintf(tw, "%.3fs\t%.1f%%\t  %s\n", entry.Time, share, entry.Name)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nTotal: %.3fs in %d tests", r.Total, r.Count)
+	if len(r.Tests) < r.Count {
+		fmt.Fprintf(&b, " (showing the %d slowest)", len(r.Tests))
+	}
+	b.WriteString("\n")
+	if len(r.Percentiles) > 0 {
+		parts := make([]string, len(r.Percentiles))
+		for i, p := range r.Percentiles {
+			parts[i] = fmt.Sprintf("P%d %.3fs", p.Percentile, p.Value)
+		}
+		fmt.Fprintf(&b, "Percentiles: %s\n", strings.Join(parts, ", "))
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+// writeStatsTimings writes a timings map of four tests adding up to 15s.
+func writeStatsTimings(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "times.json")
+	content := `{"pkg/a_test.go": 8, "pkg/b_test.go": 4, "pkg/c_test.go": 2, "pkg/d_test.go": 1}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write timings: %v", err)
+	}
+	return path
+}
+
+func TestStatsCommand_Text(t *testing.T) {
+	opts := &cmd.StatsOptions{StatsFiles: []string{writeStatsTimings(t)}, Format: "text", Top: 2}
+
+	var stdout bytes.Buffer
+	if err := cmd.RunStats(t.Context(), zerolog.Nop(), opts, &stdout); err != nil {
+		t.Fatalf("RunStats failed: %v", err)
+	}
+	want := "" +
+		"    TIME  SHARE  TEST\n" +
+		"  8.000s  53.3%  pkg/a_test.go\n" +
+		"  4.000s  26.7%  pkg/b_test.go\n" +
+		"\n" +
+		"Total: 15.000s in 4 tests (showing the 2 slowest)\n" +
+		"Percentiles: P50 3.000s, P75 5.000s, P95 7.400s, P99 7.880s\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("Output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStatsCommand_JSON(t *testing.T) {
+	tree := cmd.NewCommandTree(zerolog.Nop(), cmd.BuildInfo{})
+	var stdout bytes.Buffer
+	tree.SetIn(iotest.ErrReader(errors.New("stdin must not be read")))
+	tree.SetOut(&stdout)
+	tree.SetArgs([]string{"stats", "--stats", writeStatsTimings(t), "--format", "json"})
+
+	if _, err := tree.ExecuteC(); err != nil {
+		t.Fatalf("ExecuteC failed: %v", err)
+	}
+
+	var got struct {
+		Tests []struct {
+			Name string  `json:"name"`
+			Time float64 `json:"time"`
+		} `json:"tests"`
+		Count       int     `json:"count"`
+		Total       float64 `json:"total"`
+		Percentiles []struct {
+			Percentile int     `json:"percentile"`
+			Value      float64 `json:"value"`
+		} `json:"percentiles"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+	}
+	names := make([]string, len(got.Tests))
+	for i, test := range got.Tests {
+		names[i] = test.Name
+	}
+	if strings.Join(names, ",") != "pkg/a_test.go,pkg/b_test.go,pkg/c_test.go,pkg/d_test.go" {
+		t.Errorf("Tests = %v, want slowest first", names)
+	}
+	if got.Count != 4 || got.Total != 15 {
+		t.Errorf("Count, Total = %d, %g, want 4, 15", got.Count, got.Total)
+	}
+	if p := got.Percentiles; len(p) != 4 || p[2].Percentile != 95 || math.Abs(p[2].Value-7.4) > 1e-9 {
+		t.Errorf("Percentiles = %+v, want P50, P75, P95 7.4, P99", got.Percentiles)
+	}
+}
+
+func TestStatsCommand_JSONDeterministic(t *testing.T) {
+	// Equal times leave only the names to order the tests
+	dir := t.TempDir()
+	run := func(name, content string) []byte {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write timings: %v", err)
+		}
+		var stdout bytes.Buffer
+		opts := &cmd.StatsOptions{StatsFiles: []string{path}, Format: "json"}
+		if err := cmd.RunStats(t.Context(), zerolog.Nop(), opts, &stdout); err != nil {
+			t.Fatalf("RunStats failed: %v", err)
+		}
+		return stdout.Bytes()
+	}
+
+	want := run("forward.json", `{"pkg/a_test.go": 2, "pkg/b_test.go": 2, "pkg/c_test.go": 2, "pkg/d_test.go": 2}`)
+	for range 10 {
+		got := run("backward.json", `{"pkg/d_test.go": 2, "pkg/c_test.go": 2, "pkg/b_test.go": 2, "pkg/a_test.go": 2}`)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Output =\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func TestStatsCommand_Errors(t *testing.T) {
+	timings := writeStatsTimings(t)
+	for name, tc := range map[string]struct {
+		opts cmd.StatsOptions
+		code int
+	}{
+		"no stats":     {cmd.StatsOptions{Format: "text"}, cmd.ExitUsage},
+		"bad format":   {cmd.StatsOptions{StatsFiles: []string{timings}, Format: "csv"}, cmd.ExitUsage},
+		"negative top": {cmd.StatsOptions{StatsFiles: []string{timings}, Format: "text", Top: -1}, cmd.ExitUsage},
+		"no match":     {cmd.StatsOptions{StatsFiles: []string{"missing-*.xml"}, Format: "text"}, cmd.ExitStats},
+	} {
+		err := cmd.RunStats(t.Context(), zerolog.Nop(), &tc.opts, &bytes.Buffer{})
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if code := cmd.ExitCode(err); code != tc.code {
+			t.Errorf("%s: exit code = %d, want %d (%v)", name, code, tc.code, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/prgtw/tests-helper/internal/inputcmd"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+// verifyOptions configures the verify command. The fields mirror its flags.
+type verifyOptions struct {
+	Format            string  // text or json (--format)
+	MaxMissing        int     // Tests without stats tolerated, negative for any (--max-missing)
+	MaxMissingPercent float64 // Percentage of tests without stats tolerated, negative for any (--max-missing-percent)
+	// Split holds the options shared with split: --stats, --input, --input-cmd, --inline-times,
+	// --match, --dedupe, --granularity, --stats-key, --strip-prefix, --strict-stats, and --debug.
+	Split SplitOptions
+}
+
+// verifyReport is the output of the verify command.
+type verifyReport struct {
+	Tests          int      `json:"tests"`           // Tests in the list
+	Covered        int      `json:"covered"`         // Tests with stats or an inline time
+	MissingPercent float64  `json:"missing_percent"` // Share of the tests without stats
+	Missing        []string `json:"missing"`         // Tests without stats, sorted
+	Stale          []string `json:"stale"`           // Stats keys matching no test, sorted
+}
+
+// newVerifyCmd creates the verify command.
+func newVerifyCmd(logger zerolog.Logger) *cobra.Command {
+	opts := verifyOptions{Format: formatText, MaxMissing: -1, MaxMissingPercent: -1, Split: DefaultSplitOptions()}
+	split := &opts.Split
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check that the stats cover the test list",
+		Long: `Verify reads the test list like split does and matches it against the stats the same
+way, then prints every test without historical data and every stats entry that no
+longer matches a test of the list (stale). With --max-missing or --max-missing-percent
+it exits with code 7 when too many tests have no stats, so CI can be gated on it.
+
+Examples:
+  tests-helper verify --stats "reports/*.xml" < tests.txt
+  tests-helper verify --stats "reports/*.xml" --input tests.txt --max-missing 0
+  tests-helper verify --stats "reports/*.xml" --max-missing-percent 5 --format json < tests.txt`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return runVerify(ctx, logger, &opts, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Format, "format", opts.Format, "Output format: text or json")
+	cmd.Flags().IntVar(&opts.MaxMissing, "max-missing", opts.MaxMissing,
+		"Fail when more than N tests have no stats (negative disables the check)")
+	cmd.Flags().Float64Var(&opts.MaxMissingPercent, "max-missing-percent", opts.MaxMissingPercent,
+		"Fail when more than this percentage of the tests have no stats (negative disables the check)")
+	cmd.Flags().StringSliceVar(&split.StatsFiles, "stats", split.StatsFiles,
+		"Path(s) to JUnit XML stats files or directories, or .json timings (supports glob patterns)")
+	cmd.Flags().StringVar(&split.InputFile, "input", split.InputFile, "Read the test list from a file instead of stdin")
+	cmd.Flags().StringVar(&split.InputCmd, "input-cmd", split.InputCmd,
+		"Read the test list from the standard output of a shell command")
+	cmd.Flags().BoolVar(&split.InlineTimes, "inline-times", split.InlineTimes,
+		"Treat a trailing number on an input line as that test's time")
+	cmd.Flags().StringVar(&split.MatchMode, "match", split.MatchMode, "Stats key matching: exact or suffix")
+	cmd.Flags().StringVar(&split.Dedupe, "dedupe", split.Dedupe, "Duplicate test lines: keep or first")
+	cmd.Flags().StringVar(&split.Granularity, "granularity", split.Granularity,
+		"What is scheduled: file or suite")
+	cmd.Flags().StringVar(&split.StatsKey, "stats-key", split.StatsKey,
+		"Report attribute keying the stats: file, classname, or auto")
+	cmd.Flags().StringArrayVar(&split.StripPrefixes, "strip-prefix", split.StripPrefixes,
+		"Strip a directory from the start of names and stats keys (repeatable)")
+	cmd.Flags().BoolVar(&split.StrictStats, "strict-stats", split.StrictStats,
+		"Fail on unreadable, invalid, or truncated stats files")
+	cmd.Flags().BoolVar(&split.Debug, "debug", split.Debug, "Enable debug logging")
+
+	return cmd
+}
+
+// runVerify reads the test list and the stats, writes the tests without stats and the stale
+// stats entries to stdout, and fails when more tests lack stats than allowed.
+func runVerify(
+	ctx context.Context, logger zerolog.Logger, opts *verifyOptions, stdin io.Reader, stdout io.Writer,
+) error {
+	if opts.Split.Debug {
+		logger = logger.Level(zerolog.DebugLevel)
+	} else {
+		logger = logger.Level(zerolog.InfoLevel)
+	}
+
+	if err := validateVerifyOptions(opts); err != nil {
+		return usageError(err)
+	}
+	ts, err := newTestSplit(logger, &opts.Split)
+	if err != nil {
+		return usageError(err)
+	}
+	times, err := ts.LoadSources(ctx, ts.JUnitFiles(opts.Split.StatsFiles...))
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return statsError(err)
+	}
+	tests, err := readTests(ctx, logger, ts, &opts.Split, stdin, inputcmd.Exec, times)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return inputError(err)
+	}
+
+	report := newVerifyReport(ts.Coverage(tests, times))
+	logger.Info().
+		Int("tests", report.Tests).
+		Int("missing", len(report.Missing)).
+		Int("stale", len(report.Stale)).
+		Int("keys", len(times)).
+		Msg("Verified stats coverage")
+
+	if opts.Format == formatJSON {
+		err = writeJSON(stdout, report, reportIndent)
+	} else {
+		err = report.writeText(stdout)
+	}
+	if err != nil {
+		return err
+	}
+	return report.check(opts)
+}
+
+// validateVerifyOptions checks the flags of the verify command.
+func validateVerifyOptions(opts *verifyOptions) error {
+	switch {
+	case len(opts.Split.StatsFiles) == 0:
+		return errors.New("--stats is required")
+	case opts.Format != formatText && opts.Format != formatJSON:
+		return fmt.Errorf("invalid --format %q (expected %s or %s)", opts.Format, formatText, formatJSON)
+	case math.IsNaN(opts.MaxMissingPercent) || opts.MaxMissingPercent > 100:
+		return fmt.Errorf("invalid --max-missing-percent %g (expected at most 100)", opts.MaxMissingPercent)
+	}
+	return nil
+}
+
+// newVerifyReport turns coverage into the report, with empty lists rather than null in JSON.
+func newVerifyReport(coverage testsplit.Coverage) verifyReport {
+	report := verifyReport{
+		Tests:   coverage.Tests,
+		Covered: coverage.Tests - len(coverage.Missing),
+		Missing: coverage.Missing,
+		Stale:   coverage.Stale,
+	}
+	if report.Missing == nil {
+		report.Missing = []string{}
+	}
+	if report.Stale == nil {
+		report.Stale = []string{}
+	}
+	if report.Tests > 0 {
+		report.MissingPercent = float64(len(report.Missing)) / float64(report.Tests) * 100
+	}
+	return report
+}
+
+// check fails with an uncovered error when more tests lack stats than --max-missing or
+// --max-missing-percent allow.
+func (r *verifyReport) check(opts *verifyOptions) error {
+	missing := len(r.Missing)
+	if opts.MaxMissing >= 0 && missing > opts.MaxMissing {
+		return coverageError(fmt.Errorf("%d of %d tests have no stats, more than --max-missing %d",
+			missing, r.Tests, opts.MaxMissing))
+	}
+	if opts.MaxMissingPercent >= 0 && r.MissingPercent > opts.MaxMissingPercent {
+		return coverageError(fmt.Errorf("%.1f%% of the tests have no stats, more than --max-missing-percent %g",
+			r.MissingPercent, opts.MaxMissingPercent))
+	}
+	return nil
+}
+
+// writeText writes the tests without stats and the stale stats entries, each list only when not
+// empty, followed by a summary line.
+func (r *verifyReport) writeText(w io.Writer) error {
+	var b strings.Builder
+	writeList := func(title string, names []string) {
+		if len(names) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s (%d):\n", title, len(names))
+		for _, name := range names {
+			fmt.Fprintf(&b, "  %s\n", name)
+		}
+		b.WriteString("\n")
+	}
+	writeList("Tests without stats", r.Missing)
+	writeList("Stale stats entries", r.Stale)
+	fmt.Fprintf(&b, "Coverage: %d of %d tests have stats (%.1f%% missing), %d stale stats entries\n",
+		r.Covered, r.Tests, r.MissingPercent, len(r.Stale))
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/cmd"
+)
+
+// verifyList lists two tests with stats in writeStatsTimings, one without, and leaves two stats
+// entries unused.
+const verifyList = "pkg/a_test.go\npkg/new_test.go\npkg/b_test.go\n"
+
+func newVerifyOptions(t *testing.T) *cmd.VerifyOptions {
+	t.Helper()
+	opts := &cmd.VerifyOptions{Format: "text", MaxMissing: -1, MaxMissingPercent: -1, Split: cmd.DefaultSplitOptions()}
+	opts.Split.StatsFiles = []string{writeStatsTimings(t)}
+	return opts
+}
+
+func TestVerifyCommand_Text(t *testing.T) {
+	var stdout bytes.Buffer
+	err := cmd.RunVerify(t.Context(), zerolog.Nop(), newVerifyOptions(t), strings.NewReader(verifyList), &stdout)
+	if err != nil {
+		t.Fatalf("RunVerify failed: %v", err)
+	}
+	want := "" +
+		"Tests without stats (1):\n" +
+		"  pkg/new_test.go\n" +
+		"\n" +
+		"Stale stats entries (2):\n" +
+		"  pkg/c_test.go\n" +
+		"  pkg/d_test.go\n" +
+		"\n" +
+		"Coverage: 2 of 3 tests have stats (33.3% missing), 2 stale stats entries\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("Output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestVerifyCommand_JSON(t *testing.T) {
+	tree := cmd.NewCommandTree(zerolog.Nop(), cmd.BuildInfo{})
+	var stdout bytes.Buffer
+	tree.SetIn(strings.NewReader("pkg/a_test.go\npkg/b_test.go\npkg/c_test.go\npkg/d_test.go\n"))
+	tree.SetOut(&stdout)
+	tree.SetArgs([]string{"verify", "--stats", writeStatsTimings(t), "--format", "json", "--max-missing", "0"})
+
+	if _, err := tree.ExecuteC(); err != nil {
+		t.Fatalf("ExecuteC failed: %v", err)
+	}
+	var got struct {
+		Tests          int      `json:"tests"`
+		Covered        int      `json:"covered"`
+		MissingPercent float64  `json:"missing_percent"`
+		Missing        []string `json:"missing"`
+		Stale          []string `json:"stale"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+	}
+	if got.Tests != 4 || got.Covered != 4 || got.MissingPercent != 0 {
+		t.Errorf("Report = %+v, want all 4 tests covered", got)
+	}
+	if got.Missing == nil || got.Stale == nil || len(got.Missing)+len(got.Stale) != 0 {
+		t.Errorf("Missing = %v, Stale = %v, want empty lists", got.Missing, got.Stale)
+	}
+}
+
+func TestVerifyCommand_JSONDeterministic(t *testing.T) {
+	run := func(list string) []byte {
+		opts := newVerifyOptions(t)
+		opts.Format = "json"
+		var stdout bytes.Buffer
+		if err := cmd.RunVerify(t.Context(), zerolog.Nop(), opts, strings.NewReader(list), &stdout); err != nil {
+			t.Fatalf("RunVerify failed: %v", err)
+		}
+		return stdout.Bytes()
+	}
+
+	want := run("pkg/a_test.go\npkg/new_test.go\npkg/b_test.go\npkg/other_test.go\n")
+	for range 10 {
+		if got := run("pkg/other_test.go\npkg/b_test.go\npkg/new_test.go\npkg/a_test.go\n"); !bytes.Equal(got, want) {
+			t.Fatalf("Output =\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func TestVerifyCommand_Thresholds(t *testing.T) {
+	tests := []struct {
+		maxMissing        int
+		maxMissingPercent float64
+		wantCode          int
+	}{
+		{maxMissing: -1, maxMissingPercent: -1, wantCode: cmd.ExitOK},
+		{maxMissing: 1, maxMissingPercent: -1, wantCode: cmd.ExitOK},
+		{maxMissing: 0, maxMissingPercent: -1, wantCode: cmd.ExitCoverage},
+		{maxMissing: -1, maxMissingPercent: 34, wantCode: cmd.ExitOK},
+		{maxMissing: -1, maxMissingPercent: 33, wantCode: cmd.ExitCoverage},
+		{maxMissing: -1, maxMissingPercent: 101, wantCode: cmd.ExitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%g", tt.maxMissing, tt.maxMissingPercent), func(t *testing.T) {
+			opts := newVerifyOptions(t)
+			opts.MaxMissing, opts.MaxMissingPercent = tt.maxMissing, tt.maxMissingPercent
+
+			var stdout bytes.Buffer
+			err := cmd.RunVerify(t.Context(), zerolog.Nop(), opts, strings.NewReader(verifyList), &stdout)
+			if code := cmd.ExitCode(err); code != tt.wantCode {
+				t.Errorf("Exit code = %d (%v), want %d", code, err, tt.wantCode)
+			}
+			if tt.wantCode == cmd.ExitCoverage && !strings.Contains(stdout.String(), "pkg/new_test.go") {
+				t.Errorf("Output = %q, want the report written before failing", stdout.String())
+			}
+		})
+	}
+}
+
+func TestVerifyCommand_Errors(t *testing.T) {
+	opts := newVerifyOptions(t)
+	opts.Split.StatsFiles = nil
+	err := cmd.RunVerify(t.Context(), zerolog.Nop(), opts, strings.NewReader(verifyList), &bytes.Buffer{})
+	if code := cmd.ExitCode(err); code != cmd.ExitUsage {
+		t.Errorf("Exit code without --stats = %d (%v), want %d", code, err, cmd.ExitUsage)
+	}
+
+	opts = newVerifyOptions(t)
+	err = cmd.RunVerify(t.Context(), zerolog.Nop(), opts, strings.NewReader(""), &bytes.Buffer{})
+	if code := cmd.ExitCode(err); code != cmd.ExitInput {
+		t.Errorf("Exit code for an empty list = %d (%v), want %d", code, err, cmd.ExitInput)
+	}
+}
@@ -0,0 +1,138 @@
+// Package changes finds the tests affected by the files changed on a git branch.
+package changes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// DefaultSince is the ref the branch is compared with.
+const DefaultSince = "origin/main"
+
+// ErrInvalidRule is returned for a mapping rule not of the form "FROM -> TO".
+var ErrInvalidRule = errors.New("invalid rule")
+
+// Runner runs git with args in the current directory and returns its standard output.
+type Runner func(ctx context.Context, args ...string) ([]byte, error)
+
+// Git runs the git executable found in PATH.
+func Git(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// Files returns the files changed on the current branch since it forked from the since ref,
+// relative to the current directory. Uncommitted changes are not included.
+func Files(ctx context.Context, run Runner, since string) ([]string, error) {
+	out, err := run(ctx, "diff", "--name-only", "--relative", since+"...HEAD")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for line := range strings.Lines(string(out)) {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, path.Clean(line))
+		}
+	}
+	return files, nil
+}
+
+// Rule maps changed files to tests: a changed file matching From affects the tests matching To.
+// Both are slash-separated globs where "**" matches any number of directories.
+type Rule struct {
+	From string
+	To   string
+}
+
+// ParseRule parses a rule written as "FROM -> TO", e.g. "src/foo/** -> tests/foo/**".
+func ParseRule(s string) (Rule, error) {
+	from, to, ok := strings.Cut(s, "->")
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if !ok || from == "" || to == "" {
+		return Rule{}, fmt.Errorf("%w %q: want FROM -> TO", ErrInvalidRule, s)
+	}
+	return Rule{From: from, To: to}, nil
+}
+
+// ParseRules parses every rule with ParseRule.
+func ParseRules(raw []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(raw))
+	for _, s := range raw {
+		rule, err := ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Matcher decides which tests a set of changed files affects.
+type Matcher struct {
+	files map[string]bool
+	dirs  map[string]bool
+	rules []Rule // Rules with a changed file matching From
+}
+
+// NewMatcher creates a matcher for the changed files. A test is affected when its own file
+// changed, when a file in its directory changed, or when a rule maps a changed file to it.
+func NewMatcher(changed []string, rules []Rule) *Matcher {
+	m := &Matcher{files: make(map[string]bool, len(changed)), dirs: make(map[string]bool)}
+	for _, file := range changed {
+		file = path.Clean(file)
+		m.files[file] = true
+		m.dirs[path.Dir(file)] = true
+	}
+	for _, rule := range rules {
+		for file := range m.files {
+			if fileutil.MatchGlob(rule.From, file) {
+				m.rules = append(m.rules, rule)
+				break
+			}
+		}
+	}
+	return m
+}
+
+// Affects reports whether the test named name is affected by the changes.
+func (m *Matcher) Affects(name string) bool {
+	name = path.Clean(name)
+	if m.files[name] || m.dirs[path.Dir(name)] {
+		return true
+	}
+	return slices.ContainsFunc(m.rules, func(rule Rule) bool { return fileutil.MatchGlob(rule.To, name) })
+}
+
+// Prioritize returns a copy of tests with the affected ones moved to the front, keeping the
+// relative order within both parts, and the number of affected tests.
+func (m *Matcher) Prioritize(tests []junit.Test) ([]junit.Test, int) {
+	ordered := make([]junit.Test, 0, len(tests))
+	var rest []junit.Test
+	for _, test := range tests {
+		if m.Affects(test.Name) {
+			ordered = append(ordered, test)
+		} else {
+			rest = append(rest, test)
+		}
+	}
+	affected := len(ordered)
+	return append(ordered, rest...), affected
+}
@@ -0,0 +1,132 @@
+package changes_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/changes"
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+func TestFiles(t *testing.T) {
+	var args []string
+	run := func(_ context.Context, a ...string) ([]byte, error) {
+		args = a
+		return []byte("pkg/a.go\n\n./pkg/b/b_test.go\n"), nil
+	}
+
+	files, err := changes.Files(t.Context(), run, "origin/main")
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	if want := []string{"pkg/a.go", "pkg/b/b_test.go"}; !slices.Equal(files, want) {
+		t.Errorf("Files = %q, want %q", files, want)
+	}
+	if want := []string{"diff", "--name-only", "--relative", "origin/main...HEAD"}; !slices.Equal(args, want) {
+		t.Errorf("Args = %q, want %q", args, want)
+	}
+
+	failing := func(context.Context, ...string) ([]byte, error) { return nil, errors.New("not a git repository") }
+	if _, err = changes.Files(t.Context(), failing, "origin/main"); err == nil {
+		t.Error("Expected the git error")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	rule, err := changes.ParseRule(" src/foo/** ->tests/foo/** ")
+	if err != nil || rule != (changes.Rule{From: "src/foo/**", To: "tests/foo/**"}) {
+		t.Errorf("ParseRule = %+v, %v", rule, err)
+	}
+	for _, bad := range []string{"src/**", "-> tests/**", "src/** ->"} {
+		if _, err = changes.ParseRules([]string{bad}); !errors.Is(err, changes.ErrInvalidRule) {
+			t.Errorf("ParseRules(%q) error = %v, want ErrInvalidRule", bad, err)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m := changes.NewMatcher(
+		[]string{"pkg/a/a.go", "spec/b_spec.rb", "src/foo/bar.go"},
+		[]changes.Rule{{From: "src/foo/**", To: "tests/foo/**"}, {From: "src/other/**", To: "tests/other/**"}},
+	)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "pkg/a/a_test.go", want: true},          // Same directory
+		{name: "./pkg/a/a_test.go", want: true},        // Cleaned
+		{name: "spec/b_spec.rb", want: true},           // Changed itself
+		{name: "tests/foo/deep/x_test.go", want: true}, // Mapped by a rule
+		{name: "tests/other/y_test.go", want: false},   // Rule without a changed file
+		{name: "pkg/a/sub/c_test.go", want: false},
+		{name: "pkg/z_test.go", want: false},
+	}
+	for _, tt := range tests {
+		if got := m.Affects(tt.name); got != tt.want {
+			t.Errorf("Affects(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_Prioritize(t *testing.T) {
+	m := changes.NewMatcher([]string{"b/b.go", "d/d_test.go"}, nil)
+	tests := []junit.Test{{Name: "a/a_test.go"}, {Name: "b/b_test.go"}, {Name: "c/c_test.go"}, {Name: "d/d_test.go"}}
+
+	ordered, affected := m.Prioritize(tests)
+
+	var names []string
+	for _, test := range ordered {
+		names = append(names, test.Name)
+	}
+	if want := []string{"b/b_test.go", "d/d_test.go", "a/a_test.go", "c/c_test.go"}; !slices.Equal(names, want) {
+		t.Errorf("Order = %q, want %q", names, want)
+	}
+	if affected != 2 || tests[0].Name != "a/a_test.go" {
+		t.Errorf("Affected = %d, input order %v; want 2 and the input untouched", affected, tests)
+	}
+}
+
+func TestRenames(t *testing.T) {
+	var args []string
+	run := func(_ context.Context, a ...string) ([]byte, error) {
+		args = a
+		// Newest first: b was renamed to c after a was renamed to b; old was recreated and renamed again
+		return []byte("R100\tpkg/b_test.go\tpkg/c_test.go\nM\tpkg/d.go\n\n" +
+			"R087\tpkg/a_test.go\tpkg/b_test.go\nR100\told_test.go\tnew_test.go\nA\tpkg/e_test.go\n\n" +
+			"R100\told_test.go\tfirst_test.go\n"), nil
+	}
+
+	renames, err := changes.Renames(t.Context(), run, "", 50)
+	if err != nil {
+		t.Fatalf("Renames failed: %v", err)
+	}
+	want := map[string]string{
+		"pkg/a_test.go": "pkg/c_test.go",
+		"pkg/b_test.go": "pkg/c_test.go",
+		"old_test.go":   "new_test.go",
+	}
+	if !maps.Equal(renames, want) {
+		t.Errorf("Renames = %v, want %v", renames, want)
+	}
+	log := []string{"log", "--name-status", "--find-renames", "--relative", "--format=", "--max-count=50", "HEAD"}
+	if !slices.Equal(args, log) {
+		t.Errorf("Args = %q, want %q", args, log)
+	}
+
+	if _, err = changes.Renames(t.Context(), run, "v1.0", 50); err != nil {
+		t.Fatalf("Renames since a ref failed: %v", err)
+	}
+	diff := []string{"diff", "--name-status", "--find-renames", "--relative", "v1.0", "HEAD"}
+	if !slices.Equal(args, diff) {
+		t.Errorf("Args = %q, want %q", args, diff)
+	}
+
+	failing := func(context.Context, ...string) ([]byte, error) { return nil, errors.New("not a git repository") }
+	if _, err = changes.Renames(t.Context(), failing, "", 50); err == nil {
+		t.Error("Expected the git error")
+	}
+}
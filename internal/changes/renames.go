@@ -0,0 +1,47 @@
+package changes
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DefaultRenameCommits is the number of commits of the history Renames searches without a ref.
+const DefaultRenameCommits = 200
+
+// Renames returns the files renamed on the way to HEAD, mapping each old path to its current
+// one, relative to the current directory.
+//
+// With since set, the trees of since and HEAD are compared, so a file renamed several times in
+// between maps straight to its last path. Otherwise the last commits of the history of HEAD are
+// searched, newest first, and a chain of renames is followed to the latest path; when a path was
+// renamed more than once, as after being recreated, its latest rename wins.
+func Renames(ctx context.Context, run Runner, since string, commits int) (map[string]string, error) {
+	args := []string{"diff", "--name-status", "--find-renames", "--relative", since, "HEAD"}
+	if since == "" {
+		args = []string{"log", "--name-status", "--find-renames", "--relative", "--format=",
+			"--max-count=" + strconv.Itoa(commits), "HEAD"}
+	}
+	out, err := run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	renames := make(map[string]string)
+	for line := range strings.Lines(string(out)) {
+		// Renames read "R<score>\told\tnew"; other statuses are ignored
+		fields := strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+		if len(fields) != 3 || !strings.HasPrefix(fields[0], "R") {
+			continue
+		}
+		from, to := path.Clean(fields[1]), path.Clean(fields[2])
+		if latest, ok := renames[to]; ok {
+			to = latest
+		}
+		if _, ok := renames[from]; !ok && from != to {
+			renames[from] = to
+		}
+	}
+	return renames, nil
+}
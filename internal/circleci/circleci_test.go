@@ -0,0 +1,205 @@
+package circleci_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/circleci"
+)
+
+// fakeAPI serves a project with two pipelines on main: the newest still running,
+// the previous one with a failed and a successful workflow.
+type fakeAPI struct {
+	server    *httptest.Server
+	downloads atomic.Int32
+	limited   atomic.Bool
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{}
+	mux := http.NewServeMux()
+	respond := func(w http.ResponseWriter, items any, next string) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"items": items, "next_page_token": next})
+	}
+	mux.HandleFunc("GET /project/gh/org/repo/pipeline", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("branch") != "main" {
+			respond(w, []any{}, "")
+			return
+		}
+		if r.URL.Query().Get("page-token") == "" {
+			respond(w, []map[string]any{{"id": "p3", "number": 3}}, "older")
+			return
+		}
+		respond(w, []map[string]any{{"id": "p2", "number": 2}}, "")
+	})
+	mux.HandleFunc("GET /pipeline/p3/workflow", func(w http.ResponseWriter, _ *http.Request) {
+		respond(w, []map[string]any{{"id": "wf-3", "name": "test", "status": "running"}}, "")
+	})
+	mux.HandleFunc("GET /pipeline/p2/workflow", func(w http.ResponseWriter, _ *http.Request) {
+		respond(w, []map[string]any{
+			{"id": "wf-2a", "name": "lint", "status": "failed"},
+			{"id": "wf-2", "name": "test", "status": "success"},
+		}, "")
+	})
+	mux.HandleFunc("GET /workflow/wf-2/job", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page-token") == "" {
+			respond(w, []map[string]any{{"job_number": nil, "name": "approve"}}, "more")
+			return
+		}
+		respond(w, []map[string]any{{"job_number": 11, "name": "unit"}, {"job_number": 12, "name": "e2e"}}, "")
+	})
+	mux.HandleFunc("GET /project/gh/org/repo/11/artifacts", func(w http.ResponseWriter, _ *http.Request) {
+		respond(w, []map[string]any{
+			{"path": "test-results/junit.xml", "url": api.server.URL + "/files/junit.xml", "node_index": 0},
+			{"path": "coverage/index.html", "url": api.server.URL + "/files/index.html", "node_index": 0},
+		}, "")
+	})
+	mux.HandleFunc("GET /project/gh/org/repo/12/artifacts", func(w http.ResponseWriter, _ *http.Request) {
+		respond(w, []map[string]any{
+			{"path": "test-results/e2e/junit-1.xml", "url": api.server.URL + "/files/junit-1.xml", "node_index": 1},
+		}, "")
+	})
+	mux.HandleFunc("GET /files/{name}", func(w http.ResponseWriter, r *http.Request) {
+		// The first download is rate limited
+		if !api.limited.Swap(true) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		api.downloads.Add(1)
+		_, _ = w.Write([]byte(r.PathValue("name")))
+	})
+
+	api.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Circle-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(api.server.Close)
+	return api
+}
+
+func (api *fakeAPI) client(token string) *circleci.Client {
+	return circleci.NewClient(zerolog.Nop(), token,
+		circleci.WithBaseURL(api.server.URL),
+		circleci.WithBackoff(time.Millisecond),
+	)
+}
+
+// contentLoader returns a FileLoader keying each file by its content.
+func contentLoader(t *testing.T) circleci.FileLoader {
+	return func(_ context.Context, paths []string) (map[string]float64, error) {
+		times := make(map[string]float64)
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Errorf("Cannot read downloaded artifact: %v", err)
+				continue
+			}
+			times[string(data)]++
+		}
+		return times, nil
+	}
+}
+
+func TestClient_LatestSuccessfulWorkflow(t *testing.T) {
+	api := newFakeAPI(t)
+
+	wf, err := api.client("secret").LatestSuccessfulWorkflow(t.Context(), "gh/org/repo", "main")
+	if err != nil {
+		t.Fatalf("LatestSuccessfulWorkflow failed: %v", err)
+	}
+	if wf.ID != "wf-2" {
+		t.Errorf("Workflow = %+v, want wf-2 from the second page of pipelines", wf)
+	}
+
+	_, err = api.client("secret").LatestSuccessfulWorkflow(t.Context(), "gh/org/repo", "feature")
+	if !errors.Is(err, circleci.ErrNoSuccessfulWorkflow) {
+		t.Errorf("Error = %v, want ErrNoSuccessfulWorkflow", err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	api := newFakeAPI(t)
+
+	_, err := api.client("").LatestSuccessfulWorkflow(t.Context(), "gh/org/repo", "main")
+	if !errors.Is(err, circleci.ErrMissingToken) {
+		t.Errorf("Error without token = %v, want ErrMissingToken", err)
+	}
+
+	// Client errors other than 429 are not retried
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := circleci.NewClient(zerolog.Nop(), "secret",
+		circleci.WithBaseURL(server.URL), circleci.WithRetries(2), circleci.WithBackoff(time.Millisecond))
+
+	if _, err = client.WorkflowArtifacts(t.Context(), "gh/org/repo", "wf"); err == nil || calls.Load() != 1 {
+		t.Errorf("404: error %v after %d calls, want an error after 1", err, calls.Load())
+	}
+	calls.Store(0)
+	if _, err = client.Download(t.Context(), circleci.Artifact{URL: server.URL + "/unavailable"}); err == nil ||
+		calls.Load() != 3 {
+		t.Errorf("503: error %v after %d calls, want an error after 3", err, calls.Load())
+	}
+}
+
+func TestSource_Load(t *testing.T) {
+	api := newFakeAPI(t)
+	cacheDir := t.TempDir()
+	source := circleci.NewSource(api.client("secret"), "gh/org/repo", contentLoader(t),
+		circleci.WithArtifactGlob("**/junit*.xml"),
+		circleci.WithCacheDir(cacheDir),
+	)
+	if got := source.Describe(); got != "circleci:gh/org/repo@main" {
+		t.Errorf("Describe() = %q", got)
+	}
+
+	for range 2 {
+		times, err := source.Load(t.Context())
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		keys := slices.Sorted(maps.Keys(times))
+		if !slices.Equal(keys, []string{"junit-1.xml", "junit.xml"}) {
+			t.Errorf("Loaded %v, want both JUnit artifacts", keys)
+		}
+	}
+	// The rate-limited request was retried, and the second load used the cache
+	if got := api.downloads.Load(); got != 2 {
+		t.Errorf("Downloads = %d, want 2", got)
+	}
+	cached := filepath.Join(cacheDir, "circleci", "wf-2", "1", "test-results", "e2e", "junit-1.xml")
+	if _, err := os.Stat(cached); err != nil {
+		t.Errorf("Artifact not cached by workflow and node: %v", err)
+	}
+
+	source = circleci.NewSource(api.client("secret"), "gh/org/repo", contentLoader(t),
+		circleci.WithArtifactGlob("**/*.json"))
+	if _, err := source.Load(t.Context()); !errors.Is(err, circleci.ErrNoArtifactsMatched) {
+		t.Errorf("Error = %v, want ErrNoArtifactsMatched", err)
+	}
+}
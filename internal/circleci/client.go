@@ -0,0 +1,313 @@
+// Package circleci fetches the JUnit artifacts of previous runs through the CircleCI API v2.
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultBaseURL = "https://circleci.com/api/v2" // Default API endpoint
+	DefaultRetries = 3                             // Default retries after a rate-limited or failed request
+	DefaultBackoff = 1 * time.Second               // Default wait before the first retry, then doubled
+
+	// maxRetryAfter caps the wait requested by a rate-limited response.
+	maxRetryAfter = time.Minute
+	// maxPipelinePages bounds how far back the pipelines of a branch are searched.
+	maxPipelinePages = 5
+)
+
+var (
+	// ErrNoSuccessfulWorkflow is returned when no recent pipeline of the branch has a successful workflow.
+	ErrNoSuccessfulWorkflow = errors.New("no successful workflow found")
+	// ErrMissingToken is returned when no API token is configured.
+	ErrMissingToken = errors.New("no CircleCI API token set (CIRCLE_TOKEN)")
+)
+
+// Workflow is a CircleCI workflow.
+type Workflow struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	PipelineID string `json:"pipeline_id"`
+}
+
+// Artifact is a file stored by a job.
+type Artifact struct {
+	Path      string `json:"path"`
+	URL       string `json:"url"`
+	NodeIndex int    `json:"node_index"`
+}
+
+// pipeline is the part of a pipeline the client needs.
+type pipeline struct {
+	ID     string `json:"id"`
+	Number int    `json:"number"`
+}
+
+// job is the part of a workflow job the client needs. Approval jobs have no number.
+type job struct {
+	Number *int   `json:"job_number"`
+	Name   string `json:"name"`
+}
+
+// page is one page of a paginated API response.
+type page[T any] struct {
+	Items         []T    `json:"items"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+// Client calls the CircleCI API v2, retrying rate-limited and failed requests.
+type Client struct {
+	logger  zerolog.Logger
+	http    *http.Client
+	baseURL string
+	token   string
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sets the API endpoint, e.g. for a CircleCI server installation. An empty URL keeps the default.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests. A nil client keeps the default.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.http = hc
+		}
+	}
+}
+
+// WithRetries sets how many times a rate-limited or failed request is retried. Negative values keep the default.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		if n >= 0 {
+			c.retries = n
+		}
+	}
+}
+
+// WithBackoff sets the wait before the first retry when the response does not specify one.
+// Values below 1 keep the default.
+func WithBackoff(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.backoff = d
+		}
+	}
+}
+
+// NewClient creates a client authenticating with token.
+func NewClient(logger zerolog.Logger, token string, opts ...Option) *Client {
+	c := &Client{
+		logger:  logger,
+		http:    http.DefaultClient,
+		baseURL: DefaultBaseURL,
+		token:   token,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// LatestSuccessfulWorkflow returns the most recent successful workflow of a pipeline on branch.
+// The project slug has the form "gh/org/repo".
+func (c *Client) LatestSuccessfulWorkflow(ctx context.Context, slug, branch string) (Workflow, error) {
+	query := url.Values{"branch": {branch}}
+	pipelines := "/project/" + slug + "/pipeline"
+	for range maxPipelinePages {
+		var pipes page[pipeline]
+		if err := c.get(ctx, pipelines, query, &pipes); err != nil {
+			return Workflow{}, err
+		}
+		for _, p := range pipes.Items {
+			workflows, err := listAll[Workflow](ctx, c, "/pipeline/"+p.ID+"/workflow")
+			if err != nil {
+				return Workflow{}, err
+			}
+			for _, wf := range workflows {
+				if wf.Status == "success" {
+					c.logger.Debug().
+						Int("pipeline", p.Number).
+						Str("workflow", wf.Name).
+						Str("workflow_id", wf.ID).
+						Msg("Found successful workflow")
+					return wf, nil
+				}
+			}
+		}
+		if pipes.NextPageToken == "" {
+			break
+		}
+		query.Set("page-token", pipes.NextPageToken)
+	}
+	return Workflow{}, fmt.Errorf("%w on branch %q of %s", ErrNoSuccessfulWorkflow, branch, slug)
+}
+
+// WorkflowArtifacts returns the artifacts of every job of a workflow.
+func (c *Client) WorkflowArtifacts(ctx context.Context, slug, workflowID string) ([]Artifact, error) {
+	jobs, err := listAll[job](ctx, c, "/workflow/"+workflowID+"/job")
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, j := range jobs {
+		if j.Number == nil {
+			continue
+		}
+		found, err := listAll[Artifact](ctx, c, "/project/"+slug+"/"+strconv.Itoa(*j.Number)+"/artifacts")
+		if err != nil {
+			return nil, err
+		}
+		c.logger.Debug().Str("job", j.Name).Int("artifacts", len(found)).Msg("Listed job artifacts")
+		artifacts = append(artifacts, found...)
+	}
+	return artifacts, nil
+}
+
+// Download returns the content of an artifact.
+func (c *Client) Download(ctx context.Context, artifact Artifact) ([]byte, error) {
+	resp, err := c.do(ctx, artifact.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read artifact %s: %w", artifact.Path, err)
+	}
+	return data, nil
+}
+
+// listAll follows the pagination of the API endpoint at path and returns every item.
+func listAll[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var items []T
+	query := url.Values{}
+	for {
+		var p page[T]
+		if err := c.get(ctx, path, query, &p); err != nil {
+			return nil, err
+		}
+		items = append(items, p.Items...)
+		if p.NextPageToken == "" {
+			return items, nil
+		}
+		query.Set("page-token", p.NextPageToken)
+	}
+}
+
+// get decodes the JSON response of the API endpoint at path into v.
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	resp, err := c.do(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("cannot decode response of %s: %w", path, err)
+	}
+	return nil
+}
+
+// do sends an authenticated GET request, retrying rate-limited (429) and server error responses.
+// The caller closes the body of the returned successful response.
+func (c *Client) do(ctx context.Context, target string) (*http.Response, error) {
+	if c.token == "" {
+		return nil, ErrMissingToken
+	}
+
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create request: %w", err)
+		}
+		req.Header.Set("Circle-Token", c.token)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.http.Do(req)
+		retryAfter := time.Duration(0)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("request interrupted: %w", ctx.Err())
+			}
+			err = fmt.Errorf("request to %s failed: %w", req.URL.Path, err)
+		case resp.StatusCode == http.StatusOK:
+			return resp, nil
+		default:
+			err = fmt.Errorf("request to %s failed: %s", req.URL.Path, resp.Status)
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+				return nil, err
+			}
+		}
+		if attempt >= c.retries {
+			return nil, err
+		}
+
+		delay := wait
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		c.logger.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Dur("backoff", delay).
+			Msg("CircleCI request failed, retrying")
+		if err = sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		wait *= 2
+	}
+}
+
+// parseRetryAfter returns the wait requested by a Retry-After header given in seconds, capped at maxRetryAfter.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+}
+
+// sleep waits for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("request interrupted: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,155 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+)
+
+const (
+	DefaultBranch       = "main"     // Default branch whose artifacts are used
+	DefaultArtifactGlob = "**/*.xml" // Default pattern selecting report artifacts
+
+	// downloadsDir is the subdirectory of the cache directory holding downloaded artifacts.
+	downloadsDir = "circleci"
+)
+
+// ErrNoArtifactsMatched is returned by Source.Load when no artifact of the workflow matches the glob.
+var ErrNoArtifactsMatched = errors.New("no artifacts matched the provided pattern")
+
+// FileLoader parses local JUnit XML reports, like junit.Parser.LoadFiles.
+type FileLoader func(ctx context.Context, paths []string) (map[string]float64, error)
+
+// Source loads test times from the JUnit artifacts of the latest successful workflow on a branch.
+type Source struct {
+	client   *Client
+	load     FileLoader
+	slug     string
+	branch   string
+	glob     string
+	cacheDir string
+}
+
+// SourceOption configures a Source.
+type SourceOption func(*Source)
+
+// WithBranch sets the branch whose latest successful workflow is used. An empty branch keeps the default.
+func WithBranch(branch string) SourceOption {
+	return func(s *Source) {
+		if branch != "" {
+			s.branch = branch
+		}
+	}
+}
+
+// WithArtifactGlob sets the pattern artifact paths must match; see fileutil.MatchGlob. An empty glob keeps the default.
+func WithArtifactGlob(glob string) SourceOption {
+	return func(s *Source) {
+		if glob != "" {
+			s.glob = glob
+		}
+	}
+}
+
+// WithCacheDir keeps downloaded artifacts in dir, so the artifacts of a workflow are downloaded once.
+// An empty dir downloads to a temporary directory removed after loading.
+func WithCacheDir(dir string) SourceOption {
+	return func(s *Source) {
+		s.cacheDir = dir
+	}
+}
+
+// NewSource returns a source loading the artifacts of the project slug ("gh/org/repo")
+// with client, parsed with load.
+func NewSource(client *Client, slug string, load FileLoader, opts ...SourceOption) *Source {
+	s := &Source{
+		client: client,
+		load:   load,
+		slug:   slug,
+		branch: DefaultBranch,
+		glob:   DefaultArtifactGlob,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load finds the latest successful workflow, downloads its matching artifacts, and parses them.
+func (s *Source) Load(ctx context.Context) (map[string]float64, error) {
+	if s.slug == "" {
+		return nil, errors.New("no CircleCI project set (CIRCLE_PROJECT_USERNAME, CIRCLE_PROJECT_REPONAME)")
+	}
+	if _, err := path.Match(strings.ReplaceAll(s.glob, "**", "*"), ""); err != nil {
+		return nil, fmt.Errorf("invalid artifact glob %q: %w", s.glob, err)
+	}
+
+	wf, err := s.client.LatestSuccessfulWorkflow(ctx, s.slug, s.branch)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := s.client.WorkflowArtifacts(ctx, s.slug, wf.ID)
+	if err != nil {
+		return nil, err
+	}
+	var matched []Artifact
+	for _, artifact := range artifacts {
+		if fileutil.MatchGlob(s.glob, artifact.Path) {
+			matched = append(matched, artifact)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("%w: %s in workflow %s (%d artifacts)",
+			ErrNoArtifactsMatched, s.glob, wf.ID, len(artifacts))
+	}
+
+	dir := filepath.Join(s.cacheDir, downloadsDir, wf.ID)
+	if s.cacheDir == "" {
+		if dir, err = os.MkdirTemp("", "tests-helper-circleci-*"); err != nil {
+			return nil, fmt.Errorf("cannot create download directory: %w", err)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+	}
+
+	paths := make([]string, 0, len(matched))
+	for _, artifact := range matched {
+		file, downloadErr := s.download(ctx, artifact, dir)
+		if downloadErr != nil {
+			return nil, downloadErr
+		}
+		paths = append(paths, file)
+	}
+	return s.load(ctx, paths)
+}
+
+// Describe returns "circleci:" followed by the project slug and branch.
+func (s *Source) Describe() string {
+	return "circleci:" + s.slug + "@" + s.branch
+}
+
+// download stores artifact beneath dir and returns its path.
+// Artifacts of a workflow never change, so a file already present is reused.
+func (s *Source) download(ctx context.Context, artifact Artifact, dir string) (string, error) {
+	// Cleaning a rooted path drops any ".." that would escape dir
+	rel := filepath.FromSlash(path.Clean("/" + artifact.Path))
+	file := filepath.Join(dir, strconv.Itoa(artifact.NodeIndex), rel)
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+
+	data, err := s.client.Download(ctx, artifact)
+	if err != nil {
+		return "", err
+	}
+	if err = fileutil.WriteAtomic(file, data); err != nil {
+		return "", fmt.Errorf("cannot store artifact %s: %w", artifact.Path, err)
+	}
+	return file, nil
+}
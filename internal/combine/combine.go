@@ -0,0 +1,164 @@
+// Package combine compares the actual test times of a finished run with its split plan
+// and folds them into a timing store.
+package combine
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+const (
+	DefaultAlpha = 0.3 // Default weight of a new actual time in the moving average
+	DefaultTop   = 10  // Default number of most-changed files listed in a report
+)
+
+// Report compares the plan of a run with the times its tests actually took.
+type Report struct {
+	Workers []WorkerResult `json:"workers"`
+	// Changed lists the planned files whose actual time differs most from the prediction.
+	Changed []FileResult `json:"changed"`
+	// Predicted and Actual sum every worker's time.
+	Predicted float64 `json:"predicted_total"`
+	Actual    float64 `json:"actual_total"`
+	// MeanAbsErrorPercent averages the workers' absolute prediction errors.
+	MeanAbsErrorPercent float64 `json:"mean_abs_error_percent"`
+	// FileErrorPercent is the mean absolute percentage error (MAPE) of the planned files'
+	// predicted times. A file without an actual time counts as a 100% error, so renamed paths or
+	// the reports of another run lower the stability instead of going unnoticed.
+	FileErrorPercent float64 `json:"file_mape_percent"`
+	// Stability is 100 minus FileErrorPercent, floored at 0: 100 when every file took exactly its
+	// predicted time.
+	Stability float64 `json:"stability"`
+	// PreviousStability is the stability of the last run recorded in a History, if any.
+	PreviousStability *float64 `json:"previous_stability,omitempty"`
+	// Missing counts planned tests without an actual time; Unplanned counts actual times
+	// of tests that were not in the plan.
+	Missing   int `json:"missing"`
+	Unplanned int `json:"unplanned"`
+}
+
+// WorkerResult compares one worker's predicted and actual time. Tests without an actual time
+// count with their predicted time, so a missing report does not look like a fast shard.
+type WorkerResult struct {
+	Index        int     `json:"index"`
+	Tests        int     `json:"tests"`
+	Missing      int     `json:"missing"`
+	Predicted    float64 `json:"predicted"`
+	Actual       float64 `json:"actual"`
+	ErrorPercent float64 `json:"error_percent"`
+}
+
+// FileResult compares a planned test's predicted and actual time.
+type FileResult struct {
+	Name      string  `json:"name"`
+	Worker    int     `json:"worker"`
+	Predicted float64 `json:"predicted"`
+	Actual    float64 `json:"actual"`
+	Change    float64 `json:"change"` // Actual minus Predicted
+}
+
+// Compare matches the tests of m with actual times, keyed by key(name), and
+// keeps the top files whose time changed most.
+func Compare(m manifest.Manifest, actual map[string]float64, key func(string) string, top int) Report {
+	var r Report
+	var files []FileResult
+	var fileErrs fileErrors
+	planned := make(map[string]bool)
+	for i, group := range m.Groups {
+		w := WorkerResult{Index: i, Tests: len(group.Tests)}
+		for _, test := range group.Tests {
+			k := key(test.Name)
+			planned[k] = true
+			w.Predicted += test.Time
+			got, ok := actual[k]
+			fileErrs.add(test.Time, got, ok)
+			if !ok {
+				w.Missing++
+				w.Actual += test.Time
+				continue
+			}
+			w.Actual += got
+			files = append(files, FileResult{
+				Name: test.Name, Worker: i, Predicted: test.Time, Actual: got, Change: got - test.Time,
+			})
+		}
+		w.ErrorPercent = errorPercent(w.Predicted, w.Actual)
+		r.Workers = append(r.Workers, w)
+		r.Predicted += w.Predicted
+		r.Actual += w.Actual
+		r.Missing += w.Missing
+		r.MeanAbsErrorPercent += math.Abs(w.ErrorPercent) / float64(len(m.Groups))
+	}
+	for k := range actual {
+		if !planned[k] {
+			r.Unplanned++
+		}
+	}
+	r.FileErrorPercent = fileErrs.mean()
+	r.Stability = max(worker.Percent-r.FileErrorPercent, 0)
+
+	slices.SortStableFunc(files, func(a, b FileResult) int {
+		return cmp.Or(cmp.Compare(math.Abs(b.Change), math.Abs(a.Change)), cmp.Compare(a.Name, b.Name))
+	})
+	r.Changed = files[:min(len(files), max(top, 0))]
+	return r
+}
+
+// fileErrors accumulates the absolute percentage errors of files predicted to take some time.
+type fileErrors struct {
+	sum   float64
+	files int
+}
+
+// add records a file predicted to take predicted seconds, which took actual seconds when measured.
+func (e *fileErrors) add(predicted, actual float64, measured bool) {
+	if predicted <= 0 {
+		return
+	}
+	e.files++
+	if !measured {
+		e.sum += worker.Percent
+		return
+	}
+	e.sum += math.Abs(errorPercent(predicted, actual))
+}
+
+// mean returns the mean absolute percentage error, 0 without files.
+func (e *fileErrors) mean() float64 {
+	if e.files == 0 {
+		return 0
+	}
+	return e.sum / float64(e.files)
+}
+
+// errorPercent returns how far actual is from predicted, relative to predicted.
+func errorPercent(predicted, actual float64) float64 {
+	if predicted <= 0 {
+		return 0
+	}
+	return (actual - predicted) / predicted * worker.Percent
+}
+
+// MergeEMA folds actual times into previous with an exponential moving average:
+// each measured test becomes alpha*actual + (1-alpha)*previous. Tests without a previous
+// time take their actual time, and unmeasured entries are kept. An alpha outside (0, 1] is
+// treated as 1, replacing previous times.
+func MergeEMA(previous, actual map[string]float64, alpha float64) map[string]float64 {
+	if alpha <= 0 || alpha > 1 {
+		alpha = 1
+	}
+	merged := make(map[string]float64, len(previous)+len(actual))
+	maps.Copy(merged, previous)
+	for k, v := range actual {
+		if old, ok := previous[k]; ok {
+			v = alpha*v + (1-alpha)*old
+		}
+		merged[k] = v
+	}
+	return merged
+}
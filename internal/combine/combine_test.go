@@ -0,0 +1,216 @@
+package combine_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prgtw/tests-helper/internal/combine"
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/normalize"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// testPlan predicts 10s for worker 0 and 10s for worker 1.
+func testPlan() manifest.Manifest {
+	return manifest.Manifest{Groups: []worker.Worker{
+		{Total: 10, Tests: []junit.Test{{Name: "./a_test.go", Time: 6}, {Name: "b_test.go", Time: 4}}},
+		{Total: 10, Tests: []junit.Test{{Name: "c_test.go", Time: 10}}},
+	}}
+}
+
+func TestCompare(t *testing.T) {
+	actual := map[string]float64{"a_test.go": 9, "c_test.go": 8, "new_test.go": 1}
+
+	report := combine.Compare(testPlan(), actual, normalize.New().Key, 1)
+
+	// Worker 0: a took 9 instead of 6, b is missing and counts with its prediction
+	w0, w1 := report.Workers[0], report.Workers[1]
+	if w0.Predicted != 10 || w0.Actual != 13 || w0.Missing != 1 || w0.ErrorPercent != 30 {
+		t.Errorf("Worker 0 = %+v", w0)
+	}
+	if w1.Actual != 8 || w1.ErrorPercent != -20 {
+		t.Errorf("Worker 1 = %+v", w1)
+	}
+	if report.Predicted != 20 || report.Actual != 21 || report.Missing != 1 || report.Unplanned != 1 {
+		t.Errorf("Report = %+v", report)
+	}
+	if math.Abs(report.MeanAbsErrorPercent-25) > 1e-9 {
+		t.Errorf("MeanAbsErrorPercent = %v, want 25", report.MeanAbsErrorPercent)
+	}
+	if len(report.Changed) != 1 || report.Changed[0].Name != "./a_test.go" || report.Changed[0].Change != 3 {
+		t.Errorf("Changed = %+v, want only a_test.go (+3s)", report.Changed)
+	}
+}
+
+func TestCompare_Stability(t *testing.T) {
+	// pair is a planned file's predicted time and its actual time, negative when not measured
+	type pair struct{ predicted, actual float64 }
+
+	tests := []struct {
+		name      string
+		pairs     []pair
+		wantMAPE  float64
+		wantScore float64
+	}{
+		{name: "exact predictions", pairs: []pair{{10, 10}, {2, 2}}, wantMAPE: 0, wantScore: 100},
+		{name: "mixed errors", pairs: []pair{{10, 12}, {4, 3}, {5, 5}}, wantMAPE: 15, wantScore: 85},
+		{name: "missing file counts fully", pairs: []pair{{10, 10}, {10, -1}}, wantMAPE: 50, wantScore: 50},
+		{name: "floored at zero", pairs: []pair{{1, 4}}, wantMAPE: 300, wantScore: 0},
+		{name: "unpredicted files left out", pairs: []pair{{0, 5}, {2, 3}}, wantMAPE: 50, wantScore: 50},
+		{name: "nothing to compare", pairs: nil, wantMAPE: 0, wantScore: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var group worker.Worker
+			actual := make(map[string]float64)
+			for i, p := range tt.pairs {
+				name := fmt.Sprintf("f%d_test.go", i)
+				group.Tests = append(group.Tests, junit.Test{Name: name, Time: p.predicted})
+				if p.actual >= 0 {
+					actual[name] = p.actual
+				}
+			}
+			plan := manifest.Manifest{Groups: []worker.Worker{group}}
+
+			report := combine.Compare(plan, actual, normalize.New().Key, 0)
+			if math.Abs(report.FileErrorPercent-tt.wantMAPE) > 1e-9 || math.Abs(report.Stability-tt.wantScore) > 1e-9 {
+				t.Errorf("MAPE = %v, stability = %v, want %v and %v",
+					report.FileErrorPercent, report.Stability, tt.wantMAPE, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var h combine.History
+	if _, ok := h.Last(); ok {
+		t.Error("Empty history has a last run")
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		h = h.Add(combine.Run{Time: start.Add(time.Duration(i) * time.Hour), Stability: float64(90 + i)}, 3)
+	}
+	if len(h.Runs) != 3 || h.Runs[0].Stability != 91 {
+		t.Errorf("Runs = %+v, want the newest 3", h.Runs)
+	}
+
+	var buf bytes.Buffer
+	if err := h.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	read, err := combine.ReadHistory(&buf)
+	if err != nil {
+		t.Fatalf("ReadHistory failed: %v", err)
+	}
+	if last, ok := read.Last(); !ok || last.Stability != 93 || !last.Time.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("Last = %+v, %t, want the fourth run", last, ok)
+	}
+	if _, err = combine.ReadHistory(strings.NewReader("[1, 2]")); err == nil {
+		t.Error("Expected a malformed history to be rejected")
+	}
+}
+
+func TestMergeEMA(t *testing.T) {
+	previous := map[string]float64{"a": 10, "kept": 5}
+	merged := combine.MergeEMA(previous, map[string]float64{"a": 20, "new": 3}, 0.25)
+
+	want := map[string]float64{"a": 12.5, "kept": 5, "new": 3}
+	for k, v := range want {
+		if merged[k] != v {
+			t.Errorf("merged[%q] = %v, want %v", k, merged[k], v)
+		}
+	}
+	if len(merged) != len(want) || previous["a"] != 10 {
+		t.Errorf("Merged %v, previous %v", merged, previous)
+	}
+	if got := combine.MergeEMA(previous, map[string]float64{"a": 20}, 0)["a"]; got != 20 {
+		t.Errorf("Invalid alpha gave %v, want the actual time", got)
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	report := combine.Compare(testPlan(), map[string]float64{"a_test.go": 9, "b_test.go": 4, "c_test.go": 8},
+		normalize.New().Key, combine.DefaultTop)
+
+	var md bytes.Buffer
+	if err := report.WriteMarkdown(&md, duration.FormatSeconds); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, want := range []string{
+		"mean absolute error **25.0%**",
+		"Stability **76.7** of 100 (files off by 23.3% on average).",
+		"| 0 | 2 | 10.0s | 13.0s | +30.0% |",
+		"| `./a_test.go` | 0 | 6.0s | 9.0s | +3.0s |",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, md.String())
+		}
+	}
+
+	var human bytes.Buffer
+	if err := report.WriteMarkdown(&human, duration.FormatHuman); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, want := range []string{"| 0 | 2 | 10s | 13s | +30.0% |", "| `./a_test.go` | 0 | 6s | 9s | +3s |"} {
+		if !strings.Contains(human.String(), want) {
+			t.Errorf("Human Markdown lacks %q:\n%s", want, human.String())
+		}
+	}
+	if strings.Contains(md.String(), "no actual time") {
+		t.Errorf("Markdown mentions missing tests although none are:\n%s", md.String())
+	}
+
+	previous := 90.0
+	report.PreviousStability = &previous
+	md.Reset()
+	if err := report.WriteMarkdown(&md, duration.FormatSeconds); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	if want := "-13.3 since the previous run."; !strings.Contains(md.String(), want) {
+		t.Errorf("Markdown lacks %q:\n%s", want, md.String())
+	}
+
+	var js bytes.Buffer
+	if err := report.WriteJSON(&js); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded combine.Report
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded.Workers) != 2 ||
+		decoded.Actual != report.Actual {
+		t.Errorf("JSON round trip = %+v (%v)", decoded, err)
+	}
+}
+
+func TestReport_WriteJSONDeterministic(t *testing.T) {
+	// Every file changes by the same amount, so only names order the changed files
+	var tests []junit.Test
+	forward, backward := map[string]float64{}, map[string]float64{}
+	for i := range 20 {
+		tests = append(tests, junit.Test{Name: fmt.Sprintf("pkg/%02d_test.go", i), Time: float64(i)})
+		forward[fmt.Sprintf("pkg/%02d_test.go", i)] = float64(i + 1)
+		backward[fmt.Sprintf("pkg/%02d_test.go", 19-i)] = float64(20 - i)
+	}
+	plan := manifest.Manifest{Groups: []worker.Worker{{Tests: tests[:10]}, {Tests: tests[10:]}}}
+
+	write := func(actual map[string]float64) []byte {
+		var buf bytes.Buffer
+		if err := combine.Compare(plan, actual, normalize.New().Key, 5).WriteJSON(&buf); err != nil {
+			t.Fatalf("WriteJSON failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+	want := write(forward)
+	for range 10 {
+		if got := write(backward); !bytes.Equal(got, want) {
+			t.Fatalf("WriteJSON =\n%s\nwant\n%s", got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package combine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultHistory is the default number of runs kept in a History.
+const DefaultHistory = 50
+
+// Run is the stability of one combined run, as kept in a History.
+type Run struct {
+	Time             time.Time `json:"time"`
+	Stability        float64   `json:"stability"`
+	FileErrorPercent float64   `json:"file_mape_percent"`
+}
+
+// History is the stability of past runs, oldest first, to follow the score across runs.
+type History struct {
+	Runs []Run `json:"runs"`
+}
+
+// ReadHistory decodes a history written by History.Write.
+func ReadHistory(r io.Reader) (History, error) {
+	var h History
+	if err := json.NewDecoder(r).Decode(&h); err != nil {
+		return History{}, fmt.Errorf("cannot decode stability history: %w", err)
+	}
+	return h, nil
+}
+
+// Write encodes h as indented JSON.
+func (h History) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(h); err != nil {
+		return fmt.Errorf("cannot encode stability history: %w", err)
+	}
+	return nil
+}
+
+// Last returns the newest run, false when there is none.
+func (h History) Last() (Run, bool) {
+	if len(h.Runs) == 0 {
+		return Run{}, false
+	}
+	return h.Runs[len(h.Runs)-1], true
+}
+
+// Add returns h with run appended, keeping only the newest limit runs. A limit below 1 keeps
+// DefaultHistory runs.
+func (h History) Add(run Run, limit int) History {
+	if limit < 1 {
+		limit = DefaultHistory
+	}
+	runs := append(h.Runs[:len(h.Runs):len(h.Runs)], run)
+	return History{Runs: runs[max(len(runs)-limit, 0):]}
+}
+
+// Run returns the stability of r measured at t, to add to a History.
+func (r Report) Run(t time.Time) Run {
+	return Run{Time: t, Stability: r.Stability, FileErrorPercent: r.FileErrorPercent}
+}
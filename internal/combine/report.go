@@ -0,0 +1,64 @@
+package combine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/duration"
+)
+
+// WriteMarkdown renders r as a Markdown report with a table per worker and the most-changed files,
+// with durations rendered in the given format.
+func (r Report) WriteMarkdown(w io.Writer, durations duration.Format) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "## Test split report\n\n")
+	fmt.Fprintf(bw, "Predicted **%s**, actual **%s** across %d workers; mean absolute error **%.1f%%**.\n",
+		durations.Render(r.Predicted, 1), durations.Render(r.Actual, 1), len(r.Workers), r.MeanAbsErrorPercent)
+	fmt.Fprintf(bw, "\nStability **%.1f** of 100 (files off by %.1f%% on average)", r.Stability, r.FileErrorPercent)
+	if r.PreviousStability != nil {
+		fmt.Fprintf(bw, ", %+.1f since the previous run", r.Stability-*r.PreviousStability)
+	}
+	fmt.Fprintf(bw, ".\n")
+	if r.Missing > 0 || r.Unplanned > 0 {
+		fmt.Fprintf(bw, "\n%d planned tests had no actual time; %d measured tests were not in the plan.\n",
+			r.Missing, r.Unplanned)
+	}
+
+	fmt.Fprintf(bw, "\n| Worker | Tests | Predicted | Actual | Error |\n|---:|---:|---:|---:|---:|\n")
+	for _, ws := range r.Workers {
+		fmt.Fprintf(bw, "| %d | %d | %s | %s | %+.1f%% |\n",
+			ws.Index, ws.Tests, durations.Render(ws.Predicted, 1), durations.Render(ws.Actual, 1), ws.ErrorPercent)
+	}
+
+	if len(r.Changed) > 0 {
+		fmt.Fprintf(bw, "\n### Largest changes\n\n")
+		fmt.Fprintf(bw, "| File | Worker | Predicted | Actual | Change |\n|---|---:|---:|---:|---:|\n")
+		for _, f := range r.Changed {
+			fmt.Fprintf(bw, "| `%s` | %d | %s | %s | %s |\n", escapeCell(f.Name), f.Worker,
+				durations.Render(f.Predicted, 1), durations.Render(f.Actual, 1), durations.RenderSigned(f.Change, 1))
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON encodes r as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return nil
+}
+
+// escapeCell keeps a file name from breaking the table or its code span.
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "`", "'", "\n", " ").Replace(s)
+}
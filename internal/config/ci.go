@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// CI selects the CI provider whose variables supply the worker index and total.
+type CI string
+
+const (
+	CIAuto      CI = "auto"      // Detected from the marker variable of each provider (default)
+	CICircleCI  CI = "circleci"  // CIRCLE_NODE_INDEX and CIRCLE_NODE_TOTAL
+	CIGitHub    CI = "github"    // No variables of its own, see TESTS_HELPER_NODE_*
+	CIGitLab    CI = "gitlab"    // CI_NODE_INDEX, counting from 1, and CI_NODE_TOTAL
+	CIBuildkite CI = "buildkite" // BUILDKITE_PARALLEL_JOB and BUILDKITE_PARALLEL_JOB_COUNT
+	CISemaphore CI = "semaphore" // SEMAPHORE_JOB_INDEX, counting from 1, and SEMAPHORE_JOB_COUNT
+	CIJenkins   CI = "jenkins"   // No variables of its own, see TESTS_HELPER_NODE_*
+	CINone      CI = "none"      // Only the flags and TESTS_HELPER_NODE_*
+)
+
+// ParseCI validates a CI provider given on the command line.
+func ParseCI(s string) (CI, error) {
+	switch ci := CI(s); ci {
+	case CIAuto, CICircleCI, CIGitHub, CIGitLab, CIBuildkite, CISemaphore, CIJenkins, CINone:
+		return ci, nil
+	default:
+		return "", fmt.Errorf("unknown CI provider %q (must be %q, %q, %q, %q, %q, %q, %q, or %q)",
+			s, CIAuto, CICircleCI, CIGitHub, CIGitLab, CIBuildkite, CISemaphore, CIJenkins, CINone)
+	}
+}
+
+// genericProvider names the provider of the generic TESTS_HELPER_NODE_* variables.
+const genericProvider = "tests-helper"
+
+// provider describes the environment variables a CI provider uses for parallelism.
+type provider struct {
+	ci       CI // Empty for the generic variables
+	name     string
+	marker   string // Variable set on every job of the provider
+	indexVar string // Empty for providers without parallelism variables
+	totalVar string
+	detected func(*Config) bool
+	index    func(*Config) int
+	total    func(*Config) int
+	oneBased bool // The index counts from 1
+	// The variables are only read once the provider is detected or selected, as other tools
+	// use the same names differently
+	needsMarker bool
+}
+
+// providers lists the generic variables and the supported CI providers in order of precedence:
+// TESTS_HELPER_NODE_*, CircleCI, GitLab CI, Buildkite, Semaphore, then GitHub Actions and
+// Jenkins, which have no parallelism variables of their own. The index and the total are each
+// taken from the first of nodeProviders setting them.
+func providers() []provider {
+	unset := func(*Config) int { return Unset }
+	return []provider{
+		{
+			name:     genericProvider,
+			indexVar: "TESTS_HELPER_NODE_INDEX",
+			totalVar: "TESTS_HELPER_NODE_TOTAL",
+			index:    func(c *Config) int { return c.NodeIndex },
+			total:    func(c *Config) int { return c.NodeTotal },
+		},
+		{
+			ci:       CICircleCI,
+			name:     "CircleCI",
+			marker:   "CIRCLECI",
+			indexVar: "CIRCLE_NODE_INDEX",
+			totalVar: "CIRCLE_NODE_TOTAL",
+			detected: func(c *Config) bool { return c.CircleCI },
+			index:    func(c *Config) int { return c.CircleNodeIndex },
+			total:    func(c *Config) int { return c.CircleNodeTotal },
+		},
+		{
+			ci:          CIGitLab,
+			name:        "GitLab CI",
+			marker:      "GITLAB_CI",
+			indexVar:    "CI_NODE_INDEX",
+			totalVar:    "CI_NODE_TOTAL",
+			detected:    func(c *Config) bool { return c.GitLabCI },
+			index:       func(c *Config) int { return c.GitLabNodeIndex },
+			total:       func(c *Config) int { return c.GitLabNodeTotal },
+			oneBased:    true,
+			needsMarker: true,
+		},
+		{
+			ci:       CIBuildkite,
+			name:     "Buildkite",
+			marker:   "BUILDKITE",
+			indexVar: "BUILDKITE_PARALLEL_JOB",
+			totalVar: "BUILDKITE_PARALLEL_JOB_COUNT",
+			detected: func(c *Config) bool { return c.Buildkite },
+			index:    func(c *Config) int { return c.BuildkiteParallelJob },
+			total:    func(c *Config) int { return c.BuildkiteParallelJobCount },
+		},
+		{
+			ci:          CISemaphore,
+			name:        "Semaphore",
+			marker:      "SEMAPHORE",
+			indexVar:    "SEMAPHORE_JOB_INDEX",
+			totalVar:    "SEMAPHORE_JOB_COUNT",
+			detected:    func(c *Config) bool { return c.Semaphore },
+			index:       func(c *Config) int { return c.SemaphoreJobIndex },
+			total:       func(c *Config) int { return c.SemaphoreJobCount },
+			oneBased:    true,
+			needsMarker: true,
+		},
+		{
+			ci:       CIGitHub,
+			name:     "GitHub Actions",
+			marker:   "GITHUB_ACTIONS",
+			detected: func(c *Config) bool { return c.GitHubActions },
+			index:    unset,
+			total:    unset,
+		},
+		{
+			ci:       CIJenkins,
+			name:     "Jenkins",
+			marker:   "JENKINS_URL",
+			detected: func(c *Config) bool { return c.JenkinsURL != "" },
+			index:    unset,
+			total:    unset,
+		},
+	}
+}
+
+// DetectCI returns the CI provider selected by c.CI or, with CIAuto, the first provider in order
+// of precedence whose marker variable is set, e.g. CIRCLECI. It returns CINone when none is set.
+func (c *Config) DetectCI() CI {
+	if c.CI != "" && c.CI != CIAuto {
+		return c.CI
+	}
+	for _, p := range providers() {
+		if p.detected != nil && p.detected(c) {
+			return p.ci
+		}
+	}
+	return CINone
+}
+
+// Marker returns the variable by which DetectCI recognizes ci, e.g. "CIRCLECI", or "" for
+// CIAuto and CINone.
+func Marker(ci CI) string {
+	for _, p := range providers() {
+		if p.ci == ci {
+			return p.marker
+		}
+	}
+	return ""
+}
+
+// nodeProviders returns the providers whose variables may supply the worker index and total, in
+// order of precedence: the generic variables, then the provider DetectCI returns. With CIAuto and
+// no provider detected, or one without parallelism variables such as GitHub Actions, every
+// provider whose variables are read without its marker follows instead.
+func (c *Config) nodeProviders() []provider {
+	ci := c.DetectCI()
+	scan := (c.CI == "" || c.CI == CIAuto) && !hasNodeVars(ci)
+	return slices.DeleteFunc(providers(), func(p provider) bool {
+		switch {
+		case p.ci == "":
+			return false
+		case scan:
+			return p.needsMarker
+		}
+		return p.ci != ci
+	})
+}
+
+// hasNodeVars reports whether ci has parallelism variables of its own.
+func hasNodeVars(ci CI) bool {
+	return slices.ContainsFunc(providers(), func(p provider) bool {
+		return p.ci == ci && p.indexVar != ""
+	})
+}
@@ -0,0 +1,191 @@
+package config_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/config"
+)
+
+// clearCI unsets the marker variables of every CI provider for the duration of the test, so it
+// passes on any CI.
+func clearCI(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"CIRCLECI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "SEMAPHORE", "JENKINS_URL"} {
+		t.Setenv(name, "") // Restores the variable afterwards
+		if err := os.Unsetenv(name); err != nil {
+			t.Fatalf("Failed to unset %s: %v", name, err)
+		}
+	}
+}
+
+func TestParseCI(t *testing.T) {
+	for _, s := range []string{"auto", "circleci", "github", "gitlab", "buildkite", "semaphore", "jenkins", "none"} {
+		if ci, err := config.ParseCI(s); err != nil || string(ci) != s {
+			t.Errorf("ParseCI(%q) = %q, %v", s, ci, err)
+		}
+	}
+	_, err := config.ParseCI("travis")
+	if err == nil || !strings.Contains(err.Error(), `unknown CI provider "travis"`) {
+		t.Errorf("ParseCI(travis) error = %v, want unknown CI provider", err)
+	}
+}
+
+func TestConfig_DetectCI(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		ci          config.CI // --ci, auto when empty
+		want        config.CI
+		wantIndex   int
+		indexOrigin string
+		wantTotal   int
+		totalOrigin string
+	}{
+		{
+			name:      "CircleCI",
+			env:       map[string]string{"CIRCLECI": "true", "CIRCLE_NODE_INDEX": "1", "CIRCLE_NODE_TOTAL": "3"},
+			want:      config.CICircleCI,
+			wantIndex: 1, indexOrigin: "CIRCLE_NODE_INDEX",
+			wantTotal: 3, totalOrigin: "CIRCLE_NODE_TOTAL",
+		},
+		{
+			name: "GitHub Actions",
+			env: map[string]string{
+				"GITHUB_ACTIONS": "true", "TESTS_HELPER_NODE_INDEX": "2", "TESTS_HELPER_NODE_TOTAL": "4",
+			},
+			want:      config.CIGitHub,
+			wantIndex: 2, indexOrigin: "TESTS_HELPER_NODE_INDEX",
+			wantTotal: 4, totalOrigin: "TESTS_HELPER_NODE_TOTAL",
+		},
+		{
+			name:      "GitLab CI",
+			env:       map[string]string{"GITLAB_CI": "true", "CI_NODE_INDEX": "1", "CI_NODE_TOTAL": "2"},
+			want:      config.CIGitLab,
+			wantIndex: 0, indexOrigin: "CI_NODE_INDEX",
+			wantTotal: 2, totalOrigin: "CI_NODE_TOTAL",
+		},
+		{
+			name: "Buildkite",
+			env: map[string]string{
+				"BUILDKITE": "true", "BUILDKITE_PARALLEL_JOB": "0", "BUILDKITE_PARALLEL_JOB_COUNT": "2",
+			},
+			want:      config.CIBuildkite,
+			wantIndex: 0, indexOrigin: "BUILDKITE_PARALLEL_JOB",
+			wantTotal: 2, totalOrigin: "BUILDKITE_PARALLEL_JOB_COUNT",
+		},
+		{
+			name:      "Semaphore",
+			env:       map[string]string{"SEMAPHORE": "true", "SEMAPHORE_JOB_INDEX": "3", "SEMAPHORE_JOB_COUNT": "3"},
+			want:      config.CISemaphore,
+			wantIndex: 2, indexOrigin: "SEMAPHORE_JOB_INDEX",
+			wantTotal: 3, totalOrigin: "SEMAPHORE_JOB_COUNT",
+		},
+		{
+			name: "Jenkins",
+			env: map[string]string{
+				"JENKINS_URL":             "https://ci.example.com/",
+				"TESTS_HELPER_NODE_INDEX": "1", "TESTS_HELPER_NODE_TOTAL": "2",
+			},
+			want:      config.CIJenkins,
+			wantIndex: 1, indexOrigin: "TESTS_HELPER_NODE_INDEX",
+			wantTotal: 2, totalOrigin: "TESTS_HELPER_NODE_TOTAL",
+		},
+		{
+			name:      "none detected",
+			want:      config.CINone,
+			wantIndex: 0, indexOrigin: "default",
+			wantTotal: 1, totalOrigin: "default",
+		},
+		{
+			name: "detected provider ignores the others",
+			env: map[string]string{
+				"BUILDKITE": "true", "BUILDKITE_PARALLEL_JOB": "1", "BUILDKITE_PARALLEL_JOB_COUNT": "2",
+				"CIRCLE_NODE_INDEX": "3", "CIRCLE_NODE_TOTAL": "4",
+			},
+			want:      config.CIBuildkite,
+			wantIndex: 1, indexOrigin: "BUILDKITE_PARALLEL_JOB",
+			wantTotal: 2, totalOrigin: "BUILDKITE_PARALLEL_JOB_COUNT",
+		},
+		{
+			name:      "GitHub Actions scans the other providers",
+			env:       map[string]string{"GITHUB_ACTIONS": "true", "CIRCLE_NODE_INDEX": "1", "CIRCLE_NODE_TOTAL": "2"},
+			want:      config.CIGitHub,
+			wantIndex: 1, indexOrigin: "CIRCLE_NODE_INDEX",
+			wantTotal: 2, totalOrigin: "CIRCLE_NODE_TOTAL",
+		},
+		{
+			name:      "Semaphore variables without the marker",
+			env:       map[string]string{"SEMAPHORE_JOB_INDEX": "2", "SEMAPHORE_JOB_COUNT": "2"},
+			want:      config.CINone,
+			wantIndex: 0, indexOrigin: "default",
+			wantTotal: 1, totalOrigin: "default",
+		},
+		{
+			name:      "selected without the marker",
+			env:       map[string]string{"CI_NODE_INDEX": "2", "CI_NODE_TOTAL": "2"},
+			ci:        config.CIGitLab,
+			want:      config.CIGitLab,
+			wantIndex: 1, indexOrigin: "CI_NODE_INDEX",
+			wantTotal: 2, totalOrigin: "CI_NODE_TOTAL",
+		},
+		{
+			name:      "selected over the detected provider",
+			env:       map[string]string{"CIRCLECI": "true", "CIRCLE_NODE_INDEX": "1", "CIRCLE_NODE_TOTAL": "2"},
+			ci:        config.CIBuildkite,
+			want:      config.CIBuildkite,
+			wantIndex: 0, indexOrigin: "default",
+			wantTotal: 1, totalOrigin: "default",
+		},
+		{
+			name: "none selected",
+			env: map[string]string{
+				"CIRCLECI": "true", "CIRCLE_NODE_INDEX": "1", "CIRCLE_NODE_TOTAL": "2", "TESTS_HELPER_NODE_TOTAL": "3",
+			},
+			ci:        config.CINone,
+			want:      config.CINone,
+			wantIndex: 0, indexOrigin: "default",
+			wantTotal: 3, totalOrigin: "TESTS_HELPER_NODE_TOTAL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCI(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg := mustLoad(t)
+			cfg.CI = tt.ci
+
+			if got := cfg.DetectCI(); got != tt.want {
+				t.Errorf("DetectCI() = %q, want %q", got, tt.want)
+			}
+			index, total := cfg.ResolveNodeIndex(-1, 0), cfg.ResolveNodeTotal(-1, 1)
+			if index.Value != tt.wantIndex || index.Origin() != tt.indexOrigin {
+				t.Errorf("Index: got %d from %s, want %d from %s",
+					index.Value, index.Origin(), tt.wantIndex, tt.indexOrigin)
+			}
+			if total.Value != tt.wantTotal || total.Origin() != tt.totalOrigin {
+				t.Errorf("Total: got %d from %s, want %d from %s",
+					total.Value, total.Origin(), tt.wantTotal, tt.totalOrigin)
+			}
+			if err := config.ValidateNode(index, total); err != nil {
+				t.Errorf("ValidateNode failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestMarker(t *testing.T) {
+	for ci, want := range map[config.CI]string{
+		config.CICircleCI: "CIRCLECI", config.CIGitHub: "GITHUB_ACTIONS", config.CIGitLab: "GITLAB_CI",
+		config.CIBuildkite: "BUILDKITE", config.CISemaphore: "SEMAPHORE", config.CIJenkins: "JENKINS_URL",
+		config.CIAuto: "", config.CINone: "",
+	} {
+		if got := config.Marker(ci); got != want {
+			t.Errorf("Marker(%q) = %q, want %q", ci, got, want)
+		}
+	}
+}
@@ -4,13 +4,105 @@ import (
 	"fmt"
 
 	"github.com/caarlos0/env/v11"
+
+	"github.com/prgtw/tests-helper/internal/worker"
 )
 
+// Unset marks a flag or environment variable that was not provided.
+const Unset = -1
+
 // Config holds the application configuration.
 type Config struct {
+	// Bearer token required by the serve command, empty to disable auth
+	ServeToken string `env:"TESTS_HELPER_SERVE_TOKEN"`
+
+	// CircleCI API access for --stats-circleci-artifacts
+	CircleToken           string `env:"CIRCLE_TOKEN"`
+	CircleProjectUsername string `env:"CIRCLE_PROJECT_USERNAME"`
+	CircleProjectReponame string `env:"CIRCLE_PROJECT_REPONAME"`
+	CircleAPIURL          string `env:"TESTS_HELPER_CIRCLECI_API_URL"` // CircleCI server installations
+
+	// GitHub Actions context for --github-comment
+	GitHubToken      string `env:"GITHUB_TOKEN"`
+	GitHubRepository string `env:"GITHUB_REPOSITORY"` // owner/name
+	GitHubRef        string `env:"GITHUB_REF"`        // refs/pull/<number>/merge on pull requests
+	GitHubEventPath  string `env:"GITHUB_EVENT_PATH"` // Webhook payload of the triggering event
+	GitHubAPIURL     string `env:"GITHUB_API_URL"`    // GitHub Enterprise Server installations
+
+	// Generic worker index and total, e.g. exported from a GitHub Actions matrix; they take
+	// precedence over those of CI providers
+	NodeIndex int `env:"TESTS_HELPER_NODE_INDEX" envDefault:"-1"`
+	NodeTotal int `env:"TESTS_HELPER_NODE_TOTAL" envDefault:"-1"`
+
 	// CircleCI environment variables
 	CircleNodeIndex int `env:"CIRCLE_NODE_INDEX" envDefault:"-1"`
 	CircleNodeTotal int `env:"CIRCLE_NODE_TOTAL" envDefault:"-1"`
+
+	// GitLab CI environment variables; CI_NODE_INDEX counts from 1
+	GitLabNodeIndex int `env:"CI_NODE_INDEX" envDefault:"-1"`
+	GitLabNodeTotal int `env:"CI_NODE_TOTAL" envDefault:"-1"`
+
+	// Buildkite environment variables of parallel steps
+	BuildkiteParallelJob      int `env:"BUILDKITE_PARALLEL_JOB" envDefault:"-1"`
+	BuildkiteParallelJobCount int `env:"BUILDKITE_PARALLEL_JOB_COUNT" envDefault:"-1"`
+
+	// Semaphore environment variables of parallel jobs; SEMAPHORE_JOB_INDEX counts from 1
+	SemaphoreJobIndex int `env:"SEMAPHORE_JOB_INDEX" envDefault:"-1"`
+	SemaphoreJobCount int `env:"SEMAPHORE_JOB_COUNT" envDefault:"-1"`
+
+	// Marker variables identifying the CI provider, see DetectCI
+	CircleCI      bool   `env:"CIRCLECI"`
+	GitHubActions bool   `env:"GITHUB_ACTIONS"`
+	GitLabCI      bool   `env:"GITLAB_CI"`
+	Buildkite     bool   `env:"BUILDKITE"`
+	Semaphore     bool   `env:"SEMAPHORE"`
+	JenkinsURL    string `env:"JENKINS_URL"`
+
+	// CI provider supplying the worker index and total, set by --ci; CIAuto when empty
+	CI CI
+}
+
+// CircleProjectSlug returns the GitHub project slug ("gh/org/repo") of the CircleCI job,
+// or "" when not running on CircleCI.
+func (c *Config) CircleProjectSlug() string {
+	if c.CircleProjectUsername == "" || c.CircleProjectReponame == "" {
+		return ""
+	}
+	return "gh/" + c.CircleProjectUsername + "/" + c.CircleProjectReponame
+}
+
+// Source identifies where a resolved value came from.
+type Source int
+
+const (
+	SourceDefault Source = iota // Built-in default
+	SourceFlag                  // Command-line flag
+	SourceEnv                   // Environment variable
+)
+
+// Value is a resolved setting together with its provenance.
+type Value struct {
+	Name     string // Flag or environment variable name, empty for defaults
+	Provider string // CI provider supplying the environment variable
+	Value    int
+	Source   Source
+	OneBased bool // Given counting from 1; Value is converted to count from 0
+}
+
+// Origin describes where the value came from, e.g. "--total" or "CIRCLE_NODE_TOTAL".
+func (v Value) Origin() string {
+	if v.Source == SourceDefault {
+		return "default"
+	}
+	return v.Name
+}
+
+// given returns the value as it was given, counting from 1 when OneBased.
+func (v Value) given() int {
+	if v.OneBased {
+		return v.Value + 1
+	}
+	return v.Value
 }
 
 // Load loads configuration from environment variables.
@@ -24,22 +116,92 @@ func Load() (*Config, error) {
 
 // GetNodeIndex returns the node index, preferring flag value over env var.
 func (c *Config) GetNodeIndex(flagValue int, defaultValue int) int {
-	if flagValue >= 0 {
-		return flagValue
-	}
-	if c.CircleNodeIndex >= 0 {
-		return c.CircleNodeIndex
-	}
-	return defaultValue
+	return c.ResolveNodeIndex(flagValue, defaultValue).Value
 }
 
 // GetNodeTotal returns the total number of nodes, preferring flag value over env var.
+// The result is not validated; see ValidateTotal.
 func (c *Config) GetNodeTotal(flagValue int, defaultValue int) int {
-	if flagValue >= 0 {
-		return flagValue
+	return c.ResolveNodeTotal(flagValue, defaultValue).Value
+}
+
+// ResolveNodeIndex returns the node index and where it came from, preferring flag value over env var.
+func (c *Config) ResolveNodeIndex(flagValue int, defaultValue int) Value {
+	if flagValue != Unset {
+		return Value{Name: "--index", Value: flagValue, Source: SourceFlag}
 	}
-	if c.CircleNodeTotal >= 0 {
-		return c.CircleNodeTotal
+	for _, p := range c.nodeProviders() {
+		v := p.index(c)
+		switch {
+		case v == Unset:
+			continue
+		case p.oneBased:
+			return Value{Name: p.indexVar, Provider: p.name, Value: v - 1, Source: SourceEnv, OneBased: true}
+		}
+		return Value{Name: p.indexVar, Provider: p.name, Value: v, Source: SourceEnv}
+	}
+	return Value{Value: defaultValue, Source: SourceDefault}
+}
+
+// ResolveNodeTotal returns the total number of nodes and where it came from, preferring flag value over env var.
+func (c *Config) ResolveNodeTotal(flagValue int, defaultValue int) Value {
+	if flagValue != Unset {
+		return Value{Name: "--total", Value: flagValue, Source: SourceFlag}
+	}
+	for _, p := range c.nodeProviders() {
+		if v := p.total(c); v != Unset {
+			return Value{Name: p.totalVar, Provider: p.name, Value: v, Source: SourceEnv}
+		}
+	}
+	return Value{Value: defaultValue, Source: SourceDefault}
+}
+
+// FromOneBased converts an index given counting from 1, by --index or TESTS_HELPER_NODE_INDEX,
+// to count from 0. Indexes of CI providers, whose base is known, and defaults are returned as
+// they are.
+func FromOneBased(index Value) Value {
+	if index.OneBased || (index.Source != SourceFlag && index.Provider != genericProvider) {
+		return index
+	}
+	index.Value--
+	index.OneBased = true
+	return index
+}
+
+// ValidateTotal checks that a resolved total describes at least one worker.
+// Failures wrap worker.ErrInvalidWorkerCount.
+func ValidateTotal(total Value) error {
+	if total.Value < 1 {
+		return fmt.Errorf("%w: must be at least 1 (got %d from %s)",
+			worker.ErrInvalidWorkerCount, total.Value, total.Origin())
+	}
+	return nil
+}
+
+// ValidateNode checks that a resolved index and total describe a valid worker,
+// explaining half-configured environments where only the index variable is set.
+// Failures wrap worker.ErrInvalidWorkerCount or worker.ErrInvalidWorkerIndex.
+func ValidateNode(index, total Value) error {
+	if err := ValidateTotal(total); err != nil {
+		return err
+	}
+
+	if index.Source == SourceEnv && total.Source == SourceDefault {
+		for _, p := range providers() {
+			if p.name == index.Provider {
+				return fmt.Errorf("%w: %s is set to %d but %s is missing: set %s or pass --total",
+					worker.ErrInvalidWorkerCount, index.Name, index.given(), p.totalVar, p.totalVar)
+			}
+		}
+	}
+
+	switch {
+	case index.Value >= 0 && index.Value < total.Value:
+		return nil
+	case index.OneBased:
+		return fmt.Errorf("%w: %d from %s (counting from 1, must be between 1 and %d)",
+			worker.ErrInvalidWorkerIndex, index.given(), index.Origin(), total.Value)
 	}
-	return defaultValue
+	return fmt.Errorf("%w: %d from %s (must be between 0 and %d)",
+		worker.ErrInvalidWorkerIndex, index.Value, index.Origin(), total.Value-1)
 }
@@ -1,9 +1,14 @@
 package config_test
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/prgtw/tests-helper/internal/config"
+	"github.com/prgtw/tests-helper/internal/worker"
 )
 
 func TestLoad(t *testing.T) {
@@ -187,3 +192,420 @@ func TestConfig_Integration(t *testing.T) {
 		t.Errorf("Total from CLI override: got %d, want 8", totalOverride)
 	}
 }
+
+func TestConfig_ResolveProvenance(t *testing.T) {
+	t.Setenv("CIRCLE_NODE_INDEX", "2")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	index := cfg.ResolveNodeIndex(-1, 0)
+	if index.Source != config.SourceEnv || index.Origin() != "CIRCLE_NODE_INDEX" || index.Value != 2 {
+		t.Errorf("Index: got %+v, want 2 from CIRCLE_NODE_INDEX", index)
+	}
+
+	total := cfg.ResolveNodeTotal(-1, 1)
+	if total.Source != config.SourceDefault || total.Origin() != "default" || total.Value != 1 {
+		t.Errorf("Total: got %+v, want default 1", total)
+	}
+
+	flagged := cfg.ResolveNodeTotal(3, 1)
+	if flagged.Source != config.SourceFlag || flagged.Origin() != "--total" {
+		t.Errorf("Total from flag: got %+v, want --total", flagged)
+	}
+}
+
+func TestConfig_ResolvePrecedence(t *testing.T) {
+	both := map[string]string{
+		"TESTS_HELPER_NODE_INDEX": "1", "TESTS_HELPER_NODE_TOTAL": "3",
+		"CIRCLE_NODE_INDEX": "2", "CIRCLE_NODE_TOTAL": "4",
+	}
+	tests := []struct {
+		name       string
+		env        map[string]string
+		flag       int
+		want       int
+		wantOrigin string
+	}{
+		{name: "flag over every variable", env: both, flag: 0, want: 0, wantOrigin: "--index"},
+		{name: "generic over provider", env: both, flag: -1, want: 1, wantOrigin: "TESTS_HELPER_NODE_INDEX"},
+		{
+			name:       "provider without generic",
+			env:        map[string]string{"CIRCLE_NODE_INDEX": "2", "CIRCLE_NODE_TOTAL": "4"},
+			flag:       -1,
+			want:       2,
+			wantOrigin: "CIRCLE_NODE_INDEX",
+		},
+		{name: "default without any", flag: -1, want: 0, wantOrigin: "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg := mustLoad(t)
+
+			index := cfg.ResolveNodeIndex(tt.flag, 0)
+			if index.Value != tt.want || index.Origin() != tt.wantOrigin {
+				t.Errorf("Index: got %d from %s, want %d from %s", index.Value, index.Origin(), tt.want, tt.wantOrigin)
+			}
+		})
+	}
+
+	t.Run("total", func(t *testing.T) {
+		for k, v := range both {
+			t.Setenv(k, v)
+		}
+		cfg := mustLoad(t)
+		if total := cfg.ResolveNodeTotal(-1, 1); total.Value != 3 || total.Origin() != "TESTS_HELPER_NODE_TOTAL" {
+			t.Errorf("Total: got %d from %s, want 3 from TESTS_HELPER_NODE_TOTAL", total.Value, total.Origin())
+		}
+		if total := cfg.GetNodeTotal(5, 1); total != 5 {
+			t.Errorf("Total from flag: got %d, want 5", total)
+		}
+	})
+}
+
+func TestConfig_GitLab(t *testing.T) {
+	for node := 1; node <= 4; node++ {
+		t.Run(fmt.Sprintf("CI_NODE_INDEX=%d", node), func(t *testing.T) {
+			t.Setenv("GITLAB_CI", "true")
+			t.Setenv("CI_NODE_INDEX", strconv.Itoa(node))
+			t.Setenv("CI_NODE_TOTAL", "4")
+			cfg := mustLoad(t)
+
+			index, total := cfg.ResolveNodeIndex(-1, 0), cfg.ResolveNodeTotal(-1, 1)
+			if index.Value != node-1 || index.Origin() != "CI_NODE_INDEX" || !index.OneBased {
+				t.Errorf("Index: got %+v, want %d from CI_NODE_INDEX", index, node-1)
+			}
+			if total.Value != 4 || total.Origin() != "CI_NODE_TOTAL" {
+				t.Errorf("Total: got %+v, want 4 from CI_NODE_TOTAL", total)
+			}
+			if err := config.ValidateNode(index, total); err != nil {
+				t.Errorf("ValidateNode failed: %v", err)
+			}
+		})
+	}
+
+	t.Run("CI_NODE_INDEX=0", func(t *testing.T) {
+		t.Setenv("GITLAB_CI", "true")
+		t.Setenv("CI_NODE_INDEX", "0")
+		t.Setenv("CI_NODE_TOTAL", "4")
+		cfg := mustLoad(t)
+
+		err := config.ValidateNode(cfg.ResolveNodeIndex(-1, 0), cfg.ResolveNodeTotal(-1, 1))
+		want := "0 from CI_NODE_INDEX (counting from 1, must be between 1 and 4)"
+		if !errors.Is(err, worker.ErrInvalidWorkerIndex) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Error: got %v, want containing %q", err, want)
+		}
+	})
+
+	t.Run("outside GitLab", func(t *testing.T) {
+		clearCI(t)
+		t.Setenv("CI_NODE_INDEX", "2")
+		t.Setenv("CI_NODE_TOTAL", "4")
+		cfg := mustLoad(t)
+
+		if index := cfg.ResolveNodeIndex(-1, 0); index.Source != config.SourceDefault {
+			t.Errorf("Index: got %+v, want the default without GITLAB_CI", index)
+		}
+	})
+}
+
+func TestConfig_Buildkite(t *testing.T) {
+	buildkite := map[string]string{"BUILDKITE_PARALLEL_JOB": "0", "BUILDKITE_PARALLEL_JOB_COUNT": "3"}
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantIndex   int
+		indexOrigin string
+		wantTotal   int
+		totalOrigin string
+	}{
+		{
+			name:      "alone",
+			env:       buildkite,
+			wantIndex: 0, indexOrigin: "BUILDKITE_PARALLEL_JOB",
+			wantTotal: 3, totalOrigin: "BUILDKITE_PARALLEL_JOB_COUNT",
+		},
+		{
+			name:      "with CircleCI",
+			env:       map[string]string{"CIRCLE_NODE_INDEX": "1", "CIRCLE_NODE_TOTAL": "2"},
+			wantIndex: 1, indexOrigin: "CIRCLE_NODE_INDEX",
+			wantTotal: 2, totalOrigin: "CIRCLE_NODE_TOTAL",
+		},
+		{
+			name:      "with GitLab CI",
+			env:       map[string]string{"GITLAB_CI": "true", "CI_NODE_INDEX": "2", "CI_NODE_TOTAL": "4"},
+			wantIndex: 1, indexOrigin: "CI_NODE_INDEX",
+			wantTotal: 4, totalOrigin: "CI_NODE_TOTAL",
+		},
+		{
+			name:      "with CI_NODE_* outside GitLab",
+			env:       map[string]string{"CI_NODE_INDEX": "2", "CI_NODE_TOTAL": "4"},
+			wantIndex: 0, indexOrigin: "BUILDKITE_PARALLEL_JOB",
+			wantTotal: 3, totalOrigin: "BUILDKITE_PARALLEL_JOB_COUNT",
+		},
+		{
+			name:      "with the generic index",
+			env:       map[string]string{"TESTS_HELPER_NODE_INDEX": "2"},
+			wantIndex: 2, indexOrigin: "TESTS_HELPER_NODE_INDEX",
+			wantTotal: 3, totalOrigin: "BUILDKITE_PARALLEL_JOB_COUNT",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCI(t)
+			for k, v := range buildkite {
+				t.Setenv(k, v)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg := mustLoad(t)
+
+			index, total := cfg.ResolveNodeIndex(-1, 0), cfg.ResolveNodeTotal(-1, 1)
+			if index.Value != tt.wantIndex || index.Origin() != tt.indexOrigin {
+				t.Errorf("Index: got %d from %s, want %d from %s",
+					index.Value, index.Origin(), tt.wantIndex, tt.indexOrigin)
+			}
+			if total.Value != tt.wantTotal || total.Origin() != tt.totalOrigin {
+				t.Errorf("Total: got %d from %s, want %d from %s",
+					total.Value, total.Origin(), tt.wantTotal, tt.totalOrigin)
+			}
+			if err := config.ValidateNode(index, total); err != nil {
+				t.Errorf("ValidateNode failed: %v", err)
+			}
+		})
+	}
+
+	t.Run("index without count", func(t *testing.T) {
+		clearCI(t)
+		t.Setenv("BUILDKITE_PARALLEL_JOB", "1")
+		cfg := mustLoad(t)
+
+		err := config.ValidateNode(cfg.ResolveNodeIndex(-1, 0), cfg.ResolveNodeTotal(-1, 1))
+		want := "BUILDKITE_PARALLEL_JOB is set to 1 but BUILDKITE_PARALLEL_JOB_COUNT is missing"
+		if !errors.Is(err, worker.ErrInvalidWorkerCount) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Error: got %v, want containing %q", err, want)
+		}
+	})
+}
+
+func TestFromOneBased(t *testing.T) {
+	t.Setenv("TESTS_HELPER_NODE_INDEX", "3")
+	t.Setenv("CIRCLE_NODE_INDEX", "3")
+	cfg := mustLoad(t)
+	circle := config.Value{Name: "CIRCLE_NODE_INDEX", Provider: "CircleCI", Value: 3, Source: config.SourceEnv}
+
+	tests := []struct {
+		name  string
+		index config.Value
+		want  int
+	}{
+		{name: "flag", index: cfg.ResolveNodeIndex(4, 0), want: 3},
+		{name: "generic variable", index: cfg.ResolveNodeIndex(-1, 0), want: 2},
+		{name: "CircleCI", index: circle, want: 3},
+		{name: "default", index: config.Value{Value: 0, Source: config.SourceDefault}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := config.FromOneBased(tt.index); got.Value != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got.Value, tt.want)
+		}
+	}
+
+	converted := config.FromOneBased(config.FromOneBased(cfg.ResolveNodeIndex(4, 0)))
+	if converted.Value != 3 {
+		t.Errorf("Converting twice: got %d, want 3", converted.Value)
+	}
+	err := config.ValidateNode(config.FromOneBased(cfg.ResolveNodeIndex(5, 0)), cfg.ResolveNodeTotal(4, 1))
+	if err == nil || !strings.Contains(err.Error(), "5 from --index (counting from 1, must be between 1 and 4)") {
+		t.Errorf("Error: got %v, want the index as given", err)
+	}
+}
+
+func TestValidateNode(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantIs    error
+		indexFlag int
+		totalFlag int
+		wantErr   string
+	}{
+		{
+			name:      "flags within range",
+			indexFlag: 1,
+			totalFlag: 2,
+		},
+		{
+			name:      "CircleCI fully configured",
+			env:       map[string]string{"CIRCLE_NODE_INDEX": "2", "CIRCLE_NODE_TOTAL": "4"},
+			indexFlag: -1,
+			totalFlag: -1,
+		},
+		{
+			name:      "CircleCI index only",
+			env:       map[string]string{"CIRCLE_NODE_INDEX": "2"},
+			indexFlag: -1,
+			totalFlag: -1,
+			wantErr:   "CIRCLE_NODE_INDEX is set to 2 but CIRCLE_NODE_TOTAL is missing",
+			wantIs:    worker.ErrInvalidWorkerCount,
+		},
+		{
+			name:      "CircleCI index with total flag",
+			env:       map[string]string{"CIRCLE_NODE_INDEX": "2"},
+			indexFlag: -1,
+			totalFlag: 3,
+		},
+		{
+			name:      "generic index only",
+			env:       map[string]string{"TESTS_HELPER_NODE_INDEX": "1"},
+			indexFlag: -1,
+			totalFlag: -1,
+			wantErr:   "TESTS_HELPER_NODE_INDEX is set to 1 but TESTS_HELPER_NODE_TOTAL is missing",
+			wantIs:    worker.ErrInvalidWorkerCount,
+		},
+		{
+			name:      "generic index with CircleCI total",
+			env:       map[string]string{"TESTS_HELPER_NODE_INDEX": "1", "CIRCLE_NODE_TOTAL": "4"},
+			indexFlag: -1,
+			totalFlag: -1,
+		},
+		{
+			name:      "index out of range names its origin",
+			indexFlag: 5,
+			totalFlag: 3,
+			wantErr:   "invalid worker index: 5 from --index (must be between 0 and 2)",
+			wantIs:    worker.ErrInvalidWorkerIndex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			err = config.ValidateNode(cfg.ResolveNodeIndex(tt.indexFlag, 0), cfg.ResolveNodeTotal(tt.totalFlag, 1))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Error: got %v, want containing %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, tt.wantIs) {
+				t.Errorf("Error %v does not wrap %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestValidateTotal(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		totalFlag int
+		wantErr   string
+	}{
+		{
+			name:      "zero from flag",
+			totalFlag: 0,
+			wantErr:   "invalid worker count: must be at least 1 (got 0 from --total)",
+		},
+		{
+			name:      "negative from flag",
+			totalFlag: -4,
+			wantErr:   "invalid worker count: must be at least 1 (got -4 from --total)",
+		},
+		{
+			name:      "zero from env",
+			env:       map[string]string{"CIRCLE_NODE_TOTAL": "0"},
+			totalFlag: config.Unset,
+			wantErr:   "invalid worker count: must be at least 1 (got 0 from CIRCLE_NODE_TOTAL)",
+		},
+		{
+			name:      "negative from env",
+			env:       map[string]string{"CIRCLE_NODE_TOTAL": "-2"},
+			totalFlag: config.Unset,
+			wantErr:   "invalid worker count: must be at least 1 (got -2 from CIRCLE_NODE_TOTAL)",
+		},
+		{
+			name:      "one worker",
+			totalFlag: 1,
+		},
+		{
+			name:      "large total",
+			totalFlag: 10_000,
+		},
+		{
+			name:      "default",
+			totalFlag: config.Unset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			total := cfg.ResolveNodeTotal(tt.totalFlag, 1)
+			err = config.ValidateTotal(total)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Error: got %v, want %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, worker.ErrInvalidWorkerCount) {
+				t.Errorf("Error %v does not wrap ErrInvalidWorkerCount", err)
+			}
+
+			// ValidateNode reports the total before looking at the index
+			if err = config.ValidateNode(cfg.ResolveNodeIndex(0, 0), total); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ValidateNode error: got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_CircleProjectSlug(t *testing.T) {
+	t.Setenv("CIRCLE_PROJECT_USERNAME", "prgTW")
+	if got := mustLoad(t).CircleProjectSlug(); got != "" {
+		t.Errorf("Slug without CIRCLE_PROJECT_REPONAME = %q, want empty", got)
+	}
+
+	t.Setenv("CIRCLE_PROJECT_REPONAME", "tests-helper")
+	if got := mustLoad(t).CircleProjectSlug(); got != "gh/prgTW/tests-helper" {
+		t.Errorf("Slug = %q, want gh/prgTW/tests-helper", got)
+	}
+}
+
+func mustLoad(t *testing.T) *config.Config {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return cfg
+}
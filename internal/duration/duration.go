@@ -0,0 +1,53 @@
+// Package duration renders times in seconds for the human summary and Markdown reports, either as
+// fixed-point seconds or in hours, minutes, and seconds. Structured outputs keep raw seconds.
+package duration
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Format selects how durations are rendered.
+type Format string
+
+const (
+	FormatSeconds Format = "seconds" // Fixed-point seconds, e.g. "1873.421s" (default)
+	FormatHuman   Format = "human"   // Hours, minutes, and seconds, e.g. "31m13s" or "250ms"
+)
+
+// maxSeconds is the longest time FormatHuman renders; longer ones fall back to seconds.
+const maxSeconds = float64(math.MaxInt64 / int64(time.Second))
+
+// ParseFormat validates a duration format given on the command line.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatSeconds, FormatHuman:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown duration format %q (must be %q or %q)", s, FormatSeconds, FormatHuman)
+	}
+}
+
+// Render formats seconds. FormatSeconds, and the zero Format, print them with decimals digits
+// after the point. FormatHuman rounds times of a minute or more to the second, e.g. "31m13s",
+// and shorter ones to the millisecond, e.g. "12.345s" or "250ms".
+func (f Format) Render(seconds float64, decimals int) string {
+	if f != FormatHuman || math.IsNaN(seconds) || math.Abs(seconds) > maxSeconds {
+		return fmt.Sprintf("%.*fs", decimals, seconds)
+	}
+	d := time.Duration(seconds * float64(time.Second))
+	if d.Abs() >= time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// RenderSigned formats seconds like Render, with a leading "+" unless it is negative.
+func (f Format) RenderSigned(seconds float64, decimals int) string {
+	s := f.Render(seconds, decimals)
+	if s[0] == '-' {
+		return s
+	}
+	return "+" + s
+}
@@ -0,0 +1,71 @@
+package duration_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/duration"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []duration.Format{duration.FormatSeconds, duration.FormatHuman} {
+		got, err := duration.ParseFormat(string(f))
+		if err != nil || got != f {
+			t.Errorf("ParseFormat(%q) = %q, %v", f, got, err)
+		}
+	}
+	if _, err := duration.ParseFormat("minutes"); err == nil {
+		t.Error("Expected error for unknown duration format, got nil")
+	}
+}
+
+func TestFormat_Render(t *testing.T) {
+	tests := []struct {
+		seconds  float64
+		seconds3 string
+		human    string
+	}{
+		{0, "0.000s", "0s"},
+		{0.0004, "0.000s", "0s"},
+		{0.25, "0.250s", "250ms"},
+		{0.0015, "0.002s", "2ms"},
+		{1.5, "1.500s", "1.5s"},
+		{12.3456, "12.346s", "12.346s"},
+		{59.9996, "60.000s", "1m0s"},
+		{61.4, "61.400s", "1m1s"},
+		{1873.421, "1873.421s", "31m13s"},
+		{3600, "3600.000s", "1h0m0s"},
+		{-90.5, "-90.500s", "-1m31s"},
+		{math.Inf(1), "+Infs", "+Infs"},
+		{1e12, "1000000000000.000s", "1000000000000.000s"},
+	}
+	for _, tt := range tests {
+		if got := duration.FormatSeconds.Render(tt.seconds, 3); got != tt.seconds3 {
+			t.Errorf("FormatSeconds.Render(%v) = %q, want %q", tt.seconds, got, tt.seconds3)
+		}
+		if got := duration.FormatHuman.Render(tt.seconds, 3); got != tt.human {
+			t.Errorf("FormatHuman.Render(%v) = %q, want %q", tt.seconds, got, tt.human)
+		}
+	}
+	if got := duration.Format("").Render(1873.421, 1); got != "1873.4s" {
+		t.Errorf("Zero Format rendered %q, want seconds", got)
+	}
+}
+
+func TestFormat_RenderSigned(t *testing.T) {
+	for _, tt := range []struct {
+		format  duration.Format
+		seconds float64
+		want    string
+	}{
+		{duration.FormatSeconds, 3, "+3.0s"},
+		{duration.FormatSeconds, -0.04, "-0.0s"},
+		{duration.FormatSeconds, 0, "+0.0s"},
+		{duration.FormatHuman, 125, "+2m5s"},
+		{duration.FormatHuman, -0.5, "-500ms"},
+	} {
+		if got := tt.format.RenderSigned(tt.seconds, 1); got != tt.want {
+			t.Errorf("%s.RenderSigned(%v) = %q, want %q", tt.format, tt.seconds, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package fileutil_test
+
+import (
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob string
+		name string
+		want bool
+	}{
+		{glob: "**/junit*.xml", name: "junit.xml", want: true},
+		{glob: "**/junit*.xml", name: "test-results/unit/junit-1.xml", want: true},
+		{glob: "**/junit*.xml", name: "test-results/report.xml", want: false},
+		{glob: "test-results/*.xml", name: "test-results/junit.xml", want: true},
+		{glob: "test-results/*.xml", name: "test-results/unit/junit.xml", want: false},
+		{glob: "test-results/**", name: "test-results/unit/junit.xml", want: true},
+		{glob: "a/**/b/*.xml", name: "a/b/c.xml", want: true},
+		{glob: "a/**/b/*.xml", name: "a/x/y/b/c.xml", want: true},
+		{glob: "a/**/b/*.xml", name: "a/x/c.xml", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := fileutil.MatchGlob(tt.glob, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.glob, tt.name, got, tt.want)
+		}
+	}
+}
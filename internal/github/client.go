@@ -0,0 +1,155 @@
+// Package github upserts pull-request comments through the GitHub REST API.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+const (
+	DefaultBaseURL = "https://api.github.com" // Default API endpoint
+
+	// perPage is the page size requested when listing comments, the API maximum.
+	perPage = 100
+)
+
+var (
+	// ErrMissingToken is returned when no API token is configured.
+	ErrMissingToken = errors.New("no GitHub token set (GITHUB_TOKEN)")
+
+	nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+)
+
+// Comment is an issue or pull-request comment.
+type Comment struct {
+	Body string `json:"body"`
+	ID   int64  `json:"id"`
+}
+
+// Client calls the GitHub REST API.
+type Client struct {
+	http    *http.Client
+	baseURL string
+	token   string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sets the API endpoint, e.g. for GitHub Enterprise Server. An empty URL keeps the default.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests. A nil client keeps the default.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.http = hc
+		}
+	}
+}
+
+// NewClient creates a client authenticating with token.
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{http: http.DefaultClient, baseURL: DefaultBaseURL, token: token}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ListComments returns every comment of pull request pr in repo ("owner/name"), oldest first.
+func (c *Client) ListComments(ctx context.Context, repo string, pr int) ([]Comment, error) {
+	var comments []Comment
+	target := c.baseURL + "/repos/" + repo + "/issues/" + strconv.Itoa(pr) + "/comments?per_page=" +
+		strconv.Itoa(perPage)
+	for target != "" {
+		var page []Comment
+		next, err := c.do(ctx, http.MethodGet, target, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+		target = next
+	}
+	return comments, nil
+}
+
+// CreateComment adds a comment to pull request pr in repo.
+func (c *Client) CreateComment(ctx context.Context, repo string, pr int, body string) error {
+	target := c.baseURL + "/repos/" + repo + "/issues/" + strconv.Itoa(pr) + "/comments"
+	_, err := c.do(ctx, http.MethodPost, target, Comment{Body: body}, nil)
+	return err
+}
+
+// UpdateComment replaces the body of comment id in repo.
+func (c *Client) UpdateComment(ctx context.Context, repo string, id int64, body string) error {
+	target := c.baseURL + "/repos/" + repo + "/issues/comments/" + strconv.FormatInt(id, 10)
+	_, err := c.do(ctx, http.MethodPatch, target, Comment{Body: body}, nil)
+	return err
+}
+
+// DeleteComment removes comment id in repo.
+func (c *Client) DeleteComment(ctx context.Context, repo string, id int64) error {
+	target := c.baseURL + "/repos/" + repo + "/issues/comments/" + strconv.FormatInt(id, 10)
+	_, err := c.do(ctx, http.MethodDelete, target, nil, nil)
+	return err
+}
+
+// do sends an authenticated request with in encoded as JSON, decodes the response into out
+// when set, and returns the URL of the next page from the Link header.
+func (c *Client) do(ctx context.Context, method, target string, in, out any) (string, error) {
+	if c.token == "" {
+		return "", ErrMissingToken
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return "", fmt.Errorf("cannot encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return "", fmt.Errorf("cannot create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed: %w", method, req.URL.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return "", fmt.Errorf("%s %s failed: %s", method, req.URL.Path, resp.Status)
+	}
+	if out != nil {
+		if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return "", fmt.Errorf("cannot decode response of %s: %w", req.URL.Path, err)
+		}
+	}
+	if m := nextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		return m[1], nil
+	}
+	return "", nil
+}
@@ -0,0 +1,138 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// Marker identifies the summary comment, so later runs edit it instead of adding another.
+const Marker = "<!-- tests-helper:split-summary -->"
+
+// Comments is the part of the API Upsert needs. Client implements it.
+type Comments interface {
+	ListComments(ctx context.Context, repo string, pr int) ([]Comment, error)
+	CreateComment(ctx context.Context, repo string, pr int, body string) error
+	UpdateComment(ctx context.Context, repo string, id int64, body string) error
+	DeleteComment(ctx context.Context, repo string, id int64) error
+}
+
+// Action is what Upsert did.
+type Action string
+
+const (
+	Created   Action = "created"   // No marked comment existed
+	Updated   Action = "updated"   // The marked comment was edited
+	Unchanged Action = "unchanged" // The marked comment already had the body
+)
+
+// Upsert makes body, prefixed with Marker, the single marked comment of pull request pr:
+// it creates the comment, or edits the oldest marked one. Further marked comments, left by
+// runs that raced each other, are deleted. Only comments starting with Marker count as marked,
+// so a reply quoting the summary is left alone.
+func Upsert(ctx context.Context, api Comments, repo string, pr int, body string) (Action, error) {
+	body = Marker + "\n" + body
+
+	comments, err := api.ListComments(ctx, repo, pr)
+	if err != nil {
+		return "", err
+	}
+	var marked []Comment
+	for _, c := range comments {
+		if strings.HasPrefix(c.Body, Marker) {
+			marked = append(marked, c)
+		}
+	}
+	if len(marked) == 0 {
+		if err = api.CreateComment(ctx, repo, pr, body); err != nil {
+			return "", err
+		}
+		return Created, nil
+	}
+
+	for _, extra := range marked[1:] {
+		if err = api.DeleteComment(ctx, repo, extra.ID); err != nil {
+			return "", err
+		}
+	}
+	if marked[0].Body == body {
+		return Unchanged, nil
+	}
+	if err = api.UpdateComment(ctx, repo, marked[0].ID, body); err != nil {
+		return "", err
+	}
+	return Updated, nil
+}
+
+// PullRequestNumber returns the pull request of a GitHub Actions run: from a ref like
+// "refs/pull/123/merge", or else from the "pull_request" of the event payload at eventPath.
+// It returns 0 when the run is not for a pull request.
+func PullRequestNumber(ref, eventPath string) int {
+	if rest, ok := strings.CutPrefix(ref, "refs/pull/"); ok {
+		number, _, _ := strings.Cut(rest, "/")
+		if n, err := strconv.Atoi(number); err == nil && n > 0 {
+			return n
+		}
+	}
+	if eventPath == "" {
+		return 0
+	}
+	data, err := os.ReadFile(eventPath)
+	if err != nil {
+		return 0
+	}
+	var event struct {
+		PullRequest struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+	}
+	if json.Unmarshal(data, &event) != nil {
+		return 0
+	}
+	return event.PullRequest.Number
+}
+
+// Summary renders the distribution of a split as Markdown for a comment, with durations rendered
+// in the given format.
+func Summary(dist worker.Distribution, tests, untimed int, durations duration.Format) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Test split across %d workers\n\n", len(dist.Workers))
+	fmt.Fprintf(&b, "Predicted **%s** in total; the slowest worker takes **%s** "+
+		"(imbalance %.1f%%, efficiency %.1f%%).\n",
+		durations.Render(dist.TotalTime, 1), durations.Render(dist.MaxTotal(), 1),
+		dist.ImbalancePercent(), dist.EfficiencyPercent())
+	if untimed > 0 {
+		fmt.Fprintf(&b, "\n%d of %d tests have no timing data and use the default time.\n", untimed, tests)
+	}
+
+	rollup := slices.ContainsFunc(dist.Workers, func(ws worker.Stats) bool { return len(ws.Directories) > 0 })
+	if rollup {
+		b.WriteString("\n| Worker | Tests | Predicted | Top directories |\n|---:|---:|---:|---|\n")
+	} else {
+		b.WriteString("\n| Worker | Tests | Predicted |\n|---:|---:|---:|\n")
+	}
+	for _, ws := range dist.Workers {
+		fmt.Fprintf(&b, "| %d | %d | %s |", ws.Index, ws.TestCount, durations.Render(ws.Total, 1))
+		if rollup {
+			fmt.Fprintf(&b, " %s |", directories(ws.Directories, durations))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// directories renders the top directories of a worker for a table cell.
+func directories(dirs []worker.DirTime, durations duration.Format) string {
+	parts := make([]string, len(dirs))
+	for i, d := range dirs {
+		parts[i] = fmt.Sprintf("`%s` %s", strings.ReplaceAll(d.Dir, "|", `\|`), durations.Render(d.Time, 1))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,230 @@
+package github_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/github"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// fakeComments is an in-memory github.Comments recording the calls it receives.
+type fakeComments struct {
+	err      error
+	comments []github.Comment
+	calls    []string
+	nextID   int64
+}
+
+func (f *fakeComments) ListComments(context.Context, string, int) ([]github.Comment, error) {
+	f.calls = append(f.calls, "list")
+	return slices.Clone(f.comments), nil
+}
+
+func (f *fakeComments) CreateComment(_ context.Context, _ string, _ int, body string) error {
+	f.calls = append(f.calls, "create")
+	if f.err != nil {
+		return f.err
+	}
+	f.nextID++
+	f.comments = append(f.comments, github.Comment{ID: f.nextID, Body: body})
+	return nil
+}
+
+func (f *fakeComments) UpdateComment(_ context.Context, _ string, id int64, body string) error {
+	f.calls = append(f.calls, fmt.Sprintf("update %d", id))
+	for i := range f.comments {
+		if f.comments[i].ID == id {
+			f.comments[i].Body = body
+		}
+	}
+	return f.err
+}
+
+func (f *fakeComments) DeleteComment(_ context.Context, _ string, id int64) error {
+	f.calls = append(f.calls, fmt.Sprintf("delete %d", id))
+	f.comments = slices.DeleteFunc(f.comments, func(c github.Comment) bool { return c.ID == id })
+	return f.err
+}
+
+func TestUpsert(t *testing.T) {
+	api := &fakeComments{nextID: 10, comments: []github.Comment{{ID: 1, Body: "LGTM"}}}
+
+	action, err := github.Upsert(t.Context(), api, "org/repo", 7, "first")
+	if err != nil || action != github.Created {
+		t.Fatalf("First upsert = %q, %v; want created", action, err)
+	}
+	action, err = github.Upsert(t.Context(), api, "org/repo", 7, "second")
+	if err != nil || action != github.Updated {
+		t.Fatalf("Second upsert = %q, %v; want updated", action, err)
+	}
+	action, err = github.Upsert(t.Context(), api, "org/repo", 7, "second")
+	if err != nil || action != github.Unchanged {
+		t.Fatalf("Third upsert = %q, %v; want unchanged", action, err)
+	}
+
+	want := []string{"list", "create", "list", "update 11", "list"}
+	if !slices.Equal(api.calls, want) {
+		t.Errorf("Calls = %v, want %v", api.calls, want)
+	}
+	if len(api.comments) != 2 || api.comments[1].Body != github.Marker+"\nsecond" {
+		t.Errorf("Comments = %+v", api.comments)
+	}
+}
+
+func TestUpsert_MultipleMarkers(t *testing.T) {
+	api := &fakeComments{comments: []github.Comment{
+		{ID: 1, Body: github.Marker + "\nold"},
+		{ID: 2, Body: "unrelated"},
+		{ID: 3, Body: github.Marker + "\nduplicate"},
+		{ID: 4, Body: "quoted:\n> " + github.Marker},
+	}}
+
+	action, err := github.Upsert(t.Context(), api, "org/repo", 7, "new")
+	if err != nil || action != github.Updated {
+		t.Fatalf("Upsert = %q, %v; want updated", action, err)
+	}
+	// The oldest marked comment is kept, the duplicate is deleted, and the quote is not marked
+	if want := []string{"list", "delete 3", "update 1"}; !slices.Equal(api.calls, want) {
+		t.Errorf("Calls = %v, want %v", api.calls, want)
+	}
+	if len(api.comments) != 3 || api.comments[0].Body != github.Marker+"\nnew" || api.comments[2].ID != 4 {
+		t.Errorf("Comments = %+v", api.comments)
+	}
+
+	failing := &fakeComments{err: errors.New("forbidden")}
+	if _, err = github.Upsert(t.Context(), failing, "org/repo", 7, "new"); err == nil {
+		t.Error("Expected the create error")
+	}
+}
+
+func TestPullRequestNumber(t *testing.T) {
+	event := filepath.Join(t.TempDir(), "event.json")
+	payload := `{"action": "synchronize", "pull_request": {"number": 42}}`
+	if err := os.WriteFile(event, []byte(payload), 0o600); err != nil {
+		t.Fatalf("Failed to write event: %v", err)
+	}
+
+	tests := []struct {
+		ref   string
+		event string
+		want  int
+	}{
+		{ref: "refs/pull/17/merge", want: 17},
+		{ref: "refs/pull/17/merge", event: event, want: 17},
+		{ref: "refs/heads/main", event: event, want: 42},
+		{ref: "refs/heads/main", want: 0},
+		{ref: "refs/pull/x/merge", event: filepath.Join(t.TempDir(), "missing.json"), want: 0},
+	}
+	for _, tt := range tests {
+		if got := github.PullRequestNumber(tt.ref, tt.event); got != tt.want {
+			t.Errorf("PullRequestNumber(%q, %q) = %d, want %d", tt.ref, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	var requests []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("page") == "":
+			w.Header().Set("Link", `<`+server.URL+r.URL.Path+`?page=2>; rel="next", <x>; rel="last"`)
+			_ = json.NewEncoder(w).Encode([]github.Comment{{ID: 1, Body: "a"}})
+		case r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode([]github.Comment{{ID: 2, Body: "b"}})
+		case r.Method == http.MethodPost || r.Method == http.MethodPatch:
+			var c github.Comment
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil || c.Body != "hello" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}
+	}))
+	defer server.Close()
+	client := github.NewClient("secret", github.WithBaseURL(server.URL))
+
+	comments, err := client.ListComments(t.Context(), "org/repo", 7)
+	if err != nil || len(comments) != 2 || comments[1].ID != 2 {
+		t.Fatalf("ListComments = %+v, %v; want both pages", comments, err)
+	}
+	if err = client.CreateComment(t.Context(), "org/repo", 7, "hello"); err != nil {
+		t.Errorf("CreateComment failed: %v", err)
+	}
+	if err = client.UpdateComment(t.Context(), "org/repo", 2, "hello"); err != nil {
+		t.Errorf("UpdateComment failed: %v", err)
+	}
+	if err = client.DeleteComment(t.Context(), "org/repo", 2); err != nil {
+		t.Errorf("DeleteComment failed: %v", err)
+	}
+	want := []string{
+		"GET /repos/org/repo/issues/7/comments",
+		"GET /repos/org/repo/issues/7/comments",
+		"POST /repos/org/repo/issues/7/comments",
+		"PATCH /repos/org/repo/issues/comments/2",
+		"DELETE /repos/org/repo/issues/comments/2",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("Requests = %v, want %v", requests, want)
+	}
+
+	rejected := github.NewClient("wrong", github.WithBaseURL(server.URL))
+	if err = rejected.CreateComment(t.Context(), "o/r", 1, "x"); err == nil {
+		t.Error("Expected an error for a rejected token")
+	}
+	if _, err = github.NewClient("").ListComments(t.Context(), "o/r", 1); !errors.Is(err, github.ErrMissingToken) {
+		t.Errorf("Error = %v, want ErrMissingToken", err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	dist := worker.Distribution{
+		TotalTime: 30,
+		AvgTime:   15,
+		Workers:   []worker.Stats{{Index: 0, Total: 20, TestCount: 1}, {Index: 1, Total: 10, TestCount: 3}},
+	}
+	got := github.Summary(dist, 4, 1, duration.FormatSeconds)
+	for _, want := range []string{
+		"across 2 workers", "**30.0s** in total", "**20.0s**", "imbalance 33.3%", "1 of 4 tests", "| 1 | 3 | 10.0s |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Summary lacks %q:\n%s", want, got)
+		}
+	}
+
+	dist.TotalTime, dist.Workers[0].Total = 1883.421, 1873.421
+	got = github.Summary(dist, 4, 1, duration.FormatHuman)
+	for _, want := range []string{"**31m23s** in total", "**31m13s**", "| 1 | 3 | 10s |"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Human summary lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Top directories") {
+		t.Errorf("Summary without a rollup has a directories column:\n%s", got)
+	}
+
+	dist.Workers[1].Directories = []worker.DirTime{{Dir: "src/api", Time: 7, Tests: 2}, {Dir: "a|b", Time: 3, Tests: 1}}
+	got = github.Summary(dist, 4, 1, duration.FormatSeconds)
+	for _, want := range []string{
+		"| Predicted | Top directories |", "| 0 | 1 | 1873.4s |  |",
+		"| 1 | 3 | 10.0s | `src/api` 7.0s, `a\\|b` 3.0s |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Rolled up summary lacks %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package gotest reads package times from go test -json output, the event streams of test2json.
+package gotest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrNoFilesMatched is returned by Source.Load when no file matches the patterns.
+var ErrNoFilesMatched = errors.New("no go test -json files matched the provided patterns")
+
+// Result holds the package times read from one stream.
+type Result struct {
+	Times     map[string]float64 // Elapsed seconds keyed by package import path
+	Truncated int                // Packages without a final pass or fail event, timed from their other events
+	Skipped   int                // Lines that are not test2json events, such as build output
+}
+
+// event is one line of test2json output. Package-level events have no Test.
+type event struct {
+	Time        time.Time
+	Action      string
+	Package     string
+	Test        string
+	Elapsed     float64
+	FailedBuild string
+}
+
+// run tracks the events of one package until its final event.
+type run struct {
+	first time.Time
+	last  time.Time
+	tests float64 // Elapsed of the completed top-level tests
+}
+
+// elapsed estimates the time of a package whose final event is missing: the span of its events,
+// or the total of its completed tests when the events have no timestamps.
+func (r *run) elapsed() float64 {
+	if span := r.last.Sub(r.first).Seconds(); span > 0 {
+		return span
+	}
+	return r.tests
+}
+
+// Parse reads a test2json stream from r and returns the time of every package in it.
+//
+// Events of several packages may be interleaved, as go test -json writes them when packages run
+// in parallel. Each package is paired from its first event to its final pass or fail and timed by
+// the Elapsed of that final event; a package run several times in the stream gets the times added.
+// When the stream ends before a package finished, because the run was killed or timed out, the
+// package is timed from its first to its last event instead and counted as truncated. Packages
+// skipped for having no test files, packages that failed to build, and lines that are not events
+// are left out. Cancelling ctx stops reading and returns the context's error.
+func Parse(ctx context.Context, r io.Reader) (Result, error) {
+	res := Result{Times: make(map[string]float64)}
+	runs := make(map[string]*run)
+	br := bufio.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		line, readErr := br.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return Result{}, fmt.Errorf("cannot read go test output: %w", readErr)
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var ev event
+			if err := json.Unmarshal(line, &ev); err != nil {
+				res.Skipped++
+			} else {
+				res.add(runs, &ev)
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	for pkg, r := range runs {
+		if elapsed := r.elapsed(); elapsed > 0 {
+			res.Times[pkg] += elapsed
+			res.Truncated++
+		}
+	}
+	return res, nil
+}
+
+// add records ev in the run of its package, and the package time once ev ends the run.
+func (res *Result) add(runs map[string]*run, ev *event) {
+	if ev.Package == "" {
+		return
+	}
+	r, ok := runs[ev.Package]
+	if !ok {
+		r = &run{}
+		runs[ev.Package] = r
+	}
+	if !ev.Time.IsZero() {
+		if r.first.IsZero() {
+			r.first = ev.Time
+		}
+		r.last = ev.Time
+	}
+
+	if ev.Action != "pass" && ev.Action != "fail" && ev.Action != "skip" {
+		return
+	}
+	if ev.Test != "" {
+		if !strings.Contains(ev.Test, "/") {
+			r.tests += ev.Elapsed
+		}
+		return
+	}
+	delete(runs, ev.Package)
+	if ev.Action != "skip" && ev.FailedBuild == "" {
+		res.Times[ev.Package] += ev.Elapsed
+	}
+}
+
+// Source loads package times from files of go test -json output.
+type Source struct {
+	logger   zerolog.Logger
+	patterns []string
+}
+
+// NewSource returns a source reading the files matching the glob patterns.
+func NewSource(logger zerolog.Logger, patterns ...string) *Source {
+	return &Source{logger: logger, patterns: patterns}
+}
+
+// Load parses every matching file in sorted order and adds up the times of packages found in
+// several of them. It fails with ErrNoFilesMatched when no file matches, and on the first file
+// that cannot be read.
+func (s *Source) Load(ctx context.Context) (map[string]float64, error) {
+	files, err := s.expand()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFilesMatched
+	}
+
+	times := make(map[string]float64)
+	for _, file := range files {
+		res, err := parseFile(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		if res.Skipped > 0 {
+			s.logger.Debug().
+				Str("file", file).
+				Int("lines", res.Skipped).
+				Msg("Skipped lines that are not go test -json events")
+		}
+		if res.Truncated > 0 {
+			s.logger.Warn().
+				Str("file", file).
+				Int("packages", res.Truncated).
+				Msg("Output ends before some packages finished, timing them from their events so far")
+		}
+		for pkg, elapsed := range res.Times {
+			times[pkg] += elapsed
+		}
+	}
+	return times, nil
+}
+
+// Describe returns "gotest:" followed by the comma-separated patterns.
+func (s *Source) Describe() string {
+	return "gotest:" + strings.Join(s.patterns, ",")
+}
+
+// expand returns the files matching the patterns, sorted and without duplicates.
+func (s *Source) expand() ([]string, error) {
+	seen := make(map[string]struct{})
+	var files []string
+	for _, pattern := range s.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		for _, match := range matches {
+			if _, ok := seen[match]; !ok {
+				seen[match] = struct{}{}
+				files = append(files, match)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseFile parses the go test -json output in path.
+func parseFile(ctx context.Context, path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("cannot open go test output: %w", err)
+	}
+	defer f.Close()
+
+	res, err := Parse(ctx, f)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, nil
+}
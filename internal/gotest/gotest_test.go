@@ -0,0 +1,138 @@
+package gotest_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/gotest"
+)
+
+const testdata = "../../testdata/gotest/"
+
+func parseFixture(t *testing.T, name string) gotest.Result {
+	t.Helper()
+	f, err := os.Open(testdata + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	res, err := gotest.Parse(context.Background(), f)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return res
+}
+
+func assertTimes(t *testing.T, got, want map[string]float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for pkg, w := range want {
+		if g, ok := got[pkg]; !ok || math.Abs(g-w) > 1e-9 {
+			t.Errorf("%s = %v, want %v (all: %v)", pkg, g, w, got)
+		}
+	}
+}
+
+func TestParse_Interleaved(t *testing.T) {
+	res := parseFixture(t, "interleaved.jsonl")
+
+	// Skipped and broken packages are left out; subtests do not count twice.
+	assertTimes(t, res.Times, map[string]float64{
+		"example.com/app/api":    1.5,
+		"example.com/app/db":     3.25,
+		"example.com/app/worker": 0.75,
+	})
+	if res.Truncated != 0 {
+		t.Errorf("Truncated = %d, want 0", res.Truncated)
+	}
+	if res.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1 for the build error line", res.Skipped)
+	}
+}
+
+func TestParse_Truncated(t *testing.T) {
+	res := parseFixture(t, "truncated.jsonl")
+
+	assertTimes(t, res.Times, map[string]float64{
+		"example.com/app/api":  1.5,
+		"example.com/app/slow": 42.5,
+	})
+	if res.Truncated != 1 {
+		t.Errorf("Truncated = %d, want 1", res.Truncated)
+	}
+	if res.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1 for the cut-off last line", res.Skipped)
+	}
+}
+
+func TestParse_WithoutTimestamps(t *testing.T) {
+	input := strings.Join([]string{
+		`{"Action":"run","Package":"pkg/a","Test":"TestOne"}`,
+		`{"Action":"pass","Package":"pkg/a","Test":"TestOne","Elapsed":2}`,
+		`{"Action":"pass","Package":"pkg/a","Elapsed":2.5}`,
+		`{"Action":"pass","Package":"pkg/a","Elapsed":1.5}`,
+		`{"Action":"run","Package":"pkg/b","Test":"TestTwo"}`,
+		`{"Action":"pass","Package":"pkg/b","Test":"TestTwo/sub","Elapsed":1}`,
+		`{"Action":"pass","Package":"pkg/b","Test":"TestTwo","Elapsed":1.25}`,
+		`{"Action":"fail","Package":"pkg/b","Test":"TestThree","Elapsed":0.5}`,
+		`{"Action":"run","Package":"pkg/c","Test":"TestFour"}`,
+	}, "\n")
+
+	res, err := gotest.Parse(context.Background(), strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// Runs of one package add up; unfinished packages fall back to their completed tests,
+	// and a package with none is left out.
+	assertTimes(t, res.Times, map[string]float64{"pkg/a": 4, "pkg/b": 1.75})
+	if res.Truncated != 1 {
+		t.Errorf("Truncated = %d, want 1", res.Truncated)
+	}
+}
+
+func TestParse_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := gotest.Parse(ctx, strings.NewReader(`{"Action":"pass","Package":"pkg/a","Elapsed":1}`))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Parse error = %v, want context.Canceled", err)
+	}
+}
+
+func TestSource(t *testing.T) {
+	source := gotest.NewSource(zerolog.Nop(), testdata+"*.jsonl", testdata+"interleaved.jsonl")
+	if got, want := source.Describe(), "gotest:"+testdata+"*.jsonl,"+testdata+"interleaved.jsonl"; got != want {
+		t.Errorf("Describe = %q, want %q", got, want)
+	}
+
+	times, err := source.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Each file is read once; packages found in both are added up.
+	want := map[string]float64{
+		"example.com/app/api":    3,
+		"example.com/app/db":     3.25,
+		"example.com/app/slow":   42.5,
+		"example.com/app/worker": 0.75,
+	}
+	if !maps.Equal(times, want) {
+		t.Errorf("Load = %v, want %v", times, want)
+	}
+
+	_, err = gotest.NewSource(zerolog.Nop(), testdata+"*.missing").Load(context.Background())
+	if !errors.Is(err, gotest.ErrNoFilesMatched) {
+		t.Errorf("Load error = %v, want ErrNoFilesMatched", err)
+	}
+}
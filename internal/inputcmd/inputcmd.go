@@ -0,0 +1,88 @@
+// Package inputcmd runs the command producing the test list, e.g. a discovery script.
+package inputcmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds a discovery command when no timeout is given.
+	DefaultTimeout = 5 * time.Minute
+
+	// waitDelay is how long a finished or killed command's output pipes may stay open, held by
+	// processes it started in the background, before they are closed.
+	waitDelay = time.Second
+)
+
+var (
+	// ErrFailed is returned when the command cannot be started or exits with a non-zero status.
+	ErrFailed = errors.New("input command failed")
+	// ErrTimeout is returned when the command runs longer than its timeout.
+	ErrTimeout = errors.New("input command timed out")
+)
+
+// Output is what a command wrote.
+type Output struct {
+	Stdout []byte
+	Stderr []byte
+}
+
+// Runner runs the executable name with args and returns its output, which is kept on failure.
+type Runner func(ctx context.Context, name string, args ...string) (Output, error)
+
+// Exec runs the executable found in PATH, killing it when ctx is done.
+func Exec(ctx context.Context, name string, args ...string) (Output, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = waitDelay
+	err := cmd.Run()
+	return Output{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}, err
+}
+
+// Command is an executable with its arguments.
+type Command struct {
+	Name string
+	Args []string
+}
+
+// Shell returns the command running script with the platform shell: sh -c, or cmd /C on Windows.
+func Shell(script string) Command {
+	if runtime.GOOS == "windows" {
+		return Command{Name: "cmd", Args: []string{"/C", script}}
+	}
+	return Command{Name: "sh", Args: []string{"-c", script}}
+}
+
+// String returns the command line, space-separated.
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Run runs c with run, stopping it after timeout unless timeout is 0, and returns its output.
+// It fails with ErrFailed when the command cannot start or exits non-zero, and with ErrTimeout
+// when it is stopped; the output gathered so far is returned in both cases.
+func Run(ctx context.Context, run Runner, c Command, timeout time.Duration) (Output, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	out, err := run(ctx, c.Name, c.Args...)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return out, fmt.Errorf("%w after %s: %s", ErrTimeout, timeout, c)
+	case err != nil:
+		return out, fmt.Errorf("%w: %s: %w", ErrFailed, c, err)
+	}
+	return out, nil
+}
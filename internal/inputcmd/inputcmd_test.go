@@ -0,0 +1,80 @@
+package inputcmd_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prgtw/tests-helper/internal/inputcmd"
+)
+
+func TestRun(t *testing.T) {
+	var got []string
+	run := func(_ context.Context, name string, args ...string) (inputcmd.Output, error) {
+		got = append([]string{name}, args...)
+		return inputcmd.Output{Stdout: []byte("a_test.go\n")}, nil
+	}
+
+	c := inputcmd.Command{Name: "find", Args: []string{".", "-name", "*_test.go"}}
+	out, err := inputcmd.Run(t.Context(), run, c, 0)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if string(out.Stdout) != "a_test.go\n" {
+		t.Errorf("Stdout = %q", out.Stdout)
+	}
+	if want := []string{"find", ".", "-name", "*_test.go"}; !slices.Equal(got, want) {
+		t.Errorf("Ran %q, want %q", got, want)
+	}
+}
+
+func TestRun_Failure(t *testing.T) {
+	run := func(context.Context, string, ...string) (inputcmd.Output, error) {
+		return inputcmd.Output{Stderr: []byte("no such package\n")}, errors.New("exit status 1")
+	}
+
+	out, err := inputcmd.Run(t.Context(), run, inputcmd.Shell("discover"), time.Minute)
+	if !errors.Is(err, inputcmd.ErrFailed) || !strings.Contains(err.Error(), "discover") {
+		t.Errorf("Error = %v, want ErrFailed naming the command", err)
+	}
+	if string(out.Stderr) != "no such package\n" {
+		t.Errorf("Stderr = %q, want it kept on failure", out.Stderr)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	run := func(ctx context.Context, _ string, _ ...string) (inputcmd.Output, error) {
+		<-ctx.Done()
+		return inputcmd.Output{}, ctx.Err()
+	}
+
+	_, err := inputcmd.Run(t.Context(), run, inputcmd.Shell("sleep 60"), time.Millisecond)
+	if !errors.Is(err, inputcmd.ErrTimeout) {
+		t.Errorf("Error = %v, want ErrTimeout", err)
+	}
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	script := inputcmd.Shell("echo a_test.go; echo warning >&2")
+	out, err := inputcmd.Run(t.Context(), inputcmd.Exec, script, time.Minute)
+	if err != nil || string(out.Stdout) != "a_test.go\n" || string(out.Stderr) != "warning\n" {
+		t.Errorf("Run = %q, %q, %v", out.Stdout, out.Stderr, err)
+	}
+
+	_, err = inputcmd.Run(t.Context(), inputcmd.Exec, inputcmd.Shell("exit 3"), time.Minute)
+	if !errors.Is(err, inputcmd.ErrFailed) || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Error = %v, want ErrFailed with the exit status", err)
+	}
+}
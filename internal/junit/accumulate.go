@@ -0,0 +1,233 @@
+package junit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// suiteCounts tallies how the suites of a file contributed to the stats.
+type suiteCounts struct {
+	suppressed  float64 // seconds skipped as parent suites repeating their children's sum
+	loaded      int     // suites whose time was accumulated
+	cases       int     // test cases whose time was accumulated, for suites without a key or time
+	rejected    int     // suites or test cases whose time is negative, non-finite, or implausibly large
+	missingFile int     // suites without a key attribute: file, or name with GranularitySuite
+	missingTime int     // suites with a file but an empty or missing time attribute
+	unparseable int     // suites or test cases whose time is not a number
+	fileAttrs   int     // suites and accumulated test cases with a file attribute
+	nameOnly    int     // suites with a name but no file attribute
+	ignored     int     // skipped test cases whose time was left out, see WithIgnoreSkipped
+}
+
+// add merges other into c.
+func (c *suiteCounts) add(other suiteCounts) {
+	c.suppressed += other.suppressed
+	c.loaded += other.loaded
+	c.cases += other.cases
+	c.rejected += other.rejected
+	c.missingFile += other.missingFile
+	c.missingTime += other.missingTime
+	c.unparseable += other.unparseable
+	c.fileAttrs += other.fileAttrs
+	c.nameOnly += other.nameOnly
+	c.ignored += other.ignored
+}
+
+// accumulateTimes recursively accumulates test times from test suites into res, along with the
+// number of test cases behind each accumulated time.
+func (p *Parser) accumulateTimes(suites []TestSuite, res *fileResult) suiteCounts {
+	return p.accumulateNested(suites, res, false)
+}
+
+// accumulateNested accumulates the time of every suite and its nested suites into res. A suite
+// without a key or time attribute contributes the times of its test cases instead, unless
+// covered: an enclosing suite's own time was accumulated, and it already includes them.
+func (p *Parser) accumulateNested(suites []TestSuite, res *fileResult, covered bool) suiteCounts {
+	var counts suiteCounts
+	for _, suite := range suites {
+		own := p.accumulateSuite(suite, res)
+		counts.add(own)
+		if !covered && (own.missingFile > 0 || own.missingTime > 0) {
+			counts.add(p.accumulateCases(suite, res))
+		}
+		// Recursively process nested test suites
+		counts.add(p.accumulateNested(suite.TestSuites, res, covered || own.loaded > 0))
+	}
+	return counts
+}
+
+// accumulateCases adds the time of every test case of suite to its key, see caseKey.
+// Test cases without a key or time are skipped; unusable times are rejected with a warning.
+func (p *Parser) accumulateCases(suite TestSuite, res *fileResult) suiteCounts {
+	var counts suiteCounts
+	for i := range suite.TestCases {
+		tc := &suite.TestCases[i]
+		if p.dropSkipped && tc.Skipped != nil {
+			counts.ignored++
+			continue
+		}
+		key, ok := p.caseKey(suite, tc)
+		if !ok {
+			continue
+		}
+		val, err := p.caseTime(suite, tc)
+		switch {
+		case errors.Is(err, errMissingTime):
+			continue
+		case err != nil:
+			p.logger.Warn().
+				Str("file", tc.File).
+				Str("testcase", tc.Name).
+				Str("time", tc.Time).
+				Msg("Skipping test case with unparseable time")
+			counts.unparseable++
+			continue
+		}
+		if err = p.validateTime(val); err != nil {
+			p.logger.Warn().
+				Err(err).
+				Str("file", tc.File).
+				Str("testcase", tc.Name).
+				Str("time", tc.Time).
+				Msg("Rejecting test case time")
+			counts.rejected++
+			continue
+		}
+
+		res.times[key] += val
+		res.cases[key]++
+		if tc.File != "" {
+			counts.fileAttrs++
+		}
+		counts.cases++
+	}
+	if counts.cases > 0 {
+		p.logger.Debug().
+			Str("file", suite.File).
+			Str("suite", suite.Name).
+			Int("testcases", counts.cases).
+			Msg("Accumulated test case times of a suite without its own time")
+	}
+	return counts
+}
+
+// accumulateSuite adds a single suite's own time to its key, and its test cases to their count.
+// Unparseable, negative, non-finite, and implausibly large times are rejected with a warning.
+func (p *Parser) accumulateSuite(suite TestSuite, res *fileResult) suiteCounts {
+	var counts suiteCounts
+	switch {
+	case suite.File != "":
+		counts.fileAttrs++
+	case suite.Name != "":
+		counts.nameOnly++
+	}
+	key, ok := p.suiteKey(suite)
+	if !ok {
+		counts.missingFile++
+		return counts
+	}
+
+	val, err := p.suiteTime(suite)
+	switch {
+	case errors.Is(err, errMissingTime):
+		counts.missingTime++
+		return counts
+	case err != nil:
+		p.logger.Warn().
+			Str("file", suite.File).
+			Str("suite", suite.Name).
+			Str("time", suite.Time).
+			Msg("Skipping suite with unparseable time")
+		counts.unparseable++
+		return counts
+	}
+	if err = p.validateTime(val); err != nil {
+		p.logger.Warn().
+			Err(err).
+			Str("file", suite.File).
+			Str("suite", suite.Name).
+			Str("time", suite.Time).
+			Msg("Rejecting suite time")
+		counts.rejected++
+		return counts
+	}
+	if p.duplicatesChildren(suite, key, val) {
+		p.logger.Debug().
+			Str("file", suite.File).
+			Str("suite", suite.Name).
+			Float64("time", val).
+			Msg("Skipping parent suite time repeated by its children")
+		counts.suppressed += val
+		return counts
+	}
+	val, counts.ignored = p.withoutSkipped(suite, val)
+
+	res.times[key] += val
+	if cases := len(suite.TestCases) - counts.ignored; cases > 0 {
+		res.cases[key] += cases
+	}
+	p.logger.Debug().
+		Str("file", suite.File).
+		Str("key", key).
+		Float64("time", val).
+		Msg("Accumulated test time")
+	counts.loaded++
+	return counts
+}
+
+// duplicatesChildren reports whether a suite's own time val should be skipped because it repeats
+// the sum of its direct children sharing its key, which are counted on their own.
+func (p *Parser) duplicatesChildren(suite TestSuite, key string, val float64) bool {
+	if !p.dedupeNested {
+		return false
+	}
+
+	sum, shared := 0.0, false
+	for _, child := range suite.TestSuites {
+		if childKey, ok := p.suiteKey(child); !ok || childKey != key {
+			continue
+		}
+		if childVal, childErr := p.suiteTime(child); childErr == nil {
+			sum += childVal
+			shared = true
+		}
+	}
+	return shared && math.Abs(val-sum) <= nestedTolerance*sum
+}
+
+// withoutSkipped returns the time val of suite without that of its skipped test cases, and how
+// many were left out. A suite whose test cases are all skipped takes no time, whatever it reports.
+func (p *Parser) withoutSkipped(suite TestSuite, val float64) (float64, int) {
+	if !p.dropSkipped {
+		return val, 0
+	}
+	skipped := 0
+	for i := range suite.TestCases {
+		tc := &suite.TestCases[i]
+		if tc.Skipped == nil {
+			continue
+		}
+		skipped++
+		if caseVal, err := p.caseTime(suite, tc); err == nil && p.validateTime(caseVal) == nil {
+			val -= caseVal
+		}
+	}
+	if skipped > 0 && skipped == len(suite.TestCases) {
+		return 0, skipped
+	}
+	return max(val, 0), skipped
+}
+
+// validateTime reports why a parsed time cannot be used, if at all.
+func (p *Parser) validateTime(val float64) error {
+	switch {
+	case math.IsNaN(val) || math.IsInf(val, 0):
+		return errors.New("time is not a finite number")
+	case val < 0:
+		return errors.New("time is negative")
+	case p.maxTime > 0 && val > p.maxTime:
+		return fmt.Errorf("time exceeds the ceiling of %gs", p.maxTime)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package junit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+)
+
+// cacheFormatVersion is bumped whenever the cache entry layout or parsing semantics change.
+const cacheFormatVersion = 10
+
+// cacheEntry is the on-disk representation of a parsed stats file.
+type cacheEntry struct {
+	Times       map[string]float64 `json:"times"`
+	TestCases   map[string]int     `json:"test_cases,omitempty"`
+	Key         string             `json:"key"`
+	Count       int                `json:"count"`
+	Cases       int                `json:"cases,omitempty"`
+	Rejected    int                `json:"rejected,omitempty"`
+	MissingFile int                `json:"missing_file,omitempty"`
+	MissingTime int                `json:"missing_time,omitempty"`
+	Unparseable int                `json:"unparseable,omitempty"`
+	FileAttrs   int                `json:"file_attrs,omitempty"`
+	NameOnly    int                `json:"name_only,omitempty"`
+	Ignored     int                `json:"ignored_skipped,omitempty"`
+	Suppressed  float64            `json:"suppressed,omitempty"`
+	Date        time.Time          `json:"date,omitzero"`
+	Zoneless    int                `json:"zoneless_timestamps,omitempty"`
+	BadStamps   int                `json:"invalid_timestamps,omitempty"`
+}
+
+// newCacheEntry builds the entry stored for a parsed file.
+func newCacheEntry(key string, result fileResult) cacheEntry {
+	return cacheEntry{
+		Times:       result.times,
+		TestCases:   result.cases,
+		Key:         key,
+		Count:       result.counts.loaded,
+		Cases:       result.counts.cases,
+		Rejected:    result.counts.rejected,
+		MissingFile: result.counts.missingFile,
+		MissingTime: result.counts.missingTime,
+		Unparseable: result.counts.unparseable,
+		FileAttrs:   result.counts.fileAttrs,
+		NameOnly:    result.counts.nameOnly,
+		Ignored:     result.counts.ignored,
+		Suppressed:  result.counts.suppressed,
+		Date:        result.date,
+		Zoneless:    result.stamps.zoneless,
+		BadStamps:   result.stamps.invalid,
+	}
+}
+
+// counts restores the suite counts recorded with the entry.
+func (e cacheEntry) counts() suiteCounts {
+	return suiteCounts{
+		suppressed:  e.Suppressed,
+		loaded:      e.Count,
+		cases:       e.Cases,
+		rejected:    e.Rejected,
+		missingFile: e.MissingFile,
+		missingTime: e.MissingTime,
+		unparseable: e.Unparseable,
+		fileAttrs:   e.FileAttrs,
+		nameOnly:    e.NameOnly,
+		ignored:     e.Ignored,
+	}
+}
+
+// WithCacheDir enables the on-disk cache of parsed files in dir.
+// An empty dir disables caching.
+func WithCacheDir(dir string) Option {
+	return func(p *Parser) {
+		p.cacheDir = dir
+	}
+}
+
+// optionsFingerprint describes every parser option that affects the parsed keys or times.
+// It is part of the cache key, so any such option must be included here.
+func (p *Parser) optionsFingerprint() string {
+	return fmt.Sprintf("v%d|max-time=%g|normalize=%s|dedupe-nested=%t|ignore-skipped=%t|granularity=%s|stats-key=%s",
+		cacheFormatVersion, p.maxTime, p.normalizer, p.dedupeNested, p.dropSkipped, p.granularity, p.statsKey)
+}
+
+// cacheKey builds the fingerprint identifying a file's parsed contents.
+func (p *Parser) cacheKey(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot stat file: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve path: %w", err)
+	}
+	return fmt.Sprintf("%s|%d|%d|%s", abs, info.Size(), info.ModTime().UnixNano(), p.optionsFingerprint()), nil
+}
+
+// cachePath returns the location of the cache entry for key.
+func (p *Parser) cachePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(p.cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// parseFileCached parses a file, serving and storing results through the cache when enabled.
+// Extraction hooks disable the cache.
+func (p *Parser) parseFileCached(ctx context.Context, path string) fileResult {
+	if p.cacheDir == "" || p.hasHooks() {
+		return p.parseFile(ctx, path)
+	}
+
+	key, err := p.cacheKey(path)
+	if err != nil {
+		return p.parseFile(ctx, path)
+	}
+
+	if entry, ok := p.readCache(key); ok {
+		return fileResult{
+			times: entry.Times, cases: entry.TestCases, date: entry.Date, counts: entry.counts(), cached: true,
+			stamps: timestampCounts{zoneless: entry.Zoneless, invalid: entry.BadStamps},
+		}
+	}
+
+	result := p.parseFile(ctx, path)
+	if result.err == nil {
+		p.writeCache(key, result)
+	}
+	return result
+}
+
+// readCache loads the entry for key. Missing or corrupted entries are reported as a miss.
+func (p *Parser) readCache(key string) (cacheEntry, bool) {
+	data, err := os.ReadFile(p.cachePath(key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil || entry.Key != key || entry.Times == nil {
+		p.logger.Debug().
+			Str("key", key).
+			Msg("Ignoring unusable stats cache entry")
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// writeCache stores the parsed result under key. Failures are logged and otherwise ignored.
+func (p *Parser) writeCache(key string, result fileResult) {
+	data, err := json.Marshal(newCacheEntry(key, result))
+	if err == nil {
+		err = fileutil.WriteAtomic(p.cachePath(key), data)
+	}
+	if err != nil {
+		p.logger.Debug().
+			Err(err).
+			Str("key", key).
+			Msg("Failed to write stats cache entry")
+	}
+}
@@ -0,0 +1,108 @@
+package junit_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+func TestParser_StatsCache(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+	data, err := os.ReadFile("../../testdata/junit/example1.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	report := filepath.Join(t.TempDir(), "report.xml")
+	if err = os.WriteFile(report, data, 0o600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cacheDir := t.TempDir()
+	parser := junit.NewParser(logger, junit.WithCacheDir(cacheDir))
+
+	first, err := parser.LoadFiles(t.Context(), []string{report})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+
+	entries, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 cache entry, got %d", len(entries))
+	}
+
+	t.Run("hit returns same result", func(t *testing.T) {
+		second, err := parser.LoadFiles(t.Context(), []string{report})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if len(second) != len(first) {
+			t.Fatalf("Got %d entries, want %d", len(second), len(first))
+		}
+		for name, want := range first {
+			if second[name] != want {
+				t.Errorf("Key %q: got %v, want %v", name, second[name], want)
+			}
+		}
+	})
+
+	t.Run("hit is served from cache", func(t *testing.T) {
+		// Keep the entry's key so it still matches, but swap its times
+		raw, err := os.ReadFile(entries[0])
+		if err != nil {
+			t.Fatalf("Failed to read cache entry: %v", err)
+		}
+		var entry map[string]json.RawMessage
+		if err = json.Unmarshal(raw, &entry); err != nil {
+			t.Fatalf("Failed to decode cache entry: %v", err)
+		}
+		entry["times"] = json.RawMessage(`{"cached_test.go":1}`)
+		forged, _ := json.Marshal(entry)
+		if err = os.WriteFile(entries[0], forged, 0o600); err != nil {
+			t.Fatalf("Failed to forge cache entry: %v", err)
+		}
+
+		times, err := parser.LoadFiles(t.Context(), []string{report})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if _, ok := times["cached_test.go"]; !ok {
+			t.Errorf("Expected result served from cache, got %v", times)
+		}
+	})
+
+	t.Run("corrupted entry falls back to parsing", func(t *testing.T) {
+		if err := os.WriteFile(entries[0], []byte("{not json"), 0o600); err != nil {
+			t.Fatalf("Failed to corrupt cache entry: %v", err)
+		}
+
+		times, err := parser.LoadFiles(t.Context(), []string{report})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if len(times) != len(first) {
+			t.Errorf("Got %d entries, want %d", len(times), len(first))
+		}
+	})
+
+	t.Run("modified file invalidates entry", func(t *testing.T) {
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(report, later, later); err != nil {
+			t.Fatalf("Failed to touch report: %v", err)
+		}
+
+		if _, err := parser.LoadFiles(t.Context(), []string{report}); err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		entries, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+		if len(entries) != 2 {
+			t.Errorf("Expected a new cache entry after modification, got %d entries", len(entries))
+		}
+	})
+}
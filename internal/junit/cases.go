@@ -0,0 +1,48 @@
+package junit
+
+import (
+	"context"
+	"strings"
+)
+
+// CaseSource loads the number of test cases of each key from the JUnit XML reports matching a
+// set of patterns, keyed like the times of FileSource.
+type CaseSource struct {
+	parser   *Parser
+	patterns []string
+}
+
+// Cases returns a source loading the test case counts of the reports matching patterns with p.
+func (p *Parser) Cases(patterns ...string) *CaseSource {
+	return &CaseSource{parser: p, patterns: patterns}
+}
+
+// Load parses the matching reports and returns the test cases of every key as a float. A key
+// found in several reports, as when each run of the suite left one, gets its largest count.
+// JSON timings maps hold no test cases. It fails like Parser.LoadFiles.
+func (s *CaseSource) Load(ctx context.Context) (map[string]float64, error) {
+	files := s.parser.expandPatterns(s.patterns)
+	if len(files) == 0 {
+		return make(map[string]float64), ErrNoStatsMatched
+	}
+	results := s.parser.parseFiles(ctx, files)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cases := make(map[string]float64)
+	for i, result := range results {
+		if result.err != nil && (s.parser.strict || result.suites == 0) {
+			return nil, fileError(files[i], result.err)
+		}
+		for key, n := range result.cases {
+			cases[key] = max(cases[key], float64(n))
+		}
+	}
+	return cases, nil
+}
+
+// Describe returns "junit-cases:" followed by the comma-separated patterns.
+func (s *CaseSource) Describe() string {
+	return "junit-cases:" + strings.Join(s.patterns, ",")
+}
@@ -0,0 +1,40 @@
+package junit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError reports malformed input at a position within a file or stream.
+type ParseError struct {
+	Err    error
+	File   string // empty for unnamed input, such as stdin
+	Offset int64  // byte offset of the failure, valid when Line is 0
+	Line   int    // 1-based line, 0 when unknown
+	Column int    // 1-based column, 0 when unknown
+}
+
+// Error formats the position followed by the cause, e.g. "report.xml, line 3, column 7: ...".
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteString(", ")
+	}
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		fmt.Fprintf(&b, "line %d, column %d", e.Line, e.Column)
+	case e.Line > 0:
+		fmt.Fprintf(&b, "line %d", e.Line)
+	default:
+		fmt.Fprintf(&b, "offset %d", e.Offset)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+// Unwrap returns the underlying cause.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,8 @@
+package junit
+
+// Exported aliases of unexported functions for use in junit_test.
+var (
+	WalkDir      = walkDir      //nolint:gochecknoglobals // test-only export
+	IsReportFile = isReportFile //nolint:gochecknoglobals // test-only export
+	ParseTime    = parseTime    //nolint:gochecknoglobals // test-only export
+)
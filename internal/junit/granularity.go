@@ -0,0 +1,66 @@
+package junit
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// Granularity selects which attribute of a suite names its stats key.
+type Granularity string
+
+const (
+	GranularityFile  Granularity = "file"  // The file attribute, normalized like a path (default)
+	GranularitySuite Granularity = "suite" // The name attribute, verbatim
+)
+
+// ParseGranularity validates a granularity given on the command line.
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case GranularityFile, GranularitySuite:
+		return g, nil
+	default:
+		return "", fmt.Errorf("unknown granularity %q (must be %q or %q)", s, GranularityFile, GranularitySuite)
+	}
+}
+
+// WithGranularity sets which attribute of a suite names its stats key. With GranularitySuite,
+// suites are keyed by their name attribute exactly as written, without normalization, for
+// ecosystems such as sbt whose file attributes do not identify what is scheduled.
+func WithGranularity(g Granularity) Option {
+	return func(p *Parser) {
+		p.granularity = g
+	}
+}
+
+// reportKinds sorts the loaded files into reports naming their suites by file and reports
+// naming them only by suite name, to warn when one run mixes both.
+type reportKinds struct {
+	fileBased  []string
+	suiteBased []string
+}
+
+// add classifies file by the suites it contributed. Files mixing both kinds are left out.
+func (k *reportKinds) add(file string, counts suiteCounts) {
+	switch {
+	case counts.fileAttrs > 0 && counts.nameOnly == 0:
+		k.fileBased = append(k.fileBased, file)
+	case counts.nameOnly > 0 && counts.fileAttrs == 0:
+		k.suiteBased = append(k.suiteBased, file)
+	}
+}
+
+// warnMixed logs a warning when some files are file-based and others suite-based: only the
+// kind matching the granularity yields keys that match the test list.
+func (k *reportKinds) warnMixed(logger zerolog.Logger, g Granularity) {
+	if len(k.fileBased) == 0 || len(k.suiteBased) == 0 {
+		return
+	}
+	logger.Warn().
+		Str("granularity", string(g)).
+		Int("file_based", len(k.fileBased)).
+		Str("file_based_example", k.fileBased[0]).
+		Int("suite_based", len(k.suiteBased)).
+		Str("suite_based_example", k.suiteBased[0]).
+		Msg("Stats files mix file-based and suite-based reports; only one kind matches the test list")
+}
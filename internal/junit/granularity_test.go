@@ -0,0 +1,111 @@
+package junit_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+const sbtReports = "../../testdata/junit/sbt"
+
+func TestParseGranularity(t *testing.T) {
+	for _, g := range []junit.Granularity{junit.GranularityFile, junit.GranularitySuite} {
+		got, err := junit.ParseGranularity(string(g))
+		if err != nil || got != g {
+			t.Errorf("ParseGranularity(%q) = %q, %v", g, got, err)
+		}
+	}
+	if _, err := junit.ParseGranularity("testcase"); err == nil {
+		t.Error("Expected error for unknown granularity, got nil")
+	}
+}
+
+func TestParser_GranularitySuite(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	parser := junit.NewParser(logger, junit.WithGranularity(junit.GranularitySuite))
+	times, err := parser.LoadFiles(t.Context(), []string{sbtReports})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	assertTimes(t, times, map[string]float64{
+		"com.example.UserServiceSpec":      12.5,
+		"com.example.billing.InvoiceSpec":  30.25,
+		"com.example.util.StringUtilsSpec": 0.75,
+	})
+
+	// sbt names no files, so keying by file finds nothing
+	times, err = junit.NewParser(logger).LoadFiles(t.Context(), []string{sbtReports})
+	if !errors.Is(err, junit.ErrNoUsableStats) {
+		t.Fatalf("LoadFiles error = %v, want ErrNoUsableStats", err)
+	}
+	assertTimes(t, times, map[string]float64{})
+}
+
+func TestParser_GranularitySuiteVerbatim(t *testing.T) {
+	report := `<testsuite name="./specs//Login Spec" file="a/../b.scala" time="2"/>`
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+	times, err := junit.NewParser(logger, junit.WithGranularity(junit.GranularitySuite)).
+		LoadReader(t.Context(), strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	assertTimes(t, times, map[string]float64{"./specs//Login Spec": 2})
+
+	times, err = junit.NewParser(logger).LoadReader(t.Context(), strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	assertTimes(t, times, map[string]float64{"b.scala": 2})
+}
+
+func TestParser_MixedGranularityWarning(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		warn     bool
+	}{
+		{"file-based reports", []string{"../../testdata/junit/example*.xml"}, false},
+		{"suite-based reports", []string{sbtReports}, false},
+		{"both", []string{sbtReports, "../../testdata/junit/example1.xml"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			parser := junit.NewParser(zerolog.New(&logs), junit.WithGranularity(junit.GranularitySuite))
+			if _, err := parser.LoadFiles(t.Context(), tt.patterns); err != nil {
+				t.Fatalf("LoadFiles failed: %v", err)
+			}
+			warned := strings.Contains(logs.String(), "mix file-based and suite-based reports")
+			if warned != tt.warn {
+				t.Errorf("Warned = %t, want %t; logs:\n%s", warned, tt.warn, logs.String())
+			}
+		})
+	}
+}
+
+func TestParser_GranularityCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	// Keying by file finds nothing, but the results are cached all the same
+	if _, err := junit.NewParser(logger, junit.WithCacheDir(cacheDir)).
+		LoadFiles(t.Context(), []string{sbtReports}); !errors.Is(err, junit.ErrNoUsableStats) {
+		t.Fatalf("LoadFiles error = %v, want ErrNoUsableStats", err)
+	}
+
+	// Entries cached by file are not served when keying by suite
+	parser := junit.NewParser(logger, junit.WithCacheDir(cacheDir), junit.WithGranularity(junit.GranularitySuite))
+	times, err := parser.LoadFiles(t.Context(), []string{sbtReports})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	if len(times) != 3 {
+		t.Errorf("Times = %v, want the three suites", times)
+	}
+}
@@ -0,0 +1,127 @@
+package junit
+
+import (
+	"errors"
+	"strings"
+)
+
+// errMissingTime reports a suite without a time to accumulate.
+var errMissingTime = errors.New("time attribute is missing")
+
+// KeyFunc extracts the stats key of a suite, overriding the file attribute.
+// Returning ok=false falls back to the built-in behavior. The returned key is normalized like
+// a file attribute would be, unless suites are keyed by name (GranularitySuite).
+//
+// testcase is nil for the suite's own time. A suite without a key or time contributes the times
+// of its test cases instead, and the hook is then also called with each of them. The suite's
+// test cases are always available through suite.TestCases. Files are parsed concurrently, so
+// the hook must be safe for concurrent use.
+type KeyFunc func(suite TestSuite, testcase *TestCase) (key string, ok bool)
+
+// TimeFunc extracts the time of a suite in seconds, overriding the time attribute.
+// Returning ok=false falls back to the built-in behavior. The returned time is validated
+// like a parsed one. As with KeyFunc, testcase is nil for the suite's own time.
+type TimeFunc func(suite TestSuite, testcase *TestCase) (seconds float64, ok bool)
+
+// WithKeyFunc installs a hook choosing the stats key of each suite.
+// Parsed files are not cached while a hook is installed, since its behavior cannot be fingerprinted.
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(p *Parser) {
+		p.keyFunc = fn
+	}
+}
+
+// WithTimeFunc installs a hook choosing the time of each suite.
+// Parsed files are not cached while a hook is installed, since its behavior cannot be fingerprinted.
+func WithTimeFunc(fn TimeFunc) Option {
+	return func(p *Parser) {
+		p.timeFunc = fn
+	}
+}
+
+// hasHooks reports whether an extraction hook is installed.
+func (p *Parser) hasHooks() bool {
+	return p.keyFunc != nil || p.timeFunc != nil
+}
+
+// suiteKey returns the stats key of suite, or false when it has none: its normalized file
+// attribute or shared classname (see WithStatsKey), or its verbatim name with GranularitySuite.
+func (p *Parser) suiteKey(suite TestSuite) (string, bool) {
+	var key string
+	switch {
+	case p.granularity == GranularitySuite:
+		key = suite.Name
+	case p.statsKey == StatsKeyClassname:
+		key = sharedClassName(suite)
+	case p.statsKey == StatsKeyAuto && suite.File == "":
+		key = sharedClassName(suite)
+	default:
+		key = suite.File
+	}
+	if p.keyFunc != nil {
+		if custom, ok := p.keyFunc(suite, nil); ok {
+			key = custom
+		}
+	}
+	if key == "" {
+		return "", false
+	}
+	return p.finishKey(key), true
+}
+
+// caseKey returns the stats key of a test case of suite: its normalized file attribute or
+// classname (see WithStatsKey), or the key of the suite when it has none. With GranularitySuite
+// it is always the key of the suite.
+func (p *Parser) caseKey(suite TestSuite, tc *TestCase) (string, bool) {
+	if p.keyFunc != nil {
+		if custom, ok := p.keyFunc(suite, tc); ok && custom != "" {
+			return p.finishKey(custom), true
+		}
+	}
+	if p.granularity == GranularitySuite {
+		return p.suiteKey(suite)
+	}
+	key := tc.File
+	if p.statsKey == StatsKeyClassname || (p.statsKey == StatsKeyAuto && key == "") {
+		key = tc.ClassName
+	}
+	if key == "" {
+		return p.suiteKey(suite)
+	}
+	return p.normalizer.Key(key), true
+}
+
+// finishKey normalizes a key like a file attribute, or keeps it verbatim with GranularitySuite.
+func (p *Parser) finishKey(key string) string {
+	if p.granularity == GranularitySuite {
+		return key
+	}
+	return p.normalizer.Key(key)
+}
+
+// suiteTime returns the time of suite in seconds.
+// It fails with errMissingTime when the suite has no time, and with a parse error when it is not a number.
+func (p *Parser) suiteTime(suite TestSuite) (float64, error) {
+	if p.timeFunc != nil {
+		if val, ok := p.timeFunc(suite, nil); ok {
+			return val, nil
+		}
+	}
+	if strings.TrimSpace(suite.Time) == "" {
+		return 0, errMissingTime
+	}
+	return parseTime(suite.Time)
+}
+
+// caseTime returns the time of a test case of suite in seconds, failing like suiteTime.
+func (p *Parser) caseTime(suite TestSuite, tc *TestCase) (float64, error) {
+	if p.timeFunc != nil {
+		if val, ok := p.timeFunc(suite, tc); ok {
+			return val, nil
+		}
+	}
+	if strings.TrimSpace(tc.Time) == "" {
+		return 0, errMissingTime
+	}
+	return parseTime(tc.Time)
+}
@@ -0,0 +1,145 @@
+package junit_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+const hooksReport = `<testsuites>
+  <testsuite name="api" file="handler_test.go" time="2.0"/>
+  <testsuite name="db" file="conn_test.go" time="3.0"/>
+  <testsuite name="pytest" time="9.9">
+    <testcase classname="tests.test_users" file="tests/test_users.py" name="test_a" time="0.5"/>
+    <testcase classname="tests.test_users" file="tests/test_users.py" name="test_b" time="1.5"/>
+  </testsuite>
+</testsuites>`
+
+func TestParser_KeyFunc(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+	// Monorepo mapping: the api suites live under services/api, everything else keeps its file;
+	// suites without a file take it from their first test case
+	keyFunc := func(suite junit.TestSuite, testcase *junit.TestCase) (string, bool) {
+		if testcase != nil {
+			t.Errorf("Got test case %+v, want nil at suite granularity", testcase)
+		}
+		switch {
+		case suite.Name == "api":
+			return "services/api/" + suite.File, true
+		case suite.File == "" && len(suite.TestCases) > 0:
+			return suite.TestCases[0].File, true
+		default:
+			return "", false
+		}
+	}
+
+	parser := junit.NewParser(logger, junit.WithKeyFunc(keyFunc))
+	times, err := parser.LoadReader(t.Context(), strings.NewReader(hooksReport))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	assertTimes(t, times, map[string]float64{
+		"services/api/handler_test.go": 2.0,
+		"conn_test.go":                 3.0,
+		"tests/test_users.py":          9.9,
+	})
+}
+
+func TestParser_TimeFunc(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+	// Sum the test cases where there are any, ignoring the suite's own time
+	timeFunc := func(suite junit.TestSuite, _ *junit.TestCase) (float64, bool) {
+		if len(suite.TestCases) == 0 {
+			return 0, false
+		}
+		sum := 0.0
+		for _, tc := range suite.TestCases {
+			val, err := strconv.ParseFloat(tc.Time, 64)
+			if err != nil {
+				return 0, false
+			}
+			sum += val
+		}
+		return sum, true
+	}
+	keyFunc := func(suite junit.TestSuite, _ *junit.TestCase) (string, bool) {
+		return "./" + suite.Name + "_test.go", true
+	}
+
+	parser := junit.NewParser(logger, junit.WithKeyFunc(keyFunc), junit.WithTimeFunc(timeFunc))
+	times, err := parser.LoadReader(t.Context(), strings.NewReader(hooksReport))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	// Custom keys are normalized like file attributes
+	assertTimes(t, times, map[string]float64{
+		"api_test.go":    2.0,
+		"db_test.go":     3.0,
+		"pytest_test.go": 2.0,
+	})
+}
+
+func TestParser_KeyFuncTestCases(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	const report = `<testsuites>
+  <testsuite name="api" file="handler_test.go" time="2.0"/>
+  <testsuite name="pytest">
+    <testcase classname="tests.test_users" name="test_a" time="0.5"/>
+    <testcase classname="tests.test_orders" name="test_b" time="1.5"/>
+  </testsuite>
+</testsuites>`
+
+	// Suites without a time are asked again for each of their test cases
+	keyFunc := func(suite junit.TestSuite, testcase *junit.TestCase) (string, bool) {
+		if testcase == nil {
+			return "", false
+		}
+		return strings.ReplaceAll(testcase.ClassName, ".", "/") + ".py", true
+	}
+
+	parser := junit.NewParser(logger, junit.WithKeyFunc(keyFunc))
+	times, err := parser.LoadReader(t.Context(), strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	assertTimes(t, times, map[string]float64{
+		"handler_test.go":      2.0,
+		"tests/test_users.py":  0.5,
+		"tests/test_orders.py": 1.5,
+	})
+}
+
+func TestParser_HooksBypassCache(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	cacheDir := t.TempDir()
+
+	keyFunc := func(suite junit.TestSuite, _ *junit.TestCase) (string, bool) {
+		return "custom/" + suite.File, suite.File != ""
+	}
+	parser := junit.NewParser(logger, junit.WithCacheDir(cacheDir), junit.WithKeyFunc(keyFunc))
+	times, err := parser.LoadFiles(t.Context(), []string{"../../testdata/junit/example1.xml"})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	for key := range times {
+		if !strings.HasPrefix(key, "custom/") {
+			t.Errorf("Key %q was not produced by the hook", key)
+		}
+	}
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no cache entries while a hook is installed, got %v", entries)
+	}
+}
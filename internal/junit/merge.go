@@ -0,0 +1,91 @@
+package junit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Merge selects how LoadFiles combines the times of a key found in more than one report.
+type Merge string
+
+const (
+	MergeSum      Merge = "sum"      // Add the times together (default)
+	MergeAvg      Merge = "avg"      // Average over the reports containing the key
+	MergeMax      Merge = "max"      // Keep the longest time
+	MergeLatest   Merge = "latest"   // Keep the time of the newest report containing the key
+	MergePriority Merge = "priority" // Keep the time of the first report containing the key
+)
+
+// ParseMerge validates a merge strategy given on the command line.
+func ParseMerge(s string) (Merge, error) {
+	switch m := Merge(s); m {
+	case MergeSum, MergeAvg, MergeMax, MergeLatest, MergePriority:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy %q (must be %q, %q, %q, %q, or %q)",
+			s, MergeSum, MergeAvg, MergeMax, MergeLatest, MergePriority)
+	}
+}
+
+// WithMerge sets how the times of a key found in several reports are combined, e.g. when loading
+// the reports of the last few builds. Times within one report are always added together, so a
+// file split into several suites still counts once. MergeLatest dates a report by the newest
+// timestamp attribute of its suites, or by its modification time when no suite has one.
+// MergePriority keeps the time of the first report in sorted path order.
+func WithMerge(m Merge) Option {
+	return func(p *Parser) {
+		p.merge = m
+	}
+}
+
+// merger combines the times of the reports loaded by LoadFiles, added in sorted path order.
+type merger struct {
+	strategy Merge
+	times    map[string]float64
+	counts   map[string]int       // Reports containing each key, for MergeAvg
+	dates    map[string]time.Time // Date of the report each time was taken from, for MergeLatest
+}
+
+func newMerger(strategy Merge) *merger {
+	return &merger{
+		strategy: strategy,
+		times:    make(map[string]float64),
+		counts:   make(map[string]int),
+		dates:    make(map[string]time.Time),
+	}
+}
+
+// add merges the times of a report dated date. Among reports with the same date, the one added
+// last wins with MergeLatest.
+func (m *merger) add(times map[string]float64, date time.Time) {
+	for key, val := range times {
+		seen := m.counts[key] > 0
+		m.counts[key]++
+		switch {
+		case m.strategy == MergeMax:
+			m.times[key] = max(m.times[key], val)
+		case m.strategy == MergePriority:
+			if !seen {
+				m.times[key] = val
+			}
+		case m.strategy == MergeLatest:
+			if !seen || !date.Before(m.dates[key]) {
+				m.times[key] = val
+				m.dates[key] = date
+			}
+		default:
+			// MergeSum, and MergeAvg until result divides
+			m.times[key] += val
+		}
+	}
+}
+
+// result returns the merged times.
+func (m *merger) result() map[string]float64 {
+	if m.strategy == MergeAvg {
+		for key, n := range m.counts {
+			m.times[key] /= float64(n)
+		}
+	}
+	return m.times
+}
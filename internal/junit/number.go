@@ -0,0 +1,38 @@
+package junit
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseTime parses a suite time attribute written with either '.' or ',' as the decimal
+// separator, optionally combined with the other one as a thousands separator.
+func parseTime(s string) (float64, error) {
+	return strconv.ParseFloat(normalizeNumber(strings.TrimSpace(s)), 64)
+}
+
+// normalizeNumber rewrites a locale-formatted number into the form accepted by strconv:
+//   - with both separators, the last one is the decimal separator and the other is stripped;
+//   - with only commas, a single comma is the decimal separator and several are thousands separators;
+//   - with only dots, several dots are thousands separators.
+func normalizeNumber(s string) string {
+	lastComma := strings.LastIndexByte(s, ',')
+	lastDot := strings.LastIndexByte(s, '.')
+
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		if lastComma > lastDot {
+			return strings.Replace(strings.ReplaceAll(s, ".", ""), ",", ".", 1)
+		}
+		return strings.ReplaceAll(s, ",", "")
+	case lastComma >= 0:
+		if strings.Count(s, ",") == 1 {
+			return strings.Replace(s, ",", ".", 1)
+		}
+		return strings.ReplaceAll(s, ",", "")
+	case strings.Count(s, ".") > 1:
+		return strings.ReplaceAll(s, ".", "")
+	default:
+		return s
+	}
+}
@@ -0,0 +1,46 @@
+package junit_test
+
+import (
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{input: "1.5", want: 1.5},
+		{input: "1,5", want: 1.5},
+		{input: " 2.25 ", want: 2.25},
+		{input: "1,234.567", want: 1234.567},
+		{input: "1.234,567", want: 1234.567},
+		{input: "1,234,567", want: 1234567},
+		{input: "1,234,567.5", want: 1234567.5},
+		{input: "1.234.567", want: 1234567},
+		{input: "1.234.567,5", want: 1234567.5},
+		{input: "1,2.3,4", wantErr: true},
+		{input: "n/a", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := junit.ParseTime(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !floatEqual(got, tt.want) {
+				t.Errorf("Got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
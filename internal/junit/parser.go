@@ -1,32 +1,317 @@
 package junit
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"strconv"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/normalize"
+	"github.com/prgtw/tests-helper/internal/progress"
+)
+
+const (
+	// DefaultMaxTime is the default sanity ceiling, in seconds, for a single suite's time.
+	DefaultMaxTime = 86400.0
+
+	// nestedTolerance is the relative difference under which a parent suite's time is
+	// considered to be the sum of its children's.
+	nestedTolerance = 0.01
+)
+
+var (
+	// ErrNoStatsMatched is returned by LoadFiles when no file matches the given patterns.
+	ErrNoStatsMatched = errors.New("no files matched the provided patterns")
+	// ErrNoUsableStats is returned by LoadFiles when no matched file contributed any entries,
+	// because every file failed to load or held no usable suites.
+	ErrNoUsableStats = errors.New("no stats file could be used")
 )
 
 // Parser handles parsing of JUnit XML files.
 type Parser struct {
-	logger zerolog.Logger
+	logger       zerolog.Logger
+	cacheDir     string
+	normalizer   normalize.Normalizer
+	keyFunc      KeyFunc
+	granularity  Granularity
+	statsKey     StatsKey
+	merge        Merge
+	timeFunc     TimeFunc
+	progress     progress.Reporter
+	concurrency  int
+	maxTime      float64
+	strict       bool
+	dedupeNested bool
+	dropSkipped  bool
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithConcurrency sets the maximum number of files parsed in parallel.
+// Values below 1 fall back to parsing files one at a time.
+func WithConcurrency(n int) Option {
+	return func(p *Parser) {
+		p.concurrency = max(n, 1)
+	}
+}
+
+// WithStrict makes any unreadable, invalid, or truncated file a hard error
+// instead of a warning.
+func WithStrict(strict bool) Option {
+	return func(p *Parser) {
+		p.strict = strict
+	}
+}
+
+// WithMaxTime sets the ceiling, in seconds, above which a suite's time is rejected as implausible.
+// Values below or equal to 0 disable the ceiling.
+func WithMaxTime(seconds float64) Option {
+	return func(p *Parser) {
+		p.maxTime = seconds
+	}
+}
+
+// WithDedupeNested toggles skipping a parent suite's time when it merely repeats the sum
+// of its direct children that share its file.
+func WithDedupeNested(enabled bool) Option {
+	return func(p *Parser) {
+		p.dedupeNested = enabled
+	}
+}
+
+// WithIgnoreSkipped toggles leaving out the time of skipped test cases, which some runners
+// report with the time of a setup or of the whole suite. A suite whose test cases are all skipped
+// then takes no time.
+func WithIgnoreSkipped(enabled bool) Option {
+	return func(p *Parser) {
+		p.dropSkipped = enabled
+	}
+}
+
+// WithNormalizer sets how file attributes are normalized into stats keys.
+func WithNormalizer(n normalize.Normalizer) Option {
+	return func(p *Parser) {
+		p.normalizer = n
+	}
+}
+
+// WithProgress sets a reporter notified each time LoadFiles has parsed a file.
+func WithProgress(r progress.Reporter) Option {
+	return func(p *Parser) {
+		p.progress = r
+	}
 }
 
 // NewParser creates a new JUnit parser.
-func NewParser(logger zerolog.Logger) *Parser {
-	return &Parser{logger: logger}
+func NewParser(logger zerolog.Logger, opts ...Option) *Parser {
+	p := &Parser{
+		logger:       logger,
+		concurrency:  runtime.GOMAXPROCS(0),
+		maxTime:      DefaultMaxTime,
+		normalizer:   normalize.New(),
+		granularity:  GranularityFile,
+		statsKey:     StatsKeyFile,
+		merge:        MergeSum,
+		dedupeNested: true,
+		dropSkipped:  true,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// fileResult holds the samples parsed from a single file.
+type fileResult struct {
+	times   map[string]float64
+	cases   map[string]int // Test cases of each key
+	err     error
+	date    time.Time // Newest timestamp attribute of a suite, else the file's modification time
+	stamps  timestampCounts
+	counts  suiteCounts
+	suites  int   // number of top-level suites decoded
+	offset  int64 // byte offset at which decoding stopped on error
+	skipped int64 // bytes skipped before the first '<' (BOM, banner lines)
+	cached  bool  // served from the on-disk cache
 }
 
 // LoadFiles loads and parses multiple JUnit XML files, returning a map of test names to execution times.
-func (p *Parser) LoadFiles(patterns []string) (map[string]float64, error) {
-	times := make(map[string]float64)
+// Patterns matching a directory load every report file beneath it. Files named *.json are read as
+// JSON timings maps instead, see parseTimings, and merged like reports.
+//
+// Files are parsed concurrently, but their results are merged serially in sorted path order,
+// so the outcome never depends on the level of concurrency. A key found in several files gets
+// the times combined as set by WithMerge, added together by default.
+//
+// Cancelling ctx stops parsing between files and between suites; the context's error is returned.
+func (p *Parser) LoadFiles(ctx context.Context, patterns []string) (map[string]float64, error) {
+	files := p.expandPatterns(patterns)
+	if len(files) == 0 {
+		return make(map[string]float64), ErrNoStatsMatched
+	}
+
+	results := p.parseFiles(ctx, files)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("loading stats interrupted: %w", err)
+	}
+
+	// Merge in sorted path order
+	hits, contributed := 0, 0
+	var failures []error
+	var empty []string // Files loaded without error that contributed nothing
+	var kinds reportKinds
+	merged := newMerger(p.merge)
+	for i, file := range files {
+		result := results[i]
+		if result.cached {
+			hits++
+		}
+		if result.err != nil {
+			if p.strict {
+				return nil, fmt.Errorf("failed to load stats: %w", fileError(file, result.err))
+			}
+			failures = append(failures, fileError(file, result.err))
+			if result.suites == 0 {
+				p.logger.Warn().
+					Err(result.err).
+					Str("file", file).
+					Msg("Failed to load file")
+				continue
+			}
+			p.logger.Warn().
+				Err(result.err).
+				Str("file", file).
+				Int64("offset", result.offset).
+				Int("suites", result.suites).
+				Msg("File is truncated or malformed, keeping suites parsed so far")
+		}
+
+		if result.skipped > 0 {
+			p.logger.Info().
+				Int64("bytes", result.skipped).
+				Str("file", file).
+				Msg("Skipped leading bytes before XML content")
+		}
+
+		p.warnTimestamps(file, result.stamps)
+		merged.add(result.times, result.date)
+		switch {
+		case result.counts.loaded > 0 || result.counts.cases > 0:
+			contributed++
+		case result.err == nil:
+			empty = append(empty, file)
+		}
+		kinds.add(file, result.counts)
+
+		p.logger.Info().
+			Int("count", result.counts.loaded).
+			Int("rejected", result.counts.rejected).
+			Float64("suppressed", result.counts.suppressed).
+			Dict("skipped", zerolog.Dict().
+				Int("missing_file", result.counts.missingFile).
+				Int("missing_time", result.counts.missingTime).
+				Int("unparseable", result.counts.unparseable)).
+			Int("testcases", result.counts.cases).
+			Int("ignored_skipped", result.counts.ignored).
+			Str("file", filepath.Base(file)).
+			Msg("Loaded test times")
+	}
+
+	if p.granularity == GranularitySuite || p.statsKey == StatsKeyFile {
+		// Keying by classname is meant for reports without files, mixed or not
+		kinds.warnMixed(p.logger, p.granularity)
+	}
+	if p.cacheDir != "" {
+		p.logger.Info().
+			Int("hits", hits).
+			Int("misses", len(files)-hits).
+			Float64("hit_rate", float64(hits)/float64(len(files))).
+			Msg("Stats cache usage")
+	}
+
+	times := merged.result()
+	if contributed == 0 {
+		return times, summarizeFailures(failures, empty)
+	}
+	return times, nil
+}
+
+// LoadReader parses a single JUnit XML document from r, returning a map of test names to execution times.
+// Like LoadFiles, a truncated document keeps the suites parsed before the failure unless the parser is strict.
+func (p *Parser) LoadReader(ctx context.Context, r io.Reader) (map[string]float64, error) {
+	result := p.parseReader(ctx, r)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("loading stats interrupted: %w", err)
+	}
+	if result.err != nil {
+		if p.strict || result.suites == 0 {
+			return nil, result.err
+		}
+		p.logger.Warn().
+			Err(result.err).
+			Int64("offset", result.offset).
+			Int("suites", result.suites).
+			Msg("Document is truncated or malformed, keeping suites parsed so far")
+	}
+
+	p.logger.Info().
+		Int("count", result.counts.loaded).
+		Int("rejected", result.counts.rejected).
+		Int("testcases", result.counts.cases).
+		Int("ignored_skipped", result.counts.ignored).
+		Msg("Loaded test times")
+	return result.times, nil
+}
+
+// fileError names file in err, unless err is a ParseError that already does.
+func fileError(file string, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) && parseErr.File == file {
+		return err
+	}
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+// maxReportedFailures limits how many failed files are named in the error returned by LoadFiles.
+const maxReportedFailures = 3
+
+// summarizeFailures builds the error returned when no stats file contributed any entries, naming
+// the files that failed or, when none did, the files that held no usable suites.
+func summarizeFailures(failures []error, empty []string) error {
+	if len(failures) == 0 {
+		names := empty[:min(len(empty), maxReportedFailures)]
+		reasons := strings.Join(names, ", ")
+		if extra := len(empty) - len(names); extra > 0 {
+			reasons += fmt.Sprintf(" and %d more", extra)
+		}
+		return fmt.Errorf("%w, %d held no usable suites: %s", ErrNoUsableStats, len(empty), reasons)
+	}
+	reasons := make([]string, 0, maxReportedFailures)
+	for _, failure := range failures[:min(len(failures), maxReportedFailures)] {
+		reasons = append(reasons, failure.Error())
+	}
+	if extra := len(failures) - len(reasons); extra > 0 {
+		reasons = append(reasons, fmt.Sprintf("and %d more", extra))
+	}
+	return fmt.Errorf("%w, %d failed: %s", ErrNoUsableStats, len(failures), strings.Join(reasons, "; "))
+}
 
-	// Expand glob patterns
+// expandPatterns expands glob patterns into a sorted list of unique file paths.
+func (p *Parser) expandPatterns(patterns []string) []string {
+	seen := make(map[string]struct{})
 	var files []string
 	for _, pattern := range patterns {
 		matches, err := filepath.Glob(pattern)
@@ -37,66 +322,233 @@ func (p *Parser) LoadFiles(patterns []string) (map[string]float64, error) {
 				Msg("Invalid glob pattern")
 			continue
 		}
-		files = append(files, matches...)
+		for _, match := range p.expandDirectories(matches) {
+			if _, ok := seen[match]; ok {
+				continue
+			}
+			seen[match] = struct{}{}
+			files = append(files, match)
+		}
 	}
 
-	if len(files) == 0 {
-		return times, errors.New("no files matched the provided patterns")
-	}
+	sort.Strings(files)
+	return files
+}
 
-	// Load each file
-	for _, file := range files {
-		if err := p.loadFile(file, times); err != nil {
+// expandDirectories replaces directories in paths with the report files found beneath them.
+func (p *Parser) expandDirectories(paths []string) []string {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		found, err := walkDir(path, p.concurrency, isReportFile)
+		if err != nil {
 			p.logger.Warn().
 				Err(err).
-				Str("file", file).
-				Msg("Failed to load file")
-			continue
+				Str("directory", path).
+				Msg("Failed to scan part of directory")
 		}
+		p.logger.Debug().
+			Int("count", len(found)).
+			Str("directory", path).
+			Msg("Found report files in directory")
+		files = append(files, found...)
 	}
+	return files
+}
 
-	return times, nil
+// parseFiles parses files using at most p.concurrency goroutines, reporting each parsed file to
+// the progress reporter, if any, one at a time.
+// Results are returned in the same order as files. Once ctx is cancelled no further file is started.
+func (p *Parser) parseFiles(ctx context.Context, files []string) []fileResult {
+	results := make([]fileResult, len(files))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	parsed := 0
+	sem := make(chan struct{}, p.concurrency)
+	for i, file := range files {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return results
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			results[i] = p.parseFileCached(ctx, file)
+			if p.progress != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				parsed++
+				p.progress.OnStage(progress.StageParse, parsed, len(files))
+			}
+		})
+	}
+	wg.Wait()
+
+	return results
+}
+
+// parseFile parses a single JUnit XML file, or a JSON timings map named *.json, plain or
+// gzip-compressed, into its own samples map.
+func (p *Parser) parseFile(ctx context.Context, path string) fileResult {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileResult{err: fmt.Errorf("cannot read file: %w", err)}
+	}
+	defer func() { _ = f.Close() }()
+
+	r, err := decompress(f)
+	if err != nil {
+		return fileResult{err: err}
+	}
+	var result fileResult
+	if isTimingsFile(path) {
+		result = p.parseTimings(ctx, r)
+	} else {
+		result = p.parseReader(ctx, r)
+	}
+	var parseErr *ParseError
+	if errors.As(result.err, &parseErr) {
+		parseErr.File = path
+	}
+	if info, statErr := f.Stat(); result.date.IsZero() && statErr == nil {
+		result.date = info.ModTime()
+	}
+	return result
 }
 
-// loadFile loads a single JUnit XML file and accumulates test times.
-func (p *Parser) loadFile(path string, times map[string]float64) error {
-	data, err := os.ReadFile(path)
+// gzipMagic starts every gzip stream.
+const gzipMagic = "\x1f\x8b"
+
+// decompress returns a reader of the decompressed contents of f when they start with the gzip
+// magic bytes, whatever the file is named, and of f itself otherwise. A stream corrupted past
+// its header fails later, while reading, like a truncated XML document.
+func decompress(f io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil || string(magic) != gzipMagic {
+		// Short or empty files are left for the XML decoder to reject
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
 	if err != nil {
-		return fmt.Errorf("cannot read file: %w", err)
+		return nil, fmt.Errorf("cannot decompress file: %w", err)
 	}
+	return zr, nil
+}
 
-	var root TestSuites
-	if parseErr := xml.Unmarshal(data, &root); parseErr != nil {
-		return fmt.Errorf("cannot parse XML: %w", parseErr)
+// parseReader parses a JUnit XML document into its own samples map.
+//
+// Suites are decoded one at a time, so a document that is truncated or has garbage appended
+// still yields the suites that were complete before the point of failure.
+// Decoding stops with the context's error when ctx is cancelled.
+func (p *Parser) parseReader(ctx context.Context, src io.Reader) fileResult {
+	result := fileResult{times: make(map[string]float64), cases: make(map[string]int)}
+	r := bufio.NewReader(src)
+	lead, err := skipToMarkup(r)
+	result.skipped = lead.bytes
+	if err != nil {
+		result.err = err
+		return result
 	}
 
-	count := 0
-	p.accumulateTimes(root.TestSuites, times, &count)
+	dec := xml.NewDecoder(r)
+	depth := 0
+	for {
+		tok, tokErr := dec.Token()
+		if errors.Is(tokErr, io.EOF) {
+			break
+		}
+		if tokErr != nil {
+			result.fail(dec, lead, tokErr)
+			return result
+		}
 
-	p.logger.Info().
-		Int("count", count).
-		Str("file", filepath.Base(path)).
-		Msg("Loaded test times")
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 && t.Name.Local != "testsuites" && t.Name.Local != "testsuite" {
+				result.fail(dec, lead, fmt.Errorf("unexpected root element <%s>", t.Name.Local))
+				return result
+			}
+			if t.Name.Local != "testsuite" {
+				depth++
+				continue
+			}
+
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				result.err = ctxErr
+				return result
+			}
 
-	return nil
-}
-
-// accumulateTimes recursively accumulates test times from test suites.
-func (p *Parser) accumulateTimes(suites []TestSuite, times map[string]float64, count *int) {
-	for _, suite := range suites {
-		if suite.File != "" && suite.Time != "" {
-			// Normalize time string (replace comma with dot for some locales)
-			timeStr := strings.ReplaceAll(suite.Time, ",", ".")
-			if val, err := strconv.ParseFloat(timeStr, 64); err == nil {
-				times[suite.File] += val
-				p.logger.Debug().
-					Str("file", suite.File).
-					Float64("time", val).
-					Msg("Accumulated test time")
-				*count++
+			var suite TestSuite
+			if decodeErr := dec.DecodeElement(&suite, &t); decodeErr != nil {
+				result.fail(dec, lead, decodeErr)
+				return result
 			}
+			result.counts.add(p.accumulateTimes([]TestSuite{suite}, &result))
+			result.suites++
+			result.addTimestamp(suite.Timestamp)
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	return result
+}
+
+// fail records a ParseError for err at the decoder's current position,
+// counted from the start of the input including the leading bytes skipped before the markup.
+func (r *fileResult) fail(dec *xml.Decoder, lead leadingBytes, err error) {
+	line, column := dec.InputPos()
+	if line == 1 {
+		column += lead.column
+	}
+	r.offset = lead.bytes + dec.InputOffset()
+	r.err = &ParseError{
+		Err:    fmt.Errorf("cannot parse XML: %w", err),
+		Offset: r.offset,
+		Line:   line + lead.lines,
+		Column: column,
+	}
+}
+
+// leadingBytes describes the input skipped before the first '<'.
+type leadingBytes struct {
+	bytes  int64
+	lines  int // newlines skipped
+	column int // bytes skipped since the last newline
+}
+
+// skipToMarkup advances r past a leading byte order mark and any text before the first '<',
+// such as a banner line printed ahead of the XML prolog, and describes what was skipped.
+// A file with text but no markup at all is reported as an error.
+func skipToMarkup(r *bufio.Reader) (leadingBytes, error) {
+	var lead leadingBytes
+	for {
+		b, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			if lead.bytes > 0 {
+				return lead, &ParseError{Err: errors.New("cannot parse XML: no markup found"), Offset: lead.bytes}
+			}
+			return lead, nil
+		}
+		if err != nil {
+			return lead, fmt.Errorf("cannot read file: %w", err)
+		}
+		if b == '<' {
+			return lead, r.UnreadByte()
+		}
+		lead.bytes++
+		lead.column++
+		if b == '\n' {
+			lead.lines++
+			lead.column = 0
 		}
-		// Recursively process nested test suites
-		p.accumulateTimes(suite.TestSuites, times, count)
 	}
 }
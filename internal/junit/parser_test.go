@@ -1,13 +1,22 @@
 package junit_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 
 	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/progress"
 )
 
 //nolint:gocognit // Don't care
@@ -17,7 +26,7 @@ func TestParser_LoadFiles(t *testing.T) {
 
 	t.Run("single file", func(t *testing.T) {
 		pattern := "../../testdata/junit/example1.xml"
-		times, err := parser.LoadFiles([]string{pattern})
+		times, err := parser.LoadFiles(t.Context(), []string{pattern})
 		if err != nil {
 			t.Fatalf("LoadFiles failed: %v", err)
 		}
@@ -45,30 +54,68 @@ func TestParser_LoadFiles(t *testing.T) {
 			"../../testdata/junit/example1.xml",
 			"../../testdata/junit/example2.xml",
 		}
-		times, err := parser.LoadFiles(patterns)
+		// example1.xml has auth_test.go with 5.234s, example2.xml with 2.100s
+		for strategy, want := range map[junit.Merge]float64{
+			junit.MergeSum:      7.334,
+			junit.MergeAvg:      3.667,
+			junit.MergeMax:      5.234,
+			junit.MergePriority: 5.234, // example1.xml sorts first
+		} {
+			times, err := junit.NewParser(logger, junit.WithMerge(strategy)).LoadFiles(t.Context(), patterns)
+			if err != nil {
+				t.Fatalf("LoadFiles failed: %v", err)
+			}
+			if got := times["pkg/service/auth_test.go"]; !floatEqual(got, want) {
+				t.Errorf("%s: auth_test.go got %.3f, want %.3f", strategy, got, want)
+			}
+			// example2.xml alone has connection_test.go
+			if got := times["pkg/db/connection_test.go"]; !floatEqual(got, 12.567) {
+				t.Errorf("%s: connection_test.go got %.3f, want 12.567", strategy, got)
+			}
+		}
+
+		// The default adds the times together
+		times, err := parser.LoadFiles(t.Context(), patterns)
 		if err != nil {
 			t.Fatalf("LoadFiles failed: %v", err)
 		}
+		if got := times["pkg/service/auth_test.go"]; !floatEqual(got, 7.334) {
+			t.Errorf("auth_test.go: got %.3f, want 7.334 (accumulated)", got)
+		}
+	})
 
-		// example1.xml has auth_test.go with 5.234s
-		// example2.xml has auth_test.go with 2.100s
-		// Should accumulate to 7.334s
-		authTime := times["pkg/service/auth_test.go"]
-		expected := 7.334
-		if !floatEqual(authTime, expected) {
-			t.Errorf("auth_test.go: got %.3f, want %.3f (accumulated)", authTime, expected)
+	t.Run("multiple files, latest", func(t *testing.T) {
+		dir := t.TempDir()
+		reports := map[string]string{
+			// Sorted last, but dated first
+			"c.xml": `<testsuite file="a_test.go" time="1" timestamp="2026-01-01T10:00:00"/>`,
+			"b.xml": `<testsuites><testsuite file="a_test.go" time="2" timestamp="2026-01-02T10:00:00Z"/>` +
+				`<testsuite file="b_test.go" time="4"/></testsuites>`,
+			// Dated by its modification time, older than the timestamps
+			"a.xml": `<testsuite file="b_test.go" time="8"/>`,
+		}
+		for name, content := range reports {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+		}
+		old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := os.Chtimes(filepath.Join(dir, "a.xml"), old, old); err != nil {
+			t.Fatalf("Failed to date test file: %v", err)
 		}
 
-		// example2.xml has connection_test.go
-		connTime := times["pkg/db/connection_test.go"]
-		if !floatEqual(connTime, 12.567) {
-			t.Errorf("connection_test.go: got %.3f, want 12.567", connTime)
+		times, err := junit.NewParser(logger, junit.WithMerge(junit.MergeLatest)).LoadFiles(t.Context(), []string{dir})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if !floatEqual(times["a_test.go"], 2) || !floatEqual(times["b_test.go"], 4) {
+			t.Errorf("Got %v, want the times of the newest report, b.xml", times)
 		}
 	})
 
 	t.Run("glob pattern", func(t *testing.T) {
 		pattern := "../../testdata/junit/example*.xml"
-		times, err := parser.LoadFiles([]string{pattern})
+		times, err := parser.LoadFiles(t.Context(), []string{pattern})
 		if err != nil {
 			t.Fatalf("LoadFiles failed: %v", err)
 		}
@@ -88,7 +135,7 @@ func TestParser_LoadFiles(t *testing.T) {
 
 	t.Run("nested testsuites", func(t *testing.T) {
 		pattern := "../../testdata/junit/nested.xml"
-		times, err := parser.LoadFiles([]string{pattern})
+		times, err := parser.LoadFiles(t.Context(), []string{pattern})
 		if err != nil {
 			t.Fatalf("LoadFiles failed: %v", err)
 		}
@@ -112,7 +159,7 @@ func TestParser_LoadFiles(t *testing.T) {
 
 	t.Run("comma decimal separator", func(t *testing.T) {
 		pattern := "../../testdata/junit/comma-decimal.xml"
-		times, err := parser.LoadFiles([]string{pattern})
+		times, err := parser.LoadFiles(t.Context(), []string{pattern})
 		if err != nil {
 			t.Fatalf("LoadFiles failed: %v", err)
 		}
@@ -124,9 +171,52 @@ func TestParser_LoadFiles(t *testing.T) {
 		}
 	})
 
+	t.Run("thousands separators", func(t *testing.T) {
+		pattern := "../../testdata/junit/thousands-separators.xml"
+		times, err := parser.LoadFiles(t.Context(), []string{pattern})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+
+		expected := map[string]float64{
+			"pkg/locale/dot_thousands_test.go": 1234.567,
+			"pkg/locale/comma_decimal_test.go": 1234.567,
+			"pkg/locale/single_comma_test.go":  12.5,
+		}
+		for file, want := range expected {
+			if got := times[file]; !floatEqual(got, want) {
+				t.Errorf("File %q: got time=%.3f, want %.3f", file, got, want)
+			}
+		}
+		if _, ok := times["pkg/locale/unparseable_test.go"]; ok {
+			t.Error("Unparseable time should be skipped")
+		}
+	})
+
+	t.Run("normalized file paths", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "paths.xml")
+		content := `<testsuites>
+  <testsuite name="A" file="./pkg/api/handler_test.go" time="1.5"/>
+  <testsuite name="B" file="pkg//api/handler_test.go" time="2.5"/>
+  <testsuite name="C" file="pkg/tmp/../db/conn_test.go" time="3"/>
+</testsuites>`
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		times, err := parser.LoadFiles(t.Context(), []string{path})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if len(times) != 2 || !floatEqual(times["pkg/api/handler_test.go"], 4.0) ||
+			!floatEqual(times["pkg/db/conn_test.go"], 3.0) {
+			t.Errorf("Got %v, want keys merged under their cleaned paths", times)
+		}
+	})
+
 	t.Run("no matching files", func(t *testing.T) {
 		pattern := "../../testdata/junit/nonexistent-*.xml"
-		_, err := parser.LoadFiles([]string{pattern})
+		_, err := parser.LoadFiles(t.Context(), []string{pattern})
 		if err == nil {
 			t.Error("Expected error for non-matching pattern, got nil")
 		}
@@ -141,20 +231,19 @@ func TestParser_LoadFiles(t *testing.T) {
 			t.Fatalf("Failed to create test file: %v", err)
 		}
 
-		times, err := parser.LoadFiles([]string{invalidFile})
-		// Should not return error, but log warning and continue
-		if err != nil {
-			t.Errorf("LoadFiles returned error for invalid XML: %v", err)
+		times, err := parser.LoadFiles(t.Context(), []string{invalidFile})
+		// The only file failed, so there is nothing to continue with
+		if err == nil || !strings.Contains(err.Error(), "invalid.xml") {
+			t.Errorf("Expected error naming invalid.xml, got %v", err)
 		}
-		// Should return empty or partial results
 		if len(times) > 0 {
-			t.Logf("Got %d times from invalid file (warnings logged)", len(times))
+			t.Errorf("Got %d times from invalid file, want none", len(times))
 		}
 	})
 
 	t.Run("missing file", func(t *testing.T) {
 		pattern := "/nonexistent/path/file.xml"
-		_, err := parser.LoadFiles([]string{pattern})
+		_, err := parser.LoadFiles(t.Context(), []string{pattern})
 		// Should return error for no matching files
 		if err == nil {
 			t.Error("Expected error for missing file pattern, got nil")
@@ -162,16 +251,688 @@ func TestParser_LoadFiles(t *testing.T) {
 	})
 }
 
+func TestParser_InvalidTimes(t *testing.T) {
+	pattern := "../../testdata/junit/invalid-times.xml"
+
+	t.Run("rejects negative, non-finite, and absurd times", func(t *testing.T) {
+		var logs bytes.Buffer
+		logger := zerolog.New(&logs)
+
+		times, err := junit.NewParser(logger).LoadFiles(t.Context(), []string{pattern})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+
+		if len(times) != 1 || !floatEqual(times["pkg/times/valid_test.go"], 2.5) {
+			t.Errorf("Got %v, want only the valid entry", times)
+		}
+		for _, suite := range []string{"TestNegative", "TestNaN", "TestInf", "TestAbsurd"} {
+			if !strings.Contains(logs.String(), `"suite":"`+suite+`"`) {
+				t.Errorf("Expected a warning naming suite %s", suite)
+			}
+		}
+		if !strings.Contains(logs.String(), `"rejected":4`) {
+			t.Errorf("Expected the load log to report 4 rejected entries, got:\n%s", logs.String())
+		}
+	})
+
+	t.Run("ceiling is configurable", func(t *testing.T) {
+		logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+		times, err := junit.NewParser(logger, junit.WithMaxTime(0)).LoadFiles(t.Context(), []string{pattern})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if !floatEqual(times["pkg/times/absurd_test.go"], 100000) {
+			t.Errorf("Disabled ceiling: got %v, want the absurd entry kept", times)
+		}
+
+		// Every time is rejected, so the file contributes nothing
+		times, err = junit.NewParser(logger, junit.WithMaxTime(1)).LoadFiles(t.Context(), []string{pattern})
+		if !errors.Is(err, junit.ErrNoUsableStats) {
+			t.Fatalf("LoadFiles error = %v, want ErrNoUsableStats", err)
+		}
+		if len(times) != 0 {
+			t.Errorf("Ceiling of 1s: got %v, want no entries", times)
+		}
+	})
+}
+
+func TestParser_FailedFiles(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	parser := junit.NewParser(logger)
+
+	good, err := os.ReadFile("../../testdata/junit/example1.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	bad := []byte("<invalid>not closed")
+
+	writeDir := func(t *testing.T, files map[string][]byte) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, data := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("all bad", func(t *testing.T) {
+		dir := writeDir(t, map[string][]byte{
+			"a.xml": bad, "b.xml": bad, "c.xml": bad, "d.xml": bad, "e.xml": []byte("<html/>"),
+		})
+
+		_, err := parser.LoadFiles(t.Context(), []string{dir})
+		if err == nil {
+			t.Fatal("Expected error when every file fails, got nil")
+		}
+		msg := err.Error()
+		for _, want := range []string{"5 failed", "a.xml, line 1, column 10: cannot parse XML", "c.xml", "and 2 more"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("Error %q does not contain %q", msg, want)
+			}
+		}
+		if strings.Contains(msg, "d.xml") {
+			t.Errorf("Error %q should only name the first files", msg)
+		}
+	})
+
+	t.Run("all empty", func(t *testing.T) {
+		empty := []byte("<testsuites></testsuites>")
+		dir := writeDir(t, map[string][]byte{"a.xml": empty, "b.xml": empty})
+
+		times, err := parser.LoadFiles(t.Context(), []string{dir})
+		if !errors.Is(err, junit.ErrNoUsableStats) {
+			t.Fatalf("LoadFiles error = %v, want ErrNoUsableStats", err)
+		}
+		msg := err.Error()
+		for _, want := range []string{"2 held no usable suites", "a.xml", "b.xml"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("Error %q does not contain %q", msg, want)
+			}
+		}
+		if len(times) != 0 {
+			t.Errorf("Got %d entries, want none", len(times))
+		}
+	})
+
+	t.Run("mixed", func(t *testing.T) {
+		dir := writeDir(t, map[string][]byte{"bad.xml": bad, "good.xml": good})
+
+		times, err := parser.LoadFiles(t.Context(), []string{dir})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if len(times) != 3 {
+			t.Errorf("Got %d entries, want 3 from the good file", len(times))
+		}
+	})
+
+	t.Run("all good", func(t *testing.T) {
+		dir := writeDir(t, map[string][]byte{"one.xml": good, "two.xml": good})
+
+		times, err := parser.LoadFiles(t.Context(), []string{dir})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if len(times) != 3 {
+			t.Errorf("Got %d entries, want 3", len(times))
+		}
+	})
+}
+
+func TestParser_DedupeNested(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		dedupe   float64 // suppressed seconds
+		expected map[string]float64
+		doubled  map[string]float64
+	}{
+		{
+			name:     "PHPUnit data provider suites",
+			fixture:  "../../testdata/junit/phpunit-nested.xml",
+			dedupe:   0.6,
+			expected: map[string]float64{"/app/tests/Unit/ParserTest.php": 0.6, "/app/tests/Unit/MailerTest.php": 0.3},
+			doubled:  map[string]float64{"/app/tests/Unit/ParserTest.php": 1.2, "/app/tests/Unit/MailerTest.php": 0.3},
+		},
+		{
+			name:    "jest-junit describe blocks",
+			fixture: "../../testdata/junit/jest-junit-nested.xml",
+			dedupe:  3.02,
+			// The user.test.js parent (1.2s) is far from its child's 0.4s, so it is kept
+			expected: map[string]float64{"src/cart.test.js": 3.0, "src/user.test.js": 1.6},
+			doubled:  map[string]float64{"src/cart.test.js": 6.02, "src/user.test.js": 1.6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			times, err := junit.NewParser(zerolog.New(&logs)).LoadFiles(t.Context(), []string{tt.fixture})
+			if err != nil {
+				t.Fatalf("LoadFiles failed: %v", err)
+			}
+			assertTimes(t, times, tt.expected)
+			if want := fmt.Sprintf(`"suppressed":%g`, tt.dedupe); !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected load log to contain %s, got:\n%s", want, logs.String())
+			}
+
+			logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+			parser := junit.NewParser(logger, junit.WithDedupeNested(false))
+			times, err = parser.LoadFiles(t.Context(), []string{tt.fixture})
+			if err != nil {
+				t.Fatalf("LoadFiles failed: %v", err)
+			}
+			assertTimes(t, times, tt.doubled)
+		})
+	}
+}
+
+func assertTimes(t *testing.T, got, want map[string]float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("Got %d entries, want %d: %v", len(got), len(want), got)
+	}
+	for file, expected := range want {
+		if !floatEqual(got[file], expected) {
+			t.Errorf("File %q: got time=%.3f, want %.3f", file, got[file], expected)
+		}
+	}
+}
+
+func TestParser_LeadingBytes(t *testing.T) {
+	t.Run("BOM and banner are skipped", func(t *testing.T) {
+		var logs bytes.Buffer
+		parser := junit.NewParser(zerolog.New(&logs))
+		times, err := parser.LoadFiles(t.Context(), []string{
+			"../../testdata/junit/bom.xml",
+			"../../testdata/junit/banner.xml",
+		})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+
+		assertTimes(t, times, map[string]float64{
+			"pkg/windows/bom_test.go":    2.5,
+			"pkg/windows/banner_test.go": 4.0,
+		})
+		if !strings.Contains(logs.String(), `"bytes":3`) || !strings.Contains(logs.String(), `"bytes":55`) {
+			t.Errorf("Expected skipped bytes to be logged for both files, got:\n%s", logs.String())
+		}
+	})
+
+	t.Run("garbage still fails", func(t *testing.T) {
+		logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"text.xml":  "this is not xml at all\n",
+			"after.xml": "banner\n<html><body/></html>",
+		} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			_, err := junit.NewParser(logger, junit.WithStrict(true)).LoadFiles(t.Context(), []string{path})
+			if err == nil {
+				t.Errorf("%s: expected error, got nil", name)
+			}
+		}
+	})
+}
+
+func TestParser_SkipReasons(t *testing.T) {
+	pattern := "../../testdata/junit/skip-reasons.xml"
+	want := `"count":1,"rejected":0,"suppressed":0,"skipped":{"missing_file":1,"missing_time":3,"unparseable":1}`
+
+	for _, cached := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cached=%t", cached), func(t *testing.T) {
+			cacheDir := t.TempDir()
+			if cached {
+				// Populate the cache so the counts below come from the stored entry
+				warm := junit.NewParser(zerolog.New(os.Stderr).Level(zerolog.Disabled), junit.WithCacheDir(cacheDir))
+				if _, err := warm.LoadFiles(t.Context(), []string{pattern}); err != nil {
+					t.Fatalf("LoadFiles failed: %v", err)
+				}
+			}
+
+			var logs bytes.Buffer
+			parser := junit.NewParser(zerolog.New(&logs), junit.WithCacheDir(cacheDir))
+			times, err := parser.LoadFiles(t.Context(), []string{pattern})
+			if err != nil {
+				t.Fatalf("LoadFiles failed: %v", err)
+			}
+
+			assertTimes(t, times, map[string]float64{"pkg/skip/loaded_test.go": 1.5})
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected load log to contain %s, got:\n%s", want, logs.String())
+			}
+		})
+	}
+}
+
+func TestParser_TestCaseTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		expected map[string]float64
+		log      string
+	}{
+		{
+			name:    "pytest suite without a file",
+			fixture: "../../testdata/junit/testcase-only.xml",
+			// The suite's 7.5s has no key, the test case without a time is skipped
+			expected: map[string]float64{"tests/test_users.py": 2.0, "tests/test_orders.py": 5.5},
+			log:      `"testcases":4`,
+		},
+		{
+			name:    "suite and test case times mixed",
+			fixture: "../../testdata/junit/mixed-levels.xml",
+			// api and auth keep their own time, test cases only count where no suite time covers them
+			expected: map[string]float64{
+				"pkg/api/handler_test.go":   3.0,
+				"pkg/db/conn_test.go":       0.5,
+				"pkg/db/migrate_test.go":    2.0,
+				"pkg/service/setup_test.go": 0.25,
+				"pkg/service/auth_test.go":  4.0,
+				"pkg/service/user_test.go":  1.5,
+			},
+			log: `"testcases":4`,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, cached := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/cached=%t", tt.name, cached), func(t *testing.T) {
+				cacheDir := t.TempDir()
+				if cached {
+					logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+					warm := junit.NewParser(logger, junit.WithCacheDir(cacheDir))
+					if _, err := warm.LoadFiles(t.Context(), []string{tt.fixture}); err != nil {
+						t.Fatalf("LoadFiles failed: %v", err)
+					}
+				}
+
+				var logs bytes.Buffer
+				parser := junit.NewParser(zerolog.New(&logs), junit.WithCacheDir(cacheDir))
+				times, err := parser.LoadFiles(t.Context(), []string{tt.fixture})
+				if err != nil {
+					t.Fatalf("LoadFiles failed: %v", err)
+				}
+				assertTimes(t, times, tt.expected)
+				if !strings.Contains(logs.String(), tt.log) {
+					t.Errorf("Expected load log to contain %s, got:\n%s", tt.log, logs.String())
+				}
+			})
+		}
+	}
+}
+
+func TestParser_IgnoreSkipped(t *testing.T) {
+	fixture := "../../testdata/junit/skipped.xml"
+
+	for _, cached := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cached=%t", cached), func(t *testing.T) {
+			cacheDir := t.TempDir()
+			if cached {
+				logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+				warm := junit.NewParser(logger, junit.WithCacheDir(cacheDir))
+				if _, err := warm.LoadFiles(t.Context(), []string{fixture}); err != nil {
+					t.Fatalf("LoadFiles failed: %v", err)
+				}
+			}
+
+			var logs bytes.Buffer
+			parser := junit.NewParser(zerolog.New(&logs), junit.WithCacheDir(cacheDir))
+			times, err := parser.LoadFiles(t.Context(), []string{fixture})
+			if err != nil {
+				t.Fatalf("LoadFiles failed: %v", err)
+			}
+			assertTimes(t, times, map[string]float64{
+				"pkg/partial/partial_test.go": 2.5,
+				"pkg/gpu/gpu_test.go":         0,
+				"tests/test_a.py":             2.0,
+				"tests/test_b.py":             1.0,
+			})
+			if want := `"ignored_skipped":5`; !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected load log to contain %s, got:\n%s", want, logs.String())
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		var logs bytes.Buffer
+		parser := junit.NewParser(zerolog.New(&logs), junit.WithIgnoreSkipped(false))
+		times, err := parser.LoadFiles(t.Context(), []string{fixture})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		assertTimes(t, times, map[string]float64{
+			"pkg/partial/partial_test.go": 4.0,
+			"pkg/gpu/gpu_test.go":         3.0,
+			"tests/test_a.py":             2.0,
+			"tests/test_b.py":             7.0,
+		})
+		if want := `"ignored_skipped":0`; !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected load log to contain %s, got:\n%s", want, logs.String())
+		}
+	})
+}
+
+func TestParser_Gzip(t *testing.T) {
+	example1 := map[string]float64{
+		"pkg/service/auth_test.go": 5.234,
+		"pkg/service/user_test.go": 3.456,
+		"pkg/api/handler_test.go":  8.901,
+	}
+
+	t.Run("glob and directory", func(t *testing.T) {
+		logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+		times, err := junit.NewParser(logger).LoadFiles(t.Context(), []string{"../../testdata/junit/example1.xml.gz"})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		assertTimes(t, times, example1)
+
+		// Directories pick up compressed reports, and the magic bytes win over the name
+		dir := t.TempDir()
+		data, err := os.ReadFile("../../testdata/junit/example1.xml.gz")
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		for _, name := range []string{"report.xml.gz", "renamed.xml"} {
+			if err = os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+		}
+		times, err = junit.NewParser(logger).LoadFiles(t.Context(), []string{dir})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		doubled := make(map[string]float64, len(example1))
+		for file, time := range example1 {
+			doubled[file] = 2 * time
+		}
+		assertTimes(t, times, doubled)
+	})
+
+	t.Run("corrupted streams warn and continue", func(t *testing.T) {
+		badHeader := filepath.Join(t.TempDir(), "bad-header.xml.gz")
+		if err := os.WriteFile(badHeader, []byte("\x1f\x8bnot really gzip"), 0o600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		patterns := []string{"../../testdata/junit/corrupt.xml.gz", badHeader, "../../testdata/junit/example2.xml"}
+
+		var logs bytes.Buffer
+		times, err := junit.NewParser(zerolog.New(&logs)).LoadFiles(t.Context(), patterns)
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if len(times) == 0 {
+			t.Error("Expected the times of example2.xml")
+		}
+		for _, want := range []string{"corrupt.xml.gz", "cannot decompress file"} {
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected a warning mentioning %q, got:\n%s", want, logs.String())
+			}
+		}
+
+		logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+		if _, err = junit.NewParser(logger, junit.WithStrict(true)).LoadFiles(t.Context(), patterns); err == nil {
+			t.Error("Expected a strict parser to fail on a corrupted stream")
+		}
+	})
+}
+
+func TestParser_JSONTimings(t *testing.T) {
+	patterns := []string{"../../testdata/junit/example1.xml", "../../testdata/timings/timings.json"}
+
+	t.Run("merged with reports", func(t *testing.T) {
+		var logs bytes.Buffer
+		times, err := junit.NewParser(zerolog.New(&logs)).LoadFiles(t.Context(), patterns)
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		assertTimes(t, times, map[string]float64{
+			"pkg/service/auth_test.go": 10.0,
+			"pkg/service/user_test.go": 3.456,
+			"pkg/api/handler_test.go":  10.0,
+			"pkg/new/new_test.go":      2.5,
+		})
+		for _, want := range []string{
+			`"count":3`, `"rejected":1`, `"unparseable":2`, `"test":"pkg/bad/word_test.go"`, `"time":"null"`,
+		} {
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected logs to contain %s, got:\n%s", want, logs.String())
+			}
+		}
+	})
+
+	t.Run("merge strategy", func(t *testing.T) {
+		logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+		times, err := junit.NewParser(logger, junit.WithMerge(junit.MergeMax)).LoadFiles(t.Context(), patterns)
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		assertTimes(t, times, map[string]float64{
+			"pkg/service/auth_test.go": 5.234,
+			"pkg/service/user_test.go": 3.456,
+			"pkg/api/handler_test.go":  8.901,
+			"pkg/new/new_test.go":      2.5,
+		})
+	})
+
+	t.Run("malformed JSON warns and continues", func(t *testing.T) {
+		malformed := []string{"../../testdata/timings/malformed.json", "../../testdata/junit/example1.xml"}
+
+		var logs bytes.Buffer
+		times, err := junit.NewParser(zerolog.New(&logs)).LoadFiles(t.Context(), malformed)
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		assertTimes(t, times, map[string]float64{
+			"pkg/service/auth_test.go": 5.234,
+			"pkg/service/user_test.go": 3.456,
+			"pkg/api/handler_test.go":  8.901,
+		})
+		for _, want := range []string{"malformed.json", "invalid JSON timings", "Failed to load file"} {
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("Expected a warning mentioning %q, got:\n%s", want, logs.String())
+			}
+		}
+
+		logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+		if _, err = junit.NewParser(logger, junit.WithStrict(true)).LoadFiles(t.Context(), malformed); err == nil {
+			t.Error("Expected a strict parser to fail on malformed JSON")
+		}
+	})
+}
+
+func TestParser_TruncatedFile(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	pattern := "../../testdata/junit/truncated.xml"
+
+	t.Run("keeps suites parsed before truncation", func(t *testing.T) {
+		times, err := junit.NewParser(logger).LoadFiles(t.Context(), []string{pattern})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+
+		expected := map[string]float64{
+			"pkg/service/auth_test.go": 5.234,
+			"pkg/service/user_test.go": 3.456,
+		}
+		if len(times) != len(expected) {
+			t.Errorf("Got %d entries, want %d: %v", len(times), len(expected), times)
+		}
+		for file, expectedTime := range expected {
+			if !floatEqual(times[file], expectedTime) {
+				t.Errorf("File %q: got time=%.3f, want %.3f", file, times[file], expectedTime)
+			}
+		}
+	})
+
+	t.Run("strict mode fails", func(t *testing.T) {
+		_, err := junit.NewParser(logger, junit.WithStrict(true)).LoadFiles(t.Context(), []string{pattern})
+		if err == nil {
+			t.Error("Expected error for truncated file in strict mode, got nil")
+		}
+	})
+
+	t.Run("appended garbage", func(t *testing.T) {
+		data, err := os.ReadFile("../../testdata/junit/example1.xml")
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		path := filepath.Join(t.TempDir(), "appended.xml")
+		if err = os.WriteFile(path, append(data, []byte("<partial><testsuite file=")...), 0o600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		times, err := junit.NewParser(logger).LoadFiles(t.Context(), []string{path})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if len(times) != 3 {
+			t.Errorf("Got %d entries, want 3: %v", len(times), times)
+		}
+	})
+}
+
+func TestParser_LoadReader(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	data, err := os.ReadFile("../../testdata/junit/truncated.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	t.Run("keeps suites parsed before truncation", func(t *testing.T) {
+		times, err := junit.NewParser(logger).LoadReader(t.Context(), bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("LoadReader failed: %v", err)
+		}
+		assertTimes(t, times, map[string]float64{
+			"pkg/service/auth_test.go": 5.234,
+			"pkg/service/user_test.go": 3.456,
+		})
+	})
+
+	t.Run("strict mode fails", func(t *testing.T) {
+		parser := junit.NewParser(logger, junit.WithStrict(true))
+		if _, err := parser.LoadReader(t.Context(), bytes.NewReader(data)); err == nil {
+			t.Error("Expected error for truncated input in strict mode, got nil")
+		}
+	})
+
+	t.Run("no suites fails", func(t *testing.T) {
+		if _, err := junit.NewParser(logger).LoadReader(t.Context(), strings.NewReader("not xml")); err == nil {
+			t.Error("Expected error for input without suites, got nil")
+		}
+	})
+}
+
+func TestParser_Cancelled(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := junit.NewParser(logger).LoadFiles(ctx, []string{"../../testdata/junit/*.xml"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("LoadFiles: got %v, want context.Canceled", err)
+	}
+
+	_, err = junit.NewParser(logger).LoadReader(ctx, strings.NewReader(`<testsuite file="a_test.go" time="1"/>`))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("LoadReader: got %v, want context.Canceled", err)
+	}
+}
+
 func TestParser_EmptyInput(t *testing.T) {
 	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
 	parser := junit.NewParser(logger)
 
-	_, err := parser.LoadFiles([]string{})
-	if err == nil {
-		t.Error("Expected error for empty patterns list, got nil")
+	_, err := parser.LoadFiles(t.Context(), []string{})
+	if !errors.Is(err, junit.ErrNoStatsMatched) {
+		t.Errorf("Expected ErrNoStatsMatched for empty patterns list, got %v", err)
 	}
 }
 
+func TestParser_ParseError(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantLine   int
+		wantColumn int
+		wantOffset int64
+	}{
+		{
+			name: "syntax error after a banner",
+			path: write("banner.xml",
+				"Running tests\n<testsuites>\n  <testsuite file=\"a\" time=\"1\">\n</testsuites>"),
+			wantLine:   4,
+			wantColumn: 14,
+		},
+		{
+			name:       "unexpected root element",
+			path:       write("root.xml", "<html/>"),
+			wantLine:   1,
+			wantColumn: 8,
+		},
+		{
+			name:       "no markup",
+			path:       write("text.xml", "not xml"),
+			wantOffset: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := junit.NewParser(logger, junit.WithStrict(true))
+			_, err := parser.LoadFiles(t.Context(), []string{tt.path})
+
+			var parseErr *junit.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a ParseError, got %v", err)
+			}
+			if parseErr.File != tt.path {
+				t.Errorf("File: got %q, want %q", parseErr.File, tt.path)
+			}
+			if parseErr.Line != tt.wantLine || parseErr.Column != tt.wantColumn {
+				t.Errorf("Position: got %d:%d, want %d:%d", parseErr.Line, parseErr.Column, tt.wantLine, tt.wantColumn)
+			}
+			if tt.wantLine == 0 && parseErr.Offset != tt.wantOffset {
+				t.Errorf("Offset: got %d, want %d", parseErr.Offset, tt.wantOffset)
+			}
+			if strings.Count(err.Error(), tt.path) != 1 {
+				t.Errorf("Error should name the file once, got %q", err)
+			}
+		})
+	}
+
+	t.Run("reader has no file name", func(t *testing.T) {
+		_, err := junit.NewParser(logger).LoadReader(t.Context(), strings.NewReader("<html/>"))
+		var parseErr *junit.ParseError
+		if !errors.As(err, &parseErr) || parseErr.File != "" {
+			t.Fatalf("Expected an unnamed ParseError, got %v", err)
+		}
+		if err.Error() != "line 1, column 8: cannot parse XML: unexpected root element <html>" {
+			t.Errorf("Unexpected message %q", err)
+		}
+	})
+}
+
 // floatEqual checks if two floats are equal within tolerance.
 func floatEqual(a, b float64) bool {
 	diff := a - b
@@ -180,3 +941,119 @@ func floatEqual(a, b float64) bool {
 	}
 	return diff <= 0.001
 }
+
+func TestParser_LoadFilesConcurrencyIsDeterministic(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+	// Generate files sharing keys so that merge order affects float sums
+	tmpDir := t.TempDir()
+	rng := rand.New(rand.NewPCG(7, 11))
+	for i := range 100 {
+		var sb strings.Builder
+		sb.WriteString("<testsuites>\n")
+		for j := range 50 {
+			fmt.Fprintf(&sb, "  <testsuite file=\"pkg/file%d_test.go\" time=\"%.6f\"/>\n", (i+j)%40, rng.Float64()*100)
+		}
+		sb.WriteString("</testsuites>\n")
+
+		path := filepath.Join(tmpDir, fmt.Sprintf("report-%03d.xml", i))
+		if err := os.WriteFile(path, []byte(sb.String()), 0o600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	pattern := filepath.Join(tmpDir, "*.xml")
+	serial, err := junit.NewParser(logger, junit.WithConcurrency(1)).LoadFiles(t.Context(), []string{pattern})
+	if err != nil {
+		t.Fatalf("LoadFiles (serial) failed: %v", err)
+	}
+
+	for _, concurrency := range []int{2, 8, 100} {
+		t.Run(fmt.Sprintf("concurrency=%d", concurrency), func(t *testing.T) {
+			parallel, err := junit.NewParser(logger, junit.WithConcurrency(concurrency)).
+				LoadFiles(t.Context(), []string{pattern})
+			if err != nil {
+				t.Fatalf("LoadFiles (parallel) failed: %v", err)
+			}
+
+			if len(parallel) != len(serial) {
+				t.Fatalf("Got %d keys, want %d", len(parallel), len(serial))
+			}
+			for name, want := range serial {
+				got, ok := parallel[name]
+				if !ok {
+					t.Errorf("Key %q missing from parallel result", name)
+					continue
+				}
+				if math.Float64bits(got) != math.Float64bits(want) {
+					t.Errorf("Key %q: got %v, want %v (bit-for-bit)", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_LoadFilesProgress(t *testing.T) {
+	var done []int
+	report := progress.Func(func(stage string, n, total int) {
+		if stage != progress.StageParse || total != 3 {
+			t.Errorf("OnStage(%q, %d, %d), want stage parse of 3", stage, n, total)
+		}
+		done = append(done, n)
+	})
+	parser := junit.NewParser(zerolog.Nop(), junit.WithConcurrency(2), junit.WithProgress(report))
+
+	patterns := []string{"../../testdata/junit/example1.xml", "../../testdata/junit/example2.xml",
+		"../../testdata/junit/missing-*.xml", "../../testdata/junit/nested.xml"}
+	if _, err := parser.LoadFiles(t.Context(), patterns); err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	if len(done) != 3 || done[0] != 1 || done[1] != 2 || done[2] != 3 {
+		t.Errorf("Reported %v, want [1 2 3]", done)
+	}
+}
+
+func TestParser_Cases(t *testing.T) {
+	dir := t.TempDir()
+	reports := map[string]string{
+		"run1.xml": `<testsuites>
+  <testsuite name="api" file="pkg/api_test.go" time="3">
+    <testcase name="TestA" time="1"/>
+    <testcase name="TestB" time="1"/>
+    <testcase name="TestSkipped" time="1"><skipped/></testcase>
+  </testsuite>
+  <testsuite name="db">
+    <testcase name="TestC" file="pkg/db_test.go" time="1"/>
+  </testsuite>
+</testsuites>`,
+		"run2.xml": `<testsuite name="db">
+  <testcase name="TestC" file="pkg/db_test.go" time="1"/>
+  <testcase name="TestD" file="pkg/db_test.go" time="1"/>
+</testsuite>`,
+	}
+	for name, content := range reports {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := junit.NewParser(zerolog.Nop()).Cases(filepath.Join(dir, "*.xml"))
+	cases, err := source.Load(t.Context())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Suites count their test cases but skipped ones, test cases without a suite time count one
+	// each, and a key found in both runs keeps its largest count
+	assertTimes(t, cases, map[string]float64{"pkg/api_test.go": 2, "pkg/db_test.go": 2})
+
+	source = junit.NewParser(zerolog.Nop(), junit.WithIgnoreSkipped(false)).Cases(filepath.Join(dir, "run1.xml"))
+	if cases, err = source.Load(t.Context()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	assertTimes(t, cases, map[string]float64{"pkg/api_test.go": 3, "pkg/db_test.go": 1})
+
+	_, err = junit.NewParser(zerolog.Nop()).Cases(filepath.Join(dir, "*.missing")).Load(t.Context())
+	if !errors.Is(err, junit.ErrNoStatsMatched) {
+		t.Errorf("Load error = %v, want ErrNoStatsMatched", err)
+	}
+}
@@ -0,0 +1,27 @@
+package junit
+
+import (
+	"context"
+	"strings"
+)
+
+// FileSource loads test times from the JUnit XML reports matching a set of patterns.
+type FileSource struct {
+	parser   *Parser
+	patterns []string
+}
+
+// Files returns a source loading the reports matching patterns with p.
+func (p *Parser) Files(patterns ...string) *FileSource {
+	return &FileSource{parser: p, patterns: patterns}
+}
+
+// Load parses the matching reports. See Parser.LoadFiles.
+func (s *FileSource) Load(ctx context.Context) (map[string]float64, error) {
+	return s.parser.LoadFiles(ctx, s.patterns)
+}
+
+// Describe returns "junit:" followed by the comma-separated patterns.
+func (s *FileSource) Describe() string {
+	return "junit:" + strings.Join(s.patterns, ",")
+}
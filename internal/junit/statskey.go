@@ -0,0 +1,50 @@
+package junit
+
+import "fmt"
+
+// StatsKey selects which attribute names the stats key of a suite or test case with GranularityFile.
+type StatsKey string
+
+const (
+	StatsKeyFile      StatsKey = "file"      // The file attribute (default)
+	StatsKeyClassname StatsKey = "classname" // The classname attribute of the test cases
+	StatsKeyAuto      StatsKey = "auto"      // The file attribute, or the classname when it is missing
+)
+
+// ParseStatsKey validates a stats key attribute given on the command line.
+func ParseStatsKey(s string) (StatsKey, error) {
+	switch k := StatsKey(s); k {
+	case StatsKeyFile, StatsKeyClassname, StatsKeyAuto:
+		return k, nil
+	default:
+		return "", fmt.Errorf("unknown stats key %q (must be %q, %q, or %q)",
+			s, StatsKeyFile, StatsKeyClassname, StatsKeyAuto)
+	}
+}
+
+// WithStatsKey sets which attribute names the stats keys, for producers such as Surefire and
+// pytest that fill classname but never file. Suites carry no classname, so a suite is keyed by
+// the classname shared by all its test cases, and otherwise contributes the times of its test
+// cases keyed by their own classname. Classnames such as "tests.test_users" are normalized like
+// file attributes, which leaves them unchanged, so the test list must hold them in the same form.
+// It has no effect with GranularitySuite.
+func WithStatsKey(k StatsKey) Option {
+	return func(p *Parser) {
+		p.statsKey = k
+	}
+}
+
+// sharedClassName returns the classname of every test case of suite, or "" when they have
+// none or differ.
+func sharedClassName(suite TestSuite) string {
+	if len(suite.TestCases) == 0 {
+		return ""
+	}
+	name := suite.TestCases[0].ClassName
+	for _, tc := range suite.TestCases[1:] {
+		if tc.ClassName != name {
+			return ""
+		}
+	}
+	return name
+}
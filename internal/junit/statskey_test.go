@@ -0,0 +1,108 @@
+package junit_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+const classnameReports = "../../testdata/junit/classname"
+
+func TestParseStatsKey(t *testing.T) {
+	for _, k := range []junit.StatsKey{junit.StatsKeyFile, junit.StatsKeyClassname, junit.StatsKeyAuto} {
+		got, err := junit.ParseStatsKey(string(k))
+		if err != nil || got != k {
+			t.Errorf("ParseStatsKey(%q) = %q, %v", k, got, err)
+		}
+	}
+	if _, err := junit.ParseStatsKey("name"); err == nil {
+		t.Error("Expected error for unknown stats key, got nil")
+	}
+}
+
+func TestParser_StatsKey(t *testing.T) {
+	tests := []struct {
+		key      junit.StatsKey
+		expected map[string]float64
+	}{
+		{
+			// pytest and Surefire name no files, so only the file attributes of both.xml count
+			key:      junit.StatsKeyFile,
+			expected: map[string]float64{"tests/test_api.py": 1.5, "tests/test_legacy.py": 2.0},
+		},
+		{
+			// The Surefire suite and the legacy suite keep their own time, keyed by their shared classname
+			key: junit.StatsKeyClassname,
+			expected: map[string]float64{
+				"tests.test_users":             2.0,
+				"tests.api.test_orders":        4.1,
+				"com.example.OrderServiceTest": 6.5,
+				"tests.test_api":               1.5,
+				"tests.test_db":                1.5,
+				"tests.test_legacy":            2.0,
+			},
+		},
+		{
+			// File attributes win where present
+			key: junit.StatsKeyAuto,
+			expected: map[string]float64{
+				"tests.test_users":             2.0,
+				"tests.api.test_orders":        4.1,
+				"com.example.OrderServiceTest": 6.5,
+				"tests/test_api.py":            1.5,
+				"tests.test_db":                1.5,
+				"tests/test_legacy.py":         2.0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.key), func(t *testing.T) {
+			var logs bytes.Buffer
+			parser := junit.NewParser(zerolog.New(&logs), junit.WithStatsKey(tt.key))
+			times, err := parser.LoadFiles(t.Context(), []string{classnameReports})
+			if err != nil {
+				t.Fatalf("LoadFiles failed: %v", err)
+			}
+			assertTimes(t, times, tt.expected)
+			if tt.key != junit.StatsKeyFile && strings.Contains(logs.String(), "mix file-based and suite-based") {
+				t.Errorf("Unexpected mixed report warning:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestParser_StatsKeyCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	if _, err := junit.NewParser(logger, junit.WithCacheDir(cacheDir)).
+		LoadFiles(t.Context(), []string{classnameReports}); err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+
+	// Entries cached by file are not served when keying by classname
+	parser := junit.NewParser(logger, junit.WithCacheDir(cacheDir), junit.WithStatsKey(junit.StatsKeyClassname))
+	times, err := parser.LoadFiles(t.Context(), []string{classnameReports})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	if len(times) != 6 {
+		t.Errorf("Times = %v, want the six classnames", times)
+	}
+}
+
+func TestParser_StatsKeyIgnoredBySuiteGranularity(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	parser := junit.NewParser(logger,
+		junit.WithGranularity(junit.GranularitySuite), junit.WithStatsKey(junit.StatsKeyClassname))
+	times, err := parser.LoadFiles(t.Context(), []string{classnameReports + "/pytest.xml"})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	assertTimes(t, times, map[string]float64{"pytest": 6.1})
+}
@@ -0,0 +1,92 @@
+package junit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrTimestamp is returned by ParseTimestamp for a value in none of the known layouts.
+var ErrTimestamp = errors.New("unrecognized timestamp")
+
+// timestampLayout is a layout tried by ParseTimestamp.
+type timestampLayout struct {
+	layout string
+	zoned  bool // The layout carries a time zone offset
+}
+
+// timestampLayouts returns the layouts tried by ParseTimestamp, in order. Fractional seconds are
+// optional in all of them.
+func timestampLayouts() []timestampLayout {
+	return []timestampLayout{
+		{"2006-01-02T15:04:05.999999999Z07:00", true},     // RFC 3339
+		{"2006-01-02 15:04:05.999999999Z07:00", true},     // RFC 3339 with a space
+		{"2006-01-02T15:04:05.999999999Z0700", true},      // Offset without a colon
+		{"2006-01-02 15:04:05.999999999Z0700", true},      // The same with a space
+		{"2006-01-02 15:04:05.999999999 -0700", true},     // Ruby and Python's str()
+		{"2006-01-02 15:04:05.999999999 -0700 MST", true}, // Go's time.Time.String
+		{"2006-01-02T15:04:05.999999999", false},          // Local time, written by most JUnit writers
+		{"2006-01-02 15:04:05.999999999", false},          // Local time with a space
+	}
+}
+
+// ParseTimestamp parses the timestamp attribute of a suite. It accepts RFC 3339 as well as the
+// variants found in the wild: a space instead of the T, an offset without a colon or after a
+// space, and no time zone at all. Values without a time zone are read as UTC rather than in the
+// local zone, so the result never depends on the machine, and are reported with zoned false.
+// Values in no known layout, including empty ones, fail with ErrTimestamp.
+func ParseTimestamp(s string) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	for _, l := range timestampLayouts() {
+		if t, err := time.Parse(l.layout, s); err == nil {
+			return t, l.zoned, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("%w %q", ErrTimestamp, s)
+}
+
+// addTimestamp dates the result by a suite's timestamp attribute when it is newer than the
+// current date. Values without a time zone and unrecognized values are counted for warnTimestamps.
+func (r *fileResult) addTimestamp(s string) {
+	if s == "" {
+		return
+	}
+	date, zoned, err := ParseTimestamp(s)
+	if err != nil {
+		r.stamps.invalid++
+		return
+	}
+	if !zoned {
+		r.stamps.zoneless++
+	}
+	if date.After(r.date) {
+		r.date = date
+	}
+}
+
+// timestampCounts counts the timestamp attributes of a file that could not be used as they are.
+type timestampCounts struct {
+	zoneless int // Read as UTC
+	invalid  int // Ignored
+}
+
+// warnTimestamps warns about the timestamps of file that MergeLatest could not use as they are.
+// A report with no usable timestamp is dated by its modification time instead.
+func (p *Parser) warnTimestamps(file string, stamps timestampCounts) {
+	if p.merge != MergeLatest {
+		return
+	}
+	if stamps.zoneless > 0 {
+		p.logger.Warn().
+			Int("count", stamps.zoneless).
+			Str("file", file).
+			Msg("Timestamps have no time zone, reading them as UTC")
+	}
+	if stamps.invalid > 0 {
+		p.logger.Warn().
+			Int("count", stamps.invalid).
+			Str("file", file).
+			Msg("Ignoring unrecognized timestamps, dating the report by its other suites or modification time")
+	}
+}
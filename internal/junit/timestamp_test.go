@@ -0,0 +1,133 @@
+package junit_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	utc := time.Date(2026, 3, 4, 10, 20, 30, 0, time.UTC)
+	frac := time.Date(2026, 3, 4, 10, 20, 30, 123_000_000, time.UTC)
+	// 12:20:30+02:00 and 05:20:30-05:00 are both 10:20:30 UTC
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+		zoned bool
+	}{
+		{"RFC 3339 UTC", "2026-03-04T10:20:30Z", utc, true},
+		{"RFC 3339 offset", "2026-03-04T12:20:30+02:00", utc, true},
+		{"RFC 3339 negative offset", "2026-03-04T05:20:30-05:00", utc, true},
+		{"RFC 3339 fraction", "2026-03-04T10:20:30.123Z", frac, true},
+		{"space and offset", "2026-03-04 12:20:30+02:00", utc, true},
+		{"offset without colon", "2026-03-04T12:20:30+0200", utc, true},
+		{"space and offset without colon", "2026-03-04 12:20:30.123+0200", frac, true},
+		{"offset after a space", "2026-03-04 12:20:30 +0200", utc, true},
+		{"Go time string", "2026-03-04 12:20:30 +0200 CEST", utc, true},
+		{"no zone", "2026-03-04T10:20:30", utc, false},
+		{"no zone fraction", "2026-03-04T10:20:30.123", frac, false},
+		{"no zone comma fraction", "2026-03-04T10:20:30,123", frac, false},
+		{"no zone space", "2026-03-04 10:20:30", utc, false},
+		{"no zone space fraction", "2026-03-04 10:20:30.123", frac, false},
+		{"surrounding spaces", " 2026-03-04T10:20:30Z\n", utc, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, zoned, err := junit.ParseTimestamp(tt.input)
+			if err != nil {
+				t.Fatalf("ParseTimestamp(%q) failed: %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) || zoned != tt.zoned {
+				t.Errorf("ParseTimestamp(%q) = %v, %v, want %v, %v", tt.input, got, zoned, tt.want, tt.zoned)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"yesterday",
+		"2026-03-04",
+		"10:20:30",
+		"04/03/2026 10:20:30",
+		"2026-13-04T10:20:30Z",
+		"1772619630",
+	} {
+		t.Run(input, func(t *testing.T) {
+			if got, _, err := junit.ParseTimestamp(input); !errors.Is(err, junit.ErrTimestamp) {
+				t.Errorf("ParseTimestamp(%q) = %v, %v, want ErrTimestamp", input, got, err)
+			}
+		})
+	}
+}
+
+func TestParser_LatestTimestamps(t *testing.T) {
+	dir := t.TempDir()
+	reports := map[string]string{
+		// Zone-less, read as UTC: older than b.xml, dated 11:00 UTC
+		"a.xml": `<testsuite file="a_test.go" time="1" timestamp="2026-01-02 10:00:00"/>`,
+		"b.xml": `<testsuite file="a_test.go" time="2" timestamp="2026-01-02 12:00:00 +0100"/>`,
+		// Unrecognized, dated by its modification time, newer than both
+		"c.xml": `<testsuite file="a_test.go" time="4" timestamp="Jan 3rd"/>`,
+	}
+	for name, content := range reports {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	newer := time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "c.xml"), newer, newer); err != nil {
+		t.Fatalf("Failed to date test file: %v", err)
+	}
+
+	t.Run("unrecognized timestamp", func(t *testing.T) {
+		var logs bytes.Buffer
+		parser := junit.NewParser(zerolog.New(&logs), junit.WithMerge(junit.MergeLatest))
+		times, err := parser.LoadFiles(t.Context(), []string{dir})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if !floatEqual(times["a_test.go"], 4) {
+			t.Errorf("Got %v, want the time of c.xml, dated by its modification time", times)
+		}
+		for _, want := range []string{"reading them as UTC", "Ignoring unrecognized timestamps"} {
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("Logs missing %q:\n%s", want, logs.String())
+			}
+		}
+	})
+
+	t.Run("zone-less timestamp", func(t *testing.T) {
+		if err := os.Remove(filepath.Join(dir, "c.xml")); err != nil {
+			t.Fatalf("Failed to remove test file: %v", err)
+		}
+		parser := junit.NewParser(zerolog.Nop(), junit.WithMerge(junit.MergeLatest))
+		times, err := parser.LoadFiles(t.Context(), []string{dir})
+		if err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if !floatEqual(times["a_test.go"], 2) {
+			t.Errorf("Got %v, want the time of b.xml", times)
+		}
+	})
+
+	t.Run("other strategies", func(t *testing.T) {
+		var logs bytes.Buffer
+		if _, err := junit.NewParser(zerolog.New(&logs)).LoadFiles(t.Context(), []string{dir}); err != nil {
+			t.Fatalf("LoadFiles failed: %v", err)
+		}
+		if strings.Contains(logs.String(), "UTC") || strings.Contains(logs.String(), "unrecognized") {
+			t.Errorf("Got timestamp warnings without MergeLatest:\n%s", logs.String())
+		}
+	})
+}
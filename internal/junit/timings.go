@@ -0,0 +1,80 @@
+package junit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// errNotNumber is returned by jsonTime for a time that is neither a number nor a string holding one.
+var errNotNumber = errors.New("time is not a number")
+
+// isTimingsFile reports whether path names a JSON timings map rather than a JUnit XML report.
+func isTimingsFile(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".json") || strings.HasSuffix(lower, ".json.gz")
+}
+
+// parseTimings decodes a JSON timings map of the form {"pkg/a_test.go": 5.2, ...} from r, keyed
+// like the file attributes of a report. Times may also be strings holding a number, such as
+// "5.2". Entries whose time is not a number or cannot be used are skipped with a warning, while a
+// document that is not a JSON object fails the whole file.
+func (p *Parser) parseTimings(ctx context.Context, r io.Reader) fileResult {
+	var entries map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return fileResult{err: fmt.Errorf("invalid JSON timings: %w", err)}
+	}
+	if err := ctx.Err(); err != nil {
+		return fileResult{err: err}
+	}
+
+	result := fileResult{times: make(map[string]float64, len(entries))}
+	for name, raw := range entries {
+		if name == "" {
+			result.counts.missingFile++
+			continue
+		}
+		val, err := jsonTime(raw)
+		if err != nil {
+			p.logger.Warn().
+				Str("test", name).
+				Str("time", string(raw)).
+				Msg("Skipping timing entry with unparseable time")
+			result.counts.unparseable++
+			continue
+		}
+		if err = p.validateTime(val); err != nil {
+			p.logger.Warn().
+				Err(err).
+				Str("test", name).
+				Float64("time", val).
+				Msg("Skipping timing entry with invalid time")
+			result.counts.rejected++
+			continue
+		}
+		result.times[p.finishKey(name)] += val
+		result.counts.loaded++
+	}
+	return result
+}
+
+// jsonTime parses the time of a timings entry, a JSON number or a string holding one.
+func jsonTime(raw json.RawMessage) (float64, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		val, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return 0, errNotNumber
+		}
+		return val, nil
+	}
+	var val *float64
+	if err := json.Unmarshal(raw, &val); err != nil || val == nil {
+		return 0, errNotNumber
+	}
+	return *val, nil
+}
@@ -8,7 +8,26 @@ type TestSuite struct {
 	Name       string      `xml:"name,attr"`
 	File       string      `xml:"file,attr"`
 	Time       string      `xml:"time,attr"`
+	Timestamp  string      `xml:"timestamp,attr"`
 	TestSuites []TestSuite `xml:"testsuite"`
+	TestCases  []TestCase  `xml:"testcase"`
+}
+
+// TestCase represents a JUnit XML test case element.
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	File      string   `xml:"file,attr"`
+	Time      string   `xml:"time,attr"`
+	Skipped   *Outcome `xml:"skipped"`
+	Failure   *Outcome `xml:"failure"`
+	Error     *Outcome `xml:"error"`
+}
+
+// Outcome represents the skipped, failure, or error element of a test case.
+type Outcome struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
 }
 
 // TestSuites represents the root element of JUnit XML.
@@ -17,8 +36,29 @@ type TestSuites struct {
 	TestSuites []TestSuite `xml:"testsuite"`
 }
 
+// Source describes where a test's time came from.
+type Source string
+
+const (
+	SourceStats   Source = "stats"   // Historical data from stats files
+	SourceDefault Source = "default" // No historical data, default time used
+	SourceInline  Source = "inline"  // Override given in the test list
+	SourceClamped Source = "clamped" // Recorded as zero seconds, raised to the zero time
+)
+
+// Group holds the tests of a group that must run on the same worker. It is referenced by pointer
+// so that Test stays comparable.
+type Group struct {
+	Members []Test // In input order
+}
+
 // Test represents a single test with its execution time.
 type Test struct {
-	Name string
-	Time float64
+	Name     string // Spelling from the input list, used for output
+	Key      string // Normalized form of Name, used for matching against stats
+	Source   Source
+	Resource string // Tag of a resource limited per worker, empty for none
+	Group    *Group // Members when the test is a group read from a grouped test list, nil otherwise
+	Time     float64
+	Cases    int // Test cases the reports hold for the test, 0 when unknown
 }
@@ -0,0 +1,126 @@
+package junit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// isReportFile reports whether a file name looks like a stats report, plain or gzip-compressed.
+func isReportFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".xml") || strings.HasSuffix(lower, ".xml.gz")
+}
+
+// dirWalker walks a directory tree with bounded parallelism, following symlinks
+// but visiting every real directory at most once, which also breaks symlink loops.
+type dirWalker struct {
+	match   func(name string) bool
+	sem     chan struct{}
+	visited map[string]struct{}
+	files   []string
+	errs    []error
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+}
+
+// walkDir returns the sorted paths of all files under root accepted by match,
+// using at most concurrency goroutines in addition to the caller.
+func walkDir(root string, concurrency int, match func(name string) bool) ([]string, error) {
+	w := &dirWalker{
+		match:   match,
+		sem:     make(chan struct{}, max(concurrency, 1)),
+		visited: make(map[string]struct{}),
+	}
+
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", root, err)
+	}
+
+	w.walk(root, real)
+	w.wg.Wait()
+
+	sort.Strings(w.files)
+	return w.files, errors.Join(w.errs...)
+}
+
+// walk scans a single directory, descending into subdirectories.
+// real is the directory's path with all symlinks resolved.
+func (w *dirWalker) walk(dir, real string) {
+	w.mu.Lock()
+	_, seen := w.visited[real]
+	w.visited[real] = struct{}{}
+	w.mu.Unlock()
+	if seen {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.fail(fmt.Errorf("cannot read directory %s: %w", dir, err))
+		return
+	}
+
+	var files []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		realPath := filepath.Join(real, entry.Name())
+
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			info, statErr := os.Stat(path)
+			if statErr != nil {
+				// Broken symlink
+				continue
+			}
+			isDir = info.IsDir()
+			if !isDir && !info.Mode().IsRegular() {
+				continue
+			}
+			if isDir {
+				if realPath, statErr = filepath.EvalSymlinks(path); statErr != nil {
+					w.fail(fmt.Errorf("cannot resolve %s: %w", path, statErr))
+					continue
+				}
+			}
+		}
+
+		if isDir {
+			w.spawn(path, realPath)
+			continue
+		}
+		if w.match(entry.Name()) {
+			files = append(files, path)
+		}
+	}
+
+	w.mu.Lock()
+	w.files = append(w.files, files...)
+	w.mu.Unlock()
+}
+
+// spawn walks dir in a new goroutine when below the concurrency limit, or inline otherwise.
+func (w *dirWalker) spawn(dir, real string) {
+	select {
+	case w.sem <- struct{}{}:
+		w.wg.Go(func() {
+			defer func() { <-w.sem }()
+			w.walk(dir, real)
+		})
+	default:
+		w.walk(dir, real)
+	}
+}
+
+// fail records a non-fatal error.
+func (w *dirWalker) fail(err error) {
+	w.mu.Lock()
+	w.errs = append(w.errs, err)
+	w.mu.Unlock()
+}
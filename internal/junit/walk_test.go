@@ -0,0 +1,140 @@
+package junit_test
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// buildTree creates a directory tree of the given depth and fanout with report files at every level.
+func buildTree(tb testing.TB, root string, depth, fanout int) {
+	tb.Helper()
+
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		tb.Fatalf("Failed to create directory: %v", err)
+	}
+	for _, name := range []string{"junit.xml", "other.XML", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("<testsuites/>"), 0o600); err != nil {
+			tb.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if depth == 0 {
+		return
+	}
+	for i := range fanout {
+		buildTree(tb, filepath.Join(root, fmt.Sprintf("dir%d", i)), depth-1, fanout)
+	}
+}
+
+// walkDirReference lists report files using filepath.WalkDir.
+func walkDirReference(tb testing.TB, root string) []string {
+	tb.Helper()
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && junit.IsReportFile(d.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		tb.Fatalf("WalkDir failed: %v", err)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestWalkDir_MatchesWalkDir(t *testing.T) {
+	root := t.TempDir()
+	buildTree(t, root, 3, 4)
+
+	want := walkDirReference(t, root)
+	for _, concurrency := range []int{1, 4, 64} {
+		t.Run(fmt.Sprintf("concurrency=%d", concurrency), func(t *testing.T) {
+			got, err := junit.WalkDir(root, concurrency, junit.IsReportFile)
+			if err != nil {
+				t.Fatalf("walkDir failed: %v", err)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("Got %d files, want %d\ngot:  %v\nwant: %v", len(got), len(want), got, want)
+			}
+		})
+	}
+}
+
+func TestWalkDir_SymlinkLoop(t *testing.T) {
+	root := t.TempDir()
+	buildTree(t, root, 1, 2)
+
+	// dir0/loop points back at the root, and dir1/link at a sibling
+	if err := os.Symlink(root, filepath.Join(root, "dir0", "loop")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "dir0"), filepath.Join(root, "dir1", "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	got, err := junit.WalkDir(root, 4, junit.IsReportFile)
+	if err != nil {
+		t.Fatalf("walkDir failed: %v", err)
+	}
+
+	// Every real directory is visited once: root, dir0, dir1, each with two reports
+	if len(got) != 6 {
+		t.Errorf("Got %d files, want 6: %v", len(got), got)
+	}
+}
+
+func TestParser_LoadFilesDirectory(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+	root := t.TempDir()
+	data, err := os.ReadFile("../../testdata/junit/example2.xml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	nested := filepath.Join(root, "shard-1", "reports")
+	if err = os.MkdirAll(nested, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(nested, "junit.xml"), data, 0o600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	times, err := junit.NewParser(logger).LoadFiles(t.Context(), []string{root})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	if !floatEqual(times["pkg/db/connection_test.go"], 12.567) {
+		t.Errorf("connection_test.go: got %.3f, want 12.567", times["pkg/db/connection_test.go"])
+	}
+}
+
+func BenchmarkWalkDir(b *testing.B) {
+	root := b.TempDir()
+	buildTree(b, root, 4, 5)
+
+	b.Run("filepath.WalkDir", func(b *testing.B) {
+		for b.Loop() {
+			walkDirReference(b, root)
+		}
+	})
+
+	b.Run("parallel", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := junit.WalkDir(root, 16, junit.IsReportFile); err != nil {
+				b.Fatalf("walkDir failed: %v", err)
+			}
+		}
+	})
+}
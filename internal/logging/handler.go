@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// Handler is a slog.Handler writing to a zerolog logger.
+// Groups are flattened into dotted field names.
+type Handler struct {
+	logger zerolog.Logger
+	group  string      // prefix for attribute keys, ending with "." when set
+	attrs  []slog.Attr // attributes added with WithAttrs, keys already prefixed
+}
+
+// NewHandler creates a handler writing to logger, honoring its level.
+func NewHandler(logger zerolog.Logger) *Handler {
+	return &Handler{logger: logger}
+}
+
+// Enabled reports whether logger writes events at level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	zl := fromSlog(level)
+	return zl >= h.logger.GetLevel() && zl >= zerolog.GlobalLevel()
+}
+
+// Handle writes r as a single zerolog event.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	ev := h.logger.WithLevel(fromSlog(r.Level))
+	if ev == nil {
+		return nil
+	}
+	for _, a := range h.attrs {
+		addAttr(ev, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(ev, h.group, a)
+		return true
+	})
+	ev.Msg(r.Message)
+	return nil
+}
+
+// WithAttrs returns a handler adding attrs to every event.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, h.attrs...)
+	for _, a := range attrs {
+		clone.attrs = append(clone.attrs, slog.Attr{Key: h.group + a.Key, Value: a.Value})
+	}
+	return &clone
+}
+
+// WithGroup returns a handler prefixing the keys of subsequent attributes with name.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group = h.group + name + "."
+	return &clone
+}
+
+// addAttr adds a to ev under prefix+a.Key.
+func addAttr(ev *zerolog.Event, prefix string, a slog.Attr) {
+	value := a.Value.Resolve()
+	if a.Key == "" && value.Kind() != slog.KindGroup {
+		return
+	}
+
+	key := prefix + a.Key
+	switch value.Kind() {
+	case slog.KindString:
+		ev.Str(key, value.String())
+	case slog.KindInt64:
+		ev.Int64(key, value.Int64())
+	case slog.KindUint64:
+		ev.Uint64(key, value.Uint64())
+	case slog.KindFloat64:
+		ev.Float64(key, value.Float64())
+	case slog.KindBool:
+		ev.Bool(key, value.Bool())
+	case slog.KindDuration:
+		ev.Dur(key, value.Duration())
+	case slog.KindTime:
+		ev.Time(key, value.Time())
+	case slog.KindGroup:
+		// Inline groups (empty key) add their attributes at the current level
+		if a.Key != "" {
+			prefix = key + "."
+		}
+		for _, member := range value.Group() {
+			addAttr(ev, prefix, member)
+		}
+	default:
+		if err, ok := value.Any().(error); ok {
+			ev.AnErr(key, err)
+			return
+		}
+		ev.Interface(key, value.Any())
+	}
+}
@@ -0,0 +1,74 @@
+// Package logging bridges log/slog, used at the public API boundary, and zerolog,
+// used by the internal packages.
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// LevelTrace is the slog level zerolog's trace events are reported at.
+const LevelTrace = slog.LevelDebug - 4
+
+// Zerolog returns a zerolog logger delivering its events to l.
+//
+// A nil l yields a disabled logger. A logger created with NewHandler is unwrapped to the
+// zerolog logger it writes to, so the CLI pays no conversion cost. Any other handler receives
+// every event as a slog record; levels it does not enable are filtered before events are built.
+func Zerolog(l *slog.Logger) zerolog.Logger {
+	if l == nil {
+		return zerolog.Nop()
+	}
+	if h, ok := l.Handler().(*Handler); ok && len(h.attrs) == 0 && h.group == "" {
+		return h.logger
+	}
+
+	handler := l.Handler()
+	return zerolog.New(&slogWriter{handler: handler}).Level(minLevel(handler))
+}
+
+// minLevel returns the lowest zerolog level whose events handler accepts.
+func minLevel(handler slog.Handler) zerolog.Level {
+	for _, level := range []zerolog.Level{
+		zerolog.TraceLevel, zerolog.DebugLevel, zerolog.InfoLevel, zerolog.WarnLevel, zerolog.ErrorLevel,
+	} {
+		if handler.Enabled(context.Background(), toSlog(level)) {
+			return level
+		}
+	}
+	return zerolog.Disabled
+}
+
+// toSlog converts a zerolog level to the matching slog level.
+func toSlog(level zerolog.Level) slog.Level {
+	switch level {
+	case zerolog.TraceLevel:
+		return LevelTrace
+	case zerolog.DebugLevel:
+		return slog.LevelDebug
+	case zerolog.WarnLevel:
+		return slog.LevelWarn
+	case zerolog.ErrorLevel, zerolog.FatalLevel, zerolog.PanicLevel:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// fromSlog converts a slog level to the closest zerolog level.
+func fromSlog(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
@@ -0,0 +1,153 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/logging"
+)
+
+// recorder is a slog.Handler keeping every record at or above its level.
+type recorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+	level   slog.Level
+}
+
+func (r *recorder) Enabled(_ context.Context, level slog.Level) bool { return level >= r.level }
+
+func (r *recorder) Handle(_ context.Context, record slog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *recorder) WithAttrs([]slog.Attr) slog.Handler { return r }
+
+func (r *recorder) WithGroup(string) slog.Handler { return r }
+
+// attrs flattens the attributes of record into dotted keys.
+func attrs(record slog.Record) map[string]any {
+	out := make(map[string]any)
+	var add func(prefix string, a slog.Attr)
+	add = func(prefix string, a slog.Attr) {
+		if a.Value.Kind() == slog.KindGroup {
+			for _, member := range a.Value.Group() {
+				add(prefix+a.Key+".", member)
+			}
+			return
+		}
+		out[prefix+a.Key] = a.Value.Any()
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		add("", a)
+		return true
+	})
+	return out
+}
+
+func TestZerolog_ToSlog(t *testing.T) {
+	rec := &recorder{level: slog.LevelInfo}
+	logger := logging.Zerolog(slog.New(rec))
+
+	if logger.GetLevel() != zerolog.InfoLevel {
+		t.Errorf("Level: got %v, want info to skip building debug events", logger.GetLevel())
+	}
+
+	logger.Debug().Msg("hidden")
+	logger.Warn().
+		Int("count", 3).
+		Float64("time", 1.5).
+		Str("file", "a.xml").
+		Err(errors.New("boom")).
+		Dict("skipped", zerolog.Dict().Int("missing_file", 2)).
+		Msg("Loaded test times")
+
+	if len(rec.records) != 1 {
+		t.Fatalf("Got %d records, want 1", len(rec.records))
+	}
+	record := rec.records[0]
+	if record.Level != slog.LevelWarn || record.Message != "Loaded test times" {
+		t.Errorf("Record: got %v %q, want WARN %q", record.Level, record.Message, "Loaded test times")
+	}
+
+	want := map[string]any{
+		"count":                int64(3),
+		"time":                 1.5,
+		"file":                 "a.xml",
+		"error":                "boom",
+		"skipped.missing_file": int64(2),
+	}
+	got := attrs(record)
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: got %v (%T), want %v (%T)", key, got[key], got[key], value, value)
+		}
+	}
+}
+
+func TestZerolog_Nil(t *testing.T) {
+	logger := logging.Zerolog(nil)
+	if logger.GetLevel() != zerolog.Disabled {
+		t.Errorf("Level: got %v, want disabled", logger.GetLevel())
+	}
+}
+
+func TestZerolog_UnwrapsHandler(t *testing.T) {
+	var buf bytes.Buffer
+	original := zerolog.New(&buf).Level(zerolog.WarnLevel)
+
+	logger := logging.Zerolog(slog.New(logging.NewHandler(original)))
+	if logger.GetLevel() != zerolog.WarnLevel {
+		t.Errorf("Level: got %v, want the original warn level", logger.GetLevel())
+	}
+	logger.Warn().Int("count", 1).Msg("direct")
+	if got := buf.String(); got != `{"level":"warn","count":1,"message":"direct"}`+"\n" {
+		t.Errorf("Output: got %q", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewHandler(zerolog.New(&buf).Level(zerolog.InfoLevel)))
+
+	logger.Debug("hidden")
+	logger.With("run", "r1").WithGroup("stats").Info("Loaded",
+		"count", 2,
+		slog.Group("skipped", "missing_time", 1),
+		"error", errors.New("boom"),
+	)
+
+	var event map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("Expected a single JSON event, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"level":                      "info",
+		"message":                    "Loaded",
+		"run":                        "r1",
+		"stats.count":                float64(2),
+		"stats.skipped.missing_time": float64(1),
+		"stats.error":                "boom",
+	}
+	for key, value := range want {
+		if event[key] != value {
+			t.Errorf("%s: got %v, want %v", key, event[key], value)
+		}
+	}
+	if len(event) != len(want) {
+		t.Errorf("Got fields %v, want %v", event, want)
+	}
+
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Debug should be disabled by the zerolog level")
+	}
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// slogWriter decodes zerolog's JSON events and passes them to a slog handler.
+type slogWriter struct {
+	handler slog.Handler
+}
+
+// Write handles an event whose level is only known from its JSON.
+func (w *slogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel converts one zerolog event into a slog record.
+func (w *slogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	record, err := decodeEvent(level, p)
+	if err != nil {
+		return 0, err
+	}
+	if !w.handler.Enabled(context.Background(), record.Level) {
+		return len(p), nil
+	}
+	if err = w.handler.Handle(context.Background(), record); err != nil {
+		return 0, fmt.Errorf("cannot handle log record: %w", err)
+	}
+	return len(p), nil
+}
+
+// decodeEvent parses a zerolog JSON event, keeping the order of its top-level fields.
+func decodeEvent(level zerolog.Level, p []byte) (slog.Record, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return slog.Record{}, errors.New("cannot decode log event: not a JSON object")
+	}
+
+	var message string
+	var attrs []slog.Attr
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return slog.Record{}, fmt.Errorf("cannot decode log event: %w", err)
+		}
+		key, _ := tok.(string)
+
+		var value any
+		if err = dec.Decode(&value); err != nil {
+			return slog.Record{}, fmt.Errorf("cannot decode log event: %w", err)
+		}
+
+		switch key {
+		case zerolog.MessageFieldName:
+			message, _ = value.(string)
+		case zerolog.LevelFieldName:
+			if level == zerolog.NoLevel {
+				if name, ok := value.(string); ok {
+					level, _ = zerolog.ParseLevel(name)
+				}
+			}
+		default:
+			attrs = append(attrs, toAttr(key, value))
+		}
+	}
+
+	record := slog.NewRecord(time.Now(), toSlog(level), message, 0)
+	record.AddAttrs(attrs...)
+	return record, nil
+}
+
+// toAttr converts a decoded JSON value into an attribute. Objects become groups with sorted keys.
+func toAttr(key string, value any) slog.Attr {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return slog.Int64(key, i)
+		}
+		f, _ := v.Float64()
+		return slog.Float64(key, f)
+	case string:
+		return slog.String(key, v)
+	case bool:
+		return slog.Bool(key, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		members := make([]any, 0, len(keys))
+		for _, k := range keys {
+			members = append(members, toAttr(k, v[k]))
+		}
+		return slog.Group(key, members...)
+	default:
+		return slog.Any(key, v)
+	}
+}
@@ -0,0 +1,71 @@
+// Package manifest reads and writes split plans: the tests assigned to every worker with their predicted times.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// Version is the schema version of the manifests written by Write. Manifests without a version
+// predate it and are read as version 1.
+const Version = 1
+
+var (
+	// ErrEmpty is returned when a manifest has no groups.
+	ErrEmpty = errors.New("manifest has no groups")
+	// ErrUnsupportedVersion is returned when a manifest has a schema version Read does not know.
+	ErrUnsupportedVersion = errors.New("unsupported manifest version")
+)
+
+// Manifest is a split plan. It is written by split --manifest and returned by POST /split of serve.
+type Manifest struct {
+	PlanID       string              `json:"plan_id,omitempty"`
+	ToolVersion  string              `json:"tool_version,omitempty"` // Version of tests-helper that wrote it
+	Groups       []worker.Worker     `json:"groups"`
+	Distribution worker.Distribution `json:"distribution"`
+	Version      int                 `json:"version"` // Schema version, see Version
+}
+
+// Read decodes a manifest. It fails with ErrUnsupportedVersion for a version other than Version,
+// naming the tool version that wrote it when known, and with ErrEmpty when the manifest has no groups.
+func Read(r io.Reader) (Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return Manifest{}, fmt.Errorf("cannot decode manifest: %w", err)
+	}
+	if m.Version == 0 {
+		m.Version = Version
+	}
+	if m.Version != Version {
+		writer := ""
+		if m.ToolVersion != "" {
+			writer = " written by tests-helper " + m.ToolVersion
+		}
+		return Manifest{}, fmt.Errorf("%w %d%s (supported: %d)", ErrUnsupportedVersion, m.Version, writer, Version)
+	}
+	if len(m.Groups) == 0 {
+		return Manifest{}, ErrEmpty
+	}
+	return m, nil
+}
+
+// Write encodes m as indented JSON, stamped with Version. The caller sets ToolVersion.
+func Write(w io.Writer, m Manifest) error {
+	m.Version = Version
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("cannot encode manifest: %w", err)
+	}
+	return nil
+}
+
+// ToolVersionMismatch reports whether m was written by a version of tests-helper other than
+// version. Manifests predating the tool version, and builds without a version, are never reported.
+func ToolVersionMismatch(m Manifest, version string) bool {
+	return m.ToolVersion != "" && version != "" && m.ToolVersion != version
+}
@@ -0,0 +1,203 @@
+package manifest_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+func TestManifest_RoundTrip(t *testing.T) {
+	m := manifest.Manifest{
+		Groups: []worker.Worker{
+			{Total: 3, Tests: []junit.Test{{Name: "a_test.go", Time: 3, Source: junit.SourceStats}}},
+			{Total: 0},
+		},
+		Distribution: worker.Distribution{TotalTime: 3, AvgTime: 1.5},
+		ToolVersion:  "1.2.0",
+	}
+
+	var buf bytes.Buffer
+	if err := manifest.Write(&buf, m); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"version": 1`) {
+		t.Errorf("Manifest is not stamped with its version:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "plan_id") {
+		t.Errorf("Manifest without a plan id encodes one:\n%s", buf.String())
+	}
+	got, err := manifest.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(got.Groups) != 2 || got.Groups[0].Tests[0].Name != "a_test.go" || got.Groups[0].Tests[0].Time != 3 ||
+		got.Distribution.TotalTime != 3 || got.ToolVersion != "1.2.0" {
+		t.Errorf("Read %+v", got)
+	}
+}
+
+func TestWrite_InvalidUTF8(t *testing.T) {
+	name := "pkg/\x1b\xff_test.go"
+	m := manifest.Manifest{
+		Groups: []worker.Worker{{Total: 3, Tests: []junit.Test{{Name: name, Time: 3}}}},
+		Distribution: worker.Distribution{
+			Oversized: []worker.OversizedTest{{Name: name, Time: 3, Excess: 1}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := manifest.Write(&buf, m); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !utf8.Valid(buf.Bytes()) {
+		t.Errorf("Manifest is not valid UTF-8:\n%q", buf.String())
+	}
+	got, err := manifest.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := "pkg/\x1b\uFFFD_test.go"
+	if got.Groups[0].Tests[0].Name != want || got.Distribution.Oversized[0].Name != want {
+		t.Errorf("Read names %q and %q, want %q", got.Groups[0].Tests[0].Name, got.Distribution.Oversized[0].Name, want)
+	}
+}
+
+func TestWrite_Deterministic(t *testing.T) {
+	write := func(limits map[string]int) []byte {
+		m := manifest.Manifest{
+			Groups: []worker.Worker{
+				{Total: 3, Tests: []junit.Test{{Name: "a_test.go", Time: 3, Resource: "db"}}},
+			},
+			Distribution: worker.Distribution{TotalTime: 3, AvgTime: 3, ResourceLimits: limits},
+		}
+		var buf bytes.Buffer
+		if err := manifest.Write(&buf, m); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	tags := []string{"db", "gpu", "redis", "kafka", "s3", "ldap", "smtp", "dns"}
+	forward, backward := map[string]int{}, map[string]int{}
+	for i, tag := range tags {
+		forward[tag] = i + 1
+		backward[tags[len(tags)-1-i]] = len(tags) - i
+	}
+	want := write(forward)
+	for range 10 {
+		if got := write(backward); !bytes.Equal(got, want) {
+			t.Fatalf("Write =\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	if _, err := manifest.Read(strings.NewReader(`{"groups": []}`)); !errors.Is(err, manifest.ErrEmpty) {
+		t.Errorf("Error = %v, want ErrEmpty", err)
+	}
+	if _, err := manifest.Read(strings.NewReader(`{"groups": `)); err == nil {
+		t.Error("Expected an error for truncated JSON")
+	}
+}
+
+func TestRead_Version(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "current", data: `{"version": 1, "groups": [{"total": 0}]}`},
+		{name: "missing predates versioning", data: `{"groups": [{"total": 0}]}`},
+		{name: "newer", data: `{"version": 2, "groups": [{"total": 0}]}`, wantErr: true},
+		{name: "negative", data: `{"version": -1, "groups": [{"total": 0}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := manifest.Read(strings.NewReader(tt.data))
+			if tt.wantErr {
+				if !errors.Is(err, manifest.ErrUnsupportedVersion) {
+					t.Errorf("Error = %v, want ErrUnsupportedVersion", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if m.Version != manifest.Version {
+				t.Errorf("Version = %d, want %d", m.Version, manifest.Version)
+			}
+		})
+	}
+
+	// The error names the version found
+	_, err := manifest.Read(strings.NewReader(`{"version": 7, "groups": [{"total": 0}]}`))
+	if err == nil || !strings.Contains(err.Error(), "version 7") {
+		t.Errorf("Error = %v, want it to name version 7", err)
+	}
+}
+
+func TestRead_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		toolVersion string
+		wantErr     string
+	}{
+		{fixture: "unversioned.json"},
+		{fixture: "older-tool.json", toolVersion: "0.9.0"},
+		{
+			fixture: "future-schema.json",
+			wantErr: "unsupported manifest version 2 written by tests-helper 9.0.0 (supported: 1)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("../../testdata/manifest", tt.fixture))
+			if err != nil {
+				t.Fatalf("Failed to open fixture: %v", err)
+			}
+			defer func() { _ = f.Close() }()
+
+			m, err := manifest.Read(f)
+			if tt.wantErr != "" {
+				if !errors.Is(err, manifest.ErrUnsupportedVersion) || err.Error() != tt.wantErr {
+					t.Errorf("Error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if m.Version != manifest.Version || m.ToolVersion != tt.toolVersion || len(m.Groups) != 2 {
+				t.Errorf("Read version %d by %q with %d groups, want version %d by %q with 2 groups",
+					m.Version, m.ToolVersion, len(m.Groups), manifest.Version, tt.toolVersion)
+			}
+		})
+	}
+}
+
+func TestToolVersionMismatch(t *testing.T) {
+	tests := []struct {
+		written string
+		running string
+		want    bool
+	}{
+		{written: "1.2.0", running: "1.2.0", want: false},
+		{written: "1.1.0", running: "1.2.0", want: true},
+		{written: "", running: "1.2.0", want: false},
+		{written: "1.1.0", running: "", want: false},
+	}
+	for _, tt := range tests {
+		got := manifest.ToolVersionMismatch(manifest.Manifest{ToolVersion: tt.written}, tt.running)
+		if got != tt.want {
+			t.Errorf("ToolVersionMismatch(%q, %q) = %t, want %t", tt.written, tt.running, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package metrics emits split distribution metrics to StatsD or a Prometheus Pushgateway.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// DefaultTimeout bounds the time spent emitting metrics, so an unreachable sink never delays a split.
+const DefaultTimeout = 2 * time.Second
+
+// Gauge names, prefixed by each sink ("tests_helper." for StatsD, "tests_helper_" for Prometheus).
+const (
+	PredictedTotalSeconds  = "predicted_total_seconds"  // Sum of all tests' predicted times
+	WorkerPredictedSeconds = "worker_predicted_seconds" // Predicted time of one worker, labeled with "worker"
+	ImbalancePercent       = "imbalance_percent"        // How much the slowest worker exceeds the average
+	UntimedTests           = "untimed_tests"            // Tests without historical timing data
+	Efficiency             = "efficiency"               // Average worker time divided by the slowest worker's
+	Stability              = "stability"                // Stability score of a combined run, from 0 to 100
+	FileErrorPercent       = "file_error_percent"       // Mean absolute percentage error of the files' predictions
+)
+
+var (
+	// ErrUnsupportedURL is returned for metrics URLs other than statsd:// and pushgateway://.
+	ErrUnsupportedURL = errors.New("unsupported metrics URL")
+	// ErrInvalidLabel is returned for malformed or reserved --metrics-labels entries.
+	ErrInvalidLabel = errors.New("invalid metrics label")
+
+	labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Gauge is a single measurement.
+type Gauge struct {
+	Labels map[string]string
+	Name   string
+	Value  float64
+}
+
+// Sink receives the gauges of a run.
+type Sink interface {
+	// Emit sends gauges, giving up when ctx is done.
+	Emit(ctx context.Context, gauges []Gauge) error
+	// Describe identifies the sink in logs.
+	Describe() string
+}
+
+// Run describes a completed split.
+type Run struct {
+	Labels       map[string]string // Extra labels attached to every gauge
+	Distribution worker.Distribution
+	Index        int // Worker index of this run
+	Total        int // Number of workers
+	Untimed      int // Tests that had no historical time
+}
+
+// Gauges returns the gauges describing run, each labeled with index, total, and the run's extra labels.
+func Gauges(run Run) []Gauge {
+	labels := func(extra ...string) map[string]string {
+		l := make(map[string]string, len(run.Labels)+2+len(extra)/2)
+		maps.Copy(l, run.Labels)
+		l["index"] = strconv.Itoa(run.Index)
+		l["total"] = strconv.Itoa(run.Total)
+		for i := 0; i+1 < len(extra); i += 2 {
+			l[extra[i]] = extra[i+1]
+		}
+		return l
+	}
+
+	dist := run.Distribution
+	gauges := []Gauge{
+		{Name: PredictedTotalSeconds, Value: dist.TotalTime, Labels: labels()},
+		{Name: ImbalancePercent, Value: dist.ImbalancePercent(), Labels: labels()},
+		{Name: UntimedTests, Value: float64(run.Untimed), Labels: labels()},
+		{Name: Efficiency, Value: dist.Efficiency(), Labels: labels()},
+	}
+	for _, ws := range dist.Workers {
+		gauges = append(gauges, Gauge{
+			Name:   WorkerPredictedSeconds,
+			Value:  ws.Total,
+			Labels: labels("worker", strconv.Itoa(ws.Index)),
+		})
+	}
+	return gauges
+}
+
+// StabilityGauges returns the gauges describing the stability of a combined run, see
+// combine.Report, each labeled with labels.
+func StabilityGauges(labels map[string]string, stability, fileErrorPercent float64) []Gauge {
+	return []Gauge{
+		{Name: Stability, Value: stability, Labels: maps.Clone(labels)},
+		{Name: FileErrorPercent, Value: fileErrorPercent, Labels: maps.Clone(labels)},
+	}
+}
+
+// ParseLabels parses key=value pairs. Keys must be valid Prometheus label names
+// other than the built-in index, total, and worker labels.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		switch {
+		case !found || !labelName.MatchString(key):
+			return nil, fmt.Errorf("%w: %q (expected key=value)", ErrInvalidLabel, pair)
+		case key == "index" || key == "total" || key == "worker":
+			return nil, fmt.Errorf("%w: %q is set by tests-helper", ErrInvalidLabel, key)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// Open returns the sink for a statsd://host:port or pushgateway://host:port/job/<name> URL.
+func Open(raw string) (Sink, error) {
+	scheme, rest, _ := strings.Cut(raw, "://")
+	switch scheme {
+	case "statsd":
+		return newStatsD(raw, rest)
+	case "pushgateway":
+		return newPushgateway(raw, rest)
+	default:
+		return nil, fmt.Errorf("%w: %q (expected statsd://host:port or pushgateway://host:port/job/<name>)",
+			ErrUnsupportedURL, raw)
+	}
+}
+
+// Emit sends gauges to every sink within timeout. Failures are logged and otherwise ignored.
+func Emit(ctx context.Context, logger zerolog.Logger, sinks []Sink, gauges []Gauge, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, sink := range sinks {
+		if err := sink.Emit(ctx, gauges); err != nil {
+			logger.Warn().Err(err).Str("sink", sink.Describe()).Msg("Failed to emit metrics")
+			continue
+		}
+		logger.Debug().Int("gauges", len(gauges)).Str("sink", sink.Describe()).Msg("Emitted metrics")
+	}
+}
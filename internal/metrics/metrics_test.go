@@ -0,0 +1,221 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/metrics"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// fakeSink records emitted gauges, or blocks until the context is done when hang is set.
+type fakeSink struct {
+	err    error
+	gauges []metrics.Gauge
+	hang   bool
+}
+
+func (s *fakeSink) Emit(ctx context.Context, gauges []metrics.Gauge) error {
+	if s.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	s.gauges = gauges
+	return s.err
+}
+
+func (s *fakeSink) Describe() string {
+	return "fake"
+}
+
+func testRun() metrics.Run {
+	return metrics.Run{
+		Labels: map[string]string{"branch": "main"},
+		Distribution: worker.Distribution{
+			TotalTime: 40,
+			AvgTime:   20,
+			Workers:   []worker.Stats{{Index: 0, Total: 25}, {Index: 1, Total: 15}},
+		},
+		Index:   1,
+		Total:   2,
+		Untimed: 3,
+	}
+}
+
+func TestGauges(t *testing.T) {
+	gauges := metrics.Gauges(testRun())
+
+	want := map[string]float64{
+		metrics.PredictedTotalSeconds: 40,
+		metrics.ImbalancePercent:      25,
+		metrics.UntimedTests:          3,
+		metrics.Efficiency:            0.8,
+	}
+	var workers []float64
+	for _, g := range gauges {
+		if g.Labels["index"] != "1" || g.Labels["total"] != "2" || g.Labels["branch"] != "main" {
+			t.Errorf("%s labels = %v, want index, total, and branch", g.Name, g.Labels)
+		}
+		if g.Name == metrics.WorkerPredictedSeconds {
+			workers = append(workers, g.Value)
+			continue
+		}
+		if w, ok := want[g.Name]; !ok || g.Value != w {
+			t.Errorf("%s = %v, want %v", g.Name, g.Value, w)
+		}
+	}
+	if !slices.Equal(workers, []float64{25, 15}) {
+		t.Errorf("Worker gauges = %v, want [25 15]", workers)
+	}
+}
+
+func TestStabilityGauges(t *testing.T) {
+	labels := map[string]string{"branch": "main"}
+	gauges := metrics.StabilityGauges(labels, 85, 15)
+
+	want := map[string]float64{metrics.Stability: 85, metrics.FileErrorPercent: 15}
+	if len(gauges) != len(want) {
+		t.Fatalf("Gauges = %+v, want %v", gauges, want)
+	}
+	for _, g := range gauges {
+		if w, ok := want[g.Name]; !ok || g.Value != w || g.Labels["branch"] != "main" {
+			t.Errorf("%s = %v with %v, want %v labeled with the branch", g.Name, g.Value, g.Labels, w)
+		}
+	}
+	gauges[0].Labels["branch"] = "changed"
+	if labels["branch"] != "main" {
+		t.Error("Gauge labels share the caller's map")
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := metrics.ParseLabels([]string{"branch=main", "pipeline=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("ParseLabels failed: %v", err)
+	}
+	if labels["branch"] != "main" || labels["pipeline"] != "a=b" || labels["empty"] != "" {
+		t.Errorf("Labels = %v", labels)
+	}
+
+	for _, bad := range []string{"branch", "1st=x", "index=3", "a-b=c"} {
+		if _, err = metrics.ParseLabels([]string{bad}); !errors.Is(err, metrics.ErrInvalidLabel) {
+			t.Errorf("ParseLabels(%q) error = %v, want ErrInvalidLabel", bad, err)
+		}
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	for _, raw := range []string{
+		"udp://localhost:8125",
+		"statsd://localhost",
+		"pushgateway://localhost:9091",
+		"pushgateway://localhost:9091/jobs/x",
+		"pushgateway://localhost:9091/job/a/b",
+	} {
+		if _, err := metrics.Open(raw); !errors.Is(err, metrics.ErrUnsupportedURL) {
+			t.Errorf("Open(%q) error = %v, want ErrUnsupportedURL", raw, err)
+		}
+	}
+}
+
+func TestStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	sink, err := metrics.Open("statsd://" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err = sink.Emit(t.Context(), metrics.Gauges(testRun())); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("No packet received: %v", err)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	if len(lines) != 6 {
+		t.Errorf("Got %d lines, want 6: %q", len(lines), lines)
+	}
+	want := "tests_helper.worker_predicted_seconds:15|g|#branch:main,index:1,total:2,worker:1"
+	if !slices.Contains(lines, want) {
+		t.Errorf("Packet %q lacks %q", buf[:n], want)
+	}
+}
+
+func TestPushgateway(t *testing.T) {
+	var path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, contentType, body = r.Method+" "+r.URL.Path, r.Header.Get("Content-Type"), string(data)
+		if strings.Contains(r.URL.Path, "broken") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	sink, err := metrics.Open("pushgateway://" + host + "/job/tests-helper")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err = sink.Emit(t.Context(), metrics.Gauges(testRun())); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	if path != "PUT /metrics/job/tests-helper/index/1" {
+		t.Errorf("Request = %q, want a PUT to the group of worker 1", path)
+	}
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	for _, want := range []string{
+		"# TYPE tests_helper_worker_predicted_seconds gauge\n",
+		`tests_helper_worker_predicted_seconds{branch="main",total="2",worker="0"} 25` + "\n",
+		`tests_helper_imbalance_percent{branch="main",total="2"} 25` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Body lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Count(body, "# TYPE tests_helper_worker_predicted_seconds") != 1 {
+		t.Errorf("Worker samples not grouped under one TYPE line:\n%s", body)
+	}
+
+	sink, _ = metrics.Open("pushgateway://" + host + "/job/broken")
+	if err = sink.Emit(t.Context(), nil); err == nil {
+		t.Error("Expected an error for a rejected push")
+	}
+}
+
+func TestEmit(t *testing.T) {
+	slow := &fakeSink{hang: true}
+	failing := &fakeSink{err: errors.New("unreachable")}
+	ok := &fakeSink{}
+	gauges := metrics.Gauges(testRun())
+
+	start := time.Now()
+	metrics.Emit(t.Context(), zerolog.Nop(), []metrics.Sink{slow, failing, ok}, gauges, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Emit took %v despite the timeout", elapsed)
+	}
+	if len(ok.gauges) != len(gauges) {
+		t.Errorf("Sink after a failing one got %d gauges, want %d", len(ok.gauges), len(gauges))
+	}
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	prometheusPrefix = "tests_helper_"
+	// exposition is the content type of the Prometheus text format.
+	exposition = "text/plain; version=0.0.4"
+	// groupingLabel moves from the samples into the grouping key, so parallel workers
+	// push separate groups instead of replacing each other's.
+	groupingLabel = "index"
+)
+
+// pushgateway replaces a group of a Prometheus Pushgateway with the gauges of each run.
+type pushgateway struct {
+	http *http.Client
+	url  string
+	// endpoint is the group URL without the grouping label, e.g. http://host:9091/metrics/job/tests-helper.
+	endpoint string
+}
+
+func newPushgateway(raw, rest string) (*pushgateway, error) {
+	host, path, _ := strings.Cut(rest, "/")
+	job, found := strings.CutPrefix(path, "job/")
+	job = strings.TrimSuffix(job, "/")
+	if host == "" || !found || job == "" || strings.Contains(job, "/") {
+		return nil, fmt.Errorf("%w: %q (expected pushgateway://host:port/job/<name>)", ErrUnsupportedURL, raw)
+	}
+	return &pushgateway{
+		http:     http.DefaultClient,
+		url:      raw,
+		endpoint: "http://" + host + "/metrics/job/" + url.PathEscape(job),
+	}, nil
+}
+
+func (p *pushgateway) Emit(ctx context.Context, gauges []Gauge) error {
+	target := p.endpoint
+	if len(gauges) > 0 {
+		if value, ok := gauges[0].Labels[groupingLabel]; ok {
+			target += "/" + groupingLabel + "/" + url.PathEscape(value)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(expose(gauges)))
+	if err != nil {
+		return fmt.Errorf("cannot create request: %w", err)
+	}
+	req.Header.Set("Content-Type", exposition)
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("push to %s failed: %w", target, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("push to %s failed: %s", target, resp.Status)
+	}
+	return nil
+}
+
+func (p *pushgateway) Describe() string {
+	return p.url
+}
+
+// expose formats gauges in the Prometheus text format, grouping samples of the same name
+// under one TYPE line. The grouping label is left out of the samples.
+func expose(gauges []Gauge) []byte {
+	byName := make(map[string][]Gauge)
+	var names []string
+	for _, g := range gauges {
+		if _, seen := byName[g.Name]; !seen {
+			names = append(names, g.Name)
+		}
+		byName[g.Name] = append(byName[g.Name], g)
+	}
+
+	var b bytes.Buffer
+	for _, name := range names {
+		metric := prometheusPrefix + name
+		b.WriteString("# TYPE " + metric + " gauge\n")
+		for _, g := range byName[name] {
+			b.WriteString(metric)
+			b.WriteString(promLabels(g.Labels))
+			b.WriteString(" " + strconv.FormatFloat(g.Value, 'g', -1, 64) + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// promLabels formats labels as {key="value",...} in key order, escaping values.
+func promLabels(labels map[string]string) string {
+	keys := slices.DeleteFunc(slices.Sorted(maps.Keys(labels)), func(k string) bool { return k == groupingLabel })
+	if len(keys) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + `="` + escape.Replace(labels[k]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	statsdPrefix = "tests_helper."
+	// maxPacketBytes keeps each datagram below the common 1500-byte MTU.
+	maxPacketBytes = 1432
+)
+
+// statsD sends gauges over UDP in the DogStatsD format, with labels as tags.
+type statsD struct {
+	url  string
+	addr string
+}
+
+func newStatsD(raw, rest string) (*statsD, error) {
+	addr := strings.TrimSuffix(rest, "/")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrUnsupportedURL, raw, err)
+	}
+	return &statsD{url: raw, addr: addr}, nil
+}
+
+func (s *statsD) Emit(ctx context.Context, gauges []Gauge) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("cannot reach %s: %w", s.addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+
+	for _, packet := range packets(gauges) {
+		if _, err = conn.Write(packet); err != nil {
+			return fmt.Errorf("cannot send to %s: %w", s.addr, err)
+		}
+	}
+	return nil
+}
+
+func (s *statsD) Describe() string {
+	return s.url
+}
+
+// packets formats gauges as newline-separated lines, split into datagrams of at most maxPacketBytes.
+func packets(gauges []Gauge) [][]byte {
+	var packets [][]byte
+	var packet []byte
+	for _, g := range gauges {
+		line := statsdLine(g)
+		if len(packet) > 0 && len(packet)+1+len(line) > maxPacketBytes {
+			packets = append(packets, packet)
+			packet = nil
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+// statsdLine formats a gauge as "tests_helper.<name>:<value>|g|#key:value,...", with sorted tags.
+func statsdLine(g Gauge) string {
+	var b strings.Builder
+	b.WriteString(statsdPrefix + g.Name + ":" + strconv.FormatFloat(g.Value, 'f', -1, 64) + "|g")
+	keys := slices.Sorted(maps.Keys(g.Labels))
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteString("|#")
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(k + ":" + g.Labels[k])
+	}
+	return b.String()
+}
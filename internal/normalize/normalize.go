@@ -0,0 +1,119 @@
+// Package normalize rewrites test names and stats keys into a canonical form so that
+// differently spelled references to the same test can be matched.
+package normalize
+
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer converts names into matching keys. The zero value leaves names unchanged.
+type Normalizer struct {
+	prefixes   []string // Stripped from the start of names, each ending with "/"
+	cleanPaths bool
+	unicodeNFC bool
+}
+
+// Option configures a Normalizer.
+type Option func(*Normalizer)
+
+// WithCleanPaths toggles path cleaning: converting Windows "\" separators to "/", stripping a
+// leading "./", collapsing "//", and resolving "a/../b" segments.
+func WithCleanPaths(enabled bool) Option {
+	return func(n *Normalizer) {
+		n.cleanPaths = enabled
+	}
+}
+
+// WithUnicodeNFC toggles converting names to Unicode normalization form C, so that
+// decomposed spellings (as produced on macOS) match composed ones.
+func WithUnicodeNFC(enabled bool) Option {
+	return func(n *Normalizer) {
+		n.unicodeNFC = enabled
+	}
+}
+
+// WithStripPrefixes sets directories stripped from the start of names, such as the checkout
+// directory "/home/ci/project" in the absolute paths of some reports. The first prefix that
+// matches whole path segments is stripped; a name equal to a prefix is left unchanged.
+// Prefixes are cleaned like names when path cleaning is enabled.
+func WithStripPrefixes(prefixes ...string) Option {
+	return func(n *Normalizer) {
+		n.prefixes = prefixes
+	}
+}
+
+// New creates a Normalizer with every normalization step enabled by default.
+func New(opts ...Option) Normalizer {
+	n := Normalizer{
+		cleanPaths: true,
+		unicodeNFC: true,
+	}
+	for _, opt := range opts {
+		opt(&n)
+	}
+
+	prefixes := make([]string, 0, len(n.prefixes))
+	for _, prefix := range n.prefixes {
+		if n.cleanPaths {
+			prefix = cleanPath(prefix)
+		}
+		if prefix = strings.TrimSuffix(prefix, "/") + "/"; prefix != "./" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	n.prefixes = prefixes
+	return n
+}
+
+// Key returns the form of name used for matching.
+func (n Normalizer) Key(name string) string {
+	if n.unicodeNFC && !isASCII(name) && !norm.NFC.IsNormalString(name) {
+		name = norm.NFC.String(name)
+	}
+	if n.cleanPaths && name != "" {
+		name = cleanPath(name)
+	}
+	for _, prefix := range n.prefixes {
+		if rest, ok := strings.CutPrefix(name, prefix); ok && rest != "" {
+			return rest
+		}
+	}
+	return name
+}
+
+// cleanPath converts the separators of name to "/" and cleans the result.
+func cleanPath(name string) string {
+	return path.Clean(strings.ReplaceAll(name, `\`, "/"))
+}
+
+// String describes the enabled steps. It is stable and suitable for use in cache keys.
+func (n Normalizer) String() string {
+	var steps []string
+	if n.unicodeNFC {
+		steps = append(steps, "nfc")
+	}
+	if n.cleanPaths {
+		steps = append(steps, "clean")
+	}
+	for _, prefix := range n.prefixes {
+		steps = append(steps, "strip="+prefix)
+	}
+	if len(steps) == 0 {
+		return "none"
+	}
+	return strings.Join(steps, "+")
+}
+
+// isASCII reports whether s is pure ASCII, and therefore already in every normalization form.
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package normalize_test
+
+import (
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/normalize"
+)
+
+func TestNormalizer_Key(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "already clean", input: "pkg/api/handler_test.go", want: "pkg/api/handler_test.go"},
+		{name: "leading dot slash", input: "./pkg/api/handler_test.go", want: "pkg/api/handler_test.go"},
+		{name: "double slash", input: "pkg//api///handler_test.go", want: "pkg/api/handler_test.go"},
+		{name: "parent segment", input: "pkg/tmp/../api/handler_test.go", want: "pkg/api/handler_test.go"},
+		{name: "absolute", input: "/workspace/./pkg/a_test.go", want: "/workspace/pkg/a_test.go"},
+		{name: "leading parent kept", input: "../pkg/a_test.go", want: "../pkg/a_test.go"},
+		{name: "backslashes", input: `.\pkg\api\handler_test.go`, want: "pkg/api/handler_test.go"},
+		{name: "mixed separators", input: `pkg\api//handler_test.go`, want: "pkg/api/handler_test.go"},
+		{name: "empty", input: "", want: ""},
+	}
+
+	n := normalize.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := n.Key(tt.input); got != tt.want {
+				t.Errorf("Key(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizer_Disabled(t *testing.T) {
+	n := normalize.New(normalize.WithCleanPaths(false))
+	if got := n.Key("./pkg//a_test.go"); got != "./pkg//a_test.go" {
+		t.Errorf("Key changed name with cleaning disabled: %q", got)
+	}
+
+	var zero normalize.Normalizer
+	if got := zero.Key("./a_test.go"); got != "./a_test.go" {
+		t.Errorf("Zero value changed name: %q", got)
+	}
+}
+
+func TestNormalizer_StripPrefixes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "absolute", input: "/home/ci/project/pkg/a_test.go", want: "pkg/a_test.go"},
+		{name: "unclean", input: "/home/ci//project/./pkg/a_test.go", want: "pkg/a_test.go"},
+		{name: "second prefix", input: `C:\build\pkg\a_test.go`, want: "pkg/a_test.go"},
+		{name: "partial segment", input: "/home/ci/project2/pkg/a_test.go", want: "/home/ci/project2/pkg/a_test.go"},
+		{name: "prefix only", input: "/home/ci/project", want: "/home/ci/project"},
+		{name: "relative", input: "pkg/a_test.go", want: "pkg/a_test.go"},
+	}
+
+	n := normalize.New(normalize.WithStripPrefixes("/home/ci/project/", `C:\build`))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := n.Key(tt.input); got != tt.want {
+				t.Errorf("Key(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizer_String(t *testing.T) {
+	if got := normalize.New().String(); got != "nfc+clean" {
+		t.Errorf("Default: got %q, want %q", got, "nfc+clean")
+	}
+	if got := normalize.New(normalize.WithCleanPaths(false)).String(); got != "nfc" {
+		t.Errorf("Without path cleaning: got %q, want %q", got, "nfc")
+	}
+	if got := normalize.New(normalize.WithStripPrefixes("/src/")).String(); got != "nfc+clean+strip=/src/" {
+		t.Errorf("With a prefix: got %q, want %q", got, "nfc+clean+strip=/src/")
+	}
+	disabled := normalize.New(normalize.WithCleanPaths(false), normalize.WithUnicodeNFC(false))
+	if got := disabled.String(); got != "none" {
+		t.Errorf("Disabled: got %q, want %q", got, "none")
+	}
+}
+
+func TestNormalizer_UnicodeNFC(t *testing.T) {
+	const (
+		composed   = "tests/caf\u00e9_test.go"  // é as a single code point (Linux reports)
+		decomposed = "tests/cafe\u0301_test.go" // e + combining acute accent (macOS lists)
+	)
+
+	n := normalize.New()
+	if n.Key(composed) != n.Key(decomposed) {
+		t.Errorf("Composed %q and decomposed %q forms produce different keys", composed, decomposed)
+	}
+	if got := n.Key(decomposed); got != composed {
+		t.Errorf("Key(%q) = %q, want the composed form %q", decomposed, got, composed)
+	}
+
+	off := normalize.New(normalize.WithUnicodeNFC(false))
+	if off.Key(composed) == off.Key(decomposed) {
+		t.Error("Forms should differ with Unicode normalization disabled")
+	}
+}
@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+const (
+	// maxFactors bounds the contributing factors listed in a notification.
+	maxFactors = 5
+	// maxExamples bounds the untimed test names quoted as examples.
+	maxExamples = 3
+)
+
+// Metrics a condition can test.
+const (
+	Imbalance  = "imbalance"   // Percent by which the busiest worker exceeds the average
+	AllDefault = "all-default" // Percent of tests without timing data, using the default time
+)
+
+// ErrInvalidCondition is returned for a malformed --notify-on expression.
+var ErrInvalidCondition = errors.New("invalid notify condition")
+
+// Condition compares a metric of the run with a threshold, e.g. "imbalance>20".
+type Condition struct {
+	Metric    string
+	Op        string // One of >, >=, <, <=
+	Threshold float64
+}
+
+// String returns the condition as written, e.g. "imbalance>20".
+func (c Condition) String() string {
+	return c.Metric + c.Op + strconv.FormatFloat(c.Threshold, 'f', -1, 64)
+}
+
+// Holds reports whether value satisfies the condition.
+func (c Condition) Holds(value float64) bool {
+	switch c.Op {
+	case ">":
+		return value > c.Threshold
+	case ">=":
+		return value >= c.Threshold
+	case "<":
+		return value < c.Threshold
+	default:
+		return value <= c.Threshold
+	}
+}
+
+// ParseConditions parses expressions like "imbalance>20" or "all-default>=50". Each entry
+// may hold several comma-separated expressions; empty entries are ignored.
+func ParseConditions(exprs []string) ([]Condition, error) {
+	var conds []Condition
+	for _, entry := range exprs {
+		for expr := range strings.SplitSeq(entry, ",") {
+			expr = strings.TrimSpace(expr)
+			if expr == "" {
+				continue
+			}
+			cond, err := parseCondition(expr)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+	}
+	return conds, nil
+}
+
+func parseCondition(expr string) (Condition, error) {
+	i := strings.IndexAny(expr, "<>")
+	if i < 0 {
+		return Condition{}, fmt.Errorf("%w: %q (expected e.g. imbalance>20)", ErrInvalidCondition, expr)
+	}
+	cond := Condition{Metric: strings.TrimSpace(expr[:i]), Op: expr[i : i+1]}
+	rest := expr[i+1:]
+	if strings.HasPrefix(rest, "=") {
+		cond.Op += "="
+		rest = rest[1:]
+	}
+	if cond.Metric != Imbalance && cond.Metric != AllDefault {
+		return Condition{}, fmt.Errorf("%w: unknown metric %q in %q (expected %s or %s)",
+			ErrInvalidCondition, cond.Metric, expr, Imbalance, AllDefault)
+	}
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(rest), 64)
+	if err != nil {
+		return Condition{}, fmt.Errorf("%w: invalid threshold in %q", ErrInvalidCondition, expr)
+	}
+	cond.Threshold = threshold
+	return cond, nil
+}
+
+// Run is a completed split, with every worker's tests.
+type Run struct {
+	Workers      []worker.Worker
+	Distribution worker.Distribution
+	Index        int // Worker index of this run
+}
+
+// Evaluate tests conds against run and returns the notification to send,
+// or false when no condition holds.
+func Evaluate(conds []Condition, run Run) (Payload, bool) {
+	summary := summarize(run)
+	values := map[string]float64{
+		Imbalance:  summary.ImbalancePercent,
+		AllDefault: summary.AllDefaultPercent,
+	}
+
+	var triggered []Trigger
+	for _, cond := range conds {
+		if value := values[cond.Metric]; cond.Holds(value) {
+			triggered = append(triggered, Trigger{Condition: cond.String(), Value: value})
+		}
+	}
+	if len(triggered) == 0 {
+		return Payload{}, false
+	}
+
+	payload := Payload{Triggered: triggered, Factors: factors(run, summary), Run: summary}
+	payload.Text = text(payload)
+	return payload, true
+}
+
+func summarize(run Run) Summary {
+	dist := run.Distribution
+	s := Summary{
+		Index:                 run.Index,
+		Total:                 len(run.Workers),
+		PredictedTotalSeconds: dist.TotalTime,
+		MaxWorkerSeconds:      dist.MaxTotal(),
+		ImbalancePercent:      dist.ImbalancePercent(),
+		Efficiency:            dist.Efficiency(),
+	}
+	for _, w := range run.Workers {
+		s.Tests += len(w.Tests)
+		for _, test := range w.Tests {
+			if test.Source == junit.SourceDefault {
+				s.UntimedTests++
+			}
+		}
+	}
+	if s.Tests > 0 {
+		s.AllDefaultPercent = float64(s.UntimedTests) / float64(s.Tests) * worker.Percent
+	}
+	return s
+}
+
+// factors explains the run's quality: how many tests lack timings, and which tests
+// make the busiest worker the slowest.
+func factors(run Run, summary Summary) []string {
+	var found []string
+	if summary.UntimedTests > 0 {
+		var examples []string
+		for _, w := range run.Workers {
+			for _, test := range w.Tests {
+				if test.Source == junit.SourceDefault && len(examples) < maxExamples {
+					examples = append(examples, test.Name)
+				}
+			}
+		}
+		found = append(found, fmt.Sprintf("%d of %d tests (%.0f%%) have no timing data, e.g. %s",
+			summary.UntimedTests, summary.Tests, summary.AllDefaultPercent, strings.Join(examples, ", ")))
+	}
+
+	busiest := -1
+	for i, w := range run.Workers {
+		if busiest < 0 || w.Total > run.Workers[busiest].Total {
+			busiest = i
+		}
+	}
+	if busiest < 0 || run.Workers[busiest].Total <= 0 {
+		return found
+	}
+	found = append(found, fmt.Sprintf("worker %d is predicted at %.1fs against an average of %.1fs",
+		busiest, run.Workers[busiest].Total, run.Distribution.AvgTime))
+
+	tests := slices.Clone(run.Workers[busiest].Tests)
+	slices.SortStableFunc(tests, func(a, b junit.Test) int { return cmp.Compare(b.Time, a.Time) })
+	for _, test := range tests {
+		if len(found) >= maxFactors {
+			break
+		}
+		found = append(found, fmt.Sprintf("%s takes %.1fs (%.0f%% of worker %d)",
+			test.Name, test.Time, test.Time/run.Workers[busiest].Total*worker.Percent, busiest))
+	}
+	return found
+}
+
+// text renders payload as a Slack message.
+func text(payload Payload) string {
+	conds := make([]string, len(payload.Triggered))
+	for i, t := range payload.Triggered {
+		conds[i] = fmt.Sprintf("%s (%.1f)", t.Condition, t.Value)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, ":warning: tests-helper split quality degraded across %d workers: %s",
+		payload.Run.Total, strings.Join(conds, ", "))
+	for _, f := range payload.Factors {
+		b.WriteString("\n• " + f)
+	}
+	return b.String()
+}
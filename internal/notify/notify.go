@@ -0,0 +1,103 @@
+// Package notify posts a webhook message when the quality of a split crosses configured thresholds.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultTimeout bounds the delivery of a notification.
+const DefaultTimeout = 5 * time.Second
+
+// ErrInvalidURL is returned for webhook URLs other than http:// and https://.
+var ErrInvalidURL = errors.New("invalid webhook URL")
+
+// Payload is the JSON body posted to the webhook. Text makes it a valid Slack incoming-webhook message.
+type Payload struct {
+	Text      string    `json:"text"`
+	Triggered []Trigger `json:"triggered"`
+	Factors   []string  `json:"factors"`
+	Run       Summary   `json:"run"`
+}
+
+// Summary describes the split that triggered the notification.
+type Summary struct {
+	Index                 int     `json:"index"`
+	Total                 int     `json:"total"`
+	Tests                 int     `json:"tests"`
+	UntimedTests          int     `json:"untimed_tests"`
+	PredictedTotalSeconds float64 `json:"predicted_total_seconds"`
+	MaxWorkerSeconds      float64 `json:"max_worker_seconds"`
+	ImbalancePercent      float64 `json:"imbalance_percent"`
+	AllDefaultPercent     float64 `json:"all_default_percent"`
+	Efficiency            float64 `json:"efficiency"`
+}
+
+// Trigger is a condition that matched, with the value that matched it.
+type Trigger struct {
+	Condition string  `json:"condition"`
+	Value     float64 `json:"value"`
+}
+
+// Notifier posts payloads to a webhook.
+type Notifier struct {
+	http *http.Client
+	url  string
+}
+
+// Option configures a Notifier.
+type Option func(*Notifier)
+
+// WithHTTPClient sets the HTTP client used for requests. A nil client keeps the default.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(n *Notifier) {
+		if hc != nil {
+			n.http = hc
+		}
+	}
+}
+
+// NewNotifier returns a notifier posting to the http:// or https:// URL target.
+func NewNotifier(target string, opts ...Option) (*Notifier, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q (expected an http:// or https:// URL)", ErrInvalidURL, target)
+	}
+	n := &Notifier{http: http.DefaultClient, url: target}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n, nil
+}
+
+// Send posts payload as JSON and fails unless the webhook answers with a 2xx status.
+func (n *Notifier) Send(ctx context.Context, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("cannot encode notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook request failed: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package notify_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/notify"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+func TestParseConditions(t *testing.T) {
+	conds, err := notify.ParseConditions([]string{"imbalance>20, all-default>=50", "", "imbalance <= 5.5"})
+	if err != nil {
+		t.Fatalf("ParseConditions failed: %v", err)
+	}
+	want := []notify.Condition{
+		{Metric: notify.Imbalance, Op: ">", Threshold: 20},
+		{Metric: notify.AllDefault, Op: ">=", Threshold: 50},
+		{Metric: notify.Imbalance, Op: "<=", Threshold: 5.5},
+	}
+	if len(conds) != len(want) {
+		t.Fatalf("Parsed %v, want %v", conds, want)
+	}
+	for i := range want {
+		if conds[i] != want[i] {
+			t.Errorf("Condition %d = %+v, want %+v", i, conds[i], want[i])
+		}
+	}
+	if got := conds[1].String(); got != "all-default>=50" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, bad := range []string{"imbalance", "imbalance=20", "speed>1", "imbalance>", "imbalance>x", ">20"} {
+		if _, err = notify.ParseConditions([]string{bad}); !errors.Is(err, notify.ErrInvalidCondition) {
+			t.Errorf("ParseConditions(%q) error = %v, want ErrInvalidCondition", bad, err)
+		}
+	}
+}
+
+func TestCondition_Holds(t *testing.T) {
+	tests := []struct {
+		op    string
+		value float64
+		want  bool
+	}{
+		{op: ">", value: 20, want: false},
+		{op: ">", value: 21, want: true},
+		{op: ">=", value: 20, want: true},
+		{op: "<", value: 20, want: false},
+		{op: "<=", value: 20, want: true},
+	}
+	for _, tt := range tests {
+		cond := notify.Condition{Metric: notify.Imbalance, Op: tt.op, Threshold: 20}
+		if got := cond.Holds(tt.value); got != tt.want {
+			t.Errorf("%v.Holds(%v) = %v, want %v", cond, tt.value, got, tt.want)
+		}
+	}
+}
+
+// testRun has worker 0 at 30s and worker 1 at 10s (imbalance 50%), with one of four tests untimed.
+func testRun() notify.Run {
+	return notify.Run{
+		Workers: []worker.Worker{
+			{Total: 30, Tests: []junit.Test{
+				{Name: "slow_test.go", Time: 25, Source: junit.SourceStats},
+				{Name: "new_test.go", Time: 5, Source: junit.SourceDefault},
+			}},
+			{Total: 10, Tests: []junit.Test{
+				{Name: "a_test.go", Time: 6, Source: junit.SourceStats},
+				{Name: "b_test.go", Time: 4, Source: junit.SourceStats},
+			}},
+		},
+		Distribution: worker.Distribution{
+			TotalTime: 40,
+			AvgTime:   20,
+			Workers:   []worker.Stats{{Index: 0, Total: 30}, {Index: 1, Total: 10}},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	conds, _ := notify.ParseConditions([]string{"imbalance>20", "all-default>50"})
+
+	payload, ok := notify.Evaluate(conds, testRun())
+	if !ok {
+		t.Fatal("Expected the imbalance condition to trigger")
+	}
+	if len(payload.Triggered) != 1 || payload.Triggered[0].Condition != "imbalance>20" ||
+		payload.Triggered[0].Value != 50 {
+		t.Errorf("Triggered = %+v, want only imbalance>20 at 50", payload.Triggered)
+	}
+	if payload.Run.Tests != 4 || payload.Run.UntimedTests != 1 || payload.Run.AllDefaultPercent != 25 {
+		t.Errorf("Run = %+v", payload.Run)
+	}
+	for _, want := range []string{
+		"imbalance>20 (50.0)", "1 of 4 tests (25%)", "new_test.go", "worker 0", "slow_test.go",
+	} {
+		if !strings.Contains(payload.Text, want) {
+			t.Errorf("Text lacks %q:\n%s", want, payload.Text)
+		}
+	}
+
+	conds, _ = notify.ParseConditions([]string{"imbalance>60"})
+	if _, ok = notify.Evaluate(conds, testRun()); ok {
+		t.Error("Expected no notification below the threshold")
+	}
+}
+
+func TestNotifier_Send(t *testing.T) {
+	var got notify.Payload
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Cannot decode payload: %v", err)
+		}
+		if strings.HasSuffix(r.URL.Path, "/broken") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	conds, _ := notify.ParseConditions([]string{"imbalance>20"})
+	payload, _ := notify.Evaluate(conds, testRun())
+	notifier, err := notify.NewNotifier(server.URL + "/hook")
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+	if err = notifier.Send(t.Context(), payload); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if contentType != "application/json" || got.Text != payload.Text || got.Run.Total != 2 {
+		t.Errorf("Received %q %+v", contentType, got)
+	}
+
+	notifier, _ = notify.NewNotifier(server.URL + "/broken")
+	if err = notifier.Send(t.Context(), payload); err == nil {
+		t.Error("Expected an error for a 500 response")
+	}
+
+	for _, bad := range []string{"hooks.slack.com/x", "ftp://host/x", "https://"} {
+		if _, err = notify.NewNotifier(bad); !errors.Is(err, notify.ErrInvalidURL) {
+			t.Errorf("NewNotifier(%q) error = %v, want ErrInvalidURL", bad, err)
+		}
+	}
+}
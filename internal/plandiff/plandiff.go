@@ -0,0 +1,134 @@
+// Package plandiff compares two split plans of the same test list, e.g. before and after
+// adopting new timings.
+package plandiff
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// Report describes how the shards change from the plan before to the plan after.
+type Report struct {
+	Workers []WorkerDiff `json:"workers"`
+	// Moved lists the tests assigned to another worker after, by worker before, then name.
+	Moved []Move `json:"moved"`
+	// Tests counts the tests of the plan after.
+	Tests int `json:"tests"`
+	// Before and After sum every worker's predicted time.
+	Before float64 `json:"predicted_before"`
+	After  float64 `json:"predicted_after"`
+	// ImbalanceBefore and ImbalanceAfter are the ratios of the busiest worker's time to the average.
+	ImbalanceBefore float64 `json:"imbalance_before"`
+	ImbalanceAfter  float64 `json:"imbalance_after"`
+}
+
+// WorkerDiff compares one worker's tests and predicted time in both plans.
+type WorkerDiff struct {
+	Index       int     `json:"index"`
+	TestsBefore int     `json:"tests_before"`
+	TestsAfter  int     `json:"tests_after"`
+	MovedIn     int     `json:"moved_in"`
+	MovedOut    int     `json:"moved_out"`
+	Before      float64 `json:"predicted_before"`
+	After       float64 `json:"predicted_after"`
+	Change      float64 `json:"change"` // After minus Before
+}
+
+// Move is a test assigned to another worker after, with its predicted time in both plans.
+type Move struct {
+	Name   string  `json:"name"`
+	From   int     `json:"from"`
+	To     int     `json:"to"`
+	Before float64 `json:"time_before"`
+	After  float64 `json:"time_after"`
+}
+
+// placement is one occurrence of a test in a plan.
+type placement struct {
+	worker int
+	time   float64
+}
+
+// Compare matches the tests of before and after by name. A test listed more than once is
+// matched occurrence by occurrence; tests found in only one plan are not reported as moved.
+// Workers missing from one plan count as empty there.
+func Compare(before, after manifest.Manifest) Report {
+	r := Report{
+		Workers:         make([]WorkerDiff, max(len(before.Groups), len(after.Groups))),
+		ImbalanceBefore: before.Distribution.Imbalance(),
+		ImbalanceAfter:  after.Distribution.Imbalance(),
+	}
+	for i := range r.Workers {
+		r.Workers[i].Index = i
+	}
+	for i, group := range before.Groups {
+		r.Workers[i].TestsBefore = len(group.Tests)
+		r.Workers[i].Before = group.Total
+		r.Before += group.Total
+	}
+	for i, group := range after.Groups {
+		r.Workers[i].TestsAfter = len(group.Tests)
+		r.Workers[i].After = group.Total
+		r.After += group.Total
+		r.Tests += len(group.Tests)
+	}
+	for i := range r.Workers {
+		r.Workers[i].Change = r.Workers[i].After - r.Workers[i].Before
+	}
+
+	r.Moved = moves(before.Groups, after.Groups)
+	for _, m := range r.Moved {
+		r.Workers[m.From].MovedOut++
+		r.Workers[m.To].MovedIn++
+	}
+	return r
+}
+
+// moves pairs the occurrences of every test that are not on the same worker in both plans.
+func moves(before, after []worker.Worker) []Move {
+	from, to := placements(before), placements(after)
+	var out []Move
+	for _, name := range slices.Sorted(maps.Keys(from)) {
+		was, is := unmatched(from[name], to[name])
+		for i := range min(len(was), len(is)) {
+			out = append(out, Move{
+				Name: name, From: was[i].worker, To: is[i].worker, Before: was[i].time, After: is[i].time,
+			})
+		}
+	}
+	// Stable, so duplicates moving between the same workers keep the order of the plans
+	slices.SortStableFunc(out, func(x, y Move) int {
+		return cmp.Or(cmp.Compare(x.From, y.From), cmp.Compare(x.Name, y.Name), cmp.Compare(x.To, y.To))
+	})
+	return out
+}
+
+// placements returns where every occurrence of each test is, by name.
+func placements(groups []worker.Worker) map[string][]placement {
+	out := make(map[string][]placement)
+	for i, group := range groups {
+		for _, t := range group.Tests {
+			out[t.Name] = append(out[t.Name], placement{worker: i, time: t.Time})
+		}
+	}
+	return out
+}
+
+// unmatched drops the occurrences found on the same worker in was and is, keeping the order of the rest.
+func unmatched(was, is []placement) ([]placement, []placement) {
+	rest := slices.Clone(is)
+	var moved []placement
+	for _, p := range was {
+		i := slices.IndexFunc(rest, func(q placement) bool { return q.worker == p.worker })
+		if i < 0 {
+			moved = append(moved, p)
+			continue
+		}
+		rest = slices.Delete(rest, i, i+1)
+	}
+	return moved, rest
+}
@@ -0,0 +1,155 @@
+package plandiff_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/internal/plandiff"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// plan builds a manifest from the tests of every worker, with a distribution of their totals.
+func plan(groups ...[]junit.Test) manifest.Manifest {
+	var m manifest.Manifest
+	for i, tests := range groups {
+		w := worker.Worker{Tests: tests}
+		for _, t := range tests {
+			w.Total += t.Time
+		}
+		m.Groups = append(m.Groups, w)
+		m.Distribution.Workers = append(m.Distribution.Workers, worker.Stats{Index: i, Total: w.Total})
+		m.Distribution.TotalTime += w.Total
+	}
+	m.Distribution.AvgTime = m.Distribution.TotalTime / float64(len(groups))
+	return m
+}
+
+func TestCompare(t *testing.T) {
+	before := plan(
+		[]junit.Test{{Name: "a", Time: 10}, {Name: "b", Time: 2}},
+		[]junit.Test{{Name: "c", Time: 4}, {Name: "d", Time: 4}},
+	)
+	// b got slower and moved to worker 1, d moved to worker 0
+	after := plan(
+		[]junit.Test{{Name: "a", Time: 10}, {Name: "d", Time: 4}},
+		[]junit.Test{{Name: "b", Time: 9}, {Name: "c", Time: 4}},
+	)
+
+	r := plandiff.Compare(before, after)
+
+	want := []plandiff.Move{
+		{Name: "b", From: 0, To: 1, Before: 2, After: 9},
+		{Name: "d", From: 1, To: 0, Before: 4, After: 4},
+	}
+	if len(r.Moved) != len(want) || r.Moved[0] != want[0] || r.Moved[1] != want[1] {
+		t.Errorf("Moved = %+v, want %+v", r.Moved, want)
+	}
+	w0, w1 := r.Workers[0], r.Workers[1]
+	if w0.Before != 12 || w0.After != 14 || w0.Change != 2 || w0.MovedIn != 1 || w0.MovedOut != 1 {
+		t.Errorf("Worker 0 = %+v", w0)
+	}
+	if w1.Before != 8 || w1.After != 13 || w1.TestsAfter != 2 {
+		t.Errorf("Worker 1 = %+v", w1)
+	}
+	if r.Before != 20 || r.After != 27 || r.Tests != 4 {
+		t.Errorf("Report = %+v", r)
+	}
+	if r.ImbalanceBefore != 1.2 || r.ImbalanceAfter != 14/13.5 {
+		t.Errorf("Imbalance = %v -> %v, want 1.2 -> %v", r.ImbalanceBefore, r.ImbalanceAfter, 14/13.5)
+	}
+}
+
+func TestCompare_Duplicates(t *testing.T) {
+	// One occurrence of a stays on worker 0, the other moves from worker 1 to worker 2
+	before := plan([]junit.Test{{Name: "a", Time: 1}}, []junit.Test{{Name: "a", Time: 1}}, nil)
+	after := plan([]junit.Test{{Name: "a", Time: 1}}, nil, []junit.Test{{Name: "a", Time: 1}})
+
+	r := plandiff.Compare(before, after)
+	if len(r.Moved) != 1 || r.Moved[0].From != 1 || r.Moved[0].To != 2 {
+		t.Errorf("Moved = %+v, want a from worker 1 to 2", r.Moved)
+	}
+}
+
+func TestCompare_Unchanged(t *testing.T) {
+	p := plan([]junit.Test{{Name: "a", Time: 3}}, []junit.Test{{Name: "b", Time: 3}})
+	r := plandiff.Compare(p, p)
+	if len(r.Moved) != 0 || r.Workers[0].Change != 0 || r.ImbalanceBefore != r.ImbalanceAfter {
+		t.Errorf("Report = %+v, want no change", r)
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	r := plandiff.Compare(
+		plan([]junit.Test{{Name: "a|b", Time: 2}}, []junit.Test{{Name: "c", Time: 1}}),
+		plan([]junit.Test{{Name: "c", Time: 3}}, []junit.Test{{Name: "a|b", Time: 2}}),
+	)
+
+	var md bytes.Buffer
+	if err := r.WriteMarkdown(&md, duration.FormatSeconds); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, want := range []string{
+		"imbalance **1.33** → **1.20** (-0.13); 2 of 2 tests move",
+		"| 0 | 1 → 1 | 2.0s | 3.0s | +1.0s | 1 | 1 |",
+		"| `a\\|b` | 0 | 1 | 2.0s | 2.0s |",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, md.String())
+		}
+	}
+
+	var human bytes.Buffer
+	if err := r.WriteMarkdown(&human, duration.FormatHuman); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	if want := "| 1 | 1 → 1 | 1s | 2s | +1s | 1 | 1 |"; !strings.Contains(human.String(), want) {
+		t.Errorf("Human Markdown lacks %q:\n%s", want, human.String())
+	}
+
+	var js bytes.Buffer
+	if err := r.WriteJSON(&js); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded plandiff.Report
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(decoded.Moved) != 2 || decoded.ImbalanceAfter != r.ImbalanceAfter {
+		t.Errorf("Decoded = %+v, want %+v", decoded, r)
+	}
+}
+
+func TestReport_WriteJSONDeterministic(t *testing.T) {
+	// Duplicates of every test move together, so many moves share worker and name; the same
+	// plans listed in another order must still give the same bytes
+	var before, after, shuffledBefore []junit.Test
+	for i := range 20 {
+		name := "pkg/" + string(rune('a'+i)) + "_test.go"
+		before = append(before, junit.Test{Name: name, Time: float64(i)},
+			junit.Test{Name: name, Time: float64(i + 1)})
+		after = append(after, junit.Test{Name: name, Time: float64(i + 2)},
+			junit.Test{Name: name, Time: float64(i + 3)})
+	}
+	for i := len(before) - 2; i >= 0; i -= 2 {
+		shuffledBefore = append(shuffledBefore, before[i], before[i+1])
+	}
+
+	write := func(groups []junit.Test) []byte {
+		var buf bytes.Buffer
+		if err := plandiff.Compare(plan(groups, nil), plan(nil, after)).WriteJSON(&buf); err != nil {
+			t.Fatalf("WriteJSON failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+	want := write(before)
+	for range 10 {
+		if got := write(shuffledBefore); !bytes.Equal(got, want) {
+			t.Fatalf("WriteJSON =\n%s\nwant\n%s", got, want)
+		}
+	}
+}
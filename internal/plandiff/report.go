@@ -0,0 +1,58 @@
+package plandiff
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/duration"
+)
+
+// WriteMarkdown renders r as a Markdown report with a table per worker and the moved tests,
+// with durations rendered in the given format.
+func (r Report) WriteMarkdown(w io.Writer, durations duration.Format) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "## Test split plan diff\n\n")
+	fmt.Fprintf(bw, "Predicted **%s** before, **%s** after across %d workers; "+
+		"imbalance **%.2f** → **%.2f** (%+.2f); %d of %d tests move.\n",
+		durations.Render(r.Before, 1), durations.Render(r.After, 1), len(r.Workers), r.ImbalanceBefore, r.ImbalanceAfter,
+		r.ImbalanceAfter-r.ImbalanceBefore, len(r.Moved), r.Tests)
+
+	fmt.Fprintf(bw, "\n| Worker | Tests | Before | After | Change | In | Out |\n|---:|---:|---:|---:|---:|---:|---:|\n")
+	for _, ws := range r.Workers {
+		fmt.Fprintf(bw, "| %d | %d → %d | %s | %s | %s | %d | %d |\n",
+			ws.Index, ws.TestsBefore, ws.TestsAfter, durations.Render(ws.Before, 1), durations.Render(ws.After, 1),
+			durations.RenderSigned(ws.Change, 1), ws.MovedIn, ws.MovedOut)
+	}
+
+	if len(r.Moved) > 0 {
+		fmt.Fprintf(bw, "\n### Moved tests\n\n")
+		fmt.Fprintf(bw, "| Test | From | To | Before | After |\n|---|---:|---:|---:|---:|\n")
+		for _, m := range r.Moved {
+			fmt.Fprintf(bw, "| `%s` | %d | %d | %s | %s |\n", escapeCell(m.Name), m.From, m.To,
+				durations.Render(m.Before, 1), durations.Render(m.After, 1))
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON encodes r as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return nil
+}
+
+// escapeCell keeps a test name from breaking the table or its code span.
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "`", "'", "\n", " ").Replace(s)
+}
@@ -0,0 +1,70 @@
+// Package progress reports how far long operations have come, so embedders and the CLI can show
+// feedback while many stats files load or many tests are distributed.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Stages reported to a Reporter.
+const (
+	StageParse      = "parse"      // Stats files parsed, out of those matched
+	StageDistribute = "distribute" // Tests assigned to workers, out of those being split
+)
+
+// DistributeInterval is the number of assignments between two StageDistribute reports.
+const DistributeInterval = 1000
+
+// Reporter is notified as a long operation advances, at coarse granularity.
+type Reporter interface {
+	// OnStage is called when done of total units of stage are complete. The calls of one
+	// operation are serialized, done increases, and, unless the operation is cancelled, the last
+	// call has done equal to total.
+	OnStage(stage string, done, total int)
+}
+
+// Func adapts a function to a Reporter.
+type Func func(stage string, done, total int)
+
+// OnStage calls f.
+func (f Func) OnStage(stage string, done, total int) {
+	f(stage, done, total)
+}
+
+// Line is a Reporter rewriting a single terminal line, e.g. "Parsing stats files 12/300".
+// The cursor is left at the start of the line, so log lines written meanwhile overwrite it,
+// and the line is cleared once a stage completes. It is safe for concurrent use.
+type Line struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewLine returns a Line writing to w, which should be a terminal.
+func NewLine(w io.Writer) *Line {
+	return &Line{w: w}
+}
+
+// OnStage rewrites the line with the progress of stage. Write errors are ignored.
+func (l *Line) OnStage(stage string, done, total int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if done >= total {
+		_, _ = io.WriteString(l.w, "\r\x1b[K")
+		return
+	}
+	_, _ = fmt.Fprintf(l.w, "\r\x1b[K%s %d/%d\r", label(stage), done, total)
+}
+
+// label describes stage for a human.
+func label(stage string) string {
+	switch stage {
+	case StageParse:
+		return "Parsing stats files"
+	case StageDistribute:
+		return "Distributing tests"
+	default:
+		return stage
+	}
+}
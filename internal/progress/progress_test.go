@@ -0,0 +1,37 @@
+package progress_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/progress"
+)
+
+func TestLine(t *testing.T) {
+	var buf bytes.Buffer
+	line := progress.NewLine(&buf)
+
+	line.OnStage(progress.StageParse, 1, 3)
+	line.OnStage(progress.StageDistribute, 1000, 2500)
+	line.OnStage("custom", 1, 2)
+	line.OnStage(progress.StageDistribute, 2500, 2500)
+
+	want := "\r\x1b[KParsing stats files 1/3\r" +
+		"\r\x1b[KDistributing tests 1000/2500\r" +
+		"\r\x1b[Kcustom 1/2\r" +
+		"\r\x1b[K"
+	if got := buf.String(); got != want {
+		t.Errorf("Line wrote %q, want %q", got, want)
+	}
+}
+
+func TestFunc(t *testing.T) {
+	var got []int
+	var r progress.Reporter = progress.Func(func(_ string, done, total int) {
+		got = append(got, done, total)
+	})
+	r.OnStage(progress.StageParse, 2, 5)
+	if len(got) != 2 || got[0] != 2 || got[1] != 5 {
+		t.Errorf("Func received %v, want [2 5]", got)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+// plan is a computed split kept for later lookups.
+type plan struct {
+	id           string
+	distribution testsplit.Distribution
+	groups       []testsplit.Group
+}
+
+// manifest returns the plan in the schema of split --manifest, written by toolVersion.
+func (p *plan) manifest(toolVersion string) manifest.Manifest {
+	return manifest.Manifest{
+		Version:      manifest.Version,
+		ToolVersion:  toolVersion,
+		PlanID:       p.id,
+		Groups:       p.groups,
+		Distribution: p.distribution,
+	}
+}
+
+// planCache keeps the most recently used plans, evicting the least recently used beyond its capacity.
+type planCache struct {
+	entries  map[string]*list.Element
+	order    *list.List // front is the most recently used
+	capacity int
+	mu       sync.Mutex
+}
+
+// newPlanCache creates a cache holding at most capacity plans, and at least one.
+func newPlanCache(capacity int) *planCache {
+	return &planCache{
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		capacity: max(capacity, 1),
+	}
+}
+
+// get returns the plan with id, marking it as recently used.
+func (c *planCache) get(id string) (*plan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*plan), true
+}
+
+// put stores p, replacing a plan with the same id, and returns the number of plans evicted.
+func (c *planCache) put(p *plan) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[p.id]; ok {
+		elem.Value = p
+		c.order.MoveToFront(elem)
+		return 0
+	}
+	c.entries[p.id] = c.order.PushFront(p)
+
+	evicted := 0
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*plan).id)
+		evicted++
+	}
+	return evicted
+}
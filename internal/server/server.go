@@ -0,0 +1,358 @@
+// Package server serves split plans over HTTP.
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/manifest"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+const (
+	DefaultMaxRequestBytes = 1 << 20 // Default limit for a request body
+	DefaultPlanCacheSize   = 128     // Default number of plans kept for lookups
+	DefaultMaxWorkers      = 1024    // Default limit for the total of a request
+
+	planIDBytes = 16 // Length of the hash prefix identifying a plan
+)
+
+// Server computes split plans with a testsplit.Splitter and keeps them for later lookups.
+type Server struct {
+	logger          zerolog.Logger
+	splitter        *testsplit.Splitter
+	times           map[string]float64
+	plans           *planCache
+	token           string
+	toolVersion     string
+	maxRequestBytes int64
+	planCacheSize   int
+	maxWorkers      int
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMaxRequestBytes sets the largest request body accepted. Values below 1 keep the default.
+func WithMaxRequestBytes(n int64) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxRequestBytes = n
+		}
+	}
+}
+
+// WithPlanCacheSize sets how many plans are kept for GET lookups. Values below 1 keep the default.
+func WithPlanCacheSize(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.planCacheSize = n
+		}
+	}
+}
+
+// WithMaxWorkers sets the largest total a request may split into. Values below 1 keep the default.
+func WithMaxWorkers(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxWorkers = n
+		}
+	}
+}
+
+// WithToken requires requests to carry "Authorization: Bearer <token>". An empty token disables auth.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithToolVersion sets the version of tests-helper stamped into the returned plans.
+func WithToolVersion(version string) Option {
+	return func(s *Server) {
+		s.toolVersion = version
+	}
+}
+
+// New creates a server splitting tests with splitter, using the historical times.
+func New(logger zerolog.Logger, splitter *testsplit.Splitter, times map[string]float64, opts ...Option) *Server {
+	s := &Server{
+		logger:          logger,
+		splitter:        splitter,
+		times:           times,
+		maxRequestBytes: DefaultMaxRequestBytes,
+		planCacheSize:   DefaultPlanCacheSize,
+		maxWorkers:      DefaultMaxWorkers,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.plans = newPlanCache(s.planCacheSize)
+	return s
+}
+
+// Handler returns the HTTP handler serving:
+//
+//	POST /split          {"tests": [...], "total": N} -> the full plan
+//	GET  /split/{index}  ?plan=ID -> one group of a previously computed plan
+//	POST /split/{index}/drain  ?plan=ID -> a new plan without that group, and the moved tests
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /split", s.handleSplit)
+	mux.HandleFunc("GET /split/{index}", s.handleGroup)
+	mux.HandleFunc("POST /split/{index}/drain", s.handleDrain)
+	return s.logRequests(s.authenticate(mux))
+}
+
+// splitRequest is the body of POST /split.
+type splitRequest struct {
+	Tests []string `json:"tests"`
+	Total int      `json:"total"`
+}
+
+// groupResponse describes one group of a plan.
+type groupResponse struct {
+	PlanID string          `json:"plan_id"`
+	Group  testsplit.Group `json:"group"`
+	Index  int             `json:"index"`
+}
+
+// drainResponse is the body of POST /split/{index}/drain: the new plan and the tests it moved.
+type drainResponse struct {
+	manifest.Manifest
+
+	Moved []movedTest `json:"moved"`
+}
+
+// movedTest is a test of a drained group with the group it was moved to, indexed in the new plan.
+type movedTest struct {
+	Name   string           `json:"name"`
+	Source testsplit.Source `json:"source,omitempty"`
+	Time   float64          `json:"time"`
+	Group  int              `json:"group"`
+}
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleSplit(w http.ResponseWriter, r *http.Request) {
+	var req splitRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxRequestBytes))
+	if err := dec.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.writeError(w, http.StatusRequestEntityTooLarge,
+				fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	// Checked before the workers are allocated, since a huge total alone exhausts memory
+	if req.Total > s.maxWorkers {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("total %d exceeds the limit of %d", req.Total, s.maxWorkers))
+		return
+	}
+
+	p, err := s.computePlan(req)
+	var parseErr *testsplit.ParseError
+	switch {
+	case errors.Is(err, testsplit.ErrNoTests), errors.Is(err, testsplit.ErrInvalidWorkerCount),
+		errors.As(err, &parseErr):
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if evicted := s.plans.put(p); evicted > 0 {
+		s.logger.Debug().Int("evicted", evicted).Msg("Evicted cached plans")
+	}
+	s.writeJSON(w, http.StatusOK, p.manifest(s.toolVersion))
+}
+
+// computePlan splits the requested tests.
+func (s *Server) computePlan(req splitRequest) (*plan, error) {
+	// One name per line, the same input the split command reads
+	list := strings.Join(req.Tests, "\n")
+	tests, err := s.splitter.ReadTests(strings.NewReader(list), s.times)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.splitter.SplitInPlace(tests, req.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan{
+		id:           planID(list, req.Total),
+		distribution: result.Stats(testsplit.StatsOptions{}),
+		groups:       result.Groups(),
+	}, nil
+}
+
+// planID identifies the plan for a test list and total, so the same request yields the same id.
+func planID(list string, total int) string {
+	sum := sha256.Sum256([]byte(strconv.Itoa(total) + "\n" + list))
+	return hex.EncodeToString(sum[:planIDBytes])
+}
+
+func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request) {
+	p, index, ok := s.lookupGroup(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, groupResponse{PlanID: p.id, Group: p.groups[index], Index: index})
+}
+
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	p, index, ok := s.lookupGroup(w, r)
+	if !ok {
+		return
+	}
+	drained, moved, err := s.drainPlan(p, index)
+	switch {
+	case errors.Is(err, testsplit.ErrInvalidWorkerCount):
+		s.writeError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if evicted := s.plans.put(drained); evicted > 0 {
+		s.logger.Debug().Int("evicted", evicted).Msg("Evicted cached plans")
+	}
+	s.logger.Info().
+		Str("plan", p.id).
+		Str("drained_plan", drained.id).
+		Int("group", index).
+		Int("moved", len(moved)).
+		Msg("Drained group")
+
+	resp := drainResponse{
+		Manifest: drained.manifest(s.toolVersion),
+		Moved:    make([]movedTest, len(moved)),
+	}
+	for i, a := range moved {
+		resp.Moved[i] = movedTest{Name: a.Test.Name, Source: a.Test.Source, Time: a.Test.Time, Group: a.Worker}
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// drainPlan returns a new plan without the group at index of p, whose tests are spread across the
+// other groups, and where each of them went. p itself is left unchanged for ongoing lookups.
+func (s *Server) drainPlan(p *plan, index int) (*plan, []testsplit.Assignment, error) {
+	result, err := s.splitter.Resume(p.groups)
+	if err != nil {
+		return nil, nil, err
+	}
+	moved, err := result.Drain(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &plan{
+		// Draining the same group of the same plan yields the same id
+		id:           planID("drain "+p.id, index),
+		distribution: result.Stats(testsplit.StatsOptions{}),
+		groups:       result.Groups(),
+	}, moved, nil
+}
+
+// lookupGroup returns the cached plan named by the plan query parameter and the group index
+// from the path. It writes an error response and returns false when either is missing or unknown.
+func (s *Server) lookupGroup(w http.ResponseWriter, r *http.Request) (*plan, int, bool) {
+	id := r.URL.Query().Get("plan")
+	if id == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("missing plan query parameter"))
+		return nil, 0, false
+	}
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid group index %q", r.PathValue("index")))
+		return nil, 0, false
+	}
+
+	p, ok := s.plans.get(id)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("unknown or expired plan %q", id))
+		return nil, 0, false
+	}
+	if index < 0 || index >= len(p.groups) {
+		s.writeError(w, http.StatusNotFound,
+			fmt.Errorf("%w: %d (plan has %d groups)", testsplit.ErrInvalidWorkerIndex, index, len(p.groups)))
+		return nil, 0, false
+	}
+	return p, index, true
+}
+
+// authenticate rejects requests without the configured bearer token.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs every request once it has been served.
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		s.logger.Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", rec.status).
+			Dur("duration", time.Since(start)).
+			Msg("Served request")
+	})
+}
+
+// writeJSON writes v as the response body with status.
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to write response")
+	}
+}
+
+// writeError writes err as a JSON error response with status.
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,269 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/server"
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+// planBody is the decoded response of POST /split.
+type planBody struct {
+	PlanID      string `json:"plan_id"`
+	ToolVersion string `json:"tool_version"`
+	Groups      []struct {
+		Tests []struct {
+			Name string  `json:"name"`
+			Time float64 `json:"time"`
+		} `json:"tests"`
+		Total float64 `json:"total"`
+	} `json:"groups"`
+	Distribution struct {
+		TotalTime float64 `json:"total_time"`
+		Imbalance float64 `json:"imbalance"`
+	} `json:"distribution"`
+}
+
+func newTestServer(tb testing.TB, opts ...server.Option) http.Handler {
+	tb.Helper()
+	times := map[string]float64{"slow_test.go": 10, "medium_test.go": 6, "fast_test.go": 4}
+	return server.New(zerolog.Nop(), testsplit.New(), times, opts...).Handler()
+}
+
+func do(tb testing.TB, h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	tb.Helper()
+	req := httptest.NewRequestWithContext(tb.Context(), method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Split(t *testing.T) {
+	h := newTestServer(t, server.WithToolVersion("1.0.0"))
+
+	rec := do(t, h, http.MethodPost, "/split",
+		`{"tests": ["fast_test.go", "slow_test.go", "medium_test.go", "new_test.go"], "total": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /split: status %d, body %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var plan planBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &plan); err != nil {
+		t.Fatalf("Invalid response %s: %v", rec.Body, err)
+	}
+	if plan.PlanID == "" || len(plan.Groups) != 2 || plan.ToolVersion != "1.0.0" {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	if plan.Groups[0].Total != 11 || plan.Groups[1].Total != 10 {
+		t.Errorf("Group totals = %v, %v; want 11 and 10", plan.Groups[0].Total, plan.Groups[1].Total)
+	}
+	if plan.Distribution.TotalTime != 21 || plan.Distribution.Imbalance == 0 {
+		t.Errorf("Unexpected distribution %+v", plan.Distribution)
+	}
+
+	// The same request yields the same plan id
+	again := do(t, h, http.MethodPost, "/split",
+		`{"tests": ["fast_test.go", "slow_test.go", "medium_test.go", "new_test.go"], "total": 2}`)
+	if !bytes.Equal(again.Body.Bytes(), rec.Body.Bytes()) {
+		t.Errorf("Repeated request returned a different plan:\n%s\n%s", rec.Body, again.Body)
+	}
+
+	group := do(t, h, http.MethodGet, "/split/1?plan="+plan.PlanID, "")
+	if group.Code != http.StatusOK {
+		t.Fatalf("GET /split/1: status %d, body %s", group.Code, group.Body)
+	}
+	want := `{"plan_id":"` + plan.PlanID + `","group":{"tests":[{"name":"medium_test.go","source":"stats","time":6},` +
+		`{"name":"fast_test.go","source":"stats","time":4}],"total":10},"index":1}` + "\n"
+	if got := group.Body.String(); got != want {
+		t.Errorf("GET /split/1 = %s, want %s", got, want)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	h := newTestServer(t, server.WithMaxRequestBytes(64), server.WithMaxWorkers(8))
+	plan := do(t, h, http.MethodPost, "/split", `{"tests": ["a_test.go"], "total": 1}`)
+	var created planBody
+	if err := json.Unmarshal(plan.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Invalid response %s: %v", plan.Body, err)
+	}
+	id := created.PlanID
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{
+			name: "invalid json", method: http.MethodPost, target: "/split",
+			body: `{"tests": `, want: http.StatusBadRequest,
+		},
+		{
+			name: "no tests", method: http.MethodPost, target: "/split",
+			body: `{"total": 2}`, want: http.StatusBadRequest,
+		},
+		{
+			name: "invalid total", method: http.MethodPost, target: "/split",
+			body: `{"tests": ["a_test.go"], "total": 0}`, want: http.StatusBadRequest,
+		},
+		{
+			name: "total too large", method: http.MethodPost, target: "/split",
+			body: `{"tests": ["a_test.go"], "total": 50000000}`, want: http.StatusBadRequest,
+		},
+		{
+			name: "body too large", method: http.MethodPost, target: "/split",
+			body: `{"tests": ["` + strings.Repeat("a", 100) + `"], "total": 1}`,
+			want: http.StatusRequestEntityTooLarge,
+		},
+		{name: "missing plan", method: http.MethodGet, target: "/split/0", want: http.StatusBadRequest},
+		{name: "unknown plan", method: http.MethodGet, target: "/split/0?plan=nope", want: http.StatusNotFound},
+		{name: "bad index", method: http.MethodGet, target: "/split/x?plan=" + id, want: http.StatusBadRequest},
+		{name: "index out of range", method: http.MethodGet, target: "/split/1?plan=" + id, want: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodGet, target: "/split", want: http.StatusMethodNotAllowed},
+		{
+			name: "drain unknown plan", method: http.MethodPost, target: "/split/0/drain?plan=nope",
+			want: http.StatusNotFound,
+		},
+		{
+			name: "drain last group", method: http.MethodPost, target: "/split/0/drain?plan=" + id,
+			want: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, tt.method, tt.target, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("Status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusMethodNotAllowed && !strings.Contains(rec.Body.String(), `"error":`) {
+				t.Errorf("Expected a JSON error body, got %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestServer_Drain(t *testing.T) {
+	h := newTestServer(t)
+	rec := do(t, h, http.MethodPost, "/split",
+		`{"tests": ["fast_test.go", "slow_test.go", "medium_test.go", "new_test.go"], "total": 3}`)
+	var original planBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &original); err != nil {
+		t.Fatalf("Invalid response %s: %v", rec.Body, err)
+	}
+
+	// Group 2 holds fast_test.go (4s) and new_test.go (1s)
+	drain := do(t, h, http.MethodPost, "/split/2/drain?plan="+original.PlanID, "")
+	if drain.Code != http.StatusOK {
+		t.Fatalf("POST /split/2/drain: status %d, body %s", drain.Code, drain.Body)
+	}
+	var drained struct {
+		planBody
+
+		Moved []struct {
+			Name  string `json:"name"`
+			Group int    `json:"group"`
+		} `json:"moved"`
+	}
+	if err := json.Unmarshal(drain.Body.Bytes(), &drained); err != nil {
+		t.Fatalf("Invalid response %s: %v", drain.Body, err)
+	}
+
+	if got := fmt.Sprint(drained.Moved); got != "[{fast_test.go 1} {new_test.go 0}]" {
+		t.Errorf("Moved = %s", got)
+	}
+	if drained.PlanID == "" || drained.PlanID == original.PlanID || len(drained.Groups) != 2 {
+		t.Fatalf("Unexpected drained plan %+v", drained.planBody)
+	}
+	// The remaining groups keep their tests first
+	for i, want := range []string{"slow_test.go", "medium_test.go"} {
+		if got := drained.Groups[i].Tests[0].Name; got != want {
+			t.Errorf("Group %d starts with %s, want %s", i, got, want)
+		}
+	}
+	if drained.Groups[0].Total != 11 || drained.Groups[1].Total != 10 || drained.Distribution.TotalTime != 21 {
+		t.Errorf("Unexpected totals %+v", drained.planBody)
+	}
+
+	// Both plans stay available
+	if rec := do(t, h, http.MethodGet, "/split/1?plan="+drained.PlanID, ""); rec.Code != http.StatusOK {
+		t.Errorf("GET drained plan: status %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodGet, "/split/2?plan="+original.PlanID, ""); rec.Code != http.StatusOK {
+		t.Errorf("GET original plan: status %d", rec.Code)
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	h := newTestServer(t, server.WithToken("secret"))
+	body := `{"tests": ["a_test.go"], "total": 1}`
+
+	for _, tt := range []struct {
+		header []string
+		want   int
+	}{
+		{header: nil, want: http.StatusUnauthorized},
+		{header: []string{"Authorization", "Bearer wrong"}, want: http.StatusUnauthorized},
+		{header: []string{"Authorization", "secret"}, want: http.StatusUnauthorized},
+		{header: []string{"Authorization", "Bearer secret"}, want: http.StatusOK},
+	} {
+		if rec := do(t, h, http.MethodPost, "/split", body, tt.header...); rec.Code != tt.want {
+			t.Errorf("Headers %v: status %d, want %d", tt.header, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestServer_PlanCacheEviction(t *testing.T) {
+	h := newTestServer(t, server.WithPlanCacheSize(2))
+
+	ids := make([]string, 3)
+	for i, total := range []int{1, 2, 3} {
+		rec := do(t, h, http.MethodPost, "/split",
+			fmt.Sprintf(`{"tests": ["slow_test.go", "fast_test.go"], "total": %d}`, total))
+		var plan planBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &plan); err != nil {
+			t.Fatalf("Invalid response %s: %v", rec.Body, err)
+		}
+		ids[i] = plan.PlanID
+
+		if i == 1 {
+			// Using the first plan makes the second the least recently used
+			if rec := do(t, h, http.MethodGet, "/split/0?plan="+ids[0], ""); rec.Code != http.StatusOK {
+				t.Fatalf("GET first plan: status %d", rec.Code)
+			}
+		}
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusNotFound, http.StatusOK} {
+		if rec := do(t, h, http.MethodGet, "/split/0?plan="+ids[i], ""); rec.Code != want {
+			t.Errorf("Plan %d: status %d, want %d", i, rec.Code, want)
+		}
+	}
+}
+
+func TestServer_LogsRequests(t *testing.T) {
+	var logs bytes.Buffer
+	h := server.New(zerolog.New(&logs), testsplit.New(), nil).Handler()
+	do(t, h, http.MethodGet, "/split/0?plan=nope", "")
+
+	for _, want := range []string{`"method":"GET"`, `"path":"/split/0"`, `"status":404`, `"Served request"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected request log to contain %s, got %s", want, logs.String())
+		}
+	}
+}
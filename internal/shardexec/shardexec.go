@@ -0,0 +1,80 @@
+// Package shardexec renders the command running the tests of a worker from a template and runs it.
+package shardexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/prgtw/tests-helper/internal/inputcmd"
+)
+
+// stopDelay is how long a command may take to exit after SIGTERM before it is killed.
+const stopDelay = 10 * time.Second
+
+// Data is what a template can use.
+type Data struct {
+	Tests []string // Test names of the worker, in output order
+	Index int      // Worker index
+	Total int      // Number of workers
+}
+
+// ParseTemplate parses a text/template such as `go test {{join .Tests " "}}`. Besides the
+// built-in functions, join concatenates a list with a separator like strings.Join.
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("exec").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exec template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Render executes tmpl with data and returns the command line, without surrounding whitespace.
+func Render(tmpl *template.Template, data Data) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("cannot render exec template: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Runner runs c, streaming its standard output to stdout, and returns its exit status.
+// The error reports a command that could not be started or waited for.
+type Runner func(ctx context.Context, c inputcmd.Command, stdout io.Writer) (int, error)
+
+// Exec runs the executable found in PATH with the standard error of the process and no input.
+// When ctx is done, the command receives SIGTERM and is killed unless it exits within
+// stopDelay. A command killed by a signal reports 128 plus the signal number, as shells do.
+func Exec(ctx context.Context, c inputcmd.Command, stdout io.Writer) (int, error) {
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			// Platforms without SIGTERM, such as Windows, can only kill the command
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = stopDelay
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), nil
+	}
+	return exitErr.ExitCode(), nil
+}
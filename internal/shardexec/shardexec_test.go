@@ -0,0 +1,91 @@
+package shardexec_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/inputcmd"
+	"github.com/prgtw/tests-helper/internal/shardexec"
+)
+
+func TestRender(t *testing.T) {
+	tmpl, err := shardexec.ParseTemplate(`go test {{join .Tests " "}} # {{.Index}}/{{.Total}}` + "\n")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	got, err := shardexec.Render(tmpl, shardexec.Data{Tests: []string{"./a", "./b"}, Index: 1, Total: 3})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "go test ./a ./b # 1/3"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	if _, err = shardexec.ParseTemplate("go test {{join .Tests"); err == nil {
+		t.Error("Expected an unterminated action to be rejected")
+	}
+	tmpl, err = shardexec.ParseTemplate("go test {{.Files}}")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	if _, err = shardexec.Render(tmpl, shardexec.Data{}); err == nil {
+		t.Error("Expected an unknown field to fail rendering")
+	}
+}
+
+// requireShell skips tests running scripts on platforms without a POSIX shell.
+func requireShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+}
+
+func TestExec(t *testing.T) {
+	requireShell(t)
+
+	var stdout bytes.Buffer
+	code, err := shardexec.Exec(t.Context(), inputcmd.Shell("echo ok; exit 3"), &stdout)
+	if err != nil || code != 3 || stdout.String() != "ok\n" {
+		t.Errorf("Exec = %d, %v with output %q, want 3 and \"ok\\n\"", code, err, stdout.String())
+	}
+
+	if code, err = shardexec.Exec(t.Context(), inputcmd.Shell("kill -KILL $$"), io.Discard); err != nil || code != 137 {
+		t.Errorf("Exec = %d, %v, want 137 for a command killed by SIGKILL", code, err)
+	}
+
+	missing := inputcmd.Command{Name: "tests-helper-no-such-command"}
+	if _, err = shardexec.Exec(t.Context(), missing, io.Discard); err == nil {
+		t.Error("Expected a missing executable to fail")
+	}
+}
+
+func TestExec_Cancel(t *testing.T) {
+	requireShell(t)
+
+	// The script reports the SIGTERM it receives with its exit status, stopping its own child
+	script := `trap 'kill $!; exit 7' TERM; sleep 30 >/dev/null & echo ready; wait`
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	r, w := io.Pipe()
+	go func() {
+		if line, _ := bufio.NewReader(r).ReadString('\n'); line == "ready\n" {
+			cancel()
+		}
+		_, _ = io.Copy(io.Discard, r)
+	}()
+
+	code, err := shardexec.Exec(ctx, inputcmd.Shell(script), w)
+	_ = w.Close()
+	if err != nil || code != 7 {
+		t.Errorf("Exec = %d, %v, want the command to exit 7 on SIGTERM", code, err)
+	}
+}
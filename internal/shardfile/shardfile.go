@@ -0,0 +1,126 @@
+// Package shardfile writes the tests of every worker to its own file, named by a template.
+package shardfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// DefaultTemplate names the file of every worker when no template is given.
+const DefaultTemplate = "worker-{index}.txt"
+
+// ErrInvalidTemplate is returned for a template without {index}, with an unknown placeholder,
+// or naming a file outside the output directory.
+var ErrInvalidTemplate = errors.New("invalid output template")
+
+// placeholder matches {index}, {total}, and their zero-padded forms such as {index:02}.
+var placeholder = regexp.MustCompile(`\{([a-z]+)(?::0([1-9]))?\}`)
+
+// Template names the file of a worker from its index and the number of workers.
+type Template struct {
+	pattern *regexp.Regexp // Matches every name the template can produce
+	raw     string
+}
+
+// ParseTemplate parses a template such as "shard_{index}_of_{total}.txt". {index} is required,
+// {total} optional, and both accept a width to zero-pad to, e.g. {index:02} for "07".
+func ParseTemplate(raw string) (Template, error) {
+	if strings.ContainsAny(raw, `/\`) {
+		return Template{}, fmt.Errorf("%w %q: must be a file name without directories", ErrInvalidTemplate, raw)
+	}
+	if strings.ContainsAny(placeholder.ReplaceAllString(raw, ""), "{}") {
+		return Template{}, fmt.Errorf("%w %q: malformed placeholder, want e.g. {index} or {index:02}",
+			ErrInvalidTemplate, raw)
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	hasIndex, last := false, 0
+	for _, m := range placeholder.FindAllStringSubmatchIndex(raw, -1) {
+		switch name := raw[m[2]:m[3]]; name {
+		case "index":
+			hasIndex = true
+		case "total":
+		default:
+			return Template{}, fmt.Errorf("%w %q: unknown placeholder {%s}", ErrInvalidTemplate, raw, name)
+		}
+		pattern.WriteString(regexp.QuoteMeta(raw[last:m[0]]))
+		pattern.WriteString(`\d+`)
+		last = m[1]
+	}
+	if !hasIndex {
+		return Template{}, fmt.Errorf("%w %q: must contain {index}", ErrInvalidTemplate, raw)
+	}
+	pattern.WriteString(regexp.QuoteMeta(raw[last:]))
+	pattern.WriteString("$")
+	return Template{raw: raw, pattern: regexp.MustCompile(pattern.String())}, nil
+}
+
+// Name returns the file name of worker index out of total.
+func (t Template) Name(index, total int) string {
+	return placeholder.ReplaceAllStringFunc(t.raw, func(s string) string {
+		m := placeholder.FindStringSubmatch(s)
+		value := index
+		if m[1] == "total" {
+			value = total
+		}
+		width, _ := strconv.Atoi(m[2])
+		return fmt.Sprintf("%0*d", width, value)
+	})
+}
+
+// Matches reports whether name could have been produced by the template, for any index and total.
+func (t Template) Matches(name string) bool {
+	return t.pattern.MatchString(name)
+}
+
+// String returns the template as written.
+func (t Template) String() string {
+	return t.raw
+}
+
+// WriteAll writes the test names of every worker, one per line, to the file named by t in dir,
+// replacing existing files atomically. With clean, other files in dir matching the template,
+// such as those of a previous run with more workers, are removed. It returns the written paths.
+func WriteAll(dir string, t Template, workers [][]junit.Test, clean bool) ([]string, error) {
+	paths := make([]string, 0, len(workers))
+	written := make(map[string]bool, len(workers))
+	for i, tests := range workers {
+		var b strings.Builder
+		for _, test := range tests {
+			b.WriteString(test.Name)
+			b.WriteByte('\n')
+		}
+		name := t.Name(i, len(workers))
+		path := filepath.Join(dir, name)
+		if err := fileutil.WriteAtomic(path, []byte(b.String())); err != nil {
+			return nil, fmt.Errorf("cannot write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+		written[name] = true
+	}
+	if !clean {
+		return paths, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot clean %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !written[entry.Name()] && t.Matches(entry.Name()) {
+			if err = os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return nil, fmt.Errorf("cannot remove stale file: %w", err)
+			}
+		}
+	}
+	return paths, nil
+}
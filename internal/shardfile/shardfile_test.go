@@ -0,0 +1,103 @@
+package shardfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/shardfile"
+)
+
+func TestTemplate_Name(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+		index    int
+		total    int
+	}{
+		{template: "shard_{index}_of_{total}.txt", index: 7, total: 8, want: "shard_7_of_8.txt"},
+		{template: "shard_{index:02}.txt", index: 7, total: 8, want: "shard_07.txt"},
+		{template: "shard_{index:03}-{total:02}", index: 12, total: 16, want: "shard_012-16"},
+		{template: "{index:02}", index: 123, total: 200, want: "123"},
+		{template: shardfile.DefaultTemplate, index: 0, total: 1, want: "worker-0.txt"},
+	}
+	for _, tt := range tests {
+		tmpl, err := shardfile.ParseTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q) failed: %v", tt.template, err)
+		}
+		if got := tmpl.Name(tt.index, tt.total); got != tt.want {
+			t.Errorf("%q.Name(%d, %d) = %q, want %q", tt.template, tt.index, tt.total, got, tt.want)
+		}
+		if !tmpl.Matches(tt.want) {
+			t.Errorf("%q does not match its own name %q", tt.template, tt.want)
+		}
+	}
+}
+
+func TestParseTemplate_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		"shard_{total}.txt", "shard.txt", "{worker}-{index}", "{index:2}", "{index", "dir/{index}", `..\{index}`,
+	} {
+		if _, err := shardfile.ParseTemplate(raw); !errors.Is(err, shardfile.ErrInvalidTemplate) {
+			t.Errorf("ParseTemplate(%q) error = %v, want ErrInvalidTemplate", raw, err)
+		}
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	dir := t.TempDir()
+	tmpl, err := shardfile.ParseTemplate("shard_{index}_of_{total}.txt")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	// Files of a previous run with more workers, and files the template never produces
+	for _, name := range []string{"shard_1_of_3.txt", "shard_2_of_3.txt", "shard_x_of_3.txt", "notes.txt"} {
+		if err = os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	workers := [][]junit.Test{{{Name: "a_test.go"}, {Name: "b_test.go"}}, {}}
+
+	paths, err := shardfile.WriteAll(dir, tmpl, workers, false)
+	if err != nil {
+		t.Fatalf("WriteAll failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "shard_0_of_2.txt"), filepath.Join(dir, "shard_1_of_2.txt")}
+	if !slices.Equal(paths, want) {
+		t.Errorf("Paths = %v, want %v", paths, want)
+	}
+	if data, _ := os.ReadFile(paths[0]); string(data) != "a_test.go\nb_test.go\n" {
+		t.Errorf("Worker 0 file = %q", data)
+	}
+	if data, _ := os.ReadFile(paths[1]); len(data) != 0 {
+		t.Errorf("Worker 1 file = %q, want empty", data)
+	}
+	if names := dirNames(t, dir); len(names) != 6 {
+		t.Errorf("Files = %v, want the stale ones kept without clean", names)
+	}
+
+	if _, err = shardfile.WriteAll(dir, tmpl, workers, true); err != nil {
+		t.Fatalf("WriteAll with clean failed: %v", err)
+	}
+	wantNames := []string{"notes.txt", "shard_0_of_2.txt", "shard_1_of_2.txt", "shard_x_of_3.txt"}
+	if names := dirNames(t, dir); !slices.Equal(names, wantNames) {
+		t.Errorf("Files after clean = %v, want %v", names, wantNames)
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
@@ -0,0 +1,76 @@
+// Package shardindex gives runners without a worker index one: derived from a stable hash of
+// their name, or claimed cooperatively through lock files in a shared directory.
+package shardindex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrAllClaimed is returned when every index is already claimed by another runner.
+var ErrAllClaimed = errors.New("every worker index is claimed")
+
+// FromHash returns the index of the runner named key among total workers: the 64-bit FNV-1a hash
+// of key modulo total. It is stable across runs, platforms, and versions, but two keys may share
+// an index; see Claim. total must be at least 1.
+func FromHash(key string, total int) int {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum64() % uint64(total)) //nolint:gosec // total is positive, the result fits
+}
+
+// ClaimPath returns the lock file claiming index in dir.
+func ClaimPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("index-%d.claim", index))
+}
+
+// Claim claims the first free index among total, trying start first and wrapping around, by
+// creating its lock file in dir exclusively, with owner as content. Creation is atomic, so
+// concurrent claimers, even on other hosts sharing dir, never get the same index. An index
+// already claimed by owner is returned again, so a restarted runner keeps its index.
+// Claims are never released: dir is meant to be fresh for every run.
+// It fails with ErrAllClaimed when every index is taken.
+func Claim(dir string, total, start int, owner string) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gosec // shared with other runners
+		return 0, fmt.Errorf("cannot create claim directory: %w", err)
+	}
+	for i := range total {
+		index := (start + i) % total
+		claimed, err := claim(ClaimPath(dir, index), owner)
+		if err != nil {
+			return 0, err
+		}
+		if claimed {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: all %d lock files in %s exist", ErrAllClaimed, total, dir)
+}
+
+// claim creates the lock file at path for owner, reporting false when another owner holds it.
+func claim(path, owner string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644) //nolint:gosec // shared lock file
+	if errors.Is(err, fs.ErrExist) {
+		held, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return false, fmt.Errorf("cannot read claim: %w", readErr)
+		}
+		return bytes.Equal(held, []byte(owner)), nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot claim index: %w", err)
+	}
+	if _, err = f.WriteString(owner); err != nil {
+		_ = f.Close()
+		return false, fmt.Errorf("cannot write claim: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return false, fmt.Errorf("cannot write claim: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,94 @@
+package shardindex_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/shardindex"
+)
+
+func TestFromHash(t *testing.T) {
+	// Pinned: a changed hash would reshuffle every runner of a pool
+	for key, want := range map[string]int{"runner-a": 1, "runner-b": 0, "ip-10-0-0-12": 2, "build-pod-7f9c": 1} {
+		if got := shardindex.FromHash(key, 8); got != want {
+			t.Errorf("FromHash(%q, 8) = %d, want %d", key, got, want)
+		}
+	}
+
+	seen := make(map[int]bool)
+	for i := range 200 {
+		index := shardindex.FromHash(fmt.Sprintf("runner-%d", i), 4)
+		if index < 0 || index >= 4 {
+			t.Fatalf("FromHash out of range: %d", index)
+		}
+		seen[index] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("200 runners only reached indexes %v of 4", seen)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	dir := t.TempDir()
+
+	// Starting at the preferred index, then wrapping around
+	for _, tt := range []struct {
+		owner string
+		start int
+		want  int
+	}{
+		{owner: "a", start: 2, want: 2},
+		{owner: "b", start: 2, want: 0},
+		{owner: "c", start: 1, want: 1},
+		{owner: "a", start: 0, want: 2}, // Restarted runner keeps its index
+	} {
+		got, err := shardindex.Claim(dir, 3, tt.start, tt.owner)
+		if err != nil || got != tt.want {
+			t.Errorf("Claim(%s, start %d) = %d, %v; want %d", tt.owner, tt.start, got, err, tt.want)
+		}
+	}
+
+	if _, err := shardindex.Claim(dir, 3, 0, "d"); !errors.Is(err, shardindex.ErrAllClaimed) {
+		t.Errorf("Claim with every index taken: error = %v, want ErrAllClaimed", err)
+	}
+	if data, _ := os.ReadFile(shardindex.ClaimPath(dir, 1)); string(data) != "c" {
+		t.Errorf("Lock file of index 1 = %q, want its owner", data)
+	}
+}
+
+func TestClaim_Concurrent(t *testing.T) {
+	const total = 16
+	dir := t.TempDir()
+
+	indexes := make([]int, total+4)
+	errs := make([]error, total+4)
+	var wg sync.WaitGroup
+	for i := range indexes {
+		wg.Go(func() {
+			// Every claimer prefers the same index, the worst case for contention
+			indexes[i], errs[i] = shardindex.Claim(dir, total, 0, fmt.Sprintf("runner-%d", i))
+		})
+	}
+	wg.Wait()
+
+	var claimed []int
+	rejected := 0
+	for i, err := range errs {
+		switch {
+		case errors.Is(err, shardindex.ErrAllClaimed):
+			rejected++
+		case err != nil:
+			t.Fatalf("Claimer %d failed: %v", i, err)
+		default:
+			claimed = append(claimed, indexes[i])
+		}
+	}
+	slices.Sort(claimed)
+	if len(slices.Compact(claimed)) != total || rejected != 4 {
+		t.Errorf("Claimed %v with %d rejected; want every index exactly once and 4 rejected", claimed, rejected)
+	}
+}
@@ -0,0 +1,55 @@
+package splitter
+
+import (
+	"slices"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// Coverage tells which tests of a list have historical data and which stats no test uses.
+type Coverage struct {
+	Tests   int      // Tests in the list
+	Missing []string // Tests that got the default time, sorted
+	Stale   []string // Stats keys matching no test of the list, sorted
+}
+
+// Coverage compares tests, as returned by ReadTests, with the times they were read with.
+// A test is missing when it got the default time, including after an ambiguous suffix match;
+// tests with an inline time are not. A stats key is stale when no test matches it the way
+// ReadTests matches them, so an inline time does not make the key of its test stale.
+func (s *Splitter) Coverage(tests []junit.Test, times map[string]float64) Coverage {
+	m := newMatcher(times, s.matchMode)
+	used := make(map[string]bool, len(tests))
+	coverage := Coverage{Tests: len(tests)}
+	for _, test := range tests {
+		if test.Source == junit.SourceDefault {
+			coverage.Missing = append(coverage.Missing, test.Name)
+		}
+		if _, statsKey, _ := m.lookup(test.Key); statsKey != "" {
+			used[statsKey] = true
+		}
+	}
+	for key := range times {
+		if !used[key] {
+			coverage.Stale = append(coverage.Stale, key)
+		}
+	}
+	slices.Sort(coverage.Missing)
+	slices.Sort(coverage.Stale)
+	return coverage
+}
+
+// AssignCases sets the Cases of every test, as returned by ReadTests, to the number of test
+// cases recorded for it in cases, keyed like times and matched the same way, see
+// junit.CaseSource. It returns the number of tests given a count.
+func (s *Splitter) AssignCases(tests []junit.Test, cases map[string]float64) int {
+	m := newMatcher(cases, s.matchMode)
+	assigned := 0
+	for i := range tests {
+		if n, statsKey, _ := m.lookup(tests[i].Key); statsKey != "" && n >= 1 {
+			tests[i].Cases = int(n)
+			assigned++
+		}
+	}
+	return assigned
+}
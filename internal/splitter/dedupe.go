@@ -0,0 +1,53 @@
+package splitter
+
+import "fmt"
+
+// DedupeMode controls how tests listed more than once in the input are handled.
+type DedupeMode string
+
+const (
+	DedupeKeep  DedupeMode = "keep"  // Every occurrence is split as a separate test
+	DedupeFirst DedupeMode = "first" // Only the first occurrence of a normalized name is kept
+)
+
+// ParseDedupeMode validates a dedupe mode given on the command line.
+func ParseDedupeMode(s string) (DedupeMode, error) {
+	switch mode := DedupeMode(s); mode {
+	case DedupeKeep, DedupeFirst:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown dedupe mode %q (must be %q or %q)", s, DedupeKeep, DedupeFirst)
+	}
+}
+
+// dedupe applies the configured dedupe mode to entries, filtering them in place.
+// Names are compared after normalization, so "./a_test.go" repeats "a_test.go".
+func (s *Splitter) dedupe(entries []entry) []entry {
+	if s.dedupeMode != DedupeFirst {
+		return entries
+	}
+
+	seen := make(map[string]int, len(entries))
+	kept := entries[:0]
+	for _, e := range entries {
+		key := s.normalizer.Key(e.name)
+		if first, ok := seen[key]; ok {
+			if ev := s.logger.Debug(); ev.Enabled() {
+				ev.Str("test", e.name).
+					Int("line", e.line).
+					Int("first_line", first).
+					Msg("Skipping duplicate test")
+			}
+			continue
+		}
+		seen[key] = e.line
+		kept = append(kept, e)
+	}
+
+	if dropped := len(entries) - len(kept); dropped > 0 {
+		s.logger.Warn().
+			Int("count", dropped).
+			Msg("Skipped duplicate tests in the input")
+	}
+	return kept
+}
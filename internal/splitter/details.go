@@ -0,0 +1,121 @@
+package splitter
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// DefaultDetailsTop is the number of tests PrintWorkerDetails lists before truncating.
+const DefaultDetailsTop = 50
+
+// WorkerDetails lists the tests of a worker, longest first with a running total, to show where
+// the bulk of its time sits. The zero value lists them at debug level only.
+type WorkerDetails struct {
+	Verbose bool // List the tests at info level and add them to the distribution
+	Top     int  // Number of tests listed in the log, 0 for all
+}
+
+// WithWorkerDetails sets how PrintWorkerDetails lists the tests of the selected worker and
+// whether StatsReporter.Details adds them to the distribution. Defaults to the zero WorkerDetails.
+func WithWorkerDetails(details WorkerDetails) StatsReporterOption {
+	return func(r *StatsReporter) {
+		r.details = details
+	}
+}
+
+// Details returns a copy of stats in which every worker lists all of its tests in workers,
+// matched by index, longest first with a running total. It returns stats unchanged unless the
+// details are verbose.
+func (r *StatsReporter) Details(stats worker.Distribution, workers []worker.Worker) worker.Distribution {
+	if !r.details.Verbose {
+		return stats
+	}
+	detailed := make([]worker.Stats, len(stats.Workers))
+	for i, ws := range stats.Workers {
+		if ws.Index >= 0 && ws.Index < len(workers) {
+			ws.Tests = WorkerTests(workers[ws.Index])
+		}
+		detailed[i] = ws
+	}
+	stats.Workers = detailed
+	return stats
+}
+
+// WorkerTests returns the tests of w longest first, ties broken by name, each with the total time
+// of the tests up to and including it. Non-finite times count as zero.
+func WorkerTests(w worker.Worker) []worker.TestTime {
+	tests := longestFirst(w.Tests)
+	times := make([]worker.TestTime, len(tests))
+	cumulative := 0.0
+	for i, test := range tests {
+		cumulative += finite(test.Time)
+		times[i] = worker.TestTime{Name: test.Name, Time: finite(test.Time), Cumulative: cumulative}
+	}
+	return times
+}
+
+// longestFirst returns a copy of tests sorted by descending time, ties broken by name.
+func longestFirst(tests []junit.Test) []junit.Test {
+	sorted := slices.Clone(tests)
+	slices.SortStableFunc(sorted, func(a, b junit.Test) int {
+		return cmp.Or(cmp.Compare(finite(b.Time), finite(a.Time)), strings.Compare(a.Name, b.Name))
+	})
+	return sorted
+}
+
+// printTests logs the tests of w longest first with their running total, at info level when the
+// details are verbose and at debug level otherwise, cut to the top tests with a closing note.
+func (r *StatsReporter) printTests(index int, w *worker.Worker) {
+	level := zerolog.DebugLevel
+	if r.details.Verbose {
+		level = zerolog.InfoLevel
+	}
+	if r.logger.GetLevel() > level || len(w.Tests) == 0 {
+		return
+	}
+
+	tests := longestFirst(w.Tests)
+	shown := tests
+	if r.details.Top > 0 && len(tests) > r.details.Top {
+		shown = tests[:r.details.Top]
+	}
+	total := finite(w.Total)
+	cumulative := 0.0
+	for i, test := range shown {
+		cumulative += finite(test.Time)
+		event := r.logger.WithLevel(level).
+			Int("worker", index).
+			Int("rank", i+1).
+			Str("test", test.Name).
+			Float64("time", test.Time).
+			Float64("cumulative", cumulative).
+			Str("source", string(test.Source))
+		if test.Resource != "" {
+			event = event.Str("resource", test.Resource)
+		}
+		event.Msgf("Assigned test %d. %s %s, cumulative %s (%.1f%%)",
+			i+1, test.Name, r.dur(test.Time), r.dur(cumulative), share(cumulative, total))
+	}
+	if len(shown) < len(tests) {
+		r.logger.WithLevel(level).
+			Int("worker", index).
+			Int("listed", len(shown)).
+			Int("test_count", len(tests)).
+			Msgf("Listed the %d longest of %d tests, %.1f%% of the worker's time",
+				len(shown), len(tests), share(cumulative, total))
+	}
+}
+
+// share returns part as a percentage of total, or 0 when total is not positive.
+func share(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return part / total * 100
+}
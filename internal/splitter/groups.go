@@ -0,0 +1,119 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// InputFormat selects how ReadTests parses the test list.
+type InputFormat string
+
+const (
+	InputLines  InputFormat = "lines"  // One test per line
+	InputGroups InputFormat = "groups" // One group per line: its name, a tab, and comma-separated members
+)
+
+// ErrGroupFormat is returned by ReadTests for a line of a grouped test list that is not a group
+// name, a tab, and at least one member.
+var ErrGroupFormat = errors.New("malformed group")
+
+// ParseInputFormat validates an input format given on the command line.
+func ParseInputFormat(s string) (InputFormat, error) {
+	switch f := InputFormat(s); f {
+	case InputLines, InputGroups:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown input format %q (must be %q or %q)", s, InputLines, InputGroups)
+	}
+}
+
+// WithInputFormat sets how ReadTests parses the test list. With InputGroups, every line is a
+// group of tests that must stay on the same worker, such as a Bazel target: its members are
+// looked up like tests and the group takes the sum of their times, see junit.Group.
+// Defaults to InputLines.
+func WithInputFormat(f InputFormat) Option {
+	return func(s *Splitter) {
+		s.inputFormat = f
+	}
+}
+
+// ExpandGroups returns tests with every group replaced by its members, in order. Tests that are
+// not groups are kept as they are.
+func ExpandGroups(tests []junit.Test) []junit.Test {
+	out := make([]junit.Test, 0, len(tests))
+	for _, test := range tests {
+		if test.Group == nil {
+			out = append(out, test)
+			continue
+		}
+		out = append(out, test.Group.Members...)
+	}
+	return out
+}
+
+// checkLine checks a trimmed input line, see checkName. With InputGroups it also returns the
+// members of the group, checked the same way and joined by commas.
+func (s *Splitter) checkLine(line []byte, sp *span) ([]byte, []byte, error) {
+	if s.inputFormat != InputGroups {
+		name, err := s.checkName(line, sp)
+		return name, nil, err
+	}
+
+	if s.inlineTimes {
+		var err error
+		if line, sp.time, sp.hasTime, err = splitInlineTime(line); err != nil {
+			return nil, nil, err
+		}
+	}
+	name, list, found := bytes.Cut(line, []byte{'\t'})
+	if !found {
+		return nil, nil, fmt.Errorf("%w: expected a group name, a tab, and comma-separated members", ErrGroupFormat)
+	}
+	name, err := s.checkChars(bytes.TrimSpace(name), sp.line)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var members []byte
+	for member := range bytes.SplitSeq(list, []byte{','}) {
+		if member, err = s.checkChars(bytes.TrimSpace(member), sp.line); err != nil {
+			return nil, nil, err
+		}
+		if len(member) == 0 {
+			continue
+		}
+		if len(members) > 0 {
+			members = append(members, ',')
+		}
+		members = append(members, member...)
+	}
+	if len(members) == 0 {
+		return nil, nil, fmt.Errorf("%w: group %q has no members", ErrGroupFormat, name)
+	}
+	return name, members, nil
+}
+
+// resolveGroup assigns times to the members of a group entry like resolveTime does to tests. The
+// group takes the sum of their times unless it has an inline time, and SourceDefault when any
+// member has no historical data, since its time is then partly a guess.
+func (s *Splitter) resolveGroup(e entry, m *matcher) junit.Test {
+	group := junit.Test{Name: e.name, Key: s.normalizer.Key(e.name), Source: junit.SourceStats}
+	names := strings.Split(e.members, ",")
+	group.Group = &junit.Group{Members: make([]junit.Test, len(names))}
+	for i, name := range names {
+		member := s.resolveTime(entry{name: name, line: e.line}, m)
+		group.Group.Members[i] = member
+		group.Time += member.Time
+		if member.Source == junit.SourceDefault {
+			group.Source = junit.SourceDefault
+		}
+	}
+	if e.hasTime {
+		group.Time, group.Source = e.time, junit.SourceInline
+	}
+	return group
+}
@@ -0,0 +1,241 @@
+package splitter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// ErrNoTests is returned by ReadTests when the input contains no test names.
+var ErrNoTests = errors.New("no tests provided")
+
+// utf8BOM is the byte order mark some tools write at the start of UTF-8 files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF} //nolint:gochecknoglobals // constant byte sequence
+
+// ReadTests reads test names from a reader and assigns times based on historical data.
+//
+// Empty lines and lines starting with "#" are ignored. With inline times enabled,
+// a trailing number on a line overrides the historical time for that test.
+// Names containing control characters fail with ErrControlChars or are stripped, see
+// WithControlChars, and names longer than the maximum fail with ErrNameTooLong.
+// Tests listed more than once are handled according to the dedupe mode. With InputGroups, every
+// line is a group instead, see WithInputFormat.
+func (s *Splitter) ReadTests(r io.Reader, times map[string]float64) ([]junit.Test, error) {
+	entries, err := s.readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	entries = s.dedupe(entries)
+
+	if len(entries) == 0 {
+		return nil, ErrNoTests
+	}
+
+	m := newMatcher(times, s.matchMode)
+	tests := make([]junit.Test, 0, len(entries))
+	for _, e := range entries {
+		if e.members != "" {
+			tests = append(tests, s.resolveGroup(e, m))
+			continue
+		}
+		tests = append(tests, s.resolveTime(e, m))
+	}
+
+	s.logger.Info().
+		Int("count", len(tests)).
+		Msg("Read tests from input")
+
+	return tests, nil
+}
+
+// resolveTime assigns a time to an input entry from its override, the historical data, or the default.
+func (s *Splitter) resolveTime(e entry, m *matcher) junit.Test {
+	key := s.normalizer.Key(e.name)
+	if e.hasTime {
+		if ev := s.logger.Debug(); ev.Enabled() {
+			ev.Str("test", e.name).
+				Float64("time", e.time).
+				Int("line", e.line).
+				Msg("Using inline time override")
+		}
+		return junit.Test{Name: e.name, Key: key, Time: e.time, Source: junit.SourceInline}
+	}
+
+	time, statsKey, candidates := m.lookup(key)
+	switch {
+	case len(candidates) > 0:
+		s.logger.Warn().
+			Str("test", e.name).
+			Strs("candidates", candidates).
+			Msg("Ambiguous suffix match, using default time")
+		return junit.Test{Name: e.name, Key: key, Time: s.defaultTime, Source: junit.SourceDefault}
+	case statsKey == "":
+		time = s.defaultTime
+		if ev := s.logger.Debug(); ev.Enabled() {
+			ev.Str("test", e.name).
+				Float64("time", time).
+				Msg("No historical data, using default time")
+		}
+		return junit.Test{Name: e.name, Key: key, Time: time, Source: junit.SourceDefault}
+	case time == 0:
+		// Recorded as zero: keep it cheap, but distinguishable for sorting
+		if ev := s.logger.Debug(); ev.Enabled() {
+			ev.Str("test", e.name).
+				Float64("time", s.zeroTime).
+				Msg("Recorded as zero seconds, using the zero time")
+		}
+		return junit.Test{Name: e.name, Key: key, Time: s.zeroTime, Source: junit.SourceClamped}
+	}
+
+	if ev := s.logger.Debug(); statsKey != key && ev.Enabled() {
+		ev.Str("test", e.name).
+			Str("stats_key", statsKey).
+			Msg("Matched test by suffix")
+	}
+	return junit.Test{Name: e.name, Key: key, Time: time, Source: junit.SourceStats}
+}
+
+// entry is a single test or group read from the input.
+type entry struct {
+	name    string
+	members string  // comma-separated members of a group, empty for a test
+	time    float64 // inline override, valid when hasTime is set
+	line    int
+	hasTime bool
+}
+
+// span locates an entry's name, followed by its members, within the arena built by readEntries.
+type span struct {
+	time       float64
+	start, end int
+	membersEnd int
+	line       int
+	hasTime    bool
+}
+
+// readEntries reads non-empty, non-comment, trimmed lines from r.
+//
+// All names are copied into a single arena and returned as substrings of it,
+// so reading does not allocate per line.
+func (s *Splitter) readEntries(r io.Reader) ([]entry, error) {
+	countHint, sizeHint := s.sizeHints(r)
+
+	var arena strings.Builder
+	arena.Grow(sizeHint)
+	spans := make([]span, 0, countHint)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, s.maxLineBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		if line == 1 {
+			raw = bytes.TrimPrefix(raw, utf8BOM)
+		}
+
+		// TrimSpace also removes the \r left over from CRLF line endings
+		name := bytes.TrimSpace(raw)
+		if len(name) == 0 || name[0] == '#' {
+			continue
+		}
+
+		sp := span{line: line}
+		name, members, err := s.checkLine(name, &sp)
+		if err != nil {
+			return nil, &junit.ParseError{Err: err, File: inputName(r), Line: line}
+		}
+		if len(name) == 0 {
+			continue
+		}
+
+		sp.start = arena.Len()
+		arena.Write(name)
+		sp.end = arena.Len()
+		arena.Write(members)
+		sp.membersEnd = arena.Len()
+		spans = append(spans, sp)
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, &junit.ParseError{
+				Err:  fmt.Errorf("line is too long (limit is %d bytes): %w", s.maxLineBytes, err),
+				File: inputName(r),
+				Line: line + 1,
+			}
+		}
+		return nil, fmt.Errorf("error reading tests: %w", err)
+	}
+
+	all := arena.String()
+	entries := make([]entry, len(spans))
+	for i, sp := range spans {
+		entries[i] = entry{
+			name:    all[sp.start:sp.end],
+			members: all[sp.end:sp.membersEnd],
+			time:    sp.time,
+			line:    sp.line,
+			hasTime: sp.hasTime,
+		}
+	}
+	return entries, nil
+}
+
+// inputName returns the file name of r, or "" when r is not a named file.
+func inputName(r io.Reader) string {
+	if f, ok := r.(interface{ Name() string }); ok {
+		return f.Name()
+	}
+	return ""
+}
+
+// splitInlineTime separates a trailing time from a line. Lines whose last token is
+// not a number are returned unchanged, so names containing spaces keep working. A number
+// out of range is rejected like an infinite one rather than taken as part of the name.
+func splitInlineTime(line []byte) ([]byte, float64, bool, error) {
+	idx := bytes.LastIndexAny(line, " \t")
+	if idx < 0 {
+		return line, 0, false, nil
+	}
+
+	// Out of range, ParseFloat returns an infinity or zero, both rejected below
+	value, err := strconv.ParseFloat(string(line[idx+1:]), 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return line, 0, false, nil //nolint:nilerr // not a time, the whole line is the name
+	}
+	if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, 0, false, fmt.Errorf("inline time must be a positive number, got %q", line[idx+1:])
+	}
+
+	return bytes.TrimSpace(line[:idx]), value, true, nil
+}
+
+// sizeHints estimates the number of names and bytes to expect from r,
+// using the configured hint and, for regular files, the file size.
+func (s *Splitter) sizeHints(r io.Reader) (int, int) {
+	countHint, sizeHint := s.sizeHint, 0
+
+	if f, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			sizeHint = int(info.Size())
+			if countHint == 0 {
+				countHint = sizeHint / estimatedBytesPerLine
+			}
+		}
+	}
+
+	if sizeHint == 0 {
+		sizeHint = countHint * estimatedBytesPerLine
+	}
+	return countHint, sizeHint
+}
@@ -0,0 +1,96 @@
+package splitter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MatchMode controls how test names are matched against stats keys.
+type MatchMode string
+
+const (
+	MatchExact  MatchMode = "exact"  // Only identical keys match
+	MatchSuffix MatchMode = "suffix" // Fall back to a unique stats key ending with "/"+name
+)
+
+// ParseMatchMode validates a match mode given on the command line.
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch mode := MatchMode(s); mode {
+	case MatchExact, MatchSuffix:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown match mode %q (must be %q or %q)", s, MatchExact, MatchSuffix)
+	}
+}
+
+// matcher looks up test times by key, optionally falling back to suffix matches.
+type matcher struct {
+	times map[string]float64
+	// byBase maps the last path segment of every stats key to the keys ending with it.
+	byBase map[string][]string
+}
+
+// newMatcher builds a matcher for times. The suffix index is only built in suffix mode.
+func newMatcher(times map[string]float64, mode MatchMode) *matcher {
+	m := &matcher{times: times}
+	if mode != MatchSuffix {
+		return m
+	}
+
+	m.byBase = make(map[string][]string, len(times))
+	for key := range times {
+		base := lastSegment(key)
+		m.byBase[base] = append(m.byBase[base], key)
+	}
+	return m
+}
+
+// lookup returns the time recorded for key and the stats key it came from.
+// The returned candidates are non-empty when a suffix match was ambiguous and therefore rejected.
+func (m *matcher) lookup(key string) (float64, string, []string) {
+	if time, ok := m.times[key]; ok {
+		return time, key, nil
+	}
+	if m.byBase == nil {
+		return 0, "", nil
+	}
+
+	candidates := m.byBase[lastSegment(key)]
+	match := -1
+	for i, statsKey := range candidates {
+		if !hasPathSuffix(statsKey, key) {
+			continue
+		}
+		if match >= 0 {
+			return 0, "", ambiguousMatches(candidates, key)
+		}
+		match = i
+	}
+	if match < 0 {
+		return 0, "", nil
+	}
+	return m.times[candidates[match]], candidates[match], nil
+}
+
+// ambiguousMatches returns the sorted candidates that end with "/"+key.
+func ambiguousMatches(candidates []string, key string) []string {
+	var matches []string
+	for _, statsKey := range candidates {
+		if hasPathSuffix(statsKey, key) {
+			matches = append(matches, statsKey)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// lastSegment returns the part of name after its last "/".
+func lastSegment(name string) string {
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
+
+// hasPathSuffix reports whether key ends with "/"+name.
+func hasPathSuffix(key, name string) bool {
+	return len(key) > len(name) && key[len(key)-len(name)-1] == '/' && strings.HasSuffix(key, name)
+}
@@ -0,0 +1,164 @@
+package splitter_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/splitter"
+)
+
+func TestParseMatchMode(t *testing.T) {
+	for _, mode := range []splitter.MatchMode{splitter.MatchExact, splitter.MatchSuffix} {
+		got, err := splitter.ParseMatchMode(string(mode))
+		if err != nil || got != mode {
+			t.Errorf("ParseMatchMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := splitter.ParseMatchMode("prefix"); err == nil {
+		t.Error("Expected error for unknown match mode, got nil")
+	}
+}
+
+func TestSplitter_Coverage(t *testing.T) {
+	times := map[string]float64{
+		"/workspace/pkg/a_test.go":    5,
+		"/workspace/pkg/gone_test.go": 2,
+		"pkg/b_test.go":               1,
+		"pkg/c_test.go":               4,
+	}
+	input := "pkg/new_test.go\npkg/a_test.go\npkg/b_test.go\npkg/c_test.go 3\nother_test.go\n"
+	s := splitter.NewSplitter(zerolog.Nop(),
+		splitter.WithMatchMode(splitter.MatchSuffix), splitter.WithInlineTimes(true))
+	tests, err := s.ReadTests(strings.NewReader(input), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+
+	coverage := s.Coverage(tests, times)
+	if coverage.Tests != 5 {
+		t.Errorf("Tests = %d, want 5", coverage.Tests)
+	}
+	// The inline time of pkg/c_test.go counts as covered and keeps its key from being stale;
+	// the suffix match of pkg/a_test.go uses the full key.
+	if got := fmt.Sprint(coverage.Missing); got != "[other_test.go pkg/new_test.go]" {
+		t.Errorf("Missing = %s, want the sorted tests without stats", got)
+	}
+	if got := fmt.Sprint(coverage.Stale); got != "[/workspace/pkg/gone_test.go]" {
+		t.Errorf("Stale = %s, want the key no test matched", got)
+	}
+}
+
+func TestSplitter_AssignCases(t *testing.T) {
+	times := map[string]float64{"/workspace/pkg/a_test.go": 5, "pkg/b_test.go": 1, "pkg/c_test.go": 2}
+	cases := map[string]float64{"/workspace/pkg/a_test.go": 4, "pkg/b_test.go": 12}
+	s := splitter.NewSplitter(zerolog.Nop(), splitter.WithMatchMode(splitter.MatchSuffix))
+	tests, err := s.ReadTests(strings.NewReader("pkg/a_test.go\npkg/b_test.go\npkg/c_test.go\n"), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+
+	if got := s.AssignCases(tests, cases); got != 2 {
+		t.Errorf("AssignCases = %d, want 2", got)
+	}
+	// Counts are matched like times; a test without one keeps 0
+	for i, want := range []int{4, 12, 0} {
+		if tests[i].Cases != want {
+			t.Errorf("%s: Cases = %d, want %d", tests[i].Name, tests[i].Cases, want)
+		}
+	}
+}
+
+func TestSplitter_ReadTestsSuffixMatch(t *testing.T) {
+	times := map[string]float64{
+		"/workspace/app/pkg/db/connection_test.go": 5.0,
+		"/runner/a/pkg/api/handler_test.go":        3.0,
+		"/runner/b/pkg/api/handler_test.go":        4.0,
+		"/mnt/src/pkg/util/zero_test.go":           0,
+		"pkg/exact_test.go":                        2.0,
+		"/workspace/app/pkg/exact_test.go":         9.0,
+	}
+	input := strings.Join([]string{
+		"pkg/db/connection_test.go",
+		"pkg/api/handler_test.go",
+		"pkg/util/zero_test.go",
+		"pkg/exact_test.go",
+		"connection_test.go",
+		"db/connection_test.go",
+		"other/connection_test.go",
+	}, "\n")
+
+	t.Run("suffix mode", func(t *testing.T) {
+		var logs bytes.Buffer
+		s := splitter.NewSplitter(zerolog.New(&logs), splitter.WithMatchMode(splitter.MatchSuffix))
+		tests, err := s.ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+
+		want := []struct {
+			time   float64
+			source junit.Source
+		}{
+			{5.0, junit.SourceStats},                        // unique suffix
+			{splitter.DefaultTestTime, junit.SourceDefault}, // ambiguous between two runners
+			{splitter.DefaultZeroTime, junit.SourceClamped}, // suffix match recorded as zero
+			{2.0, junit.SourceStats},                        // exact key wins over a suffix match
+			{5.0, junit.SourceStats},                        // file name alone, still unique
+			{5.0, junit.SourceStats},
+			{splitter.DefaultTestTime, junit.SourceDefault}, // suffix must align with a segment
+		}
+		for i, w := range want {
+			if !floatEqual(tests[i].Time, w.time, 1e-9) || tests[i].Source != w.source {
+				t.Errorf("%s: got %.3f (%s), want %.3f (%s)",
+					tests[i].Name, tests[i].Time, tests[i].Source, w.time, w.source)
+			}
+		}
+
+		if !strings.Contains(logs.String(), "Ambiguous suffix match") ||
+			!strings.Contains(logs.String(), "/runner/a/pkg/api/handler_test.go") {
+			t.Errorf("Expected ambiguous match to be logged with candidates, got:\n%s", logs.String())
+		}
+	})
+
+	t.Run("exact mode", func(t *testing.T) {
+		s := splitter.NewSplitter(zerolog.New(os.Stderr).Level(zerolog.Disabled))
+		tests, err := s.ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		for _, test := range tests {
+			wantSource := junit.SourceDefault
+			if test.Name == "pkg/exact_test.go" {
+				wantSource = junit.SourceStats
+			}
+			if test.Source != wantSource {
+				t.Errorf("%s: got source %s, want %s", test.Name, test.Source, wantSource)
+			}
+		}
+	})
+}
+
+func BenchmarkReadTestsSuffixMatch(b *testing.B) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	s := splitter.NewSplitter(logger, splitter.WithMatchMode(splitter.MatchSuffix))
+
+	const size = 100_000
+	input := generateTestList(size)
+	times := make(map[string]float64, size)
+	for i := range size {
+		times[fmt.Sprintf("/workspace/app/pkg/module%d/file%d_test.go", i%100, i)] = float64(i%997) / 10
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := s.ReadTests(strings.NewReader(input), times); err != nil {
+			b.Fatalf("ReadTests failed: %v", err)
+		}
+	}
+}
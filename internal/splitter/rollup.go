@@ -0,0 +1,110 @@
+package splitter
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// rollupTop is the number of directories reported for every worker.
+const rollupTop = 5
+
+// Rollup groups the tests of every worker by directory in the summary, to show which areas of
+// the code base each worker runs. The zero value reports no directories.
+type Rollup struct {
+	Depth int // Number of leading path segments a directory keeps, 0 to disable
+}
+
+// ParseRollup validates a rollup given on the command line as "dir:N", N being the directory
+// depth. An empty string disables the rollup.
+func ParseRollup(s string) (Rollup, error) {
+	if s == "" {
+		return Rollup{}, nil
+	}
+	kind, depth, ok := strings.Cut(s, ":")
+	if !ok || kind != "dir" {
+		return Rollup{}, fmt.Errorf("unknown summary rollup %q (must be dir:N)", s)
+	}
+	n, err := strconv.Atoi(depth)
+	if err != nil || n < 1 {
+		return Rollup{}, fmt.Errorf("invalid summary rollup depth %q (expected 1 or more)", depth)
+	}
+	return Rollup{Depth: n}, nil
+}
+
+// WithRollup sets how StatsReporter.Rollup groups the tests of every worker by directory.
+// Defaults to no rollup.
+func WithRollup(rollup Rollup) StatsReporterOption {
+	return func(r *StatsReporter) {
+		r.rollup = rollup
+	}
+}
+
+// Rollup returns a copy of stats in which every worker lists the directories holding most of the
+// predicted time of its tests in workers, matched by index, longest first. It returns stats
+// unchanged without a rollup. The split itself is not affected.
+func (r *StatsReporter) Rollup(stats worker.Distribution, workers []worker.Worker) worker.Distribution {
+	if r.rollup.Depth == 0 {
+		return stats
+	}
+	rolled := make([]worker.Stats, len(stats.Workers))
+	for i, ws := range stats.Workers {
+		if ws.Index >= 0 && ws.Index < len(workers) {
+			ws.Directories = RollupDirectories(workers[ws.Index], r.rollup.Depth, rollupTop)
+		}
+		rolled[i] = ws
+	}
+	stats.Workers = rolled
+	return stats
+}
+
+// RollupDirectories adds up the times of the tests of w by directory, cut to its first depth
+// segments, and returns the top directories by time, ties broken by name. Tests at the top level
+// are counted under ".". Non-finite times count as zero.
+func RollupDirectories(w worker.Worker, depth, top int) []worker.DirTime {
+	index := make(map[string]int)
+	var dirs []worker.DirTime
+	for _, test := range w.Tests {
+		dir := rollupDir(test.Name, depth)
+		i, ok := index[dir]
+		if !ok {
+			i = len(dirs)
+			index[dir] = i
+			dirs = append(dirs, worker.DirTime{Dir: dir})
+		}
+		dirs[i].Time += finite(test.Time)
+		dirs[i].Tests++
+	}
+	slices.SortFunc(dirs, func(a, b worker.DirTime) int {
+		if c := cmp.Compare(b.Time, a.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Dir, b.Dir)
+	})
+	if len(dirs) > top {
+		dirs = dirs[:top]
+	}
+	return dirs
+}
+
+// rollupDir returns the directory of the test file name, cut to its first depth segments.
+func rollupDir(name string, depth int) string {
+	dir := path.Dir(path.Clean(strings.ReplaceAll(name, `\`, "/")))
+	if dir == "." || dir == "/" {
+		return dir
+	}
+	segments := strings.Split(dir, "/")
+	if segments[0] == "" {
+		// Keep the root of an absolute path on top of depth segments
+		depth++
+	}
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,113 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ControlMode controls how test names containing control characters are handled.
+type ControlMode string
+
+const (
+	ControlReject ControlMode = "reject" // Fail ReadTests with the line of the first such name
+	ControlStrip  ControlMode = "strip"  // Remove escape sequences and other control characters
+)
+
+var (
+	// ErrControlChars is returned by ReadTests for a test name containing control characters,
+	// such as ANSI escape sequences or NUL bytes, with ControlReject.
+	ErrControlChars = errors.New("test name contains control characters")
+	// ErrNameTooLong is returned by ReadTests for a test name longer than the maximum.
+	ErrNameTooLong = errors.New("test name is too long")
+)
+
+// escapeSequence matches ANSI CSI sequences such as colors ("\x1b[31m"), OSC sequences such as
+// hyperlinks ending with BEL or ST, and other two-byte escapes.
+var escapeSequence = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// ParseControlMode validates a control character mode given on the command line.
+func ParseControlMode(s string) (ControlMode, error) {
+	switch mode := ControlMode(s); mode {
+	case ControlReject, ControlStrip:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown control character mode %q (must be %q or %q)", s, ControlReject, ControlStrip)
+	}
+}
+
+// WithControlChars sets how ReadTests handles test names containing control characters, which
+// garble logs and terminals downstream. Defaults to ControlReject.
+func WithControlChars(mode ControlMode) Option {
+	return func(s *Splitter) {
+		s.controlMode = mode
+	}
+}
+
+// WithMaxNameBytes sets the maximum length of a test name accepted by ReadTests, after its inline
+// time is split off. Values below 1 keep the default.
+func WithMaxNameBytes(n int) Option {
+	return func(s *Splitter) {
+		if n > 0 {
+			s.maxNameBytes = n
+		}
+	}
+}
+
+// checkName splits off the inline time of a trimmed line into sp when enabled, then checks the
+// name for control characters and its length. It returns an empty name for a line that only held
+// control characters with ControlStrip.
+func (s *Splitter) checkName(name []byte, sp *span) ([]byte, error) {
+	if s.inlineTimes {
+		var err error
+		if name, sp.time, sp.hasTime, err = splitInlineTime(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.checkChars(name, sp.line)
+}
+
+// checkChars checks a name read at line for control characters, stripping them with
+// ControlStrip, and its length.
+func (s *Splitter) checkChars(name []byte, line int) ([]byte, error) {
+	if i := indexControl(name); i >= 0 {
+		if s.controlMode != ControlStrip {
+			r, _ := utf8.DecodeRune(name[i:])
+			return nil, fmt.Errorf("%w: %U at byte %d", ErrControlChars, r, i+1)
+		}
+		name = bytes.TrimSpace(stripControl(name))
+		s.logger.Warn().
+			Int("line", line).
+			Msg("Stripped control characters from test name")
+	}
+	if len(name) > s.maxNameBytes {
+		return nil, fmt.Errorf("%w: %d bytes (limit is %d bytes)", ErrNameTooLong, len(name), s.maxNameBytes)
+	}
+	return name, nil
+}
+
+// indexControl returns the byte offset of the first control character in b, or -1 if there is
+// none. Bytes that are not valid UTF-8 are not control characters.
+func indexControl(b []byte) int {
+	return bytes.IndexFunc(b, func(r rune) bool {
+		return r != utf8.RuneError && unicode.IsControl(r)
+	})
+}
+
+// stripControl returns b without escape sequences, then without the control characters left.
+// Bytes that are not valid UTF-8 are kept as they are.
+func stripControl(b []byte) []byte {
+	b = escapeSequence.ReplaceAll(b, nil)
+	out := b[:0]
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError || !unicode.IsControl(r) {
+			out = append(out, b[i:i+size]...)
+		}
+		i += size
+	}
+	return out
+}
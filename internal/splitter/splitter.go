@@ -1,75 +1,162 @@
 package splitter
 
 import (
-	"bufio"
-	"errors"
-	"fmt"
-	"io"
+	"maps"
+	"math"
+	"slices"
 	"sort"
-	"strings"
 
 	"github.com/rs/zerolog"
 
 	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/normalize"
+	"github.com/prgtw/tests-helper/internal/progress"
 	"github.com/prgtw/tests-helper/internal/worker"
 )
 
 const (
-	DefaultTestTime = 1.0 // Default time for tests without historical data
+	DefaultTestTime     = 1.0     // Default time for tests without historical data
+	DefaultZeroTime     = 0.001   // Time used for tests recorded as taking zero seconds
+	DefaultMaxLineBytes = 4 << 20 // Default maximum length of a single input line
+	DefaultMaxNameBytes = 4096    // Default maximum length of a test name
+
+	estimatedBytesPerLine = 48 // Rough average input line length used for pre-allocation
 )
 
 // Splitter handles the test splitting logic.
 type Splitter struct {
-	logger zerolog.Logger
+	logger       zerolog.Logger
+	normalizer   normalize.Normalizer
+	observer     worker.Observer
+	progress     progress.Reporter
+	limits       map[string]int
+	matchMode    MatchMode
+	dedupeMode   DedupeMode
+	controlMode  ControlMode
+	inputFormat  InputFormat
+	maxLineBytes int
+	maxNameBytes int
+	sizeHint     int
+	defaultTime  float64
+	zeroTime     float64
+	rules        []worker.ResourceRule
+	inlineTimes  bool
 }
 
-// NewSplitter creates a new test splitter.
-func NewSplitter(logger zerolog.Logger) *Splitter {
-	return &Splitter{logger: logger}
+// Option configures a Splitter.
+type Option func(*Splitter)
+
+// WithMaxLineBytes sets the maximum length of a single line accepted by ReadTests.
+// Values below 1 keep the default.
+func WithMaxLineBytes(n int) Option {
+	return func(s *Splitter) {
+		if n > 0 {
+			s.maxLineBytes = n
+		}
+	}
 }
 
-// ReadTests reads test names from a reader and assigns times based on historical data.
-func (s *Splitter) ReadTests(r io.Reader, times map[string]float64) ([]junit.Test, error) {
-	var tests []junit.Test
-	scanner := bufio.NewScanner(r)
+// WithSizeHint sets the expected number of tests, used to pre-allocate memory in ReadTests.
+func WithSizeHint(count int) Option {
+	return func(s *Splitter) {
+		s.sizeHint = max(count, 0)
+	}
+}
 
-	for scanner.Scan() {
-		name := strings.TrimSpace(scanner.Text())
-		if name == "" {
-			continue
+// WithDefaultTime sets the time used for tests without historical data.
+// Values that are not positive and finite keep the default.
+func WithDefaultTime(t float64) Option {
+	return func(s *Splitter) {
+		if t > 0 && !math.IsInf(t, 0) {
+			s.defaultTime = t
 		}
+	}
+}
 
-		time := times[name]
-		if time == 0 {
-			time = DefaultTestTime
-			s.logger.Debug().
-				Str("test", name).
-				Float64("time", time).
-				Msg("No historical data, using default time")
+// WithZeroTime sets the time used for tests whose recorded time is exactly zero.
+// Values that are not finite and at least zero keep the default.
+func WithZeroTime(t float64) Option {
+	return func(s *Splitter) {
+		if t >= 0 && !math.IsInf(t, 0) {
+			s.zeroTime = t
 		}
+	}
+}
 
-		tests = append(tests, junit.Test{
-			Name: name,
-			Time: time,
-		})
+// WithInlineTimes enables per-line time overrides in the test list: when the last
+// whitespace-separated token of a line parses as a number, it is used as the test's time.
+func WithInlineTimes(enabled bool) Option {
+	return func(s *Splitter) {
+		s.inlineTimes = enabled
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading tests: %w", err)
+// WithNormalizer sets how input names are normalized before being matched against stats keys.
+// It should match the normalizer used by the parser that produced the stats.
+func WithNormalizer(n normalize.Normalizer) Option {
+	return func(s *Splitter) {
+		s.normalizer = n
 	}
+}
 
-	if len(tests) == 0 {
-		return nil, errors.New("no tests provided")
+// WithMatchMode sets how input names are matched against stats keys.
+func WithMatchMode(mode MatchMode) Option {
+	return func(s *Splitter) {
+		s.matchMode = mode
 	}
+}
 
-	s.logger.Info().
-		Int("count", len(tests)).
-		Msg("Read tests from input")
+// WithDedupe sets how tests listed more than once in the input are handled.
+func WithDedupe(mode DedupeMode) Option {
+	return func(s *Splitter) {
+		s.dedupeMode = mode
+	}
+}
+
+// WithObserver sets an observer notified of every assignment made while splitting.
+func WithObserver(o worker.Observer) Option {
+	return func(s *Splitter) {
+		s.observer = o
+	}
+}
+
+// WithProgress sets a reporter notified as tests are distributed, see worker.WithProgress.
+func WithProgress(p progress.Reporter) Option {
+	return func(s *Splitter) {
+		s.progress = p
+	}
+}
 
-	return tests, nil
+// WithResources tags the tests matching rules and caps how many tests of each tag a worker may
+// take with limits, see worker.TagResources and worker.WithResourceLimits.
+func WithResources(rules []worker.ResourceRule, limits map[string]int) Option {
+	return func(s *Splitter) {
+		s.rules = rules
+		s.limits = limits
+	}
 }
 
-// SortTests sorts tests by descending execution time.
+// NewSplitter creates a new test splitter.
+func NewSplitter(logger zerolog.Logger, opts ...Option) *Splitter {
+	s := &Splitter{
+		logger:       logger,
+		maxLineBytes: DefaultMaxLineBytes,
+		maxNameBytes: DefaultMaxNameBytes,
+		controlMode:  ControlReject,
+		defaultTime:  DefaultTestTime,
+		zeroTime:     DefaultZeroTime,
+		normalizer:   normalize.New(),
+		matchMode:    MatchExact,
+		dedupeMode:   DedupeKeep,
+		inputFormat:  InputLines,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SortTests sorts tests in place by descending execution time.
 func (s *Splitter) SortTests(tests []junit.Test) {
 	sort.Slice(tests, func(i, j int) bool {
 		return tests[i].Time > tests[j].Time
@@ -77,19 +164,65 @@ func (s *Splitter) SortTests(tests []junit.Test) {
 	s.logger.Debug().Msg("Sorted tests by descending time")
 }
 
-// Split performs the complete test splitting operation.
-func (s *Splitter) Split(tests []junit.Test, numWorkers int) *worker.Allocator {
+// Split performs the complete test splitting operation, assigning tests with alg, see
+// worker.WithAlgorithm. The tests slice is left untouched; see SplitInPlace to avoid the copy.
+// It fails with worker.ErrInvalidWorkerCount when numWorkers is less than 1.
+func (s *Splitter) Split(tests []junit.Test, numWorkers int, alg worker.Algorithm) (*worker.Allocator, error) {
+	return s.SplitInPlace(slices.Clone(tests), numWorkers, alg)
+}
+
+// SplitInPlace is like Split, but sorts and tags the caller's tests slice instead of a copy.
+// Use it when the original order is no longer needed.
+// It fails with worker.ErrResourceLimit when the workers cannot take the tagged tests.
+func (s *Splitter) SplitInPlace(tests []junit.Test, numWorkers int, alg worker.Algorithm) (*worker.Allocator, error) {
+	allocator, err := worker.NewAllocator(numWorkers,
+		worker.WithObserver(s.observer),
+		worker.WithProgress(s.progress),
+		worker.WithResourceLimits(s.limits),
+		worker.WithAlgorithm(alg),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.tagResources(tests, numWorkers); err != nil {
+		return nil, err
+	}
+
 	// Sort tests by descending time for optimal distribution
 	s.SortTests(tests)
-
-	// Create allocator and distribute tests
-	allocator := worker.NewAllocator(numWorkers)
 	allocator.Distribute(tests)
 
 	s.logger.Info().
 		Int("workers", numWorkers).
 		Int("tests", len(tests)).
+		Str("algorithm", string(alg)).
 		Msg("Split tests across workers")
 
-	return allocator
+	return allocator, nil
+}
+
+// Resume returns an allocator holding copies of workers from an earlier split, e.g. read back
+// from a manifest, notifying the observer of later changes such as Drain.
+// It fails with worker.ErrInvalidWorkerCount when workers is empty.
+func (s *Splitter) Resume(workers []worker.Worker) (*worker.Allocator, error) {
+	return worker.NewAllocatorFrom(workers, worker.WithObserver(s.observer), worker.WithResourceLimits(s.limits))
+}
+
+// tagResources tags tests with the resource rules and checks that numWorkers can take them
+// under the limits.
+func (s *Splitter) tagResources(tests []junit.Test, numWorkers int) error {
+	if len(s.rules) == 0 && len(s.limits) == 0 {
+		return nil
+	}
+	counts := worker.TagResources(tests, s.rules)
+	for _, tag := range slices.Sorted(maps.Keys(counts)) {
+		event := s.logger.Info().
+			Str("resource", tag).
+			Int("tests", counts[tag])
+		if limit, ok := s.limits[tag]; ok {
+			event = event.Int("limit", limit)
+		}
+		event.Msg("Tagged tests with a resource")
+	}
+	return worker.CheckResourceLimits(counts, s.limits, numWorkers)
 }
@@ -1,13 +1,22 @@
 package splitter_test
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/rs/zerolog"
 
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/normalize"
 	"github.com/prgtw/tests-helper/internal/splitter"
+	"github.com/prgtw/tests-helper/internal/worker"
 )
 
 //nolint:gocognit // Don't care
@@ -44,6 +53,46 @@ func TestSplitter_ReadTests(t *testing.T) {
 		}
 	})
 
+	t.Run("recorded zero differs from missing", func(t *testing.T) {
+		input := "zero.go\nmissing.go\n"
+		times := map[string]float64{"zero.go": 0}
+
+		tests, err := s.ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+
+		if tests[0].Time != splitter.DefaultZeroTime {
+			t.Errorf("zero.go: got time=%.3f, want %.3f (zero time)", tests[0].Time, splitter.DefaultZeroTime)
+		}
+		if tests[1].Time != splitter.DefaultTestTime {
+			t.Errorf("missing.go: got time=%.3f, want %.3f (default)", tests[1].Time, splitter.DefaultTestTime)
+		}
+	})
+
+	t.Run("configurable zero time", func(t *testing.T) {
+		custom := splitter.NewSplitter(logger, splitter.WithZeroTime(0.25))
+		tests, err := custom.ReadTests(strings.NewReader("zero.go\n"), map[string]float64{"zero.go": 0})
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if tests[0].Time != 0.25 {
+			t.Errorf("zero.go: got time=%.3f, want 0.25", tests[0].Time)
+		}
+	})
+
+	t.Run("invalid zero time keeps the default", func(t *testing.T) {
+		custom := splitter.NewSplitter(logger,
+			splitter.WithZeroTime(-100), splitter.WithZeroTime(math.NaN()), splitter.WithZeroTime(math.Inf(1)))
+		tests, err := custom.ReadTests(strings.NewReader("zero.go\n"), map[string]float64{"zero.go": 0})
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if tests[0].Time != splitter.DefaultZeroTime {
+			t.Errorf("zero.go: got time=%.3f, want %.3f", tests[0].Time, splitter.DefaultZeroTime)
+		}
+	})
+
 	t.Run("empty lines ignored", func(t *testing.T) {
 		input := "test1.go\n\n\ntest2.go\n\n"
 		tests, err := s.ReadTests(strings.NewReader(input), map[string]float64{})
@@ -101,6 +150,41 @@ func TestSplitter_ReadTests(t *testing.T) {
 		}
 	})
 
+	t.Run("fixtures with CRLF and BOM", func(t *testing.T) {
+		times := map[string]float64{
+			"pkg/service/auth_test.go": 5.0,
+			"pkg/service/user_test.go": 3.0,
+			"pkg/api/handler_test.go":  8.0,
+		}
+
+		for _, fixture := range []string{"crlf.txt", "bom.txt"} {
+			file, err := os.Open("../../testdata/testlists/" + fixture)
+			if err != nil {
+				t.Fatalf("Failed to open fixture: %v", err)
+			}
+
+			tests, err := s.ReadTests(file, times)
+			_ = file.Close()
+			if err != nil {
+				t.Fatalf("%s: ReadTests failed: %v", fixture, err)
+			}
+
+			if len(tests) != 3 {
+				t.Fatalf("%s: expected 3 tests, got %d", fixture, len(tests))
+			}
+			for _, test := range tests {
+				want, ok := times[test.Name]
+				if !ok {
+					t.Errorf("%s: unexpected name %q", fixture, test.Name)
+					continue
+				}
+				if test.Time != want {
+					t.Errorf("%s: %q got time=%.1f, want %.1f", fixture, test.Name, test.Time, want)
+				}
+			}
+		}
+	})
+
 	t.Run("fixture with empty lines", func(t *testing.T) {
 		file, err := os.Open("../../testdata/testlists/empty-lines.txt")
 		if err != nil {
@@ -119,6 +203,343 @@ func TestSplitter_ReadTests(t *testing.T) {
 	})
 }
 
+func TestSplitter_ReadTestsNormalizesPaths(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	times := map[string]float64{
+		"pkg/api/handler_test.go": 4.0,
+		"pkg/db/conn_test.go":     3.0,
+		"pkg/util/str_test.go":    2.0,
+	}
+	input := "./pkg/api/handler_test.go\npkg//db/conn_test.go\npkg/tmp/../util/str_test.go\n"
+
+	t.Run("enabled by default", func(t *testing.T) {
+		tests, err := splitter.NewSplitter(logger).ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+
+		want := []junit.Test{
+			{Name: "./pkg/api/handler_test.go", Key: "pkg/api/handler_test.go", Time: 4.0, Source: junit.SourceStats},
+			{Name: "pkg//db/conn_test.go", Key: "pkg/db/conn_test.go", Time: 3.0, Source: junit.SourceStats},
+			{Name: "pkg/tmp/../util/str_test.go", Key: "pkg/util/str_test.go", Time: 2.0, Source: junit.SourceStats},
+		}
+		if len(tests) != len(want) {
+			t.Fatalf("Expected %d tests, got %d: %v", len(want), len(tests), tests)
+		}
+		for i := range want {
+			if tests[i] != want[i] {
+				t.Errorf("Test %d: got %+v, want %+v", i, tests[i], want[i])
+			}
+		}
+	})
+
+	t.Run("decomposed unicode", func(t *testing.T) {
+		const decomposed = "tests/cafe\u0301_test.go"
+		composedTimes := map[string]float64{"tests/caf\u00e9_test.go": 6.0}
+
+		tests, err := splitter.NewSplitter(logger).ReadTests(strings.NewReader(decomposed+"\n"), composedTimes)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if tests[0].Source != junit.SourceStats || tests[0].Time != 6.0 {
+			t.Errorf("Decomposed name did not match the composed stats key: %+v", tests[0])
+		}
+		if tests[0].Name != decomposed {
+			t.Errorf("Name should keep the input bytes: got %q, want %q", tests[0].Name, decomposed)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := splitter.NewSplitter(logger,
+			splitter.WithNormalizer(normalize.New(normalize.WithCleanPaths(false))))
+		tests, err := s.ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		for _, test := range tests {
+			if test.Source != junit.SourceDefault || test.Key != test.Name {
+				t.Errorf("Expected %q to be unmatched and unchanged, got %+v", test.Name, test)
+			}
+		}
+	})
+}
+
+func TestSplitter_ReadTestsCommentsAndInlineTimes(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	times := map[string]float64{"pkg/slow_test.go": 10.0, "pkg/fast_test.go": 2.0}
+
+	t.Run("comment lines ignored", func(t *testing.T) {
+		s := splitter.NewSplitter(logger)
+		input := "# generated list\npkg/fast_test.go\n  # indented comment\n"
+		tests, err := s.ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if len(tests) != 1 || tests[0].Name != "pkg/fast_test.go" {
+			t.Errorf("Expected only pkg/fast_test.go, got %v", tests)
+		}
+	})
+
+	t.Run("inline time overrides stats", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithInlineTimes(true))
+		input := "pkg/slow_test.go 45.0\npkg/fast_test.go\nmy spaced name_test.go\nother\t7\n"
+		tests, err := s.ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+
+		want := []junit.Test{
+			{Name: "pkg/slow_test.go", Key: "pkg/slow_test.go", Time: 45.0, Source: junit.SourceInline},
+			{Name: "pkg/fast_test.go", Key: "pkg/fast_test.go", Time: 2.0, Source: junit.SourceStats},
+			{
+				Name:   "my spaced name_test.go",
+				Key:    "my spaced name_test.go",
+				Time:   splitter.DefaultTestTime,
+				Source: junit.SourceDefault,
+			},
+			{Name: "other", Key: "other", Time: 7.0, Source: junit.SourceInline},
+		}
+		if len(tests) != len(want) {
+			t.Fatalf("Expected %d tests, got %d: %v", len(want), len(tests), tests)
+		}
+		for i := range want {
+			if tests[i] != want[i] {
+				t.Errorf("Test %d: got %+v, want %+v", i, tests[i], want[i])
+			}
+		}
+	})
+
+	t.Run("inline times disabled keeps full name", func(t *testing.T) {
+		s := splitter.NewSplitter(logger)
+		tests, err := s.ReadTests(strings.NewReader("pkg/slow_test.go 45.0\n"), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if tests[0].Name != "pkg/slow_test.go 45.0" {
+			t.Errorf("Expected the whole line as name, got %q", tests[0].Name)
+		}
+	})
+
+	t.Run("non-positive or non-finite inline time rejected", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithInlineTimes(true))
+		for _, input := range []string{
+			"a.go\nb.go -1\n", "a.go\nb.go 0\n", "a.go\nb.go NaN\n", "a.go\nb.go Inf\n",
+			"a.go\nb.go 1e400\n", "a.go\nb.go 1e-400\n",
+		} {
+			_, err := s.ReadTests(strings.NewReader(input), times)
+			if err == nil {
+				t.Errorf("Expected error for %q, got nil", input)
+				continue
+			}
+			var parseErr *junit.ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line != 2 {
+				t.Errorf("Error should be a ParseError naming line 2, got %v", err)
+			}
+		}
+	})
+}
+
+func TestSplitter_ReadTestsLongLines(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	longName := strings.Repeat("a", 1<<20) + "_test.go"
+	input := "first_test.go\n" + longName + "\nlast_test.go\n"
+
+	t.Run("1MB line within default limit", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithMaxNameBytes(len(longName)))
+		tests, err := s.ReadTests(strings.NewReader(input), map[string]float64{})
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if len(tests) != 3 {
+			t.Fatalf("Expected 3 tests, got %d", len(tests))
+		}
+		if tests[1].Name != longName {
+			t.Errorf("Long name was not preserved (got %d bytes)", len(tests[1].Name))
+		}
+	})
+
+	t.Run("line exceeding configured limit", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithMaxLineBytes(64*1024))
+		_, err := s.ReadTests(strings.NewReader(input), map[string]float64{})
+		if err == nil {
+			t.Fatal("Expected error for overlong line, got nil")
+		}
+		if !errors.Is(err, bufio.ErrTooLong) {
+			t.Errorf("Expected bufio.ErrTooLong, got %v", err)
+		}
+		var parseErr *junit.ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != 2 {
+			t.Errorf("Error should be a ParseError naming line 2, got %v", err)
+		}
+	})
+}
+
+func TestSplitter_ReadTestsControlChars(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+
+	for name, input := range map[string]string{
+		"escape sequence": "a_test.go\n\x1b[31mb_test.go\x1b[0m\n",
+		"NUL byte":        "a_test.go\nb\x00_test.go\n",
+		"C1 control":      "a_test.go\nb\u009b_test.go\n",
+	} {
+		t.Run(name+" rejected", func(t *testing.T) {
+			_, err := splitter.NewSplitter(logger).ReadTests(strings.NewReader(input), map[string]float64{})
+			var parseErr *junit.ParseError
+			if !errors.Is(err, splitter.ErrControlChars) || !errors.As(err, &parseErr) || parseErr.Line != 2 {
+				t.Errorf("Error = %v, want ErrControlChars on line 2", err)
+			}
+		})
+	}
+
+	t.Run("stripped", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithControlChars(splitter.ControlStrip),
+			splitter.WithInlineTimes(true))
+		input := "\x1b[31ma_test.go\x1b[0m\n" +
+			"\x1b]8;;https://example.com\x07b\x00_test.go\x1b]8;;\x07 2.5\n" +
+			"\x1b[0m\n" +
+			"c\u009b_test\xff.go\n"
+		tests, err := s.ReadTests(strings.NewReader(input), map[string]float64{})
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		got := make([]string, len(tests))
+		for i, test := range tests {
+			got[i] = test.Name
+		}
+		if want := []string{"a_test.go", "b_test.go", "c_test\xff.go"}; !slices.Equal(got, want) {
+			t.Errorf("Names = %q, want %q", got, want)
+		}
+		if tests[1].Time != 2.5 {
+			t.Errorf("Inline time = %g, want 2.5", tests[1].Time)
+		}
+	})
+
+	if _, err := splitter.ParseControlMode("escape"); err == nil {
+		t.Error("Expected an unknown control character mode to be rejected")
+	}
+}
+
+func TestSplitter_ReadTestsOverlongNames(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	limit := strings.Repeat("a", splitter.DefaultMaxNameBytes-len("_test.go")) + "_test.go"
+
+	tests, err := splitter.NewSplitter(logger).ReadTests(strings.NewReader(limit+"\n"), map[string]float64{})
+	if err != nil || len(tests) != 1 {
+		t.Fatalf("ReadTests = %d tests, %v, want a name at the limit accepted", len(tests), err)
+	}
+
+	input := "a_test.go\n" + strings.Repeat("x", 30_000) + "\n"
+	_, err = splitter.NewSplitter(logger).ReadTests(strings.NewReader(input), map[string]float64{})
+	var parseErr *junit.ParseError
+	if !errors.Is(err, splitter.ErrNameTooLong) || !errors.As(err, &parseErr) || parseErr.Line != 2 {
+		t.Errorf("Error = %v, want ErrNameTooLong on line 2", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "30000 bytes (limit is 4096 bytes)") {
+		t.Errorf("Error = %v, want the length and the limit", err)
+	}
+
+	s := splitter.NewSplitter(logger, splitter.WithMaxNameBytes(8))
+	_, err = s.ReadTests(strings.NewReader("a_test.go\n"), map[string]float64{})
+	if !errors.Is(err, splitter.ErrNameTooLong) {
+		t.Errorf("Error = %v, want ErrNameTooLong with a limit of 8 bytes", err)
+	}
+}
+
+func TestSplitter_ReadTestsGroups(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	times := map[string]float64{"pkg/a_test.go": 4, "pkg/b_test.go": 2, "pkg/c_test.go": 3}
+	s := splitter.NewSplitter(logger, splitter.WithInputFormat(splitter.InputGroups),
+		splitter.WithInlineTimes(true), splitter.WithDefaultTime(5))
+	input := "# Bazel targets\n" +
+		"//pkg:known\tpkg/a_test.go,pkg/b_test.go\n" +
+		"//pkg:mixed\tpkg/c_test.go, pkg/new_test.go,\n" +
+		"//pkg:pinned\tpkg/a_test.go,pkg/other_test.go 1.5\n"
+	tests, err := s.ReadTests(strings.NewReader(input), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+
+	want := []struct {
+		name    string
+		source  junit.Source
+		time    float64
+		members []float64
+	}{
+		{"//pkg:known", junit.SourceStats, 6, []float64{4, 2}},
+		{"//pkg:mixed", junit.SourceDefault, 8, []float64{3, 5}},
+		{"//pkg:pinned", junit.SourceInline, 1.5, []float64{4, 5}},
+	}
+	if len(tests) != len(want) {
+		t.Fatalf("Read %d groups, want %d", len(tests), len(want))
+	}
+	for i, w := range want {
+		got := tests[i]
+		if got.Name != w.name || got.Source != w.source || got.Time != w.time {
+			t.Errorf("Group %d = %s %s %g, want %s %s %g", i, got.Name, got.Source, got.Time, w.name, w.source, w.time)
+		}
+		if got.Group == nil || !slices.Equal(testTimes(got.Group.Members), w.members) {
+			t.Errorf("Members of %s = %+v, want times %v", w.name, got.Group, w.members)
+		}
+	}
+	if m := tests[1].Group.Members[1]; m.Name != "pkg/new_test.go" || m.Source != junit.SourceDefault {
+		t.Errorf("Unknown member = %s from %s, want pkg/new_test.go with the default time", m.Name, m.Source)
+	}
+
+	expanded := splitter.ExpandGroups(append(tests, junit.Test{Name: "pkg/single_test.go", Time: 1}))
+	names := make([]string, len(expanded))
+	for i, test := range expanded {
+		names[i] = test.Name
+	}
+	wantNames := []string{
+		"pkg/a_test.go", "pkg/b_test.go", "pkg/c_test.go", "pkg/new_test.go",
+		"pkg/a_test.go", "pkg/other_test.go", "pkg/single_test.go",
+	}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("ExpandGroups = %q, want %q", names, wantNames)
+	}
+
+	for name, line := range map[string]string{
+		"without a tab":     "//pkg:known pkg/a_test.go\n",
+		"without members":   "//pkg:known\t , \n",
+		"control character": "//pkg:known\tpkg/a\x1b_test.go\n",
+	} {
+		_, err := s.ReadTests(strings.NewReader("//pkg:ok\tpkg/a_test.go\n"+line), times)
+		var parseErr *junit.ParseError
+		if err == nil || !errors.As(err, &parseErr) || parseErr.Line != 2 {
+			t.Errorf("%s: error = %v, want a parse error on line 2", name, err)
+		}
+	}
+
+	if _, err := splitter.ParseInputFormat("csv"); err == nil {
+		t.Error("Expected an unknown input format to be rejected")
+	}
+}
+
+func TestSplitter_ReadTestsAllocations(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	const lines = 10_000
+	input := generateTestList(lines)
+
+	measure := func(s *splitter.Splitter) float64 {
+		return testing.AllocsPerRun(5, func() {
+			if _, err := s.ReadTests(strings.NewReader(input), map[string]float64{}); err != nil {
+				t.Fatalf("ReadTests failed: %v", err)
+			}
+		})
+	}
+
+	unhinted := measure(splitter.NewSplitter(logger))
+	hinted := measure(splitter.NewSplitter(logger, splitter.WithSizeHint(lines)))
+
+	// Names share a single arena, so allocations must not scale with the line count
+	if unhinted > lines/100 {
+		t.Errorf("ReadTests allocated %.0f times for %d lines, expected no per-line allocations", unhinted, lines)
+	}
+	if hinted >= unhinted {
+		t.Errorf("Size hint should reduce allocations: hinted=%.0f, unhinted=%.0f", hinted, unhinted)
+	}
+}
+
 func TestSplitter_SortTests(t *testing.T) {
 	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
 	s := splitter.NewSplitter(logger)
@@ -178,6 +599,66 @@ func TestSplitter_SortTests(t *testing.T) {
 	})
 }
 
+func TestSplitter_SplitKeepsInputOrder(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	s := splitter.NewSplitter(logger)
+
+	tests := []junit.Test{
+		{Name: "fast.go", Time: 1.0},
+		{Name: "slow.go", Time: 10.0},
+		{Name: "medium.go", Time: 5.0},
+	}
+	original := slices.Clone(tests)
+
+	allocator, err := s.Split(tests, 2, worker.AlgorithmGreedy)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if !slices.Equal(tests, original) {
+		t.Errorf("Split modified the input slice: got %v, want %v", tests, original)
+	}
+	if got := allocator.GetWorker(0).Tests[0].Name; got != "slow.go" {
+		t.Errorf("Worker 0 first test: got %q, want slow.go", got)
+	}
+
+	if _, err = s.SplitInPlace(tests, 2, worker.AlgorithmGreedy); err != nil {
+		t.Fatalf("SplitInPlace failed: %v", err)
+	}
+	if tests[0].Name != "slow.go" || tests[2].Name != "fast.go" {
+		t.Errorf("SplitInPlace should sort the input slice, got %v", tests)
+	}
+}
+
+func TestSplitter_SplitResources(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	rules := []worker.ResourceRule{{Pattern: "gpu/**", Tag: "gpu"}}
+	tests := []junit.Test{
+		{Name: "gpu/a_test.go", Time: 9},
+		{Name: "gpu/b_test.go", Time: 8},
+		{Name: "gpu/c_test.go", Time: 7},
+		{Name: "cpu_test.go", Time: 1},
+	}
+
+	s := splitter.NewSplitter(logger, splitter.WithResources(rules, map[string]int{"gpu": 2}))
+	allocator, err := s.Split(tests, 2, worker.AlgorithmGreedy)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if tests[0].Resource != "" {
+		t.Error("Split tagged the caller's tests")
+	}
+	for i, ws := range allocator.GetStats().Workers {
+		if ws.Resources["gpu"] > 2 {
+			t.Errorf("Worker %d holds %d gpu tests, over the limit of 2", i, ws.Resources["gpu"])
+		}
+	}
+
+	// Three gpu tests cannot fit on one worker taking two
+	if _, err = s.Split(tests, 1, worker.AlgorithmGreedy); !errors.Is(err, worker.ErrResourceLimit) {
+		t.Errorf("Split on one worker: error = %v, want ErrResourceLimit", err)
+	}
+}
+
 func TestSplitter_Split(t *testing.T) {
 	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
 	s := splitter.NewSplitter(logger)
@@ -195,7 +676,10 @@ func TestSplitter_Split(t *testing.T) {
 		t.Fatalf("ReadTests failed: %v", err)
 	}
 
-	allocator := s.Split(tests, 2)
+	allocator, err := s.Split(tests, 2, worker.AlgorithmGreedy)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
 
 	t.Run("returns allocator", func(t *testing.T) {
 		if allocator == nil {
@@ -252,7 +736,10 @@ func TestSplitter_Integration(t *testing.T) {
 		t.Fatalf("ReadTests failed: %v", err)
 	}
 
-	allocator := s.Split(tests, 2)
+	allocator, err := s.Split(tests, 2, worker.AlgorithmGreedy)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
 	stats := allocator.GetStats()
 
 	t.Run("all tests accounted for", func(t *testing.T) {
@@ -279,3 +766,192 @@ func floatEqual(a, b, tolerance float64) bool {
 	}
 	return diff <= tolerance
 }
+
+func BenchmarkSplitEndToEnd(b *testing.B) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	s := splitter.NewSplitter(logger)
+
+	const numWorkers = 200
+
+	for _, size := range []int{10_000, 100_000} {
+		data := generateTestList(size)
+		times := make(map[string]float64, size)
+		for i := range size {
+			// Leave every tenth test without historical data
+			if i%10 != 0 {
+				times[fmt.Sprintf("pkg/module%d/file%d_test.go", i%100, i)] = float64(i%997) / 10
+			}
+		}
+
+		b.Run(fmt.Sprintf("tests=%d/workers=%d", size, numWorkers), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				tests, err := s.ReadTests(strings.NewReader(data), times)
+				if err != nil {
+					b.Fatalf("ReadTests failed: %v", err)
+				}
+				if _, err = s.Split(tests, numWorkers, worker.AlgorithmGreedy); err != nil {
+					b.Fatalf("Split failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadTests(b *testing.B) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	const lines = 100_000
+	input := generateTestList(lines)
+
+	b.Run("no hint", func(b *testing.B) {
+		s := splitter.NewSplitter(logger)
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := s.ReadTests(strings.NewReader(input), map[string]float64{}); err != nil {
+				b.Fatalf("ReadTests failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("with hint", func(b *testing.B) {
+		s := splitter.NewSplitter(logger, splitter.WithSizeHint(lines))
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := s.ReadTests(strings.NewReader(input), map[string]float64{}); err != nil {
+				b.Fatalf("ReadTests failed: %v", err)
+			}
+		}
+	})
+}
+
+// generateTestList builds a newline-separated list of n test file names.
+func generateTestList(n int) string {
+	var sb strings.Builder
+	for i := range n {
+		fmt.Fprintf(&sb, "pkg/module%d/file%d_test.go\n", i%100, i)
+	}
+	return sb.String()
+}
+
+func TestSplitter_Errors(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	s := splitter.NewSplitter(logger, splitter.WithInlineTimes(true))
+
+	t.Run("invalid worker count", func(t *testing.T) {
+		tests := []junit.Test{{Name: "a.go", Time: 1}}
+		for _, n := range []int{0, -1} {
+			if _, err := s.Split(tests, n, worker.AlgorithmGreedy); !errors.Is(err, worker.ErrInvalidWorkerCount) {
+				t.Errorf("Split(%d): got %v, want ErrInvalidWorkerCount", n, err)
+			}
+		}
+	})
+
+	t.Run("parse error names the input file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tests.txt")
+		if err := os.WriteFile(path, []byte("a.go\n# comment\nb.go -1\n"), 0o600); err != nil {
+			t.Fatalf("Failed to create input file: %v", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Failed to open input file: %v", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		_, err = s.ReadTests(f, nil)
+		var parseErr *junit.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("Expected a ParseError, got %v", err)
+		}
+		if parseErr.File != path || parseErr.Line != 3 {
+			t.Errorf("Position: got %s:%d, want %s:3", parseErr.File, parseErr.Line, path)
+		}
+		if !strings.HasPrefix(err.Error(), path+", line 3: ") {
+			t.Errorf("Unexpected message %q", err)
+		}
+	})
+
+	t.Run("no tests", func(t *testing.T) {
+		if _, err := s.ReadTests(strings.NewReader("\n# nothing\n"), nil); !errors.Is(err, splitter.ErrNoTests) {
+			t.Errorf("Got %v, want ErrNoTests", err)
+		}
+	})
+}
+
+func TestSplitter_Options(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	input := "a_test.go\n./b_test.go\nb_test.go 7\na_test.go\n"
+	times := map[string]float64{"a_test.go": 2}
+
+	t.Run("defaults keep current behavior", func(t *testing.T) {
+		tests, err := splitter.NewSplitter(logger).ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		want := []float64{2, splitter.DefaultTestTime, splitter.DefaultTestTime, 2}
+		if got := testTimes(tests); !slices.Equal(got, want) {
+			t.Errorf("Times = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("default time", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithDefaultTime(2.5))
+		tests, err := s.ReadTests(strings.NewReader("missing_test.go\n"), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if tests[0].Time != 2.5 || tests[0].Source != junit.SourceDefault {
+			t.Errorf("Test = %+v, want the configured default 2.5", tests[0])
+		}
+	})
+
+	t.Run("invalid default time keeps the default", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithDefaultTime(0), splitter.WithDefaultTime(-1))
+		tests, err := s.ReadTests(strings.NewReader("missing_test.go\n"), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		if tests[0].Time != splitter.DefaultTestTime {
+			t.Errorf("Time = %v, want %v", tests[0].Time, splitter.DefaultTestTime)
+		}
+	})
+
+	t.Run("dedupe first", func(t *testing.T) {
+		s := splitter.NewSplitter(logger, splitter.WithDedupe(splitter.DedupeFirst), splitter.WithInlineTimes(true))
+		tests, err := s.ReadTests(strings.NewReader(input), times)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		names := make([]string, len(tests))
+		for i, test := range tests {
+			names[i] = test.Name
+		}
+		// Repeats are compared after normalization; the first spelling and its time win
+		if want := []string{"a_test.go", "./b_test.go"}; !slices.Equal(names, want) {
+			t.Errorf("Names = %v, want %v", names, want)
+		}
+		if tests[1].Time != splitter.DefaultTestTime {
+			t.Errorf("./b_test.go: got time %v, want the default from its first occurrence", tests[1].Time)
+		}
+	})
+}
+
+func TestParseDedupeMode(t *testing.T) {
+	for _, mode := range []splitter.DedupeMode{splitter.DedupeKeep, splitter.DedupeFirst} {
+		got, err := splitter.ParseDedupeMode(string(mode))
+		if err != nil || got != mode {
+			t.Errorf("ParseDedupeMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := splitter.ParseDedupeMode("last"); err == nil {
+		t.Error("Expected error for unknown dedupe mode, got nil")
+	}
+}
+
+// testTimes returns the time of each test, in order.
+func testTimes(tests []junit.Test) []float64 {
+	times := make([]float64, len(tests))
+	for i, test := range tests {
+		times[i] = test.Time
+	}
+	return times
+}
@@ -1,31 +1,100 @@
 package splitter
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/rs/zerolog"
 
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/junit"
 	"github.com/prgtw/tests-helper/internal/worker"
 )
 
 // StatsReporter handles printing of distribution statistics.
 type StatsReporter struct {
-	logger zerolog.Logger
+	logger    zerolog.Logger
+	durations duration.Format
+	rollup    Rollup
+	details   WorkerDetails
+	weighted  bool
+}
+
+// StatsReporterOption configures a StatsReporter.
+type StatsReporterOption func(*StatsReporter)
+
+// WithDurationFormat sets how durations are rendered in log messages. Structured fields always
+// hold raw seconds. Defaults to duration.FormatSeconds.
+func WithDurationFormat(f duration.Format) StatsReporterOption {
+	return func(r *StatsReporter) {
+		r.durations = f
+	}
+}
+
+// WithCaseWeights weighs the times of every worker by their test cases in its percentiles, when
+// all of its tests have a count, so they reflect individual tests rather than files. Defaults to
+// false.
+func WithCaseWeights(enabled bool) StatsReporterOption {
+	return func(r *StatsReporter) {
+		r.weighted = enabled
+	}
 }
 
 // NewStatsReporter creates a new statistics reporter.
-func NewStatsReporter(logger zerolog.Logger) *StatsReporter {
-	return &StatsReporter{logger: logger}
+func NewStatsReporter(logger zerolog.Logger, opts ...StatsReporterOption) *StatsReporter {
+	r := &StatsReporter{logger: logger, durations: duration.FormatSeconds}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-// PrintSummary prints the overall distribution summary.
-func (r *StatsReporter) PrintSummary(stats worker.Distribution, showPercentiles bool) {
+// dur renders seconds for a log message, with millisecond precision.
+func (r *StatsReporter) dur(seconds float64) string {
+	return r.durations.Render(seconds, 3)
+}
+
+// StatsOptions returns the statistics options PrintSummary needs to report percentiles, per
+// worker and for the whole suite, so callers can avoid collecting data that will not be reported.
+// No percentiles disables both.
+func (r *StatsReporter) StatsOptions(percentiles []int) worker.StatsOptions {
+	show := len(percentiles) > 0
+	return worker.StatsOptions{
+		IncludeTestTimes: show,
+		SortTestTimes:    show,
+		SuitePercentiles: percentiles,
+		WeightByCases:    show && r.weighted,
+	}
+}
+
+// PrintSummary prints the overall distribution summary: the suite-wide percentiles carried by
+// stats, then every worker with its own percentiles, for the given list.
+// Non-finite values (NaN, ±Inf) are reported as zero.
+func (r *StatsReporter) PrintSummary(stats worker.Distribution, percentiles []int) {
+	stats = sanitizeDistribution(stats)
+
 	r.logger.Info().Msg("=== Distribution Summary ===")
 	r.logger.Info().
 		Float64("total_time", stats.TotalTime).
 		Float64("avg_per_bucket", stats.AvgTime).
-		Msgf("Total time: %.3fs, Avg per bucket: %.3fs", stats.TotalTime, stats.AvgTime)
+		Msgf("Total time: %s, Avg per bucket: %s", r.dur(stats.TotalTime), r.dur(stats.AvgTime))
+
+	if len(percentiles) > 0 && len(stats.SuitePercentiles) > 0 {
+		r.logger.Info().Msg("Suite percentiles (all test files, before distribution):")
+		for _, p := range stats.SuitePercentiles {
+			label := fmt.Sprintf("P%-3d", p.Percentile)
+			r.logger.Info().
+				Str("scope", "suite").
+				Int("percentile", p.Percentile).
+				Float64("value", p.Value).
+				Msgf("Suite %4s = %s", label, r.dur(p.Value))
+		}
+	}
 
 	for _, ws := range stats.Workers {
 		if ws.TestCount == 0 {
@@ -41,38 +110,196 @@ func (r *StatsReporter) PrintSummary(stats worker.Distribution, showPercentiles
 			Int("test_count", ws.TestCount).
 			Float64("min_time", ws.MinTime).
 			Float64("max_time", ws.MaxTime).
-			Msgf("Worker %d: %.3fs (%d test files, min %.3fs, max %.3fs)",
-				ws.Index, ws.Total, ws.TestCount, ws.MinTime, ws.MaxTime)
+			Msgf("Worker %d: %s (%d test files, min %s, max %s)",
+				ws.Index, r.dur(ws.Total), ws.TestCount, r.dur(ws.MinTime), r.dur(ws.MaxTime))
+
+		if len(percentiles) > 0 && len(ws.TestTimes) > 0 {
+			r.printWorkerPercentiles(&ws, percentiles)
+		}
+		if len(ws.Resources) > 0 {
+			r.printResources(ws, stats.ResourceLimits)
+		}
+		if len(ws.Directories) > 0 {
+			r.printDirectories(ws)
+		}
+	}
+
+	r.printOversized(stats)
+}
 
-		if showPercentiles && len(ws.TestTimes) > 0 {
-			r.printWorkerPercentiles(ws.TestTimes)
+// printOversized warns about every test taking longer than the average worker budget on its own,
+// which caps how well any split can balance the run.
+func (r *StatsReporter) printOversized(stats worker.Distribution) {
+	for _, t := range stats.Oversized {
+		r.logger.Warn().
+			Str("test", t.Name).
+			Int("worker", t.Worker).
+			Float64("time", t.Time).
+			Float64("budget", stats.AvgTime).
+			Float64("excess", t.Excess).
+			Msgf("Test %s alone takes %s, %s over the average worker budget of %s; "+
+				"no split can balance it, split the file or use fewer workers",
+				t.Name, r.dur(t.Time), r.dur(t.Excess), r.dur(stats.AvgTime))
+	}
+}
+
+// printResources logs how many tests of each resource tag a worker holds, with the limit of
+// limited tags.
+func (r *StatsReporter) printResources(ws worker.Stats, limits map[string]int) {
+	tags := slices.Sorted(maps.Keys(ws.Resources))
+	parts := make([]string, len(tags))
+	dict := zerolog.Dict()
+	for i, tag := range tags {
+		dict.Int(tag, ws.Resources[tag])
+		parts[i] = fmt.Sprintf("%s=%d", tag, ws.Resources[tag])
+		if limit, ok := limits[tag]; ok {
+			parts[i] += fmt.Sprintf("/%d", limit)
 		}
 	}
+	r.logger.Info().
+		Int("worker", ws.Index).
+		Dict("resources", dict).
+		Msgf("Worker %d resources: %s", ws.Index, strings.Join(parts, ", "))
 }
 
-// printWorkerPercentiles prints percentile statistics for a worker.
-func (r *StatsReporter) printWorkerPercentiles(times []float64) {
-	// Sort times for percentile calculation
-	sorted := make([]float64, len(times))
-	copy(sorted, times)
-	sort.Float64s(sorted)
+// printDirectories logs the top directories of a worker by predicted time, see Rollup.
+func (r *StatsReporter) printDirectories(ws worker.Stats) {
+	parts := make([]string, len(ws.Directories))
+	dict := zerolog.Dict()
+	for i, d := range ws.Directories {
+		dict.Float64(d.Dir, d.Time)
+		parts[i] = fmt.Sprintf("%s %s (%d test files)", d.Dir, r.dur(d.Time), d.Tests)
+	}
+	r.logger.Info().
+		Int("worker", ws.Index).
+		Dict("directories", dict).
+		Msgf("Worker %d top directories: %s", ws.Index, strings.Join(parts, ", "))
+}
+
+// sanitizeDistribution returns a copy of stats with every non-finite value replaced by zero.
+func sanitizeDistribution(stats worker.Distribution) worker.Distribution {
+	stats.TotalTime = finite(stats.TotalTime)
+	stats.AvgTime = finite(stats.AvgTime)
+
+	workers := make([]worker.Stats, len(stats.Workers))
+	for i, ws := range stats.Workers {
+		ws.Total = finite(ws.Total)
+		ws.MinTime = finite(ws.MinTime)
+		ws.MaxTime = finite(ws.MaxTime)
+
+		for j, t := range ws.TestTimes {
+			if t != finite(t) {
+				ws.TestTimes = sanitizeTimes(ws.TestTimes, j)
+				ws.TestTimesSorted = false
+				break
+			}
+		}
+		workers[i] = ws
+	}
+	stats.Workers = workers
+
+	suite := make([]worker.Percentile, len(stats.SuitePercentiles))
+	for i, p := range stats.SuitePercentiles {
+		suite[i] = worker.Percentile{Percentile: p.Percentile, Value: finite(p.Value)}
+	}
+	stats.SuitePercentiles = suite
 
+	oversized := make([]worker.OversizedTest, len(stats.Oversized))
+	for i, t := range stats.Oversized {
+		t.Time, t.Excess = finite(t.Time), finite(t.Excess)
+		oversized[i] = t
+	}
+	stats.Oversized = oversized
+
+	return stats
+}
+
+// sanitizeTimes copies times, replacing non-finite values from index from onwards.
+func sanitizeTimes(times []float64, from int) []float64 {
+	sanitized := make([]float64, len(times))
+	copy(sanitized, times)
+	for i := from; i < len(sanitized); i++ {
+		sanitized[i] = finite(sanitized[i])
+	}
+	return sanitized
+}
+
+// finite returns v, or zero when v is NaN or infinite.
+func finite(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
+
+// printWorkerPercentiles prints percentile statistics for the worker of ws, weighted by the test
+// cases of its times when it carries them and labeled by the weighting used.
+// Times are sorted here only when the caller could not provide them sorted.
+func (r *StatsReporter) printWorkerPercentiles(ws *worker.Stats, percentiles []int) {
 	calc := NewPercentileCalculator()
-	percentiles := []int{50, 75, 95, 99, 100}
-	results := calc.Calculate(sorted, percentiles)
+	var results map[int]float64
+	weighting, suffix := "files", ""
+	switch {
+	case len(ws.TestCases) == len(ws.TestTimes) && ws.TestTimesSorted:
+		results = calc.CalculateWeightedSorted(ws.TestTimes, ws.TestCases, percentiles)
+		weighting, suffix = "cases", " (weighted by test cases)"
+	case len(ws.TestCases) == len(ws.TestTimes):
+		results = calc.CalculateWeighted(ws.TestTimes, ws.TestCases, percentiles)
+		weighting, suffix = "cases", " (weighted by test cases)"
+	case ws.TestTimesSorted:
+		results = calc.CalculateSorted(ws.TestTimes, percentiles)
+	default:
+		results = calc.Calculate(ws.TestTimes, percentiles)
+	}
 
 	for _, p := range percentiles {
 		label := fmt.Sprintf("P%-3d", p)
 		r.logger.Info().
+			Str("scope", "worker").
+			Int("worker", ws.Index).
 			Int("percentile", p).
 			Float64("value", results[p]).
-			Msgf("%4s = %.3fs", label, results[p])
+			Str("weighting", weighting).
+			Msgf("%4s = %s%s", label, r.dur(results[p]), suffix)
+	}
+}
+
+// PrintBudget prints the time every worker kept within budget and the time deferred by trimming,
+// given the trimmed workers and the deferred tests of each. A worker still over budget is
+// reported as a warning: its most expensive test alone exceeds the budget.
+func (r *StatsReporter) PrintBudget(budget float64, workers []worker.Worker, deferred [][]junit.Test) {
+	for i, w := range workers {
+		var deferredTime float64
+		var deferredTests []junit.Test
+		if i < len(deferred) {
+			deferredTests = deferred[i]
+		}
+		for _, t := range deferredTests {
+			deferredTime += t.Time
+		}
+
+		r.logger.Info().
+			Int("worker", i).
+			Float64("kept_time", w.Total).
+			Int("kept", len(w.Tests)).
+			Float64("deferred_time", deferredTime).
+			Int("deferred", len(deferredTests)).
+			Msgf("Worker %d: kept %s (%d test files), deferred %s (%d test files), budget %s",
+				i, r.dur(w.Total), len(w.Tests), r.dur(deferredTime), len(deferredTests), r.dur(budget))
+		if w.Total > budget {
+			r.logger.Warn().
+				Int("worker", i).
+				Float64("kept_time", w.Total).
+				Float64("budget", budget).
+				Msgf("Worker %d stays over the budget: its most expensive test is never deferred", i)
+		}
 	}
 }
 
-// PrintWorkerDetails prints detailed information about a specific worker.
-func (r *StatsReporter) PrintWorkerDetails(allocator *worker.Allocator, index int) {
-	w := allocator.GetWorker(index)
+// PrintWorkerDetails prints detailed information about the worker at index: its totals, then its
+// tests longest first with a running total, see WithWorkerDetails. A nil w is reported as an
+// invalid index.
+func (r *StatsReporter) PrintWorkerDetails(index int, w *worker.Worker) {
 	if w == nil {
 		r.logger.Error().
 			Int("worker_index", index).
@@ -85,6 +312,8 @@ func (r *StatsReporter) PrintWorkerDetails(allocator *worker.Allocator, index in
 		Float64("total_time", w.Total).
 		Int("test_count", len(w.Tests)).
 		Msg("Rendering test files")
+
+	r.printTests(index, w)
 }
 
 // PercentileCalculator calculates percentiles for test time distributions.
@@ -97,33 +326,60 @@ func NewPercentileCalculator() *PercentileCalculator {
 
 // Calculate calculates percentiles for a set of test times.
 func (pc *PercentileCalculator) Calculate(times []float64, percentiles []int) map[int]float64 {
-	if len(times) == 0 {
+	return pc.CalculateSorted(sortedCopy(times), percentiles)
+}
+
+// CalculateSorted calculates percentiles for test times already sorted in ascending order.
+// The input is trusted and not re-sorted.
+func (pc *PercentileCalculator) CalculateSorted(sorted []float64, percentiles []int) map[int]float64 {
+	if len(sorted) == 0 {
 		return make(map[int]float64)
 	}
 
-	// Sort times for percentile calculation
-	sorted := make([]float64, len(times))
-	copy(sorted, times)
-	sort.Float64s(sorted)
-
 	results := make(map[int]float64)
-	const percentageDivisor = 100.0
 	for _, p := range percentiles {
-		pos := float64(p) / percentageDivisor * float64(len(sorted)-1)
-		results[p] = interpolate(sorted, pos)
+		results[p] = worker.PercentileOf(sorted, p)
 	}
 
 	return results
 }
 
-// interpolate performs linear interpolation for percentile calculation.
-func interpolate(sorted []float64, pos float64) float64 {
-	i := int(pos)
-	if i >= len(sorted)-1 {
-		return sorted[len(sorted)-1]
+// CalculateWeighted calculates percentiles for a set of test times, each counting as many times as
+// its weight in weights, aligned with times, e.g. the test cases of a file. See
+// worker.WeightedPercentileOf.
+func (pc *PercentileCalculator) CalculateWeighted(times, weights []float64, percentiles []int) map[int]float64 {
+	n := min(len(times), len(weights))
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(times[a], times[b]) })
+	sorted, sortedWeights := make([]float64, n), make([]float64, n)
+	for i, j := range order {
+		sorted[i], sortedWeights[i] = times[j], weights[j]
+	}
+	return pc.CalculateWeightedSorted(sorted, sortedWeights, percentiles)
+}
+
+// CalculateWeightedSorted calculates weighted percentiles for test times already sorted in
+// ascending order, weights moved along with them. The input is trusted and not re-sorted.
+func (pc *PercentileCalculator) CalculateWeightedSorted(sorted, weights []float64, percentiles []int) map[int]float64 {
+	results := make(map[int]float64)
+	if len(sorted) == 0 {
+		return results
 	}
-	frac := pos - float64(i)
-	return sorted[i]*(1-frac) + sorted[i+1]*frac
+	for _, p := range percentiles {
+		results[p] = worker.WeightedPercentileOf(sorted, weights, p)
+	}
+	return results
+}
+
+// sortedCopy returns an ascending copy of times, leaving the input untouched.
+func sortedCopy(times []float64) []float64 {
+	sorted := make([]float64, len(times))
+	copy(sorted, times)
+	sort.Float64s(sorted)
+	return sorted
 }
 
 // PrintPercentiles prints percentile statistics.
@@ -133,7 +389,7 @@ func (r *StatsReporter) PrintPercentiles(times []float64) {
 	}
 
 	calc := NewPercentileCalculator()
-	percentiles := []int{50, 75, 95, 99, 100}
+	percentiles := worker.DefaultPercentiles()
 	results := calc.Calculate(times, percentiles)
 
 	for _, p := range percentiles {
@@ -141,6 +397,6 @@ func (r *StatsReporter) PrintPercentiles(times []float64) {
 		r.logger.Info().
 			Int("percentile", p).
 			Float64("value", results[p]).
-			Msgf("%4s = %.3fs", label, results[p])
+			Msgf("%4s = %s", label, r.dur(results[p]))
 	}
 }
@@ -1,12 +1,20 @@
 package splitter_test
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/rs/zerolog"
 
+	"github.com/prgtw/tests-helper/internal/duration"
+	"github.com/prgtw/tests-helper/internal/junit"
 	"github.com/prgtw/tests-helper/internal/splitter"
 	"github.com/prgtw/tests-helper/internal/worker"
 )
@@ -65,6 +73,86 @@ func TestPercentileCalculator_Calculate(t *testing.T) {
 	})
 }
 
+func TestPercentileCalculator_CalculateSorted(t *testing.T) {
+	calc := splitter.NewPercentileCalculator()
+	percentiles := []int{50, 75, 95, 99, 100}
+
+	unsorted := []float64{5.0, 1.0, 4.0, 2.0, 3.0}
+	sorted := []float64{1.0, 2.0, 3.0, 4.0, 5.0}
+
+	want := calc.Calculate(unsorted, percentiles)
+	got := calc.CalculateSorted(sorted, percentiles)
+
+	for _, p := range percentiles {
+		if !floatEqual(got[p], want[p], 0.001) {
+			t.Errorf("P%d: got %.3f, want %.3f", p, got[p], want[p])
+		}
+	}
+
+	if unsorted[0] != 5.0 {
+		t.Error("Calculate must not reorder its input")
+	}
+}
+
+func TestPercentileCalculator_CalculateWeighted(t *testing.T) {
+	calc := splitter.NewPercentileCalculator()
+	percentiles := []int{0, 50, 95, 100}
+
+	// Sorted with their weights, the times stand for [1 1 1 4 4 10]: P50 sits at rank 2.5,
+	// between 1 and 4, and P95 at rank 4.75, between 4 and 10
+	got := calc.CalculateWeighted([]float64{10, 1, 4}, []float64{1, 3, 2}, percentiles)
+	want := map[int]float64{0: 1, 50: 2.5, 95: 8.5, 100: 10}
+	for _, p := range percentiles {
+		if !floatEqual(got[p], want[p], 1e-9) {
+			t.Errorf("P%d: got %v, want %v", p, got[p], want[p])
+		}
+	}
+	if unweighted := calc.Calculate([]float64{10, 1, 4}, []int{50}); unweighted[50] != 4 {
+		t.Errorf("Unweighted P50 = %v, want 4", unweighted[50])
+	}
+
+	sorted := calc.CalculateWeightedSorted([]float64{1, 4, 10}, []float64{3, 2, 1}, percentiles)
+	for _, p := range percentiles {
+		if !floatEqual(sorted[p], want[p], 1e-9) {
+			t.Errorf("Sorted P%d: got %v, want %v", p, sorted[p], want[p])
+		}
+	}
+	if got := calc.CalculateWeighted(nil, nil, percentiles); len(got) != 0 {
+		t.Errorf("Percentiles of nothing = %v, want none", got)
+	}
+}
+
+func TestStatsReporter_PrintSummaryWeighted(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := splitter.NewStatsReporter(zerolog.New(&buf), splitter.WithCaseWeights(true))
+	if !reporter.StatsOptions([]int{50}).WeightByCases {
+		t.Error("Test cases should be requested with WithCaseWeights")
+	}
+
+	stats := worker.Distribution{
+		TotalTime: 30,
+		AvgTime:   15,
+		Workers: []worker.Stats{
+			{Index: 0, Total: 15, TestCount: 3, MinTime: 1, MaxTime: 10,
+				TestTimes: []float64{1, 4, 10}, TestCases: []float64{3, 2, 1}, TestTimesSorted: true},
+			{Index: 1, Total: 15, TestCount: 3, MinTime: 1, MaxTime: 10,
+				TestTimes: []float64{1, 4, 10}, TestTimesSorted: true},
+		},
+	}
+	reporter.PrintSummary(stats, []int{50})
+	out := buf.String()
+
+	// Worker 0 carries test cases and is weighted; worker 1 falls back to file times
+	for _, want := range []string{
+		`"worker":0,"percentile":50,"value":2.5,"weighting":"cases","message":"P50  = 2.500s (weighted by test cases)"`,
+		`"worker":1,"percentile":50,"value":4,"weighting":"files","message":"P50  = 4.000s"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in:\n%s", want, out)
+		}
+	}
+}
+
 func TestStatsReporter_PrintSummary(t *testing.T) {
 	var buf bytes.Buffer
 	logger := zerolog.New(&buf)
@@ -95,7 +183,7 @@ func TestStatsReporter_PrintSummary(t *testing.T) {
 
 	t.Run("without percentiles", func(t *testing.T) {
 		buf.Reset()
-		reporter.PrintSummary(stats, false)
+		reporter.PrintSummary(stats, nil)
 
 		if buf.Len() == 0 {
 			t.Error("PrintSummary produced no output")
@@ -124,7 +212,7 @@ func TestStatsReporter_PrintSummary(t *testing.T) {
 
 	t.Run("with percentiles", func(t *testing.T) {
 		buf.Reset()
-		reporter.PrintSummary(stats, true)
+		reporter.PrintSummary(stats, worker.DefaultPercentiles())
 
 		// Should contain percentile markers
 		percentileMarkers := []string{"P50", "P75", "P95", "P99", "P100"}
@@ -133,6 +221,26 @@ func TestStatsReporter_PrintSummary(t *testing.T) {
 				t.Errorf("Output missing percentile marker %q", marker)
 			}
 		}
+		if bytes.Contains(buf.Bytes(), []byte("Suite")) {
+			t.Error("Output contains a suite block although stats carry no suite percentiles")
+		}
+	})
+
+	t.Run("suite percentiles", func(t *testing.T) {
+		buf.Reset()
+		suite := stats
+		suite.SuitePercentiles = []worker.Percentile{{Percentile: 95, Value: 41.5}}
+		reporter.PrintSummary(suite, []int{95})
+
+		wants := []string{"Suite percentiles", "Suite P95  = 41.500s", `"scope":"suite"`, `"scope":"worker"`}
+		for _, want := range wants {
+			if !bytes.Contains(buf.Bytes(), []byte(want)) {
+				t.Errorf("Output missing %q:\n%s", want, buf.String())
+			}
+		}
+		if bytes.Contains(buf.Bytes(), []byte("P50")) {
+			t.Error("Output contains P50 although only P95 was requested")
+		}
 	})
 
 	t.Run("empty workers", func(t *testing.T) {
@@ -145,7 +253,7 @@ func TestStatsReporter_PrintSummary(t *testing.T) {
 			},
 		}
 
-		reporter.PrintSummary(emptyStats, false)
+		reporter.PrintSummary(emptyStats, nil)
 
 		if !bytes.Contains(buf.Bytes(), []byte("0 test files")) {
 			t.Error("Output should mention '0 test files' for empty worker")
@@ -153,6 +261,166 @@ func TestStatsReporter_PrintSummary(t *testing.T) {
 	})
 }
 
+func TestStatsReporter_PrintSummaryNonFinite(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := splitter.NewStatsReporter(zerolog.New(&buf))
+
+	t.Run("zero-worker allocator", func(t *testing.T) {
+		buf.Reset()
+		var empty worker.Allocator
+		reporter.PrintSummary(empty.GetStats(), worker.DefaultPercentiles())
+		if bytes.Contains(buf.Bytes(), []byte("NaN")) {
+			t.Errorf("Output contains NaN: %s", buf.String())
+		}
+	})
+
+	t.Run("non-finite values", func(t *testing.T) {
+		buf.Reset()
+		stats := worker.Distribution{
+			TotalTime: math.Inf(1),
+			AvgTime:   math.NaN(),
+			Workers: []worker.Stats{
+				{
+					Index:     0,
+					Total:     math.NaN(),
+					TestCount: 2,
+					MinTime:   math.Inf(-1),
+					MaxTime:   math.Inf(1),
+					TestTimes: []float64{1.0, math.NaN()},
+				},
+			},
+		}
+
+		reporter.PrintSummary(stats, worker.DefaultPercentiles())
+
+		for _, bad := range []string{"NaN", "Inf"} {
+			if bytes.Contains(buf.Bytes(), []byte(bad)) {
+				t.Errorf("Output contains %s: %s", bad, buf.String())
+			}
+		}
+		if !math.IsNaN(stats.Workers[0].TestTimes[1]) {
+			t.Error("PrintSummary must not modify the caller's data")
+		}
+	})
+}
+
+func TestStatsReporter_PrintSummary_Golden(t *testing.T) {
+	stats := worker.Distribution{
+		TotalTime:        3746.842,
+		AvgTime:          1873.421,
+		SuitePercentiles: []worker.Percentile{{Percentile: 50, Value: 0.25}, {Percentile: 100, Value: 1800}},
+		Workers: []worker.Stats{
+			{Index: 0, Total: 1873.421, TestCount: 2, MinTime: 73.421, MaxTime: 1800,
+				TestTimes: []float64{73.421, 1800}},
+			{Index: 1, Total: 1873.421, TestCount: 3, MinTime: 0.25, MaxTime: 3600,
+				TestTimes: []float64{0.25, 12.5, 3600}},
+		},
+		Oversized: []worker.OversizedTest{{Name: "huge_test.go", Time: 3600, Excess: 1726.579, Worker: 1}},
+	}
+
+	for _, format := range []duration.Format{duration.FormatSeconds, duration.FormatHuman} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			reporter := splitter.NewStatsReporter(zerolog.New(&buf), splitter.WithDurationFormat(format))
+			reporter.PrintSummary(stats, []int{50, 100})
+			raw := buf.String()
+			got := logMessages(t, &buf)
+
+			want, err := os.ReadFile("../../testdata/summary/" + string(format) + ".txt")
+			if err != nil {
+				t.Fatalf("cannot read golden file: %v", err)
+			}
+			if strings.TrimSpace(got) != strings.TrimSpace(string(want)) {
+				t.Errorf("PrintSummary() =\n%s\nwant\n%s", got, want)
+			}
+			// Structured fields keep raw seconds whatever the format
+			if !strings.Contains(raw, `"total_time":1873.421`) {
+				t.Errorf("Fields lack the raw seconds:\n%s", raw)
+			}
+		})
+	}
+}
+
+// logMessages returns the message of every JSON log line in buf, one per line.
+func logMessages(t *testing.T, buf *bytes.Buffer) string {
+	t.Helper()
+	var b strings.Builder
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid log line %q: %v", scanner.Text(), err)
+		}
+		b.WriteString(entry.Message + "\n")
+	}
+	return b.String()
+}
+
+func TestStatsReporter_PrintOversized(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := splitter.NewStatsReporter(zerolog.New(&buf))
+
+	reporter.PrintSummary(worker.Distribution{AvgTime: 10, Workers: []worker.Stats{{Total: 10}}}, nil)
+	if bytes.Contains(buf.Bytes(), []byte("budget")) {
+		t.Errorf("Expected no budget warning, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter.PrintSummary(worker.Distribution{
+		AvgTime: 10,
+		Oversized: []worker.OversizedTest{
+			{Name: "huge_test.go", Time: 25, Excess: 15},
+			{Name: "big_test.go", Time: 12, Excess: 2, Worker: 1},
+		},
+	}, nil)
+	for _, want := range []string{
+		"Test huge_test.go alone takes 25.000s, 15.000s over the average worker budget of 10.000s",
+		"Test big_test.go alone takes 12.000s, 2.000s over",
+		"split the file or use fewer workers",
+		`"level":"warn"`,
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("Output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestStatsReporter_PrintBudget(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := splitter.NewStatsReporter(zerolog.New(&buf))
+
+	reporter.PrintBudget(10, []worker.Worker{
+		{Tests: []junit.Test{{Name: "a", Time: 6}}, Total: 6},
+		{Tests: []junit.Test{{Name: "huge", Time: 30}}, Total: 30},
+	}, [][]junit.Test{nil, {{Name: "b", Time: 2}, {Name: "c", Time: 1}}})
+
+	for _, want := range []string{
+		"Worker 0: kept 6.000s (1 test files), deferred 0.000s (0 test files), budget 10.000s",
+		"Worker 1: kept 30.000s (1 test files), deferred 3.000s (2 test files), budget 10.000s",
+		"Worker 1 stays over the budget",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("Output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("Worker 0 stays")) {
+		t.Error("Worker 0 is within budget and must not be reported as over it")
+	}
+}
+
+func TestStatsReporter_StatsOptions(t *testing.T) {
+	reporter := splitter.NewStatsReporter(zerolog.Nop())
+
+	if !reporter.StatsOptions(worker.DefaultPercentiles()).IncludeTestTimes {
+		t.Error("Test times should be requested when percentiles are shown")
+	}
+	if reporter.StatsOptions(nil).IncludeTestTimes {
+		t.Error("Test times should not be requested when percentiles are hidden")
+	}
+}
+
 func TestStatsReporter_PrintWorkerDetails(t *testing.T) {
 	var buf bytes.Buffer
 	logger := zerolog.New(&buf)
@@ -163,11 +431,14 @@ func TestStatsReporter_PrintWorkerDetails(t *testing.T) {
 	input := "test1.go\ntest2.go\n"
 	times := map[string]float64{"test1.go": 10.0, "test2.go": 5.0}
 	testList, _ := s.ReadTests(bytes.NewReader([]byte(input)), times)
-	allocator := s.Split(testList, 2)
+	allocator, err := s.Split(testList, 2, worker.AlgorithmGreedy)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
 
 	t.Run("valid worker", func(t *testing.T) {
 		buf.Reset()
-		reporter.PrintWorkerDetails(allocator, 0)
+		reporter.PrintWorkerDetails(0, allocator.GetWorkerRef(0))
 
 		if buf.Len() == 0 {
 			t.Error("PrintWorkerDetails produced no output")
@@ -181,7 +452,7 @@ func TestStatsReporter_PrintWorkerDetails(t *testing.T) {
 
 	t.Run("invalid worker index", func(t *testing.T) {
 		buf.Reset()
-		reporter.PrintWorkerDetails(allocator, 99)
+		reporter.PrintWorkerDetails(99, allocator.GetWorkerRef(99))
 
 		// Should log error about invalid index
 		if !bytes.Contains(buf.Bytes(), []byte("Invalid worker index")) {
@@ -189,3 +460,222 @@ func TestStatsReporter_PrintWorkerDetails(t *testing.T) {
 		}
 	})
 }
+
+func BenchmarkPrintSummary(b *testing.B) {
+	const numWorkers = 200
+
+	tests := make([]junit.Test, 100_000)
+	for i := range tests {
+		tests[i] = junit.Test{Name: fmt.Sprintf("file%d_test.go", i), Time: float64(i%997) / 10}
+	}
+	allocator, err := worker.NewAllocator(numWorkers)
+	if err != nil {
+		b.Fatalf("NewAllocator failed: %v", err)
+	}
+	allocator.Distribute(tests)
+
+	reporter := splitter.NewStatsReporter(zerolog.Nop())
+	percentiles := worker.DefaultPercentiles()
+
+	b.Run("unsorted", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			reporter.PrintSummary(allocator.GetStats(), percentiles)
+		}
+	})
+
+	b.Run("presorted", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			reporter.PrintSummary(allocator.GetStatsWithOptions(reporter.StatsOptions(percentiles)), percentiles)
+		}
+	})
+}
+
+func TestStatsReporter_PrintResources(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := splitter.NewStatsReporter(zerolog.New(&buf))
+
+	reporter.PrintSummary(worker.Distribution{
+		ResourceLimits: map[string]int{"gpu": 2},
+		Workers: []worker.Stats{
+			{Index: 0, Total: 5, TestCount: 3, Resources: map[string]int{"gpu": 2, "net": 1}},
+			{Index: 1, Total: 4, TestCount: 2},
+		},
+	}, nil)
+	if want := "Worker 0 resources: gpu=2/2, net=1"; !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("Output missing %q:\n%s", want, buf.String())
+	}
+	if want := `"resources":{"gpu":2,"net":1}`; !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("Output missing %s:\n%s", want, buf.String())
+	}
+	if bytes.Contains(buf.Bytes(), []byte("Worker 1 resources")) {
+		t.Errorf("Worker without tagged tests reported resources:\n%s", buf.String())
+	}
+}
+
+func TestParseRollup(t *testing.T) {
+	for in, want := range map[string]int{"": 0, "dir:1": 1, "dir:3": 3} {
+		got, err := splitter.ParseRollup(in)
+		if err != nil || got.Depth != want {
+			t.Errorf("ParseRollup(%q) = %+v, %v, want depth %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"dir", "dir:0", "dir:-1", "dir:x", "package:2"} {
+		if _, err := splitter.ParseRollup(in); err == nil {
+			t.Errorf("ParseRollup(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestRollupDirectories(t *testing.T) {
+	w := worker.Worker{Tests: []junit.Test{
+		{Name: "src/api/v1/users_test.go", Time: 4},
+		{Name: "src/api/v2/users_test.go", Time: 2.5},
+		{Name: "./src/api/health_test.go", Time: 0.5},
+		{Name: `src\web\login_test.go`, Time: 3},
+		{Name: "src/web/forms/input_test.go", Time: 3},
+		{Name: "tools/gen_test.go", Time: 1.25},
+		{Name: "main_test.go", Time: 0.75},
+		{Name: "docs/broken_test.go", Time: math.NaN()},
+	}}
+
+	tests := []struct {
+		name  string
+		depth int
+		top   int
+		want  []worker.DirTime
+	}{
+		{
+			name:  "depth 1",
+			depth: 1,
+			top:   10,
+			want: []worker.DirTime{
+				{Dir: "src", Time: 13, Tests: 5},
+				{Dir: "tools", Time: 1.25, Tests: 1},
+				{Dir: ".", Time: 0.75, Tests: 1},
+				{Dir: "docs", Time: 0, Tests: 1},
+			},
+		},
+		{
+			name:  "depth 2",
+			depth: 2,
+			top:   10,
+			want: []worker.DirTime{
+				{Dir: "src/api", Time: 7, Tests: 3},
+				{Dir: "src/web", Time: 6, Tests: 2},
+				{Dir: "tools", Time: 1.25, Tests: 1},
+				{Dir: ".", Time: 0.75, Tests: 1},
+				{Dir: "docs", Time: 0, Tests: 1},
+			},
+		},
+		{
+			name:  "depth 3 keeps the top, ties by name",
+			depth: 3,
+			top:   4,
+			want: []worker.DirTime{
+				{Dir: "src/api/v1", Time: 4, Tests: 1},
+				{Dir: "src/web", Time: 3, Tests: 1},
+				{Dir: "src/web/forms", Time: 3, Tests: 1},
+				{Dir: "src/api/v2", Time: 2.5, Tests: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitter.RollupDirectories(w, tt.depth, tt.top); !slices.Equal(got, tt.want) {
+				t.Errorf("RollupDirectories = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsReporter_Rollup(t *testing.T) {
+	workers := []worker.Worker{
+		{Tests: []junit.Test{{Name: "a/x/1_test.go", Time: 2}, {Name: "a/y/2_test.go", Time: 3}}, Total: 5},
+		{Tests: []junit.Test{{Name: "b/3_test.go", Time: 4}}, Total: 4},
+	}
+	stats := worker.Distribution{Workers: []worker.Stats{
+		{Index: 0, Total: 5, TestCount: 2},
+		{Index: 1, Total: 4, TestCount: 1},
+	}}
+
+	if got := splitter.NewStatsReporter(zerolog.Nop()).Rollup(stats, workers); got.Workers[0].Directories != nil {
+		t.Errorf("Rollup without a depth = %+v, want no directories", got.Workers[0].Directories)
+	}
+
+	var buf bytes.Buffer
+	reporter := splitter.NewStatsReporter(zerolog.New(&buf), splitter.WithRollup(splitter.Rollup{Depth: 1}))
+	got := reporter.Rollup(stats, workers)
+	if want := []worker.DirTime{{Dir: "a", Time: 5, Tests: 2}}; !slices.Equal(got.Workers[0].Directories, want) {
+		t.Errorf("Worker 0 directories = %+v, want %+v", got.Workers[0].Directories, want)
+	}
+	if stats.Workers[0].Directories != nil {
+		t.Error("Rollup modified its input")
+	}
+
+	reporter.PrintSummary(got, nil)
+	for _, want := range []string{
+		"Worker 0 top directories: a 5.000s (2 test files)", `"directories":{"b":4}`,
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("Output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestStatsReporter_WorkerDetails(t *testing.T) {
+	w := worker.Worker{Tests: []junit.Test{
+		{Name: "c_test.go", Time: 1, Source: junit.SourceStats},
+		{Name: "a_test.go", Time: 4, Source: junit.SourceStats},
+		{Name: "b_test.go", Time: 2, Source: junit.SourceDefault},
+		{Name: "d_test.go", Time: 1, Source: junit.SourceStats},
+	}, Total: 8}
+
+	want := []worker.TestTime{
+		{Name: "a_test.go", Time: 4, Cumulative: 4},
+		{Name: "b_test.go", Time: 2, Cumulative: 6},
+		{Name: "c_test.go", Time: 1, Cumulative: 7},
+		{Name: "d_test.go", Time: 1, Cumulative: 8},
+	}
+	if got := splitter.WorkerTests(w); !slices.Equal(got, want) {
+		t.Errorf("WorkerTests = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Level(zerolog.InfoLevel)
+	splitter.NewStatsReporter(logger).PrintWorkerDetails(0, &w)
+	if bytes.Contains(buf.Bytes(), []byte("Assigned test")) {
+		t.Errorf("Tests listed at info level without verbose details:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	reporter := splitter.NewStatsReporter(logger,
+		splitter.WithWorkerDetails(splitter.WorkerDetails{Verbose: true, Top: 2}))
+	reporter.PrintWorkerDetails(0, &w)
+	for _, want := range []string{
+		"Assigned test 1. a_test.go 4.000s, cumulative 4.000s (50.0%)",
+		"Assigned test 2. b_test.go 2.000s, cumulative 6.000s (75.0%)",
+		"Listed the 2 longest of 4 tests, 75.0% of the worker's time",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("Output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("c_test.go")) {
+		t.Errorf("Output lists tests beyond the top 2:\n%s", buf.String())
+	}
+
+	stats := worker.Distribution{Workers: []worker.Stats{{Index: 0, Total: 8, TestCount: 4}}}
+	if got := splitter.NewStatsReporter(zerolog.Nop()).Details(stats, []worker.Worker{w}); got.Workers[0].Tests != nil {
+		t.Errorf("Details without verbose = %+v, want no tests", got.Workers[0].Tests)
+	}
+	got := reporter.Details(stats, []worker.Worker{w})
+	if !slices.Equal(got.Workers[0].Tests, want) {
+		t.Errorf("Details = %+v, want every test %+v", got.Workers[0].Tests, want)
+	}
+	if stats.Workers[0].Tests != nil {
+		t.Error("Details modified its input")
+	}
+}
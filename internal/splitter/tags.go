@@ -0,0 +1,159 @@
+package splitter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+var (
+	// ErrMetadata is returned by ReadMetadata for input that is not a JSON object of tag lists.
+	ErrMetadata = errors.New("invalid metadata")
+	// ErrInvalidWeight is returned for a malformed weight rule.
+	ErrInvalidWeight = errors.New("invalid weight")
+)
+
+// tagPrefix marks a weight rule matching tags rather than test names.
+const tagPrefix = "tag:"
+
+// Metadata holds the tags of tests by normalized key, see ReadMetadata.
+type Metadata map[string][]string
+
+// ReadMetadata reads the tags of tests from a JSON object mapping test names, e.g. fully
+// qualified class names, to lists of arbitrary string tags:
+//
+//	{"com.acme.FooTest": ["slow", "integration"], "com.acme.BarTest": ["unit"]}
+//
+// Names are normalized like those of the test list and matched exactly.
+func (s *Splitter) ReadMetadata(r io.Reader) (Metadata, error) {
+	var raw map[string][]string
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMetadata, err)
+	}
+	meta := make(Metadata, len(raw))
+	for name, tags := range raw {
+		key := s.normalizer.Key(name)
+		meta[key] = append(meta[key], tags...)
+	}
+	return meta, nil
+}
+
+// Tags returns the tags of test, along with those of its members for a group.
+func (m Metadata) Tags(test junit.Test) []string {
+	if test.Group == nil {
+		return m[test.Key]
+	}
+	tags := slices.Clone(m[test.Key])
+	for _, member := range test.Group.Members {
+		tags = append(tags, m[member.Key]...)
+	}
+	return tags
+}
+
+// TagFilter selects tests by their tags. Patterns are globs, see fileutil.MatchGlob.
+type TagFilter struct {
+	Only    []string // Keep only the tests with a tag matching one of these, every test when empty
+	Exclude []string // Drop the tests with a tag matching one of these
+}
+
+// Filter returns the tests the filter keeps, in order. Tests without tags are dropped when
+// f.Only is set, and kept otherwise.
+func (m Metadata) Filter(tests []junit.Test, f TagFilter) []junit.Test {
+	kept := make([]junit.Test, 0, len(tests))
+	for _, test := range tests {
+		tags := m.Tags(test)
+		if len(f.Only) > 0 && !matchAnyTag(f.Only, tags) {
+			continue
+		}
+		if matchAnyTag(f.Exclude, tags) {
+			continue
+		}
+		kept = append(kept, test)
+	}
+	return kept
+}
+
+// matchAnyTag reports whether any of tags matches any of patterns.
+func matchAnyTag(patterns, tags []string) bool {
+	for _, pattern := range patterns {
+		for _, tag := range tags {
+			if fileutil.MatchGlob(pattern, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WeightRule multiplies the time of the tests matching Pattern by Factor, e.g. to account for
+// tests known to run slower on CI than their reports say.
+type WeightRule struct {
+	Pattern string // Glob matching test names, or their tags when Tag is set
+	Factor  float64
+	Tag     bool
+}
+
+// ParseWeightRule parses a rule written as "PATTERN=FACTOR" matching test names, e.g.
+// "tests/e2e/**=1.5", or "tag:PATTERN=FACTOR" matching their tags, e.g. "tag:integration=2".
+// The factor follows the last "=" and must be a positive number.
+func ParseWeightRule(s string) (WeightRule, error) {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return WeightRule{}, fmt.Errorf("%w rule %q: want PATTERN=FACTOR or tag:PATTERN=FACTOR", ErrInvalidWeight, s)
+	}
+	rule := WeightRule{Pattern: strings.TrimSpace(s[:i])}
+	if pattern, ok := strings.CutPrefix(rule.Pattern, tagPrefix); ok {
+		rule.Pattern, rule.Tag = pattern, true
+	}
+	factor, err := strconv.ParseFloat(strings.TrimSpace(s[i+1:]), 64)
+	if err != nil || factor <= 0 || math.IsInf(factor, 1) || rule.Pattern == "" {
+		return WeightRule{}, fmt.Errorf("%w rule %q: want a pattern and a positive factor", ErrInvalidWeight, s)
+	}
+	rule.Factor = factor
+	return rule, nil
+}
+
+// ParseWeightRules parses every rule with ParseWeightRule.
+func ParseWeightRules(raw []string) ([]WeightRule, error) {
+	rules := make([]WeightRule, 0, len(raw))
+	for _, s := range raw {
+		rule, err := ParseWeightRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ApplyWeights multiplies the time of every test by the factor of the first rule matching its
+// name or, for tag rules, one of its tags. Other tests keep their time. It returns the number of
+// tests weighted.
+func (m Metadata) ApplyWeights(tests []junit.Test, rules []WeightRule) int {
+	weighted := 0
+	for i := range tests {
+		for _, rule := range rules {
+			if rule.matches(tests[i], m) {
+				tests[i].Time *= rule.Factor
+				weighted++
+				break
+			}
+		}
+	}
+	return weighted
+}
+
+func (r WeightRule) matches(test junit.Test, m Metadata) bool {
+	if r.Tag {
+		return matchAnyTag([]string{r.Pattern}, m.Tags(test))
+	}
+	return fileutil.MatchGlob(r.Pattern, test.Name)
+}
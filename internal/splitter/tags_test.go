@@ -0,0 +1,170 @@
+package splitter_test
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/splitter"
+)
+
+// jvmTests lists the classes of testdata/metadata/tags.json and one without tags.
+const jvmTests = "com.acme.billing.InvoiceTest\ncom.acme.billing.InvoiceIT\ncom.acme.search.IndexIT\n" +
+	"com.acme.search.QueryTest\ncom.acme.legacy.ReportTest\ncom.acme.util.StringsTest\n"
+
+func readMetadata(t *testing.T, s *splitter.Splitter) splitter.Metadata {
+	t.Helper()
+	f, err := os.Open("../../testdata/metadata/tags.json")
+	if err != nil {
+		t.Fatalf("Failed to open metadata: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	meta, err := s.ReadMetadata(f)
+	if err != nil {
+		t.Fatalf("ReadMetadata failed: %v", err)
+	}
+	return meta
+}
+
+func testNames(tests []junit.Test) []string {
+	names := make([]string, len(tests))
+	for i, test := range tests {
+		names[i] = strings.TrimPrefix(test.Name, "com.acme.")
+	}
+	return names
+}
+
+func TestMetadata_Filter(t *testing.T) {
+	s := splitter.NewSplitter(zerolog.Nop())
+	meta := readMetadata(t, s)
+	tests, err := s.ReadTests(strings.NewReader(jvmTests), nil)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		filter splitter.TagFilter
+		want   []string
+	}{
+		{
+			name:   "none",
+			filter: splitter.TagFilter{},
+			want: []string{
+				"billing.InvoiceTest", "billing.InvoiceIT", "search.IndexIT",
+				"search.QueryTest", "legacy.ReportTest", "util.StringsTest",
+			},
+		},
+		{
+			name:   "exclude",
+			filter: splitter.TagFilter{Exclude: []string{"slow"}},
+			want:   []string{"billing.InvoiceTest", "search.IndexIT", "search.QueryTest", "util.StringsTest"},
+		},
+		{
+			name:   "only",
+			filter: splitter.TagFilter{Only: []string{"unit"}},
+			want:   []string{"billing.InvoiceTest", "search.QueryTest"},
+		},
+		{
+			name:   "only and exclude",
+			filter: splitter.TagFilter{Only: []string{"integration"}, Exclude: []string{"slow"}},
+			want:   []string{"search.IndexIT"},
+		},
+		{
+			name:   "several only tags",
+			filter: splitter.TagFilter{Only: []string{"db", "flaky"}},
+			want:   []string{"search.IndexIT", "search.QueryTest"},
+		},
+		{
+			name:   "glob exclude",
+			filter: splitter.TagFilter{Exclude: []string{"fl*"}},
+			want: []string{
+				"billing.InvoiceTest", "billing.InvoiceIT", "search.IndexIT", "legacy.ReportTest", "util.StringsTest",
+			},
+		},
+		{
+			name:   "glob only and exclude",
+			filter: splitter.TagFilter{Only: []string{"*"}, Exclude: []string{"s?ow", "d*"}},
+			want:   []string{"billing.InvoiceTest", "search.QueryTest"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testNames(meta.Filter(tests, tt.filter)); !slices.Equal(got, tt.want) {
+				t.Errorf("Filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("groups", func(t *testing.T) {
+		grouped := splitter.NewSplitter(zerolog.Nop(), splitter.WithInputFormat(splitter.InputGroups))
+		input := "//billing\tcom.acme.billing.InvoiceTest,com.acme.billing.InvoiceIT\n" +
+			"//search\tcom.acme.search.QueryTest\n"
+		groups, err := grouped.ReadTests(strings.NewReader(input), nil)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		// A group carries the tags of its members
+		got := testNames(meta.Filter(groups, splitter.TagFilter{Exclude: []string{"slow"}}))
+		if want := []string{"//search"}; !slices.Equal(got, want) {
+			t.Errorf("Filter = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestMetadata_ApplyWeights(t *testing.T) {
+	s := splitter.NewSplitter(zerolog.Nop())
+	meta := readMetadata(t, s)
+	rules, err := splitter.ParseWeightRules([]string{"tag:integration=2", "com.acme.legacy.*=1.5", "tag:unit=0.5"})
+	if err != nil {
+		t.Fatalf("ParseWeightRules failed: %v", err)
+	}
+	tests, err := s.ReadTests(strings.NewReader(jvmTests), nil)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+
+	if n := meta.ApplyWeights(tests, rules); n != 5 {
+		t.Errorf("ApplyWeights weighted %d tests, want 5", n)
+	}
+	// The first matching rule applies: QueryTest is unit, InvoiceIT integration
+	want := map[string]float64{
+		"billing.InvoiceTest": 0.5, "billing.InvoiceIT": 2, "search.IndexIT": 2,
+		"search.QueryTest": 0.5, "legacy.ReportTest": 1.5, "util.StringsTest": 1,
+	}
+	for i, name := range testNames(tests) {
+		if got := tests[i].Time / splitter.DefaultTestTime; got != want[name] {
+			t.Errorf("%s: weighted by %g, want %g", name, got, want[name])
+		}
+	}
+}
+
+func TestParseWeightRule(t *testing.T) {
+	rule, err := splitter.ParseWeightRule(" tag:int*= 2.5")
+	if err != nil || rule != (splitter.WeightRule{Pattern: "int*", Factor: 2.5, Tag: true}) {
+		t.Errorf("ParseWeightRule = %+v, %v", rule, err)
+	}
+	rule, err = splitter.ParseWeightRule("a=b/**=3")
+	if err != nil || rule != (splitter.WeightRule{Pattern: "a=b/**", Factor: 3}) {
+		t.Errorf("ParseWeightRule = %+v, %v", rule, err)
+	}
+	for _, s := range []string{"slow", "=2", "tag:=2", "tests/**=0", "tests/**=-1", "tests/**=x", "tests/**=+Inf"} {
+		if _, err := splitter.ParseWeightRule(s); !errors.Is(err, splitter.ErrInvalidWeight) {
+			t.Errorf("ParseWeightRule(%q) error = %v, want ErrInvalidWeight", s, err)
+		}
+	}
+}
+
+func TestSplitter_ReadMetadataInvalid(t *testing.T) {
+	for _, input := range []string{`["slow"]`, `{"a": "slow"}`, `{"a": [1]}`, `{`} {
+		if _, err := splitter.NewSplitter(zerolog.Nop()).ReadMetadata(strings.NewReader(input)); !errors.Is(
+			err, splitter.ErrMetadata) {
+			t.Errorf("ReadMetadata(%s) error = %v, want ErrMetadata", input, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // Content-MD5 integrity check, not a security boundary
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// httpBucket is a read-only Bucket serving the reports of a web server, e.g. an artifact host.
+// Keys are URL paths, including any query string, relative to the host.
+type httpBucket struct {
+	client *http.Client
+	base   string
+}
+
+// openHTTP opens the host of an http:// or https:// location.
+func openHTTP(loc Location) *httpBucket {
+	return &httpBucket{client: http.DefaultClient, base: loc.Scheme + "://" + loc.Bucket + "/"}
+}
+
+func (b *httpBucket) Put(context.Context, string, []byte, string) error {
+	return fmt.Errorf("%w: cannot upload to %s", ErrUnsupportedURL, b.base)
+}
+
+// Checksum returns the MD5 digest announced by the Content-MD5 or x-goog-hash header, empty when
+// the server sends neither.
+func (b *httpBucket) Checksum(ctx context.Context, key string) (string, error) {
+	obj, found, err := b.head(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("%s%s: %w", b.base, key, ErrNotFound)
+	}
+	return obj.Checksum, nil
+}
+
+// List returns the single object named by prefix, since web servers cannot be listed,
+// or nothing when it does not exist.
+func (b *httpBucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	obj, found, err := b.head(ctx, prefix)
+	if err != nil || !found {
+		return nil, err
+	}
+	return []Object{obj}, nil
+}
+
+func (b *httpBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s%s: %w", b.base, key, ErrNotFound)
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("cannot get %s%s: %s", b.base, key, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s%s: %w", b.base, key, err)
+	}
+	return data, nil
+}
+
+func (b *httpBucket) Close() error {
+	return nil
+}
+
+// head describes the object under key from a HEAD request. A server rejecting HEAD requests
+// yields an object without checksum, leaving the decision to Get.
+func (b *httpBucket) head(ctx context.Context, key string) (Object, bool, error) {
+	resp, err := b.do(ctx, http.MethodHead, key)
+	if err != nil {
+		return Object{}, false, err
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return Object{}, false, nil
+	case resp.StatusCode/100 != 2:
+		return Object{Key: key, Size: -1}, true, nil
+	}
+	return Object{Key: key, Checksum: headerChecksum(resp.Header), Size: resp.ContentLength}, true, nil
+}
+
+// do sends a request for the object under key.
+func (b *httpBucket) do(ctx context.Context, method, key string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.base+key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request: %w", err)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s%s: %w", b.base, key, err)
+	}
+	return resp, nil
+}
+
+// headerChecksum returns the hex-encoded MD5 digest of a Content-MD5 header, or of the md5 entry
+// of a Google Cloud Storage x-goog-hash header, empty when neither is present or valid.
+func headerChecksum(h http.Header) string {
+	if sum := decodeMD5(h.Get("Content-MD5")); sum != "" {
+		return sum
+	}
+	for _, value := range h.Values("X-Goog-Hash") {
+		for entry := range strings.SplitSeq(value, ",") {
+			if digest, ok := strings.CutPrefix(strings.TrimSpace(entry), "md5="); ok {
+				return decodeMD5(digest)
+			}
+		}
+	}
+	return ""
+}
+
+// decodeMD5 converts a base64-encoded MD5 digest to hex, returning "" for anything else.
+func decodeMD5(b64 string) string {
+	sum, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil || len(sum) != md5.Size {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec // Content-MD5 integrity check, not a security boundary
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// s3Bucket is a Bucket backed by Amazon S3.
+type s3Bucket struct {
+	client *s3.Client
+	name   string
+}
+
+// openS3 opens an S3 bucket with the default AWS configuration (environment, shared files, instance role).
+func openS3(ctx context.Context, name string) (*s3Bucket, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load AWS configuration: %w", err)
+	}
+	return &s3Bucket{client: s3.NewFromConfig(cfg), name: name}, nil
+}
+
+func (b *s3Bucket) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	sum := md5.Sum(data) //nolint:gosec // See import
+	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(b.name),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+		ContentMD5:  aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot put s3://%s/%s: %w", b.name, key, err)
+	}
+	return nil
+}
+
+// Checksum returns the MD5 digest given by the object's ETag, empty when it was uploaded in parts.
+func (b *s3Bucket) Checksum(ctx context.Context, key string) (string, error) {
+	out, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.name),
+		Key:    aws.String(key),
+	})
+	if isS3NotFound(err) {
+		return "", fmt.Errorf("s3://%s/%s: %w", b.name, key, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot stat s3://%s/%s: %w", b.name, key, err)
+	}
+	return etagChecksum(out.ETag), nil
+}
+
+func (b *s3Bucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	pages := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.name),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list s3://%s/%s: %w", b.name, prefix, err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, Object{
+				Key:      aws.ToString(obj.Key),
+				Checksum: etagChecksum(obj.ETag),
+				Size:     aws.ToInt64(obj.Size),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (b *s3Bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.name),
+		Key:    aws.String(key),
+	})
+	if isS3NotFound(err) {
+		return nil, fmt.Errorf("s3://%s/%s: %w", b.name, key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get s3://%s/%s: %w", b.name, key, err)
+	}
+	defer func() { _ = out.Body.Close() }()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read s3://%s/%s: %w", b.name, key, err)
+	}
+	return data, nil
+}
+
+func (b *s3Bucket) Close() error {
+	return nil
+}
+
+// isS3NotFound reports whether err means the object does not exist.
+func isS3NotFound(err error) bool {
+	var respErr interface{ HTTPStatusCode() int }
+	var apiErr smithy.APIError
+	return (errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound) ||
+		(errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey"))
+}
+
+// etagChecksum returns the MD5 digest an ETag stands for. Multipart ETags ("<md5>-<parts>")
+// are no digest of the content and yield an empty checksum.
+func etagChecksum(etag *string) string {
+	sum := strings.Trim(aws.ToString(etag), `"`)
+	if strings.Contains(sum, "-") {
+		return ""
+	}
+	return sum
+}
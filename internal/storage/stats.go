@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // Compared with the stores' MD5 checksums, not used for security
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+)
+
+const (
+	DefaultDownloadRetries = 2 // Default number of downloads repeated after a failed integrity check
+
+	// downloadsDir is the subdirectory of the cache directory holding downloaded reports.
+	downloadsDir = "objects"
+)
+
+// Results of the integrity check of a downloaded report, as logged.
+const (
+	checkVerified     = "verified"     // The content matched every known checksum
+	checkUnverifiable = "unverifiable" // Neither the store nor the caller provided a checksum
+	checkFailed       = "failed"       // The content did not match a checksum
+)
+
+// FileLoader parses local JUnit XML reports, like junit.Parser.LoadFiles.
+type FileLoader func(ctx context.Context, paths []string) (map[string]float64, error)
+
+// StatsSource loads test times from the JUnit XML reports stored in a bucket.
+//
+// The key part of its URL selects the reports:
+//
+//   - a key with glob metacharacters is matched against whole object keys with path.Match:
+//     "*" and "?" never match "/", so reports/*.xml does not select reports/nested/a.xml;
+//   - a key ending in "/" selects every .xml object beneath that prefix, at any depth;
+//   - any other key selects the single object with exactly that key.
+//
+// An http:// or https:// URL names a single report, since web servers cannot be listed.
+//
+// Matching objects are downloaded and parsed with a FileLoader. Each download is checked against
+// the MD5 checksum of the store (Content-MD5 or x-goog-hash for web servers) and the SHA-256
+// checksum given with WithSHA256, and downloaded again on a mismatch.
+type StatsSource struct {
+	logger   zerolog.Logger
+	open     Opener
+	load     FileLoader
+	url      string
+	cacheDir string
+	sha256   string
+	retries  int
+}
+
+// SourceOption configures a StatsSource.
+type SourceOption func(*StatsSource)
+
+// WithCacheDir keeps downloaded reports in dir, so objects whose checksum did not change
+// are not downloaded again. An empty dir downloads to a temporary directory removed after loading.
+func WithCacheDir(dir string) SourceOption {
+	return func(s *StatsSource) {
+		s.cacheDir = dir
+	}
+}
+
+// WithLogger sets the logger receiving the integrity check result of every download.
+func WithLogger(logger zerolog.Logger) SourceOption {
+	return func(s *StatsSource) {
+		s.logger = logger
+	}
+}
+
+// WithSHA256 checks the report selected by the URL against a hex-encoded SHA-256 digest.
+// Load then fails with ErrInvalidChecksum unless the URL selects exactly one report.
+func WithSHA256(digest string) SourceOption {
+	return func(s *StatsSource) {
+		s.sha256 = strings.ToLower(digest)
+	}
+}
+
+// WithDownloadRetries sets how many times a report failing its integrity check is downloaded
+// again. Negative values keep the default.
+func WithDownloadRetries(n int) SourceOption {
+	return func(s *StatsSource) {
+		if n >= 0 {
+			s.retries = n
+		}
+	}
+}
+
+// NewStatsSource returns a source loading the reports selected by url, an s3:// or gs:// URL
+// whose key may be a pattern, or an http(s):// URL. Buckets are opened with open and reports
+// parsed with load.
+func NewStatsSource(url string, open Opener, load FileLoader, opts ...SourceOption) *StatsSource {
+	s := &StatsSource{logger: zerolog.Nop(), open: open, load: load, url: url, retries: DefaultDownloadRetries}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load downloads the matching reports and parses them.
+// It fails with ErrNoObjectsMatched when the pattern selects no object, and with
+// ErrChecksumMismatch when a report fails its integrity check after every retry.
+func (s *StatsSource) Load(ctx context.Context) (map[string]float64, error) {
+	if err := ValidateSHA256(s.sha256); s.sha256 != "" && err != nil {
+		return nil, err
+	}
+	loc, err := ParseStatsURL(s.url)
+	if err != nil {
+		return nil, err
+	}
+	prefix := loc.Key
+	if !loc.IsHTTP() {
+		if _, err = path.Match(loc.Key, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q: %w", loc.Key, err)
+		}
+		prefix = keyPrefix(loc.Key)
+	}
+
+	bucket, err := s.open(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = bucket.Close() }()
+
+	listed, err := bucket.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, obj := range listed {
+		if loc.IsHTTP() || MatchKey(loc.Key, obj.Key) {
+			objects = append(objects, obj)
+		}
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoObjectsMatched, s.url)
+	}
+	if s.sha256 != "" && len(objects) > 1 {
+		return nil, fmt.Errorf("%w: %s selects %d reports, a digest applies to one", ErrInvalidChecksum, s.url,
+			len(objects))
+	}
+
+	dir := filepath.Join(s.cacheDir, downloadsDir)
+	if s.cacheDir == "" {
+		if dir, err = os.MkdirTemp("", "tests-helper-stats-*"); err != nil {
+			return nil, fmt.Errorf("cannot create download directory: %w", err)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+	}
+
+	paths := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		file, downloadErr := s.download(ctx, bucket, loc, obj, dir)
+		if downloadErr != nil {
+			return nil, downloadErr
+		}
+		paths = append(paths, file)
+	}
+	return s.load(ctx, paths)
+}
+
+// Describe returns "junit:" followed by the URL.
+func (s *StatsSource) Describe() string {
+	return "junit:" + s.url
+}
+
+// download stores obj in dir and returns its path. A cached copy with the same checksum is reused.
+// A download failing its integrity check is repeated up to the configured number of retries.
+func (s *StatsSource) download(
+	ctx context.Context, bucket Bucket, loc Location, obj Object, dir string,
+) (string, error) {
+	url := Location{Scheme: loc.Scheme, Bucket: loc.Bucket, Key: obj.Key}.String()
+	id := sha256.Sum256([]byte(url + "|" + obj.Checksum + "|" + s.sha256))
+	name, _, _ := strings.Cut(obj.Key, "?")
+	file := filepath.Join(dir, hex.EncodeToString(id[:8])+"-"+path.Base(name))
+	if obj.Checksum != "" {
+		if info, err := os.Stat(file); err == nil && info.Size() == obj.Size {
+			return file, nil
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		data, err := bucket.Get(ctx, obj.Key)
+		if err != nil {
+			return "", err
+		}
+		check, err := s.verify(obj, data)
+		ev := s.logger.Info()
+		if err != nil {
+			ev = s.logger.Warn().Err(err)
+		}
+		ev.Str("url", url).
+			Str("check", check).
+			Int("attempt", attempt+1).
+			Msg("Checked stats download")
+		if err != nil {
+			if attempt < s.retries {
+				continue
+			}
+			return "", fmt.Errorf("%s after %d attempts: %w", url, attempt+1, err)
+		}
+		if err = fileutil.WriteAtomic(file, data); err != nil {
+			return "", fmt.Errorf("cannot store %s: %w", url, err)
+		}
+		return file, nil
+	}
+}
+
+// verify checks data against the MD5 checksum of obj and the SHA-256 digest of the source,
+// returning the check result and, when it failed, an error wrapping ErrChecksumMismatch.
+func (s *StatsSource) verify(obj Object, data []byte) (string, error) {
+	if s.sha256 != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != s.sha256 {
+			return checkFailed, fmt.Errorf("%w: SHA-256 is %s, want %s", ErrChecksumMismatch, got, s.sha256)
+		}
+	}
+	if obj.Checksum != "" {
+		sum := md5.Sum(data) //nolint:gosec // See import
+		if got := hex.EncodeToString(sum[:]); got != obj.Checksum {
+			return checkFailed, fmt.Errorf("%w: MD5 is %s, want %s", ErrChecksumMismatch, got, obj.Checksum)
+		}
+	}
+	if s.sha256 == "" && obj.Checksum == "" {
+		return checkUnverifiable, nil
+	}
+	return checkVerified, nil
+}
+
+// ValidateSHA256 checks that digest is a hex-encoded SHA-256 digest.
+func ValidateSHA256(digest string) error {
+	if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("%w: %q", ErrInvalidChecksum, digest)
+	}
+	return nil
+}
+
+// MatchKey reports whether the object key is selected by pattern. See StatsSource.
+func MatchKey(pattern, key string) bool {
+	switch {
+	case hasMeta(pattern):
+		ok, err := path.Match(pattern, key)
+		return err == nil && ok
+	case strings.HasSuffix(pattern, "/"):
+		return strings.HasPrefix(key, pattern) && strings.HasSuffix(strings.ToLower(key), ".xml")
+	default:
+		return key == pattern
+	}
+}
+
+// keyPrefix returns the literal part of pattern before its first glob metacharacter,
+// the prefix every matching key starts with.
+func keyPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// hasMeta reports whether pattern contains glob metacharacters.
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
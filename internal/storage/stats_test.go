@@ -0,0 +1,283 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/storage"
+)
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{pattern: "reports/*.xml", key: "reports/a.xml", want: true},
+		{pattern: "reports/*.xml", key: "reports/nested/a.xml", want: false},
+		{pattern: "reports/*.xml", key: "reports/a.json", want: false},
+		{pattern: "reports/*/*.xml", key: "reports/nested/a.xml", want: true},
+		{pattern: "reports/junit-?.xml", key: "reports/junit-1.xml", want: true},
+		{pattern: "reports/junit-[0-9].xml", key: "reports/junit-x.xml", want: false},
+		{pattern: "reports/", key: "reports/nested/a.XML", want: true},
+		{pattern: "reports/", key: "reports/a.json", want: false},
+		{pattern: "reports/", key: "reports-old/a.xml", want: false},
+		{pattern: "reports/a.xml", key: "reports/a.xml", want: true},
+		{pattern: "reports/a.xml", key: "reports/a.xml.bak", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := storage.MatchKey(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("MatchKey(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+// recordingLoader returns a FileLoader remembering the base names of the files it was given.
+func recordingLoader(loaded *[]string) storage.FileLoader {
+	return func(_ context.Context, paths []string) (map[string]float64, error) {
+		times := make(map[string]float64)
+		for _, file := range paths {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			times[string(data)]++
+			*loaded = append(*loaded, filepath.Base(file))
+		}
+		return times, nil
+	}
+}
+
+func TestStatsSource_Load(t *testing.T) {
+	bucket := newFakeBucket()
+	bucket.objects["reports/a.xml"] = []byte("a")
+	bucket.objects["reports/b.xml"] = []byte("b")
+	bucket.objects["reports/nested/c.xml"] = []byte("c")
+	bucket.objects["other/d.xml"] = []byte("d")
+
+	var opened storage.Location
+	open := func(_ context.Context, loc storage.Location) (storage.Bucket, error) {
+		opened = loc
+		return bucket, nil
+	}
+
+	var loaded []string
+	cacheDir := t.TempDir()
+	source := storage.NewStatsSource("gs://ci/reports/*.xml", open, recordingLoader(&loaded),
+		storage.WithCacheDir(cacheDir))
+	if got := source.Describe(); got != "junit:gs://ci/reports/*.xml" {
+		t.Errorf("Describe() = %q", got)
+	}
+
+	for range 2 {
+		times, err := source.Load(t.Context())
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(times) != 2 || times["a"] != 1 || times["b"] != 1 {
+			t.Errorf("Load() = %v, want the two top-level reports", times)
+		}
+	}
+	if opened.Bucket != "ci" {
+		t.Errorf("Opened bucket %q", opened.Bucket)
+	}
+	// The second load reuses the cached downloads
+	if bucket.gets != 2 {
+		t.Errorf("Gets = %d, want 2", bucket.gets)
+	}
+	if len(loaded) != 4 || !slices.Equal(loaded[:2], loaded[2:]) {
+		t.Errorf("Loaded files %v, want the same two files twice", loaded)
+	}
+
+	// Changed content is downloaded again
+	bucket.objects["reports/a.xml"] = []byte("A")
+	if _, err := source.Load(t.Context()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if bucket.gets != 3 {
+		t.Errorf("Gets = %d, want only the changed object downloaded", bucket.gets)
+	}
+}
+
+func TestStatsSource_TemporaryDownloads(t *testing.T) {
+	bucket := newFakeBucket()
+	bucket.objects["reports/nested/c.xml"] = []byte("c")
+	open := func(context.Context, storage.Location) (storage.Bucket, error) { return bucket, nil }
+
+	var paths []string
+	load := func(_ context.Context, files []string) (map[string]float64, error) {
+		paths = files
+		return map[string]float64{}, nil
+	}
+	if _, err := storage.NewStatsSource("s3://ci/reports/", open, load).Load(t.Context()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(paths) != 1 {
+		t.Fatalf("Loaded %v, want one file", paths)
+	}
+	if _, err := os.Stat(paths[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Temporary download %s was not removed: %v", paths[0], err)
+	}
+}
+
+func TestStatsSource_Errors(t *testing.T) {
+	bucket := newFakeBucket()
+	bucket.objects["reports/a.xml"] = []byte("a")
+	errDenied := errors.New("access denied")
+	load := func(context.Context, []string) (map[string]float64, error) { return map[string]float64{}, nil }
+
+	tests := []struct {
+		name string
+		url  string
+		open storage.Opener
+		want error
+	}{
+		{name: "no match", url: "s3://ci/reports/*.json", want: storage.ErrNoObjectsMatched},
+		{name: "missing object", url: "s3://ci/reports/b.xml", want: storage.ErrNoObjectsMatched},
+		{name: "bad url", url: "s3://ci", want: storage.ErrUnsupportedURL},
+		{name: "bad pattern", url: "s3://ci/reports/[.xml", want: path.ErrBadPattern},
+		{
+			name: "no credentials", url: "s3://ci/reports/*.xml",
+			open: func(context.Context, storage.Location) (storage.Bucket, error) { return nil, errDenied },
+			want: errDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open := tt.open
+			if open == nil {
+				open = func(context.Context, storage.Location) (storage.Bucket, error) { return bucket, nil }
+			}
+			_, err := storage.NewStatsSource(tt.url, open, load).Load(t.Context())
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Load() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// flakyServer serves report at /junit.xml with its digest in header, truncated for the first
+// corrupt GET requests, and counts the GET requests.
+func flakyServer(t *testing.T, report []byte, header string, corrupt int) (*httptest.Server, *int) {
+	t.Helper()
+	sum := md5.Sum(report)
+	digest := base64.StdEncoding.EncodeToString(sum[:])
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/junit.xml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch header {
+		case "Content-MD5":
+			w.Header().Set("Content-MD5", digest)
+		case "X-Goog-Hash":
+			w.Header().Set("X-Goog-Hash", "crc32c=n03x6A==, md5="+digest)
+		}
+		if r.Method != http.MethodGet {
+			return
+		}
+		gets++
+		if gets <= corrupt {
+			_, _ = w.Write(report[:len(report)/2])
+			return
+		}
+		_, _ = w.Write(report)
+	}))
+	t.Cleanup(server.Close)
+	return server, &gets
+}
+
+func TestStatsSource_HTTPRetry(t *testing.T) {
+	report := []byte("<testsuite><testcase file='a_test.go' time='1'/></testsuite>")
+
+	for _, header := range []string{"Content-MD5", "X-Goog-Hash"} {
+		t.Run(header, func(t *testing.T) {
+			server, gets := flakyServer(t, report, header, 2)
+			var loaded []byte
+			load := func(_ context.Context, paths []string) (map[string]float64, error) {
+				var err error
+				loaded, err = os.ReadFile(paths[0])
+				return map[string]float64{}, err
+			}
+			var logs bytes.Buffer
+			source := storage.NewStatsSource(server.URL+"/junit.xml", storage.Open, load,
+				storage.WithLogger(zerolog.New(&logs)))
+
+			if _, err := source.Load(t.Context()); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if *gets != 3 || !bytes.Equal(loaded, report) {
+				t.Errorf("Downloaded %d times, loaded %q; want 3 downloads of the full report", *gets, loaded)
+			}
+			failed := strings.Count(logs.String(), `"check":"failed"`)
+			if failed != 2 || !strings.Contains(logs.String(), `"check":"verified"`) {
+				t.Errorf("Logs = %s, want two failed checks and a verified one", logs.String())
+			}
+		})
+	}
+}
+
+func TestStatsSource_ChecksumMismatch(t *testing.T) {
+	report := []byte("<testsuite/>")
+	load := func(context.Context, []string) (map[string]float64, error) { return map[string]float64{}, nil }
+
+	// Retries exhausted
+	server, gets := flakyServer(t, report, "Content-MD5", 5)
+	source := storage.NewStatsSource(server.URL+"/junit.xml", storage.Open, load, storage.WithDownloadRetries(1))
+	if _, err := source.Load(t.Context()); !errors.Is(err, storage.ErrChecksumMismatch) || *gets != 2 {
+		t.Errorf("Load error = %v after %d downloads, want ErrChecksumMismatch after 2", err, *gets)
+	}
+
+	// Without a header only the given SHA-256 digest is checked
+	sum := sha256.Sum256(report)
+	server, _ = flakyServer(t, report, "", 0)
+	var logs bytes.Buffer
+	source = storage.NewStatsSource(server.URL+"/junit.xml", storage.Open, load,
+		storage.WithSHA256(strings.ToUpper(hex.EncodeToString(sum[:]))), storage.WithLogger(zerolog.New(&logs)))
+	if _, err := source.Load(t.Context()); err != nil || !strings.Contains(logs.String(), `"check":"verified"`) {
+		t.Errorf("Load = %v, logs %s; want a verified download", err, logs.String())
+	}
+	logs.Reset()
+	source = storage.NewStatsSource(server.URL+"/junit.xml", storage.Open, load, storage.WithLogger(zerolog.New(&logs)))
+	if _, err := source.Load(t.Context()); err != nil || !strings.Contains(logs.String(), `"check":"unverifiable"`) {
+		t.Errorf("Load = %v, logs %s; want an unverifiable download", err, logs.String())
+	}
+
+	wrong := storage.NewStatsSource(server.URL+"/junit.xml", storage.Open, load,
+		storage.WithSHA256(strings.Repeat("0", 64)), storage.WithDownloadRetries(0))
+	if _, err := wrong.Load(t.Context()); !errors.Is(err, storage.ErrChecksumMismatch) {
+		t.Errorf("Load error = %v, want ErrChecksumMismatch", err)
+	}
+	missing := storage.NewStatsSource(server.URL+"/missing.xml", storage.Open, load)
+	if _, err := missing.Load(t.Context()); !errors.Is(err, storage.ErrNoObjectsMatched) {
+		t.Errorf("Load error = %v, want ErrNoObjectsMatched", err)
+	}
+
+	// A digest applies to a single report
+	bucket := newFakeBucket()
+	bucket.objects["reports/a.xml"] = []byte("a")
+	bucket.objects["reports/b.xml"] = []byte("b")
+	open := func(context.Context, storage.Location) (storage.Bucket, error) { return bucket, nil }
+	several := storage.NewStatsSource("s3://ci/reports/*.xml", open, load, storage.WithSHA256(strings.Repeat("0", 64)))
+	if _, err := several.Load(t.Context()); !errors.Is(err, storage.ErrInvalidChecksum) {
+		t.Errorf("Load error = %v, want ErrInvalidChecksum", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package storage accesses timing data kept in object storage (S3 and GCS) or on web servers.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// URL schemes of the supported object stores and web servers.
+const (
+	SchemeS3    = "s3"
+	SchemeGCS   = "gs"
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+)
+
+var (
+	// ErrUnsupportedURL is returned for URLs that are not s3://bucket/key or gs://bucket/key
+	// (or http(s)://host/path for stats), and for uploads to web servers.
+	ErrUnsupportedURL = errors.New("unsupported storage URL")
+	// ErrNotFound is returned by Bucket.Checksum and Bucket.Get when the object does not exist.
+	ErrNotFound = errors.New("object not found")
+	// ErrNoObjectsMatched is returned by StatsSource.Load when no object matches the URL's key pattern.
+	ErrNoObjectsMatched = errors.New("no objects matched the provided pattern")
+	// ErrChecksumMismatch is returned by StatsSource.Load when a report still fails its integrity
+	// check after every retry.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrInvalidChecksum is returned for a SHA-256 digest that is not 64 hex characters.
+	ErrInvalidChecksum = errors.New("invalid SHA-256 checksum")
+)
+
+// Location identifies an object: the store, the bucket, and the key within it.
+// For web servers, the bucket is the host and the key the path with its query string.
+type Location struct {
+	Scheme string
+	Bucket string
+	Key    string
+}
+
+// ParseURL parses an s3://bucket/key or gs://bucket/key URL.
+func ParseURL(raw string) (Location, error) {
+	scheme, rest, found := strings.Cut(raw, "://")
+	if !found || (scheme != SchemeS3 && scheme != SchemeGCS) {
+		return Location{}, fmt.Errorf("%w: %q (expected s3://bucket/key or gs://bucket/key)", ErrUnsupportedURL, raw)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" || key == "" {
+		return Location{}, fmt.Errorf("%w: %q has no bucket or key", ErrUnsupportedURL, raw)
+	}
+	return Location{Scheme: scheme, Bucket: bucket, Key: key}, nil
+}
+
+// ParseStatsURL parses a URL of stats reports: an object store URL accepted by ParseURL,
+// or an http(s)://host/path URL, whose path keeps its query string.
+func ParseStatsURL(raw string) (Location, error) {
+	scheme, rest, found := strings.Cut(raw, "://")
+	if !found || (scheme != SchemeHTTP && scheme != SchemeHTTPS) {
+		return ParseURL(raw)
+	}
+	host, key, _ := strings.Cut(rest, "/")
+	if host == "" || key == "" {
+		return Location{}, fmt.Errorf("%w: %q has no host or path", ErrUnsupportedURL, raw)
+	}
+	return Location{Scheme: scheme, Bucket: host, Key: key}, nil
+}
+
+// IsURL reports whether s is an s3://, gs://, or http(s):// URL rather than a local path or pattern.
+func IsURL(s string) bool {
+	scheme, _, found := strings.Cut(s, "://")
+	return found && slices.Contains([]string{SchemeS3, SchemeGCS, SchemeHTTP, SchemeHTTPS}, scheme)
+}
+
+// IsHTTP reports whether the location is on a web server rather than in an object store.
+func (l Location) IsHTTP() bool {
+	return l.Scheme == SchemeHTTP || l.Scheme == SchemeHTTPS
+}
+
+// String returns the location as a URL.
+func (l Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Key
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key      string
+	Checksum string // Hex-encoded MD5 digest (S3 ETag), empty when the store does not provide one
+	Size     int64
+}
+
+// Bucket is an object storage bucket.
+type Bucket interface {
+	io.Closer
+
+	// Put stores data under key with the given content type, replacing any existing object.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+
+	// Checksum returns the hex-encoded MD5 digest of the object stored under key,
+	// or ErrNotFound when there is none.
+	Checksum(ctx context.Context, key string) (string, error)
+
+	// List returns the objects whose keys start with prefix, in lexical key order.
+	List(ctx context.Context, prefix string) ([]Object, error)
+
+	// Get returns the content of the object stored under key, or ErrNotFound when there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Opener opens the bucket of a location.
+type Opener func(ctx context.Context, loc Location) (Bucket, error)
+
+// Open opens the bucket of loc with the official SDK of its store.
+// Credentials come from the standard environment, configuration file, and instance metadata chains.
+// Web servers are accessed with plain, unauthenticated requests and cannot be written to.
+func Open(ctx context.Context, loc Location) (Bucket, error) {
+	switch loc.Scheme {
+	case SchemeS3:
+		return openS3(ctx, loc.Bucket)
+	case SchemeGCS:
+		return openGCS(ctx, loc.Bucket)
+	case SchemeHTTP, SchemeHTTPS:
+		return openHTTP(loc), nil
+	default:
+		return nil, fmt.Errorf("%w: scheme %q", ErrUnsupportedURL, loc.Scheme)
+	}
+}
@@ -0,0 +1,202 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/storage"
+)
+
+// fakeBucket is an in-memory Bucket; its next failures calls fail.
+type fakeBucket struct {
+	objects      map[string][]byte
+	contentTypes map[string]string
+	puts         int
+	gets         int
+	checksums    int
+	failures     int
+	mu           sync.Mutex
+}
+
+var errUnavailable = errors.New("service unavailable")
+
+func newFakeBucket() *fakeBucket {
+	return &fakeBucket{objects: make(map[string][]byte), contentTypes: make(map[string]string)}
+}
+
+func (b *fakeBucket) fail() bool {
+	if b.failures > 0 {
+		b.failures--
+		return true
+	}
+	return false
+}
+
+func (b *fakeBucket) Put(_ context.Context, key string, data []byte, contentType string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.puts++
+	if b.fail() {
+		return errUnavailable
+	}
+	b.objects[key] = append([]byte(nil), data...)
+	b.contentTypes[key] = contentType
+	return nil
+}
+
+func (b *fakeBucket) Checksum(_ context.Context, key string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checksums++
+	if b.fail() {
+		return "", errUnavailable
+	}
+	data, ok := b.objects[key]
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (b *fakeBucket) List(_ context.Context, prefix string) ([]storage.Object, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail() {
+		return nil, errUnavailable
+	}
+	var objects []storage.Object
+	for key, data := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			sum := md5.Sum(data)
+			objects = append(objects, storage.Object{Key: key, Checksum: hex.EncodeToString(sum[:]), Size: int64(len(data))})
+		}
+	}
+	slices.SortFunc(objects, func(a, b storage.Object) int { return strings.Compare(a.Key, b.Key) })
+	return objects, nil
+}
+
+func (b *fakeBucket) Get(_ context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.gets++
+	if b.fail() {
+		return nil, errUnavailable
+	}
+	data, ok := b.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return data, nil
+}
+
+func (b *fakeBucket) Close() error {
+	return nil
+}
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    storage.Location
+		wantErr bool
+	}{
+		{
+			raw:  "s3://ci-timings/main/times.json",
+			want: storage.Location{Scheme: "s3", Bucket: "ci-timings", Key: "main/times.json"},
+		},
+		{
+			raw:  "gs://ci-timings/times.json",
+			want: storage.Location{Scheme: "gs", Bucket: "ci-timings", Key: "times.json"},
+		},
+		{raw: "s3://ci-timings", wantErr: true},
+		{raw: "s3://ci-timings/", wantErr: true},
+		{raw: "s3:///times.json", wantErr: true},
+		{raw: "https://example.com/times.json", wantErr: true},
+		{raw: "times.json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := storage.ParseURL(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, storage.ErrUnsupportedURL) {
+					t.Fatalf("ParseURL(%q) error = %v, want ErrUnsupportedURL", tt.raw, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseURL(%q) failed: %v", tt.raw, err)
+			}
+			if got != tt.want || got.String() != tt.raw {
+				t.Errorf("ParseURL(%q) = %+v (%s), want %+v", tt.raw, got, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploader_Upload(t *testing.T) {
+	bucket := newFakeBucket()
+	uploader := storage.NewUploader(zerolog.Nop(), storage.WithIfChanged(true), storage.WithBackoff(time.Millisecond))
+
+	uploaded, err := uploader.Upload(t.Context(), bucket, "times.json", []byte(`{"a_test.go": 1}`), "application/json")
+	if err != nil || !uploaded {
+		t.Fatalf("First upload = %v, %v; want uploaded", uploaded, err)
+	}
+	if got := bucket.contentTypes["times.json"]; got != "application/json" {
+		t.Errorf("Content type = %q", got)
+	}
+
+	uploaded, err = uploader.Upload(t.Context(), bucket, "times.json", []byte(`{"a_test.go": 1}`), "application/json")
+	if err != nil || uploaded {
+		t.Fatalf("Identical upload = %v, %v; want skipped", uploaded, err)
+	}
+
+	uploaded, err = uploader.Upload(t.Context(), bucket, "times.json", []byte(`{"a_test.go": 2}`), "application/json")
+	if err != nil || !uploaded {
+		t.Fatalf("Changed upload = %v, %v; want uploaded", uploaded, err)
+	}
+	if bucket.puts != 2 {
+		t.Errorf("Puts = %d, want 2", bucket.puts)
+	}
+}
+
+func TestUploader_Retries(t *testing.T) {
+	bucket := newFakeBucket()
+	bucket.failures = 2
+	uploader := storage.NewUploader(zerolog.Nop(), storage.WithRetries(2), storage.WithBackoff(time.Millisecond))
+
+	if _, err := uploader.Upload(t.Context(), bucket, "times.json", []byte("{}"), "application/json"); err != nil {
+		t.Fatalf("Upload failed despite retries: %v", err)
+	}
+	if bucket.puts != 3 {
+		t.Errorf("Puts = %d, want 3", bucket.puts)
+	}
+
+	bucket.failures = 3
+	_, err := uploader.Upload(t.Context(), bucket, "times.json", []byte("{}"), "application/json")
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("Upload error = %v, want the last failure once retries are exhausted", err)
+	}
+}
+
+func TestUploader_Cancelled(t *testing.T) {
+	bucket := newFakeBucket()
+	bucket.failures = 1
+	uploader := storage.NewUploader(zerolog.Nop(), storage.WithBackoff(time.Hour))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := uploader.Upload(ctx, bucket, "times.json", []byte("{}"), "application/json")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Upload error = %v, want context.Canceled while backing off", err)
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // Compared with the stores' MD5 checksums, not used for security
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultRetries = 3               // Default number of retries after a failed attempt
+	DefaultBackoff = 1 * time.Second // Default wait before the first retry, doubled for each further retry
+)
+
+// Uploader writes objects to a Bucket, retrying failed attempts with exponential backoff.
+type Uploader struct {
+	logger    zerolog.Logger
+	retries   int
+	backoff   time.Duration
+	ifChanged bool
+}
+
+// UploadOption configures an Uploader.
+type UploadOption func(*Uploader)
+
+// WithRetries sets how many times a failed attempt is retried. Negative values keep the default.
+func WithRetries(n int) UploadOption {
+	return func(u *Uploader) {
+		if n >= 0 {
+			u.retries = n
+		}
+	}
+}
+
+// WithBackoff sets the wait before the first retry. Values below 1 keep the default.
+func WithBackoff(d time.Duration) UploadOption {
+	return func(u *Uploader) {
+		if d > 0 {
+			u.backoff = d
+		}
+	}
+}
+
+// WithIfChanged skips the upload when the stored object already has the same content.
+func WithIfChanged(enabled bool) UploadOption {
+	return func(u *Uploader) {
+		u.ifChanged = enabled
+	}
+}
+
+// NewUploader creates an uploader.
+func NewUploader(logger zerolog.Logger, opts ...UploadOption) *Uploader {
+	u := &Uploader{
+		logger:  logger,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Upload writes data under key in bucket. It reports whether the object was written,
+// which is false when only changed content is uploaded and the stored object is identical.
+func (u *Uploader) Upload(
+	ctx context.Context, bucket Bucket, key string, data []byte, contentType string,
+) (bool, error) {
+	if u.ifChanged {
+		sum := md5.Sum(data) //nolint:gosec // See import
+		var stored string
+		err := u.retry(ctx, "checksum", func() error {
+			var err error
+			stored, err = bucket.Checksum(ctx, key)
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		if stored == hex.EncodeToString(sum[:]) {
+			u.logger.Info().Str("key", key).Msg("Stored object is unchanged, skipping upload")
+			return false, nil
+		}
+	}
+
+	if err := u.retry(ctx, "put", func() error {
+		return bucket.Put(ctx, key, data, contentType)
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// retry runs op until it succeeds, the retries are exhausted, or ctx is cancelled.
+func (u *Uploader) retry(ctx context.Context, name string, op func() error) error {
+	wait := u.backoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s interrupted: %w", name, ctx.Err())
+		}
+		if attempt >= u.retries {
+			return fmt.Errorf("%s failed after %d attempts: %w", name, attempt+1, err)
+		}
+
+		u.logger.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Dur("backoff", wait).
+			Msgf("Storage %s failed, retrying", name)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s interrupted: %w", name, ctx.Err())
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
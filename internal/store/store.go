@@ -0,0 +1,95 @@
+// Package store reads and writes timing stores: JSON files mapping test names to seconds.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ErrUnsupportedLocation is returned for store locations whose scheme cannot be accessed.
+var ErrUnsupportedLocation = errors.New("unsupported store location")
+
+// Read decodes a timing store of the form {"pkg/a_test.go": 5.2, ...}.
+// Negative times are rejected.
+func Read(r io.Reader) (map[string]float64, error) {
+	var times map[string]float64
+	if err := json.NewDecoder(r).Decode(&times); err != nil {
+		return nil, fmt.Errorf("cannot decode timing store: %w", err)
+	}
+	for name, t := range times {
+		if t < 0 {
+			return nil, fmt.Errorf("timing store entry %q has negative time %g", name, t)
+		}
+	}
+	if times == nil {
+		times = make(map[string]float64)
+	}
+	return times, nil
+}
+
+// Write encodes times as an indented timing store, with keys in sorted order.
+func Write(w io.Writer, times map[string]float64) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(times); err != nil {
+		return fmt.Errorf("cannot encode timing store: %w", err)
+	}
+	return nil
+}
+
+// Source loads test times from a timing store.
+type Source struct {
+	location string
+}
+
+// NewSource returns a source reading the store at location, a local path or file:// URL.
+func NewSource(location string) *Source {
+	return &Source{location: location}
+}
+
+// Load reads the store.
+// It fails with ErrUnsupportedLocation for locations other than local files.
+func (s *Source) Load(ctx context.Context) (map[string]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := localPath(s.location)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open timing store: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	return Read(f)
+}
+
+// Describe returns "store:" followed by the location.
+func (s *Source) Describe() string {
+	return "store:" + s.location
+}
+
+// localPath resolves a store location to a file path.
+func localPath(location string) (string, error) {
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		return location, nil
+	}
+	if scheme != "file" {
+		return "", fmt.Errorf("%w: %s (only local paths and file:// URLs are supported)",
+			ErrUnsupportedLocation, location)
+	}
+	u, err := url.Parse(location)
+	if err != nil || rest == "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedLocation, location)
+	}
+	return u.Path, nil
+}
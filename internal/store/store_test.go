@@ -0,0 +1,78 @@
+package store_test
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/store"
+)
+
+func TestReadWrite_RoundTrip(t *testing.T) {
+	want := map[string]float64{"pkg/b_test.go": 1.5, "pkg/a_test.go": 5.2}
+
+	var buf bytes.Buffer
+	if err := store.Write(&buf, want); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got := buf.String(); got != "{\n  \"pkg/a_test.go\": 5.2,\n  \"pkg/b_test.go\": 1.5\n}\n" {
+		t.Errorf("Write produced %q, want sorted indented keys", got)
+	}
+
+	got, err := store.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("Read = %v, want %v", got, want)
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"not json":      "pkg/a_test.go 5",
+		"not a map":     `[1, 2]`,
+		"string value":  `{"a_test.go": "5"}`,
+		"negative time": `{"a_test.go": -1}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Read(strings.NewReader(input)); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+
+	times, err := store.Read(strings.NewReader("null"))
+	if err != nil || times == nil || len(times) != 0 {
+		t.Errorf("Read(null) = %v, %v; want an empty map", times, err)
+	}
+}
+
+func TestSource_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "times.json")
+	if err := os.WriteFile(path, []byte(`{"a_test.go": 3}`), 0o600); err != nil {
+		t.Fatalf("Failed to write store: %v", err)
+	}
+
+	for _, location := range []string{path, "file://" + path} {
+		times, err := store.NewSource(location).Load(t.Context())
+		if err != nil {
+			t.Fatalf("Load(%s) failed: %v", location, err)
+		}
+		if times["a_test.go"] != 3 {
+			t.Errorf("Load(%s) = %v", location, times)
+		}
+	}
+
+	_, err := store.NewSource("s3://bucket/times.json").Load(t.Context())
+	if !errors.Is(err, store.ErrUnsupportedLocation) {
+		t.Errorf("Got %v, want ErrUnsupportedLocation", err)
+	}
+	if got := store.NewSource(path).Describe(); got != "store:"+path {
+		t.Errorf("Describe() = %q", got)
+	}
+}
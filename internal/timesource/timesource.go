@@ -0,0 +1,157 @@
+// Package timesource combines test times from any number of providers into one map.
+package timesource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/normalize"
+)
+
+// Source provides historical test times keyed by test name.
+type Source interface {
+	// Load returns the times known to the source. It should stop early and return
+	// the context's error when ctx is cancelled.
+	Load(ctx context.Context) (map[string]float64, error)
+	// Describe identifies the source in logs and errors, e.g. "junit:reports/*.xml".
+	Describe() string
+}
+
+// Loader loads and merges sources.
+type Loader struct {
+	logger     zerolog.Logger
+	normalizer normalize.Normalizer
+	strict     bool
+	priority   bool
+}
+
+// Option configures a Loader.
+type Option func(*Loader)
+
+// WithNormalizer sets how keys from the sources are normalized before merging.
+func WithNormalizer(n normalize.Normalizer) Option {
+	return func(l *Loader) {
+		l.normalizer = n
+	}
+}
+
+// WithStrict makes a failing source an error instead of a warning.
+func WithStrict(strict bool) Option {
+	return func(l *Loader) {
+		l.strict = strict
+	}
+}
+
+// WithPriority makes the first source holding a key win instead of summing the times of every
+// source, so later sources only fill the gaps of earlier ones, e.g. the times of the main branch
+// behind those of a feature branch. The number of keys taken from each source is logged.
+func WithPriority(priority bool) Option {
+	return func(l *Loader) {
+		l.priority = priority
+	}
+}
+
+// NewLoader creates a Loader.
+func NewLoader(logger zerolog.Logger, opts ...Option) *Loader {
+	l := &Loader{
+		logger:     logger,
+		normalizer: normalize.New(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Load loads every source in order and merges their times.
+//
+// Keys are normalized, and times recorded for the same key by several sources are summed,
+// the same way a source combining several reports does, or taken from the first of them with
+// WithPriority. A failing source is skipped with a warning unless the loader is strict; the
+// failures are returned instead when no source loaded.
+func (l *Loader) Load(ctx context.Context, sources ...Source) (map[string]float64, error) {
+	loaded := make([]map[string]float64, len(sources))
+	var failures []error
+	for i, source := range sources {
+		times, err := source.Load(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("loading %s interrupted: %w", source.Describe(), ctxErr)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", source.Describe(), err)
+			if l.strict {
+				return nil, err
+			}
+			failures = append(failures, err)
+			continue
+		}
+
+		loaded[i] = make(map[string]float64, len(times))
+		for name, time := range times {
+			loaded[i][l.normalizer.Key(name)] += time
+		}
+		l.logger.Debug().
+			Str("source", source.Describe()).
+			Int("count", len(times)).
+			Msg("Loaded time source")
+	}
+
+	times := l.merge(sources, loaded)
+	if len(failures) > 0 && len(failures) == len(sources) {
+		return times, errors.Join(failures...)
+	}
+	for _, err := range failures {
+		l.logger.Warn().Err(err).Msg("Failed to load time source, continuing with the others")
+	}
+	return times, nil
+}
+
+// merge combines the normalized times loaded from each source, nil for a failed source.
+func (l *Loader) merge(sources []Source, loaded []map[string]float64) map[string]float64 {
+	times := make(map[string]float64)
+	for i, source := range loaded {
+		won := 0
+		for key, time := range source {
+			if _, seen := times[key]; l.priority && seen {
+				continue
+			}
+			times[key] += time
+			won++
+		}
+		if l.priority && source != nil {
+			l.logger.Info().
+				Int("source", i+1).
+				Str("name", sources[i].Describe()).
+				Int("keys", won).
+				Msg("Keys taken from time source")
+		}
+	}
+	return times
+}
+
+// Rename moves the times of renamed tests to their new names, given as old name to new name and
+// normalized like the keys of times. A time moves only when the new name has none of its own, so
+// times recorded under the new name are kept, and old names are taken in sorted order when several
+// are renamed to the same name. It returns the number of times moved.
+func (l *Loader) Rename(times map[string]float64, renames map[string]string) int {
+	moved := 0
+	for _, from := range slices.Sorted(maps.Keys(renames)) {
+		from, to := l.normalizer.Key(from), l.normalizer.Key(renames[from])
+		time, ok := times[from]
+		if !ok || from == to {
+			continue
+		}
+		if _, exists := times[to]; exists {
+			continue
+		}
+		times[to] = time
+		delete(times, from)
+		moved++
+	}
+	return moved
+}
@@ -0,0 +1,179 @@
+package timesource_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/timesource"
+)
+
+// fakeSource returns fixed times or a fixed error.
+type fakeSource struct {
+	times map[string]float64
+	err   error
+	name  string
+}
+
+func (s fakeSource) Load(ctx context.Context) (map[string]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.times, s.err
+}
+
+func (s fakeSource) Describe() string {
+	return s.name
+}
+
+func TestLoader_Merge(t *testing.T) {
+	logger := zerolog.New(os.Stderr).Level(zerolog.Disabled)
+	first := fakeSource{name: "first", times: map[string]float64{"a_test.go": 1, "./b_test.go": 2}}
+	second := fakeSource{name: "second", times: map[string]float64{"b_test.go": 3, "c_test.go": 4}}
+	broken := fakeSource{name: "broken", err: errors.New("unreachable")}
+
+	t.Run("sums times under normalized keys", func(t *testing.T) {
+		times, err := timesource.NewLoader(logger).Load(t.Context(), first, second)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		want := map[string]float64{"a_test.go": 1, "b_test.go": 5, "c_test.go": 4}
+		if len(times) != len(want) {
+			t.Errorf("Got %v, want %v", times, want)
+		}
+		for key, time := range want {
+			if times[key] != time {
+				t.Errorf("%s: got %v, want %v", key, times[key], time)
+			}
+		}
+	})
+
+	t.Run("skips a failing source", func(t *testing.T) {
+		times, err := timesource.NewLoader(logger).Load(t.Context(), broken, second)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(times) != 2 || times["b_test.go"] != 3 {
+			t.Errorf("Got %v, want only the times of the second source", times)
+		}
+	})
+
+	t.Run("strict fails on a failing source", func(t *testing.T) {
+		_, err := timesource.NewLoader(logger, timesource.WithStrict(true)).Load(t.Context(), first, broken)
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+		if err.Error() != "broken: unreachable" {
+			t.Errorf("Error should name the source, got %q", err)
+		}
+	})
+
+	t.Run("fails when no source loads", func(t *testing.T) {
+		sentinel := errors.New("sentinel")
+		_, err := timesource.NewLoader(logger).Load(t.Context(), broken, fakeSource{name: "other", err: sentinel})
+		if !errors.Is(err, sentinel) {
+			t.Errorf("Expected the failures to be returned, got %v", err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, err := timesource.NewLoader(logger).Load(ctx, first, second)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Got %v, want context.Canceled", err)
+		}
+	})
+}
+
+func TestLoader_Priority(t *testing.T) {
+	feature := fakeSource{name: "feature", times: map[string]float64{"a_test.go": 1, "./b_test.go": 2}}
+	mainBranch := fakeSource{name: "main", times: map[string]float64{"b_test.go": 30, "c_test.go": 40, "d_test.go": 50}}
+	release := fakeSource{name: "release", times: map[string]float64{"e_test.go": 6}}
+	broken := fakeSource{name: "broken", err: errors.New("unreachable")}
+
+	tests := []struct {
+		name     string
+		sources  []timesource.Source
+		want     map[string]float64
+		wantLogs []string
+	}{
+		{
+			name:    "overlapping keys",
+			sources: []timesource.Source{feature, mainBranch},
+			want:    map[string]float64{"a_test.go": 1, "b_test.go": 2, "c_test.go": 40, "d_test.go": 50},
+			wantLogs: []string{
+				`"source":1,"name":"feature","keys":2`,
+				`"source":2,"name":"main","keys":2`,
+			},
+		},
+		{
+			name:    "order decides",
+			sources: []timesource.Source{mainBranch, feature},
+			want:    map[string]float64{"a_test.go": 1, "b_test.go": 30, "c_test.go": 40, "d_test.go": 50},
+			wantLogs: []string{
+				`"source":1,"name":"main","keys":3`,
+				`"source":2,"name":"feature","keys":1`,
+			},
+		},
+		{
+			name:    "disjoint keys",
+			sources: []timesource.Source{broken, feature, release},
+			want:    map[string]float64{"a_test.go": 1, "b_test.go": 2, "e_test.go": 6},
+			wantLogs: []string{
+				`"source":2,"name":"feature","keys":2`,
+				`"source":3,"name":"release","keys":1`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			loader := timesource.NewLoader(zerolog.New(&logs), timesource.WithPriority(true))
+			times, err := loader.Load(t.Context(), tt.sources...)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if len(times) != len(tt.want) {
+				t.Errorf("Got %v, want %v", times, tt.want)
+			}
+			for key, time := range tt.want {
+				if times[key] != time {
+					t.Errorf("%s: got %v, want %v", key, times[key], time)
+				}
+			}
+			for _, want := range tt.wantLogs {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("Logs should contain %s, got:\n%s", want, logs.String())
+				}
+			}
+			if strings.Contains(logs.String(), `"name":"broken","keys"`) {
+				t.Errorf("A failed source should not be counted, got:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestLoader_Rename(t *testing.T) {
+	loader := timesource.NewLoader(zerolog.Nop())
+	times := map[string]float64{"old/a_test.go": 4, "old/b_test.go": 2, "new/b_test.go": 1, "c_test.go": 3}
+	moved := loader.Rename(times, map[string]string{
+		"./old/a_test.go": "new/a_test.go", // normalized like the keys
+		"old/b_test.go":   "new/b_test.go", // the new path has its own time
+		"gone_test.go":    "d_test.go",     // no time recorded
+	})
+
+	if moved != 1 {
+		t.Errorf("Rename moved %d times, want 1", moved)
+	}
+	want := map[string]float64{"new/a_test.go": 4, "old/b_test.go": 2, "new/b_test.go": 1, "c_test.go": 3}
+	if !maps.Equal(times, want) {
+		t.Errorf("Times = %v, want %v", times, want)
+	}
+}
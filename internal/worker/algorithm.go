@@ -0,0 +1,212 @@
+package worker
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"slices"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// Algorithm selects how Distribute assigns tests to workers.
+type Algorithm string
+
+const (
+	AlgorithmGreedy     Algorithm = "greedy"     // Every test to the least loaded worker, longest first
+	AlgorithmRoundRobin Algorithm = "roundrobin" // Tests dealt to the workers in turn, back and forth
+	AlgorithmKK         Algorithm = "kk"         // Karmarkar–Karp largest differencing
+)
+
+// ParseAlgorithm validates an allocation algorithm given on the command line.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch a := Algorithm(s); a {
+	case AlgorithmGreedy, AlgorithmRoundRobin, AlgorithmKK:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown algorithm %q (must be %q, %q, or %q)",
+			s, AlgorithmGreedy, AlgorithmRoundRobin, AlgorithmKK)
+	}
+}
+
+// WithAlgorithm sets the algorithm Distribute assigns tests with. Defaults to AlgorithmGreedy,
+// which is also used whenever resource limits are set, since only it honours them, and instead
+// of AlgorithmRoundRobin when the workers already hold tests; Drain always places tests greedily.
+// Every algorithm is deterministic: identical tests and workers always give the same assignments.
+func WithAlgorithm(alg Algorithm) Option {
+	return func(a *Allocator) {
+		a.algorithm = alg
+	}
+}
+
+// owners returns the worker index of every test, chosen up front by the allocator's algorithm,
+// or nil when the tests are placed one by one with the greedy heap.
+func (a *Allocator) owners(tests []junit.Test) []int {
+	if len(a.limits) > 0 {
+		return nil
+	}
+	switch a.algorithm {
+	case AlgorithmRoundRobin:
+		if a.loaded() {
+			// Dealing in turn would ignore what the workers already hold
+			return nil
+		}
+		return roundRobin(len(tests), len(a.workers))
+	case AlgorithmKK:
+		return karmarkarKarp(tests, a.workers)
+	case AlgorithmGreedy:
+	}
+	return nil
+}
+
+// loaded reports whether any worker already holds tests or time.
+func (a *Allocator) loaded() bool {
+	return slices.ContainsFunc(a.workers, func(w Worker) bool { return w.Total > 0 || len(w.Tests) > 0 })
+}
+
+// roundRobin deals n tests to k workers in turn, reversing direction every round so that the
+// worker taking the longest test of a round takes the shortest of the next.
+func roundRobin(n, k int) []int {
+	owners := make([]int, n)
+	for i := range owners {
+		owners[i] = i % k
+		if (i/k)%2 == 1 {
+			owners[i] = k - 1 - owners[i]
+		}
+	}
+	return owners
+}
+
+// kkSubset is one of the k subsets of a partition built by karmarkarKarp: the total time of its
+// tests, which are linked through kkState.next, and the worker it goes to, or -1 until it is
+// joined with the subset of that worker.
+type kkSubset struct {
+	total  float64
+	head   int
+	tail   int
+	worker int
+}
+
+// kkPartition is a partition of some of the tests into k subsets. Only subsets holding tests or
+// a worker are stored, largest first; the others are empty.
+type kkPartition struct {
+	subsets []kkSubset
+	seq     int // Creation order, breaking ties between partitions
+}
+
+// spread returns the difference between the largest and the smallest subset of p.
+func (p *kkPartition) spread(k int) float64 {
+	if len(p.subsets) < k {
+		return p.subsets[0].total
+	}
+	return p.subsets[0].total - p.subsets[k-1].total
+}
+
+// kkState holds the partitions left to combine, as a max-heap by spread, ties going to the
+// older partition.
+type kkState struct {
+	k     int
+	next  []int // Next test of the same subset, -1 at the end
+	parts []*kkPartition
+	seq   int
+}
+
+func (s *kkState) Len() int { return len(s.parts) }
+
+func (s *kkState) Less(i, j int) bool {
+	si, sj := s.parts[i].spread(s.k), s.parts[j].spread(s.k)
+	if si != sj {
+		return si > sj
+	}
+	return s.parts[i].seq < s.parts[j].seq
+}
+
+func (s *kkState) Swap(i, j int) { s.parts[i], s.parts[j] = s.parts[j], s.parts[i] }
+
+func (s *kkState) Push(x any) { s.parts = append(s.parts, x.(*kkPartition)) }
+
+func (s *kkState) Pop() any {
+	n := len(s.parts)
+	x := s.parts[n-1]
+	s.parts = s.parts[:n-1]
+	return x
+}
+
+// karmarkarKarp partitions tests into one subset per worker with the largest differencing method
+// of Karmarkar and Karp: every test starts as a partition of its own, and the two partitions
+// whose largest and smallest subsets differ most are repeatedly combined, the largest subset of
+// one joining the smallest of the other, until a single partition is left. The current loads of
+// the workers take part as one more partition, so every final subset holds one worker.
+func karmarkarKarp(tests []junit.Test, workers []Worker) []int {
+	s := &kkState{k: len(workers), next: make([]int, len(tests)), parts: make([]*kkPartition, 0, len(tests)+1)}
+	seed := make([]kkSubset, len(workers))
+	for i := range workers {
+		seed[i] = kkSubset{total: workers[i].Total, head: -1, tail: -1, worker: i}
+	}
+	sortSubsets(seed)
+	s.add(seed)
+	for i, test := range tests {
+		s.next[i] = -1
+		s.add([]kkSubset{{total: test.Time, head: i, tail: i, worker: -1}})
+	}
+
+	heap.Init(s)
+	for s.Len() > 1 {
+		x := heap.Pop(s).(*kkPartition)
+		y := heap.Pop(s).(*kkPartition)
+		s.add(s.combine(x.subsets, y.subsets))
+		heap.Fix(s, s.Len()-1)
+	}
+
+	owners := make([]int, len(tests))
+	for _, subset := range s.parts[0].subsets {
+		for i := subset.head; i >= 0; i = s.next[i] {
+			owners[i] = subset.worker
+		}
+	}
+	return owners
+}
+
+// add appends a partition of subsets, which the caller then fixes in the heap.
+func (s *kkState) add(subsets []kkSubset) {
+	s.parts = append(s.parts, &kkPartition{subsets: subsets, seq: s.seq})
+	s.seq++
+}
+
+// combine joins the i-th largest subset of x with the i-th smallest of y, counting the subsets
+// that are not stored as empty.
+func (s *kkState) combine(x, y []kkSubset) []kkSubset {
+	out := make([]kkSubset, 0, min(s.k, len(x)+len(y)))
+	for i := range s.k {
+		j := s.k - 1 - i
+		switch {
+		case i < len(x) && j < len(y):
+			out = append(out, s.join(x[i], y[j]))
+		case i < len(x):
+			out = append(out, x[i])
+		case j < len(y):
+			out = append(out, y[j])
+		}
+	}
+	sortSubsets(out)
+	return out
+}
+
+// join returns the union of two subsets, at most one of which holds a worker.
+func (s *kkState) join(a, b kkSubset) kkSubset {
+	joined := kkSubset{total: a.total + b.total, head: a.head, tail: a.tail, worker: max(a.worker, b.worker)}
+	switch {
+	case b.head < 0:
+	case a.head < 0:
+		joined.head, joined.tail = b.head, b.tail
+	default:
+		s.next[a.tail] = b.head
+		joined.tail = b.tail
+	}
+	return joined
+}
+
+// sortSubsets sorts subsets largest first, keeping the order of equal ones.
+func sortSubsets(subsets []kkSubset) {
+	slices.SortStableFunc(subsets, func(x, y kkSubset) int { return cmp.Compare(y.total, x.total) })
+}
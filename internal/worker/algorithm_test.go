@@ -0,0 +1,171 @@
+package worker_test
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+// algorithms returns every allocation algorithm.
+func algorithms() []worker.Algorithm {
+	return []worker.Algorithm{worker.AlgorithmGreedy, worker.AlgorithmRoundRobin, worker.AlgorithmKK}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, alg := range algorithms() {
+		if got, err := worker.ParseAlgorithm(string(alg)); err != nil || got != alg {
+			t.Errorf("ParseAlgorithm(%q) = %q, %v", alg, got, err)
+		}
+	}
+	if _, err := worker.ParseAlgorithm("lpt"); err == nil {
+		t.Error("ParseAlgorithm(lpt) succeeded, want an error")
+	}
+}
+
+// distribute splits tests across n workers with alg and returns the allocator.
+func distribute(
+	t *testing.T, alg worker.Algorithm, n int, tests []junit.Test, opts ...worker.Option,
+) *worker.Allocator {
+	t.Helper()
+	allocator, err := worker.NewAllocator(n, append(opts, worker.WithAlgorithm(alg))...)
+	if err != nil {
+		t.Fatalf("NewAllocator failed: %v", err)
+	}
+	allocator.Distribute(tests)
+	return allocator
+}
+
+// busiest returns the highest total of the workers of a.
+func busiest(a *worker.Allocator) float64 {
+	most := 0.0
+	for _, w := range a.GetWorkersRef() {
+		most = max(most, w.Total)
+	}
+	return most
+}
+
+func TestAllocator_Algorithms(t *testing.T) {
+	tests := generateTests(2000)
+
+	for _, alg := range algorithms() {
+		t.Run(string(alg), func(t *testing.T) {
+			rec := &worker.Recorder{}
+			a := distribute(t, alg, 7, tests, worker.WithObserver(rec))
+			checkBalanced(t, a.GetStats())
+
+			// Every test is placed once, in order, and each worker keeps the longest first
+			assignments := rec.Assignments()
+			if len(assignments) != len(tests) {
+				t.Fatalf("Observed %d assignments, want %d", len(assignments), len(tests))
+			}
+			count := 0
+			for i, w := range a.GetWorkersRef() {
+				count += len(w.Tests)
+				if !slices.IsSortedFunc(w.Tests, func(x, y junit.Test) int { return cmp.Compare(y.Time, x.Time) }) {
+					t.Errorf("Worker %d tests are not longest first", i)
+				}
+			}
+			if count != len(tests) || assignments[0].Test.Name != tests[0].Name {
+				t.Errorf("Placed %d tests starting with %s, want %d starting with %s",
+					count, assignments[0].Test.Name, len(tests), tests[0].Name)
+			}
+
+			// Identical input gives identical output
+			again := distribute(t, alg, 7, tests)
+			for i, w := range a.GetWorkersRef() {
+				if !slices.Equal(w.Tests, again.GetWorkerRef(i).Tests) {
+					t.Fatalf("Worker %d differs between runs", i)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocator_RoundRobin(t *testing.T) {
+	tests := []junit.Test{
+		{Name: "a", Time: 6}, {Name: "b", Time: 5}, {Name: "c", Time: 4},
+		{Name: "d", Time: 3}, {Name: "e", Time: 2}, {Name: "f", Time: 1}, {Name: "g", Time: 1},
+	}
+	a := distribute(t, worker.AlgorithmRoundRobin, 3, tests)
+
+	// Dealt back and forth: a b c, then f e d, then g
+	want := [][]string{{"a", "f", "g"}, {"b", "e"}, {"c", "d"}}
+	for i, names := range want {
+		var got []string
+		for _, test := range a.GetWorkerRef(i).Tests {
+			got = append(got, test.Name)
+		}
+		if !slices.Equal(got, names) {
+			t.Errorf("Worker %d = %v, want %v", i, got, names)
+		}
+	}
+}
+
+func TestAllocator_RoundRobinCurrentLoad(t *testing.T) {
+	a, err := worker.NewAllocatorFrom([]worker.Worker{
+		{Tests: []junit.Test{{Name: "old", Time: 10}}, Total: 10},
+		{},
+	}, worker.WithAlgorithm(worker.AlgorithmRoundRobin))
+	if err != nil {
+		t.Fatalf("NewAllocatorFrom failed: %v", err)
+	}
+	a.Distribute([]junit.Test{{Name: "x", Time: 6}, {Name: "y", Time: 4}, {Name: "z", Time: 2}})
+
+	// Dealing would give x and z to the busy worker, 18s; greedy gives them to the idle one
+	if got := busiest(a); got != 12 {
+		t.Errorf("Busiest worker = %g, want 12", got)
+	}
+}
+
+func TestAllocator_KarmarkarKarpBeatsGreedy(t *testing.T) {
+	// Graham's worst case for longest-first greedy on three workers: two tests each of 5 and 4,
+	// then three of 3. The best split is 9 per worker; greedy ends at 11
+	var tests []junit.Test
+	for _, time := range []float64{5, 5, 4, 4, 3, 3, 3} {
+		tests = append(tests, junit.Test{Name: "t", Time: time})
+	}
+
+	greedy := busiest(distribute(t, worker.AlgorithmGreedy, 3, tests))
+	kk := busiest(distribute(t, worker.AlgorithmKK, 3, tests))
+	if greedy != 11 || kk != 10 {
+		t.Errorf("Busiest worker: greedy %g, kk %g, want 11 and 10", greedy, kk)
+	}
+}
+
+func TestAllocator_KarmarkarKarpCurrentLoad(t *testing.T) {
+	a, err := worker.NewAllocatorFrom([]worker.Worker{
+		{Tests: []junit.Test{{Name: "old", Time: 10}}, Total: 10},
+		{},
+	}, worker.WithAlgorithm(worker.AlgorithmKK))
+	if err != nil {
+		t.Fatalf("NewAllocatorFrom failed: %v", err)
+	}
+	a.Distribute([]junit.Test{{Name: "x", Time: 6}, {Name: "y", Time: 4}, {Name: "z", Time: 2}})
+
+	// The worker already holding 10s takes only the shortest test
+	if got := a.GetWorkerRef(0); got.Total != 12 || len(got.Tests) != 2 || got.Tests[1].Name != "z" {
+		t.Errorf("Worker 0 = %+v, want old and z", got)
+	}
+	if got := a.GetWorkerRef(1).Total; got != 10 {
+		t.Errorf("Worker 1 total = %g, want 10", got)
+	}
+}
+
+func TestAllocator_AlgorithmWithResourceLimits(t *testing.T) {
+	tests := []junit.Test{
+		{Name: "a", Time: 4, Resource: "db"}, {Name: "b", Time: 3, Resource: "db"},
+		{Name: "c", Time: 2}, {Name: "d", Time: 1},
+	}
+	// Only greedy honours limits, so it is used whatever the algorithm
+	for _, alg := range algorithms() {
+		a := distribute(t, alg, 2, tests, worker.WithResourceLimits(map[string]int{"db": 1}))
+		for i, w := range a.GetWorkersRef() {
+			if len(w.Tests) == 0 || w.Tests[0].Resource != "db" {
+				t.Errorf("%s: worker %d = %+v, want one db test first", alg, i, w.Tests)
+			}
+		}
+	}
+}
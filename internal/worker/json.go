@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// Percent converts a ratio to a percentage.
+const Percent = 100
+
+// MaxTotal returns the total time of the busiest worker, which bounds the wall-clock time of the run.
+func (d Distribution) MaxTotal() float64 {
+	maxTotal := 0.0
+	for _, ws := range d.Workers {
+		maxTotal = max(maxTotal, ws.Total)
+	}
+	return maxTotal
+}
+
+// Imbalance returns the ratio of the busiest worker's time to the average, 1 being a perfect split.
+// It is zero when there is no work to compare against.
+func (d Distribution) Imbalance() float64 {
+	if d.AvgTime <= 0 {
+		return 0
+	}
+	return finite(d.MaxTotal() / d.AvgTime)
+}
+
+// Efficiency returns the ratio of the average worker's time to the busiest one's, in (0, 1].
+// It is the share of the reserved worker time spent running tests, and zero when there is no work.
+func (d Distribution) Efficiency() float64 {
+	maxTotal := d.MaxTotal()
+	if maxTotal <= 0 {
+		return 0
+	}
+	return finite(d.AvgTime / maxTotal)
+}
+
+// ImbalancePercent returns how much the busiest worker's time exceeds the average, as a percentage,
+// 0 being a perfect split. It is zero when there is no work to compare against.
+func (d Distribution) ImbalancePercent() float64 {
+	ratio := d.Imbalance()
+	if ratio <= 0 {
+		return 0
+	}
+	return (ratio - 1) * Percent
+}
+
+// EfficiencyPercent returns Efficiency as a percentage.
+func (d Distribution) EfficiencyPercent() float64 {
+	return d.Efficiency() * Percent
+}
+
+// distributionJSON has the fields of Distribution without its methods, so encoding it does not recurse.
+type distributionJSON Distribution
+
+// MarshalJSON encodes d together with its imbalance and efficiency.
+// Non-finite values, which JSON cannot represent, are encoded as zero.
+func (d Distribution) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		distributionJSON
+
+		Imbalance  float64 `json:"imbalance"`
+		Efficiency float64 `json:"efficiency"`
+	}{
+		distributionJSON: distributionJSON{
+			TotalTime:        finite(d.TotalTime),
+			AvgTime:          finite(d.AvgTime),
+			Workers:          d.Workers,
+			SuitePercentiles: finitePercentiles(d.SuitePercentiles),
+			Oversized:        finiteOversized(d.Oversized),
+			ResourceLimits:   d.ResourceLimits,
+		},
+		Imbalance:  d.Imbalance(),
+		Efficiency: d.Efficiency(),
+	})
+}
+
+// statsJSON has the fields of Stats without its methods, so encoding it does not recurse.
+type statsJSON Stats
+
+// MarshalJSON encodes s, replacing non-finite values by zero.
+// Times that had to be replaced are no longer reported as sorted.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	s.Total = finite(s.Total)
+	s.MinTime = finite(s.MinTime)
+	s.MaxTime = finite(s.MaxTime)
+
+	if slices.ContainsFunc(s.TestTimes, func(t float64) bool { return t != finite(t) }) {
+		times := make([]float64, len(s.TestTimes))
+		for i, t := range s.TestTimes {
+			times[i] = finite(t)
+		}
+		s.TestTimes = times
+		s.TestTimesSorted = false
+	}
+
+	return json.Marshal(statsJSON(s))
+}
+
+// assignmentJSON is the serialized form of a test assigned to a worker.
+type assignmentJSON struct {
+	Name     string       `json:"name"`
+	Source   junit.Source `json:"source,omitempty"`
+	Resource string       `json:"resource,omitempty"`
+	Time     float64      `json:"time"`
+}
+
+// workerJSON is the serialized form of a Worker.
+type workerJSON struct {
+	Tests []assignmentJSON `json:"tests"`
+	Total float64          `json:"total"`
+}
+
+// MarshalJSON encodes w as its total and the name, time, time source, and resource tag of each
+// assigned test, with invalid UTF-8 in names and tags replaced, see validUTF8.
+// The normalized key, a matching detail, is left out.
+func (w Worker) MarshalJSON() ([]byte, error) {
+	out := workerJSON{
+		Tests: make([]assignmentJSON, len(w.Tests)),
+		Total: finite(w.Total),
+	}
+	for i, t := range w.Tests {
+		out.Tests[i] = assignmentJSON{
+			Name:     validUTF8(t.Name),
+			Source:   t.Source,
+			Resource: validUTF8(t.Resource),
+			Time:     finite(t.Time),
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a worker encoded by MarshalJSON.
+// The decoded tests carry their name, time, time source, and resource tag.
+func (w *Worker) UnmarshalJSON(data []byte) error {
+	var in workerJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	w.Total = in.Total
+	w.Tests = make([]junit.Test, len(in.Tests))
+	for i, t := range in.Tests {
+		w.Tests[i] = junit.Test{Name: t.Name, Source: t.Source, Resource: t.Resource, Time: t.Time}
+	}
+	return nil
+}
+
+// finitePercentiles returns a copy of ps with non-finite values replaced by zero.
+func finitePercentiles(ps []Percentile) []Percentile {
+	if ps == nil {
+		return nil
+	}
+	out := make([]Percentile, len(ps))
+	for i, p := range ps {
+		out[i] = Percentile{Percentile: p.Percentile, Value: finite(p.Value)}
+	}
+	return out
+}
+
+// finiteOversized returns a copy of tests with non-finite times replaced by zero.
+func finiteOversized(tests []OversizedTest) []OversizedTest {
+	if tests == nil {
+		return nil
+	}
+	out := make([]OversizedTest, len(tests))
+	for i, t := range tests {
+		t.Name = validUTF8(t.Name)
+		t.Time, t.Excess = finite(t.Time), finite(t.Excess)
+		out[i] = t
+	}
+	return out
+}
+
+// validUTF8 returns s with every run of bytes that are not valid UTF-8 replaced by U+FFFD.
+// Test lists are read as bytes; replacing here keeps the JSON output and manifests valid UTF-8
+// whatever the encoder does with invalid strings, which encoding/json/v2 rejects.
+func validUTF8(s string) string {
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
+
+// finite returns v, or zero when v is NaN or infinite.
+func finite(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,216 @@
+package worker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+func goldenAllocator(tb testing.TB) *worker.Allocator {
+	tb.Helper()
+
+	allocator := newAllocator(tb, 3)
+	allocator.Distribute([]junit.Test{
+		{Name: "tests/slow_test.go", Key: "tests/slow_test.go", Time: 8, Source: junit.SourceStats},
+		{Name: "tests/medium_test.go", Key: "tests/medium_test.go", Time: 4, Source: junit.SourceStats},
+		{Name: "tests/inline_test.go", Key: "tests/inline_test.go", Time: 3, Source: junit.SourceInline},
+		{Name: "tests/fast_test.go", Key: "tests/fast_test.go", Time: 1, Source: junit.SourceDefault},
+	})
+	return allocator
+}
+
+func goldenStatsOptions() worker.StatsOptions {
+	return worker.StatsOptions{IncludeTestTimes: true, SortTestTimes: true, SuitePercentiles: []int{50, 100}}
+}
+
+func TestDistribution_MarshalJSON_Golden(t *testing.T) {
+	allocator := goldenAllocator(t)
+
+	got, err := json.MarshalIndent(struct {
+		Distribution worker.Distribution `json:"distribution"`
+		Workers      []worker.Worker     `json:"workers"`
+	}{
+		Distribution: allocator.GetStatsWithOptions(goldenStatsOptions()),
+		Workers:      allocator.GetWorkersRef(),
+	}, "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent() error = %v", err)
+	}
+
+	want, err := os.ReadFile("../../testdata/json/distribution.json")
+	if err != nil {
+		t.Fatalf("cannot read golden file: %v", err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+		t.Errorf("MarshalIndent() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDistribution_RoundTrip(t *testing.T) {
+	want := goldenAllocator(t).GetStatsWithOptions(goldenStatsOptions())
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got worker.Distribution
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestWorker_RoundTrip(t *testing.T) {
+	want := goldenAllocator(t).GetWorkers()
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), "Key") || strings.Contains(string(data), "Source") {
+		t.Errorf("Marshal() = %s, want only names and times", data)
+	}
+
+	var got []worker.Worker
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("round trip returned %d workers, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Total != want[i].Total {
+			t.Errorf("worker %d total = %v, want %v", i, got[i].Total, want[i].Total)
+		}
+		if len(got[i].Tests) != len(want[i].Tests) {
+			t.Fatalf("worker %d has %d tests, want %d", i, len(got[i].Tests), len(want[i].Tests))
+		}
+		for j, test := range want[i].Tests {
+			if got[i].Tests[j].Name != test.Name || got[i].Tests[j].Time != test.Time {
+				t.Errorf("worker %d test %d = %+v, want name %q and time %v",
+					i, j, got[i].Tests[j], test.Name, test.Time)
+			}
+		}
+	}
+}
+
+func TestWorker_MarshalJSON_InvalidUTF8(t *testing.T) {
+	w := worker.Worker{Total: 1, Tests: []junit.Test{{Name: "a\xff\xfe_test.go", Resource: "gpu\xc3", Time: 1}}}
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !utf8.Valid(data) {
+		t.Errorf("Marshal() = %q, want valid UTF-8", data)
+	}
+	var got worker.Worker
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.Tests[0].Name != "a\uFFFD_test.go" || got.Tests[0].Resource != "gpu\uFFFD" {
+		t.Errorf("Decoded %q and %q, want each invalid run replaced by U+FFFD",
+			got.Tests[0].Name, got.Tests[0].Resource)
+	}
+}
+
+func TestDistribution_Balance(t *testing.T) {
+	tests := []struct {
+		name           string
+		dist           worker.Distribution
+		wantImbalance  float64
+		wantEfficiency float64
+		wantPercents   [2]float64 // Imbalance and efficiency percentages
+	}{
+		{
+			name: "perfect split",
+			dist: worker.Distribution{
+				TotalTime: 20,
+				AvgTime:   10,
+				Workers:   []worker.Stats{{Total: 10}, {Total: 10}},
+			},
+			wantImbalance:  1,
+			wantEfficiency: 1,
+			wantPercents:   [2]float64{0, 100},
+		},
+		{
+			name: "skewed split",
+			dist: worker.Distribution{
+				TotalTime: 20,
+				AvgTime:   10,
+				Workers:   []worker.Stats{{Total: 16}, {Total: 4}},
+			},
+			wantImbalance:  1.6,
+			wantEfficiency: 0.625,
+			wantPercents:   [2]float64{60, 62.5},
+		},
+		{
+			name:           "no work",
+			dist:           worker.Distribution{Workers: []worker.Stats{{}, {}}},
+			wantImbalance:  0,
+			wantEfficiency: 0,
+		},
+		{
+			name: "no workers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dist.Imbalance(); math.Abs(got-tt.wantImbalance) > 1e-9 {
+				t.Errorf("Imbalance() = %v, want %v", got, tt.wantImbalance)
+			}
+			if got := tt.dist.Efficiency(); math.Abs(got-tt.wantEfficiency) > 1e-9 {
+				t.Errorf("Efficiency() = %v, want %v", got, tt.wantEfficiency)
+			}
+			got := [2]float64{tt.dist.ImbalancePercent(), tt.dist.EfficiencyPercent()}
+			if math.Abs(got[0]-tt.wantPercents[0]) > 1e-9 || math.Abs(got[1]-tt.wantPercents[1]) > 1e-9 {
+				t.Errorf("ImbalancePercent(), EfficiencyPercent() = %v, want %v", got, tt.wantPercents)
+			}
+		})
+	}
+}
+
+func TestDistribution_MarshalJSON_NonFinite(t *testing.T) {
+	dist := worker.Distribution{
+		TotalTime: math.Inf(1),
+		AvgTime:   math.NaN(),
+		Workers: []worker.Stats{{
+			Total:           math.Inf(1),
+			TestCount:       2,
+			MaxTime:         math.NaN(),
+			TestTimes:       []float64{1, math.Inf(1)},
+			TestTimesSorted: true,
+		}},
+	}
+
+	data, err := json.Marshal(dist)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got worker.Distribution
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	ws := got.Workers[0]
+	if got.TotalTime != 0 || got.AvgTime != 0 || ws.Total != 0 || ws.MaxTime != 0 {
+		t.Errorf("non-finite values not zeroed: %s", data)
+	}
+	if !reflect.DeepEqual(ws.TestTimes, []float64{1, 0}) || ws.TestTimesSorted {
+		t.Errorf("TestTimes = %v (sorted %v), want [1 0] unsorted", ws.TestTimes, ws.TestTimesSorted)
+	}
+	if !math.IsInf(dist.Workers[0].TestTimes[1], 1) {
+		t.Error("MarshalJSON() modified the caller's TestTimes")
+	}
+}
@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// Observer is notified of assignments as Distribute makes them.
+type Observer interface {
+	// OnAssign is called after test was assigned to the worker at index worker,
+	// whose total time is now workerTotalAfter.
+	OnAssign(test junit.Test, worker int, workerTotalAfter float64)
+}
+
+// Assignment is a single assignment reported to an Observer.
+type Assignment struct {
+	Test       junit.Test
+	Worker     int
+	TotalAfter float64
+}
+
+// Recorder is an Observer keeping the ordered trace of assignments.
+// It is safe for concurrent use, but assignments from concurrent Distribute calls interleave.
+//
+// The zero Recorder keeps the whole trace in memory. One made by NewRecorder spills it to a
+// temporary file instead whenever it holds too many assignments, which Reset removes.
+type Recorder struct {
+	trace      []Assignment // Assignments not spilled yet, made after the spilled ones
+	spill      *os.File     // Spilled assignments, in the format of appendAssignment
+	buf        []byte       // Encoding buffer, reused between spills
+	err        error        // First spill failure, after which assignments stay in memory
+	spillAfter int
+	spilled    int   // Assignments in spill
+	size       int64 // Bytes of spill written successfully
+	mu         sync.Mutex
+}
+
+// NewRecorder returns a Recorder writing its trace to a temporary file as soon as it holds more
+// than spillAfter assignments in memory, so the trace of a large split costs a file rather than
+// memory. The trace is read back from the file by Assignments and Replay. A spillAfter of 0 or
+// less never spills, like the zero Recorder.
+func NewRecorder(spillAfter int) *Recorder {
+	return &Recorder{spillAfter: spillAfter}
+}
+
+// OnAssign records the assignment.
+func (r *Recorder) OnAssign(test junit.Test, worker int, workerTotalAfter float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace = append(r.trace, Assignment{Test: test, Worker: worker, TotalAfter: workerTotalAfter})
+	if r.spillAfter > 0 && len(r.trace) > r.spillAfter && r.err == nil {
+		r.err = r.spillTrace()
+	}
+}
+
+// spillTrace appends the assignments held in memory to the spill file, creating it first. On
+// failure the assignments stay in memory, and those written partially are overwritten by the
+// next attempt, if any.
+func (r *Recorder) spillTrace() error {
+	if r.spill == nil {
+		f, err := os.CreateTemp("", "tests-helper-trace-*")
+		if err != nil {
+			return fmt.Errorf("failed to create trace spill file: %w", err)
+		}
+		r.spill = f
+	}
+
+	r.buf = r.buf[:0]
+	for _, a := range r.trace {
+		r.buf = appendAssignment(r.buf, a)
+	}
+	if _, err := r.spill.WriteAt(r.buf, r.size); err != nil {
+		return fmt.Errorf("failed to spill trace: %w", err)
+	}
+	r.size += int64(len(r.buf))
+	r.spilled += len(r.trace)
+	clear(r.trace) // Release the tests for the garbage collector
+	r.trace = r.trace[:0]
+	return nil
+}
+
+// Replay calls fn with every assignment recorded so far, in the order they were made, reading
+// spilled assignments back from the spill file as it goes. It fails if the spill file cannot be
+// read back. fn must not call methods of the Recorder.
+func (r *Recorder) Replay(fn func(Assignment)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.spilled > 0 {
+		br := newSpillReader(io.NewSectionReader(r.spill, 0, r.size), r.size)
+		for range r.spilled {
+			a, err := readAssignment(br)
+			if err != nil {
+				err = fmt.Errorf("failed to read spilled trace: %w", err)
+				if r.err == nil {
+					r.err = err
+				}
+				return err
+			}
+			fn(a)
+		}
+	}
+	for _, a := range r.trace {
+		fn(a)
+	}
+	return nil
+}
+
+// Assignments returns a copy of the assignments recorded so far, in the order they were made.
+// Should the spill file fail to be read back, it returns those read before; see Err.
+func (r *Recorder) Assignments() []Assignment {
+	var trace []Assignment
+	_ = r.Replay(func(a Assignment) { // The failure is kept for Err
+		trace = append(trace, a)
+	})
+	return trace
+}
+
+// Err returns the first failure to write or read the spill file, if any. Assignments are kept in
+// memory once writing failed, so the trace is complete unless reading failed.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Reset discards the recorded assignments and removes the spill file, if any.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace, r.buf, r.err, r.spilled, r.size = nil, nil, nil, 0, 0
+	if r.spill != nil {
+		_ = r.spill.Close()
+		_ = os.Remove(r.spill.Name())
+		r.spill = nil
+	}
+}
@@ -0,0 +1,230 @@
+package worker_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/progress"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+func TestAllocator_Observer(t *testing.T) {
+	var recorder worker.Recorder
+	allocator, err := worker.NewAllocator(3, worker.WithObserver(&recorder))
+	if err != nil {
+		t.Fatalf("NewAllocator failed: %v", err)
+	}
+	tests := generateTests(50)
+	allocator.Distribute(tests)
+
+	trace := recorder.Assignments()
+	if len(trace) != len(tests) {
+		t.Fatalf("Recorded %d assignments, want %d", len(trace), len(tests))
+	}
+
+	// Replaying the trace per worker must rebuild each worker's tests and running total
+	replayed := make([]worker.Worker, 3)
+	for i, a := range trace {
+		if a.Test != tests[i] {
+			t.Errorf("Assignment %d is %q, want tests in input order (%q)", i, a.Test.Name, tests[i].Name)
+		}
+		w := &replayed[a.Worker]
+		w.Tests = append(w.Tests, a.Test)
+		w.Total += a.Test.Time
+		if a.TotalAfter != w.Total {
+			t.Errorf("Assignment %d: total after = %v, want %v", i, a.TotalAfter, w.Total)
+		}
+	}
+
+	for i, w := range allocator.GetWorkersRef() {
+		if len(w.Tests) != len(replayed[i].Tests) || w.Total != replayed[i].Total {
+			t.Fatalf("Worker %d: got %d tests (%.3fs), trace has %d (%.3fs)",
+				i, len(w.Tests), w.Total, len(replayed[i].Tests), replayed[i].Total)
+		}
+		for j := range w.Tests {
+			if w.Tests[j] != replayed[i].Tests[j] {
+				t.Errorf("Worker %d test %d: got %q, trace has %q", i, j, w.Tests[j].Name, replayed[i].Tests[j].Name)
+			}
+		}
+	}
+
+	recorder.Reset()
+	if got := recorder.Assignments(); len(got) != 0 {
+		t.Errorf("Reset kept %d assignments", len(got))
+	}
+}
+
+func BenchmarkDistributeObserved(b *testing.B) {
+	tests := generateTests(100_000)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		var recorder worker.Recorder
+		allocator, err := worker.NewAllocator(200, worker.WithObserver(&recorder))
+		if err != nil {
+			b.Fatalf("NewAllocator failed: %v", err)
+		}
+		allocator.Distribute(tests)
+	}
+}
+
+func TestAllocator_Progress(t *testing.T) {
+	var done []int
+	report := progress.Func(func(stage string, n, total int) {
+		if stage != progress.StageDistribute || total != 2500 {
+			t.Errorf("OnStage(%q, %d, %d), want stage distribute of 2500", stage, n, total)
+		}
+		done = append(done, n)
+	})
+	allocator, err := worker.NewAllocator(3, worker.WithProgress(report))
+	if err != nil {
+		t.Fatalf("NewAllocator failed: %v", err)
+	}
+	allocator.Distribute(generateTests(2500))
+	if want := []int{1000, 2000, 2500}; !slices.Equal(done, want) {
+		t.Errorf("Reported %v, want %v", done, want)
+	}
+
+	// A multiple of the interval is reported once at the end
+	done = nil
+	allocator, err = worker.NewAllocator(3, worker.WithProgress(progress.Func(func(_ string, n, _ int) {
+		done = append(done, n)
+	})))
+	if err != nil {
+		t.Fatalf("NewAllocator failed: %v", err)
+	}
+	allocator.Distribute(generateTests(2000))
+	if want := []int{1000, 2000}; !slices.Equal(done, want) {
+		t.Errorf("Reported %v, want %v", done, want)
+	}
+}
+
+// BenchmarkDistributeProgress compares Distribute without a progress reporter, the default,
+// with Distribute reporting to a no-op one.
+func BenchmarkDistributeProgress(b *testing.B) {
+	tests := generateTests(100_000)
+	for _, tt := range []struct {
+		name string
+		opts []worker.Option
+	}{
+		{"none", nil},
+		{"noop", []worker.Option{worker.WithProgress(progress.Func(func(string, int, int) {}))}},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				allocator, err := worker.NewAllocator(200, tt.opts...)
+				if err != nil {
+					b.Fatalf("NewAllocator failed: %v", err)
+				}
+				allocator.Distribute(tests)
+			}
+		})
+	}
+}
+
+func TestRecorder_Spill(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	tests := generateTests(50)
+	tests[3].Key = "module3/file3"
+	tests[5].Source, tests[5].Resource, tests[5].Cases = junit.SourceDefault, "db", 4
+	tests[8].Group = &junit.Group{Members: []junit.Test{{Name: "a_test.go", Time: 1}, {Name: "b_test.go", Key: "b"}}}
+
+	trace := func(recorder *worker.Recorder) []worker.Assignment {
+		allocator, err := worker.NewAllocator(3, worker.WithObserver(recorder))
+		if err != nil {
+			t.Fatalf("NewAllocator failed: %v", err)
+		}
+		allocator.Distribute(tests)
+		return recorder.Assignments()
+	}
+
+	var inMemory worker.Recorder
+	want := trace(&inMemory)
+	spilling := worker.NewRecorder(7)
+	if got := trace(spilling); !reflect.DeepEqual(got, want) {
+		t.Errorf("Spilled trace differs from the in-memory one:\n got %+v\nwant %+v", got, want)
+	}
+	if err := spilling.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(os.TempDir(), "tests-helper-trace-*"))
+	if len(files) != 1 {
+		t.Fatalf("Got spill files %v, want one", files)
+	}
+
+	var replayed int
+	if err := spilling.Replay(func(a worker.Assignment) {
+		if a.Test.Name != want[replayed].Test.Name {
+			t.Errorf("Replay %d is %q, want %q", replayed, a.Test.Name, want[replayed].Test.Name)
+		}
+		replayed++
+	}); err != nil || replayed != len(want) {
+		t.Errorf("Replay saw %d assignments (%v), want %d", replayed, err, len(want))
+	}
+
+	spilling.Reset()
+	if got := spilling.Assignments(); len(got) != 0 {
+		t.Errorf("Reset kept %d assignments", len(got))
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("Reset kept the spill file: %v", err)
+	}
+}
+
+func TestRecorder_CorruptSpill(t *testing.T) {
+	// Worker and total take 9 bytes and the flags one, so the first name length is at offset 10
+	tests := []struct {
+		name    string
+		corrupt func(path string) error
+	}{
+		{"truncated", func(path string) error { return os.Truncate(path, 20) }},
+		{"huge length", func(path string) error { return writeAt(path, 10, binary.AppendVarint(nil, 1<<40)) }},
+		{"negative length", func(path string) error { return writeAt(path, 10, binary.AppendVarint(nil, -5)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TMPDIR", t.TempDir())
+			recorder := worker.NewRecorder(2)
+			allocator, err := worker.NewAllocator(3, worker.WithObserver(recorder))
+			if err != nil {
+				t.Fatalf("NewAllocator failed: %v", err)
+			}
+			allocator.Distribute(generateTests(10))
+
+			files, _ := filepath.Glob(filepath.Join(os.TempDir(), "tests-helper-trace-*"))
+			if len(files) != 1 {
+				t.Fatalf("Got spill files %v, want one", files)
+			}
+			if err = tt.corrupt(files[0]); err != nil {
+				t.Fatalf("Failed to corrupt the spill file: %v", err)
+			}
+
+			if got := recorder.Assignments(); len(got) >= 10 {
+				t.Errorf("Read back %d assignments from a corrupt spill file", len(got))
+			}
+			if recorder.Err() == nil {
+				t.Error("Err() = nil, want a read failure")
+			}
+		})
+	}
+}
+
+// writeAt overwrites the bytes of the file at path from offset with b.
+func writeAt(path string, offset int64, b []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt(b, offset); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,81 @@
+package worker
+
+import "math"
+
+// Percentile is the time at a percentile of a set of test times.
+type Percentile struct {
+	Percentile int     `json:"percentile"`
+	Value      float64 `json:"value"`
+}
+
+// DefaultPercentiles returns the percentiles reported when none are configured.
+func DefaultPercentiles() []int {
+	return []int{50, 75, 95, 99, 100}
+}
+
+// PercentileOf returns the p-th percentile of times sorted in ascending order, linearly
+// interpolated between the two closest ranks, or zero when there are no times.
+func PercentileOf(sorted []float64, p int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	const percentageDivisor = 100.0
+	pos := float64(p) / percentageDivisor * float64(len(sorted)-1)
+
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(i)
+	return sorted[i]*(1-frac) + sorted[i+1]*frac
+}
+
+// WeightedPercentileOf returns the p-th percentile of times sorted in ascending order, each
+// counting as many times as its weight in weights, aligned with times: the percentile of the times
+// repeated by their weights when those are whole numbers, linearly interpolated between the two
+// closest ranks like PercentileOf, which it equals when every weight is 1. Times without a
+// positive weight are left out; it returns zero when no time is left.
+func WeightedPercentileOf(sorted, weights []float64, p int) float64 {
+	total := 0.0
+	last := -1
+	for i, w := range weights[:min(len(weights), len(sorted))] {
+		if w > 0 {
+			total += w
+			last = i
+		}
+	}
+	if last < 0 {
+		return 0
+	}
+	const percentageDivisor = 100.0
+	pos := float64(p) / percentageDivisor * max(total-1, 0)
+
+	// The time at rank r is the one whose weight covers r, counting weights from the shortest
+	at := func(r float64) float64 {
+		cumulative := 0.0
+		for i, w := range weights[:last+1] {
+			if w <= 0 {
+				continue
+			}
+			if cumulative += w; r < cumulative {
+				return sorted[i]
+			}
+		}
+		return sorted[last]
+	}
+	lower := math.Floor(pos)
+	frac := pos - lower
+	return at(lower)*(1-frac) + at(lower+1)*frac
+}
+
+// percentilesOf returns every percentile in ps of sorted times, in the order of ps.
+func percentilesOf(sorted []float64, ps []int) []Percentile {
+	if len(ps) == 0 || len(sorted) == 0 {
+		return nil
+	}
+	out := make([]Percentile, len(ps))
+	for i, p := range ps {
+		out[i] = Percentile{Percentile: p, Value: PercentileOf(sorted, p)}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package worker_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+func TestPercentileOf(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	for p, want := range map[int]float64{0: 1, 50: 3, 75: 4, 95: 4.8, 100: 5} {
+		if got := worker.PercentileOf(sorted, p); !floatEqual(got, want) {
+			t.Errorf("P%d = %v, want %v", p, got, want)
+		}
+	}
+	if got := worker.PercentileOf(nil, 95); got != 0 {
+		t.Errorf("P95 of nothing = %v, want 0", got)
+	}
+}
+
+func TestWeightedPercentileOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		sorted  []float64
+		weights []float64
+		want    map[int]float64
+	}{
+		{
+			// As if [1 1 1 10]: P75 sits at rank 2.25, between 1 and 10
+			name:    "repeated times",
+			sorted:  []float64{1, 10},
+			weights: []float64{3, 1},
+			want:    map[int]float64{0: 1, 50: 1, 75: 3.25, 100: 10},
+		},
+		{
+			name:    "unit weights",
+			sorted:  []float64{1, 2, 3, 4, 5},
+			weights: []float64{1, 1, 1, 1, 1},
+			want:    map[int]float64{0: 1, 50: 3, 75: 4, 95: 4.8, 100: 5},
+		},
+		{
+			// As if [1 1 10 10]; the time without weight is left out
+			name:    "zero weight",
+			sorted:  []float64{1, 5, 10},
+			weights: []float64{2, 0, 2},
+			want:    map[int]float64{50: 5.5, 100: 10},
+		},
+		{
+			name:    "no weight",
+			sorted:  []float64{1, 2},
+			weights: []float64{0, 0},
+			want:    map[int]float64{50: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for p, want := range tt.want {
+				if got := worker.WeightedPercentileOf(tt.sorted, tt.weights, p); !floatEqual(got, want) {
+					t.Errorf("P%d = %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocator_WeightByCases(t *testing.T) {
+	allocator := newAllocator(t, 2)
+	allocator.Distribute([]junit.Test{
+		{Name: "a", Time: 5, Cases: 1}, {Name: "b", Time: 4, Cases: 2}, {Name: "c", Time: 3, Cases: 10},
+		{Name: "d", Time: 2},
+	})
+
+	stats := allocator.GetStatsWithOptions(worker.StatsOptions{
+		IncludeTestTimes: true, SortTestTimes: true, WeightByCases: true,
+	})
+	// Worker 0 holds a and d, worker 1 b and c; the counts follow their sorted times
+	if got := stats.Workers[0].TestCases; got != nil {
+		t.Errorf("Worker 0 TestCases = %v, want none while d has no count", got)
+	}
+	if got, want := fmt.Sprint(stats.Workers[1].TestTimes, stats.Workers[1].TestCases), "[3 4] [10 2]"; got != want {
+		t.Errorf("Worker 1 times and cases = %s, want %s", got, want)
+	}
+
+	stats = allocator.GetStatsWithOptions(worker.StatsOptions{IncludeTestTimes: true})
+	if got := stats.Workers[1].TestCases; got != nil {
+		t.Errorf("TestCases without WeightByCases = %v, want none", got)
+	}
+}
+
+func TestAllocator_SuitePercentiles(t *testing.T) {
+	allocator := newAllocator(t, 2)
+	allocator.Distribute([]junit.Test{
+		{Name: "a", Time: 5}, {Name: "b", Time: 4}, {Name: "c", Time: 3}, {Name: "d", Time: 2}, {Name: "e", Time: 1},
+	})
+
+	// Computed over every test, not per worker, in the requested order
+	got := allocator.GetStatsWithOptions(worker.StatsOptions{SuitePercentiles: []int{95, 50}}).SuitePercentiles
+	want := []worker.Percentile{{Percentile: 95, Value: 4.8}, {Percentile: 50, Value: 3}}
+	if len(got) != len(want) {
+		t.Fatalf("SuitePercentiles = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Percentile != want[i].Percentile || !floatEqual(got[i].Value, want[i].Value) {
+			t.Errorf("SuitePercentiles[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if got := allocator.GetStats().SuitePercentiles; got != nil {
+		t.Errorf("SuitePercentiles without options = %v, want none", got)
+	}
+}
+
+func floatEqual(a, b float64) bool {
+	const epsilon = 1e-9
+	return a-b < epsilon && b-a < epsilon
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prgtw/tests-helper/internal/fileutil"
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+var (
+	// ErrInvalidResource is returned for a malformed resource rule or limit.
+	ErrInvalidResource = errors.New("invalid resource")
+	// ErrResourceLimit is returned when more tests carry a resource tag than the workers may take.
+	ErrResourceLimit = errors.New("resource limit cannot be met")
+)
+
+// ResourceRule tags the tests whose name matches Pattern with Tag. Pattern is a slash-separated
+// glob where "**" matches any number of directories.
+type ResourceRule struct {
+	Pattern string
+	Tag     string
+}
+
+// ParseResourceRule parses a rule written as "PATTERN=TAG", e.g. "tests/gpu/**=gpu".
+// The tag follows the last "=".
+func ParseResourceRule(s string) (ResourceRule, error) {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return ResourceRule{}, fmt.Errorf("%w rule %q: want PATTERN=TAG", ErrInvalidResource, s)
+	}
+	pattern, tag := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if pattern == "" || tag == "" {
+		return ResourceRule{}, fmt.Errorf("%w rule %q: want PATTERN=TAG", ErrInvalidResource, s)
+	}
+	return ResourceRule{Pattern: pattern, Tag: tag}, nil
+}
+
+// ParseResourceRules parses every rule with ParseResourceRule.
+func ParseResourceRules(raw []string) ([]ResourceRule, error) {
+	rules := make([]ResourceRule, 0, len(raw))
+	for _, s := range raw {
+		rule, err := ParseResourceRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ParseResourceLimits parses limits written as "TAG=N": at most N tests tagged TAG per worker.
+// N must be 0 or more, and every tag may be limited once.
+func ParseResourceLimits(raw []string) (map[string]int, error) {
+	limits := make(map[string]int, len(raw))
+	for _, s := range raw {
+		tag, value, ok := strings.Cut(s, "=")
+		tag = strings.TrimSpace(tag)
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || tag == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("%w limit %q: want TAG=N with N at least 0", ErrInvalidResource, s)
+		}
+		if _, dup := limits[tag]; dup {
+			return nil, fmt.Errorf("%w limit %q: %s is already limited", ErrInvalidResource, s, tag)
+		}
+		limits[tag] = n
+	}
+	return limits, nil
+}
+
+// TagResources sets the Resource of every test whose name matches a rule to the tag of the first
+// one; other tests keep theirs. It returns how many tests carry each tag.
+func TagResources(tests []junit.Test, rules []ResourceRule) map[string]int {
+	counts := make(map[string]int)
+	for i := range tests {
+		for _, rule := range rules {
+			if fileutil.MatchGlob(rule.Pattern, tests[i].Name) {
+				tests[i].Resource = rule.Tag
+				break
+			}
+		}
+		if tests[i].Resource != "" {
+			counts[tests[i].Resource]++
+		}
+	}
+	return counts
+}
+
+// CheckResourceLimits fails with ErrResourceLimit when a tag is carried by more tests than
+// workers may take together under limits. Otherwise a split respecting the limits exists, since
+// every test carries at most one tag.
+func CheckResourceLimits(counts, limits map[string]int, workers int) error {
+	for _, tag := range slices.Sorted(maps.Keys(limits)) {
+		if capacity := limits[tag] * workers; counts[tag] > capacity {
+			return fmt.Errorf("%w: %d tests tagged %s, but %d workers take at most %d each (%d in total)",
+				ErrResourceLimit, counts[tag], tag, workers, limits[tag], capacity)
+		}
+	}
+	return nil
+}
+
+// WithResourceLimits caps how many tests with the same Resource tag a worker may take.
+// Tags without a limit are not capped.
+func WithResourceLimits(limits map[string]int) Option {
+	return func(a *Allocator) {
+		a.limits = limits
+	}
+}
+
+// countResources returns how many tests of each tag every worker holds.
+func countResources(workers []Worker) []map[string]int {
+	counts := make([]map[string]int, len(workers))
+	for i := range workers {
+		counts[i] = workers[i].resources()
+	}
+	return counts
+}
+
+// resources returns how many tests of each tag w holds, nil when none is tagged.
+func (w *Worker) resources() map[string]int {
+	var counts map[string]int
+	for _, t := range w.Tests {
+		if t.Resource == "" {
+			continue
+		}
+		if counts == nil {
+			counts = make(map[string]int)
+		}
+		counts[t.Resource]++
+	}
+	return counts
+}
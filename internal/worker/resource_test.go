@@ -0,0 +1,205 @@
+package worker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+func TestParseResourceRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    worker.ResourceRule
+		wantErr bool
+	}{
+		{in: "tests/gpu/**=gpu", want: worker.ResourceRule{Pattern: "tests/gpu/**", Tag: "gpu"}},
+		{in: " *_device_test.go = device ", want: worker.ResourceRule{Pattern: "*_device_test.go", Tag: "device"}},
+		{in: "a=b=gpu", want: worker.ResourceRule{Pattern: "a=b", Tag: "gpu"}},
+		{in: "tests/gpu/**", wantErr: true},
+		{in: "=gpu", wantErr: true},
+		{in: "tests/**=", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := worker.ParseResourceRule(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, worker.ErrInvalidResource) {
+				t.Errorf("ParseResourceRule(%q) error = %v, want ErrInvalidResource", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseResourceRule(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseResourceLimits(t *testing.T) {
+	limits, err := worker.ParseResourceLimits([]string{"gpu=2", " db = 0 "})
+	if err != nil {
+		t.Fatalf("ParseResourceLimits failed: %v", err)
+	}
+	if want := map[string]int{"gpu": 2, "db": 0}; !maps.Equal(limits, want) {
+		t.Errorf("Limits = %v, want %v", limits, want)
+	}
+
+	for _, raw := range [][]string{{"gpu"}, {"gpu=-1"}, {"gpu=two"}, {"=2"}, {"gpu=1", "gpu=2"}} {
+		if _, err = worker.ParseResourceLimits(raw); !errors.Is(err, worker.ErrInvalidResource) {
+			t.Errorf("ParseResourceLimits(%q) error = %v, want ErrInvalidResource", raw, err)
+		}
+	}
+}
+
+func TestTagResources(t *testing.T) {
+	tests := []junit.Test{
+		{Name: "tests/gpu/render_test.go"},
+		{Name: "tests/gpu/db_test.go"},
+		{Name: "tests/api_test.go"},
+		{Name: "tests/preset_test.go", Resource: "db"},
+	}
+	rules := []worker.ResourceRule{
+		{Pattern: "tests/gpu/**", Tag: "gpu"},
+		{Pattern: "**/db_test.go", Tag: "db"},
+	}
+
+	counts := worker.TagResources(tests, rules)
+	want := []string{"gpu", "gpu", "", "db"} // The first matching rule wins; tags already set stay
+	for i, tag := range want {
+		if tests[i].Resource != tag {
+			t.Errorf("Test %s: resource = %q, want %q", tests[i].Name, tests[i].Resource, tag)
+		}
+	}
+	if wantCounts := map[string]int{"gpu": 2, "db": 1}; !maps.Equal(counts, wantCounts) {
+		t.Errorf("Counts = %v, want %v", counts, wantCounts)
+	}
+}
+
+func TestCheckResourceLimits(t *testing.T) {
+	counts := map[string]int{"gpu": 5, "db": 1, "net": 9}
+	if err := worker.CheckResourceLimits(counts, map[string]int{"gpu": 2, "db": 1}, 3); err != nil {
+		t.Errorf("5 gpu tests on 3 workers of 2: %v", err)
+	}
+	err := worker.CheckResourceLimits(counts, map[string]int{"gpu": 2}, 2)
+	if !errors.Is(err, worker.ErrResourceLimit) {
+		t.Errorf("5 gpu tests on 2 workers of 2: error = %v, want ErrResourceLimit", err)
+	}
+	if err = worker.CheckResourceLimits(counts, map[string]int{"db": 0}, 4); !errors.Is(err, worker.ErrResourceLimit) {
+		t.Errorf("db test with a limit of 0: error = %v, want ErrResourceLimit", err)
+	}
+}
+
+func TestAllocator_ResourceLimits(t *testing.T) {
+	tests := []junit.Test{
+		{Name: "a", Time: 10},
+		{Name: "g1", Time: 5, Resource: "gpu"},
+		{Name: "g2", Time: 4, Resource: "gpu"},
+		{Name: "b", Time: 1},
+	}
+
+	// Unlimited, both gpu tests end up on worker 1 next to the long untagged test
+	unlimited := newAllocator(t, 2)
+	unlimited.Distribute(tests)
+	if got := testNames(unlimited.GetWorkerRef(1).Tests); got != "g1 g2 b" {
+		t.Fatalf("Unlimited worker 1 = %q, want %q", got, "g1 g2 b")
+	}
+
+	a, err := worker.NewAllocator(2, worker.WithResourceLimits(map[string]int{"gpu": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Distribute(tests)
+	for i, want := range []string{"a g2", "g1 b"} {
+		if got := testNames(a.GetWorkerRef(i).Tests); got != want {
+			t.Errorf("Worker %d = %q, want %q", i, got, want)
+		}
+	}
+
+	stats := a.GetStats()
+	for i, ws := range stats.Workers {
+		if ws.Resources["gpu"] != 1 {
+			t.Errorf("Worker %d resources = %v, want one gpu test", i, ws.Resources)
+		}
+	}
+	if stats.ResourceLimits["gpu"] != 1 {
+		t.Errorf("ResourceLimits = %v", stats.ResourceLimits)
+	}
+}
+
+func TestAllocator_ResourceLimitsUntaggedAndUnlimited(t *testing.T) {
+	a, err := worker.NewAllocator(2, worker.WithResourceLimits(map[string]int{"gpu": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tags without a limit are placed by load alone
+	a.Distribute([]junit.Test{
+		{Name: "n1", Time: 10, Resource: "net"},
+		{Name: "n2", Time: 3, Resource: "net"},
+		{Name: "n3", Time: 2, Resource: "net"},
+	})
+	if got := testNames(a.GetWorkerRef(1).Tests); got != "n2 n3" {
+		t.Errorf("Worker 1 = %q, want %q", got, "n2 n3")
+	}
+	if got := a.GetStats().Workers[1].Resources["net"]; got != 2 {
+		t.Errorf("Worker 1 has %d net tests, want 2", got)
+	}
+}
+
+func TestAllocator_DrainResourceLimits(t *testing.T) {
+	groups := []worker.Worker{
+		{Tests: []junit.Test{{Name: "g0", Time: 3, Resource: "gpu"}}, Total: 3},
+		{Tests: []junit.Test{{Name: "g1", Time: 2, Resource: "gpu"}}, Total: 2},
+		{Tests: []junit.Test{{Name: "g2", Time: 1, Resource: "gpu"}, {Name: "x", Time: 1}}, Total: 2},
+	}
+
+	a, err := worker.NewAllocatorFrom(groups, worker.WithResourceLimits(map[string]int{"gpu": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Drain(0); !errors.Is(err, worker.ErrResourceLimit) {
+		t.Fatalf("Drain error = %v, want ErrResourceLimit", err)
+	}
+	if len(a.GetWorkersRef()) != 3 {
+		t.Errorf("A failed drain removed a worker")
+	}
+
+	// The least loaded remaining worker already holds a gpu test
+	groups = []worker.Worker{
+		{Tests: []junit.Test{{Name: "g0", Time: 3, Resource: "gpu"}}, Total: 3},
+		{Tests: []junit.Test{{Name: "x", Time: 1}}, Total: 1},
+		{Tests: []junit.Test{{Name: "g2", Time: 0.5, Resource: "gpu"}}, Total: 0.5},
+	}
+	a, err = worker.NewAllocatorFrom(groups, worker.WithResourceLimits(map[string]int{"gpu": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := a.Drain(0)
+	if err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if len(moved) != 1 || moved[0].Worker != 0 {
+		t.Errorf("Moved = %+v, want g0 on worker 0, the former worker 1", moved)
+	}
+}
+
+func TestWorker_JSONResource(t *testing.T) {
+	w := worker.Worker{Tests: []junit.Test{{Name: "g", Time: 2, Resource: "gpu"}, {Name: "x", Time: 1}}, Total: 3}
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"resource":"gpu"`) || strings.Count(string(data), "resource") != 1 {
+		t.Errorf("JSON = %s, want the tag of the tagged test only", data)
+	}
+
+	var got worker.Worker
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Tests[0].Resource != "gpu" || got.Tests[1].Resource != "" {
+		t.Errorf("Decoded tests = %+v", got.Tests)
+	}
+}
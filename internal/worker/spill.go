@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+)
+
+// keySameAsName flags a test whose key is its name, stored once.
+const keySameAsName = 1
+
+// errCorruptSpill is returned when a length read back from the spill file cannot be right.
+var errCorruptSpill = errors.New("corrupt spill file")
+
+// spillReader reads a section of the spill file, counting the bytes left so that lengths read
+// back are checked before anything is allocated for them.
+type spillReader struct {
+	r    *bufio.Reader
+	left int64
+}
+
+// newSpillReader returns a spillReader of the size bytes of section.
+func newSpillReader(section io.Reader, size int64) *spillReader {
+	return &spillReader{r: bufio.NewReader(section), left: size}
+}
+
+func (r *spillReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.left -= int64(n)
+	return n, err
+}
+
+func (r *spillReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.left--
+	}
+	return b, err
+}
+
+// readLength reads a length prefix, failing unless it is between 0 and the bytes left.
+func (r *spillReader) readLength() (int64, error) {
+	n, err := binary.ReadVarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > r.left {
+		return 0, fmt.Errorf("%w: length %d with %d bytes left", errCorruptSpill, n, r.left)
+	}
+	return n, nil
+}
+
+// appendAssignment appends the binary encoding of a spilled assignment to buf: the worker, the
+// total after it, and the test, see appendTest.
+func appendAssignment(buf []byte, a Assignment) []byte {
+	buf = binary.AppendVarint(buf, int64(a.Worker))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(a.TotalAfter))
+	return appendTest(buf, a.Test)
+}
+
+// appendTest appends the binary encoding of a test to buf. Integers are varints and strings are
+// length-prefixed. The key is omitted when it equals the name, and a group is encoded as its
+// member count plus one, 0 standing for none, followed by its members.
+func appendTest(buf []byte, test junit.Test) []byte {
+	var flags byte
+	if test.Key == test.Name {
+		flags |= keySameAsName
+	}
+	buf = append(buf, flags)
+	buf = appendString(buf, test.Name)
+	if flags&keySameAsName == 0 {
+		buf = appendString(buf, test.Key)
+	}
+	buf = appendString(buf, string(test.Source))
+	buf = appendString(buf, test.Resource)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(test.Time))
+	buf = binary.AppendVarint(buf, int64(test.Cases))
+	if test.Group == nil {
+		return binary.AppendVarint(buf, 0)
+	}
+	buf = binary.AppendVarint(buf, int64(len(test.Group.Members))+1)
+	for _, member := range test.Group.Members {
+		buf = appendTest(buf, member)
+	}
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+// readAssignment decodes an assignment encoded by appendAssignment.
+func readAssignment(r *spillReader) (Assignment, error) {
+	worker, err := binary.ReadVarint(r)
+	if err != nil {
+		return Assignment{}, err
+	}
+	total, err := readFloat(r)
+	if err != nil {
+		return Assignment{}, err
+	}
+	test, err := readTest(r)
+	if err != nil {
+		return Assignment{}, err
+	}
+	return Assignment{Test: test, Worker: int(worker), TotalAfter: total}, nil //nolint:gosec // Written from an int
+}
+
+// readTest decodes a test encoded by appendTest.
+func readTest(r *spillReader) (junit.Test, error) {
+	var test junit.Test
+	flags, err := r.ReadByte()
+	if err != nil {
+		return test, err
+	}
+	if test.Name, err = readString(r); err != nil {
+		return test, err
+	}
+	test.Key = test.Name
+	if flags&keySameAsName == 0 {
+		if test.Key, err = readString(r); err != nil {
+			return test, err
+		}
+	}
+	source, err := readString(r)
+	if err != nil {
+		return test, err
+	}
+	test.Source = junit.Source(source)
+	if test.Resource, err = readString(r); err != nil {
+		return test, err
+	}
+	if test.Time, err = readFloat(r); err != nil {
+		return test, err
+	}
+	cases, err := binary.ReadVarint(r)
+	if err != nil {
+		return test, err
+	}
+	test.Cases = int(cases) //nolint:gosec // Written from an int
+	return test, readGroup(r, &test)
+}
+
+// readGroup decodes the group of a test encoded by appendTest.
+func readGroup(r *spillReader, test *junit.Test) error {
+	// Every member takes at least a byte, so the count is checked like a length
+	n, err := r.readLength()
+	if err != nil || n == 0 {
+		return err
+	}
+	test.Group = &junit.Group{Members: make([]junit.Test, n-1)}
+	for i := range test.Group.Members {
+		if test.Group.Members[i], err = readTest(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readString(r *spillReader) (string, error) {
+	n, err := r.readLength()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func readFloat(r *spillReader) (float64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+}
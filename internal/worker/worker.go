@@ -1,9 +1,23 @@
 package worker
 
 import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"fmt"
 	"math"
+	"slices"
+	"sort"
 
 	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/progress"
+)
+
+var (
+	// ErrInvalidWorkerCount is returned when the number of workers is less than 1.
+	ErrInvalidWorkerCount = errors.New("invalid worker count")
+	// ErrInvalidWorkerIndex is returned when a worker index is outside [0, count).
+	ErrInvalidWorkerIndex = errors.New("invalid worker index")
 )
 
 // Worker represents a worker with assigned tests.
@@ -13,67 +27,405 @@ type Worker struct {
 }
 
 // Allocator handles distribution of tests across workers.
+//
+// GetWorker and GetWorkers return copies owned by the caller. GetWorkerRef and GetWorkersRef
+// avoid the copy but share the allocator's state, which must then be treated as read-only.
 type Allocator struct {
-	workers []Worker
+	observer  Observer
+	progress  progress.Reporter
+	limits    map[string]int // Resource tag limits per worker, see WithResourceLimits
+	algorithm Algorithm
+	workers   []Worker
+}
+
+// Option configures an Allocator.
+type Option func(*Allocator)
+
+// WithObserver sets an observer notified of every assignment made by Distribute.
+func WithObserver(o Observer) Option {
+	return func(a *Allocator) {
+		a.observer = o
+	}
+}
+
+// WithProgress sets a reporter notified every progress.DistributeInterval tests assigned by
+// Distribute, and once all are.
+func WithProgress(p progress.Reporter) Option {
+	return func(a *Allocator) {
+		a.progress = p
+	}
 }
 
 // NewAllocator creates a new worker allocator.
-func NewAllocator(numWorkers int) *Allocator {
-	return &Allocator{
-		workers: make([]Worker, numWorkers),
+// It fails with ErrInvalidWorkerCount when numWorkers is less than 1.
+func NewAllocator(numWorkers int, opts ...Option) (*Allocator, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("%w: need at least 1 worker, got %d", ErrInvalidWorkerCount, numWorkers)
 	}
+	a := &Allocator{
+		algorithm: AlgorithmGreedy,
+		workers:   make([]Worker, numWorkers),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
 }
 
-// Distribute distributes tests across workers using a greedy algorithm.
-// Tests should be sorted by time in descending order for best results.
+// NewAllocatorFrom creates an allocator holding copies of workers, e.g. decoded from a manifest,
+// so a plan computed earlier can be changed with Drain.
+// It fails with ErrInvalidWorkerCount when workers is empty.
+func NewAllocatorFrom(workers []Worker, opts ...Option) (*Allocator, error) {
+	a, err := NewAllocator(len(workers), opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range workers {
+		a.workers[i] = workers[i].clone()
+	}
+	return a, nil
+}
+
+// Distribute distributes tests across workers using the algorithm set by WithAlgorithm, greedy by
+// default. Tests should be sorted by time in descending order for best results.
+// The greedy algorithm tracks the least loaded worker with a min-heap, ties going to the lowest
+// index; a tagged test goes to the least loaded worker still below the limit of its tag. The other
+// algorithms choose the worker of every test up front, then place the tests in order.
+// The observer, if any, is notified after each assignment, the progress reporter at intervals.
 func (a *Allocator) Distribute(tests []junit.Test) {
-	for _, test := range tests {
-		// Find worker with minimum total time
-		minIdx := 0
-		for i := 1; i < len(a.workers); i++ {
-			if a.workers[i].Total < a.workers[minIdx].Total {
-				minIdx = i
+	if len(a.workers) == 0 {
+		return
+	}
+
+	// Pre-size worker slices to avoid repeated growth for large inputs
+	capacityHint := len(tests)/len(a.workers) + 1
+	for i := range a.workers {
+		if a.workers[i].Tests == nil {
+			a.workers[i].Tests = make([]junit.Test, 0, capacityHint)
+		}
+	}
+
+	owners := a.owners(tests)
+	var h *loadHeap
+	if owners == nil {
+		h = a.newHeap()
+	}
+	for i, test := range tests {
+		if owners == nil {
+			a.assign(h, test)
+		} else {
+			a.place(test, owners[i])
+		}
+		if a.progress != nil && (i+1)%progress.DistributeInterval == 0 {
+			a.progress.OnStage(progress.StageDistribute, i+1, len(tests))
+		}
+	}
+	if a.progress != nil && len(tests)%progress.DistributeInterval != 0 {
+		a.progress.OnStage(progress.StageDistribute, len(tests), len(tests))
+	}
+}
+
+// Drain removes the worker at index and places its tests, longest first, on the least loaded of
+// the remaining workers, which keep their own tests. Workers after index move down by one.
+// It returns the assignments of the moved tests, with their new worker indices, in placement
+// order; the observer, if any, is notified of each one.
+// It fails with ErrInvalidWorkerIndex for an index outside [0, count), with
+// ErrInvalidWorkerCount when the worker is the last one, and with ErrResourceLimit when the
+// remaining workers cannot take its tagged tests; the workers are then left unchanged.
+func (a *Allocator) Drain(index int) ([]Assignment, error) {
+	if index < 0 || index >= len(a.workers) {
+		return nil, fmt.Errorf("%w: %d (have %d workers)", ErrInvalidWorkerIndex, index, len(a.workers))
+	}
+	if len(a.workers) == 1 {
+		return nil, fmt.Errorf("%w: cannot drain the last worker", ErrInvalidWorkerCount)
+	}
+	if len(a.limits) > 0 {
+		counts := make(map[string]int)
+		for _, c := range countResources(a.workers) {
+			for tag, n := range c {
+				counts[tag] += n
 			}
 		}
+		if err := CheckResourceLimits(counts, a.limits, len(a.workers)-1); err != nil {
+			return nil, err
+		}
+	}
+
+	moved := slices.Clone(a.workers[index].Tests)
+	slices.SortStableFunc(moved, func(x, y junit.Test) int { return cmp.Compare(y.Time, x.Time) })
+	a.workers = slices.Delete(a.workers, index, index+1)
 
-		// Assign test to worker with minimum load
-		a.workers[minIdx].Tests = append(a.workers[minIdx].Tests, test)
-		a.workers[minIdx].Total += test.Time
+	h := a.newHeap()
+	assignments := make([]Assignment, 0, len(moved))
+	for _, test := range moved {
+		assignments = append(assignments, a.assign(h, test))
 	}
+	return assignments, nil
+}
+
+// loadHeap is a min-heap of worker indices ordered by total time, then by index.
+type loadHeap struct {
+	limits    map[string]int
+	workers   []Worker
+	indices   []int
+	resources []map[string]int // Tests of each tag per worker, tracked when limits are set
 }
 
-// GetWorker returns the worker at the specified index.
+// newLoadHeap creates a heap over all workers.
+func newLoadHeap(workers []Worker) *loadHeap {
+	h := &loadHeap{
+		workers: workers,
+		indices: make([]int, len(workers)),
+	}
+	for i := range h.indices {
+		h.indices[i] = i
+	}
+	heap.Init(h)
+	return h
+}
+
+func (h *loadHeap) Len() int { return len(h.indices) }
+
+func (h *loadHeap) Less(i, j int) bool {
+	wi, wj := h.indices[i], h.indices[j]
+	if h.workers[wi].Total != h.workers[wj].Total {
+		return h.workers[wi].Total < h.workers[wj].Total
+	}
+	return wi < wj
+}
+
+func (h *loadHeap) Swap(i, j int) { h.indices[i], h.indices[j] = h.indices[j], h.indices[i] }
+
+func (h *loadHeap) Push(x any) { h.indices = append(h.indices, x.(int)) }
+
+func (h *loadHeap) Pop() any {
+	n := len(h.indices)
+	x := h.indices[n-1]
+	h.indices = h.indices[:n-1]
+	return x
+}
+
+// popFull pops the workers at the top of h that hold as many tests tagged tag as its limit
+// allows, so that the top can take one more, and returns them. When every worker is full, which
+// CheckResourceLimits rules out, they are all kept and the least loaded worker goes over.
+func (h *loadHeap) popFull(tag string) []int {
+	limit, ok := h.limits[tag]
+	if tag == "" || !ok {
+		return nil
+	}
+	var full []int
+	for h.Len() > 0 && h.resources[h.indices[0]][tag] >= limit {
+		full = append(full, heap.Pop(h).(int))
+	}
+	if h.Len() == 0 {
+		h.pushAll(full)
+		return nil
+	}
+	return full
+}
+
+// pushAll pushes the workers popped by popFull back.
+func (h *loadHeap) pushAll(indices []int) {
+	for _, i := range indices {
+		heap.Push(h, i)
+	}
+}
+
+// take records a test tagged tag on the worker at index.
+func (h *loadHeap) take(index int, tag string) {
+	if h.resources == nil || tag == "" {
+		return
+	}
+	if h.resources[index] == nil {
+		h.resources[index] = make(map[string]int)
+	}
+	h.resources[index][tag]++
+}
+
+// Trim cuts every worker down to budget seconds by deferring its cheapest tests, keeping the
+// order of the rest. A worker's most expensive test is never deferred, so a worker whose
+// longest test alone exceeds budget keeps that test and stays over it. It returns the deferred
+// tests of every worker, in their original order, nil for workers already within budget.
+func (a *Allocator) Trim(budget float64) [][]junit.Test {
+	deferred := make([][]junit.Test, len(a.workers))
+	for i := range a.workers {
+		deferred[i] = a.workers[i].trim(budget)
+	}
+	return deferred
+}
+
+// GetWorker returns a copy of the worker at the specified index, or nil if there is none.
+// The caller owns the returned worker and may modify it freely.
 func (a *Allocator) GetWorker(index int) *Worker {
+	w := a.GetWorkerRef(index)
+	if w == nil {
+		return nil
+	}
+	clone := w.clone()
+	return &clone
+}
+
+// GetWorkerRef returns the allocator's own worker at the specified index, or nil if there is none.
+// The worker is shared with the allocator and must not be modified.
+func (a *Allocator) GetWorkerRef(index int) *Worker {
 	if index < 0 || index >= len(a.workers) {
 		return nil
 	}
 	return &a.workers[index]
 }
 
-// GetWorkers returns all workers.
+// GetWorkers returns copies of all workers. The caller owns the returned slice.
 func (a *Allocator) GetWorkers() []Worker {
+	workers := make([]Worker, len(a.workers))
+	for i := range a.workers {
+		workers[i] = a.workers[i].clone()
+	}
+	return workers
+}
+
+// GetWorkersRef returns the allocator's own workers without copying.
+// The slice and its workers are shared with the allocator and must not be modified.
+func (a *Allocator) GetWorkersRef() []Worker {
 	return a.workers
 }
 
+// trim defers the cheapest tests of w, except its most expensive one, until its total fits
+// budget, and returns them.
+func (w *Worker) trim(budget float64) []junit.Test {
+	if w.Total <= budget || len(w.Tests) < 2 {
+		return nil
+	}
+
+	longest := 0
+	for i, t := range w.Tests {
+		if t.Time > w.Tests[longest].Time {
+			longest = i
+		}
+	}
+	cheapest := make([]int, len(w.Tests))
+	for i := range cheapest {
+		cheapest[i] = i
+	}
+	slices.SortStableFunc(cheapest, func(x, y int) int { return cmp.Compare(w.Tests[x].Time, w.Tests[y].Time) })
+
+	drop := make([]bool, len(w.Tests))
+	total := w.Total
+	for _, i := range cheapest {
+		if total <= budget {
+			break
+		}
+		if i != longest {
+			drop[i] = true
+			total -= w.Tests[i].Time
+		}
+	}
+
+	var kept, deferred []junit.Test
+	w.Total = 0
+	for i, t := range w.Tests {
+		if drop[i] {
+			deferred = append(deferred, t)
+			continue
+		}
+		kept = append(kept, t)
+		w.Total += t.Time
+	}
+	w.Tests = kept
+	return deferred
+}
+
+// clone returns a deep copy of w.
+func (w *Worker) clone() Worker {
+	return Worker{Tests: slices.Clone(w.Tests), Total: w.Total}
+}
+
 // Distribution returns statistics about worker distribution.
+//
+// It encodes to JSON with the computed Imbalance and Efficiency alongside the stored fields.
 type Distribution struct {
-	TotalTime float64
-	AvgTime   float64
-	Workers   []Stats
+	TotalTime float64 `json:"total_time"`
+	AvgTime   float64 `json:"avg_time"`
+	Workers   []Stats `json:"workers"`
+	// SuitePercentiles are percentiles of every test's time, across all workers, as requested by
+	// StatsOptions.SuitePercentiles. Unlike the per-worker times they do not depend on the split.
+	SuitePercentiles []Percentile `json:"suite_percentiles,omitempty"`
+	// Oversized lists the tests taking longer than AvgTime on their own, longest first. No split
+	// can bring the run below their time, so the busiest worker is at least that long.
+	Oversized []OversizedTest `json:"oversized_tests,omitempty"`
+	// ResourceLimits are the per-worker limits of resource tags the split respected.
+	ResourceLimits map[string]int `json:"resource_limits,omitempty"`
+}
+
+// OversizedTest is a test whose time alone exceeds the average worker budget.
+type OversizedTest struct {
+	Name   string  `json:"name"`
+	Time   float64 `json:"time"`
+	Excess float64 `json:"excess"` // Time minus the budget
+	Worker int     `json:"worker"`
 }
 
 // Stats represents statistics for a single worker.
 type Stats struct {
-	Index     int
-	Total     float64
-	TestCount int
-	MinTime   float64
-	MaxTime   float64
-	TestTimes []float64
+	Index     int       `json:"index"`
+	Total     float64   `json:"total"`
+	TestCount int       `json:"test_count"`
+	MinTime   float64   `json:"min_time"`
+	MaxTime   float64   `json:"max_time"`
+	TestTimes []float64 `json:"test_times,omitempty"`
+	// Resources counts the tests of each resource tag, nil when none is tagged.
+	Resources map[string]int `json:"resources,omitempty"`
+	// TestTimesSorted reports whether TestTimes is sorted in ascending order.
+	TestTimesSorted bool `json:"test_times_sorted,omitempty"`
+	// TestCases holds the test cases of each test of TestTimes, in the same order, when
+	// StatsOptions.WeightByCases is set and every test of the worker has a known count.
+	TestCases []float64 `json:"test_cases,omitempty"`
+	// Directories are the directories holding most of the worker's time, longest first, when the
+	// summary rolls tests up by directory. Stats never fills them.
+	Directories []DirTime `json:"directories,omitempty"`
+	// Tests are all of the worker's tests, longest first, when the summary details workers.
+	// Stats never fills them.
+	Tests []TestTime `json:"tests,omitempty"`
+}
+
+// DirTime is the predicted time of the tests of a worker under one directory.
+type DirTime struct {
+	Dir   string  `json:"dir"`
+	Time  float64 `json:"time"`
+	Tests int     `json:"tests"`
+}
+
+// TestTime is a test of a worker with the total time of the tests listed up to and including it.
+type TestTime struct {
+	Name       string  `json:"name"`
+	Time       float64 `json:"time"`
+	Cumulative float64 `json:"cumulative"`
+}
+
+// StatsOptions controls which optional data GetStatsWithOptions collects.
+type StatsOptions struct {
+	// IncludeTestTimes populates Stats.TestTimes with every assigned test's time.
+	IncludeTestTimes bool
+	// SuitePercentiles lists the percentiles computed into Distribution.SuitePercentiles.
+	SuitePercentiles []int
+	// SortTestTimes sorts Stats.TestTimes in ascending order; requires IncludeTestTimes.
+	SortTestTimes bool
+	// WeightByCases populates Stats.TestCases for workers whose tests all have a test case count;
+	// requires IncludeTestTimes.
+	WeightByCases bool
+}
+
+// DefaultStatsOptions returns the options used by GetStats.
+func DefaultStatsOptions() StatsOptions {
+	return StatsOptions{IncludeTestTimes: true}
 }
 
 // GetStats calculates distribution statistics.
 func (a *Allocator) GetStats() Distribution {
+	return a.GetStatsWithOptions(DefaultStatsOptions())
+}
+
+// GetStatsWithOptions calculates distribution statistics, collecting only the optional data requested.
+func (a *Allocator) GetStatsWithOptions(opts StatsOptions) Distribution {
 	var totalTime float64
 	workerStats := make([]Stats, len(a.workers))
 
@@ -82,10 +434,7 @@ func (a *Allocator) GetStats() Distribution {
 
 		minTime := math.MaxFloat64
 		maxTime := 0.0
-		testTimes := make([]float64, len(w.Tests))
-
-		for j, t := range w.Tests {
-			testTimes[j] = t.Time
+		for _, t := range w.Tests {
 			if t.Time < minTime {
 				minTime = t.Time
 			}
@@ -99,19 +448,144 @@ func (a *Allocator) GetStats() Distribution {
 			minTime = 0
 		}
 
+		testTimes, testCases := testTimesOf(w.Tests, opts)
+		sorted := testTimes != nil && opts.SortTestTimes
+		if sorted {
+			sortByTime(testTimes, testCases)
+		}
+
 		workerStats[i] = Stats{
-			Index:     i,
-			Total:     w.Total,
-			TestCount: len(w.Tests),
-			MinTime:   minTime,
-			MaxTime:   maxTime,
-			TestTimes: testTimes,
+			Index:           i,
+			Total:           w.Total,
+			TestCount:       len(w.Tests),
+			MinTime:         minTime,
+			MaxTime:         maxTime,
+			TestTimes:       testTimes,
+			Resources:       w.resources(),
+			TestTimesSorted: sorted,
+			TestCases:       testCases,
 		}
 	}
 
+	// An allocator without workers has nothing to average
+	avgTime := 0.0
+	if len(a.workers) > 0 {
+		avgTime = totalTime / float64(len(a.workers))
+	}
+
 	return Distribution{
-		TotalTime: totalTime,
-		AvgTime:   totalTime / float64(len(a.workers)),
-		Workers:   workerStats,
+		TotalTime:        totalTime,
+		AvgTime:          avgTime,
+		Workers:          workerStats,
+		SuitePercentiles: a.suitePercentiles(opts.SuitePercentiles),
+		Oversized:        a.oversized(avgTime),
+		ResourceLimits:   a.limits,
+	}
+}
+
+// testTimesOf returns the times of tests when opts includes them, and their test case counts
+// when opts weighs by them and every test has one, nil otherwise.
+func testTimesOf(tests []junit.Test, opts StatsOptions) ([]float64, []float64) {
+	if !opts.IncludeTestTimes {
+		return nil, nil
+	}
+	times := make([]float64, len(tests))
+	var cases []float64
+	if opts.WeightByCases && len(tests) > 0 {
+		cases = make([]float64, len(tests))
+	}
+	for i, t := range tests {
+		times[i] = t.Time
+		if cases == nil {
+			continue
+		}
+		if t.Cases <= 0 {
+			cases = nil
+			continue
+		}
+		cases[i] = float64(t.Cases)
+	}
+	return times, cases
+}
+
+// sortByTime sorts times in ascending order, moving the test case counts in cases, when not nil,
+// along with them.
+func sortByTime(times, cases []float64) {
+	if cases == nil {
+		sort.Float64s(times)
+		return
+	}
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(times[a], times[b]) })
+	sortedTimes, sortedCases := make([]float64, len(times)), make([]float64, len(cases))
+	for i, j := range order {
+		sortedTimes[i], sortedCases[i] = times[j], cases[j]
+	}
+	copy(times, sortedTimes)
+	copy(cases, sortedCases)
+}
+
+// newHeap creates the load heap of the workers, tracking their resource tags when limits are set.
+func (a *Allocator) newHeap() *loadHeap {
+	h := newLoadHeap(a.workers)
+	if len(a.limits) > 0 {
+		h.limits = a.limits
+		h.resources = countResources(a.workers)
+	}
+	return h
+}
+
+// assign gives test to the least loaded worker that may take its resource tag, the top of h
+// once full workers are popped, and notifies the observer.
+func (a *Allocator) assign(h *loadHeap, test junit.Test) Assignment {
+	full := h.popFull(test.Resource)
+	minIdx := h.indices[0]
+	assignment := a.place(test, minIdx)
+	h.take(minIdx, test.Resource)
+	heap.Fix(h, 0)
+	h.pushAll(full)
+	return assignment
+}
+
+// place appends test to the worker at index and notifies the observer.
+func (a *Allocator) place(test junit.Test, index int) Assignment {
+	a.workers[index].Tests = append(a.workers[index].Tests, test)
+	a.workers[index].Total += test.Time
+
+	if a.observer != nil {
+		a.observer.OnAssign(test, index, a.workers[index].Total)
+	}
+	return Assignment{Test: test, Worker: index, TotalAfter: a.workers[index].Total}
+}
+
+// suitePercentiles computes the percentiles ps over the times of every assigned test.
+func (a *Allocator) suitePercentiles(ps []int) []Percentile {
+	if len(ps) == 0 {
+		return nil
+	}
+	var times []float64
+	for i := range a.workers {
+		for _, t := range a.workers[i].Tests {
+			times = append(times, t.Time)
+		}
+	}
+	sort.Float64s(times)
+	return percentilesOf(times, ps)
+}
+
+// oversized returns the tests taking longer than budget, longest first.
+func (a *Allocator) oversized(budget float64) []OversizedTest {
+	var out []OversizedTest
+	for i := range a.workers {
+		for _, t := range a.workers[i].Tests {
+			if t.Time > budget {
+				out = append(out, OversizedTest{Name: t.Name, Time: t.Time, Excess: t.Time - budget, Worker: i})
+			}
+		}
 	}
+	slices.SortStableFunc(out, func(x, y OversizedTest) int { return cmp.Compare(y.Time, x.Time) })
+	return out
 }
@@ -1,6 +1,12 @@
 package worker_test
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/prgtw/tests-helper/internal/junit"
@@ -67,7 +73,7 @@ func TestAllocator_Distribute(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			allocator := worker.NewAllocator(tt.numWorkers)
+			allocator := newAllocator(t, tt.numWorkers)
 			allocator.Distribute(tt.tests)
 
 			for i := range tt.numWorkers {
@@ -89,7 +95,7 @@ func TestAllocator_GetWorker(t *testing.T) {
 		{Name: "test2", Time: 20.0},
 	}
 
-	allocator := worker.NewAllocator(2)
+	allocator := newAllocator(t, 2)
 	allocator.Distribute(tests)
 
 	t.Run("valid index", func(t *testing.T) {
@@ -117,6 +123,43 @@ func TestAllocator_GetWorker(t *testing.T) {
 	})
 }
 
+func TestAllocator_ReturnsCopies(t *testing.T) {
+	tests := []junit.Test{
+		{Name: "test1", Time: 10.0},
+		{Name: "test2", Time: 20.0},
+		{Name: "test3", Time: 5.0},
+	}
+
+	allocator := newAllocator(t, 2)
+	allocator.Distribute(tests)
+	before := allocator.GetStats()
+
+	w := allocator.GetWorker(0)
+	w.Tests[0].Name = "mutated"
+	w.Tests = append(w.Tests, junit.Test{Name: "extra", Time: 100.0})
+	w.Total += 100.0
+
+	workers := allocator.GetWorkers()
+	workers[1].Tests = nil
+	workers[1].Total = 0
+
+	ref := allocator.GetWorkerRef(0)
+	if ref.Tests[0].Name == "mutated" || len(ref.Tests) != before.Workers[0].TestCount {
+		t.Errorf("Mutating GetWorker's result changed the allocator: %+v", ref.Tests)
+	}
+
+	after := allocator.GetStats()
+	for i := range before.Workers {
+		if after.Workers[i].Total != before.Workers[i].Total ||
+			after.Workers[i].TestCount != before.Workers[i].TestCount {
+			t.Errorf("Worker %d stats changed: before %+v, after %+v", i, before.Workers[i], after.Workers[i])
+		}
+	}
+	if len(allocator.GetWorkersRef()[1].Tests) == 0 {
+		t.Error("Mutating GetWorkers' result changed the allocator")
+	}
+}
+
 //nolint:gocognit // Don't care
 func TestAllocator_GetStats(t *testing.T) {
 	tests := []junit.Test{
@@ -126,7 +169,7 @@ func TestAllocator_GetStats(t *testing.T) {
 		{Name: "test4", Time: 2.0},
 	}
 
-	allocator := worker.NewAllocator(2)
+	allocator := newAllocator(t, 2)
 	allocator.Distribute(tests)
 
 	stats := allocator.GetStats()
@@ -187,7 +230,7 @@ func TestAllocator_GetStats(t *testing.T) {
 }
 
 func TestAllocator_EmptyTests(t *testing.T) {
-	allocator := worker.NewAllocator(3)
+	allocator := newAllocator(t, 3)
 	allocator.Distribute([]junit.Test{})
 
 	stats := allocator.GetStats()
@@ -209,8 +252,44 @@ func TestAllocator_EmptyTests(t *testing.T) {
 	}
 }
 
+func TestNewAllocator_InvalidCount(t *testing.T) {
+	for _, n := range []int{0, -3} {
+		allocator, err := worker.NewAllocator(n)
+		if !errors.Is(err, worker.ErrInvalidWorkerCount) {
+			t.Errorf("NewAllocator(%d): got %v, want ErrInvalidWorkerCount", n, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), fmt.Sprintf("got %d", n)) {
+			t.Errorf("NewAllocator(%d): error %q should name the count", n, err)
+		}
+		if allocator != nil {
+			t.Errorf("NewAllocator(%d) returned an allocator", n)
+		}
+	}
+}
+
+func TestAllocator_ZeroWorkers(t *testing.T) {
+	// The zero value has no workers and must not produce NaN statistics
+	var allocator worker.Allocator
+	allocator.Distribute([]junit.Test{{Name: "test1", Time: 10.0}})
+
+	stats := allocator.GetStats()
+
+	if math.IsNaN(stats.AvgTime) || stats.AvgTime != 0 {
+		t.Errorf("AvgTime: got %v, want 0", stats.AvgTime)
+	}
+	if stats.TotalTime != 0 {
+		t.Errorf("TotalTime: got %v, want 0", stats.TotalTime)
+	}
+	if len(stats.Workers) != 0 {
+		t.Errorf("Workers: got %d, want 0", len(stats.Workers))
+	}
+	if allocator.GetWorker(0) != nil {
+		t.Error("GetWorker(0) should return nil")
+	}
+}
+
 func TestAllocator_BalancedDistribution(t *testing.T) {
-	// Test that the greedy algorithm produces reasonable balance
+	// Test that every algorithm produces reasonable balance
 	tests := []junit.Test{
 		{Name: "t1", Time: 100.0},
 		{Name: "t2", Time: 90.0},
@@ -224,10 +303,22 @@ func TestAllocator_BalancedDistribution(t *testing.T) {
 		{Name: "t10", Time: 10.0},
 	}
 
-	allocator := worker.NewAllocator(3)
-	allocator.Distribute(tests)
+	for _, alg := range algorithms() {
+		t.Run(string(alg), func(t *testing.T) {
+			allocator, err := worker.NewAllocator(3, worker.WithAlgorithm(alg))
+			if err != nil {
+				t.Fatalf("NewAllocator failed: %v", err)
+			}
+			allocator.Distribute(tests)
+			checkBalanced(t, allocator.GetStats())
+		})
+	}
+}
 
-	stats := allocator.GetStats()
+// checkBalanced fails t when the busiest and the idlest workers differ by more than 30% of the
+// average worker time.
+func checkBalanced(t *testing.T, stats worker.Distribution) {
+	t.Helper()
 
 	// Calculate the maximum difference between workers
 	minWorkerTime := stats.Workers[0].Total
@@ -253,3 +344,283 @@ func TestAllocator_BalancedDistribution(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkDistribute(b *testing.B) {
+	const numWorkers = 200
+
+	for _, size := range []int{10_000, 100_000} {
+		tests := generateTests(size)
+
+		for _, alg := range algorithms() {
+			b.Run(fmt.Sprintf("%s/tests=%d/workers=%d", alg, size, numWorkers), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					allocator, err := worker.NewAllocator(numWorkers, worker.WithAlgorithm(alg))
+					if err != nil {
+						b.Fatalf("NewAllocator failed: %v", err)
+					}
+					allocator.Distribute(tests)
+				}
+			})
+		}
+	}
+}
+
+// newAllocator creates an allocator for n workers, failing the test on error.
+func newAllocator(tb testing.TB, n int) *worker.Allocator {
+	tb.Helper()
+	allocator, err := worker.NewAllocator(n)
+	if err != nil {
+		tb.Fatalf("NewAllocator(%d) failed: %v", n, err)
+	}
+	return allocator
+}
+
+// generateTests builds a deterministic list of tests sorted by descending time.
+func generateTests(n int) []junit.Test {
+	rng := rand.New(rand.NewPCG(1, 2))
+	tests := make([]junit.Test, n)
+	for i := range tests {
+		tests[i] = junit.Test{
+			Name: fmt.Sprintf("pkg/module%d/file%d_test.go", i%100, i),
+			Time: rng.Float64() * 10,
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Time > tests[j].Time
+	})
+	return tests
+}
+
+func TestAllocator_GetStatsWithOptions(t *testing.T) {
+	allocator := newAllocator(t, 2)
+	allocator.Distribute([]junit.Test{
+		{Name: "test1", Time: 10.0},
+		{Name: "test2", Time: 5.0},
+		{Name: "test3", Time: 3.0},
+	})
+
+	t.Run("without test times", func(t *testing.T) {
+		stats := allocator.GetStatsWithOptions(worker.StatsOptions{})
+		for i, ws := range stats.Workers {
+			if ws.TestTimes != nil {
+				t.Errorf("Worker %d: TestTimes should be nil, got %v", i, ws.TestTimes)
+			}
+			if ws.TestCount == 0 || ws.MinTime <= 0 || ws.MaxTime <= 0 {
+				t.Errorf("Worker %d: expected count, min and max to be populated, got %+v", i, ws)
+			}
+		}
+		if stats.TotalTime != 18.0 {
+			t.Errorf("TotalTime: got %.1f, want 18.0", stats.TotalTime)
+		}
+	})
+
+	t.Run("sorted test times", func(t *testing.T) {
+		stats := allocator.GetStatsWithOptions(worker.StatsOptions{IncludeTestTimes: true, SortTestTimes: true})
+		for i, ws := range stats.Workers {
+			if !ws.TestTimesSorted {
+				t.Errorf("Worker %d: TestTimesSorted should be set", i)
+			}
+			if !sort.Float64sAreSorted(ws.TestTimes) {
+				t.Errorf("Worker %d: TestTimes not sorted: %v", i, ws.TestTimes)
+			}
+		}
+	})
+
+	t.Run("default options match GetStats", func(t *testing.T) {
+		stats := allocator.GetStatsWithOptions(worker.DefaultStatsOptions())
+		for i, ws := range stats.Workers {
+			if len(ws.TestTimes) != ws.TestCount {
+				t.Errorf("Worker %d: TestTimes length %d != TestCount %d", i, len(ws.TestTimes), ws.TestCount)
+			}
+		}
+	})
+}
+
+func TestAllocator_Drain(t *testing.T) {
+	allocator := newAllocator(t, 4)
+	allocator.Distribute([]junit.Test{
+		{Name: "a", Time: 10}, {Name: "b", Time: 9}, {Name: "c", Time: 8}, {Name: "d", Time: 7},
+		{Name: "e", Time: 3}, {Name: "f", Time: 2}, {Name: "g", Time: 1},
+	})
+	before := allocator.GetWorkers()
+
+	moved, err := allocator.Drain(1)
+	if err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	// Every worker held 10s, worker 1 with b and g; b goes to the lowest index among the
+	// tied survivors, g to the next one, now at index 1 after the removal
+	var got []string
+	for _, m := range moved {
+		got = append(got, fmt.Sprintf("%s->%d", m.Test.Name, m.Worker))
+	}
+	if want := "b->0 g->1"; strings.Join(got, " ") != want {
+		t.Errorf("Moved = %v, want %s", got, want)
+	}
+
+	workers := allocator.GetWorkers()
+	if len(workers) != 3 {
+		t.Fatalf("Workers = %d, want 3", len(workers))
+	}
+	// The survivors keep their original tests, the moved ones appended after them
+	for i, original := range []worker.Worker{before[0], before[2], before[3]} {
+		kept := workers[i].Tests[:len(original.Tests)]
+		for j := range kept {
+			if kept[j] != original.Tests[j] {
+				t.Errorf("Worker %d test %d = %v, want %v", i, j, kept[j], original.Tests[j])
+			}
+		}
+	}
+
+	stats := allocator.GetStats()
+	if len(stats.Workers) != 3 || stats.TotalTime != 40 {
+		t.Errorf("Stats = %d workers, %.1f total; want 3 and 40", len(stats.Workers), stats.TotalTime)
+	}
+	for i, ws := range stats.Workers {
+		if ws.Index != i || ws.Total != workers[i].Total {
+			t.Errorf("Stats of worker %d = %+v, want total %.1f", i, ws, workers[i].Total)
+		}
+	}
+}
+
+func TestAllocator_DrainErrors(t *testing.T) {
+	allocator := newAllocator(t, 2)
+	for _, index := range []int{-1, 2} {
+		if _, err := allocator.Drain(index); !errors.Is(err, worker.ErrInvalidWorkerIndex) {
+			t.Errorf("Drain(%d) error = %v, want ErrInvalidWorkerIndex", index, err)
+		}
+	}
+	if _, err := allocator.Drain(0); err != nil {
+		t.Fatalf("Drain(0) failed: %v", err)
+	}
+	if _, err := allocator.Drain(0); !errors.Is(err, worker.ErrInvalidWorkerCount) {
+		t.Errorf("Draining the last worker error = %v, want ErrInvalidWorkerCount", err)
+	}
+}
+
+func TestNewAllocatorFrom(t *testing.T) {
+	workers := []worker.Worker{{Tests: []junit.Test{{Name: "a", Time: 2}}, Total: 2}, {}}
+	allocator, err := worker.NewAllocatorFrom(workers)
+	if err != nil {
+		t.Fatalf("NewAllocatorFrom failed: %v", err)
+	}
+	allocator.Distribute([]junit.Test{{Name: "b", Time: 1}})
+	if len(workers[1].Tests) != 0 || allocator.GetWorkerRef(1).Total != 1 {
+		t.Errorf("Workers = %+v, want the input untouched and b on worker 1", allocator.GetWorkersRef())
+	}
+	if _, err = worker.NewAllocatorFrom(nil); !errors.Is(err, worker.ErrInvalidWorkerCount) {
+		t.Errorf("NewAllocatorFrom(nil) error = %v, want ErrInvalidWorkerCount", err)
+	}
+}
+
+func TestAllocator_Oversized(t *testing.T) {
+	tests := []struct {
+		name  string
+		tests []junit.Test
+		want  []string
+	}{
+		{
+			// Average 6s per worker
+			name:  "no offender",
+			tests: []junit.Test{{Name: "a", Time: 6}, {Name: "b", Time: 5}, {Name: "c", Time: 4}, {Name: "d", Time: 3}},
+		},
+		{
+			// Average 10s per worker
+			name:  "one offender",
+			tests: []junit.Test{{Name: "big", Time: 25}, {Name: "b", Time: 3}, {Name: "c", Time: 2}},
+			want:  []string{"big 25.0 +15.0 @0"},
+		},
+		{
+			// Average 30s per worker
+			name: "multiple offenders",
+			tests: []junit.Test{
+				{Name: "huge", Time: 50}, {Name: "big", Time: 35}, {Name: "c", Time: 3}, {Name: "d", Time: 2},
+			},
+			want: []string{"huge 50.0 +20.0 @0", "big 35.0 +5.0 @1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocator := newAllocator(t, 3)
+			allocator.Distribute(tt.tests)
+
+			var got []string
+			for _, o := range allocator.GetStats().Oversized {
+				got = append(got, fmt.Sprintf("%s %.1f +%.1f @%d", o.Name, o.Time, o.Excess, o.Worker))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Oversized = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocator_Trim(t *testing.T) {
+	tests := []struct {
+		name         string
+		tests        []junit.Test
+		wantKept     string
+		wantDeferred string
+		wantTotal    float64
+	}{
+		{
+			name:      "under budget",
+			tests:     []junit.Test{{Name: "a", Time: 4}, {Name: "b", Time: 3}, {Name: "c", Time: 2}},
+			wantKept:  "a b c",
+			wantTotal: 9,
+		},
+		{
+			name: "cheapest deferred first",
+			tests: []junit.Test{
+				{Name: "a", Time: 5}, {Name: "b", Time: 1}, {Name: "c", Time: 4},
+				{Name: "d", Time: 2}, {Name: "e", Time: 1},
+			},
+			wantKept:     "a c",
+			wantDeferred: "b d e",
+			wantTotal:    9,
+		},
+		{
+			name:         "one huge test",
+			tests:        []junit.Test{{Name: "small", Time: 1}, {Name: "huge", Time: 60}, {Name: "tiny", Time: 0.5}},
+			wantKept:     "huge",
+			wantDeferred: "small tiny",
+			wantTotal:    60,
+		},
+		{
+			name:      "single test over budget",
+			tests:     []junit.Test{{Name: "huge", Time: 60}},
+			wantKept:  "huge",
+			wantTotal: 60,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocator := newAllocator(t, 1)
+			allocator.Distribute(tt.tests)
+
+			deferred := allocator.Trim(10)
+
+			w := allocator.GetWorkerRef(0)
+			if got := testNames(w.Tests); got != tt.wantKept {
+				t.Errorf("Kept = %q, want %q", got, tt.wantKept)
+			}
+			if got := testNames(deferred[0]); got != tt.wantDeferred {
+				t.Errorf("Deferred = %q, want %q", got, tt.wantDeferred)
+			}
+			if w.Total != tt.wantTotal || allocator.GetStats().TotalTime != tt.wantTotal {
+				t.Errorf("Total = %.1f, stats %.1f; want %.1f", w.Total, allocator.GetStats().TotalTime, tt.wantTotal)
+			}
+		})
+	}
+}
+
+// testNames returns the names of tests, space-separated.
+func testNames(tests []junit.Test) string {
+	names := make([]string, len(tests))
+	for i, test := range tests {
+		names[i] = test.Name
+	}
+	return strings.Join(names, " ")
+}
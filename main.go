@@ -4,6 +4,13 @@ import (
 	"github.com/prgtw/tests-helper/cmd"
 )
 
+// Build information, replaced by GoReleaser through ldflags.
+var (
+	version = "snapshot"         //nolint:gochecknoglobals // replaced by GoReleaser
+	commit  = "<commit-unknown>" //nolint:gochecknoglobals // replaced by GoReleaser
+	date    = "unknown"          //nolint:gochecknoglobals // replaced by GoReleaser
+)
+
 func main() {
-	cmd.Execute()
+	cmd.Execute(cmd.BuildInfo{Version: version, Commit: commit, Date: date})
 }
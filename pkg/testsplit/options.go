@@ -0,0 +1,360 @@
+package testsplit
+
+import (
+	"log/slog"
+
+	"github.com/rs/zerolog"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/logging"
+	"github.com/prgtw/tests-helper/internal/normalize"
+	"github.com/prgtw/tests-helper/internal/splitter"
+)
+
+// Option configures a Splitter.
+type Option func(*config)
+
+// config collects the settings applied by options.
+type config struct {
+	logger       zerolog.Logger
+	observer     Observer
+	progress     Progress
+	limits       map[string]int
+	cacheDir     string
+	keyFunc      KeyFunc
+	timeFunc     TimeFunc
+	rules        []ResourceRule
+	prefixes     []string
+	matchMode    MatchMode
+	dedupeMode   DedupeMode
+	controlMode  ControlMode
+	inputFormat  InputFormat
+	algorithm    Algorithm
+	granularity  Granularity
+	statsKey     StatsKey
+	statsMerge   StatsMerge
+	maxTime      float64
+	defaultTime  float64
+	zeroTime     float64
+	concurrency  int
+	maxLineBytes int
+	maxNameBytes int
+	sizeHint     int
+	strict       bool
+	dedupeNested bool
+	dropSkipped  bool
+	cleanPaths   bool
+	unicodeNFC   bool
+	inlineTimes  bool
+}
+
+// defaultConfig returns the settings used when no option overrides them.
+func defaultConfig() config {
+	return config{
+		logger:       zerolog.Nop(),
+		matchMode:    MatchExact,
+		dedupeMode:   DedupeKeep,
+		algorithm:    AlgorithmGreedy,
+		granularity:  GranularityFile,
+		statsKey:     StatsKeyFile,
+		statsMerge:   StatsMergeSum,
+		maxTime:      junit.DefaultMaxTime,
+		defaultTime:  DefaultTestTime,
+		zeroTime:     splitter.DefaultZeroTime,
+		maxLineBytes: splitter.DefaultMaxLineBytes,
+		maxNameBytes: splitter.DefaultMaxNameBytes,
+		controlMode:  ControlReject,
+		inputFormat:  InputLines,
+		dedupeNested: true,
+		dropSkipped:  true,
+		cleanPaths:   true,
+		unicodeNFC:   true,
+	}
+}
+
+// parserOptions translates the settings for the JUnit parser.
+func (c *config) parserOptions(n normalize.Normalizer) []junit.Option {
+	opts := []junit.Option{
+		junit.WithStrict(c.strict),
+		junit.WithCacheDir(c.cacheDir),
+		junit.WithMaxTime(c.maxTime),
+		junit.WithNormalizer(n),
+		junit.WithDedupeNested(c.dedupeNested),
+		junit.WithIgnoreSkipped(c.dropSkipped),
+		junit.WithGranularity(c.granularity),
+		junit.WithStatsKey(c.statsKey),
+		junit.WithMerge(c.statsMerge),
+		junit.WithKeyFunc(c.keyFunc),
+		junit.WithTimeFunc(c.timeFunc),
+		junit.WithProgress(c.progress),
+	}
+	if c.concurrency > 0 {
+		opts = append(opts, junit.WithConcurrency(c.concurrency))
+	}
+	return opts
+}
+
+// splitterOptions translates the settings for the splitter.
+func (c *config) splitterOptions(n normalize.Normalizer) []splitter.Option {
+	return []splitter.Option{
+		splitter.WithMaxLineBytes(c.maxLineBytes),
+		splitter.WithMaxNameBytes(c.maxNameBytes),
+		splitter.WithControlChars(c.controlMode),
+		splitter.WithSizeHint(c.sizeHint),
+		splitter.WithDefaultTime(c.defaultTime),
+		splitter.WithZeroTime(c.zeroTime),
+		splitter.WithInlineTimes(c.inlineTimes),
+		splitter.WithInputFormat(c.inputFormat),
+		splitter.WithNormalizer(n),
+		splitter.WithMatchMode(c.matchMode),
+		splitter.WithDedupe(c.dedupeMode),
+		splitter.WithObserver(c.observer),
+		splitter.WithProgress(c.progress),
+		splitter.WithResources(c.rules, c.limits),
+	}
+}
+
+// WithLogger sets the logger receiving progress and diagnostic events.
+// Nothing is logged by default or when logger is nil.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logging.Zerolog(logger)
+	}
+}
+
+// WithObserver sets an observer notified of every assignment as Split makes it, in order.
+// Concurrent splits notify the same observer concurrently. A *Recorder keeps the whole trace,
+// in memory or, made by NewRecorder, spilled to disk.
+func WithObserver(o Observer) Option {
+	return func(c *config) {
+		c.observer = o
+	}
+}
+
+// WithProgress sets a reporter notified after every stats file LoadTimings and LoadSources parse
+// (StageParse) and every 1,000 tests Split assigns (StageDistribute), each stage ending with
+// done equal to total. Nothing is reported by default.
+func WithProgress(p Progress) Option {
+	return func(c *config) {
+		c.progress = p
+	}
+}
+
+// WithStrictStats makes any unreadable, invalid, or truncated report an error instead of a warning.
+func WithStrictStats(strict bool) Option {
+	return func(c *config) {
+		c.strict = strict
+	}
+}
+
+// WithCacheDir caches parsed reports in dir between runs. An empty dir disables caching.
+func WithCacheDir(dir string) Option {
+	return func(c *config) {
+		c.cacheDir = dir
+	}
+}
+
+// WithConcurrency sets the maximum number of reports parsed in parallel. Defaults to GOMAXPROCS.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		c.concurrency = n
+	}
+}
+
+// WithMaxTestTime sets the ceiling, in seconds, above which a recorded time is rejected.
+// Values below or equal to 0 disable the ceiling. Defaults to one day.
+func WithMaxTestTime(seconds float64) Option {
+	return func(c *config) {
+		c.maxTime = seconds
+	}
+}
+
+// WithDedupeNested toggles skipping a parent suite's time when it repeats the sum of its
+// children sharing its file. Enabled by default.
+func WithDedupeNested(enabled bool) Option {
+	return func(c *config) {
+		c.dedupeNested = enabled
+	}
+}
+
+// WithStatsIgnoreSkipped toggles leaving out the time of test cases reported as skipped, and
+// counting suites whose test cases are all skipped as taking no time. Enabled by default.
+func WithStatsIgnoreSkipped(enabled bool) Option {
+	return func(c *config) {
+		c.dropSkipped = enabled
+	}
+}
+
+// WithKeyFunc overrides how the timing key of a report suite is chosen, e.g. to map suites
+// of a monorepo to the paths used in test lists. When fn returns ok=false, the suite's file
+// attribute is used. Keys are normalized like file attributes, except with GranularitySuite.
+// fn may be called concurrently. Reports are not cached while a hook is set.
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(c *config) {
+		c.keyFunc = fn
+	}
+}
+
+// WithTimeFunc overrides how the time of a report suite is chosen, e.g. to sum its test cases.
+// When fn returns ok=false, the suite's time attribute is used. Returned times are checked
+// against WithMaxTestTime like parsed ones. fn may be called concurrently.
+// Reports are not cached while a hook is set.
+func WithTimeFunc(fn TimeFunc) Option {
+	return func(c *config) {
+		c.timeFunc = fn
+	}
+}
+
+// WithPathNormalization toggles matching names and timing keys after cleaning their paths
+// ("./a", "a//b", "a/../b", "a\b"). Enabled by default.
+func WithPathNormalization(enabled bool) Option {
+	return func(c *config) {
+		c.cleanPaths = enabled
+	}
+}
+
+// WithStripPrefixes sets directories stripped from the start of names and timing keys, such as
+// the checkout directory in the absolute paths of some reports. The first prefix matching whole
+// path segments is stripped. It has no effect with GranularitySuite.
+func WithStripPrefixes(prefixes ...string) Option {
+	return func(c *config) {
+		c.prefixes = prefixes
+	}
+}
+
+// WithUnicodeNormalization toggles matching names and timing keys in Unicode NFC.
+// Enabled by default.
+func WithUnicodeNormalization(enabled bool) Option {
+	return func(c *config) {
+		c.unicodeNFC = enabled
+	}
+}
+
+// WithMatchMode sets how test names are matched against timing keys. Defaults to MatchExact.
+func WithMatchMode(mode MatchMode) Option {
+	return func(c *config) {
+		c.matchMode = mode
+	}
+}
+
+// WithGranularity sets what timings are keyed by. Defaults to GranularityFile; with
+// GranularitySuite, reports are keyed by suite name and test lists hold suite names, both
+// matched verbatim without path or Unicode normalization.
+func WithGranularity(g Granularity) Option {
+	return func(c *config) {
+		c.granularity = g
+	}
+}
+
+// WithStatsKey sets which report attribute timings are keyed by with GranularityFile. Defaults
+// to StatsKeyFile; StatsKeyClassname keys them by the classname of the test cases, e.g.
+// "tests.test_users" for pytest, and StatsKeyAuto by the file attribute where there is one and
+// the classname otherwise. Test lists must name tests the same way.
+func WithStatsKey(k StatsKey) Option {
+	return func(c *config) {
+		c.statsKey = k
+	}
+}
+
+// WithStatsMerge sets how the times of a test found in several reports are combined, e.g. the
+// reports of the last few builds. Defaults to StatsMergeSum; StatsMergeAvg averages over the
+// reports containing the test, StatsMergeMax keeps the longest time, and StatsMergeLatest the
+// time of the newest report, dated by its timestamp attributes or modification time.
+// StatsMergePriority keeps the time of the first report in sorted path order, and the time of
+// the first source passed to LoadSources holding the test, which later sources only fill in for.
+func WithStatsMerge(m StatsMerge) Option {
+	return func(c *config) {
+		c.statsMerge = m
+	}
+}
+
+// WithResources tags each test with the resource of the first rule whose pattern matches its name,
+// and lets a group take at most limits[tag] tests of a tag. Tags without a limit are only
+// reported. Split fails with ErrResourceLimit when the groups together cannot take the tests of
+// a tag.
+func WithResources(rules []ResourceRule, limits map[string]int) Option {
+	return func(c *config) {
+		c.rules = rules
+		c.limits = limits
+	}
+}
+
+// WithAlgorithm sets how Split assigns tests to groups. Defaults to AlgorithmGreedy, the only
+// algorithm honouring the limits of WithResources, which then always use it.
+func WithAlgorithm(alg Algorithm) Option {
+	return func(c *config) {
+		c.algorithm = alg
+	}
+}
+
+// WithDedupe sets how tests listed more than once in a test list are handled.
+// Defaults to DedupeKeep, splitting every occurrence.
+func WithDedupe(mode DedupeMode) Option {
+	return func(c *config) {
+		c.dedupeMode = mode
+	}
+}
+
+// WithInlineTimes enables per-line time overrides in test lists ("pkg/slow_test.go 45.0").
+func WithInlineTimes(enabled bool) Option {
+	return func(c *config) {
+		c.inlineTimes = enabled
+	}
+}
+
+// WithInputFormat sets how ReadTests parses test lists. With InputGroups every line is a group of
+// tests that must stay in the same group, e.g. a Bazel target: "//pkg:target<TAB>a_test.go,b_test.go".
+// The group is split as a single Test whose time is the sum of its members', see ExpandGroups.
+// Defaults to InputLines.
+func WithInputFormat(f InputFormat) Option {
+	return func(c *config) {
+		c.inputFormat = f
+	}
+}
+
+// WithMaxLineBytes sets the maximum length of a single test list line. Defaults to 4 MiB.
+func WithMaxLineBytes(n int) Option {
+	return func(c *config) {
+		c.maxLineBytes = n
+	}
+}
+
+// WithMaxNameBytes sets the maximum length of a test name. Defaults to 4096 bytes; longer names
+// fail ReadTests with ErrNameTooLong.
+func WithMaxNameBytes(n int) Option {
+	return func(c *config) {
+		c.maxNameBytes = n
+	}
+}
+
+// WithControlChars sets how test names containing control characters, such as ANSI escape
+// sequences or NUL bytes, are handled. Defaults to ControlReject, which fails ReadTests with
+// ErrControlChars; ControlStrip removes them.
+func WithControlChars(mode ControlMode) Option {
+	return func(c *config) {
+		c.controlMode = mode
+	}
+}
+
+// WithSizeHint sets the expected number of tests, used to pre-allocate memory.
+func WithSizeHint(count int) Option {
+	return func(c *config) {
+		c.sizeHint = count
+	}
+}
+
+// WithZeroTime sets the time used for tests recorded as taking zero seconds.
+// Values that are negative or not finite keep the default.
+func WithZeroTime(seconds float64) Option {
+	return func(c *config) {
+		c.zeroTime = seconds
+	}
+}
+
+// WithDefaultTime sets the time, in seconds, used for tests without historical data.
+// Values that are not positive keep the default of DefaultTestTime.
+func WithDefaultTime(seconds float64) Option {
+	return func(c *config) {
+		c.defaultTime = seconds
+	}
+}
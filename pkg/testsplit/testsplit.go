@@ -0,0 +1,384 @@
+// Package testsplit distributes tests across parallel workers based on historical execution
+// times from JUnit XML reports.
+//
+// It is the supported, semantically versioned API of tests-helper: load timings, read a test
+// list, split it into groups, and inspect the resulting distribution. Everything under
+// internal/ may change without notice.
+package testsplit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/prgtw/tests-helper/internal/junit"
+	"github.com/prgtw/tests-helper/internal/normalize"
+	"github.com/prgtw/tests-helper/internal/progress"
+	"github.com/prgtw/tests-helper/internal/splitter"
+	"github.com/prgtw/tests-helper/internal/timesource"
+	"github.com/prgtw/tests-helper/internal/worker"
+)
+
+type (
+	// Test is a single test name with the time used to schedule it.
+	Test = junit.Test
+	// TestGroup holds the members of a Test read as a group from a grouped test list, see
+	// WithInputFormat.
+	TestGroup = junit.Group
+	// Source describes where a test's time came from.
+	Source = junit.Source
+	// Group is the set of tests assigned to one worker, with their total time.
+	Group = worker.Worker
+	// Distribution summarizes how tests were spread across groups.
+	Distribution = worker.Distribution
+	// GroupStats summarizes a single group within a Distribution.
+	GroupStats = worker.Stats
+	// DirTime is the time of a group under one directory, see GroupStats.Directories.
+	DirTime = worker.DirTime
+	// TestTime is a test of a group with the running total of its times, see GroupStats.Tests.
+	TestTime = worker.TestTime
+	// Coverage lists the tests of a list without timings and the timings no test uses.
+	Coverage = splitter.Coverage
+	// StatsOptions controls what Result.Stats computes.
+	StatsOptions = worker.StatsOptions
+	// Percentile is the time at a percentile of the suite, see StatsOptions.SuitePercentiles.
+	Percentile = worker.Percentile
+	// MatchMode controls how test names are matched against timing keys.
+	MatchMode = splitter.MatchMode
+	// InputFormat selects whether a test list has a test or a group of tests per line.
+	InputFormat = splitter.InputFormat
+	// Algorithm selects how Split assigns tests to groups; see WithAlgorithm.
+	Algorithm = worker.Algorithm
+	// DedupeMode controls how tests listed more than once are handled.
+	DedupeMode = splitter.DedupeMode
+	// ControlMode controls how test names containing control characters are handled.
+	ControlMode = splitter.ControlMode
+	// Granularity selects whether timings are keyed by report file or by suite name.
+	Granularity = junit.Granularity
+	// StatsKey selects whether timings are keyed by the file or the classname attribute.
+	StatsKey = junit.StatsKey
+	// StatsMerge selects how the times of a test found in several reports are combined.
+	StatsMerge = junit.Merge
+	// ResourceRule tags the tests matching a pattern with a resource; see WithResources.
+	ResourceRule = worker.ResourceRule
+	// Metadata holds the tags of tests read by ReadMetadata.
+	Metadata = splitter.Metadata
+	// TagFilter selects tests by their tags; see Metadata.Filter.
+	TagFilter = splitter.TagFilter
+	// WeightRule multiplies the time of the tests matching a name or tag pattern; see
+	// Metadata.ApplyWeights.
+	WeightRule = splitter.WeightRule
+	// ParseError reports malformed input, a report or test list, at a position within a file.
+	ParseError = junit.ParseError
+	// Suite is a JUnit XML test suite as passed to extraction hooks.
+	Suite = junit.TestSuite
+	// Case is a JUnit XML test case as passed to extraction hooks.
+	Case = junit.TestCase
+	// KeyFunc chooses the timing key of a suite; see WithKeyFunc.
+	KeyFunc = junit.KeyFunc
+	// TimeFunc chooses the time of a suite; see WithTimeFunc.
+	TimeFunc = junit.TimeFunc
+	// Observer is notified of every assignment made while splitting; see WithObserver.
+	Observer = worker.Observer
+	// Assignment is a single assignment of a test to a group.
+	Assignment = worker.Assignment
+	// Recorder is an Observer keeping the ordered trace of assignments; see NewRecorder.
+	Recorder = worker.Recorder
+	// Progress is notified as stats files are parsed and tests distributed; see WithProgress.
+	Progress = progress.Reporter
+	// ProgressFunc adapts a function to a Progress.
+	ProgressFunc = progress.Func
+	// TimeSource provides historical test times. Implement it to feed timings from
+	// any store into LoadSources.
+	TimeSource = timesource.Source
+)
+
+const (
+	SourceStats   = junit.SourceStats   // Historical data from stats files
+	SourceDefault = junit.SourceDefault // No historical data, default time used
+	SourceInline  = junit.SourceInline  // Override given in the test list
+	SourceClamped = junit.SourceClamped // Recorded as zero seconds, raised to the zero time
+
+	MatchExact  = splitter.MatchExact  // Only identical keys match
+	MatchSuffix = splitter.MatchSuffix // Fall back to a unique timing key ending with "/"+name
+
+	AlgorithmGreedy     = worker.AlgorithmGreedy     // Every test to the least loaded group, longest first
+	AlgorithmRoundRobin = worker.AlgorithmRoundRobin // Tests dealt to the groups in turn, back and forth
+	AlgorithmKK         = worker.AlgorithmKK         // Karmarkar–Karp largest differencing
+
+	DedupeKeep  = splitter.DedupeKeep  // Every occurrence is split as a separate test
+	DedupeFirst = splitter.DedupeFirst // Only the first occurrence of a normalized name is kept
+
+	GranularityFile  = junit.GranularityFile  // Timings keyed by the suite's file attribute
+	GranularitySuite = junit.GranularitySuite // Timings keyed by the suite's name, matched verbatim
+
+	StatsKeyFile      = junit.StatsKeyFile      // Timings keyed by the file attribute
+	StatsKeyClassname = junit.StatsKeyClassname // Timings keyed by the classname attribute of test cases
+	StatsKeyAuto      = junit.StatsKeyAuto      // Timings keyed by the file attribute, else the classname
+
+	StatsMergeSum      = junit.MergeSum      // Times found in several reports added together
+	StatsMergeAvg      = junit.MergeAvg      // Times averaged over the reports containing the test
+	StatsMergeMax      = junit.MergeMax      // The longest time of the reports containing the test
+	StatsMergeLatest   = junit.MergeLatest   // The time of the newest report containing the test
+	StatsMergePriority = junit.MergePriority // The time of the first report or source containing the test
+
+	InputLines  = splitter.InputLines  // One test per line
+	InputGroups = splitter.InputGroups // One group per line: its name, a tab, and comma-separated members
+
+	ControlReject = splitter.ControlReject // Test names with control characters fail ReadTests
+	ControlStrip  = splitter.ControlStrip  // Control characters are removed from test names
+
+	StageParse      = progress.StageParse      // Progress stage: stats files parsed by LoadTimings
+	StageDistribute = progress.StageDistribute // Progress stage: tests assigned by Split
+
+	DefaultTestTime = splitter.DefaultTestTime // Time for tests without historical data
+)
+
+// Errors returned by the Splitter, for use with errors.Is.
+var (
+	ErrNoTests            = splitter.ErrNoTests          // The test list contains no test names
+	ErrControlChars       = splitter.ErrControlChars     // A test name contains control characters
+	ErrNameTooLong        = splitter.ErrNameTooLong      // A test name is longer than the maximum
+	ErrGroupFormat        = splitter.ErrGroupFormat      // A line of a grouped test list is malformed
+	ErrMetadata           = splitter.ErrMetadata         // Test metadata is not a JSON object of tag lists
+	ErrInvalidWeight      = splitter.ErrInvalidWeight    // A weight rule is malformed
+	ErrNoStatsMatched     = junit.ErrNoStatsMatched      // No report matched the given patterns
+	ErrNoUsableStats      = junit.ErrNoUsableStats       // Every matched report failed to load
+	ErrInvalidWorkerCount = worker.ErrInvalidWorkerCount // The number of groups is less than 1
+	ErrInvalidWorkerIndex = worker.ErrInvalidWorkerIndex // A group index is outside [0, groups)
+	ErrResourceLimit      = worker.ErrResourceLimit      // More tests carry a resource than the groups may take
+)
+
+// DefaultPercentiles returns the percentiles the command line reports when none are configured.
+func DefaultPercentiles() []int {
+	return worker.DefaultPercentiles()
+}
+
+// Splitter loads timings, reads test lists, and splits them into groups.
+// A Splitter is safe for concurrent use.
+type Splitter struct {
+	parser    *junit.Parser
+	loader    *timesource.Loader
+	splitter  *splitter.Splitter
+	algorithm Algorithm
+}
+
+// New creates a Splitter configured by opts.
+func New(opts ...Option) *Splitter {
+	c := defaultConfig()
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	n := normalize.New(
+		normalize.WithCleanPaths(c.cleanPaths),
+		normalize.WithUnicodeNFC(c.unicodeNFC),
+		normalize.WithStripPrefixes(c.prefixes...),
+	)
+	if c.granularity == GranularitySuite {
+		// Suite names are matched as written
+		n = normalize.Normalizer{}
+	}
+	return &Splitter{
+		parser: junit.NewParser(c.logger, c.parserOptions(n)...),
+		loader: timesource.NewLoader(c.logger,
+			timesource.WithNormalizer(n),
+			timesource.WithStrict(c.strict),
+			timesource.WithPriority(c.statsMerge == StatsMergePriority),
+		),
+		splitter:  splitter.NewSplitter(c.logger, c.splitterOptions(n)...),
+		algorithm: c.algorithm,
+	}
+}
+
+// LoadTimings parses the JUnit XML reports matching patterns, which may be file paths,
+// glob patterns, or directories scanned recursively, and returns test times keyed by file.
+// Cancelling ctx stops loading and returns the context's error.
+func (s *Splitter) LoadTimings(ctx context.Context, patterns ...string) (map[string]float64, error) {
+	times, err := s.parser.LoadFiles(ctx, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load timings: %w", err)
+	}
+	return times, nil
+}
+
+// LoadTimingsFrom parses a single JUnit XML report from r and returns test times keyed by file.
+// Cancelling ctx stops parsing and returns the context's error.
+func (s *Splitter) LoadTimingsFrom(ctx context.Context, r io.Reader) (map[string]float64, error) {
+	times, err := s.parser.LoadReader(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load timings: %w", err)
+	}
+	return times, nil
+}
+
+// JUnitFiles returns a source loading the JUnit XML reports matching patterns,
+// parsed the same way as by LoadTimings.
+func (s *Splitter) JUnitFiles(patterns ...string) TimeSource {
+	return s.parser.Files(patterns...)
+}
+
+// JUnitCases returns a source loading the number of test cases of each key from the JUnit XML
+// reports matching patterns, keyed like the times of JUnitFiles. Load it with LoadSources and
+// hand the counts to AssignCases.
+func (s *Splitter) JUnitCases(patterns ...string) TimeSource {
+	return s.parser.Cases(patterns...)
+}
+
+// LoadSources loads every source and merges their times: keys are normalized like test names,
+// and times recorded for the same key by several sources are summed, or taken from the first of
+// them with StatsMergePriority. A failing source is skipped
+// unless strict stats are enabled; an error is returned when no source could be loaded.
+func (s *Splitter) LoadSources(ctx context.Context, sources ...TimeSource) (map[string]float64, error) {
+	times, err := s.loader.Load(ctx, sources...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load timings: %w", err)
+	}
+	return times, nil
+}
+
+// FollowRenames moves the times of renamed test files to their current names in times, given
+// renames from old to new paths, e.g. from git history. Both paths are normalized like test names.
+// Times already recorded under a new name are kept. It returns the number of times moved.
+func (s *Splitter) FollowRenames(times map[string]float64, renames map[string]string) int {
+	return s.loader.Rename(times, renames)
+}
+
+// ReadTests reads a test list from r, one name per line, and assigns each test its time
+// from timings, an inline override, or DefaultTestTime. With InputGroups every line is a group
+// whose members are timed that way and whose time is their sum.
+func (s *Splitter) ReadTests(r io.Reader, timings map[string]float64) ([]Test, error) {
+	tests, err := s.splitter.ReadTests(r, timings)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tests: %w", err)
+	}
+	return tests, nil
+}
+
+// ReadMetadata reads the tags of tests from a JSON object mapping test names to lists of
+// strings. Names are normalized like those of ReadTests, so the tags can filter and weigh the
+// tests it returns.
+func (s *Splitter) ReadMetadata(r io.Reader) (Metadata, error) {
+	meta, err := s.splitter.ReadMetadata(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// ParseWeightRule parses a weight rule given as "PATTERN=FACTOR", matching test names, or
+// "tag:PATTERN=FACTOR", matching tags.
+func ParseWeightRule(s string) (WeightRule, error) {
+	return splitter.ParseWeightRule(s)
+}
+
+// Coverage compares tests read by ReadTests with the timings they were read with: the tests that
+// got the default time, and the timing keys no test matched, e.g. of deleted test files.
+func (s *Splitter) Coverage(tests []Test, timings map[string]float64) Coverage {
+	return s.splitter.Coverage(tests, timings)
+}
+
+// AssignCases sets the Cases of tests read by ReadTests from test case counts loaded from
+// JUnitCases, matched like the timings, so StatsOptions.WeightByCases can weigh the worker
+// percentiles by them. It returns the number of tests given a count.
+func (s *Splitter) AssignCases(tests []Test, cases map[string]float64) int {
+	return s.splitter.AssignCases(tests, cases)
+}
+
+// ExpandGroups returns tests with every group read with InputGroups replaced by its members,
+// in order, e.g. to run the members of the groups a worker was assigned.
+func ExpandGroups(tests []Test) []Test {
+	return splitter.ExpandGroups(tests)
+}
+
+// NewRecorder returns a Recorder spilling its trace to a temporary file whenever it holds more
+// than spillAfter assignments in memory, e.g. to observe splits of very long test lists. The trace
+// is read back by Assignments and Replay, and the file removed by Reset. A spillAfter of 0 or
+// less never spills, like the zero Recorder.
+func NewRecorder(spillAfter int) *Recorder {
+	return worker.NewRecorder(spillAfter)
+}
+
+// Split distributes tests across the given number of groups, balancing their total times.
+// The tests slice is not modified. It fails with ErrInvalidWorkerCount when groups is less than 1.
+func (s *Splitter) Split(tests []Test, groups int) (*Result, error) {
+	allocator, err := s.splitter.Split(tests, groups, s.algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot split tests: %w", err)
+	}
+	return &Result{allocator: allocator}, nil
+}
+
+// SplitInPlace is like Split but sorts tests in place instead of copying them first.
+// Use it when the input order is not needed afterwards.
+func (s *Splitter) SplitInPlace(tests []Test, groups int) (*Result, error) {
+	allocator, err := s.splitter.SplitInPlace(tests, groups, s.algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot split tests: %w", err)
+	}
+	return &Result{allocator: allocator}, nil
+}
+
+// Resume returns a Result holding copies of groups from an earlier split, e.g. read back from a
+// manifest, so the plan can be changed with Result.Drain. It fails with ErrInvalidWorkerCount
+// when groups is empty.
+func (s *Splitter) Resume(groups []Group) (*Result, error) {
+	allocator, err := s.splitter.Resume(groups)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resume split: %w", err)
+	}
+	return &Result{allocator: allocator}, nil
+}
+
+// Result is the outcome of Split.
+type Result struct {
+	allocator *worker.Allocator
+}
+
+// Len returns the number of groups.
+func (r *Result) Len() int {
+	return len(r.allocator.GetWorkersRef())
+}
+
+// Group returns a copy of the group at index, or nil if there is none.
+func (r *Result) Group(index int) *Group {
+	return r.allocator.GetWorker(index)
+}
+
+// GroupRef returns the group at index without copying it, or nil if there is none.
+// The returned group is shared with the Result and must not be modified.
+func (r *Result) GroupRef(index int) *Group {
+	return r.allocator.GetWorkerRef(index)
+}
+
+// Groups returns copies of all groups.
+func (r *Result) Groups() []Group {
+	return r.allocator.GetWorkers()
+}
+
+// Stats computes distribution statistics across all groups.
+func (r *Result) Stats(opts StatsOptions) Distribution {
+	return r.allocator.GetStatsWithOptions(opts)
+}
+
+// Drain removes the group at index, e.g. after losing its runner, and spreads its tests across
+// the remaining groups, longest first onto the least loaded, leaving their own tests in place.
+// Groups after index move down by one. It returns where each moved test went, and fails with
+// ErrInvalidWorkerIndex for an unknown group or ErrInvalidWorkerCount for the last one.
+// Drain must not run concurrently with other methods of the Result.
+func (r *Result) Drain(index int) ([]Assignment, error) {
+	moved, err := r.allocator.Drain(index)
+	if err != nil {
+		return nil, fmt.Errorf("cannot drain group: %w", err)
+	}
+	return moved, nil
+}
+
+// Trim cuts every group down to budget seconds by deferring its cheapest tests; a group's most
+// expensive test is never deferred, so a group can stay over budget because of that test alone.
+// The kept tests stay in order and Stats reflects them afterwards. It returns the deferred tests
+// of every group, nil for groups already within budget.
+// Trim must not run concurrently with other methods of the Result.
+func (r *Result) Trim(budget float64) [][]Test {
+	return r.allocator.Trim(budget)
+}
@@ -0,0 +1,441 @@
+package testsplit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prgtw/tests-helper/pkg/testsplit"
+)
+
+func TestSplitter_EndToEnd(t *testing.T) {
+	s := testsplit.New()
+
+	times, err := s.LoadTimings(t.Context(), "../../testdata/junit/example1.xml")
+	if err != nil {
+		t.Fatalf("LoadTimings failed: %v", err)
+	}
+
+	input := "pkg/service/auth_test.go\npkg/service/user_test.go\npkg/api/handler_test.go\nnew_test.go\n"
+	tests, err := s.ReadTests(strings.NewReader(input), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+	if tests[3].Source != testsplit.SourceDefault || tests[3].Time != testsplit.DefaultTestTime {
+		t.Errorf("Unknown test = %+v, want the default time", tests[3])
+	}
+
+	result, err := s.Split(tests, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if result.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", result.Len())
+	}
+	if tests[0].Name != "pkg/service/auth_test.go" {
+		t.Errorf("Split reordered the input: first test is %q", tests[0].Name)
+	}
+
+	assigned := 0
+	for _, g := range result.Groups() {
+		assigned += len(g.Tests)
+	}
+	if assigned != len(tests) {
+		t.Errorf("Assigned %d tests, want %d", assigned, len(tests))
+	}
+
+	// The slowest test gets a group of its own
+	if g := result.Group(0); len(g.Tests) != 1 || g.Tests[0].Name != "pkg/api/handler_test.go" {
+		t.Errorf("Group(0) = %+v, want only pkg/api/handler_test.go", g.Tests)
+	}
+	if result.Group(2) != nil {
+		t.Error("Group(2) should be nil for 2 groups")
+	}
+
+	stats := result.Stats(testsplit.StatsOptions{})
+	if len(stats.Workers) != 2 {
+		t.Errorf("Stats has %d groups, want 2", len(stats.Workers))
+	}
+}
+
+func TestSplitter_GroupIsCopy(t *testing.T) {
+	s := testsplit.New()
+	tests, err := s.ReadTests(strings.NewReader("a_test.go\nb_test.go\n"), nil)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+	result, err := s.SplitInPlace(tests, 1)
+	if err != nil {
+		t.Fatalf("SplitInPlace failed: %v", err)
+	}
+
+	g := result.Group(0)
+	g.Tests[0].Name = "changed"
+	if result.GroupRef(0).Tests[0].Name == "changed" {
+		t.Error("Modifying the group returned by Group changed the result")
+	}
+}
+
+func TestSplitter_LoadTimingsFrom(t *testing.T) {
+	s := testsplit.New(testsplit.WithPathNormalization(false))
+
+	f, err := os.Open("../../testdata/junit/example2.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	times, err := s.LoadTimingsFrom(t.Context(), f)
+	if err != nil {
+		t.Fatalf("LoadTimingsFrom failed: %v", err)
+	}
+	if len(times) == 0 {
+		t.Error("LoadTimingsFrom returned no times")
+	}
+
+	_, err = testsplit.New(testsplit.WithStrictStats(true)).LoadTimingsFrom(t.Context(), strings.NewReader("not xml"))
+	if err == nil {
+		t.Error("LoadTimingsFrom should fail on invalid XML")
+	}
+}
+
+func TestSplitter_Errors(t *testing.T) {
+	s := testsplit.New(testsplit.WithStrictStats(true), testsplit.WithInlineTimes(true))
+
+	t.Run("invalid group count", func(t *testing.T) {
+		tests := []testsplit.Test{{Name: "a_test.go", Key: "a_test.go", Time: 1}}
+		for _, groups := range []int{0, -1} {
+			if _, err := s.Split(tests, groups); !errors.Is(err, testsplit.ErrInvalidWorkerCount) {
+				t.Errorf("Split(%d): got %v, want ErrInvalidWorkerCount", groups, err)
+			}
+		}
+	})
+
+	t.Run("no tests", func(t *testing.T) {
+		if _, err := s.ReadTests(strings.NewReader("# empty\n"), nil); !errors.Is(err, testsplit.ErrNoTests) {
+			t.Errorf("Got %v, want ErrNoTests", err)
+		}
+	})
+
+	t.Run("no matching reports", func(t *testing.T) {
+		_, err := s.LoadTimings(t.Context(), filepath.Join(t.TempDir(), "*.xml"))
+		if !errors.Is(err, testsplit.ErrNoStatsMatched) {
+			t.Errorf("Got %v, want ErrNoStatsMatched", err)
+		}
+	})
+
+	t.Run("malformed test list", func(t *testing.T) {
+		_, err := s.ReadTests(strings.NewReader("a_test.go\nb_test.go 0\n"), nil)
+		var parseErr *testsplit.ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != 2 {
+			t.Errorf("Got %v, want a ParseError on line 2", err)
+		}
+	})
+}
+
+func TestSplitter_MatchMode(t *testing.T) {
+	s := testsplit.New(testsplit.WithMatchMode(testsplit.MatchSuffix))
+	times := map[string]float64{"repo/pkg/a_test.go": 7}
+
+	tests, err := s.ReadTests(strings.NewReader("pkg/a_test.go\n"), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+	if tests[0].Time != 7 || tests[0].Source != testsplit.SourceStats {
+		t.Errorf("Test = %+v, want time 7 from stats", tests[0])
+	}
+}
+
+func TestSplitter_DefaultTimeAndDedupe(t *testing.T) {
+	s := testsplit.New(testsplit.WithDefaultTime(3), testsplit.WithDedupe(testsplit.DedupeFirst))
+
+	times := map[string]float64{"b_test.go": 1}
+
+	tests, err := s.ReadTests(strings.NewReader("a_test.go\nb_test.go\na_test.go\n"), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+	if len(tests) != 2 {
+		t.Fatalf("Got %d tests, want the repeated a_test.go dropped", len(tests))
+	}
+	if tests[0].Time != 3 || tests[0].Source != testsplit.SourceDefault {
+		t.Errorf("Test = %+v, want the default time 3", tests[0])
+	}
+}
+
+func TestSplitter_InputGroups(t *testing.T) {
+	s := testsplit.New(testsplit.WithInputFormat(testsplit.InputGroups))
+	times := map[string]float64{"a_test.go": 5, "b_test.go": 4, "c_test.go": 3}
+
+	input := "//:ab\ta_test.go,b_test.go\n//:c\tc_test.go\n//:new\tnew_test.go\n"
+	tests, err := s.ReadTests(strings.NewReader(input), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+	result, err := s.Split(tests, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	first := result.Group(0)
+	if len(first.Tests) != 1 || first.Tests[0].Name != "//:ab" || first.Total != 9 {
+		t.Errorf("Group 0 = %+v, want //:ab alone with 9s", first)
+	}
+	members := testsplit.ExpandGroups(first.Tests)
+	if len(members) != 2 || members[0].Name != "a_test.go" || members[1].Name != "b_test.go" {
+		t.Errorf("Members = %+v, want a_test.go and b_test.go", members)
+	}
+}
+
+func TestSplitter_Metadata(t *testing.T) {
+	s := testsplit.New(testsplit.WithInlineTimes(true))
+	meta, err := s.ReadMetadata(strings.NewReader(`{"./a_test.go": ["slow"], "b_test.go": ["db"]}`))
+	if err != nil {
+		t.Fatalf("ReadMetadata failed: %v", err)
+	}
+	tests, err := s.ReadTests(strings.NewReader("a_test.go 2\nb_test.go 3\nc_test.go 4\n"), nil)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+
+	tests = meta.Filter(tests, testsplit.TagFilter{Exclude: []string{"slow"}})
+	rule, err := testsplit.ParseWeightRule("tag:db=2")
+	if err != nil {
+		t.Fatalf("ParseWeightRule failed: %v", err)
+	}
+	if n := meta.ApplyWeights(tests, []testsplit.WeightRule{rule}); n != 1 {
+		t.Errorf("ApplyWeights weighted %d tests, want 1", n)
+	}
+	if len(tests) != 2 || tests[0].Name != "b_test.go" || tests[0].Time != 6 || tests[1].Time != 4 {
+		t.Errorf("Tests = %+v, want b_test.go weighted to 6s and c_test.go", tests)
+	}
+
+	if _, err := s.ReadMetadata(strings.NewReader(`{"a_test.go": "slow"}`)); !errors.Is(err, testsplit.ErrMetadata) {
+		t.Errorf("Got %v, want ErrMetadata", err)
+	}
+	if _, err := testsplit.ParseWeightRule("tag:db"); !errors.Is(err, testsplit.ErrInvalidWeight) {
+		t.Errorf("Got %v, want ErrInvalidWeight", err)
+	}
+}
+
+func TestSplitter_WithAlgorithm(t *testing.T) {
+	list := "a 5\nb 5\nc 4\nd 4\ne 3\nf 3\ng 3\n"
+	busiest := func(alg testsplit.Algorithm) float64 {
+		s := testsplit.New(testsplit.WithInlineTimes(true), testsplit.WithAlgorithm(alg))
+		tests, err := s.ReadTests(strings.NewReader(list), nil)
+		if err != nil {
+			t.Fatalf("ReadTests failed: %v", err)
+		}
+		result, err := s.Split(tests, 3)
+		if err != nil {
+			t.Fatalf("Split failed: %v", err)
+		}
+		most := 0.0
+		for _, group := range result.Groups() {
+			most = max(most, group.Total)
+		}
+		return most
+	}
+	if greedy, kk := busiest(testsplit.AlgorithmGreedy), busiest(testsplit.AlgorithmKK); greedy != 11 || kk != 10 {
+		t.Errorf("Busiest group: greedy %g, kk %g, want 11 and 10", greedy, kk)
+	}
+}
+
+func TestSplitter_KeyFunc(t *testing.T) {
+	s := testsplit.New(testsplit.WithKeyFunc(func(suite testsplit.Suite, _ *testsplit.Case) (string, bool) {
+		if suite.File == "" {
+			return "", false
+		}
+		return "services/api/" + suite.File, true
+	}))
+
+	report := `<testsuites><testsuite file="a_test.go" time="4"/><testsuite name="no file" time="1"/></testsuites>`
+	times, err := s.LoadTimingsFrom(t.Context(), strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("LoadTimingsFrom failed: %v", err)
+	}
+	if len(times) != 1 || times["services/api/a_test.go"] != 4 {
+		t.Errorf("Timings = %v, want only services/api/a_test.go", times)
+	}
+}
+
+// staticSource is a TimeSource implemented outside the library.
+type staticSource map[string]float64
+
+func (s staticSource) Load(context.Context) (map[string]float64, error) { return s, nil }
+
+func (s staticSource) Describe() string { return "static" }
+
+func TestSplitter_LoadSources(t *testing.T) {
+	s := testsplit.New()
+
+	times, err := s.LoadSources(t.Context(),
+		s.JUnitFiles("../../testdata/junit/example1.xml"),
+		staticSource{"pkg/service/auth_test.go": 1, "./custom_test.go": 2},
+	)
+	if err != nil {
+		t.Fatalf("LoadSources failed: %v", err)
+	}
+	if got := times["pkg/service/auth_test.go"]; got < 6.233 || got > 6.235 {
+		t.Errorf("auth_test.go = %v, want the sum of both sources (6.234)", got)
+	}
+	if times["custom_test.go"] != 2 {
+		t.Errorf("custom_test.go = %v, want 2 under its normalized key", times["custom_test.go"])
+	}
+}
+
+// recordingHandler is a slog.Handler keeping the messages of every record at or above its level.
+type recordingHandler struct {
+	mu       sync.Mutex
+	messages []string
+	counts   map[string]int64
+	level    slog.Level
+}
+
+func (h *recordingHandler) Enabled(_ context.Context, level slog.Level) bool { return level >= h.level }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "count" {
+			h.counts[r.Message] = a.Value.Int64()
+		}
+		return true
+	})
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func TestSplitter_WithLogger(t *testing.T) {
+	h := &recordingHandler{counts: make(map[string]int64), level: slog.LevelInfo}
+	s := testsplit.New(testsplit.WithLogger(slog.New(h)))
+
+	times, err := s.LoadTimings(t.Context(), "../../testdata/junit/example1.xml")
+	if err != nil {
+		t.Fatalf("LoadTimings failed: %v", err)
+	}
+	tests, err := s.ReadTests(strings.NewReader("pkg/service/auth_test.go\nnew_test.go\n"), times)
+	if err != nil {
+		t.Fatalf("ReadTests failed: %v", err)
+	}
+	if _, err = s.Split(tests, 2); err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	if h.counts["Loaded test times"] != 3 {
+		t.Errorf("Loaded test times: got count %d, want 3 (messages %v)", h.counts["Loaded test times"], h.messages)
+	}
+	if h.counts["Read tests from input"] != 2 {
+		t.Errorf("Read tests from input: got count %d, want 2", h.counts["Read tests from input"])
+	}
+	if slices.Contains(h.messages, "No historical data, using default time") {
+		t.Error("Debug events should not reach an info-level handler")
+	}
+
+	// A nil logger is the same as none
+	if _, err = testsplit.New(testsplit.WithLogger(nil)).Split(tests, 1); err != nil {
+		t.Errorf("Split with a nil logger failed: %v", err)
+	}
+}
+
+func TestSplitter_WithObserver(t *testing.T) {
+	var recorder testsplit.Recorder
+	s := testsplit.New(testsplit.WithObserver(&recorder))
+	tests := []testsplit.Test{{Name: "a_test.go", Time: 1}, {Name: "b_test.go", Time: 3}, {Name: "c_test.go", Time: 2}}
+
+	if _, err := s.Split(tests, 2); err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	// Tests are assigned longest first
+	var got []string
+	for _, a := range recorder.Assignments() {
+		got = append(got, fmt.Sprintf("%s->%d", a.Test.Name, a.Worker))
+	}
+	if want := []string{"b_test.go->0", "c_test.go->1", "a_test.go->1"}; !slices.Equal(got, want) {
+		t.Errorf("Trace = %v, want %v", got, want)
+	}
+
+	// A spilling recorder reads the same trace back
+	spilling := testsplit.NewRecorder(1)
+	defer spilling.Reset()
+	if _, err := testsplit.New(testsplit.WithObserver(spilling)).Split(tests, 2); err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if got, want := spilling.Assignments(), recorder.Assignments(); !slices.Equal(got, want) {
+		t.Errorf("Spilled trace = %v, want %v", got, want)
+	}
+}
+
+func TestSplitter_WithProgress(t *testing.T) {
+	var got []string
+	s := testsplit.New(testsplit.WithProgress(testsplit.ProgressFunc(func(stage string, done, total int) {
+		got = append(got, fmt.Sprintf("%s %d/%d", stage, done, total))
+	})))
+
+	if _, err := s.LoadTimings(t.Context(), "../../testdata/junit/example*.xml"); err != nil {
+		t.Fatalf("LoadTimings failed: %v", err)
+	}
+	tests := make([]testsplit.Test, 1500)
+	for i := range tests {
+		tests[i] = testsplit.Test{Name: fmt.Sprintf("t%d_test.go", i), Time: 1}
+	}
+	if _, err := s.Split(tests, 4); err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	want := []string{"parse 1/2", "parse 2/2", "distribute 1000/1500", "distribute 1500/1500"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Progress = %v, want %v", got, want)
+	}
+}
+
+func TestResult_Drain(t *testing.T) {
+	var recorder testsplit.Recorder
+	s := testsplit.New(testsplit.WithObserver(&recorder))
+	groups := []testsplit.Group{
+		{Tests: []testsplit.Test{{Name: "a_test.go", Time: 5}}, Total: 5},
+		{Tests: []testsplit.Test{{Name: "b_test.go", Time: 1}, {Name: "c_test.go", Time: 3}}, Total: 4},
+		{Tests: []testsplit.Test{{Name: "d_test.go", Time: 2}}, Total: 2},
+	}
+	result, err := s.Resume(groups)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	moved, err := result.Drain(1)
+	if err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	var got []string
+	for _, a := range moved {
+		got = append(got, fmt.Sprintf("%s->%d", a.Test.Name, a.Worker))
+	}
+	if want := []string{"c_test.go->1", "b_test.go->0"}; !slices.Equal(got, want) {
+		t.Errorf("Moved = %v, want %v", got, want)
+	}
+	if len(recorder.Assignments()) != 2 {
+		t.Errorf("Observer saw %d assignments, want 2", len(recorder.Assignments()))
+	}
+	if result.Len() != 2 || result.GroupRef(0).Tests[0].Name != "a_test.go" || len(groups[1].Tests) != 2 {
+		t.Errorf("Groups = %+v, want two with a_test.go kept first and the input untouched", result.Groups())
+	}
+
+	if _, err = result.Drain(5); !errors.Is(err, testsplit.ErrInvalidWorkerIndex) {
+		t.Errorf("Drain(5) error = %v, want ErrInvalidWorkerIndex", err)
+	}
+	if _, err = s.Resume(nil); !errors.Is(err, testsplit.ErrInvalidWorkerCount) {
+		t.Errorf("Resume(nil) error = %v, want ErrInvalidWorkerCount", err)
+	}
+}